@@ -0,0 +1,55 @@
+package exported
+
+import (
+	"bytes"
+	"reflect"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/cbor"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/multisig"
+)
+
+// Decodes the parameters of a method of a builtin actor, returning a pointer to the method's parameter type.
+// Returns false if the code is not that of a builtin actor or the actor has no such method.
+// Sends (method 0) take no parameters, and so are not decoded.
+func DecodeMethodParams(code cid.Cid, method abi.MethodNum, params []byte) (interface{}, bool, error) {
+	for _, actor := range BuiltinActors() {
+		if !actor.Code().Equals(code) {
+			continue
+		}
+		exports := actor.Exports()
+		if int(method) >= len(exports) || exports[method] == nil {
+			return nil, false, nil
+		}
+		paramsType := reflect.TypeOf(exports[method]).In(1).Elem()
+		decoded, ok := reflect.New(paramsType).Interface().(cbor.Unmarshaler)
+		if !ok {
+			return nil, false, xerrors.Errorf("parameters %v of method %d of actor %v cannot be decoded", paramsType, method, code)
+		}
+		if err := decoded.UnmarshalCBOR(bytes.NewReader(params)); err != nil {
+			return nil, false, xerrors.Errorf("failed to decode parameters of method %d of actor %v: %w", method, code, err)
+		}
+		return decoded, true, nil
+	}
+	return nil, false, nil
+}
+
+// Returns a decoder of the parameters of multisig transactions which invoke methods of builtin actors,
+// given the code of the actor at an address.
+func MultisigParamsDecoder(codeOf func(addr.Address) (cid.Cid, bool, error)) multisig.ParamsDecoder {
+	return func(to addr.Address, method abi.MethodNum, params []byte) (interface{}, error) {
+		code, found, err := codeOf(to)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to get code of %v: %w", to, err)
+		}
+		if !found {
+			return nil, nil
+		}
+		decoded, _, err := DecodeMethodParams(code, method, params)
+		return decoded, err
+	}
+}
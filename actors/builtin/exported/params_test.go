@@ -0,0 +1,62 @@
+package exported
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/cbor"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/multisig"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestDecodeMethodParams(t *testing.T) {
+	t.Run("parameters of every builtin method can be decoded", func(t *testing.T) {
+		for _, actor := range BuiltinActors() {
+			for i, m := range actor.Exports() {
+				if m == nil {
+					continue
+				}
+				paramsType := reflect.TypeOf(m).In(1)
+				assert.True(t, paramsType.Implements(reflect.TypeOf((*cbor.Unmarshaler)(nil)).Elem()),
+					"parameters %v of method %d of actor %v", paramsType, i, actor.Code())
+			}
+		}
+	})
+
+	t.Run("decodes parameters of a known method", func(t *testing.T) {
+		params := multisig.AddSignerParams{Signer: tutil.NewIDAddr(t, 100), Increase: true}
+		buf := bytes.Buffer{}
+		require.NoError(t, params.MarshalCBOR(&buf))
+
+		decoded, ok, err := DecodeMethodParams(builtin.MultisigActorCodeID, builtin.MethodsMultisig.AddSigner, buf.Bytes())
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, &params, decoded)
+
+		_, _, err = DecodeMethodParams(builtin.MultisigActorCodeID, builtin.MethodsMultisig.AddSigner, []byte{0x80})
+		assert.Error(t, err)
+	})
+
+	t.Run("does not decode unknown methods", func(t *testing.T) {
+		for _, tc := range []struct {
+			code   cid.Cid
+			method abi.MethodNum
+		}{
+			{builtin.MultisigActorCodeID, builtin.MethodSend},
+			{builtin.MultisigActorCodeID, abi.MethodNum(1000)},
+			{tutil.MakeCID("unknown", nil), builtin.MethodsMultisig.AddSigner},
+		} {
+			decoded, ok, err := DecodeMethodParams(tc.code, tc.method, nil)
+			require.NoError(t, err)
+			assert.False(t, ok)
+			assert.Nil(t, decoded)
+		}
+	})
+}
@@ -1,7 +1,18 @@
 package market
 
 import (
+	"bytes"
+	"io"
+	"unicode/utf8"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	acrypto "github.com/filecoin-project/go-state-types/crypto"
 	market0 "github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
 )
 
 //var PieceCIDPrefix = cid.Prefix{
@@ -12,6 +23,93 @@ import (
 //}
 var PieceCIDPrefix = market0.PieceCIDPrefix
 
+// A client chosen label for a deal, which is either a valid UTF-8 string or arbitrary bytes.
+// Strings are encoded as CBOR text strings and bytes as CBOR byte strings, so labels which are not valid UTF-8
+// round-trip through tools which decode text strings as UTF-8.
+// The zero value is the empty string label.
+type DealLabel struct {
+	bs        string
+	notString bool
+}
+
+// Makes a string label, which must be valid UTF-8.
+func NewLabelFromString(s string) (DealLabel, error) {
+	if !utf8.ValidString(s) {
+		return DealLabel{}, xerrors.Errorf("label string is not valid UTF-8")
+	}
+	return DealLabel{bs: s}, nil
+}
+
+// Makes a bytes label.
+func NewLabelFromBytes(b []byte) DealLabel {
+	return DealLabel{bs: string(b), notString: true}
+}
+
+func (l DealLabel) IsString() bool {
+	return !l.notString
+}
+
+func (l DealLabel) IsBytes() bool {
+	return l.notString
+}
+
+// Returns a string label's value. It is an error to call this on a bytes label.
+func (l DealLabel) ToString() (string, error) {
+	if l.notString {
+		return "", xerrors.Errorf("label is not a string")
+	}
+	return l.bs, nil
+}
+
+// Returns the label's value as bytes, of either a string or bytes label.
+func (l DealLabel) ToBytes() []byte {
+	return []byte(l.bs)
+}
+
+// The length of the label's value in bytes.
+func (l DealLabel) Length() int {
+	return len(l.bs)
+}
+
+func (l *DealLabel) MarshalCBOR(w io.Writer) error {
+	if uint64(len(l.bs)) > cbg.MaxLength {
+		return xerrors.Errorf("label is too long (%d > %d)", len(l.bs), cbg.MaxLength)
+	}
+	majorType := byte(cbg.MajTextString)
+	if l.notString {
+		majorType = cbg.MajByteString
+	}
+	scratch := make([]byte, 9)
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, majorType, uint64(len(l.bs))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, l.bs)
+	return err
+}
+
+func (l *DealLabel) UnmarshalCBOR(r io.Reader) error {
+	scratch := make([]byte, 8)
+	maj, length, err := cbg.CborReadHeaderBuf(r, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajTextString && maj != cbg.MajByteString {
+		return xerrors.Errorf("unexpected major type %d for label", maj)
+	}
+	if length > cbg.MaxLength {
+		return xerrors.Errorf("label is too long (%d > %d)", length, cbg.MaxLength)
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	if maj == cbg.MajTextString && !utf8.Valid(buf) {
+		return xerrors.Errorf("label string is not valid UTF-8")
+	}
+	*l = DealLabel{bs: string(buf), notString: maj == cbg.MajByteString}
+	return nil
+}
+
 // Note: Deal Collateral is only released and returned to clients and miners
 // when the storage deal stops counting towards power. In the current iteration,
 // it will be released when the sector containing the storage deals expires,
@@ -20,33 +118,54 @@ var PieceCIDPrefix = market0.PieceCIDPrefix
 // minimal deals that last for a long time.
 // Note: ClientCollateralPerEpoch may not be needed and removed pending future confirmation.
 // There will be a Minimum value for both client and provider deal collateral.
-//type DealProposal struct {
-//	PieceCID     cid.Cid `checked:"true"` // Checked in validateDeal, CommP
-//	PieceSize    abi.PaddedPieceSize
-//	VerifiedDeal bool
-//	Client       addr.Address
-//	Provider     addr.Address
-//
-//	// Label is an arbitrary client chosen label to apply to the deal
-//	// TODO: Limit the size of this: https://github.com/filecoin-project/specs-actors/issues/897
-//	Label string
-//
-//	// Nominal start epoch. Deal payment is linear between StartEpoch and EndEpoch,
-//	// with total amount StoragePricePerEpoch * (EndEpoch - StartEpoch).
-//	// Storage deal must appear in a sealed (proven) sector no later than StartEpoch,
-//	// otherwise it is invalid.
-//	StartEpoch           abi.ChainEpoch
-//	EndEpoch             abi.ChainEpoch
-//	StoragePricePerEpoch abi.TokenAmount
-//
-//	ProviderCollateral abi.TokenAmount
-//	ClientCollateral   abi.TokenAmount
-//}
-type DealProposal = market0.DealProposal
+type DealProposal struct {
+	PieceCID     cid.Cid `checked:"true"` // Checked in validateDeal, CommP
+	PieceSize    abi.PaddedPieceSize
+	VerifiedDeal bool
+	Client       addr.Address
+	Provider     addr.Address
+
+	// Label is an arbitrary client chosen label to apply to the deal
+	Label DealLabel
+
+	// Nominal start epoch. Deal payment is linear between StartEpoch and EndEpoch,
+	// with total amount StoragePricePerEpoch * (EndEpoch - StartEpoch).
+	// Storage deal must appear in a sealed (proven) sector no later than StartEpoch,
+	// otherwise it is invalid.
+	StartEpoch           abi.ChainEpoch
+	EndEpoch             abi.ChainEpoch
+	StoragePricePerEpoch abi.TokenAmount
+
+	ProviderCollateral abi.TokenAmount
+	ClientCollateral   abi.TokenAmount
+}
 
 // ClientDealProposal is a DealProposal signed by a client
-// type ClientDealProposal struct {
-// 	Proposal        DealProposal
-// 	ClientSignature crypto.Signature
-// }
-type ClientDealProposal = market0.ClientDealProposal
+type ClientDealProposal struct {
+	Proposal        DealProposal
+	ClientSignature acrypto.Signature
+}
+
+func (p *DealProposal) Duration() abi.ChainEpoch {
+	return p.EndEpoch - p.StartEpoch
+}
+
+func (p *DealProposal) TotalStorageFee() abi.TokenAmount {
+	return big.Mul(p.StoragePricePerEpoch, big.NewInt(int64(p.Duration())))
+}
+
+func (p *DealProposal) ClientBalanceRequirement() abi.TokenAmount {
+	return big.Add(p.ClientCollateral, p.TotalStorageFee())
+}
+
+func (p *DealProposal) ProviderBalanceRequirement() abi.TokenAmount {
+	return p.ProviderCollateral
+}
+
+func (p *DealProposal) Cid() (cid.Cid, error) {
+	buf := new(bytes.Buffer)
+	if err := p.MarshalCBOR(buf); err != nil {
+		return cid.Undef, err
+	}
+	return abi.CidBuilder.Sum(buf.Bytes())
+}
@@ -1,6 +1,7 @@
 package market
 
 import (
+	"encoding/binary"
 	"sort"
 
 	addr "github.com/filecoin-project/go-address"
@@ -12,6 +13,7 @@ import (
 	rtt "github.com/filecoin-project/go-state-types/rt"
 	market0 "github.com/filecoin-project/specs-actors/actors/builtin/market"
 	"github.com/ipfs/go-cid"
+	"github.com/minio/blake2b-simd"
 	cbg "github.com/whyrusleeping/cbor-gen"
 	"golang.org/x/xerrors"
 
@@ -38,6 +40,11 @@ func (a Actor) Exports() []interface{} {
 		7:                         a.OnMinerSectorsTerminate,
 		8:                         a.ComputeDataCommitment,
 		9:                         a.CronTick,
+		10:                        a.SettleDealPayments,
+		11:                        a.ActivateSignedDeals,
+		12:                        a.AddBalances,
+		13:                        a.WithdrawBalances,
+		14:                        a.PurgeExpiredProposals,
 	}
 }
 
@@ -137,10 +144,137 @@ func (a Actor) AddBalance(rt Runtime, providerOrClientAddress *addr.Address) *ab
 	return nil
 }
 
-// type PublishStorageDealsParams struct {
-// 	Deals []ClientDealProposal
-// }
-type PublishStorageDealsParams = market0.PublishStorageDealsParams
+// An amount to be deposited into or withdrawn from the balance held in escrow for a client or provider.
+type EscrowAmount struct {
+	ProviderOrClientAddress addr.Address
+	Amount                  abi.TokenAmount
+}
+
+type AddBalancesParams struct {
+	Deposits []EscrowAmount
+}
+
+// Deposits the received value into many balances held in escrow, as AddBalance would into each.
+// The value received must equal the total of the amounts deposited.
+func (a Actor) AddBalances(rt Runtime, params *AddBalancesParams) *abi.EmptyValue {
+	// only signing parties can add balance for client AND provider.
+	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
+	validateBalanceBatch(rt, len(params.Deposits))
+
+	total := big.Zero()
+	deltas := make(map[addr.Address]abi.TokenAmount, len(params.Deposits))
+	for _, d := range params.Deposits {
+		builtin.RequireParam(rt, d.Amount.GreaterThan(big.Zero()), "balance to add to %v must be greater than zero", d.ProviderOrClientAddress)
+		nominal, _, _ := escrowAddress(rt, d.ProviderOrClientAddress)
+		total = big.Add(total, d.Amount)
+		deltas[nominal] = addToDelta(deltas, nominal, d.Amount)
+	}
+	builtin.RequireParam(rt, total.Equals(rt.ValueReceived()), "value received %v does not match total deposits %v",
+		rt.ValueReceived(), total)
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withEscrowTable(WritePermission).
+			withLockedTable(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		_, err = msm.escrowTable.ApplyDeltas(deltas, adt.NoDust)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add balances to escrow table")
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+	return nil
+}
+
+type WithdrawBalancesParams struct {
+	Withdrawals []EscrowAmount
+}
+
+type WithdrawBalancesReturn struct {
+	// The amount withdrawn for each withdrawal, which is less than requested if less was available.
+	AmountsWithdrawn []abi.TokenAmount
+}
+
+// Attempts to withdraw from many balances held in escrow, as WithdrawBalance would from each.
+// The caller must be permitted to withdraw from every balance: a client itself, or a provider's owner or worker.
+// The funds withdrawn are sent to the recipient of each balance, in a single send to each distinct recipient.
+func (a Actor) WithdrawBalances(rt Runtime, params *WithdrawBalancesParams) *WithdrawBalancesReturn {
+	// Each balance approves its own callers, which are checked below.
+	rt.ValidateImmediateCallerAcceptAny()
+	validateBalanceBatch(rt, len(params.Withdrawals))
+
+	caller := rt.Caller()
+	nominals := make([]addr.Address, len(params.Withdrawals))
+	recipients := make([]addr.Address, len(params.Withdrawals))
+	for i, w := range params.Withdrawals {
+		builtin.RequireParam(rt, w.Amount.GreaterThanEqual(big.Zero()), "negative amount %v to withdraw from %v", w.Amount, w.ProviderOrClientAddress)
+		nominal, recipient, approvedCallers := escrowAddress(rt, w.ProviderOrClientAddress)
+		if !addressIn(caller, approvedCallers) {
+			rt.Abortf(exitcode.ErrForbidden, "caller %v is not permitted to withdraw from %v", caller, w.ProviderOrClientAddress)
+		}
+		nominals[i] = nominal
+		recipients[i] = recipient
+	}
+
+	withdrawn := make([]abi.TokenAmount, len(params.Withdrawals))
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withEscrowTable(WritePermission).
+			withLockedTable(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		// The amount available to withdraw from each balance, less any withdrawn by earlier withdrawals.
+		available := make(map[addr.Address]abi.TokenAmount)
+		deltas := make(map[addr.Address]abi.TokenAmount)
+		for i, w := range params.Withdrawals {
+			nominal := nominals[i]
+			avail, ok := available[nominal]
+			if !ok {
+				escrow, err := msm.escrowTable.Get(nominal)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get escrow balance")
+				// The withdrawable amount might be slightly less than nominal
+				// depending on whether or not all relevant entries have been processed
+				// by cron
+				locked, err := msm.lockedTable.Get(nominal)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get locked balance")
+				avail = big.Max(big.Zero(), big.Sub(escrow, locked))
+			}
+			withdrawn[i] = big.Min(avail, w.Amount)
+			available[nominal] = big.Sub(avail, withdrawn[i])
+			if withdrawn[i].GreaterThan(big.Zero()) {
+				deltas[nominal] = addToDelta(deltas, nominal, withdrawn[i].Neg())
+			}
+		}
+
+		_, err = msm.escrowTable.ApplyDeltas(deltas, adt.NoDust)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to subtract from escrow table")
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+
+	// Send to each recipient once, in order of first appearance.
+	var order []addr.Address
+	payments := make(map[addr.Address]abi.TokenAmount)
+	for i, recipient := range recipients {
+		if _, ok := payments[recipient]; !ok {
+			order = append(order, recipient)
+		}
+		payments[recipient] = addToDelta(payments, recipient, withdrawn[i])
+	}
+	for _, recipient := range order {
+		payment := payments[recipient]
+		if payment.IsZero() {
+			continue
+		}
+		code := rt.Send(recipient, builtin.MethodSend, nil, payment, &builtin.Discard{})
+		builtin.RequireSuccess(rt, code, "failed to send funds to %v", recipient)
+	}
+	return &WithdrawBalancesReturn{AmountsWithdrawn: withdrawn}
+}
+
+type PublishStorageDealsParams struct {
+	Deals []ClientDealProposal
+}
 
 type PublishStorageDealsReturn struct {
 	IDs        []abi.DealID
@@ -450,6 +584,7 @@ func (a Actor) ActivateDeals(rt Runtime, params *ActivateDealsParams) *abi.Empty
 				SectorStartEpoch: currEpoch,
 				LastUpdatedEpoch: epochUndefined,
 				SlashEpoch:       epochUndefined,
+				DataCap:          dealDataCap(proposal),
 			})
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set deal state %d", dealID)
 		}
@@ -461,6 +596,154 @@ func (a Actor) ActivateDeals(rt Runtime, params *ActivateDealsParams) *abi.Empty
 	return nil
 }
 
+type ActivateSignedDealsParams struct {
+	Deals        []ClientDealProposal
+	SectorExpiry abi.ChainEpoch
+}
+
+type ActivateSignedDealsReturn struct {
+	IDs     []abi.DealID
+	Weights SectorWeights
+}
+
+// Publishes and activates a set of client-signed deal proposals for a sector currently being ProveCommitted,
+// called by the miner's ProveCommitWithSignedDeals.
+// This allows a provider to hold signed proposals off-chain until they are sealed into a sector, rather than
+// publishing deals which may never be activated.
+// The proposals are validated as for publication and activation, except that any invalid proposal
+// aborts the whole call, since a sector's deals must all be activated together.
+func (a Actor) ActivateSignedDeals(rt Runtime, params *ActivateSignedDealsParams) *ActivateSignedDealsReturn {
+	rt.ValidateImmediateCallerType(builtin.StorageMinerActorCodeID)
+	minerAddr := rt.Caller()
+	currEpoch := rt.CurrEpoch()
+	if len(params.Deals) == 0 {
+		rt.Abortf(exitcode.ErrIllegalArgument, "empty deals parameter")
+	}
+
+	baselinePower := requestCurrentBaselinePower(rt)
+	networkRawPower, networkQAPower := requestCurrentNetworkPower(rt)
+
+	var st State
+	rt.StateReadonly(&st)
+	msm, err := st.mutator(adt.AsStore(rt)).withPendingProposals(ReadOnlyPermission).
+		withEscrowTable(ReadOnlyPermission).withLockedTable(ReadOnlyPermission).build()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+	proposalCidLookup := make(map[cid.Cid]struct{}, len(params.Deals))
+	proposalCids := make([]cid.Cid, 0, len(params.Deals))
+	proposals := make([]DealProposal, 0, len(params.Deals))
+	totalClientLockup := make(map[addr.Address]abi.TokenAmount)
+	totalProviderLockup := abi.NewTokenAmount(0)
+	weights := SectorWeights{DealWeight: big.Zero(), VerifiedDealWeight: big.Zero()}
+	for di, deal := range params.Deals {
+		err := validateDeal(rt, deal, networkRawPower, networkQAPower, baselinePower)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid deal %d", di)
+
+		// Normalise provider and client addresses in the proposal stored on chain.
+		// Must happen after signature verification and before taking cid.
+		provider, ok := rt.ResolveAddress(deal.Proposal.Provider)
+		if !ok {
+			rt.Abortf(exitcode.ErrNotFound, "failed to resolve provider address %v for deal %d", deal.Proposal.Provider, di)
+		}
+		client, ok := rt.ResolveAddress(deal.Proposal.Client)
+		if !ok {
+			rt.Abortf(exitcode.ErrNotFound, "failed to resolve client address %v for deal %d", deal.Proposal.Client, di)
+		}
+		deal.Proposal.Provider = provider
+		deal.Proposal.Client = client
+		err = validateDealCanActivate(&deal.Proposal, minerAddr, params.SectorExpiry, currEpoch)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "cannot activate deal %d", di)
+
+		if _, ok := totalClientLockup[client]; !ok {
+			totalClientLockup[client] = abi.NewTokenAmount(0)
+		}
+		totalClientLockup[client] = big.Sum(totalClientLockup[client], deal.Proposal.ClientBalanceRequirement())
+		clientBalanceOk, err := msm.balanceCovered(client, totalClientLockup[client])
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check client balance coverage")
+		if !clientBalanceOk {
+			rt.Abortf(exitcode.ErrInsufficientFunds, "insufficient client funds to cover deal %d", di)
+		}
+		totalProviderLockup = big.Sum(totalProviderLockup, deal.Proposal.ProviderCollateral)
+		providerBalanceOk, err := msm.balanceCovered(provider, totalProviderLockup)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check provider balance coverage")
+		if !providerBalanceOk {
+			rt.Abortf(exitcode.ErrInsufficientFunds, "insufficient provider funds to cover deal %d", di)
+		}
+
+		// A proposal which is pending has already been published or activated, and may not be activated again.
+		pcid, err := deal.Proposal.Cid()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to take cid of proposal %d", di)
+		duplicateInState, err := msm.pendingDeals.Has(abi.CidKey(pcid))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check for existence of deal proposal")
+		_, duplicateInMessage := proposalCidLookup[pcid]
+		if duplicateInState || duplicateInMessage {
+			rt.Abortf(exitcode.ErrIllegalArgument, "cannot activate duplicate deal proposal %d", di)
+		}
+
+		if deal.Proposal.VerifiedDeal {
+			code := rt.Send(
+				builtin.VerifiedRegistryActorAddr,
+				builtin.MethodsVerifiedRegistry.UseBytes,
+				&verifreg.UseBytesParams{
+					Address:  client,
+					DealSize: big.NewIntUnsigned(uint64(deal.Proposal.PieceSize)),
+				},
+				abi.NewTokenAmount(0),
+				&builtin.Discard{},
+			)
+			builtin.RequireSuccess(rt, code, "failed to acquire datacap for deal %d", di)
+		}
+
+		weights.addDeal(dealSpec(&deal.Proposal))
+		proposalCidLookup[pcid] = struct{}{}
+		proposalCids = append(proposalCids, pcid)
+		proposals = append(proposals, deal.Proposal)
+	}
+
+	var newDealIds []abi.DealID
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withPendingProposals(WritePermission).
+			withDealProposals(WritePermission).withDealStates(WritePermission).withDealsByEpoch(WritePermission).
+			withEscrowTable(WritePermission).withLockedTable(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		for i := range proposals {
+			proposal := &proposals[i]
+			err := msm.lockClientAndProviderBalances(proposal)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to lock balance")
+
+			id := msm.generateStorageDealID()
+
+			// The proposal remains pending until the deal is first processed by cron, as for a published deal.
+			err = msm.pendingDeals.Put(abi.CidKey(proposalCids[i]))
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set pending deal")
+
+			err = msm.dealProposals.Set(id, proposal)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set deal")
+
+			err = msm.dealStates.Set(id, &DealState{
+				SectorStartEpoch: currEpoch,
+				LastUpdatedEpoch: epochUndefined,
+				SlashEpoch:       epochUndefined,
+				DataCap:          dealDataCap(proposal),
+			})
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set deal state %d", id)
+
+			err = msm.dealsByEpoch.Put(GenRandNextEpoch(proposal.StartEpoch, id), id)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set deal ops by epoch")
+
+			newDealIds = append(newDealIds, id)
+		}
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+
+	return &ActivateSignedDealsReturn{
+		IDs:     newDealIds,
+		Weights: weights,
+	}
+}
+
 type SectorDataSpec struct {
 	DealIDs    []abi.DealID
 	SectorType abi.RegisteredSealProof
@@ -622,7 +905,7 @@ func (a Actor) CronTick(rt Runtime, _ *abi.EmptyValue) *abi.EmptyValue {
 					builtin.RequireNoErr(rt, pdErr, exitcode.ErrIllegalState, "failed to delete pending proposal %v", dcid)
 				}
 
-				slashAmount, nextEpoch, removeDeal := msm.updatePendingDealState(rt, state, deal, rt.CurrEpoch())
+				slashAmount, nextEpoch, removeDeal := msm.updatePendingDealState(rt, dealID, state, deal, rt.CurrEpoch())
 				builtin.RequireState(rt, slashAmount.GreaterThanEqual(big.Zero()), "computed negative slash amount %v for deal %d", slashAmount, dealID)
 
 				if removeDeal {
@@ -675,42 +958,176 @@ func (a Actor) CronTick(rt Runtime, _ *abi.EmptyValue) *abi.EmptyValue {
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
 	})
 
-	for _, d := range timedOutVerifiedDeals {
-		code := rt.Send(
-			builtin.VerifiedRegistryActorAddr,
-			builtin.MethodsVerifiedRegistry.RestoreBytes,
-			&verifreg.RestoreBytesParams{
-				Address:  d.Client,
-				DealSize: big.NewIntUnsigned(uint64(d.PieceSize)),
-			},
-			abi.NewTokenAmount(0),
-			&builtin.Discard{},
-		)
+	restoreTimedOutDataCap(rt, timedOutVerifiedDeals)
 
-		if !code.IsSuccess() {
-			rt.Log(rtt.ERROR, "failed to send RestoreBytes call to the VerifReg actor for timed-out verified deal, client: %s, dealSize: %v, "+
-				"provider: %v, got code %v", d.Client, d.PieceSize, d.Provider, code)
+	if !amountSlashed.IsZero() {
+		e := rt.Send(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, amountSlashed, &builtin.Discard{})
+		builtin.RequireSuccess(rt, e, "expected send to burnt funds actor to succeed")
+	}
+
+	return nil
+}
+
+type PurgeExpiredProposalsParams struct {
+	DealIDs []abi.DealID
+}
+
+type PurgeExpiredProposalsReturn struct {
+	// Indices into the deal IDs of the proposals which were purged.
+	PurgedDeals bitfield.BitField
+}
+
+// Purges published deal proposals which were not activated before their start epoch, without waiting for cron
+// to process them. As in cron, the provider collateral penalty for the missed activation is slashed, and the
+// client's funds are unlocked.
+// Any caller may purge expired proposals, and is rewarded with a share of the collateral slashed.
+// Proposals which are not found, have been activated, or have not yet expired are skipped.
+func (a Actor) PurgeExpiredProposals(rt Runtime, params *PurgeExpiredProposalsParams) *PurgeExpiredProposalsReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+	builtin.RequireParam(rt, len(params.DealIDs) <= PurgeExpiredProposalsMaxSize, "too many proposals to purge %d, max %d",
+		len(params.DealIDs), PurgeExpiredProposalsMaxSize)
+	currEpoch := rt.CurrEpoch()
+	amountSlashed := big.Zero()
+	var purged []uint64
+	var timedOutVerifiedDeals []*DealProposal
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withDealStates(ReadOnlyPermission).
+			withLockedTable(WritePermission).withEscrowTable(WritePermission).withDealsByEpoch(WritePermission).
+			withDealProposals(WritePermission).withPendingProposals(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		for i, dealID := range params.DealIDs {
+			deal, found, err := msm.dealProposals.Get(dealID)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %v", dealID)
+			if !found {
+				rt.Log(rtt.INFO, "couldn't find deal %d", dealID)
+				continue
+			}
+			_, activated, err := msm.dealStates.Get(dealID)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal state %v", dealID)
+			if activated {
+				rt.Log(rtt.INFO, "deal %d has been activated", dealID)
+				continue
+			}
+			// A deal may be activated up to and including its start epoch.
+			if currEpoch <= deal.StartEpoch {
+				rt.Log(rtt.INFO, "deal %d may be activated until %d", dealID, deal.StartEpoch)
+				continue
+			}
+
+			slashed := msm.purgeExpiredProposal(rt, dealID, deal)
+			amountSlashed = big.Add(amountSlashed, slashed)
+			if deal.VerifiedDeal {
+				timedOutVerifiedDeals = append(timedOutVerifiedDeals, deal)
+			}
+			purged = append(purged, uint64(i))
 		}
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
+
+	restoreTimedOutDataCap(rt, timedOutVerifiedDeals)
+
+	reward := ExpiredProposalPurgeReward(amountSlashed)
+	if !reward.IsZero() {
+		code := rt.Send(rt.Caller(), builtin.MethodSend, nil, reward, &builtin.Discard{})
+		builtin.RequireSuccess(rt, code, "failed to send purge reward to %v", rt.Caller())
+	}
+	if toBurn := big.Sub(amountSlashed, reward); !toBurn.IsZero() {
+		e := rt.Send(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, toBurn, &builtin.Discard{})
+		builtin.RequireSuccess(rt, e, "expected send to burnt funds actor to succeed")
 	}
+	return &PurgeExpiredProposalsReturn{PurgedDeals: bitfield.NewFromSet(purged)}
+}
+
+type SettleDealPaymentsParams struct {
+	DealIDs []abi.DealID
+}
+
+type SettleDealPaymentsReturn struct {
+	// Indices into the deal IDs of the deals which were settled.
+	SettledDeals bitfield.BitField
+	// Indices of the settled deals which were completed, having expired or been terminated, and removed.
+	CompletedDeals bitfield.BitField
+}
+
+// Settles the payments accrued by activated deals outside of cron, rather than waiting for each deal's next
+// scheduled update. Deals which have expired or been terminated are completed, and the collateral slashed from
+// terminated deals is burnt in a single batch.
+// Deals which are not found, not activated, or not yet started are skipped.
+// Any caller may settle deals, since settlement makes only the payments which are already due.
+func (a Actor) SettleDealPayments(rt Runtime, params *SettleDealPaymentsParams) *SettleDealPaymentsReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+	currEpoch := rt.CurrEpoch()
+	amountSlashed := big.Zero()
+	var settled, completed []uint64
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		msm, err := st.mutator(adt.AsStore(rt)).withDealStates(WritePermission).
+			withLockedTable(WritePermission).withEscrowTable(WritePermission).withDealsByEpoch(WritePermission).
+			withDealProposals(WritePermission).withPendingProposals(WritePermission).build()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load state")
+
+		for i, dealID := range params.DealIDs {
+			deal, found, err := msm.dealProposals.Get(dealID)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal proposal %v", dealID)
+			if !found {
+				rt.Log(rtt.INFO, "couldn't find deal %d", dealID)
+				continue
+			}
+			state, found, err := msm.dealStates.Get(dealID)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get deal state %v", dealID)
+			if !found {
+				rt.Log(rtt.INFO, "deal %d not activated", dealID)
+				continue
+			}
+			if currEpoch < deal.StartEpoch {
+				rt.Log(rtt.INFO, "deal %d not started until %d", dealID, deal.StartEpoch)
+				continue
+			}
+
+			slashed, removed := msm.settleDeal(rt, dealID, deal, state, currEpoch)
+			amountSlashed = big.Add(amountSlashed, slashed)
+			settled = append(settled, uint64(i))
+			if removed {
+				completed = append(completed, uint64(i))
+			}
+		}
+
+		err = msm.commitState()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush state")
+	})
 
 	if !amountSlashed.IsZero() {
 		e := rt.Send(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, amountSlashed, &builtin.Discard{})
 		builtin.RequireSuccess(rt, e, "expected send to burnt funds actor to succeed")
 	}
-
-	return nil
+	return &SettleDealPaymentsReturn{
+		SettledDeals:   bitfield.NewFromSet(settled),
+		CompletedDeals: bitfield.NewFromSet(completed),
+	}
 }
 
+// Returns the first epoch at or after an epoch at which cron may process a deal.
+// Each deal is processed only at epochs with a fixed offset within the update interval, given by a hash of its ID,
+// so that deals are spread evenly over the epochs of the interval whenever they start or are processed.
 func GenRandNextEpoch(startEpoch abi.ChainEpoch, dealID abi.DealID) abi.ChainEpoch {
-	DealUpdatesInterval := DealUpdatesInterval()
-	offset := abi.ChainEpoch(uint64(dealID) % uint64(DealUpdatesInterval))
-	q := builtin.NewQuantSpec(DealUpdatesInterval, 0)
-	prevDay := q.QuantizeDown(startEpoch)
-	if prevDay+offset >= startEpoch {
-		return prevDay + offset
-	}
-	nextDay := q.QuantizeUp(startEpoch)
-	return nextDay + offset
+	interval := DealUpdatesInterval()
+	return builtin.NewQuantSpec(interval, dealProcessingOffset(dealID, interval)).QuantizeUp(startEpoch)
+}
+
+// Hashes a deal ID to its processing offset within the update interval.
+// The offset is taken from a hash of the ID rather than the ID itself, so that the deals active at any time are
+// spread uniformly over the interval whatever the pattern of their IDs, e.g. deals published in large batches.
+func dealProcessingOffset(dealID abi.DealID, interval abi.ChainEpoch) abi.ChainEpoch {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(dealID))
+	digest := blake2b.Sum256(buf[:])
+	return abi.ChainEpoch(binary.BigEndian.Uint64(digest[:8]) % uint64(interval))
 }
 
 //
@@ -738,9 +1155,7 @@ func validateAndComputeDealWeight(proposals *DealArray, dealIDs []abi.DealID, mi
 	sectorExpiry abi.ChainEpoch, sectorActivation abi.ChainEpoch) (big.Int, big.Int, uint64, error) {
 
 	seenDealIDs := make(map[abi.DealID]struct{}, len(dealIDs))
-	totalDealSpace := uint64(0)
-	totalDealSpaceTime := big.Zero()
-	totalVerifiedSpaceTime := big.Zero()
+	weights := SectorWeights{DealWeight: big.Zero(), VerifiedDealWeight: big.Zero()}
 	for _, dealID := range dealIDs {
 		// Make sure we don't double-count deals.
 		if _, seen := seenDealIDs[dealID]; seen {
@@ -760,15 +1175,9 @@ func validateAndComputeDealWeight(proposals *DealArray, dealIDs []abi.DealID, mi
 		}
 
 		// Compute deal weight
-		totalDealSpace += uint64(proposal.PieceSize)
-		dealSpaceTime := DealWeight(proposal)
-		if proposal.VerifiedDeal {
-			totalVerifiedSpaceTime = big.Add(totalVerifiedSpaceTime, dealSpaceTime)
-		} else {
-			totalDealSpaceTime = big.Add(totalDealSpaceTime, dealSpaceTime)
-		}
+		weights.addDeal(dealSpec(proposal))
 	}
-	return totalDealSpaceTime, totalVerifiedSpaceTime, totalDealSpace, nil
+	return weights.DealWeight, weights.VerifiedDealWeight, weights.DealSpace, nil
 }
 
 func validateDealCanActivate(proposal *DealProposal, minerAddr addr.Address, sectorExpiration, sectorActivation abi.ChainEpoch) error {
@@ -791,8 +1200,8 @@ func validateDeal(rt Runtime, deal ClientDealProposal, networkRawPower, networkQ
 
 	proposal := deal.Proposal
 
-	if len(proposal.Label) > DealMaxLabelSize {
-		return xerrors.Errorf("deal label can be at most %d bytes, is %d", DealMaxLabelSize, len(proposal.Label))
+	if proposal.Label.Length() > DealMaxLabelSize {
+		return xerrors.Errorf("deal label can be at most %d bytes, is %d", DealMaxLabelSize, proposal.Label.Length())
 	}
 
 	if err := proposal.PieceSize.Validate(); err != nil {
@@ -865,6 +1274,49 @@ func escrowAddress(rt Runtime, address addr.Address) (nominal addr.Address, reci
 	return nominal, nominal, []addr.Address{nominal}
 }
 
+// Returns the datacap consumed by verified deals which timed out before activation to their clients.
+func restoreTimedOutDataCap(rt Runtime, deals []*DealProposal) {
+	for _, d := range deals {
+		code := rt.Send(
+			builtin.VerifiedRegistryActorAddr,
+			builtin.MethodsVerifiedRegistry.RestoreBytes,
+			&verifreg.RestoreBytesParams{
+				Address:  d.Client,
+				DealSize: big.NewIntUnsigned(uint64(d.PieceSize)),
+			},
+			abi.NewTokenAmount(0),
+			&builtin.Discard{},
+		)
+
+		if !code.IsSuccess() {
+			rt.Log(rtt.ERROR, "failed to send RestoreBytes call to the VerifReg actor for timed-out verified deal, client: %s, dealSize: %v, "+
+				"provider: %v, got code %v", d.Client, d.PieceSize, d.Provider, code)
+		}
+	}
+}
+
+func validateBalanceBatch(rt Runtime, size int) {
+	builtin.RequireParam(rt, size > 0, "no balances to adjust")
+	builtin.RequireParam(rt, size <= BalanceBatchMaxSize, "too many balances to adjust %d, max %d", size, BalanceBatchMaxSize)
+}
+
+// Returns an amount added to the amount for an address in a map, treating a missing entry as zero.
+func addToDelta(deltas map[addr.Address]abi.TokenAmount, a addr.Address, amount abi.TokenAmount) abi.TokenAmount {
+	if prev, ok := deltas[a]; ok {
+		return big.Add(prev, amount)
+	}
+	return amount
+}
+
+func addressIn(a addr.Address, list []addr.Address) bool {
+	for _, l := range list {
+		if a == l {
+			return true
+		}
+	}
+	return false
+}
+
 func getDealProposal(proposals *DealArray, dealID abi.DealID) (*DealProposal, error) {
 	proposal, found, err := proposals.Get(dealID)
 	if err != nil {
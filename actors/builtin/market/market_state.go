@@ -105,7 +105,7 @@ func ConstructState(store adt.Store) (*State, error) {
 // Deal state operations
 ////////////////////////////////////////////////////////////////////////////////
 
-func (m *marketStateMutation) updatePendingDealState(rt Runtime, state *DealState, deal *DealProposal, epoch abi.ChainEpoch) (amountSlashed abi.TokenAmount, nextEpoch abi.ChainEpoch, removeDeal bool) {
+func (m *marketStateMutation) updatePendingDealState(rt Runtime, dealID abi.DealID, state *DealState, deal *DealProposal, epoch abi.ChainEpoch) (amountSlashed abi.TokenAmount, nextEpoch abi.ChainEpoch, removeDeal bool) {
 	amountSlashed = abi.NewTokenAmount(0)
 
 	everUpdated := state.LastUpdatedEpoch != epochUndefined
@@ -156,14 +156,17 @@ func (m *marketStateMutation) updatePendingDealState(rt Runtime, state *DealStat
 		err = m.unlockBalance(deal.Client, paymentRemaining, ClientStorageFee)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to unlock remaining client storage fee")
 
-		// unlock client collateral
-		err = m.unlockBalance(deal.Client, deal.ClientCollateral, ClientCollateral)
+		// slash a portion of client collateral, as set by policy, and unlock the remainder
+		clientPenalty := ClientCollateralPenaltyForDealTermination(deal.ClientCollateral)
+		err = m.slashBalance(deal.Client, clientPenalty, ClientCollateral)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to slash client collateral")
+		err = m.unlockBalance(deal.Client, big.Sub(deal.ClientCollateral, clientPenalty), ClientCollateral)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to unlock client collateral")
 
 		// slash provider collateral
-		amountSlashed = deal.ProviderCollateral
-		err = m.slashBalance(deal.Provider, amountSlashed, ProviderCollateral)
+		err = m.slashBalance(deal.Provider, deal.ProviderCollateral, ProviderCollateral)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "slashing balance")
+		amountSlashed = big.Add(deal.ProviderCollateral, clientPenalty)
 		return amountSlashed, epochUndefined, true
 	}
 
@@ -174,11 +177,73 @@ func (m *marketStateMutation) updatePendingDealState(rt Runtime, state *DealStat
 
 	// We're explicitly not inspecting the end epoch and may process a deal's expiration late, in order to prevent an outsider
 	// from loading a cron tick by activating too many deals with the same end epoch.
-	nextEpoch = epoch + DealUpdatesInterval()
+	// A deal processed late, or settled outside cron, is next processed at its following epoch at its offset,
+	// keeping its place in the interval rather than joining the other deals processed at the same epoch.
+	nextEpoch = GenRandNextEpoch(epoch+1, dealID)
 
 	return amountSlashed, nextEpoch, false
 }
 
+// Settles an activated deal's payments up to an epoch outside of cron, completing the deal if it has expired or
+// been terminated. The deal's cron operation is moved to the deal's next update, or removed with a completed deal.
+// Returns the collateral slashed, and whether the deal was completed.
+func (m *marketStateMutation) settleDeal(rt Runtime, dealID abi.DealID, deal *DealProposal, state *DealState, epoch abi.ChainEpoch) (abi.TokenAmount, bool) {
+	builtin.RequireState(rt, epoch >= deal.StartEpoch, "deal %d settled before start epoch %d", dealID, deal.StartEpoch)
+
+	m.removeDealOp(rt, dealID, dealScheduledEpoch(dealID, deal, state, m.st.LastCron))
+
+	// As in cron, a deal is pending until it is first processed.
+	if state.LastUpdatedEpoch == epochUndefined {
+		dcid, err := deal.Cid()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to calculate CID for proposal %v", dealID)
+		err = m.pendingDeals.Delete(abi.CidKey(dcid))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete pending proposal %v", dcid)
+	}
+
+	slashAmount, nextEpoch, removeDeal := m.updatePendingDealState(rt, dealID, state, deal, epoch)
+	builtin.RequireState(rt, slashAmount.GreaterThanEqual(big.Zero()), "computed negative slash amount %v for deal %d", slashAmount, dealID)
+	if removeDeal {
+		err := m.dealStates.Delete(dealID)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete deal state %d", dealID)
+		err = m.dealProposals.Delete(dealID)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete deal proposal %d", dealID)
+		return slashAmount, true
+	}
+	builtin.RequireState(rt, nextEpoch > epoch, "continuing deal %d next epoch %d should be in future", dealID, nextEpoch)
+	builtin.RequireState(rt, slashAmount.IsZero(), "continuing deal %d should not be slashed", dealID)
+
+	state.LastUpdatedEpoch = epoch
+	err := m.dealStates.Set(dealID, state)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set deal state %d", dealID)
+	err = m.dealsByEpoch.Put(nextEpoch, dealID)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to reschedule deal %d", dealID)
+	return slashAmount, false
+}
+
+// Removes a deal's cron operation from the epoch at which the deal is next scheduled for processing.
+func (m *marketStateMutation) removeDealOp(rt Runtime, dealID abi.DealID, scheduled abi.ChainEpoch) {
+	found, err := m.dealsByEpoch.Remove(scheduled, dealID)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to remove deal op for deal %d", dealID)
+	builtin.RequireState(rt, found, "deal %d not scheduled at epoch %d", dealID, scheduled)
+}
+
+// Removes a proposal which was not activated before its start epoch, before cron processes it.
+// The proposal is timed out as in cron, returning the provider collateral slashed.
+func (m *marketStateMutation) purgeExpiredProposal(rt Runtime, dealID abi.DealID, deal *DealProposal) abi.TokenAmount {
+	// An unactivated deal is processed only at its first epoch at its offset following its start.
+	m.removeDealOp(rt, dealID, dealScheduledEpoch(dealID, deal, nil, m.st.LastCron))
+
+	dcid, err := deal.Cid()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to calculate CID for proposal %v", dealID)
+	err = m.pendingDeals.Delete(abi.CidKey(dcid))
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete pending proposal %d (%v)", dealID, dcid)
+
+	slashed := m.processDealInitTimedOut(rt, deal)
+	err = m.dealProposals.Delete(dealID)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete deal proposal %d", dealID)
+	return slashed
+}
+
 // Deal start deadline elapsed without appearing in a proven sector.
 // Slash a portion of provider's collateral, and unlock remaining collaterals
 // for both provider and client.
@@ -241,6 +306,30 @@ func dealProposalIsInternallyValid(rt Runtime, proposal ClientDealProposal) erro
 	return nil
 }
 
+// Returns the epoch at which cron is scheduled to next process a deal: the first epoch at the deal's offset from
+// its start, and then the first following each epoch at which it is processed, but never at or before the
+// last cron. A deal is scheduled after the last cron anyway, except those which were overdue at the network
+// version 15 upgrade, which were moved to their first epoch at their offset following it.
+// The state is nil for a deal which has not been activated.
+func dealScheduledEpoch(dealID abi.DealID, deal *DealProposal, state *DealState, lastCron abi.ChainEpoch) abi.ChainEpoch {
+	from := deal.StartEpoch
+	if state != nil && state.LastUpdatedEpoch != epochUndefined {
+		from = state.LastUpdatedEpoch + 1
+	}
+	if from <= lastCron {
+		from = lastCron + 1
+	}
+	return GenRandNextEpoch(from, dealID)
+}
+
+// Returns the datacap consumed by a deal's client when the deal was published.
+func dealDataCap(deal *DealProposal) abi.StoragePower {
+	if !deal.VerifiedDeal {
+		return big.Zero()
+	}
+	return big.NewIntUnsigned(uint64(deal.PieceSize))
+}
+
 func dealGetPaymentRemaining(deal *DealProposal, slashEpoch abi.ChainEpoch) (abi.TokenAmount, error) {
 	if slashEpoch > deal.EndEpoch {
 		return big.Zero(), xerrors.Errorf("deal slash epoch %d after end epoch %d", slashEpoch, deal.EndEpoch)
@@ -5,10 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"strings"
 	"testing"
 
 	address "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/cbor"
@@ -39,6 +41,14 @@ func mustCbor(o cbor.Marshaler) []byte {
 	return buf.Bytes()
 }
 
+func mustLabel(s string) market.DealLabel {
+	label, err := market.NewLabelFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return label
+}
+
 func TestExports(t *testing.T) {
 	mock.CheckActorExports(t, market.Actor{})
 }
@@ -401,6 +411,156 @@ func TestMarketActor(t *testing.T) {
 			actor.checkState(rt)
 		})
 	})
+
+	// A second provider with the same owner, as for a fleet of miners owned by one multisig.
+	provider2 := tutil.NewIDAddr(t, 105)
+	worker2 := tutil.NewIDAddr(t, 106)
+	minerAddrs2 := *minerAddrs
+	minerAddrs2.worker, minerAddrs2.provider = worker2, provider2
+
+	t.Run("AddBalances", func(t *testing.T) {
+		t.Run("adds to many provider and client escrow funds", func(t *testing.T) {
+			rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+			rt.SetAddressActorType(provider2, builtin.StorageMinerActorCodeID)
+
+			expectGetControlAddresses(rt, provider, owner, worker)
+			expectGetControlAddresses(rt, provider2, owner, worker2)
+			expectGetControlAddresses(rt, provider, owner, worker)
+			actor.addBalances(rt, owner, market.EscrowAmount{ProviderOrClientAddress: provider, Amount: abi.NewTokenAmount(10)},
+				market.EscrowAmount{ProviderOrClientAddress: provider2, Amount: abi.NewTokenAmount(20)},
+				market.EscrowAmount{ProviderOrClientAddress: client, Amount: abi.NewTokenAmount(30)},
+				market.EscrowAmount{ProviderOrClientAddress: provider, Amount: abi.NewTokenAmount(5)})
+
+			assert.Equal(t, abi.NewTokenAmount(15), actor.getEscrowBalance(rt, provider))
+			assert.Equal(t, abi.NewTokenAmount(20), actor.getEscrowBalance(rt, provider2))
+			assert.Equal(t, abi.NewTokenAmount(30), actor.getEscrowBalance(rt, client))
+			actor.checkState(rt)
+		})
+
+		t.Run("fails when value received does not match deposits", func(t *testing.T) {
+			rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+			params := market.AddBalancesParams{Deposits: []market.EscrowAmount{
+				{ProviderOrClientAddress: client, Amount: abi.NewTokenAmount(10)},
+			}}
+
+			rt.SetCaller(owner, builtin.AccountActorCodeID)
+			rt.SetReceived(abi.NewTokenAmount(11))
+			rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+			rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "does not match total deposits", func() {
+				rt.Call(actor.AddBalances, &params)
+			})
+			rt.Verify()
+			actor.checkState(rt)
+		})
+
+		t.Run("fails with a zero amount or no deposits", func(t *testing.T) {
+			rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+			rt.SetCaller(owner, builtin.AccountActorCodeID)
+
+			params := market.AddBalancesParams{Deposits: []market.EscrowAmount{
+				{ProviderOrClientAddress: client, Amount: big.Zero()},
+			}}
+			rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+			rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+				rt.Call(actor.AddBalances, &params)
+			})
+			rt.Verify()
+
+			rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+			rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+				rt.Call(actor.AddBalances, &market.AddBalancesParams{})
+			})
+			rt.Verify()
+			actor.checkState(rt)
+		})
+	})
+
+	t.Run("WithdrawBalances", func(t *testing.T) {
+		t.Run("owner withdraws from many provider escrows in a single send", func(t *testing.T) {
+			rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+			rt.SetAddressActorType(provider2, builtin.StorageMinerActorCodeID)
+			actor.addProviderFunds(rt, abi.NewTokenAmount(20), minerAddrs)
+			actor.addProviderFunds(rt, abi.NewTokenAmount(30), &minerAddrs2)
+
+			expectGetControlAddresses(rt, provider, owner, worker)
+			expectGetControlAddresses(rt, provider2, owner, worker2)
+			expectGetControlAddresses(rt, provider, owner, worker)
+			rt.ExpectSend(owner, builtin.MethodSend, nil, abi.NewTokenAmount(45), nil, exitcode.Ok)
+			withdrawn := actor.withdrawBalances(rt, owner,
+				market.EscrowAmount{ProviderOrClientAddress: provider, Amount: abi.NewTokenAmount(5)},
+				market.EscrowAmount{ProviderOrClientAddress: provider2, Amount: abi.NewTokenAmount(35)},
+				market.EscrowAmount{ProviderOrClientAddress: provider, Amount: abi.NewTokenAmount(10)})
+
+			// The second provider's withdrawal is limited to its balance.
+			assert.Equal(t, []abi.TokenAmount{abi.NewTokenAmount(5), abi.NewTokenAmount(30), abi.NewTokenAmount(10)}, withdrawn)
+			assert.Equal(t, abi.NewTokenAmount(5), actor.getEscrowBalance(rt, provider))
+			actor.assertAccountZero(rt, provider2)
+			actor.checkState(rt)
+		})
+
+		t.Run("withdrawals are limited by locked funds", func(t *testing.T) {
+			rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+			rt.SetEpoch(5)
+			dealId := actor.generateAndPublishDeal(rt, client, minerAddrs, 10, 10+200*builtin.EpochsInDay())
+			deal := actor.getDealProposal(rt, dealId)
+			actor.addProviderFunds(rt, abi.NewTokenAmount(25), minerAddrs)
+
+			expectGetControlAddresses(rt, provider, owner, worker)
+			expectGetControlAddresses(rt, provider, owner, worker)
+			rt.ExpectSend(owner, builtin.MethodSend, nil, abi.NewTokenAmount(25), nil, exitcode.Ok)
+			withdrawn := actor.withdrawBalances(rt, worker,
+				market.EscrowAmount{ProviderOrClientAddress: provider, Amount: abi.NewTokenAmount(20)},
+				market.EscrowAmount{ProviderOrClientAddress: provider, Amount: abi.NewTokenAmount(20)})
+
+			assert.Equal(t, []abi.TokenAmount{abi.NewTokenAmount(20), abi.NewTokenAmount(5)}, withdrawn)
+			assert.Equal(t, deal.ProviderCollateral, actor.getEscrowBalance(rt, provider))
+			actor.checkState(rt)
+		})
+
+		t.Run("nothing is sent when nothing is available", func(t *testing.T) {
+			rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+			withdrawn := actor.withdrawBalances(rt, client,
+				market.EscrowAmount{ProviderOrClientAddress: client, Amount: abi.NewTokenAmount(1)})
+			assert.Equal(t, []abi.TokenAmount{big.Zero()}, withdrawn)
+			actor.checkState(rt)
+		})
+
+		t.Run("fails unless caller may withdraw from every balance", func(t *testing.T) {
+			rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+			actor.addProviderFunds(rt, abi.NewTokenAmount(20), minerAddrs)
+			actor.addParticipantFunds(rt, client, abi.NewTokenAmount(20))
+
+			params := market.WithdrawBalancesParams{Withdrawals: []market.EscrowAmount{
+				{ProviderOrClientAddress: provider, Amount: abi.NewTokenAmount(1)},
+				{ProviderOrClientAddress: client, Amount: abi.NewTokenAmount(1)},
+			}}
+			rt.SetCaller(owner, builtin.AccountActorCodeID)
+			rt.ExpectValidateCallerAny()
+			expectGetControlAddresses(rt, provider, owner, worker)
+			rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "not permitted to withdraw", func() {
+				rt.Call(actor.WithdrawBalances, &params)
+			})
+			rt.Verify()
+
+			assert.Equal(t, abi.NewTokenAmount(20), actor.getEscrowBalance(rt, provider))
+			assert.Equal(t, abi.NewTokenAmount(20), actor.getEscrowBalance(rt, client))
+			actor.checkState(rt)
+		})
+
+		t.Run("fails with a negative amount", func(t *testing.T) {
+			rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+			params := market.WithdrawBalancesParams{Withdrawals: []market.EscrowAmount{
+				{ProviderOrClientAddress: client, Amount: abi.NewTokenAmount(-1)},
+			}}
+			rt.SetCaller(client, builtin.AccountActorCodeID)
+			rt.ExpectValidateCallerAny()
+			rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+				rt.Call(actor.WithdrawBalances, &params)
+			})
+			rt.Verify()
+			actor.checkState(rt)
+		})
+	})
 }
 
 func TestDealOpsByEpochOffset(t *testing.T) {
@@ -411,15 +571,18 @@ func TestDealOpsByEpochOffset(t *testing.T) {
 	control := tutil.NewIDAddr(t, 200)
 	mAddr := &minerAddrs{owner, worker, provider, []address.Address{control}}
 
-	assertNGoodDeals := func(t *testing.T, dobe *market.SetMultimap, e abi.ChainEpoch, n int) {
+	// Counts the deals scheduled at each epoch in a range, checking each is at its offset.
+	countGoodDeals := func(t *testing.T, dobe *market.SetMultimap, from, to abi.ChainEpoch) int {
 		count := 0
-		err := dobe.ForEach(e, func(id abi.DealID) error {
-			assert.Equal(t, uint64(e%market.DealUpdatesInterval()), uint64(id%abi.DealID(market.DealUpdatesInterval())))
-			count++
-			return nil
-		})
-		require.NoError(t, err)
-		assert.Equal(t, n, count, "unexpected deal count at epoch %d", e)
+		for e := from; e < to; e++ {
+			err := dobe.ForEach(e, func(id abi.DealID) error {
+				assert.Equal(t, e, market.GenRandNextEpoch(e, id), "deal %d at epoch %d is not at its offset", id, e)
+				count++
+				return nil
+			})
+			require.NoError(t, err)
+		}
+		return count
 	}
 
 	t.Run("deal starts on day boundary", func(t *testing.T) {
@@ -436,33 +599,29 @@ func TestDealOpsByEpochOffset(t *testing.T) {
 			assert.Equal(t, abi.DealID(i), dealID)
 		}
 
-		// Check that DOBE has exactly 3 deals scheduled every epoch in the day following the start time
+		// Check that DOBE has every deal scheduled in the day following the start time
 		var st market.State
 		rt.GetState(&st)
 		dobe, err := market.AsSetMultimap(rt.AdtStore(), st.DealOpsByEpoch, builtin.DefaultHamtBitwidth, builtin.DefaultHamtBitwidth)
 		require.NoError(t, err)
-		for e := abi.ChainEpoch(market.DealUpdatesInterval()); e < abi.ChainEpoch(2*market.DealUpdatesInterval()); e++ {
-			assertNGoodDeals(t, dobe, e, 3)
-		}
+		interval := market.DealUpdatesInterval()
+		assert.Equal(t, 3*int(interval), countGoodDeals(t, dobe, interval, 2*interval))
 
 		// DOBE has no deals scheduled in the previous or next day
-		for e := abi.ChainEpoch(0); e < abi.ChainEpoch(market.DealUpdatesInterval()); e++ {
-			assertNGoodDeals(t, dobe, e, 0)
-		}
-		for e := 2 * abi.ChainEpoch(market.DealUpdatesInterval()); e < 3*abi.ChainEpoch(market.DealUpdatesInterval()); e++ {
-			assertNGoodDeals(t, dobe, e, 0)
-		}
+		assert.Equal(t, 0, countGoodDeals(t, dobe, 0, interval))
+		assert.Equal(t, 0, countGoodDeals(t, dobe, 2*interval, 3*interval))
 	})
 
 	t.Run("deal starts partway through day", func(t *testing.T) {
 		startEpoch := abi.ChainEpoch(1000)
 		endEpoch := startEpoch + 200*builtin.EpochsInDay()
 		publishEpoch := abi.ChainEpoch(1)
+		interval := market.DealUpdatesInterval()
 
 		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
 		rt.SetEpoch(publishEpoch)
 
-		// First 1000 deals (startEpoch % update interval) scheduled starting in the next day
+		// Deals are scheduled in the interval following the start time, at their offsets either side of the day boundary
 		for i := 0; i < 1000; i++ {
 			pieceCID := tutil.MakeCID(fmt.Sprintf("%d", i), &market.PieceCIDPrefix)
 			dealID := actor.generateAndPublishDealForPiece(rt, client, mAddr, startEpoch, endEpoch, pieceCID, abi.PaddedPieceSize(2048))
@@ -472,13 +631,9 @@ func TestDealOpsByEpochOffset(t *testing.T) {
 		rt.GetState(&st)
 		dobe, err := market.AsSetMultimap(rt.AdtStore(), st.DealOpsByEpoch, builtin.DefaultHamtBitwidth, builtin.DefaultHamtBitwidth)
 		require.NoError(t, err)
-		for e := abi.ChainEpoch(2880); e < abi.ChainEpoch(2880)+startEpoch; e++ {
-			assertNGoodDeals(t, dobe, e, 1)
-		}
-		// Nothing scheduled between 0 and 2880
-		for e := abi.ChainEpoch(0); e < abi.ChainEpoch(2880); e++ {
-			assertNGoodDeals(t, dobe, e, 0)
-		}
+		assert.Equal(t, 1000, countGoodDeals(t, dobe, startEpoch, startEpoch+interval))
+		// Nothing scheduled before the start time
+		assert.Equal(t, 0, countGoodDeals(t, dobe, 0, startEpoch))
 
 		// Now add another 500 deals
 		for i := 1000; i < 1500; i++ {
@@ -489,10 +644,54 @@ func TestDealOpsByEpochOffset(t *testing.T) {
 		rt.GetState(&st)
 		dobe, err = market.AsSetMultimap(rt.AdtStore(), st.DealOpsByEpoch, builtin.DefaultHamtBitwidth, builtin.DefaultHamtBitwidth)
 		require.NoError(t, err)
-		for e := startEpoch; e < startEpoch+500; e++ {
-			assertNGoodDeals(t, dobe, e, 1)
+		assert.Equal(t, 1500, countGoodDeals(t, dobe, startEpoch, startEpoch+interval))
+	})
+
+	t.Run("deals are spread evenly over the interval whatever their IDs", func(t *testing.T) {
+		interval := market.DealUpdatesInterval()
+		const perEpoch = 10
+		// Consecutive IDs, and IDs a whole interval apart, which would share an offset if taken from the ID directly.
+		for _, stride := range []abi.DealID{1, abi.DealID(interval)} {
+			counts := make(map[abi.ChainEpoch]int)
+			for i := abi.DealID(0); i < perEpoch*abi.DealID(interval); i++ {
+				epoch := market.GenRandNextEpoch(0, i*stride)
+				require.True(t, epoch >= 0 && epoch < interval, "deal %d scheduled at %d outside the interval", i*stride, epoch)
+				counts[epoch]++
+			}
+			assert.GreaterOrEqual(t, len(counts), int(interval)*99/100, "too few epochs used with stride %d", stride)
+			for epoch, count := range counts { // nolint:nomaprange
+				assert.LessOrEqual(t, count, 3*perEpoch, "too many deals at epoch %d with stride %d", epoch, stride)
+			}
 		}
 	})
+
+	t.Run("deals processed late remain at their offsets", func(t *testing.T) {
+		startEpoch := abi.ChainEpoch(market.DealUpdatesInterval())
+		endEpoch := startEpoch + 200*builtin.EpochsInDay()
+		sectorExpiry := endEpoch + 100
+
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		var dealIds []abi.DealID
+		for i := 0; i < 3; i++ {
+			dealIds = append(dealIds, actor.publishAndActivateDeal(rt, client, mAddr, startEpoch, endEpoch+abi.ChainEpoch(i), 0, sectorExpiry))
+		}
+
+		// A single cron tick after all three deals' epochs processes them together.
+		rt.SetEpoch(startEpoch + 10)
+		actor.cronTick(rt)
+
+		r, err := market.NewStateReader(rt.AdtStore(), rt.StateRoot())
+		require.NoError(t, err)
+		schedule, err := r.DealSchedule(startEpoch, startEpoch+2*market.DealUpdatesInterval())
+		require.NoError(t, err)
+		var expected []market.EpochDeals
+		for _, id := range dealIds {
+			expected = append(expected, market.EpochDeals{Epoch: market.GenRandNextEpoch(startEpoch+11, id), Deals: []abi.DealID{id}})
+		}
+		sort.Slice(expected, func(i, j int) bool { return expected[i].Epoch < expected[j].Epoch })
+		assert.Equal(t, expected, schedule)
+		actor.checkState(rt)
+	})
 }
 
 func TestPublishStorageDeals(t *testing.T) {
@@ -1109,6 +1308,31 @@ func TestActivateDeals(t *testing.T) {
 		actor.assertDealsNotActivated(rt, currentEpoch, dealId4)
 		actor.checkState(rt)
 	})
+
+	t.Run("activated deal records datacap consumed by a verified deal", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		rt.SetEpoch(currentEpoch)
+		mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+		vd := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		vd.VerifiedDeal = true
+		d := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch+1)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIds := actor.publishDeals(rt, mAddrs, publishDealReq{deal: vd}, publishDealReq{deal: d})
+
+		actor.activateDeals(rt, sectorExpiry, provider, currentEpoch, dealIds...)
+		assert.Equal(t, big.NewIntUnsigned(uint64(vd.PieceSize)), actor.getDealState(rt, dealIds[0]).DataCap)
+		assert.Equal(t, big.Zero(), actor.getDealState(rt, dealIds[1]).DataCap)
+
+		r, err := market.NewStateReader(rt.AdtStore(), rt.StateRoot())
+		require.NoError(t, err)
+		usage, err := r.DataCapUsage()
+		require.NoError(t, err)
+		assert.Equal(t, big.NewIntUnsigned(uint64(vd.PieceSize)), usage.Total)
+		assert.Equal(t, map[address.Address]abi.StoragePower{client: usage.Total}, usage.ByClient)
+		assert.Equal(t, map[address.Address]abi.StoragePower{provider: usage.Total}, usage.ByProvider)
+		actor.checkState(rt)
+	})
 }
 
 func TestActivateDealFailures(t *testing.T) {
@@ -1561,8 +1785,8 @@ func TestCronTick(t *testing.T) {
 	})
 
 	t.Run("crontick for a deal at it's start epoch results in zero payment and no slashing", func(t *testing.T) {
-		// set start epoch to coincide with processing (0 + 0 % 2880 = 0)
-		startEpoch := abi.ChainEpoch(0)
+		// set start epoch to coincide with processing of the first deal
+		startEpoch := processEpoch(t, 0, 0)
 		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
 		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
 
@@ -1588,6 +1812,9 @@ func TestCronTick(t *testing.T) {
 
 		// slash deal1
 		slashEpoch := rt.SetEpoch(processEpoch(t, dealId2, startEpoch) + abi.ChainEpoch(100))
+		if deal1Epoch := processEpoch(t, dealId1, startEpoch); deal1Epoch > slashEpoch {
+			slashEpoch = rt.SetEpoch(deal1Epoch)
+		}
 		actor.terminateDeals(rt, provider, dealId1)
 
 		// cron tick will slash deal1 and make payment for deal2
@@ -1791,20 +2018,27 @@ func TestLockedFundTrackingStates(t *testing.T) {
 	curr = rt.SetEpoch(processEpoch(t, dealId3, startEpoch))
 	rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, d3.ProviderCollateral, nil, exitcode.Ok)
 	actor.cronTick(rt)
-	payment := big.Product(big.NewInt(4), d1.StoragePricePerEpoch)
+	// deal1 and deal2 are first processed at their offsets no later than this, and paid from their start.
+	require.True(t, processEpoch(t, dealId1, startEpoch) <= curr && processEpoch(t, dealId2, startEpoch) <= curr)
+	payment := big.Product(big.NewInt(2), d1.StoragePricePerEpoch, big.NewInt(int64(curr-startEpoch)))
 	csf = big.Sub(big.Sub(csf, payment), d3.TotalStorageFee())
 	plc = big.Sub(plc, d3.ProviderCollateral)
 	clc = big.Sub(clc, d3.ClientCollateral)
 	actor.assertLockedFundStates(rt, csf, plc, clc)
 
-	// deal1 and deal2 will now be charged at epoch curr + market.DealUpdatesInterval(), so nothing changes before that.
-	curr = rt.SetEpoch(curr + market.DealUpdatesInterval() - 1)
+	// deal1 and deal2 will now be charged at their epochs in the next update interval, so nothing changes before that.
+	lastPaid := curr
+	next1, next2 := processEpoch(t, dealId1, curr+1), processEpoch(t, dealId2, curr+1)
+	if next1 < next2 {
+		next1, next2 = next2, next1
+	}
+	curr = rt.SetEpoch(next2 - 1)
 	actor.cronTick(rt)
 	actor.assertLockedFundStates(rt, csf, plc, clc)
 
 	// one more round of payment for deal1 and deal2
-	curr = rt.SetEpoch(curr + 1)
-	duration := big.NewInt(int64(market.DealUpdatesInterval()))
+	curr = rt.SetEpoch(next1)
+	duration := big.NewInt(int64(curr - lastPaid))
 	payment = big.Product(big.NewInt(2), d1.StoragePricePerEpoch, duration)
 	csf = big.Sub(csf, payment)
 	actor.cronTick(rt)
@@ -1856,8 +2090,8 @@ func TestCronTickTimedoutDeals(t *testing.T) {
 	})
 
 	t.Run("publishing timed out deal again should work after cron tick as it should no longer be pending", func(t *testing.T) {
-		// dealID(0) + 0 % 2880 = 0.  Need processing epoch == start epoch to do hack where we publish deals after cron in same epoch
-		startEpoch := abi.ChainEpoch(0)
+		// Need processing epoch == start epoch to do hack where we publish deals after cron in same epoch
+		startEpoch := processEpoch(t, 0, 0)
 		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
 		dealId := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch, endEpoch)
 		d := actor.getDealProposal(rt, dealId)
@@ -1976,8 +2210,7 @@ func TestCronTickDealExpiry(t *testing.T) {
 
 	t.Run("deal expiry -> regular payments till deal expires and then locked funds are unlocked", func(t *testing.T) {
 		// start epoch should equal first processing epoch for logic to work
-		// 2880 + 0 % 2880 = 2880
-		startEpoch := abi.ChainEpoch(builtin.EpochsInDay())
+		startEpoch := processEpoch(t, 0, builtin.EpochsInDay())
 		t.Parallel()
 		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
 		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
@@ -1990,13 +2223,14 @@ func TestCronTickDealExpiry(t *testing.T) {
 		require.EqualValues(t, pay, big.Mul(big.NewInt(5), d.StoragePricePerEpoch))
 		require.EqualValues(t, big.Zero(), slashed)
 
-		// Setting the current epoch to anything less than next schedule wont make any payment
-		current = rt.SetEpoch(current + market.DealUpdatesInterval() - 1)
+		// Setting the current epoch to anything less than next schedule wont make any payment.
+		// The deal is processed late, but remains scheduled at its offset in the following interval.
+		current = rt.SetEpoch(startEpoch + market.DealUpdatesInterval() - 1)
 		actor.cronTickNoChange(rt, client, provider)
 
 		// however setting the current epoch to next schedle will make the payment
 		current = rt.SetEpoch(current + 1)
-		duration := big.NewInt(int64(market.DealUpdatesInterval()))
+		duration := big.NewInt(int64(market.DealUpdatesInterval() - 5))
 		pay, slashed = actor.cronTickAndAssertBalances(rt, client, provider, current, dealId)
 		require.EqualValues(t, big.Mul(duration, d.StoragePricePerEpoch), pay)
 		require.EqualValues(t, big.Zero(), slashed)
@@ -2222,8 +2456,7 @@ func TestCronTickDealSlashing(t *testing.T) {
 	t.Run("deal is correctly processed twice in the same crontick and slashed", func(t *testing.T) {
 		t.Parallel()
 		// start epoch should equal first processing epoch for logic to work
-		// 2880 + 0 % 2880 = 2880
-		startEpoch := abi.ChainEpoch(builtin.EpochsInDay())
+		startEpoch := processEpoch(t, 0, builtin.EpochsInDay())
 		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
 		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
 		d := actor.getDealProposal(rt, dealId)
@@ -2296,8 +2529,8 @@ func TestCronTickDealSlashing(t *testing.T) {
 		require.EqualValues(t, big.Zero(), slashed)
 
 		// Setting the current epoch to before the next schedule will NOT make any changes as the deal
-		// is still not scheduled
-		current = rt.SetEpoch(current + market.DealUpdatesInterval() - 1)
+		// is still not scheduled. The deal is processed late, but remains scheduled at its offset.
+		current = rt.SetEpoch(processStart + market.DealUpdatesInterval() - 1)
 		actor.cronTickNoChange(rt, client, provider)
 
 		// a second cron tick for the same epoch should not change anything
@@ -2305,7 +2538,7 @@ func TestCronTickDealSlashing(t *testing.T) {
 
 		//  make another payment
 		current = rt.SetEpoch(current + 1)
-		duration := big.NewInt(int64(market.DealUpdatesInterval()))
+		duration := big.NewInt(int64(market.DealUpdatesInterval() - 5))
 		pay, slashed = actor.cronTickAndAssertBalances(rt, client, provider, current, dealId)
 		require.EqualValues(t, pay, big.Mul(duration, d.StoragePricePerEpoch))
 		require.EqualValues(t, big.Zero(), slashed)
@@ -2375,6 +2608,482 @@ func TestCronTickDealSlashing(t *testing.T) {
 	})
 }
 
+func TestClientCollateralSlashing(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+	startEpoch := abi.ChainEpoch(10)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay()
+	sectorExpiry := endEpoch + 100
+	providerCollateral := abi.NewTokenAmount(10)
+	clientCollateral := abi.NewTokenAmount(100)
+
+	// The policy is global, so these tests must not run in parallel with others.
+	setClientSlash := func(t *testing.T, percentage int64) {
+		prev := market.CurrentMarketPolicy
+		market.CurrentMarketPolicy.SetPercentageClientCollateralSlash(percentage)
+		t.Cleanup(func() { market.CurrentMarketPolicy = prev })
+	}
+
+	publishAndActivate := func(rt *mock.Runtime, actor *marketActorTestHarness) (abi.DealID, *market.DealProposal) {
+		deal := actor.generateDealWithCollateralAndAddFunds(rt, client, mAddrs, providerCollateral, clientCollateral,
+			startEpoch, endEpoch)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealId := actor.publishDeals(rt, mAddrs, publishDealReq{deal: deal})[0]
+		actor.activateDeals(rt, sectorExpiry, provider, rt.Epoch(), dealId)
+		return dealId, &deal
+	}
+
+	t.Run("penalty is a fraction of client collateral set by policy", func(t *testing.T) {
+		assert.Equal(t, big.Zero(), market.ClientCollateralPenaltyForDealTermination(clientCollateral))
+
+		setClientSlash(t, 25)
+		assert.Equal(t, abi.NewTokenAmount(25), market.ClientCollateralPenaltyForDealTermination(clientCollateral))
+		assert.Equal(t, builtin.BigFrac{Numerator: big.NewInt(25), Denominator: big.NewInt(100)}, market.ClientCollateralSlashTarget())
+
+		// The penalty never exceeds the collateral.
+		setClientSlash(t, 150)
+		assert.Equal(t, clientCollateral, market.ClientCollateralPenaltyForDealTermination(clientCollateral))
+	})
+
+	t.Run("client collateral is returned in full on termination by default", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId, deal := publishAndActivate(rt, actor)
+		clientEscrow := actor.getEscrowBalance(rt, client)
+
+		rt.SetEpoch(startEpoch + 5)
+		actor.terminateDeals(rt, provider, dealId)
+
+		rt.SetEpoch(processEpoch(t, dealId, startEpoch))
+		pay, slashed := actor.cronTickAndAssertBalances(rt, client, provider, rt.Epoch(), dealId)
+		assert.Equal(t, abi.NewTokenAmount(50), pay)
+		assert.Equal(t, providerCollateral, slashed)
+		actor.assertDealDeleted(rt, dealId, deal)
+
+		// The client pays only for storage provided, keeping its collateral.
+		assert.Equal(t, big.Sub(clientEscrow, pay), actor.getEscrowBalance(rt, client))
+		assert.Equal(t, big.Zero(), actor.getLockedBalance(rt, client))
+		actor.checkState(rt)
+	})
+
+	t.Run("policy slashes client collateral on termination", func(t *testing.T) {
+		setClientSlash(t, 40)
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId, deal := publishAndActivate(rt, actor)
+		clientEscrow := actor.getEscrowBalance(rt, client)
+
+		rt.SetEpoch(startEpoch + 5)
+		actor.terminateDeals(rt, provider, dealId)
+
+		rt.SetEpoch(processEpoch(t, dealId, startEpoch))
+		pay, slashed := actor.cronTickAndAssertBalances(rt, client, provider, rt.Epoch(), dealId)
+		assert.Equal(t, abi.NewTokenAmount(50), pay)
+		assert.Equal(t, big.Add(providerCollateral, abi.NewTokenAmount(40)), slashed)
+		actor.assertDealDeleted(rt, dealId, deal)
+
+		assert.Equal(t, big.Subtract(clientEscrow, pay, abi.NewTokenAmount(40)), actor.getEscrowBalance(rt, client))
+		assert.Equal(t, big.Zero(), actor.getLockedBalance(rt, client))
+		actor.checkState(rt)
+	})
+
+	t.Run("policy does not slash client collateral on expiry", func(t *testing.T) {
+		setClientSlash(t, 100)
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId, deal := publishAndActivate(rt, actor)
+		clientEscrow := actor.getEscrowBalance(rt, client)
+		payment := big.Mul(big.NewInt(10), big.NewInt(int64(endEpoch-startEpoch)))
+
+		rt.SetEpoch(endEpoch + market.DealUpdatesInterval())
+		actor.cronTick(rt)
+		actor.assertDealDeleted(rt, dealId, deal)
+
+		assert.Equal(t, big.Sub(clientEscrow, payment), actor.getEscrowBalance(rt, client))
+		assert.Equal(t, big.Zero(), actor.getLockedBalance(rt, client))
+		actor.checkState(rt)
+	})
+}
+
+func TestSettleDealPayments(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	anyone := tutil.NewIDAddr(t, 105)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay()
+	sectorExpiry := endEpoch + 100
+
+	assertIndices := func(t *testing.T, bf bitfield.BitField, expected ...uint64) {
+		indices, err := bf.All(math.MaxUint64)
+		require.NoError(t, err)
+		if len(expected) == 0 {
+			assert.Empty(t, indices)
+		} else {
+			assert.Equal(t, expected, indices)
+		}
+	}
+
+	t.Run("settles accrued payment and reschedules the deal", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		d := actor.getDealProposal(rt, dealId)
+		clientEscrow := actor.getEscrowBalance(rt, client)
+		providerEscrow := actor.getEscrowBalance(rt, provider)
+
+		// Settle before the deal is first processed by cron.
+		settleEpoch := rt.SetEpoch(startEpoch + 100)
+		ret := actor.settleDealPayments(rt, anyone, big.Zero(), dealId)
+		assertIndices(t, ret.SettledDeals, 0)
+		assertIndices(t, ret.CompletedDeals)
+
+		payment := big.Mul(big.NewInt(100), d.StoragePricePerEpoch)
+		assert.Equal(t, big.Sub(clientEscrow, payment), actor.getEscrowBalance(rt, client))
+		assert.Equal(t, big.Add(providerEscrow, payment), actor.getEscrowBalance(rt, provider))
+		assert.Equal(t, settleEpoch, actor.getDealState(rt, dealId).LastUpdatedEpoch)
+		actor.checkState(rt)
+
+		// The deal remains scheduled at its offset, and cron next pays for the epochs since settlement.
+		current := rt.SetEpoch(processEpoch(t, dealId, settleEpoch+1))
+		pay, slashed := actor.cronTickAndAssertBalances(rt, client, provider, current, dealId)
+		assert.Equal(t, big.Mul(big.NewInt(int64(current-settleEpoch)), d.StoragePricePerEpoch), pay)
+		assert.True(t, slashed.IsZero())
+		actor.checkState(rt)
+	})
+
+	t.Run("fails to settle a deal not scheduled at its expected epoch", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		first := processEpoch(t, dealId, startEpoch)
+		actor.moveDealOp(rt, dealId, first, first+market.DealUpdatesInterval())
+
+		rt.SetEpoch(startEpoch + 100)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalState, "not scheduled at epoch", func() {
+			actor.settleDealPayments(rt, anyone, big.Zero(), dealId)
+		})
+	})
+
+	t.Run("completes terminated deals and slashes them in a batch", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId1 := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		dealId2 := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch+1, endEpoch+1, 0, sectorExpiry)
+		d1 := actor.getDealProposal(rt, dealId1)
+		d2 := actor.getDealProposal(rt, dealId2)
+
+		rt.SetEpoch(startEpoch + 100)
+		actor.terminateDeals(rt, provider, dealId1, dealId2)
+
+		// The deals are completed by one settlement, with deal IDs which can't be settled skipped.
+		rt.SetEpoch(startEpoch + 101)
+		ret := actor.settleDealPayments(rt, anyone, big.Add(d1.ProviderCollateral, d2.ProviderCollateral), dealId1, abi.DealID(99), dealId2)
+		assertIndices(t, ret.SettledDeals, 0, 2)
+		assertIndices(t, ret.CompletedDeals, 0, 2)
+		actor.assertDealDeleted(rt, dealId1, d1)
+		actor.assertDealDeleted(rt, dealId2, d2)
+		assert.Equal(t, big.Zero(), actor.getLockedBalance(rt, client))
+		assert.Equal(t, big.Zero(), actor.getLockedBalance(rt, provider))
+		actor.checkState(rt)
+
+		// Cron has nothing left to do for the deals.
+		rt.SetEpoch(processEpoch(t, dealId2, startEpoch+1))
+		actor.cronTickNoChange(rt, client, provider)
+		actor.checkState(rt)
+	})
+
+	t.Run("completes an expired deal", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		dealId := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		d := actor.getDealProposal(rt, dealId)
+		clientEscrow := actor.getEscrowBalance(rt, client)
+
+		rt.SetEpoch(endEpoch + 10)
+		ret := actor.settleDealPayments(rt, anyone, big.Zero(), dealId)
+		assertIndices(t, ret.SettledDeals, 0)
+		assertIndices(t, ret.CompletedDeals, 0)
+		actor.assertDealDeleted(rt, dealId, d)
+		assert.Equal(t, big.Sub(clientEscrow, d.TotalStorageFee()), actor.getEscrowBalance(rt, client))
+		assert.Equal(t, big.Zero(), actor.getLockedBalance(rt, client))
+		assert.Equal(t, big.Zero(), actor.getLockedBalance(rt, provider))
+		actor.checkState(rt)
+	})
+
+	t.Run("skips deals which are not activated or not started", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		activated := actor.publishAndActivateDeal(rt, client, mAddrs, startEpoch, endEpoch, 0, sectorExpiry)
+		unactivated := actor.generateAndPublishDeal(rt, client, mAddrs, startEpoch+1, endEpoch+1)
+		var st market.State
+		rt.GetState(&st)
+
+		rt.SetEpoch(startEpoch - 1)
+		ret := actor.settleDealPayments(rt, anyone, big.Zero(), activated, unactivated)
+		assertIndices(t, ret.SettledDeals)
+		assertIndices(t, ret.CompletedDeals)
+		var stAfter market.State
+		rt.GetState(&stAfter)
+		assert.Equal(t, st, stAfter)
+
+		rt.SetEpoch(startEpoch + 1)
+		ret = actor.settleDealPayments(rt, anyone, big.Zero(), activated, unactivated)
+		assertIndices(t, ret.SettledDeals, 0)
+		actor.checkState(rt)
+	})
+}
+
+func TestPurgeExpiredProposals(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	anyone := tutil.NewIDAddr(t, 105)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay()
+	providerCollateral := abi.NewTokenAmount(1000)
+
+	assertIndices := func(t *testing.T, bf bitfield.BitField, expected ...uint64) {
+		indices, err := bf.All(math.MaxUint64)
+		require.NoError(t, err)
+		if len(expected) == 0 {
+			assert.Empty(t, indices)
+		} else {
+			assert.Equal(t, expected, indices)
+		}
+	}
+
+	publish := func(rt *mock.Runtime, actor *marketActorTestHarness, deals ...market.DealProposal) []abi.DealID {
+		var reqs []publishDealReq
+		for _, d := range deals {
+			reqs = append(reqs, publishDealReq{deal: d})
+		}
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		return actor.publishDeals(rt, mAddrs, reqs...)
+	}
+
+	t.Run("purges an expired proposal, rewarding the caller", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealWithCollateralAndAddFunds(rt, client, mAddrs, providerCollateral, big.Zero(), startEpoch, endEpoch)
+		dealId := publish(rt, actor, deal)[0]
+		clientEscrow := actor.getEscrowBalance(rt, client)
+
+		rt.SetEpoch(startEpoch + 1)
+		penalty := market.CollateralPenaltyForDealActivationMissed(providerCollateral)
+		reward := market.ExpiredProposalPurgeReward(penalty)
+		assert.Equal(t, abi.NewTokenAmount(10), reward)
+		ret := actor.purgeExpiredProposals(rt, anyone, reward, big.Sub(penalty, reward), dealId)
+		assertIndices(t, ret.PurgedDeals, 0)
+
+		actor.assertDealDeleted(rt, dealId, &deal)
+		assert.Equal(t, clientEscrow, actor.getEscrowBalance(rt, client))
+		assert.Equal(t, big.Zero(), actor.getLockedBalance(rt, client))
+		actor.assertAccountZero(rt, provider)
+		actor.checkState(rt)
+
+		// The deal is no longer scheduled for cron.
+		rt.SetEpoch(processEpoch(t, dealId, startEpoch))
+		actor.cronTick(rt)
+		actor.checkState(rt)
+	})
+
+	t.Run("skips proposals which are missing, activated, or may yet be activated", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		d1 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		d2 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch+1)
+		d3 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch+10, endEpoch)
+		dealIds := publish(rt, actor, d1, d2, d3)
+		actor.activateDeals(rt, endEpoch+100, provider, rt.Epoch(), dealIds[1])
+
+		// At its start epoch a deal may still be activated.
+		rt.SetEpoch(startEpoch)
+		ret := actor.purgeExpiredProposals(rt, anyone, big.Zero(), big.Zero(), dealIds[0])
+		assertIndices(t, ret.PurgedDeals)
+
+		rt.SetEpoch(startEpoch + 1)
+		penalty := market.CollateralPenaltyForDealActivationMissed(d1.ProviderCollateral)
+		ret = actor.purgeExpiredProposals(rt, anyone, big.Zero(), penalty, abi.DealID(100), dealIds[1], dealIds[2], dealIds[0])
+		assertIndices(t, ret.PurgedDeals, 3)
+		actor.assertDealDeleted(rt, dealIds[0], &d1)
+		actor.getDealState(rt, dealIds[1])
+		actor.getDealProposal(rt, dealIds[2])
+		actor.checkState(rt)
+	})
+
+	t.Run("restores datacap to the client of a verified deal", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		deal := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		deal.VerifiedDeal = true
+		dealId := publish(rt, actor, deal)[0]
+
+		rt.SetEpoch(startEpoch + 1)
+		rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.RestoreBytes, &verifreg.RestoreBytesParams{
+			Address:  client,
+			DealSize: big.NewIntUnsigned(uint64(deal.PieceSize)),
+		}, big.Zero(), nil, exitcode.Ok)
+		ret := actor.purgeExpiredProposals(rt, anyone, big.Zero(), deal.ProviderCollateral, dealId)
+		assertIndices(t, ret.PurgedDeals, 0)
+		actor.assertDealDeleted(rt, dealId, &deal)
+		actor.checkState(rt)
+	})
+
+	t.Run("fails with too many proposals", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		params := market.PurgeExpiredProposalsParams{DealIDs: make([]abi.DealID, market.PurgeExpiredProposalsMaxSize+1)}
+		rt.SetCaller(anyone, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+			rt.Call(actor.PurgeExpiredProposals, &params)
+		})
+		rt.Verify()
+		actor.checkState(rt)
+	})
+}
+
+func TestActivateSignedDeals(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+
+	startEpoch := abi.ChainEpoch(50)
+	endEpoch := startEpoch + 200*builtin.EpochsInDay()
+	currentEpoch := abi.ChainEpoch(5)
+	sectorExpiry := endEpoch + 100
+
+	t.Run("activates signed deals which were not published", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		rt.SetEpoch(currentEpoch)
+		vd := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		vd.VerifiedDeal = true
+		d := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch+1)
+
+		ret := actor.activateSignedDeals(rt, sectorExpiry, provider, vd, d)
+		assert.Equal(t, []abi.DealID{0, 1}, ret.IDs)
+		assert.Equal(t, uint64(vd.PieceSize+d.PieceSize), ret.Weights.DealSpace)
+		assert.Equal(t, market.DealWeight(&vd), ret.Weights.VerifiedDealWeight)
+		assert.Equal(t, market.DealWeight(&d), ret.Weights.DealWeight)
+		assert.Equal(t, big.NewIntUnsigned(uint64(vd.PieceSize)), actor.getDealState(rt, ret.IDs[0]).DataCap)
+		assert.Equal(t, big.Zero(), actor.getDealState(rt, ret.IDs[1]).DataCap)
+		assert.Equal(t, big.Add(vd.ClientBalanceRequirement(), d.ClientBalanceRequirement()), actor.getLockedBalance(rt, client))
+		assert.Equal(t, big.Add(vd.ProviderCollateral, d.ProviderCollateral), actor.getLockedBalance(rt, provider))
+		actor.checkState(rt)
+
+		// The deals are processed by cron as if they had been published and then activated.
+		first, price := ret.IDs[0], vd.StoragePricePerEpoch
+		if processEpoch(t, ret.IDs[1], startEpoch) < processEpoch(t, first, startEpoch) {
+			first, price = ret.IDs[1], d.StoragePricePerEpoch
+		}
+		current := rt.SetEpoch(processEpoch(t, first, startEpoch))
+		pay, slashed := actor.cronTickAndAssertBalances(rt, client, provider, current, first)
+		assert.Equal(t, big.Mul(big.NewInt(int64(current-startEpoch)), price), pay)
+		assert.Equal(t, big.Zero(), slashed)
+		actor.checkState(rt)
+	})
+
+	t.Run("fail when proposal has already been published", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		rt.SetEpoch(currentEpoch)
+		d := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		actor.publishDeals(rt, mAddrs, publishDealReq{deal: d})
+		actor.addProviderFunds(rt, d.ProviderCollateral, mAddrs)
+		actor.addParticipantFunds(rt, client, d.ClientBalanceRequirement())
+
+		rt.SetCaller(provider, builtin.StorageMinerActorCodeID)
+		rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+		expectQueryNetworkInfo(rt, actor)
+		params := actor.signedDealsParams(rt, sectorExpiry, d)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "duplicate deal proposal 0", func() {
+			rt.Call(actor.ActivateSignedDeals, params)
+		})
+		rt.Verify()
+		actor.checkState(rt)
+	})
+
+	t.Run("fail when proposal is duplicated", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		rt.SetEpoch(currentEpoch)
+		d := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		actor.addProviderFunds(rt, d.ProviderCollateral, mAddrs)
+		actor.addParticipantFunds(rt, client, d.ClientBalanceRequirement())
+
+		rt.SetCaller(provider, builtin.StorageMinerActorCodeID)
+		rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+		expectQueryNetworkInfo(rt, actor)
+		params := actor.signedDealsParams(rt, sectorExpiry, d, d)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "duplicate deal proposal 1", func() {
+			rt.Call(actor.ActivateSignedDeals, params)
+		})
+		rt.Verify()
+		actor.checkState(rt)
+	})
+
+	t.Run("fail when client funds do not cover all deals", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		rt.SetEpoch(currentEpoch)
+		d1 := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+		d2 := generateDealProposal(client, provider, startEpoch, endEpoch+1)
+		actor.addProviderFunds(rt, d2.ProviderCollateral, mAddrs)
+
+		rt.SetCaller(provider, builtin.StorageMinerActorCodeID)
+		rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+		expectQueryNetworkInfo(rt, actor)
+		params := actor.signedDealsParams(rt, sectorExpiry, d1, d2)
+		rt.ExpectAbortContainsMessage(exitcode.ErrInsufficientFunds, "insufficient client funds to cover deal 1", func() {
+			rt.Call(actor.ActivateSignedDeals, params)
+		})
+		rt.Verify()
+		actor.checkState(rt)
+	})
+
+	t.Run("fail when caller is not the provider of the deal", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		rt.SetEpoch(currentEpoch)
+		provider2 := tutil.NewIDAddr(t, 201)
+		d := generateDealProposal(client, provider2, startEpoch, endEpoch)
+
+		rt.SetCaller(provider, builtin.StorageMinerActorCodeID)
+		rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+		expectQueryNetworkInfo(rt, actor)
+		params := actor.signedDealsParams(rt, sectorExpiry, d)
+		rt.ExpectAbort(exitcode.ErrForbidden, func() {
+			rt.Call(actor.ActivateSignedDeals, params)
+		})
+		rt.Verify()
+		actor.checkState(rt)
+	})
+
+	t.Run("fail when deal ends after the sector expires", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		rt.SetEpoch(currentEpoch)
+		d := actor.generateDealAndAddFunds(rt, client, mAddrs, startEpoch, endEpoch)
+
+		rt.SetCaller(provider, builtin.StorageMinerActorCodeID)
+		rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+		expectQueryNetworkInfo(rt, actor)
+		params := actor.signedDealsParams(rt, endEpoch-1, d)
+		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+			rt.Call(actor.ActivateSignedDeals, params)
+		})
+		rt.Verify()
+		actor.checkState(rt)
+	})
+
+	t.Run("fail when caller is not a StorageMinerActor", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		rt.SetCaller(provider, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.ActivateSignedDeals, &market.ActivateSignedDealsParams{})
+		})
+		rt.Verify()
+		actor.checkState(rt)
+	})
+}
+
 func TestMarketActorDeals(t *testing.T) {
 	owner := tutil.NewIDAddr(t, 101)
 	provider := tutil.NewIDAddr(t, 102)
@@ -2415,7 +3124,7 @@ func TestMarketActorDeals(t *testing.T) {
 		rt.Verify()
 	}
 
-	dealProposal.Label = "foo"
+	dealProposal.Label = mustLabel("foo")
 
 	// Same deal with a different label should work
 	{
@@ -2425,6 +3134,91 @@ func TestMarketActorDeals(t *testing.T) {
 	actor.checkState(rt)
 }
 
+func TestDealLabel(t *testing.T) {
+	roundTrip := func(t *testing.T, label market.DealLabel) (market.DealLabel, []byte) {
+		encoded := mustCbor(&label)
+		var decoded market.DealLabel
+		require.NoError(t, decoded.UnmarshalCBOR(bytes.NewReader(encoded)))
+		return decoded, encoded
+	}
+
+	t.Run("string labels are text strings", func(t *testing.T) {
+		label, err := market.NewLabelFromString("label")
+		require.NoError(t, err)
+		decoded, encoded := roundTrip(t, label)
+		assert.Equal(t, append([]byte{0x60 | 5}, "label"...), encoded)
+		assert.Equal(t, label, decoded)
+		assert.True(t, decoded.IsString())
+		s, err := decoded.ToString()
+		require.NoError(t, err)
+		assert.Equal(t, "label", s)
+
+		// The zero value is the empty string.
+		decoded, encoded = roundTrip(t, market.DealLabel{})
+		assert.Equal(t, []byte{0x60}, encoded)
+		assert.True(t, decoded.IsString())
+		assert.Equal(t, 0, decoded.Length())
+	})
+
+	t.Run("bytes labels round-trip without UTF-8", func(t *testing.T) {
+		raw := []byte{0xff, 0xfe, 0x00, 'a'}
+		_, err := market.NewLabelFromString(string(raw))
+		require.Error(t, err)
+
+		label := market.NewLabelFromBytes(raw)
+		decoded, encoded := roundTrip(t, label)
+		assert.Equal(t, append([]byte{0x40 | 4}, raw...), encoded)
+		assert.Equal(t, label, decoded)
+		assert.True(t, decoded.IsBytes())
+		assert.Equal(t, raw, decoded.ToBytes())
+		assert.Equal(t, 4, decoded.Length())
+		_, err = decoded.ToString()
+		assert.Error(t, err)
+	})
+
+	t.Run("text strings which are not UTF-8 are rejected", func(t *testing.T) {
+		var decoded market.DealLabel
+		err := decoded.UnmarshalCBOR(bytes.NewReader([]byte{0x60 | 2, 0xff, 0xfe}))
+		assert.Error(t, err)
+	})
+
+	t.Run("the label representation distinguishes proposals", func(t *testing.T) {
+		client := tutil.NewIDAddr(t, 104)
+		provider := tutil.NewIDAddr(t, 102)
+		withString := generateDealProposal(client, provider, abi.ChainEpoch(1), abi.ChainEpoch(200*builtin.EpochsInDay()))
+		withBytes := withString
+		withBytes.Label = market.NewLabelFromBytes([]byte("label"))
+
+		var decoded market.DealProposal
+		require.NoError(t, decoded.UnmarshalCBOR(bytes.NewReader(mustCbor(&withBytes))))
+		assert.Equal(t, withBytes, decoded)
+
+		stringCid, err := withString.Cid()
+		require.NoError(t, err)
+		bytesCid, err := withBytes.Cid()
+		require.NoError(t, err)
+		assert.NotEqual(t, stringCid, bytesCid)
+	})
+
+	t.Run("deals may be published with bytes labels", func(t *testing.T) {
+		owner := tutil.NewIDAddr(t, 101)
+		provider := tutil.NewIDAddr(t, 102)
+		worker := tutil.NewIDAddr(t, 103)
+		client := tutil.NewIDAddr(t, 104)
+		minerAddrs := &minerAddrs{owner, worker, provider, nil}
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+		actor.addProviderFunds(rt, abi.NewTokenAmount(20000000), minerAddrs)
+		actor.addParticipantFunds(rt, client, abi.NewTokenAmount(20000000))
+
+		dealProposal := generateDealProposal(client, provider, abi.ChainEpoch(1), abi.ChainEpoch(200*builtin.EpochsInDay()))
+		dealProposal.Label = market.NewLabelFromBytes([]byte{0xff, 0xfe})
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIDs := actor.publishDeals(rt, minerAddrs, publishDealReq{deal: dealProposal})
+		assert.Equal(t, dealProposal.Label, actor.getDealProposal(rt, dealIDs[0]).Label)
+		actor.checkState(rt)
+	})
+}
+
 func TestMaxDealLabelSize(t *testing.T) {
 	owner := tutil.NewIDAddr(t, 101)
 	provider := tutil.NewIDAddr(t, 102)
@@ -2443,7 +3237,7 @@ func TestMaxDealLabelSize(t *testing.T) {
 	actor.addParticipantFunds(rt, client, abi.NewTokenAmount(20000000))
 
 	dealProposal := generateDealProposal(client, provider, abi.ChainEpoch(1), abi.ChainEpoch(200*builtin.EpochsInDay()))
-	dealProposal.Label = string(make([]byte, market.DealMaxLabelSize))
+	dealProposal.Label = mustLabel(string(make([]byte, market.DealMaxLabelSize)))
 	params := &market.PublishStorageDealsParams{Deals: []market.ClientDealProposal{{Proposal: dealProposal}}}
 
 	// Label at max size should work.
@@ -2452,7 +3246,7 @@ func TestMaxDealLabelSize(t *testing.T) {
 		actor.publishDeals(rt, minerAddrs, publishDealReq{deal: dealProposal})
 	}
 
-	dealProposal.Label = string(make([]byte, market.DealMaxLabelSize+1))
+	dealProposal.Label = mustLabel(string(make([]byte, market.DealMaxLabelSize+1)))
 
 	// Label greater than max size should fail.
 	{
@@ -2698,6 +3492,36 @@ func TestVerifyDealsForActivation(t *testing.T) {
 		actor.checkState(rt)
 	})
 
+	t.Run("weights computed from deal sizes and durations match verification", func(t *testing.T) {
+		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+
+		vd := actor.generateDealAndAddFunds(rt, client, mAddrs, start, end)
+		vd.VerifiedDeal = true
+		d := actor.generateDealAndAddFunds(rt, client, mAddrs, start, end+1)
+
+		rt.SetCaller(worker, builtin.AccountActorCodeID)
+		dealIds := actor.publishDeals(rt, mAddrs, publishDealReq{deal: vd}, publishDealReq{deal: d})
+
+		resp := actor.verifyDealsForActivation(rt, provider, []market.SectorDeals{{
+			SectorExpiry: sectorExpiry,
+			DealIDs:      dealIds,
+		}})
+
+		weights := market.SectorWeightsForDeals([]market.DealSpec{
+			{PieceSize: vd.PieceSize, Duration: end - start, Verified: true},
+			{PieceSize: d.PieceSize, Duration: end + 1 - start, Verified: false},
+		})
+		assert.Equal(t, resp.Sectors[0], weights)
+		assert.Equal(t, market.DealWeight(&d), market.DealWeightForPiece(d.PieceSize, end+1-start))
+		assert.Equal(t, uint64(vd.PieceSize+d.PieceSize), weights.DealSpace)
+
+		empty := market.SectorWeightsForDeals(nil)
+		assert.True(t, empty.DealWeight.IsZero())
+		assert.True(t, empty.VerifiedDealWeight.IsZero())
+		assert.Zero(t, empty.DealSpace)
+		actor.checkState(rt)
+	})
+
 	t.Run("fail when caller is not a StorageMinerActor", func(t *testing.T) {
 		rt, actor := basicMarketSetup(t, owner, provider, worker, client)
 		dealId := actor.generateAndPublishDeal(rt, client, mAddrs, start, end)
@@ -2857,6 +3681,38 @@ func (h *marketActorTestHarness) addParticipantFunds(rt *mock.Runtime, addr addr
 	rt.SetBalance(big.Add(rt.Balance(), amount))
 }
 
+func (h *marketActorTestHarness) addBalances(rt *mock.Runtime, caller address.Address, deposits ...market.EscrowAmount) {
+	total := big.Zero()
+	for _, d := range deposits {
+		total = big.Add(total, d.Amount)
+	}
+	rt.SetReceived(total)
+	rt.SetCaller(caller, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+
+	rt.Call(h.AddBalances, &market.AddBalancesParams{Deposits: deposits})
+	rt.Verify()
+	rt.SetBalance(big.Add(rt.Balance(), total))
+}
+
+// Expectations of control address queries and sends must be set by the caller.
+func (h *marketActorTestHarness) withdrawBalances(rt *mock.Runtime, caller address.Address, withdrawals ...market.EscrowAmount) []abi.TokenAmount {
+	rt.SetCaller(caller, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAny()
+
+	ret := rt.Call(h.WithdrawBalances, &market.WithdrawBalancesParams{Withdrawals: withdrawals})
+	rt.Verify()
+	withdrawn, ok := ret.(*market.WithdrawBalancesReturn)
+	require.True(h.t, ok, "unexpected return type from WithdrawBalances")
+
+	total := big.Zero()
+	for _, w := range withdrawn.AmountsWithdrawn {
+		total = big.Add(total, w)
+	}
+	rt.SetBalance(big.Sub(rt.Balance(), total))
+	return withdrawn.AmountsWithdrawn
+}
+
 func (h *marketActorTestHarness) withdrawProviderBalance(rt *mock.Runtime, withDrawAmt, expectedSend abi.TokenAmount, miner *minerAddrs) {
 	rt.SetCaller(miner.worker, builtin.AccountActorCodeID)
 	rt.ExpectValidateCallerAddr(miner.owner, miner.worker)
@@ -2932,9 +3788,11 @@ func (h *marketActorTestHarness) cronTickAndAssertBalances(rt *mock.Runtime, cli
 
 	// end epoch for payment calc
 	paymentEnd := d.EndEpoch
+	clientPenalty := big.Zero()
 	if s.SlashEpoch != -1 {
-		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, d.ProviderCollateral, nil, exitcode.Ok)
-		amountSlashed = d.ProviderCollateral
+		clientPenalty = market.ClientCollateralPenaltyForDealTermination(d.ClientCollateral)
+		amountSlashed = big.Add(d.ProviderCollateral, clientPenalty)
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, amountSlashed, nil, exitcode.Ok)
 
 		if s.SlashEpoch < d.StartEpoch {
 			paymentEnd = d.StartEpoch
@@ -2954,9 +3812,9 @@ func (h *marketActorTestHarness) cronTickAndAssertBalances(rt *mock.Runtime, cli
 	payment = big.Mul(big.NewInt(int64(duration)), d.StoragePricePerEpoch)
 
 	// expected updated amounts
-	updatedClientEscrow := big.Sub(cEscrow, payment)
+	updatedClientEscrow := big.Subtract(cEscrow, payment, clientPenalty)
 	updatedProviderEscrow := big.Add(pEscrow, payment)
-	updatedProviderEscrow = big.Sub(updatedProviderEscrow, amountSlashed)
+	updatedProviderEscrow = big.Sub(updatedProviderEscrow, big.Sub(amountSlashed, clientPenalty))
 	updatedClientLocked := big.Sub(cLocked, payment)
 	updatedProviderLocked := pLocked
 	// if the deal has expired or been slashed, locked amount will be zero for provider and client.
@@ -3212,6 +4070,75 @@ func (h *marketActorTestHarness) terminateDeals(rt *mock.Runtime, minerAddr addr
 	require.Nil(h.t, ret)
 }
 
+func (h *marketActorTestHarness) settleDealPayments(rt *mock.Runtime, caller address.Address, expectedBurn abi.TokenAmount, dealIds ...abi.DealID) *market.SettleDealPaymentsReturn {
+	rt.SetCaller(caller, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAny()
+	if !expectedBurn.IsZero() {
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, expectedBurn, nil, exitcode.Ok)
+	}
+
+	ret := rt.Call(h.SettleDealPayments, &market.SettleDealPaymentsParams{DealIDs: dealIds})
+	rt.Verify()
+	return ret.(*market.SettleDealPaymentsReturn)
+}
+
+func (h *marketActorTestHarness) purgeExpiredProposals(rt *mock.Runtime, caller address.Address, expectedReward, expectedBurn abi.TokenAmount,
+	dealIds ...abi.DealID) *market.PurgeExpiredProposalsReturn {
+	rt.SetCaller(caller, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAny()
+	if !expectedReward.IsZero() {
+		rt.ExpectSend(caller, builtin.MethodSend, nil, expectedReward, nil, exitcode.Ok)
+	}
+	if !expectedBurn.IsZero() {
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, expectedBurn, nil, exitcode.Ok)
+	}
+
+	ret := rt.Call(h.PurgeExpiredProposals, &market.PurgeExpiredProposalsParams{DealIDs: dealIds})
+	rt.Verify()
+	rt.SetBalance(big.Subtract(rt.Balance(), expectedReward, expectedBurn))
+	return ret.(*market.PurgeExpiredProposalsReturn)
+}
+
+func (h *marketActorTestHarness) activateSignedDeals(rt *mock.Runtime, sectorExpiry abi.ChainEpoch, provider address.Address,
+	deals ...market.DealProposal) *market.ActivateSignedDealsReturn {
+	rt.SetCaller(provider, builtin.StorageMinerActorCodeID)
+	rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+	expectQueryNetworkInfo(rt, h)
+	params := h.signedDealsParams(rt, sectorExpiry, deals...)
+	for _, deal := range deals {
+		if deal.VerifiedDeal {
+			param := &verifreg.UseBytesParams{
+				Address:  deal.Client,
+				DealSize: big.NewIntUnsigned(uint64(deal.PieceSize)),
+			}
+			rt.ExpectSend(builtin.VerifiedRegistryActorAddr, builtin.MethodsVerifiedRegistry.UseBytes, param, abi.NewTokenAmount(0), nil, exitcode.Ok)
+		}
+	}
+
+	ret := rt.Call(h.ActivateSignedDeals, params).(*market.ActivateSignedDealsReturn)
+	rt.Verify()
+
+	require.Len(h.t, ret.IDs, len(deals))
+	for i, id := range ret.IDs {
+		require.Equal(h.t, deals[i], *h.getDealProposal(rt, id))
+		require.EqualValues(h.t, rt.Epoch(), h.getDealState(rt, id).SectorStartEpoch)
+	}
+	return ret
+}
+
+// Signs deal proposals for activation, expecting each signature to be verified.
+func (h *marketActorTestHarness) signedDealsParams(rt *mock.Runtime, sectorExpiry abi.ChainEpoch, deals ...market.DealProposal) *market.ActivateSignedDealsParams {
+	params := &market.ActivateSignedDealsParams{SectorExpiry: sectorExpiry}
+	for _, deal := range deals {
+		buf := bytes.Buffer{}
+		require.NoError(h.t, deal.MarshalCBOR(&buf), "failed to marshal deal proposal")
+		sig := crypto.Signature{Type: crypto.SigTypeBLS, Data: []byte("does not matter")}
+		params.Deals = append(params.Deals, market.ClientDealProposal{Proposal: deal, ClientSignature: sig})
+		rt.ExpectVerifySignature(sig, deal.Client, buf.Bytes(), nil)
+	}
+	return params
+}
+
 func (h *marketActorTestHarness) publishAndActivateDeal(rt *mock.Runtime, client address.Address, minerAddrs *minerAddrs,
 	startEpoch, endEpoch, currentEpoch, sectorExpiry abi.ChainEpoch) abi.DealID {
 	deal := h.generateDealAndAddFunds(rt, client, minerAddrs, startEpoch, endEpoch)
@@ -3232,12 +4159,27 @@ func (h *marketActorTestHarness) updateLastUpdated(rt *mock.Runtime, dealId abi.
 	require.NoError(h.t, err)
 	require.NotNil(h.t, s)
 
-	require.NoError(h.t, states.Set(dealId, &market.DealState{s.SectorStartEpoch, newLastUpdated, s.SlashEpoch}))
+	require.NoError(h.t, states.Set(dealId, &market.DealState{s.SectorStartEpoch, newLastUpdated, s.SlashEpoch, s.DataCap}))
 	st.States, err = states.Root()
 	require.NoError(h.t, err)
 	rt.ReplaceState(&st)
 }
 
+func (h *marketActorTestHarness) moveDealOp(rt *mock.Runtime, dealId abi.DealID, from, to abi.ChainEpoch) {
+	var st market.State
+	rt.GetState(&st)
+
+	dealOps, err := market.AsSetMultimap(adt.AsStore(rt), st.DealOpsByEpoch, builtin.DefaultHamtBitwidth, builtin.DefaultHamtBitwidth)
+	require.NoError(h.t, err)
+	found, err := dealOps.Remove(from, dealId)
+	require.NoError(h.t, err)
+	require.True(h.t, found)
+	require.NoError(h.t, dealOps.Put(to, dealId))
+	st.DealOpsByEpoch, err = dealOps.Root()
+	require.NoError(h.t, err)
+	rt.ReplaceState(&st)
+}
+
 func (h *marketActorTestHarness) deleteDealProposal(rt *mock.Runtime, dealId abi.DealID) {
 	var st market.State
 	rt.GetState(&st)
@@ -3266,7 +4208,7 @@ func (h *marketActorTestHarness) generateAndPublishDealForPiece(rt *mock.Runtime
 	clientCollateral := big.NewInt(10)
 	providerCollateral := big.NewInt(10)
 
-	deal := market.DealProposal{PieceCID: pieceCID, PieceSize: pieceSize, Client: client, Provider: minerAddrs.provider, Label: "label", StartEpoch: startEpoch,
+	deal := market.DealProposal{PieceCID: pieceCID, PieceSize: pieceSize, Client: client, Provider: minerAddrs.provider, Label: mustLabel("label"), StartEpoch: startEpoch,
 		EndEpoch: endEpoch, StoragePricePerEpoch: storagePerEpoch, ProviderCollateral: providerCollateral, ClientCollateral: clientCollateral}
 
 	// add funds
@@ -3318,7 +4260,7 @@ func generateDealProposalWithCollateral(client, provider address.Address, provid
 	pieceSize := abi.PaddedPieceSize(2048)
 	storagePerEpoch := big.NewInt(10)
 
-	return market.DealProposal{PieceCID: pieceCid, PieceSize: pieceSize, Client: client, Provider: provider, Label: "label", StartEpoch: startEpoch,
+	return market.DealProposal{PieceCID: pieceCid, PieceSize: pieceSize, Client: client, Provider: provider, Label: mustLabel("label"), StartEpoch: startEpoch,
 		EndEpoch: endEpoch, StoragePricePerEpoch: storagePerEpoch, ProviderCollateral: providerCollateral, ClientCollateral: clientCollateral}
 }
 
@@ -12,12 +12,19 @@ type Policy struct {
 	providerCollateralSupplyTarget builtin.BigFrac
 	DealMinDuration                abi.ChainEpoch
 	DealMaxDuration                abi.ChainEpoch
+	// Fraction of a deal's client collateral slashed when the deal is terminated before its end epoch.
+	clientCollateralSlashTarget builtin.BigFrac
 }
 
 func (p *Policy) SetPercentageCollateralSupply(percentageCollateralSupply int64)  {
 	p.providerCollateralSupplyTarget.Numerator = big.NewInt(percentageCollateralSupply)
 }
 
+// Sets the percentage of client collateral slashed on early termination of a deal. Zero disables client slashing.
+func (p *Policy) SetPercentageClientCollateralSlash(percentageClientCollateralSlash int64) {
+	p.clientCollateralSlashTarget.Numerator = big.NewInt(percentageClientCollateralSlash)
+}
+
 func MakeMarketPolicy(dealUpdatesInterval abi.ChainEpoch,
 	percentageCollateralSupply int64,
 	dealMinDuration abi.ChainEpoch,
@@ -30,6 +37,11 @@ func MakeMarketPolicy(dealUpdatesInterval abi.ChainEpoch,
 		},
 		DealMinDuration: dealMinDuration,
 		DealMaxDuration: dealMaxDuration,
+		// Client collateral is not slashed unless a policy opts in.
+		clientCollateralSlashTarget: builtin.BigFrac{
+			Numerator:   big.Zero(),
+			Denominator: big.NewInt(100),
+		},
 	}
 }
 
@@ -43,6 +55,16 @@ var CurrentMarketPolicy = DefaultMarketPolicy
 // DealMaxLabelSize is the maximum size of a deal label.
 const DealMaxLabelSize = 256
 
+// Maximum number of balances adjusted by a single AddBalances or WithdrawBalances message.
+const BalanceBatchMaxSize = 256
+
+// Maximum number of deal proposals purged by a single PurgeExpiredProposals message.
+const PurgeExpiredProposalsMaxSize = 256
+
+// Share of the collateral slashed from expired proposals which is paid to the caller purging them,
+// applied as slashed / expiredProposalPurgeRewardShare.
+const expiredProposalPurgeRewardShare int64 = 100
+
 func DealUpdatesInterval() abi.ChainEpoch {
 	return CurrentMarketPolicy.DealUpdatesInterval
 }
@@ -50,6 +72,9 @@ func DealUpdatesInterval() abi.ChainEpoch {
 func ProviderCollateralSupplyTarget() builtin.BigFrac {
 	return CurrentMarketPolicy.providerCollateralSupplyTarget
 }
+func ClientCollateralSlashTarget() builtin.BigFrac {
+	return CurrentMarketPolicy.clientCollateralSlashTarget
+}
 func DealMinDuration() abi.ChainEpoch {
 	return CurrentMarketPolicy.DealMinDuration
 }
@@ -92,10 +117,59 @@ func CollateralPenaltyForDealActivationMissed(providerCollateral abi.TokenAmount
 	return providerCollateral
 }
 
+// Reward to the caller purging expired deal proposals, out of the provider collateral slashed from them.
+func ExpiredProposalPurgeReward(slashed abi.TokenAmount) abi.TokenAmount {
+	return big.Div(slashed, big.NewInt(expiredProposalPurgeRewardShare))
+}
+
+// Penalty to client deal collateral if the deal is terminated before its end epoch.
+// The remainder of the collateral is returned to the client.
+func ClientCollateralPenaltyForDealTermination(clientCollateral abi.TokenAmount) abi.TokenAmount {
+	target := CurrentMarketPolicy.clientCollateralSlashTarget
+	penalty := big.Div(big.Mul(clientCollateral, target.Numerator), target.Denominator)
+	return big.Min(penalty, clientCollateral)
+}
+
 // Computes the weight for a deal proposal, which is a function of its size and duration.
 func DealWeight(proposal *DealProposal) abi.DealWeight {
-	dealDuration := big.NewInt(int64(proposal.Duration()))
-	dealSize := big.NewIntUnsigned(uint64(proposal.PieceSize))
+	return DealWeightForPiece(proposal.PieceSize, proposal.Duration())
+}
+
+// Computes the weight for a deal with a piece size and duration, which is the space-time the deal occupies.
+func DealWeightForPiece(pieceSize abi.PaddedPieceSize, duration abi.ChainEpoch) abi.DealWeight {
+	dealDuration := big.NewInt(int64(duration))
+	dealSize := big.NewIntUnsigned(uint64(pieceSize))
 	dealSpaceTime := big.Mul(dealDuration, dealSize)
 	return dealSpaceTime
 }
+
+// The properties of a deal which determine its contribution to the weights of a sector.
+type DealSpec struct {
+	PieceSize abi.PaddedPieceSize
+	Duration  abi.ChainEpoch
+	Verified  bool
+}
+
+func dealSpec(proposal *DealProposal) DealSpec {
+	return DealSpec{PieceSize: proposal.PieceSize, Duration: proposal.Duration(), Verified: proposal.VerifiedDeal}
+}
+
+// Computes the weights of a sector holding deals, as the market computes them when the deals are activated.
+// This allows the quality of a sector to be predicted before its deals are published.
+func SectorWeightsForDeals(deals []DealSpec) SectorWeights {
+	weights := SectorWeights{DealWeight: big.Zero(), VerifiedDealWeight: big.Zero()}
+	for _, deal := range deals {
+		weights.addDeal(deal)
+	}
+	return weights
+}
+
+func (w *SectorWeights) addDeal(deal DealSpec) {
+	w.DealSpace += uint64(deal.PieceSize)
+	weight := DealWeightForPiece(deal.PieceSize, deal.Duration)
+	if deal.Verified {
+		w.VerifiedDealWeight = big.Add(w.VerifiedDealWeight, weight)
+	} else {
+		w.DealWeight = big.Add(w.DealWeight, weight)
+	}
+}
@@ -0,0 +1,344 @@
+package market
+
+import (
+	"sort"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// Read-only queries of the market's state, for tools outside the VM which index deals.
+// The indexes a reader builds are of the head it was created from; an indexer should create a reader per head
+// and query it for as many providers and clients as it needs, so that the cost of building them is shared.
+type StateReader struct {
+	store adt.Store
+	st    State
+	// Loaded on first use.
+	proposals *DealArray
+	states    *DealMetaArray
+	// Built on first use, each by one pass over the deal proposals.
+	parties *partyIndex
+	pending []pendingEntry
+}
+
+// The IDs of the deals with each provider and client, in increasing order.
+type partyIndex struct {
+	byProvider map[addr.Address][]abi.DealID
+	byClient   map[addr.Address][]abi.DealID
+}
+
+// A pending proposal, indexed by its start epoch.
+type pendingEntry struct {
+	start abi.ChainEpoch
+	id    abi.DealID
+}
+
+// A deal's proposal and, if the deal has been activated, its state.
+type Deal struct {
+	ID       abi.DealID
+	Proposal DealProposal
+	State    *DealState // Nil if the deal has not been activated.
+}
+
+// A page of the deals matching a query, in increasing deal ID order.
+type DealPage struct {
+	Deals []Deal
+	// The cursor from which to query the next page, if there are more deals to examine.
+	Next abi.DealID
+	More bool
+}
+
+// The deals scheduled for processing by cron at an epoch, in increasing deal ID order.
+type EpochDeals struct {
+	Epoch abi.ChainEpoch
+	Deals []abi.DealID
+}
+
+// The datacap consumed by the verified deals which are active in the market.
+type DataCapUsage struct {
+	Total      abi.StoragePower
+	ByClient   map[addr.Address]abi.StoragePower
+	ByProvider map[addr.Address]abi.StoragePower
+}
+
+// Loads the market's state from its head.
+func NewStateReader(store adt.Store, head cid.Cid) (*StateReader, error) {
+	r := &StateReader{store: store}
+	if err := store.Get(store.Context(), head, &r.st); err != nil {
+		return nil, xerrors.Errorf("failed to load market state %v: %w", head, err)
+	}
+	return r, nil
+}
+
+// The state loaded from the head. The reader's indexes are built from it, so it must not be modified.
+func (r *StateReader) State() *State {
+	return &r.st
+}
+
+func (r *StateReader) loadProposals() (*DealArray, error) {
+	if r.proposals == nil {
+		proposals, err := AsDealProposalArray(r.store, r.st.Proposals)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load deal proposals: %w", err)
+		}
+		r.proposals = proposals
+	}
+	return r.proposals, nil
+}
+
+func (r *StateReader) loadStates() (*DealMetaArray, error) {
+	if r.states == nil {
+		states, err := AsDealStateArray(r.store, r.st.States)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load deal states: %w", err)
+		}
+		r.states = states
+	}
+	return r.states, nil
+}
+
+// Returns a deal's proposal and state, if the proposal is present.
+func (r *StateReader) Deal(id abi.DealID) (*Deal, bool, error) {
+	proposals, err := r.loadProposals()
+	if err != nil {
+		return nil, false, err
+	}
+	proposal, found, err := proposals.Get(id)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	deal := Deal{ID: id, Proposal: *proposal}
+	if err := r.loadDealState(&deal); err != nil {
+		return nil, false, err
+	}
+	return &deal, true, nil
+}
+
+// Loads an indexed deal, whose proposal must be present.
+func (r *StateReader) loadDeal(id abi.DealID) (Deal, error) {
+	deal, found, err := r.Deal(id)
+	if err != nil {
+		return Deal{}, xerrors.Errorf("failed to load deal %d: %w", id, err)
+	}
+	if !found {
+		return Deal{}, xerrors.Errorf("no proposal for indexed deal %d", id)
+	}
+	return *deal, nil
+}
+
+// Returns up to `limit` deals with a provider, starting from the deal with ID `cursor`.
+// The provider must be given by its ID address, as recorded in deal proposals.
+// Deals are not indexed by party in the state, so the first query by provider or client scans every proposal
+// to build an index in the reader. Later queries load only the deals they return.
+func (r *StateReader) DealsForProvider(provider addr.Address, cursor abi.DealID, limit int) (*DealPage, error) {
+	parties, err := r.loadParties()
+	if err != nil {
+		return nil, err
+	}
+	return r.dealsPage(parties.byProvider[provider], cursor, limit)
+}
+
+// Returns up to `limit` deals with a client, starting from the deal with ID `cursor`.
+// The client must be given by its ID address, as recorded in deal proposals.
+// Deals are indexed by client in the reader, as for DealsForProvider.
+func (r *StateReader) DealsForClient(client addr.Address, cursor abi.DealID, limit int) (*DealPage, error) {
+	parties, err := r.loadParties()
+	if err != nil {
+		return nil, err
+	}
+	return r.dealsPage(parties.byClient[client], cursor, limit)
+}
+
+func (r *StateReader) loadParties() (*partyIndex, error) {
+	if r.parties != nil {
+		return r.parties, nil
+	}
+	proposals, err := r.loadProposals()
+	if err != nil {
+		return nil, err
+	}
+	parties := partyIndex{
+		byProvider: make(map[addr.Address][]abi.DealID),
+		byClient:   make(map[addr.Address][]abi.DealID),
+	}
+	var proposal DealProposal
+	if err := proposals.ForEach(&proposal, func(id int64) error {
+		parties.byProvider[proposal.Provider] = append(parties.byProvider[proposal.Provider], abi.DealID(id))
+		parties.byClient[proposal.Client] = append(parties.byClient[proposal.Client], abi.DealID(id))
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to iterate deal proposals: %w", err)
+	}
+	r.parties = &parties
+	return r.parties, nil
+}
+
+// Loads the page of deals from `ids`, which are in increasing order, starting from `cursor`.
+func (r *StateReader) dealsPage(ids []abi.DealID, cursor abi.DealID, limit int) (*DealPage, error) {
+	if limit <= 0 {
+		return nil, xerrors.Errorf("invalid page limit %d", limit)
+	}
+	ids = ids[sort.Search(len(ids), func(i int) bool { return ids[i] >= cursor }):]
+	page := DealPage{}
+	if len(ids) > limit {
+		page.Next = ids[limit]
+		page.More = true
+		ids = ids[:limit]
+	}
+	for _, id := range ids {
+		deal, err := r.loadDeal(id)
+		if err != nil {
+			return nil, err
+		}
+		page.Deals = append(page.Deals, deal)
+	}
+	return &page, nil
+}
+
+// Returns the pending proposals with start epoch before an epoch, ordered by start epoch and then deal ID.
+// A proposal is pending from publication until the market processes it at its start epoch, and expires
+// then if its deal has not been activated.
+// The pending set holds proposal CIDs, so the first query computes the CID of every proposal to build an index
+// in the reader. Later queries load only the deals they return.
+func (r *StateReader) PendingProposalsByExpiry(before abi.ChainEpoch) ([]Deal, error) {
+	pending, err := r.loadPending()
+	if err != nil {
+		return nil, err
+	}
+	n := sort.Search(len(pending), func(i int) bool { return pending[i].start >= before })
+	var deals []Deal
+	for _, entry := range pending[:n] {
+		deal, err := r.loadDeal(entry.id)
+		if err != nil {
+			return nil, err
+		}
+		deals = append(deals, deal)
+	}
+	return deals, nil
+}
+
+func (r *StateReader) loadPending() ([]pendingEntry, error) {
+	if r.pending != nil {
+		return r.pending, nil
+	}
+	proposals, err := r.loadProposals()
+	if err != nil {
+		return nil, err
+	}
+	pendingSet, err := adt.AsSet(r.store, r.st.PendingProposals, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load pending proposals: %w", err)
+	}
+	pending := []pendingEntry{}
+	var proposal DealProposal
+	if err := proposals.ForEach(&proposal, func(id int64) error {
+		pcid, err := proposal.Cid()
+		if err != nil {
+			return xerrors.Errorf("failed to compute CID of deal proposal %d: %w", id, err)
+		}
+		if isPending, err := pendingSet.Has(abi.CidKey(pcid)); err != nil {
+			return xerrors.Errorf("failed to check pending proposal %d: %w", id, err)
+		} else if isPending {
+			pending = append(pending, pendingEntry{start: proposal.StartEpoch, id: abi.DealID(id)})
+		}
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to iterate deal proposals: %w", err)
+	}
+	// Proposals are iterated in increasing ID order, so a stable sort keeps IDs ordered within an epoch.
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].start < pending[j].start })
+	r.pending = pending
+	return r.pending, nil
+}
+
+// Returns the datacap recorded in the states of activated deals, in total and by client and provider.
+// Deals which timed out before activation, or have been completed, are not included.
+func (r *StateReader) DataCapUsage() (*DataCapUsage, error) {
+	proposals, err := r.loadProposals()
+	if err != nil {
+		return nil, err
+	}
+	states, err := r.loadStates()
+	if err != nil {
+		return nil, err
+	}
+	usage := DataCapUsage{
+		Total:      big.Zero(),
+		ByClient:   make(map[addr.Address]abi.StoragePower),
+		ByProvider: make(map[addr.Address]abi.StoragePower),
+	}
+	add := func(m map[addr.Address]abi.StoragePower, a addr.Address, dataCap abi.StoragePower) {
+		if prev, ok := m[a]; ok {
+			dataCap = big.Add(prev, dataCap)
+		}
+		m[a] = dataCap
+	}
+	var state DealState
+	if err := states.ForEach(&state, func(id int64) error {
+		if state.DataCap.IsZero() {
+			return nil
+		}
+		proposal, found, err := proposals.Get(abi.DealID(id))
+		if err != nil {
+			return xerrors.Errorf("failed to load deal proposal %d: %w", id, err)
+		}
+		if !found {
+			return xerrors.Errorf("no proposal for deal state %d", id)
+		}
+		usage.Total = big.Add(usage.Total, state.DataCap)
+		add(usage.ByClient, proposal.Client, state.DataCap)
+		add(usage.ByProvider, proposal.Provider, state.DataCap)
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to iterate deal states: %w", err)
+	}
+	return &usage, nil
+}
+
+// Returns the deals scheduled for processing by cron at each epoch from `from` until before `to`,
+// omitting epochs at which no deal is scheduled.
+func (r *StateReader) DealSchedule(from, to abi.ChainEpoch) ([]EpochDeals, error) {
+	if to < from {
+		return nil, xerrors.Errorf("invalid epoch range [%d, %d)", from, to)
+	}
+	dealOps, err := AsSetMultimap(r.store, r.st.DealOpsByEpoch, builtin.DefaultHamtBitwidth, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load deal ops: %w", err)
+	}
+	var schedule []EpochDeals
+	for epoch := from; epoch < to; epoch++ {
+		var deals []abi.DealID
+		if err := dealOps.ForEach(epoch, func(id abi.DealID) error {
+			deals = append(deals, id)
+			return nil
+		}); err != nil {
+			return nil, xerrors.Errorf("failed to iterate deal ops at epoch %d: %w", epoch, err)
+		}
+		if len(deals) > 0 {
+			sort.Slice(deals, func(i, j int) bool { return deals[i] < deals[j] })
+			schedule = append(schedule, EpochDeals{Epoch: epoch, Deals: deals})
+		}
+	}
+	return schedule, nil
+}
+
+func (r *StateReader) loadDealState(deal *Deal) error {
+	states, err := r.loadStates()
+	if err != nil {
+		return err
+	}
+	state, found, err := states.Get(deal.ID)
+	if err != nil {
+		return xerrors.Errorf("failed to load state of deal %d: %w", deal.ID, err)
+	}
+	if found {
+		deal.State = state
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package market_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestStateReader(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	provider := tutil.NewIDAddr(t, 102)
+	worker := tutil.NewIDAddr(t, 103)
+	client := tutil.NewIDAddr(t, 104)
+	client2 := tutil.NewIDAddr(t, 105)
+	provider2 := tutil.NewIDAddr(t, 109)
+	mAddrs := &minerAddrs{owner, worker, provider, nil}
+	mAddrs2 := &minerAddrs{owner, worker, provider2, nil}
+	duration := abi.ChainEpoch(200 * builtin.EpochsInDay())
+
+	rt, actor := basicMarketSetup(t, owner, provider, worker, client)
+	deal0 := actor.generateAndPublishDeal(rt, client, mAddrs, 50, 50+duration)
+	deal1 := actor.generateAndPublishDeal(rt, client2, mAddrs, 200, 200+duration)
+	deal2 := actor.generateAndPublishDeal(rt, client, mAddrs2, 100, 100+duration)
+	deal3 := actor.generateAndPublishDeal(rt, client, mAddrs, 150, 150+duration)
+	actor.activateDeals(rt, 300+duration, provider, rt.Epoch(), deal0)
+
+	ids := func(deals []market.Deal) []abi.DealID {
+		var out []abi.DealID
+		for _, d := range deals {
+			out = append(out, d.ID)
+		}
+		return out
+	}
+
+	r, err := market.NewStateReader(rt.AdtStore(), rt.StateRoot())
+	require.NoError(t, err)
+
+	t.Run("deal", func(t *testing.T) {
+		deal, found, err := r.Deal(deal0)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, provider, deal.Proposal.Provider)
+		require.NotNil(t, deal.State)
+		assert.Equal(t, rt.Epoch(), deal.State.SectorStartEpoch)
+
+		deal, found, err = r.Deal(deal1)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Nil(t, deal.State)
+
+		_, found, err = r.Deal(abi.DealID(4))
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("deals for provider are paginated", func(t *testing.T) {
+		page, err := r.DealsForProvider(provider, 0, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []abi.DealID{deal0, deal1}, ids(page.Deals))
+		assert.NotNil(t, page.Deals[0].State)
+		require.True(t, page.More)
+		assert.Equal(t, deal3, page.Next)
+
+		page, err = r.DealsForProvider(provider, page.Next, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []abi.DealID{deal3}, ids(page.Deals))
+		assert.False(t, page.More)
+
+		page, err = r.DealsForProvider(provider2, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []abi.DealID{deal2}, ids(page.Deals))
+		assert.False(t, page.More)
+
+		_, err = r.DealsForProvider(provider, 0, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("deals for client", func(t *testing.T) {
+		page, err := r.DealsForClient(client, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []abi.DealID{deal0, deal2, deal3}, ids(page.Deals))
+		assert.False(t, page.More)
+
+		page, err = r.DealsForClient(client2, deal2, 10)
+		require.NoError(t, err)
+		assert.Empty(t, page.Deals)
+		assert.False(t, page.More)
+	})
+
+	t.Run("pending proposals by expiry", func(t *testing.T) {
+		pending, err := r.PendingProposalsByExpiry(1000)
+		require.NoError(t, err)
+		assert.Equal(t, []abi.DealID{deal0, deal2, deal3, deal1}, ids(pending))
+
+		pending, err = r.PendingProposalsByExpiry(150)
+		require.NoError(t, err)
+		assert.Equal(t, []abi.DealID{deal0, deal2}, ids(pending))
+	})
+
+	t.Run("deal schedule", func(t *testing.T) {
+		schedule, err := r.DealSchedule(0, 2*market.DealUpdatesInterval())
+		require.NoError(t, err)
+		// Each deal is first processed at its offset in the interval following its start.
+		expected := []market.EpochDeals{
+			{Epoch: market.GenRandNextEpoch(50, deal0), Deals: []abi.DealID{deal0}},
+			{Epoch: market.GenRandNextEpoch(200, deal1), Deals: []abi.DealID{deal1}},
+			{Epoch: market.GenRandNextEpoch(100, deal2), Deals: []abi.DealID{deal2}},
+			{Epoch: market.GenRandNextEpoch(150, deal3), Deals: []abi.DealID{deal3}},
+		}
+		sort.Slice(expected, func(i, j int) bool { return expected[i].Epoch < expected[j].Epoch })
+		assert.Equal(t, expected, schedule)
+
+		schedule, err = r.DealSchedule(0, expected[2].Epoch)
+		require.NoError(t, err)
+		assert.Equal(t, expected[:2], schedule)
+
+		_, err = r.DealSchedule(10, 5)
+		assert.Error(t, err)
+	})
+
+	t.Run("processed proposals are no longer pending", func(t *testing.T) {
+		// The activated deal is first processed at its offset following its start.
+		// Unactivated deals scheduled no later than that time out.
+		epoch := rt.SetEpoch(market.GenRandNextEpoch(50, deal0))
+		slashed := big.Zero()
+		var remaining []abi.DealID
+		for _, d := range []struct {
+			id    abi.DealID
+			start abi.ChainEpoch
+		}{{deal2, 100}, {deal3, 150}, {deal1, 200}} {
+			if market.GenRandNextEpoch(d.start, d.id) <= epoch {
+				slashed = big.Add(slashed, actor.getDealProposal(rt, d.id).ProviderCollateral)
+			} else {
+				remaining = append(remaining, d.id)
+			}
+		}
+		if !slashed.IsZero() {
+			rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, slashed, nil, exitcode.Ok)
+		}
+		actor.cronTick(rt)
+
+		r, err := market.NewStateReader(rt.AdtStore(), rt.StateRoot())
+		require.NoError(t, err)
+		pending, err := r.PendingProposalsByExpiry(1000)
+		require.NoError(t, err)
+		assert.Equal(t, remaining, ids(pending))
+		actor.checkState(rt)
+	})
+}
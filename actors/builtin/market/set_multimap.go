@@ -1,6 +1,7 @@
 package market
 
 import (
+	"errors"
 	"reflect"
 
 	"github.com/filecoin-project/go-state-types/abi"
@@ -117,6 +118,40 @@ func (mm *SetMultimap) PutMany(epoch abi.ChainEpoch, vs []abi.DealID) error {
 	return nil
 }
 
+// Removes a value for a key, returning whether it was present. The key is removed with its last value.
+func (mm *SetMultimap) Remove(epoch abi.ChainEpoch, v abi.DealID) (bool, error) {
+	k := abi.UIntKey(uint64(epoch))
+	set, found, err := mm.get(k)
+	if err != nil || !found {
+		return false, err
+	}
+	if found, err = set.TryDelete(dealKey(v)); err != nil || !found {
+		return false, err
+	}
+
+	stopErr := errors.New("stop")
+	empty := true
+	if err = set.ForEach(func(string) error {
+		empty = false
+		return stopErr
+	}); err != nil && err != stopErr {
+		return false, xerrors.Errorf("failed to iterate set %v: %w", epoch, err)
+	}
+	if empty {
+		return true, mm.RemoveAll(epoch)
+	}
+
+	src, err := set.Root()
+	if err != nil {
+		return false, xerrors.Errorf("failed to flush set root: %w", err)
+	}
+	newSetRoot := cbg.CborCid(src)
+	if err = mm.mp.Put(k, &newSetRoot); err != nil {
+		return false, xerrors.Errorf("failed to store set: %w", err)
+	}
+	return true, nil
+}
+
 // Removes all values for a key.
 func (mm *SetMultimap) RemoveAll(key abi.ChainEpoch) error {
 	if _, err := mm.mp.TryDelete(abi.UIntKey(uint64(key))); err != nil {
@@ -23,10 +23,12 @@ type DealSummary struct {
 	SectorStartEpoch abi.ChainEpoch
 	LastUpdatedEpoch abi.ChainEpoch
 	SlashEpoch       abi.ChainEpoch
+	DataCap          abi.StoragePower // Zero if the deal is not activated.
 }
 
 type StateSummary struct {
 	Deals                map[abi.DealID]*DealSummary
+	ActiveDataCap        abi.StoragePower // Datacap consumed by activated deals.
 	PendingProposalCount uint64
 	DealStateCount       uint64
 	LockTableCount       uint64
@@ -57,6 +59,7 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount, c
 	proposalCids := make(map[cid.Cid]struct{})
 	maxDealID := int64(-1)
 	proposalStats := make(map[abi.DealID]*DealSummary)
+	proposalDataCaps := make(map[abi.DealID]abi.StoragePower)
 	expectedDealOps := make(map[abi.DealID]struct{})
 	totalProposalCollateral := abi.NewTokenAmount(0)
 
@@ -86,7 +89,9 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount, c
 				SectorStartEpoch: abi.ChainEpoch(-1),
 				LastUpdatedEpoch: abi.ChainEpoch(-1),
 				SlashEpoch:       abi.ChainEpoch(-1),
+				DataCap:          big.Zero(),
 			}
+			proposalDataCaps[abi.DealID(dealID)] = dealDataCap(&proposal)
 
 			totalProposalCollateral = big.Sum(totalProposalCollateral, proposal.ClientCollateral, proposal.ProviderCollateral)
 
@@ -105,6 +110,7 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount, c
 	//
 
 	dealStateCount := uint64(0)
+	activeDataCap := big.Zero()
 	if dealStates, err := adt.AsArray(store, st.States, StatesAmtBitwidth); err != nil {
 		acc.Addf("error loading deal states: %v", err)
 	} else {
@@ -137,7 +143,11 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount, c
 				stats.SectorStartEpoch = dealState.SectorStartEpoch
 				stats.LastUpdatedEpoch = dealState.LastUpdatedEpoch
 				stats.SlashEpoch = dealState.SlashEpoch
+				stats.DataCap = dealState.DataCap
+				acc.Require(dealState.DataCap.Equals(proposalDataCaps[abi.DealID(dealID)]),
+					"deal %d state datacap %v does not match proposal's %v", dealID, dealState.DataCap, proposalDataCaps[abi.DealID(dealID)])
 			}
+			activeDataCap = big.Add(activeDataCap, dealState.DataCap)
 
 			dealStateCount++
 			return nil
@@ -235,6 +245,9 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount, c
 			return dealOps.ForEach(abi.ChainEpoch(epoch), func(id abi.DealID) error {
 				_, found := proposalStats[id]
 				acc.Require(found, "deal op found for deal id %d with missing proposal at epoch %d", id, epoch)
+				acc.Require(GenRandNextEpoch(abi.ChainEpoch(epoch), id) == abi.ChainEpoch(epoch),
+					"deal op for deal id %d at epoch %d is not at the deal's offset", id, epoch)
+				acc.Require(abi.ChainEpoch(epoch) > st.LastCron, "deal op for deal id %d at epoch %d not after last cron %d", id, epoch, st.LastCron)
 				delete(expectedDealOps, id)
 				dealOpCount++
 				return nil
@@ -247,6 +260,7 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount, c
 
 	return &StateSummary{
 		Deals:                proposalStats,
+		ActiveDataCap:        activeDataCap,
 		PendingProposalCount: pendingProposalCount,
 		DealStateCount:       dealStateCount,
 		LockTableCount:       lockTableCount,
@@ -2,6 +2,7 @@ package market
 
 import (
 	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
 	. "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
 
 	"github.com/ipfs/go-cid"
@@ -52,6 +53,9 @@ type DealState struct {
 	SectorStartEpoch abi.ChainEpoch // -1 if not yet included in proven sector
 	LastUpdatedEpoch abi.ChainEpoch // -1 if deal state never updated
 	SlashEpoch       abi.ChainEpoch // -1 if deal never slashed
+	// Datacap consumed by a verified deal's client when the deal was published, which is the deal's piece size.
+	// Zero for a deal which is not verified.
+	DataCap abi.StoragePower
 }
 
 // Interprets a store as balance table with root `r`.
@@ -81,6 +85,7 @@ func (t *DealMetaArray) Get(id abi.DealID) (*DealState, bool, error) {
 			SectorStartEpoch: epochUndefined,
 			LastUpdatedEpoch: epochUndefined,
 			SlashEpoch:       epochUndefined,
+			DataCap:          big.Zero(),
 		}, false, nil
 	}
 	return &value, true, nil
@@ -42,14 +42,21 @@ var MethodsMultisig = struct {
 	SwapSigner                  abi.MethodNum
 	ChangeNumApprovalsThreshold abi.MethodNum
 	LockBalance                 abi.MethodNum
-}{MethodConstructor, 2, 3, 4, 5, 6, 7, 8, 9}
+	ProposeWithExpiration       abi.MethodNum
+	RemoveExpiredTransaction    abi.MethodNum
+	ApproveBatch                abi.MethodNum
+	SetSignerWeights            abi.MethodNum
+	SetSpendingLimit            abi.MethodNum
+}{MethodConstructor, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
 
 var MethodsPaych = struct {
-	Constructor        abi.MethodNum
-	UpdateChannelState abi.MethodNum
-	Settle             abi.MethodNum
-	Collect            abi.MethodNum
-}{MethodConstructor, 2, 3, 4}
+	Constructor             abi.MethodNum
+	UpdateChannelState      abi.MethodNum
+	Settle                  abi.MethodNum
+	Collect                 abi.MethodNum
+	UpdateChannelStateBatch abi.MethodNum
+	SetWatcher              abi.MethodNum
+}{MethodConstructor, 2, 3, 4, 5, 6}
 
 var MethodsMarket = struct {
 	Constructor              abi.MethodNum
@@ -61,7 +68,12 @@ var MethodsMarket = struct {
 	OnMinerSectorsTerminate  abi.MethodNum
 	ComputeDataCommitment    abi.MethodNum
 	CronTick                 abi.MethodNum
-}{MethodConstructor, 2, 3, 4, 5, 6, 7, 8, 9}
+	SettleDealPayments       abi.MethodNum
+	ActivateSignedDeals      abi.MethodNum
+	AddBalances              abi.MethodNum
+	WithdrawBalances         abi.MethodNum
+	PurgeExpiredProposals    abi.MethodNum
+}{MethodConstructor, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
 
 var MethodsPower = struct {
 	Constructor              abi.MethodNum
@@ -73,42 +85,58 @@ var MethodsPower = struct {
 	Deprecated1              abi.MethodNum
 	SubmitPoRepForBulkVerify abi.MethodNum
 	CurrentTotalPower        abi.MethodNum
-}{MethodConstructor, 2, 3, 4, 5, 6, 7, 8, 9}
+	DeregisterMiner          abi.MethodNum
+	OnConsensusFault         abi.MethodNum
+	EnrollPriorityCronEvent  abi.MethodNum
+}{MethodConstructor, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
 
 var MethodsMiner = struct {
-	Constructor              abi.MethodNum
-	ControlAddresses         abi.MethodNum
-	ChangeWorkerAddress      abi.MethodNum
-	ChangePeerID             abi.MethodNum
-	SubmitWindowedPoSt       abi.MethodNum
-	PreCommitSector          abi.MethodNum
-	ProveCommitSector        abi.MethodNum
-	ExtendSectorExpiration   abi.MethodNum
-	TerminateSectors         abi.MethodNum
-	DeclareFaults            abi.MethodNum
-	DeclareFaultsRecovered   abi.MethodNum
-	OnDeferredCronEvent      abi.MethodNum
-	CheckSectorProven        abi.MethodNum
-	ApplyRewards             abi.MethodNum
-	ReportConsensusFault     abi.MethodNum
-	WithdrawBalance          abi.MethodNum
-	ConfirmSectorProofsValid abi.MethodNum
-	ChangeMultiaddrs         abi.MethodNum
-	CompactPartitions        abi.MethodNum
-	CompactSectorNumbers     abi.MethodNum
-	ConfirmUpdateWorkerKey   abi.MethodNum
-	RepayDebt                abi.MethodNum
-	ChangeOwnerAddress       abi.MethodNum
-	DisputeWindowedPoSt      abi.MethodNum
-	PreCommitSectorBatch     abi.MethodNum
-	ProveCommitAggregate     abi.MethodNum
-}{MethodConstructor, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26}
+	Constructor                        abi.MethodNum
+	ControlAddresses                   abi.MethodNum
+	ChangeWorkerAddress                abi.MethodNum
+	ChangePeerID                       abi.MethodNum
+	SubmitWindowedPoSt                 abi.MethodNum
+	PreCommitSector                    abi.MethodNum
+	ProveCommitSector                  abi.MethodNum
+	ExtendSectorExpiration             abi.MethodNum
+	TerminateSectors                   abi.MethodNum
+	DeclareFaults                      abi.MethodNum
+	DeclareFaultsRecovered             abi.MethodNum
+	OnDeferredCronEvent                abi.MethodNum
+	CheckSectorProven                  abi.MethodNum
+	ApplyRewards                       abi.MethodNum
+	ReportConsensusFault               abi.MethodNum
+	WithdrawBalance                    abi.MethodNum
+	ConfirmSectorProofsValid           abi.MethodNum
+	ChangeMultiaddrs                   abi.MethodNum
+	CompactPartitions                  abi.MethodNum
+	CompactSectorNumbers               abi.MethodNum
+	ConfirmUpdateWorkerKey             abi.MethodNum
+	RepayDebt                          abi.MethodNum
+	ChangeOwnerAddress                 abi.MethodNum
+	DisputeWindowedPoSt                abi.MethodNum
+	PreCommitSectorBatch               abi.MethodNum
+	ProveCommitAggregate               abi.MethodNum
+	ExtendSectorExpirationBatch        abi.MethodNum
+	ChangeBeneficiary                  abi.MethodNum
+	CompactTerminatedSectors           abi.MethodNum
+	DeclareFaultsAndRecoveries         abi.MethodNum
+	RecommitSector                     abi.MethodNum
+	Deregister                         abi.MethodNum
+	ProveCommitWithSignedDeals         abi.MethodNum
+	ChangeWorkerAddressWithPermissions abi.MethodNum
+	DisputeWindowedPoStSectors         abi.MethodNum
+}{MethodConstructor, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34, 35}
 
 var MethodsVerifiedRegistry = struct {
-	Constructor       abi.MethodNum
-	AddVerifier       abi.MethodNum
-	RemoveVerifier    abi.MethodNum
-	AddVerifiedClient abi.MethodNum
-	UseBytes          abi.MethodNum
-	RestoreBytes      abi.MethodNum
-}{MethodConstructor, 2, 3, 4, 5, 6}
+	Constructor                 abi.MethodNum
+	AddVerifier                 abi.MethodNum
+	RemoveVerifier              abi.MethodNum
+	AddVerifiedClient           abi.MethodNum
+	UseBytes                    abi.MethodNum
+	RestoreBytes                abi.MethodNum
+	RemoveVerifiedClientDataCap abi.MethodNum
+	RemoveExpiredDataCap        abi.MethodNum
+	AddVerifierSubKey           abi.MethodNum
+	RemoveVerifierSubKey        abi.MethodNum
+}{MethodConstructor, 2, 3, 4, 5, 6, 7, 8, 9, 10}
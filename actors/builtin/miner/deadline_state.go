@@ -14,6 +14,7 @@ import (
 
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
 	"github.com/filecoin-project/specs-actors/v7/actors/runtime/proof"
+	"github.com/filecoin-project/specs-actors/v7/actors/util"
 	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
 )
 
@@ -50,6 +51,12 @@ type Deadline struct {
 	// verified on-chain.
 	PartitionsPoSted bitfield.BitField
 
+	// Partitions which may have non-faulty or recovering power, and so must be checked for a
+	// missed PoSt at the end of the challenge window. A partition is marked whenever it may gain
+	// such power, and unmarked at the end of a challenge window in which it's found to have none.
+	// Partitions which aren't marked are not loaded at the end of the challenge window.
+	DirtyPartitions bitfield.BitField
+
 	// Partitions with sectors that terminated early.
 	EarlyTerminations bitfield.BitField
 
@@ -181,6 +188,7 @@ func ConstructDeadline(store adt.Store) (*Deadline, error) {
 		TotalSectors:                      0,
 		FaultyPower:                       NewPowerPairZero(),
 		PartitionsPoSted:                  bitfield.New(),
+		DirtyPartitions:                   bitfield.New(),
 		OptimisticPoStSubmissions:         emptyPoStSubmissionsArrayCid,
 		PartitionsSnapshot:                emptyPartitionsArrayCid,
 		OptimisticPoStSubmissionsSnapshot: emptyPoStSubmissionsArrayCid,
@@ -427,6 +435,7 @@ func (dl *Deadline) AddSectors(
 			if err != nil {
 				return NewPowerPairZero(), err
 			}
+			dl.DirtyPartitions.Set(partIdx)
 
 			// Record deadline -> partition mapping so we can later update the deadlines.
 			for _, sector := range partitionNewSectors {
@@ -670,6 +679,7 @@ func (dl *Deadline) RemovePartitions(store adt.Store, toRemove bitfield.BitField
 	if err != nil {
 		return bitfield.BitField{}, bitfield.BitField{}, NewPowerPairZero(), xerrors.Errorf("failed to create empty array for initializing partitions: %w", err)
 	}
+	newDirtyPartitions := bitfield.New()
 	allDeadSectors := make([]bitfield.BitField, 0, len(toRemoveSet))
 	allLiveSectors := make([]bitfield.BitField, 0, len(toRemoveSet))
 	removedPower = NewPowerPairZero()
@@ -683,6 +693,12 @@ func (dl *Deadline) RemovePartitions(store adt.Store, toRemove bitfield.BitField
 	if err = oldPartitions.ForEach(&lazyPartition, func(partIdx int64) error {
 		// If we're keeping the partition as-is, append it to the new partitions array.
 		if _, ok := toRemoveSet[uint64(partIdx)]; !ok {
+			// Carry the dirty mark over to the partition's new index.
+			if dirty, err := dl.DirtyPartitions.IsSet(uint64(partIdx)); err != nil {
+				return xc.ErrIllegalState.Wrapf("failed to check dirty partition %d: %w", partIdx, err)
+			} else if dirty {
+				newDirtyPartitions.Set(newPartitions.Length())
+			}
 			return newPartitions.AppendContinuous(&lazyPartition)
 		}
 
@@ -730,6 +746,7 @@ func (dl *Deadline) RemovePartitions(store adt.Store, toRemove bitfield.BitField
 	if err != nil {
 		return bitfield.BitField{}, bitfield.BitField{}, NewPowerPairZero(), xerrors.Errorf("failed to persist new partition table: %w", err)
 	}
+	dl.DirtyPartitions = newDirtyPartitions
 
 	dead, err = bitfield.MultiMerge(allDeadSectors...)
 	if err != nil {
@@ -775,6 +792,131 @@ func (dl *Deadline) RemovePartitions(store adt.Store, toRemove bitfield.BitField
 	return live, dead, removedPower, nil
 }
 
+// RemoveTerminatedSectors removes up to maxSectors terminated sectors from the specified partitions, returning
+// the sectors removed and whether any terminated sectors remain in the partitions. Partitions are processed in order.
+//
+// Entries for the partitions in the deadline's expiration queue are also pruned to the epochs at which the partition
+// still has sectors expiring. Partitions with faults are not pruned, since a faulty sector's original expiration is
+// restored on recovery without updating this queue.
+//
+// Returns an error if the deadline has any pending early terminations.
+func (dl *Deadline) RemoveTerminatedSectors(store adt.Store, partitionIdxs bitfield.BitField, maxSectors uint64, quant builtin.QuantSpec) (
+	removed bitfield.BitField, hasMore bool, err error,
+) {
+	if noEarlyTerminations, err := dl.EarlyTerminations.IsEmpty(); err != nil {
+		return bitfield.BitField{}, false, xerrors.Errorf("failed to check for early terminations: %w", err)
+	} else if !noEarlyTerminations {
+		return bitfield.BitField{}, false, xc.ErrForbidden.Wrapf("cannot remove terminated sectors from deadline with early terminations")
+	}
+
+	partitions, err := dl.PartitionsArray(store)
+	if err != nil {
+		return bitfield.BitField{}, false, err
+	}
+
+	var (
+		allRemoved   []bitfield.BitField
+		removedCount uint64
+		prunable     []uint64                        // Partitions whose expiration queue entries may be pruned.
+		keep         = map[abi.ChainEpoch][]uint64{} // Partitions with sectors expiring at each epoch.
+		partition    Partition
+	)
+	if err = partitionIdxs.ForEach(func(partIdx uint64) error {
+		if found, err := partitions.Get(partIdx, &partition); err != nil {
+			return xerrors.Errorf("failed to load partition %d: %w", partIdx, err)
+		} else if !found {
+			return xc.ErrNotFound.Wrapf("failed to find partition %d", partIdx)
+		}
+
+		partRemoved, more, err := partition.RemoveTerminatedSectors(maxSectors - removedCount)
+		if err != nil {
+			return xerrors.Errorf("failed to remove terminated sectors from partition %d: %w", partIdx, err)
+		}
+		count, err := partRemoved.Count()
+		if err != nil {
+			return xerrors.Errorf("failed to count removed sectors: %w", err)
+		}
+		allRemoved = append(allRemoved, partRemoved)
+		removedCount += count
+		hasMore = hasMore || more
+
+		if noFaults, err := partition.Faults.IsEmpty(); err != nil {
+			return xerrors.Errorf("failed to check for faults: %w", err)
+		} else if noFaults {
+			expirations, err := LoadExpirationQueue(store, partition.ExpirationsEpochs, quant, PartitionExpirationAmtBitwidth)
+			if err != nil {
+				return xerrors.Errorf("failed to load expiration queue for partition %d: %w", partIdx, err)
+			}
+			if err = expirations.traverse(func(epoch abi.ChainEpoch, _ *ExpirationSet) (bool, error) {
+				keep[epoch] = append(keep[epoch], partIdx)
+				return true, nil
+			}); err != nil {
+				return xerrors.Errorf("failed to traverse expiration queue for partition %d: %w", partIdx, err)
+			}
+			prunable = append(prunable, partIdx)
+		}
+
+		if err = partitions.Set(partIdx, &partition); err != nil {
+			return xerrors.Errorf("failed to store partition %d: %w", partIdx, err)
+		}
+		return nil
+	}); err != nil {
+		return bitfield.BitField{}, false, err
+	}
+
+	if dl.Partitions, err = partitions.Root(); err != nil {
+		return bitfield.BitField{}, false, xerrors.Errorf("failed to persist partitions: %w", err)
+	}
+	if removed, err = bitfield.MultiMerge(allRemoved...); err != nil {
+		return bitfield.BitField{}, false, xerrors.Errorf("failed to merge removed sectors: %w", err)
+	}
+	dl.TotalSectors -= removedCount
+
+	// Prune stale expiration queue entries.
+	{
+		expirationEpochs, err := LoadBitfieldQueue(store, dl.ExpirationsEpochs, quant, DeadlineExpirationAmtBitwidth)
+		if err != nil {
+			return bitfield.BitField{}, false, xerrors.Errorf("failed to load expiration queue: %w", err)
+		}
+		prunableSet := bitfield.NewFromSet(prunable)
+		var epochsToRemove []uint64
+		if err = expirationEpochs.ForEach(func(epoch abi.ChainEpoch, bf bitfield.BitField) error {
+			stale, err := bitfield.IntersectBitField(bf, prunableSet)
+			if err != nil {
+				return err
+			}
+			if stale, err = bitfield.SubtractBitField(stale, bitfield.NewFromSet(keep[epoch])); err != nil {
+				return err
+			}
+			if noneStale, err := stale.IsEmpty(); err != nil {
+				return err
+			} else if noneStale {
+				return nil
+			}
+			if bf, err = bitfield.SubtractBitField(bf, stale); err != nil {
+				return err
+			}
+			if empty, err := bf.IsEmpty(); err != nil {
+				return err
+			} else if !empty {
+				return expirationEpochs.Set(uint64(epoch), bf)
+			}
+			epochsToRemove = append(epochsToRemove, uint64(epoch))
+			return nil
+		}); err != nil {
+			return bitfield.BitField{}, false, xerrors.Errorf("failed to prune deadline expiration queue: %w", err)
+		}
+		if err = expirationEpochs.BatchDelete(epochsToRemove, true); err != nil {
+			return bitfield.BitField{}, false, xerrors.Errorf("failed to remove empty epochs from deadline expiration queue: %w", err)
+		}
+		if dl.ExpirationsEpochs, err = expirationEpochs.Root(); err != nil {
+			return bitfield.BitField{}, false, xerrors.Errorf("failed to persist deadline expiration queue: %w", err)
+		}
+	}
+
+	return removed, hasMore, nil
+}
+
 func (dl *Deadline) RecordFaults(
 	store adt.Store, sectors Sectors, ssize abi.SectorSize, quant builtin.QuantSpec,
 	faultExpirationEpoch abi.ChainEpoch, partitionSectors PartitionSectorMap,
@@ -858,6 +1000,7 @@ func (dl *Deadline) DeclareFaultsRecovered(
 		if err != nil {
 			return xc.ErrIllegalState.Wrapf("failed to update partition %d: %w", partIdx, err)
 		}
+		dl.DirtyPartitions.Set(partIdx)
 		return nil
 	}); err != nil {
 		return err
@@ -875,12 +1018,26 @@ func (dl *Deadline) DeclareFaultsRecovered(
 // ProcessDeadlineEnd processes all PoSt submissions, marking unproven sectors as
 // faulty and clearing failed recoveries. It returns the power delta, and any
 // power that should be penalized (new faults and failed recoveries).
+// Only dirty partitions are loaded, so a deadline whose dirty partitions were all proven
+// is processed without loading any partition.
 func (dl *Deadline) ProcessDeadlineEnd(store adt.Store, quant builtin.QuantSpec, faultExpirationEpoch abi.ChainEpoch) (
 	powerDelta, penalizedPower PowerPair, err error,
 ) {
 	powerDelta = NewPowerPairZero()
 	penalizedPower = NewPowerPairZero()
 
+	// Only dirty partitions may have power to prove, so only those left unproven need checking.
+	// If there are none, the partitions are not loaded at all.
+	toCheck, err := bitfield.SubtractBitField(dl.DirtyPartitions, dl.PartitionsPoSted)
+	if err != nil {
+		return powerDelta, penalizedPower, xerrors.Errorf("failed to determine unproven dirty partitions: %w", err)
+	}
+	if noneToCheck, err := toCheck.IsEmpty(); err != nil {
+		return powerDelta, penalizedPower, xerrors.Errorf("failed to check unproven dirty partitions: %w", err)
+	} else if noneToCheck {
+		return powerDelta, penalizedPower, dl.resetPoStSubmissions(store)
+	}
+
 	partitions, err := dl.PartitionsArray(store)
 	if err != nil {
 		return powerDelta, penalizedPower, xerrors.Errorf("failed to load partitions: %w", err)
@@ -888,28 +1045,24 @@ func (dl *Deadline) ProcessDeadlineEnd(store adt.Store, quant builtin.QuantSpec,
 
 	detectedAny := false
 	var rescheduledPartitions []uint64
-	for partIdx := uint64(0); partIdx < partitions.Length(); partIdx++ {
-		proven, err := dl.PartitionsPoSted.IsSet(partIdx)
-		if err != nil {
-			return powerDelta, penalizedPower, xerrors.Errorf("failed to check submission for partition %d: %w", partIdx, err)
-		}
-		if proven {
-			continue
-		}
-
+	if err = toCheck.ForEach(func(partIdx uint64) error {
 		var partition Partition
 		found, err := partitions.Get(partIdx, &partition)
 		if err != nil {
-			return powerDelta, penalizedPower, xerrors.Errorf("failed to load partition %d: %w", partIdx, err)
+			return xerrors.Errorf("failed to load partition %d: %w", partIdx, err)
 		}
 		if !found {
-			return powerDelta, penalizedPower, xerrors.Errorf("no partition %d", partIdx)
+			return xerrors.Errorf("no partition %d", partIdx)
 		}
 
+		// After this challenge window the partition has no power left to prove, either because
+		// it already had none or because it's all marked faulty below.
+		dl.DirtyPartitions.Unset(partIdx)
+
 		// If we have no recovering power/sectors, and all power is faulty, skip
 		// this. This lets us skip some work if a miner repeatedly fails to PoSt.
 		if partition.RecoveringPower.IsZero() && partition.FaultyPower.Equals(partition.LivePower) {
-			continue
+			return nil
 		}
 
 		// Ok, we actually need to process this partition. Make sure we save the partition state back.
@@ -917,7 +1070,7 @@ func (dl *Deadline) ProcessDeadlineEnd(store adt.Store, quant builtin.QuantSpec,
 
 		partPowerDelta, partPenalizedPower, partNewFaultyPower, err := partition.RecordMissedPost(store, faultExpirationEpoch, quant)
 		if err != nil {
-			return powerDelta, penalizedPower, xerrors.Errorf("failed to record missed PoSt for partition %v: %w", partIdx, err)
+			return xerrors.Errorf("failed to record missed PoSt for partition %v: %w", partIdx, err)
 		}
 
 		// We marked some sectors faulty, we need to record the new
@@ -930,13 +1083,16 @@ func (dl *Deadline) ProcessDeadlineEnd(store adt.Store, quant builtin.QuantSpec,
 		// Save new partition state.
 		err = partitions.Set(partIdx, &partition)
 		if err != nil {
-			return powerDelta, penalizedPower, xerrors.Errorf("failed to update partition %v: %w", partIdx, err)
+			return xerrors.Errorf("failed to update partition %v: %w", partIdx, err)
 		}
 
 		dl.FaultyPower = dl.FaultyPower.Add(partNewFaultyPower)
 
 		powerDelta = powerDelta.Add(partPowerDelta)
 		penalizedPower = penalizedPower.Add(partPenalizedPower)
+		return nil
+	}); err != nil {
+		return powerDelta, penalizedPower, err
 	}
 
 	// Save modified deadline state.
@@ -952,15 +1108,20 @@ func (dl *Deadline) ProcessDeadlineEnd(store adt.Store, quant builtin.QuantSpec,
 		return powerDelta, penalizedPower, xc.ErrIllegalState.Wrapf("failed to update deadline expiration queue: %w", err)
 	}
 
-	// Reset PoSt submissions, snapshot proofs.
+	return powerDelta, penalizedPower, dl.resetPoStSubmissions(store)
+}
+
+// Resets PoSt submissions at the end of the challenge window, snapshotting the partitions and proofs.
+func (dl *Deadline) resetPoStSubmissions(store adt.Store) error {
+	var err error
 	dl.PartitionsPoSted = bitfield.New()
 	dl.PartitionsSnapshot = dl.Partitions
 	dl.OptimisticPoStSubmissionsSnapshot = dl.OptimisticPoStSubmissions
 	dl.OptimisticPoStSubmissions, err = adt.StoreEmptyArray(store, DeadlineOptimisticPoStSubmissionsAmtBitwidth)
 	if err != nil {
-		return powerDelta, penalizedPower, xerrors.Errorf("failed to clear pending proofs array: %w", err)
+		return xerrors.Errorf("failed to clear pending proofs array: %w", err)
 	}
-	return powerDelta, penalizedPower, nil
+	return nil
 }
 
 type PoStResult struct {
@@ -974,6 +1135,16 @@ type PoStResult struct {
 	IgnoredSectors bitfield.BitField
 	// Bitfield of partitions that were proven.
 	Partitions bitfield.BitField
+	// The sectors of each proven partition, in the order the partitions were posted.
+	PartitionSectors []PartitionProofSectors
+}
+
+// The sectors of a single partition covered by a proof.
+type PartitionProofSectors struct {
+	// All sectors in the partition.
+	Sectors bitfield.BitField
+	// The subset of Sectors that should be ignored.
+	IgnoredSectors bitfield.BitField
 }
 
 // RecordProvenSectors processes a series of posts, recording proven partitions
@@ -1017,6 +1188,7 @@ func (dl *Deadline) RecordProvenSectors(
 
 	allSectors := make([]bitfield.BitField, 0, len(postPartitions))
 	allIgnored := make([]bitfield.BitField, 0, len(postPartitions))
+	partitionSectors := make([]PartitionProofSectors, 0, len(postPartitions))
 	newFaultyPowerTotal := NewPowerPairZero()
 	retractedRecoveryPowerTotal := NewPowerPairZero()
 	recoveredPowerTotal := NewPowerPairZero()
@@ -1075,6 +1247,14 @@ func (dl *Deadline) RecordProvenSectors(
 		allSectors = append(allSectors, partition.Sectors)
 		allIgnored = append(allIgnored, partition.Faults)
 		allIgnored = append(allIgnored, partition.Terminated)
+		ignored, err := bitfield.MergeBitFields(partition.Faults, partition.Terminated)
+		if err != nil {
+			return nil, xc.ErrIllegalState.Wrapf("failed to merge ignored sectors of partition %d: %w", post.Index, err)
+		}
+		partitionSectors = append(partitionSectors, PartitionProofSectors{
+			Sectors:        partition.Sectors,
+			IgnoredSectors: ignored,
+		})
 	}
 
 	err = dl.AddExpirationPartitions(store, faultExpiration, rescheduledPartitions, quant)
@@ -1108,6 +1288,7 @@ func (dl *Deadline) RecordProvenSectors(
 		RecoveredPower:         recoveredPowerTotal,
 		RetractedRecoveryPower: retractedRecoveryPowerTotal,
 		Partitions:             partitionIndexes,
+		PartitionSectors:       partitionSectors,
 	}, nil
 }
 
@@ -1164,8 +1345,10 @@ func (dl *Deadline) TakePoStProofs(store adt.Store, idx uint64) (partitions bitf
 // given partitions.
 type DisputeInfo struct {
 	AllSectorNos, IgnoredSectorNos bitfield.BitField
-	DisputedSectors                PartitionSectorMap
-	DisputedPower                  PowerPair
+	// The sectors of each disputed partition, in increasing partition index order.
+	PartitionSectors []PartitionProofSectors
+	DisputedSectors  PartitionSectorMap
+	DisputedPower    PowerPair
 }
 
 // LoadPartitionsForDispute
@@ -1176,6 +1359,7 @@ func (dl *Deadline) LoadPartitionsForDispute(store adt.Store, partitions bitfiel
 	}
 
 	var allSectors, allIgnored []bitfield.BitField
+	var partitionSectors []PartitionProofSectors
 	disputedSectors := make(PartitionSectorMap)
 	disputedPower := NewPowerPairZero()
 	err = partitions.ForEach(func(partIdx uint64) error {
@@ -1191,6 +1375,14 @@ func (dl *Deadline) LoadPartitionsForDispute(store adt.Store, partitions bitfiel
 		allIgnored = append(allIgnored, partitionSnapshot.Faults)
 		allIgnored = append(allIgnored, partitionSnapshot.Terminated)
 		allIgnored = append(allIgnored, partitionSnapshot.Unproven)
+		ignored, err := bitfield.MultiMerge(partitionSnapshot.Faults, partitionSnapshot.Terminated, partitionSnapshot.Unproven)
+		if err != nil {
+			return err
+		}
+		partitionSectors = append(partitionSectors, PartitionProofSectors{
+			Sectors:        partitionSnapshot.Sectors,
+			IgnoredSectors: ignored,
+		})
 
 		// Record active sectors for marking faults.
 		active, err := partitionSnapshot.ActiveSectors()
@@ -1228,11 +1420,30 @@ func (dl *Deadline) LoadPartitionsForDispute(store adt.Store, partitions bitfiel
 	return &DisputeInfo{
 		AllSectorNos:     allSectorsNos,
 		IgnoredSectorNos: allIgnoredNos,
+		PartitionSectors: partitionSectors,
 		DisputedSectors:  disputedSectors,
 		DisputedPower:    disputedPower,
 	}, nil
 }
 
+// Restricts a dispute to some of the sectors which were active in one of the disputed partitions.
+// The proof is still verified for all the disputed partitions, but only the given sectors, with the given power,
+// are penalised and marked faulty if it is invalid.
+func (di *DisputeInfo) RestrictToSectors(partIdx uint64, sectorNos bitfield.BitField, power PowerPair) error {
+	active, ok := di.DisputedSectors[partIdx]
+	if !ok {
+		return xc.ErrIllegalArgument.Wrapf("partition %d was not proven by the disputed post", partIdx)
+	}
+	if contained, err := util.BitFieldContainsAll(active, sectorNos); err != nil {
+		return xc.ErrIllegalState.Wrapf("failed to check disputed sectors: %w", err)
+	} else if !contained {
+		return xc.ErrIllegalArgument.Wrapf("disputed sectors are not all active in partition %d", partIdx)
+	}
+	di.DisputedSectors = PartitionSectorMap{partIdx: sectorNos}
+	di.DisputedPower = power
+	return nil
+}
+
 // IsLive returns true if the deadline has any live sectors or any other state that should be
 // updated at the end of the challenge window.
 func (d *Deadline) IsLive() (bool, error) {
@@ -7,6 +7,7 @@ import (
 
 	"github.com/filecoin-project/go-bitfield"
 	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -610,6 +611,12 @@ func TestDeadlines(t *testing.T) {
 		// - 6 has recovered.
 		assertBitfieldEquals(t, postResult.Sectors, 1, 2, 3, 4, 5, 6, 7, 8)
 		assertBitfieldEquals(t, postResult.IgnoredSectors, 1, 5, 7)
+		// The same sectors, split by partition.
+		require.Len(t, postResult.PartitionSectors, 2)
+		assertBitfieldEquals(t, postResult.PartitionSectors[0].Sectors, 1, 2, 3, 4)
+		assertBitfieldEquals(t, postResult.PartitionSectors[0].IgnoredSectors, 1)
+		assertBitfieldEquals(t, postResult.PartitionSectors[1].Sectors, 5, 6, 7, 8)
+		assertBitfieldEquals(t, postResult.PartitionSectors[1].IgnoredSectors, 5, 7)
 		// sector 7 is newly faulty
 		require.True(t, postResult.NewFaultyPower.Equals(sectorPower(t, 7)))
 		// we failed to recover 1 (retracted)
@@ -856,6 +863,111 @@ func TestDeadlines(t *testing.T) {
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "no such partition 4")
 	})
+
+	t.Run("skips partitions which are not dirty at deadline end", func(t *testing.T) {
+		store := &loadRecordingStore{Store: ipld.NewADTStore(context.Background())}
+		dl := emptyDeadline(t, store)
+
+		addSectors(t, store, dl, true)
+		assertBitfieldEquals(t, dl.DirtyPartitions, 0, 1, 2)
+		sectorArr := sectorsArr(t, store, allSectors)
+
+		// Prove everything: the partitions are not loaded at the deadline end.
+		_, err := dl.RecordProvenSectors(store, sectorArr, sectorSize, quantSpec, 13, []miner.PoStPartition{{Index: 0}, {Index: 1}, {Index: 2}})
+		require.NoError(t, err)
+		store.reset()
+		powerDelta, penalizedPower, err := dl.ProcessDeadlineEnd(store, quantSpec, 13)
+		require.NoError(t, err)
+		assert.True(t, powerDelta.IsZero())
+		assert.True(t, penalizedPower.IsZero())
+		assert.False(t, store.loaded[dl.Partitions])
+		assertBitfieldEquals(t, dl.DirtyPartitions, 0, 1, 2)
+
+		// Skip partition 2, which is marked faulty and no longer dirty.
+		_, err = dl.RecordProvenSectors(store, sectorArr, sectorSize, quantSpec, 13, []miner.PoStPartition{{Index: 0}, {Index: 1}})
+		require.NoError(t, err)
+		powerDelta, penalizedPower, err = dl.ProcessDeadlineEnd(store, quantSpec, 13)
+		require.NoError(t, err)
+		assert.True(t, powerDelta.Equals(sectorPower(t, 9).Neg()))
+		assert.True(t, penalizedPower.Equals(sectorPower(t, 9)))
+		assertBitfieldEquals(t, dl.DirtyPartitions, 0, 1)
+		dlState.withFaults(9).
+			withPartitions(
+				bf(1, 2, 3, 4),
+				bf(5, 6, 7, 8),
+				bf(9),
+			).assert(t, store, dl)
+
+		// Skipping the faulty partition again doesn't load the partitions.
+		_, err = dl.RecordProvenSectors(store, sectorArr, sectorSize, quantSpec, 13, []miner.PoStPartition{{Index: 0}, {Index: 1}})
+		require.NoError(t, err)
+		store.reset()
+		powerDelta, penalizedPower, err = dl.ProcessDeadlineEnd(store, quantSpec, 13)
+		require.NoError(t, err)
+		assert.True(t, powerDelta.IsZero())
+		assert.True(t, penalizedPower.IsZero())
+		assert.False(t, store.loaded[dl.Partitions])
+
+		// Declaring a recovery marks the partition dirty, so failing to prove it is penalized.
+		err = dl.DeclareFaultsRecovered(store, sectorArr, sectorSize, map[uint64]bitfield.BitField{2: bf(9)})
+		require.NoError(t, err)
+		assertBitfieldEquals(t, dl.DirtyPartitions, 0, 1, 2)
+		_, err = dl.RecordProvenSectors(store, sectorArr, sectorSize, quantSpec, 13, []miner.PoStPartition{{Index: 0}, {Index: 1}})
+		require.NoError(t, err)
+		powerDelta, penalizedPower, err = dl.ProcessDeadlineEnd(store, quantSpec, 13)
+		require.NoError(t, err)
+		assert.True(t, powerDelta.IsZero())
+		assert.True(t, penalizedPower.Equals(sectorPower(t, 9)))
+		assertBitfieldEquals(t, dl.DirtyPartitions, 0, 1)
+		dlState.withFaults(9).
+			withPartitions(
+				bf(1, 2, 3, 4),
+				bf(5, 6, 7, 8),
+				bf(9),
+			).assert(t, store, dl)
+	})
+
+	t.Run("removing partitions renumbers dirty partitions", func(t *testing.T) {
+		store := ipld.NewADTStore(context.Background())
+		dl := emptyDeadline(t, store)
+
+		addSectors(t, store, dl, true)
+		sectorArr := sectorsArr(t, store, allSectors)
+
+		// Skip partition 2, which is marked faulty and no longer dirty.
+		_, err := dl.RecordProvenSectors(store, sectorArr, sectorSize, quantSpec, 13, []miner.PoStPartition{{Index: 0}, {Index: 1}})
+		require.NoError(t, err)
+		_, _, err = dl.ProcessDeadlineEnd(store, quantSpec, 13)
+		require.NoError(t, err)
+		assertBitfieldEquals(t, dl.DirtyPartitions, 0, 1)
+
+		_, _, _, err = dl.RemovePartitions(store, bf(0), quantSpec)
+		require.NoError(t, err)
+		assertBitfieldEquals(t, dl.DirtyPartitions, 0)
+		dlState.withFaults(9).
+			withPartitions(
+				bf(5, 6, 7, 8),
+				bf(9),
+			).assert(t, store, dl)
+	})
+}
+
+// A store which records the objects loaded through it.
+type loadRecordingStore struct {
+	adt.Store
+	loaded map[cid.Cid]bool
+}
+
+func (s *loadRecordingStore) Get(ctx context.Context, c cid.Cid, out interface{}) error {
+	if s.loaded == nil {
+		s.loaded = make(map[cid.Cid]bool)
+	}
+	s.loaded[c] = true
+	return s.Store.Get(ctx, c, out)
+}
+
+func (s *loadRecordingStore) reset() {
+	s.loaded = nil
 }
 
 func emptyDeadline(t *testing.T, store adt.Store) *miner.Deadline {
@@ -76,6 +76,15 @@ func (a Actor) Exports() []interface{} {
 		24:                        a.DisputeWindowedPoSt,
 		25:                        a.PreCommitSectorBatch,
 		26:                        a.ProveCommitAggregate,
+		27:                        a.ExtendSectorExpirationBatch,
+		28:                        a.ChangeBeneficiary,
+		29:                        a.CompactTerminatedSectors,
+		30:                        a.DeclareFaultsAndRecoveries,
+		31:                        a.RecommitSector,
+		32:                        a.Deregister,
+		33:                        a.ProveCommitWithSignedDeals,
+		34:                        a.ChangeWorkerAddressWithPermissions,
+		35:                        a.DisputeWindowedPoStSectors,
 	}
 }
 
@@ -139,11 +148,11 @@ func (a Actor) Constructor(rt Runtime, params *ConstructorParams) *abi.EmptyValu
 // Control //
 /////////////
 
-// type GetControlAddressesReturn struct {
-// 	Owner        addr.Address
-// 	Worker       addr.Address
-// 	ControlAddrs []addr.Address
-// }
+//	type GetControlAddressesReturn struct {
+//		Owner        addr.Address
+//		Worker       addr.Address
+//		ControlAddrs []addr.Address
+//	}
 type GetControlAddressesReturn = miner2.GetControlAddressesReturn
 
 func (a Actor) ControlAddresses(rt Runtime, _ *abi.EmptyValue) *GetControlAddressesReturn {
@@ -158,22 +167,44 @@ func (a Actor) ControlAddresses(rt Runtime, _ *abi.EmptyValue) *GetControlAddres
 	}
 }
 
-//type ChangeWorkerAddressParams struct {
-//	NewWorker       addr.Address
-//	NewControlAddrs []addr.Address
-//}
-type ChangeWorkerAddressParams = miner0.ChangeWorkerAddressParams
+type ChangeWorkerAddressParams struct {
+	NewWorker       addr.Address
+	NewControlAddrs []addr.Address
+}
 
 // ChangeWorkerAddress will ALWAYS overwrite the existing control addresses with the control addresses passed in the params.
 // If a nil addresses slice is passed, the control addresses will be cleared.
-// A worker change will be scheduled if the worker passed in the params is different from the existing worker.
+// Each control address is permitted all methods; use ChangeWorkerAddressWithPermissions to restrict them.
+// A worker change will be scheduled if the worker passed in the params is different from the worker that will be in
+// effect after any changes already scheduled. A change is queued behind those already scheduled, taking effect no
+// earlier than them.
 func (a Actor) ChangeWorkerAddress(rt Runtime, params *ChangeWorkerAddressParams) *abi.EmptyValue {
-	checkControlAddresses(rt, params.NewControlAddrs)
+	changeWorkerAddress(rt, params.NewWorker, params.NewControlAddrs, UnrestrictedControlPermissions(len(params.NewControlAddrs)))
+	return nil
+}
+
+type ChangeWorkerAddressWithPermissionsParams struct {
+	NewWorker       addr.Address
+	NewControlAddrs []addr.Address
+	// The permissions of each new control address, in the same order.
+	NewControlPermissions []ControlPermissions
+}
+
+// As ChangeWorkerAddress, but restricts each control address to the methods of its permissions.
+// Permissions are unaffected by an owner change.
+func (a Actor) ChangeWorkerAddressWithPermissions(rt Runtime, params *ChangeWorkerAddressWithPermissionsParams) *abi.EmptyValue {
+	changeWorkerAddress(rt, params.NewWorker, params.NewControlAddrs, params.NewControlPermissions)
+	return nil
+}
+
+func changeWorkerAddress(rt Runtime, worker addr.Address, newControlAddrs []addr.Address, controlPermissions []ControlPermissions) {
+	checkControlAddresses(rt, newControlAddrs)
+	checkControlPermissions(rt, newControlAddrs, controlPermissions)
 
-	newWorker := resolveWorkerAddress(rt, params.NewWorker)
+	newWorker := resolveWorkerAddress(rt, worker)
 
 	var controlAddrs []addr.Address
-	for _, ca := range params.NewControlAddrs {
+	for _, ca := range newControlAddrs {
 		resolved := resolveControlAddress(rt, ca)
 		controlAddrs = append(controlAddrs, resolved)
 	}
@@ -187,23 +218,34 @@ func (a Actor) ChangeWorkerAddress(rt Runtime, params *ChangeWorkerAddressParams
 
 		// save the new control addresses
 		info.ControlAddresses = controlAddrs
+		info.ControlPermissions = controlPermissions
 
 		// save newWorker addr key change request
-		if newWorker != info.Worker && info.PendingWorkerKey == nil {
-			info.PendingWorkerKey = &WorkerKeyChange{
-				NewWorker:   newWorker,
-				EffectiveAt: rt.CurrEpoch() + WorkerKeyChangeDelay(),
+		lastWorker := info.Worker
+		effectiveAt := rt.CurrEpoch() + WorkerKeyChangeDelay()
+		if pending := len(info.PendingWorkerKeys); pending > 0 {
+			last := info.PendingWorkerKeys[pending-1]
+			lastWorker = last.NewWorker
+			if last.EffectiveAt > effectiveAt {
+				effectiveAt = last.EffectiveAt
 			}
 		}
+		if newWorker != lastWorker {
+			if len(info.PendingWorkerKeys) >= MaxPendingWorkerKeyChanges {
+				rt.Abortf(exitcode.ErrForbidden, "too many pending worker key changes, limit %d", MaxPendingWorkerKeyChanges)
+			}
+			info.PendingWorkerKeys = append(info.PendingWorkerKeys, WorkerKeyChange{
+				NewWorker:   newWorker,
+				EffectiveAt: effectiveAt,
+			})
+		}
 
 		err := st.SaveInfo(adt.AsStore(rt), info)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "could not save miner info")
 	})
-
-	return nil
 }
 
-// Triggers a worker address change if a change has been requested and its effective epoch has arrived.
+// Triggers any requested worker address changes whose effective epoch has arrived.
 func (a Actor) ConfirmUpdateWorkerKey(rt Runtime, params *abi.EmptyValue) *abi.EmptyValue {
 	var st State
 	rt.StateTransaction(&st, func() {
@@ -245,6 +287,10 @@ func (a Actor) ChangeOwnerAddress(rt Runtime, newAddress *addr.Address) *abi.Emp
 				rt.Abortf(exitcode.ErrIllegalArgument, "expected confirmation of %v, got %v",
 					info.PendingOwnerAddress, newAddress)
 			}
+			// A beneficiary that was the old owner follows the owner.
+			if info.Beneficiary == info.Owner {
+				info.Beneficiary = *info.PendingOwnerAddress
+			}
 			info.Owner = *info.PendingOwnerAddress
 		}
 
@@ -259,9 +305,93 @@ func (a Actor) ChangeOwnerAddress(rt Runtime, newAddress *addr.Address) *abi.Emp
 	return nil
 }
 
-//type ChangePeerIDParams struct {
-//	NewID abi.PeerID
-//}
+type ChangeBeneficiaryParams struct {
+	NewBeneficiary addr.Address
+	NewQuota       abi.TokenAmount
+	NewExpiration  abi.ChainEpoch
+}
+
+// Proposes or approves a change of the miner's beneficiary.
+// The owner proposes a new beneficiary and term, replacing any pending proposal. The change takes effect once
+// it is approved by both the current beneficiary and the nominee, each calling with the same parameters as the
+// proposal. The current beneficiary's approval is implied when the owner is the beneficiary, or when the current
+// term has nothing left to withdraw; the nominee's approval is implied when the nominee is the owner.
+func (a Actor) ChangeBeneficiary(rt Runtime, params *ChangeBeneficiaryParams) *abi.EmptyValue {
+	newBeneficiary, ok := rt.ResolveAddress(params.NewBeneficiary)
+	if !ok {
+		rt.Abortf(exitcode.ErrIllegalArgument, "unable to resolve address %v", params.NewBeneficiary)
+	}
+	if params.NewQuota.LessThan(big.Zero()) {
+		rt.Abortf(exitcode.ErrIllegalArgument, "negative beneficiary quota %v", params.NewQuota)
+	}
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		info := getMinerInfo(rt, &st)
+		caller := rt.Caller()
+		if caller == info.Owner {
+			// Propose a new beneficiary.
+			rt.ValidateImmediateCallerIs(info.Owner)
+			if newBeneficiary == info.Owner {
+				if !params.NewQuota.IsZero() || params.NewExpiration != 0 {
+					rt.Abortf(exitcode.ErrIllegalArgument, "owner as beneficiary must have zero quota and expiration")
+				}
+			} else if params.NewQuota.IsZero() {
+				rt.Abortf(exitcode.ErrIllegalArgument, "beneficiary quota must be positive")
+			} else if params.NewExpiration <= rt.CurrEpoch() {
+				rt.Abortf(exitcode.ErrIllegalArgument, "beneficiary expiration %d must be after current epoch %d", params.NewExpiration, rt.CurrEpoch())
+			}
+			available := info.BeneficiaryTerm.Available(rt.CurrEpoch())
+			info.PendingBeneficiaryTerm = &PendingBeneficiaryChange{
+				NewBeneficiary:        newBeneficiary,
+				NewQuota:              params.NewQuota,
+				NewExpiration:         params.NewExpiration,
+				ApprovedByBeneficiary: available.IsZero(),
+				ApprovedByNominee:     false,
+			}
+		} else {
+			// Approve the pending proposal.
+			if info.PendingBeneficiaryTerm == nil {
+				rt.Abortf(exitcode.ErrForbidden, "no pending beneficiary change")
+			}
+			rt.ValidateImmediateCallerIs(info.Beneficiary, info.PendingBeneficiaryTerm.NewBeneficiary)
+			pending := info.PendingBeneficiaryTerm
+			if newBeneficiary != pending.NewBeneficiary || !params.NewQuota.Equals(pending.NewQuota) || params.NewExpiration != pending.NewExpiration {
+				rt.Abortf(exitcode.ErrIllegalArgument, "expected approval of beneficiary %v quota %v expiration %d, got %v %v %d",
+					pending.NewBeneficiary, pending.NewQuota, pending.NewExpiration, newBeneficiary, params.NewQuota, params.NewExpiration)
+			}
+			// A proposal may not be approved once its term has expired.
+			if pending.NewBeneficiary != info.Owner && pending.NewExpiration <= rt.CurrEpoch() {
+				rt.Abortf(exitcode.ErrIllegalArgument, "beneficiary expiration %d must be after current epoch %d", pending.NewExpiration, rt.CurrEpoch())
+			}
+		}
+
+		pending := info.PendingBeneficiaryTerm
+		if caller == info.Beneficiary {
+			pending.ApprovedByBeneficiary = true
+		}
+		if caller == pending.NewBeneficiary {
+			pending.ApprovedByNominee = true
+		}
+		if pending.ApprovedByBeneficiary && pending.ApprovedByNominee {
+			if pending.NewBeneficiary != info.Beneficiary {
+				info.BeneficiaryTerm.UsedQuota = big.Zero()
+			}
+			info.Beneficiary = pending.NewBeneficiary
+			info.BeneficiaryTerm.Quota = pending.NewQuota
+			info.BeneficiaryTerm.Expiration = pending.NewExpiration
+			info.PendingBeneficiaryTerm = nil
+		}
+
+		err := st.SaveInfo(adt.AsStore(rt), info)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save miner info")
+	})
+	return nil
+}
+
+//	type ChangePeerIDParams struct {
+//		NewID abi.PeerID
+//	}
 type ChangePeerIDParams = miner0.ChangePeerIDParams
 
 func (a Actor) ChangePeerID(rt Runtime, params *ChangePeerIDParams) *abi.EmptyValue {
@@ -271,7 +401,7 @@ func (a Actor) ChangePeerID(rt Runtime, params *ChangePeerIDParams) *abi.EmptyVa
 	rt.StateTransaction(&st, func() {
 		info := getMinerInfo(rt, &st)
 
-		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+		validateCallerPermitted(rt, info, PermitPeerInfo)
 
 		info.PeerId = params.NewID
 		err := st.SaveInfo(adt.AsStore(rt), info)
@@ -280,9 +410,9 @@ func (a Actor) ChangePeerID(rt Runtime, params *ChangePeerIDParams) *abi.EmptyVa
 	return nil
 }
 
-//type ChangeMultiaddrsParams struct {
-//	NewMultiaddrs []abi.Multiaddrs
-//}
+//	type ChangeMultiaddrsParams struct {
+//		NewMultiaddrs []abi.Multiaddrs
+//	}
 type ChangeMultiaddrsParams = miner0.ChangeMultiaddrsParams
 
 func (a Actor) ChangeMultiaddrs(rt Runtime, params *ChangeMultiaddrsParams) *abi.EmptyValue {
@@ -292,7 +422,7 @@ func (a Actor) ChangeMultiaddrs(rt Runtime, params *ChangeMultiaddrsParams) *abi
 	rt.StateTransaction(&st, func() {
 		info := getMinerInfo(rt, &st)
 
-		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+		validateCallerPermitted(rt, info, PermitPeerInfo)
 
 		info.Multiaddrs = params.NewMultiaddrs
 		err := st.SaveInfo(adt.AsStore(rt), info)
@@ -305,30 +435,30 @@ func (a Actor) ChangeMultiaddrs(rt Runtime, params *ChangeMultiaddrsParams) *abi
 // WindowedPoSt //
 //////////////////
 
-//type PoStPartition struct {
-//	// Partitions are numbered per-deadline, from zero.
-//	Index uint64
-//	// Sectors skipped while proving that weren't already declared faulty
-//	Skipped bitfield.BitField
-//}
+//	type PoStPartition struct {
+//		// Partitions are numbered per-deadline, from zero.
+//		Index uint64
+//		// Sectors skipped while proving that weren't already declared faulty
+//		Skipped bitfield.BitField
+//	}
 type PoStPartition = miner0.PoStPartition
 
 // Information submitted by a miner to provide a Window PoSt.
-//type SubmitWindowedPoStParams struct {
-//	// The deadline index which the submission targets.
-//	Deadline uint64
-//	// The partitions being proven.
-//	Partitions []PoStPartition
-//	// Array of proofs, one per distinct registered proof type present in the sectors being proven.
-//	// In the usual case of a single proof type, this array will always have a single element (independent of number of partitions).
-//	Proofs []proof.PoStProof
-//	// The epoch at which these proofs is being committed to a particular chain.
-//	// NOTE: This field should be removed in the future. See
-//	// https://github.com/filecoin-project/specs-actors/issues/1094
-//	ChainCommitEpoch abi.ChainEpoch
-//	// The ticket randomness on the chain at the chain commit epoch.
-//	ChainCommitRand abi.Randomness
-//}
+//	type SubmitWindowedPoStParams struct {
+//		// The deadline index which the submission targets.
+//		Deadline uint64
+//		// The partitions being proven.
+//		Partitions []PoStPartition
+//		// Either a single proof of all the partitions, or one proof per partition in the same order as the partitions,
+//		// which are then verified as a batch. Per-partition proofs require the partitions in increasing index order.
+//		Proofs []proof.PoStProof
+//		// The epoch at which these proofs is being committed to a particular chain.
+//		// NOTE: This field should be removed in the future. See
+//		// https://github.com/filecoin-project/specs-actors/issues/1094
+//		ChainCommitEpoch abi.ChainEpoch
+//		// The ticket randomness on the chain at the chain commit epoch.
+//		ChainCommitRand abi.Randomness
+//	}
 type SubmitWindowedPoStParams = miner0.SubmitWindowedPoStParams
 
 // Invoked by miner's worker address to submit their fallback post
@@ -337,13 +467,35 @@ func (a Actor) SubmitWindowedPoSt(rt Runtime, params *SubmitWindowedPoStParams)
 	store := adt.AsStore(rt)
 	var st State
 
-	// Verify that the miner has passed exactly 1 proof.
-	if len(params.Proofs) != 1 {
-		rt.Abortf(exitcode.ErrIllegalArgument, "expected exactly one proof, got %d", len(params.Proofs))
+	// Verify that the miner has passed either a single proof, or one proof per partition to be verified as a batch.
+	if len(params.Proofs) == 0 {
+		rt.Abortf(exitcode.ErrIllegalArgument, "expected exactly one proof, or one per partition, got none")
+	}
+	batched := len(params.Proofs) > 1
+	if batched {
+		if rt.NetworkVersion() < BatchWindowPoStNetworkVersion {
+			rt.Abortf(exitcode.ErrIllegalArgument, "proofs of separate partitions not accepted before network version %d", BatchWindowPoStNetworkVersion)
+		}
+		if len(params.Proofs) != len(params.Partitions) {
+			rt.Abortf(exitcode.ErrIllegalArgument, "expected exactly one proof, or one per partition, got %d for %d partitions",
+				len(params.Proofs), len(params.Partitions))
+		}
+		if uint64(len(params.Proofs)) > MaxWindowPoStBatchSize() {
+			rt.Abortf(exitcode.ErrIllegalArgument, "too many proofs %d, batch limit %d", len(params.Proofs), MaxWindowPoStBatchSize())
+		}
+		// Proofs are matched with partitions by index order when disputed.
+		for i := 1; i < len(params.Partitions); i++ {
+			if params.Partitions[i].Index <= params.Partitions[i-1].Index {
+				rt.Abortf(exitcode.ErrIllegalArgument, "partitions proven separately must be in increasing order, got %d after %d",
+					params.Partitions[i].Index, params.Partitions[i-1].Index)
+			}
+		}
 	}
 
-	if !CanWindowPoStProof(params.Proofs[0].PoStProof) {
-		rt.Abortf(exitcode.ErrIllegalArgument, "proof type %d not allowed", params.Proofs[0].PoStProof)
+	for _, p := range params.Proofs {
+		if !CanWindowPoStProof(p.PoStProof) {
+			rt.Abortf(exitcode.ErrIllegalArgument, "proof type %d not allowed", p.PoStProof)
+		}
 	}
 
 	if params.Deadline >= WPoStPeriodDeadlines() {
@@ -363,15 +515,26 @@ func (a Actor) SubmitWindowedPoSt(rt Runtime, params *SubmitWindowedPoStParams)
 		maxProofSize, err := info.WindowPoStProofType.ProofSize()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to determine max window post proof size")
 
-		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+		validateCallerPermitted(rt, info, PermitWindowPoSt)
 
 		// Make sure the miner is using the correct proof type.
-		if params.Proofs[0].PoStProof != info.WindowPoStProofType {
-			rt.Abortf(exitcode.ErrIllegalArgument, "expected proof of type %d, got proof of type %d", info.WindowPoStProofType, params.Proofs[0])
+		for _, p := range params.Proofs {
+			if p.PoStProof != info.WindowPoStProofType {
+				rt.Abortf(exitcode.ErrIllegalArgument, "expected proof of type %d, got proof of type %d", info.WindowPoStProofType, p.PoStProof)
+			}
+		}
+		if batched && !CanBatchWindowPoStProof(info.WindowPoStProofType) {
+			rt.Abortf(exitcode.ErrIllegalArgument, "proof type %d cannot be verified in a batch", info.WindowPoStProofType)
 		}
 
 		// Make sure the proof size doesn't exceed the max. We could probably check for an exact match, but this is safer.
-		if maxSize := maxProofSize * uint64(len(params.Partitions)); uint64(len(params.Proofs[0].ProofBytes)) > maxSize {
+		if batched {
+			for i, p := range params.Proofs {
+				if uint64(len(p.ProofBytes)) > maxProofSize {
+					rt.Abortf(exitcode.ErrIllegalArgument, "expected proof %d to be smaller than %d bytes", i, maxProofSize)
+				}
+			}
+		} else if maxSize := maxProofSize * uint64(len(params.Partitions)); uint64(len(params.Proofs[0].ProofBytes)) > maxSize {
 			rt.Abortf(exitcode.ErrIllegalArgument, "expected proof to be smaller than %d bytes", maxSize)
 		}
 
@@ -450,6 +613,19 @@ func (a Actor) SubmitWindowedPoSt(rt Runtime, params *SubmitWindowedPoStParams)
 		if postResult.RecoveredPower.IsZero() {
 			err = deadline.RecordPoStProofs(store, postResult.Partitions, params.Proofs)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record proof for optimistic verification", params.Deadline)
+		} else if batched {
+			// otherwise, check the proofs, each against the sectors of its partition
+			partitionSectorInfos := make([][]*SectorOnChainInfo, len(postResult.PartitionSectors))
+			for i, ps := range postResult.PartitionSectors {
+				partitionSectorInfos[i], err = sectors.LoadForProof(ps.Sectors, ps.IgnoredSectors)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors for post verification")
+				if len(partitionSectorInfos[i]) == 0 {
+					rt.Abortf(exitcode.ErrIllegalArgument, "cannot separately prove partition %d with no active sectors", params.Partitions[i].Index)
+				}
+			}
+
+			err = batchVerifyWindowedPosts(rt, currDeadline.Challenge, partitionSectorInfos, params.Proofs)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "window post failed")
 		} else {
 			// otherwise, check the proof
 			sectorInfos, err := sectors.LoadForProof(postResult.Sectors, postResult.IgnoredSectors)
@@ -479,14 +655,56 @@ func (a Actor) SubmitWindowedPoSt(rt Runtime, params *SubmitWindowedPoStParams)
 	return nil
 }
 
-// type DisputeWindowedPoStParams struct {
-// 		Deadline  uint64
-// 		PoStIndex uint64 // only one is allowed at a time to avoid loading too many sector infos.
-// }
+//	type DisputeWindowedPoStParams struct {
+//			Deadline  uint64
+//			PoStIndex uint64 // only one is allowed at a time to avoid loading too many sector infos.
+//	}
 type DisputeWindowedPoStParams = miner3.DisputeWindowedPoStParams
 
 func (a Actor) DisputeWindowedPoSt(rt Runtime, params *DisputeWindowedPoStParams) *abi.EmptyValue {
 	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
+	disputeWindowedPoSt(rt, params, nil)
+	return nil
+}
+
+type DisputeWindowedPoStSectorsParams struct {
+	Deadline  uint64
+	PoStIndex uint64
+	// A partition proven by the PoSt, and the sectors in it to dispute.
+	Partition uint64
+	Sectors   bitfield.BitField
+}
+
+// Disputes a Window PoSt for a subset of the sectors within one of the partitions it proved.
+// The whole PoSt is verified, and removed from the deadline's snapshot so it can't be disputed again, as for
+// DisputeWindowedPoSt. If it is invalid, only the disputed sectors are marked faulty, and the penalty and the
+// reporter's reward are computed from their power alone.
+func (a Actor) DisputeWindowedPoStSectors(rt Runtime, params *DisputeWindowedPoStSectorsParams) *abi.EmptyValue {
+	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
+
+	sectorCount, err := params.Sectors.Count()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to count disputed sectors")
+	if sectorCount == 0 {
+		rt.Abortf(exitcode.ErrIllegalArgument, "no sectors disputed")
+	}
+	if sectorCount > AddressedSectorsMax() {
+		rt.Abortf(exitcode.ErrIllegalArgument, "too many sectors disputed %d > %d", sectorCount, AddressedSectorsMax())
+	}
+
+	disputeWindowedPoSt(rt, &DisputeWindowedPoStParams{
+		Deadline:  params.Deadline,
+		PoStIndex: params.PoStIndex,
+	}, func(sectors Sectors, sectorSize abi.SectorSize, disputeInfo *DisputeInfo) {
+		infos, err := sectors.Load(params.Sectors)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to load disputed sectors")
+		err = disputeInfo.RestrictToSectors(params.Partition, params.Sectors, PowerForSectors(sectorSize, infos))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to restrict dispute to sectors")
+	})
+	return nil
+}
+
+// Disputes a Window PoSt, first calling restrict, if non-nil, to limit the sectors penalised if it is invalid.
+func disputeWindowedPoSt(rt Runtime, params *DisputeWindowedPoStParams, restrict func(sectors Sectors, sectorSize abi.SectorSize, disputeInfo *DisputeInfo)) {
 	reporter := rt.Caller()
 
 	if params.Deadline >= WPoStPeriodDeadlines() {
@@ -544,19 +762,37 @@ func (a Actor) DisputeWindowedPoSt(rt Runtime, params *DisputeWindowedPoStParams
 			// Load the partition info we need for the dispute.
 			disputeInfo, err := dlCurrent.LoadPartitionsForDispute(store, partitions)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load partition info for dispute")
-			// This includes power that is no longer active (e.g., due to sector terminations).
-			// It must only be used for penalty calculations, not power adjustments.
-			penalisedPower = disputeInfo.DisputedPower
 
 			// Load sectors for the dispute.
 			sectors, err := LoadSectors(store, st.Sectors)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors array")
 
-			sectorInfos, err := sectors.LoadForProof(disputeInfo.AllSectorNos, disputeInfo.IgnoredSectorNos)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors to dispute window post")
+			if restrict != nil {
+				restrict(sectors, info.SectorSize, disputeInfo)
+			}
+			// This includes power that is no longer active (e.g., due to sector terminations).
+			// It must only be used for penalty calculations, not power adjustments.
+			penalisedPower = disputeInfo.DisputedPower
 
 			// Check proof, we fail if validation succeeds.
-			err = verifyWindowedPost(rt, targetDeadline.Challenge, sectorInfos, proofs)
+			// Multiple proofs were submitted one per partition, in increasing partition order.
+			if len(proofs) > 1 {
+				if len(proofs) != len(disputeInfo.PartitionSectors) {
+					rt.Abortf(exitcode.ErrIllegalState, "%d proofs recorded for %d partitions", len(proofs), len(disputeInfo.PartitionSectors))
+				}
+				partitionSectorInfos := make([][]*SectorOnChainInfo, len(disputeInfo.PartitionSectors))
+				for i, ps := range disputeInfo.PartitionSectors {
+					partitionSectorInfos[i], err = sectors.LoadForProof(ps.Sectors, ps.IgnoredSectors)
+					builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors to dispute window post")
+				}
+				err = batchVerifyWindowedPosts(rt, targetDeadline.Challenge, partitionSectorInfos, proofs)
+			} else {
+				var sectorInfos []*SectorOnChainInfo
+				sectorInfos, err = sectors.LoadForProof(disputeInfo.AllSectorNos, disputeInfo.IgnoredSectorNos)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors to dispute window post")
+
+				err = verifyWindowedPost(rt, targetDeadline.Challenge, sectorInfos, proofs)
+			}
 			if err == nil {
 				rt.Abortf(exitcode.ErrIllegalArgument, "failed to dispute valid post")
 				return
@@ -631,26 +867,25 @@ func (a Actor) DisputeWindowedPoSt(rt Runtime, params *DisputeWindowedPoStParams
 
 	err := st.CheckBalanceInvariants(rt.CurrentBalance())
 	builtin.RequireNoErr(rt, err, ErrBalanceInvariantBroken, "balance invariants broken")
-	return nil
 }
 
 ///////////////////////
 // Sector Commitment //
 ///////////////////////
 
-//type SectorPreCommitInfo struct {
-//	SealProof       abi.RegisteredSealProof
-//	SectorNumber    abi.SectorNumber
-//	SealedCID       cid.Cid `checked:"true"` // CommR
-//	SealRandEpoch   abi.ChainEpoch
-//	DealIDs         []abi.DealID
-//	Expiration      abi.ChainEpoch
-//	ReplaceCapacity bool // Whether to replace a "committed capacity" no-deal sector (requires non-empty DealIDs)
-//	// The committed capacity sector to replace, and it's deadline/partition location
-//	ReplaceSectorDeadline  uint64
-//	ReplaceSectorPartition uint64
-//	ReplaceSectorNumber    abi.SectorNumber
-//}
+//	type SectorPreCommitInfo struct {
+//		SealProof       abi.RegisteredSealProof
+//		SectorNumber    abi.SectorNumber
+//		SealedCID       cid.Cid `checked:"true"` // CommR
+//		SealRandEpoch   abi.ChainEpoch
+//		DealIDs         []abi.DealID
+//		Expiration      abi.ChainEpoch
+//		ReplaceCapacity bool // Whether to replace a "committed capacity" no-deal sector (requires non-empty DealIDs)
+//		// The committed capacity sector to replace, and it's deadline/partition location
+//		ReplaceSectorDeadline  uint64
+//		ReplaceSectorPartition uint64
+//		ReplaceSectorNumber    abi.SectorNumber
+//	}
 type PreCommitSectorParams = miner0.SectorPreCommitInfo
 
 // Pledges to seal and commit a single sector.
@@ -675,6 +910,9 @@ type PreCommitSectorBatchParams struct {
 // when proven.
 // This method calculates the sector's power, locks a pre-commit deposit for the sector, stores information about the
 // sector in state and waits for it to be proven or expire.
+// A batch of up to PreCommitSectorBatchMaxSize sectors is checked and locks a single total deposit, and the sectors'
+// expirations are queued with one insertion per clean-up epoch. Batches of more than one sector pay an aggregate
+// network fee.
 func (a Actor) PreCommitSectorBatch(rt Runtime, params *PreCommitSectorBatchParams) *abi.EmptyValue {
 	currEpoch := rt.CurrEpoch()
 	if len(params.Sectors) == 0 {
@@ -762,7 +1000,7 @@ func (a Actor) PreCommitSectorBatch(rt Runtime, params *PreCommitSectorBatchPara
 		feeToBurn = RepayDebtsOrAbort(rt, &st)
 
 		info := getMinerInfo(rt, &st)
-		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+		validateCallerPermitted(rt, info, PermitSealing)
 
 		if ConsensusFaultActive(info, currEpoch) {
 			rt.Abortf(exitcode.ErrForbidden, "pre-commit not allowed during active consensus fault")
@@ -851,9 +1089,7 @@ func (a Actor) PreCommitSectorBatch(rt Runtime, params *PreCommitSectorBatchPara
 	builtin.RequireNoErr(rt, err, ErrBalanceInvariantBroken, "balance invariants broken")
 	if needsCron {
 		newDlInfo := st.DeadlineInfo(currEpoch)
-		enrollCronEvent(rt, newDlInfo.Last(), &CronEventPayload{
-			EventType: CronEventProvingDeadline,
-		})
+		enrollProvingDeadlineCronEvent(rt, newDlInfo.Last())
 	}
 
 	return nil
@@ -870,15 +1106,15 @@ type ProveCommitAggregateParams struct {
 func (a Actor) ProveCommitAggregate(rt Runtime, params *ProveCommitAggregateParams) *abi.EmptyValue {
 	aggSectorsCount, err := params.SectorNumbers.Count()
 	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to count aggregated sectors")
-	if aggSectorsCount > MaxAggregatedSectors {
-		rt.Abortf(exitcode.ErrIllegalArgument, "too many sectors addressed, addressed %d want <= %d", aggSectorsCount, MaxAggregatedSectors)
-	} else if aggSectorsCount < MinAggregatedSectors {
-		rt.Abortf(exitcode.ErrIllegalArgument, "too few sectors addressed, addressed %d want >= %d", aggSectorsCount, MinAggregatedSectors)
+	if aggSectorsCount > MaxAggregatedSectors() {
+		rt.Abortf(exitcode.ErrIllegalArgument, "too many sectors addressed, addressed %d want <= %d", aggSectorsCount, MaxAggregatedSectors())
+	} else if aggSectorsCount < MinAggregatedSectors() {
+		rt.Abortf(exitcode.ErrIllegalArgument, "too few sectors addressed, addressed %d want >= %d", aggSectorsCount, MinAggregatedSectors())
 	}
 
-	if uint64(len(params.AggregateProof)) > MaxAggregateProofSize {
+	if uint64(len(params.AggregateProof)) > MaxAggregateProofSize() {
 		rt.Abortf(exitcode.ErrIllegalArgument, "sector prove-commit proof of size %d exceeds max size of %d",
-			len(params.AggregateProof), MaxAggregateProofSize)
+			len(params.AggregateProof), MaxAggregateProofSize())
 	}
 
 	store := adt.AsStore(rt)
@@ -886,7 +1122,7 @@ func (a Actor) ProveCommitAggregate(rt Runtime, params *ProveCommitAggregatePara
 	rt.StateReadonly(&st)
 
 	info := getMinerInfo(rt, &st)
-	rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+	validateCallerPermitted(rt, info, PermitSealing)
 
 	precommits, err := st.GetAllPrecommittedSectors(store, params.SectorNumbers)
 	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get precommits")
@@ -983,10 +1219,10 @@ func (a Actor) ProveCommitAggregate(rt Runtime, params *ProveCommitAggregatePara
 	return nil
 }
 
-//type ProveCommitSectorParams struct {
-//	SectorNumber abi.SectorNumber
-//	Proof        []byte
-//}
+//	type ProveCommitSectorParams struct {
+//		SectorNumber abi.SectorNumber
+//		Proof        []byte
+//	}
 type ProveCommitSectorParams = miner0.ProveCommitSectorParams
 
 // Checks state of the corresponding sector pre-commitment, then schedules the proof to be verified in bulk
@@ -1048,6 +1284,115 @@ func (a Actor) ProveCommitSector(rt Runtime, params *ProveCommitSectorParams) *a
 	return nil
 }
 
+type ProveCommitWithSignedDealsParams struct {
+	SectorNumber abi.SectorNumber
+	Proof        []byte
+	Deals        []market.ClientDealProposal // Client-signed proposals for the sector's deals, not yet published.
+}
+
+// Proves a sector pre-committed without deals, together with deals held as client-signed proposals rather than
+// published before pre-commitment. The market publishes and activates the deals, and the sector is activated
+// with their IDs and weights.
+// Unlike ProveCommitSector, the proof is verified immediately rather than by the power actor at the end of the
+// epoch, since the proposals aren't kept in state. The unsealed CID is computed from the proposals' pieces.
+func (a Actor) ProveCommitWithSignedDeals(rt Runtime, params *ProveCommitWithSignedDealsParams) *abi.EmptyValue {
+	if params.SectorNumber > abi.MaxSectorNumber {
+		rt.Abortf(exitcode.ErrIllegalArgument, "sector number greater than maximum")
+	}
+	if len(params.Deals) == 0 {
+		rt.Abortf(exitcode.ErrIllegalArgument, "no deals to activate")
+	}
+
+	store := adt.AsStore(rt)
+	sectorNo := params.SectorNumber
+
+	var st State
+	rt.StateReadonly(&st)
+	info := getMinerInfo(rt, &st)
+	validateCallerPermitted(rt, info, PermitSealing)
+
+	precommit, found, err := st.GetPrecommittedSector(store, sectorNo)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load pre-committed sector %v", sectorNo)
+	if !found {
+		rt.Abortf(exitcode.ErrNotFound, "no pre-committed sector %v", sectorNo)
+	}
+	if len(precommit.Info.DealIDs) > 0 {
+		rt.Abortf(exitcode.ErrIllegalArgument, "sector %d was pre-committed with deals", sectorNo)
+	}
+
+	maxProofSize, err := precommit.Info.SealProof.ProofSize()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to determine max proof size for sector %v", sectorNo)
+	if uint64(len(params.Proof)) > maxProofSize {
+		rt.Abortf(exitcode.ErrIllegalArgument, "sector prove-commit proof of size %d exceeds max size of %d",
+			len(params.Proof), maxProofSize)
+	}
+
+	msd, ok := MaxProveCommitDuration()[precommit.Info.SealProof]
+	if !ok {
+		rt.Abortf(exitcode.ErrIllegalState, "no max seal duration for proof type: %d", precommit.Info.SealProof)
+	}
+	proveCommitDue := precommit.PreCommitEpoch + msd
+	if rt.CurrEpoch() > proveCommitDue {
+		rt.Abortf(exitcode.ErrIllegalArgument, "commitment proof for %d too late at %d, due %d", sectorNo, rt.CurrEpoch(), proveCommitDue)
+	}
+	// Checked here, rather than skipping the sector on activation, since the deals can't be activated without it.
+	if duration := precommit.Info.Expiration - rt.CurrEpoch(); duration < MinSectorExpiration() {
+		rt.Abortf(exitcode.ErrIllegalArgument, "sector %d has lifetime %d less than minimum %d", sectorNo, duration, MinSectorExpiration())
+	}
+	interactiveEpoch := precommit.PreCommitEpoch + PreCommitChallengeDelay()
+	if rt.CurrEpoch() <= interactiveEpoch {
+		rt.Abortf(exitcode.ErrForbidden, "too early to prove sector")
+	}
+
+	sectorSize, err := precommit.Info.SealProof.SectorSize()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to determine sector size for sector %v", sectorNo)
+	pieces := make([]abi.PieceInfo, len(params.Deals))
+	dealSpace := uint64(0)
+	for i, deal := range params.Deals {
+		pieces[i] = abi.PieceInfo{Size: deal.Proposal.PieceSize, PieceCID: deal.Proposal.PieceCID}
+		dealSpace += uint64(deal.Proposal.PieceSize)
+	}
+	if dealSpace > uint64(sectorSize) {
+		rt.Abortf(exitcode.ErrIllegalArgument, "deals too large to fit in sector %d > %d", dealSpace, sectorSize)
+	}
+	commD, err := rt.ComputeUnsealedSectorCID(precommit.Info.SealProof, pieces)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to compute unsealed sector CID for sector %d", sectorNo)
+
+	svi := sealVerifyInfo(rt, &SealVerifyStuff{
+		SealedCID:           precommit.Info.SealedCID,
+		InteractiveEpoch:    interactiveEpoch,
+		SealRandEpoch:       precommit.Info.SealRandEpoch,
+		Proof:               params.Proof,
+		SectorNumber:        sectorNo,
+		RegisteredSealProof: precommit.Info.SealProof,
+	}, commD)
+	err = rt.VerifySeal(*svi)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "seal verify failed for sector %d", sectorNo)
+
+	var activated market.ActivateSignedDealsReturn
+	code := rt.Send(
+		builtin.StorageMarketActorAddr,
+		builtin.MethodsMarket.ActivateSignedDeals,
+		&market.ActivateSignedDealsParams{
+			Deals:        params.Deals,
+			SectorExpiry: precommit.Info.Expiration,
+		},
+		abi.NewTokenAmount(0),
+		&activated,
+	)
+	builtin.RequireSuccess(rt, code, "failed to activate deals for sector %d", sectorNo)
+
+	proven := *precommit
+	proven.Info.DealIDs = activated.IDs
+	proven.DealWeight = activated.Weights.DealWeight
+	proven.VerifiedDealWeight = activated.Weights.VerifiedDealWeight
+
+	rew := requestCurrentEpochBlockReward(rt)
+	pwr := requestCurrentTotalPower(rt)
+	activateProvenSectors(rt, []*SectorPreCommitOnChainInfo{&proven}, rew.ThisEpochBaselinePower, rew.ThisEpochRewardSmoothed, pwr.QualityAdjPowerSmoothed)
+	return nil
+}
+
 func (a Actor) ConfirmSectorProofsValid(rt Runtime, params *builtin.ConfirmSectorProofsParams) *abi.EmptyValue {
 	rt.ValidateImmediateCallerIs(builtin.StoragePowerActorAddr)
 
@@ -1075,8 +1420,6 @@ func (a Actor) ConfirmSectorProofsValid(rt Runtime, params *builtin.ConfirmSecto
 func confirmSectorProofsValid(rt Runtime, preCommits []*SectorPreCommitOnChainInfo, thisEpochBaselinePower big.Int,
 	thisEpochRewardSmoothed smoothing.FilterEstimate, qualityAdjPowerSmoothed smoothing.FilterEstimate) {
 
-	circulatingSupply := rt.TotalFilCircSupply()
-
 	// 1. Activate deals, skipping pre-commits with invalid deals.
 	//    - calls the market actor.
 	// 2. Reschedule replacement sector expiration.
@@ -1089,7 +1432,6 @@ func confirmSectorProofsValid(rt Runtime, preCommits []*SectorPreCommitOnChainIn
 	// Ideally, we'd combine some of these operations, but at least we have
 	// a constant number of them.
 
-	activation := rt.CurrEpoch()
 	// Pre-commits for new sectors.
 	var validPreCommits []*SectorPreCommitOnChainInfo
 	for _, precommit := range preCommits {
@@ -1122,6 +1464,16 @@ func confirmSectorProofsValid(rt Runtime, preCommits []*SectorPreCommitOnChainIn
 		rt.Abortf(exitcode.ErrIllegalArgument, "all prove commits failed to validate")
 	}
 
+	activateProvenSectors(rt, validPreCommits, thisEpochBaselinePower, thisEpochRewardSmoothed, qualityAdjPowerSmoothed)
+}
+
+// Adds proven sectors, whose deals have been activated, replacing their pre-commitments.
+// The sectors take the deal IDs and weights from their pre-commitments.
+func activateProvenSectors(rt Runtime, preCommits []*SectorPreCommitOnChainInfo, thisEpochBaselinePower big.Int,
+	thisEpochRewardSmoothed smoothing.FilterEstimate, qualityAdjPowerSmoothed smoothing.FilterEstimate) {
+	circulatingSupply := rt.TotalFilCircSupply()
+	activation := rt.CurrEpoch()
+
 	totalPledge := big.Zero()
 	depositToUnlock := big.Zero()
 	newSectors := make([]*SectorOnChainInfo, 0)
@@ -1131,8 +1483,8 @@ func confirmSectorProofsValid(rt Runtime, preCommits []*SectorPreCommitOnChainIn
 	rt.StateTransaction(&st, func() {
 		info := getMinerInfo(rt, &st)
 
-		newSectorNos := make([]abi.SectorNumber, 0, len(validPreCommits))
-		for _, precommit := range validPreCommits {
+		newSectorNos := make([]abi.SectorNumber, 0, len(preCommits))
+		for _, precommit := range preCommits {
 			// compute initial pledge
 			duration := precommit.Info.Expiration - activation
 			// This should have been caught in precommit, but don't let other sectors fail because of it.
@@ -1205,9 +1557,9 @@ func confirmSectorProofsValid(rt Runtime, preCommits []*SectorPreCommitOnChainIn
 	notifyPledgeChanged(rt, big.Sub(totalPledge, newlyVested))
 }
 
-//type CheckSectorProvenParams struct {
-//	SectorNumber abi.SectorNumber
-//}
+//	type CheckSectorProvenParams struct {
+//		SectorNumber abi.SectorNumber
+//	}
 type CheckSectorProvenParams = miner0.CheckSectorProvenParams
 
 func (a Actor) CheckSectorProven(rt Runtime, params *CheckSectorProvenParams) *abi.EmptyValue {
@@ -1234,17 +1586,17 @@ func (a Actor) CheckSectorProven(rt Runtime, params *CheckSectorProvenParams) *a
 // Sector Modification //
 /////////////////////////
 
-//type ExtendSectorExpirationParams struct {
-//	Extensions []ExpirationExtension
-//}
+//	type ExtendSectorExpirationParams struct {
+//		Extensions []ExpirationExtension
+//	}
 type ExtendSectorExpirationParams = miner0.ExtendSectorExpirationParams
 
-//type ExpirationExtension struct {
-//	Deadline      uint64
-//	Partition     uint64
-//	Sectors       bitfield.BitField
-//	NewExpiration abi.ChainEpoch
-//}
+//	type ExpirationExtension struct {
+//		Deadline      uint64
+//		Partition     uint64
+//		Sectors       bitfield.BitField
+//		NewExpiration abi.ChainEpoch
+//	}
 type ExpirationExtension = miner0.ExpirationExtension
 
 // Changes the expiration epoch for a sector to a new, later one.
@@ -1279,8 +1631,6 @@ func (a Actor) ExtendSectorExpiration(rt Runtime, params *ExtendSectorExpiration
 		)
 	}
 
-	currEpoch := rt.CurrEpoch()
-
 	powerDelta := NewPowerPairZero()
 	pledgeDelta := big.Zero()
 	store := adt.AsStore(rt)
@@ -1288,7 +1638,7 @@ func (a Actor) ExtendSectorExpiration(rt Runtime, params *ExtendSectorExpiration
 	rt.StateTransaction(&st, func() {
 		info := getMinerInfo(rt, &st)
 
-		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+		validateCallerPermitted(rt, info, PermitSectorManagement)
 
 		deadlines, err := st.LoadDeadlines(adt.AsStore(rt))
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
@@ -1311,140 +1661,455 @@ func (a Actor) ExtendSectorExpiration(rt Runtime, params *ExtendSectorExpiration
 		sectors, err := LoadSectors(store, st.Sectors)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors array")
 
-		for _, dlIdx := range deadlinesToLoad {
-			deadline, err := deadlines.LoadDeadline(store, dlIdx)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", dlIdx)
+		powerDelta, pledgeDelta = extendSectorExpirations(rt, store, &st, info, deadlines, sectors, deadlinesToLoad, declsByDeadline)
 
-			partitions, err := deadline.PartitionsArray(store)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load partitions for deadline %d", dlIdx)
+		st.Sectors, err = sectors.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save sectors")
 
-			quant := st.QuantSpecForDeadline(dlIdx)
+		err = st.SaveDeadlines(store, deadlines)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
+	})
+
+	requestUpdatePower(rt, powerDelta)
+	// Note: the pledge delta is expected to be zero, since pledge is not re-calculated for the extension.
+	// But in case that ever changes, we can do the right thing here.
+	notifyPledgeChanged(rt, pledgeDelta)
+	return nil
+}
+
+type ExtendSectorExpirationBatchParams struct {
+	Groups []ExpirationExtensionGroup
+}
+
+// A group of sectors in one deadline to be extended to the same new expiration.
+type ExpirationExtensionGroup struct {
+	Deadline uint64
+	// Partitions in the deadline that hold the sectors.
+	Partitions bitfield.BitField
+	// Sectors to extend, each of which must be in one of the partitions.
+	Sectors       bitfield.BitField
+	NewExpiration abi.ChainEpoch
+}
+
+// Changes the expiration epochs of many sectors, as ExtendSectorExpiration.
+// Sectors are declared in groups by deadline and new expiration, with a single sectors bitfield spanning all
+// the group's partitions, rather than one declaration per partition.
+// Every listed partition must hold at least one of the group's sectors, and every sector must be found in one
+// of the listed partitions.
+func (a Actor) ExtendSectorExpirationBatch(rt Runtime, params *ExtendSectorExpirationBatchParams) *abi.EmptyValue {
+	if uint64(len(params.Groups)) > DeclarationsMax {
+		rt.Abortf(exitcode.ErrIllegalArgument, "too many groups %d, max %d", len(params.Groups), DeclarationsMax)
+	}
+
+	var partitionCount, sectorCount uint64
+	for i, group := range params.Groups {
+		if group.Deadline >= WPoStPeriodDeadlines() {
+			rt.Abortf(exitcode.ErrIllegalArgument, "deadline %d not in range 0..%d", group.Deadline, WPoStPeriodDeadlines())
+		}
+		count, err := group.Partitions.Count()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to count partitions for group %d", i)
+		if partitionCount > math.MaxUint64-count {
+			rt.Abortf(exitcode.ErrIllegalArgument, "partition bitfield integer overflow")
+		}
+		partitionCount += count
+
+		count, err = group.Sectors.Count()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to count sectors for group %d", i)
+		if sectorCount > math.MaxUint64-count {
+			rt.Abortf(exitcode.ErrIllegalArgument, "sector bitfield integer overflow")
+		}
+		sectorCount += count
+	}
+	if partitionCount > AddressedPartitionsMax {
+		rt.Abortf(exitcode.ErrIllegalArgument, "too many partitions %d, max %d", partitionCount, AddressedPartitionsMax)
+	}
+	if sectorCount > AddressedSectorsMax() {
+		rt.Abortf(exitcode.ErrIllegalArgument, "too many sectors %d, max %d", sectorCount, AddressedSectorsMax())
+	}
+
+	var powerDelta PowerPair
+	var pledgeDelta abi.TokenAmount
+	store := adt.AsStore(rt)
+	var st State
+	rt.StateTransaction(&st, func() {
+		info := getMinerInfo(rt, &st)
+
+		validateCallerPermitted(rt, info, PermitSectorManagement)
+
+		deadlines, err := st.LoadDeadlines(store)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
+
+		// Split each group into per-partition declarations, grouped by deadline in iteration order.
+		declsByDeadline := map[uint64][]*ExpirationExtension{}
+		var deadlinesToLoad []uint64
+		for i, group := range params.Groups {
+			deadline, err := deadlines.LoadDeadline(store, group.Deadline)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", group.Deadline)
+
+			partitions, err := deadline.PartitionsArray(store)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load partitions for deadline %d", group.Deadline)
 
-			// Group modified partitions by epoch to which they are extended. Duplicates are ok.
-			partitionsByNewEpoch := map[abi.ChainEpoch][]uint64{}
-			// Remember iteration order of epochs.
-			var epochsToReschedule []abi.ChainEpoch
+			if _, ok := declsByDeadline[group.Deadline]; !ok {
+				deadlinesToLoad = append(deadlinesToLoad, group.Deadline)
+			}
 
-			for _, decl := range declsByDeadline[dlIdx] {
+			foundSectors := bitfield.New()
+			err = group.Partitions.ForEach(func(partIdx uint64) error {
 				var partition Partition
-				found, err := partitions.Get(decl.Partition, &partition)
-				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %v partition %v", dlIdx, decl.Partition)
+				found, err := partitions.Get(partIdx, &partition)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %v partition %v", group.Deadline, partIdx)
 				if !found {
-					rt.Abortf(exitcode.ErrNotFound, "no such deadline %v partition %v", dlIdx, decl.Partition)
+					rt.Abortf(exitcode.ErrNotFound, "no such deadline %v partition %v", group.Deadline, partIdx)
 				}
 
-				oldSectors, err := sectors.Load(decl.Sectors)
-				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors in deadline %v partition %v", dlIdx, decl.Partition)
-				newSectors := make([]*SectorOnChainInfo, len(oldSectors))
-				for i, sector := range oldSectors {
-					if !CanExtendSealProofType(sector.SealProof) {
-						rt.Abortf(exitcode.ErrForbidden, "cannot extend expiration for sector %v with unsupported seal type %v",
-							sector.SectorNumber, sector.SealProof)
-					}
-					// This can happen if the sector should have already expired, but hasn't
-					// because the end of its deadline hasn't passed yet.
-					if sector.Expiration < currEpoch {
-						rt.Abortf(exitcode.ErrForbidden, "cannot extend expiration for expired sector %v, expired at %d, now %d",
-							sector.SectorNumber,
-							sector.Expiration,
-							currEpoch,
-						)
-					}
-					if decl.NewExpiration < sector.Expiration {
-						rt.Abortf(exitcode.ErrIllegalArgument, "cannot reduce sector %v's expiration to %d from %d",
-							sector.SectorNumber, decl.NewExpiration, sector.Expiration)
-					}
-					validateExpiration(rt, sector.Activation, decl.NewExpiration, sector.SealProof)
+				partitionSectors, err := bitfield.IntersectBitField(group.Sectors, partition.Sectors)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to intersect sectors with deadline %v partition %v", group.Deadline, partIdx)
+				if empty, err := partitionSectors.IsEmpty(); err != nil {
+					return err
+				} else if empty {
+					rt.Abortf(exitcode.ErrIllegalArgument, "group %d declares no sectors in deadline %v partition %v", i, group.Deadline, partIdx)
+				}
 
-					// Remove "spent" deal weights
-					newDealWeight := big.Div(
-						big.Mul(sector.DealWeight, big.NewInt(int64(sector.Expiration-currEpoch))),
-						big.NewInt(int64(sector.Expiration-sector.Activation)),
-					)
-					newVerifiedDealWeight := big.Div(
-						big.Mul(sector.VerifiedDealWeight, big.NewInt(int64(sector.Expiration-currEpoch))),
-						big.NewInt(int64(sector.Expiration-sector.Activation)),
-					)
+				foundSectors, err = bitfield.MergeBitFields(foundSectors, partitionSectors)
+				if err != nil {
+					return err
+				}
+				declsByDeadline[group.Deadline] = append(declsByDeadline[group.Deadline], &ExpirationExtension{
+					Deadline:      group.Deadline,
+					Partition:     partIdx,
+					Sectors:       partitionSectors,
+					NewExpiration: group.NewExpiration,
+				})
+				return nil
+			})
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to split group %d by partition", i)
+
+			missing, err := bitfield.SubtractBitField(group.Sectors, foundSectors)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to check sectors for group %d", i)
+			if empty, err := missing.IsEmpty(); err != nil {
+				rt.Abortf(exitcode.ErrIllegalArgument, "failed to check sectors for group %d: %v", i, err)
+			} else if !empty {
+				rt.Abortf(exitcode.ErrNotFound, "group %d declares sectors not in its partitions of deadline %d", i, group.Deadline)
+			}
+		}
 
-					newSector := *sector
-					newSector.Expiration = decl.NewExpiration
-					newSector.DealWeight = newDealWeight
-					newSector.VerifiedDealWeight = newVerifiedDealWeight
+		sectors, err := LoadSectors(store, st.Sectors)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors array")
 
-					newSectors[i] = &newSector
-				}
+		powerDelta, pledgeDelta = extendSectorExpirations(rt, store, &st, info, deadlines, sectors, deadlinesToLoad, declsByDeadline)
 
-				// Overwrite sector infos.
-				err = sectors.Store(newSectors...)
-				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update sectors %v", decl.Sectors)
+		st.Sectors, err = sectors.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save sectors")
 
-				// Remove old sectors from partition and assign new sectors.
-				partitionPowerDelta, partitionPledgeDelta, err := partition.ReplaceSectors(store, oldSectors, newSectors, info.SectorSize, quant)
-				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to replace sector expirations at deadline %v partition %v", dlIdx, decl.Partition)
+		err = st.SaveDeadlines(store, deadlines)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
+	})
 
-				powerDelta = powerDelta.Add(partitionPowerDelta)
-				pledgeDelta = big.Add(pledgeDelta, partitionPledgeDelta) // expected to be zero, see note below.
+	requestUpdatePower(rt, powerDelta)
+	// As for ExtendSectorExpiration, the pledge delta is expected to be zero.
+	notifyPledgeChanged(rt, pledgeDelta)
+	return nil
+}
 
-				err = partitions.Set(decl.Partition, &partition)
-				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadline %v partition %v", dlIdx, decl.Partition)
+// Extends the expiration of the declared sectors, visiting deadlines in the order given.
+// The modified sectors and deadlines are stored, but the caller must save their roots to state.
+// Returns the resulting power and pledge deltas.
+func extendSectorExpirations(rt Runtime, store adt.Store, st *State, info *MinerInfo, deadlines *Deadlines, sectors Sectors,
+	deadlinesToLoad []uint64, declsByDeadline map[uint64][]*ExpirationExtension) (PowerPair, abi.TokenAmount) {
+	currEpoch := rt.CurrEpoch()
+	powerDelta := NewPowerPairZero()
+	pledgeDelta := big.Zero()
+	for _, dlIdx := range deadlinesToLoad {
+		deadline, err := deadlines.LoadDeadline(store, dlIdx)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", dlIdx)
+
+		partitions, err := deadline.PartitionsArray(store)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load partitions for deadline %d", dlIdx)
+
+		quant := st.QuantSpecForDeadline(dlIdx)
+
+		// Group modified partitions by epoch to which they are extended. Duplicates are ok.
+		partitionsByNewEpoch := map[abi.ChainEpoch][]uint64{}
+		// Remember iteration order of epochs.
+		var epochsToReschedule []abi.ChainEpoch
+
+		for _, decl := range declsByDeadline[dlIdx] {
+			var partition Partition
+			found, err := partitions.Get(decl.Partition, &partition)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %v partition %v", dlIdx, decl.Partition)
+			if !found {
+				rt.Abortf(exitcode.ErrNotFound, "no such deadline %v partition %v", dlIdx, decl.Partition)
+			}
 
-				// Record the new partition expiration epoch for setting outside this loop over declarations.
-				prevEpochPartitions, ok := partitionsByNewEpoch[decl.NewExpiration]
-				partitionsByNewEpoch[decl.NewExpiration] = append(prevEpochPartitions, decl.Partition)
-				if !ok {
-					epochsToReschedule = append(epochsToReschedule, decl.NewExpiration)
+			oldSectors, err := sectors.Load(decl.Sectors)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors in deadline %v partition %v", dlIdx, decl.Partition)
+			newSectors := make([]*SectorOnChainInfo, len(oldSectors))
+			for i, sector := range oldSectors {
+				if !CanExtendSealProofType(sector.SealProof) {
+					rt.Abortf(exitcode.ErrForbidden, "cannot extend expiration for sector %v with unsupported seal type %v",
+						sector.SectorNumber, sector.SealProof)
 				}
+				// This can happen if the sector should have already expired, but hasn't
+				// because the end of its deadline hasn't passed yet.
+				if sector.Expiration < currEpoch {
+					rt.Abortf(exitcode.ErrForbidden, "cannot extend expiration for expired sector %v, expired at %d, now %d",
+						sector.SectorNumber,
+						sector.Expiration,
+						currEpoch,
+					)
+				}
+				if decl.NewExpiration < sector.Expiration {
+					rt.Abortf(exitcode.ErrIllegalArgument, "cannot reduce sector %v's expiration to %d from %d",
+						sector.SectorNumber, decl.NewExpiration, sector.Expiration)
+				}
+				validateExpiration(rt, sector.Activation, decl.NewExpiration, sector.SealProof)
+
+				// Remove "spent" deal weights
+				newDealWeight := big.Div(
+					big.Mul(sector.DealWeight, big.NewInt(int64(sector.Expiration-currEpoch))),
+					big.NewInt(int64(sector.Expiration-sector.Activation)),
+				)
+				newVerifiedDealWeight := big.Div(
+					big.Mul(sector.VerifiedDealWeight, big.NewInt(int64(sector.Expiration-currEpoch))),
+					big.NewInt(int64(sector.Expiration-sector.Activation)),
+				)
+
+				newSector := *sector
+				newSector.Expiration = decl.NewExpiration
+				newSector.DealWeight = newDealWeight
+				newSector.VerifiedDealWeight = newVerifiedDealWeight
+
+				newSectors[i] = &newSector
 			}
 
-			deadline.Partitions, err = partitions.Root()
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save partitions for deadline %d", dlIdx)
+			// Overwrite sector infos.
+			err = sectors.Store(newSectors...)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update sectors %v", decl.Sectors)
+
+			// Remove old sectors from partition and assign new sectors.
+			partitionPowerDelta, partitionPledgeDelta, err := partition.ReplaceSectors(store, oldSectors, newSectors, info.SectorSize, quant)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to replace sector expirations at deadline %v partition %v", dlIdx, decl.Partition)
+
+			powerDelta = powerDelta.Add(partitionPowerDelta)
+			pledgeDelta = big.Add(pledgeDelta, partitionPledgeDelta) // expected to be zero, see note below.
+
+			err = partitions.Set(decl.Partition, &partition)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadline %v partition %v", dlIdx, decl.Partition)
+
+			// Record the new partition expiration epoch for setting outside this loop over declarations.
+			prevEpochPartitions, ok := partitionsByNewEpoch[decl.NewExpiration]
+			partitionsByNewEpoch[decl.NewExpiration] = append(prevEpochPartitions, decl.Partition)
+			if !ok {
+				epochsToReschedule = append(epochsToReschedule, decl.NewExpiration)
+			}
+		}
+
+		deadline.Partitions, err = partitions.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save partitions for deadline %d", dlIdx)
+
+		// Record partitions in deadline expiration queue
+		for _, epoch := range epochsToReschedule {
+			pIdxs := partitionsByNewEpoch[epoch]
+			err := deadline.AddExpirationPartitions(store, epoch, pIdxs, quant)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add expiration partitions to deadline %v epoch %v: %v",
+				dlIdx, epoch, pIdxs)
+		}
+
+		err = deadlines.UpdateDeadline(store, dlIdx, deadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadline %d", dlIdx)
+	}
+
+	return powerDelta, pledgeDelta
+}
+
+type RecommitSectorParams struct {
+	SectorNumber abi.SectorNumber
+	// The deadline and partition to which the sector is assigned.
+	Deadline  uint64
+	Partition uint64
+	// The epoch of the ticket with which the sector was sealed.
+	SealRandEpoch abi.ChainEpoch
+	// The epoch of the randomness from which the proof's challenges were derived.
+	InteractiveEpoch abi.ChainEpoch
+	NewExpiration    abi.ChainEpoch
+	// A proof of replication of the sector's existing sealed data, for fresh challenges.
+	Proof []byte
+}
+
+// Re-commits an active sector with a fresh proof of its existing replica, renewing the validity of its proof
+// without sealing it again.
+// The sector is treated as activating at the current epoch, so its maximum lifetime runs from now, and its
+// expiration may be set up to the maximum commitment for its seal proof from now.
+// The sector's power, expected rewards and initial pledge are recomputed for current network conditions, but its
+// pledge is never reduced. Its prior age and reward rate are retained for the termination fee, as for a replaced sector.
+// The proof must be over challenges drawn from randomness no older than ChainFinality. The market actor must still
+// hold the proposals of any deals in the sector, from which its unsealed CID is computed.
+func (a Actor) RecommitSector(rt Runtime, params *RecommitSectorParams) *abi.EmptyValue {
+	currEpoch := rt.CurrEpoch()
+	if params.Deadline >= WPoStPeriodDeadlines() {
+		rt.Abortf(exitcode.ErrIllegalArgument, "deadline %d not in range 0..%d", params.Deadline, WPoStPeriodDeadlines())
+	}
+	if params.InteractiveEpoch < currEpoch-ChainFinality() {
+		rt.Abortf(exitcode.ErrIllegalArgument, "interactive epoch %d is more than %d before current epoch %d",
+			params.InteractiveEpoch, ChainFinality(), currEpoch)
+	}
+
+	store := adt.AsStore(rt)
+	var st State
+	rt.StateReadonly(&st)
+	info := getMinerInfo(rt, &st)
+	validateCallerPermitted(rt, info, PermitSealing)
+
+	sector, found, err := st.GetSector(store, params.SectorNumber)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sector %v", params.SectorNumber)
+	if !found {
+		rt.Abortf(exitcode.ErrNotFound, "no such sector %v", params.SectorNumber)
+	}
+	if !CanExtendSealProofType(sector.SealProof) {
+		rt.Abortf(exitcode.ErrForbidden, "cannot re-commit sector %v with unsupported seal type %v", sector.SectorNumber, sector.SealProof)
+	}
+	maxProofSize, err := sector.SealProof.ProofSize()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to determine max proof size for sector %v", sector.SectorNumber)
+	if uint64(len(params.Proof)) > maxProofSize {
+		rt.Abortf(exitcode.ErrIllegalArgument, "sector re-commit proof of size %d exceeds max size of %d", len(params.Proof), maxProofSize)
+	}
+
+	svi := getVerifyInfo(rt, &SealVerifyStuff{
+		SealedCID:           sector.SealedCID,
+		InteractiveEpoch:    params.InteractiveEpoch,
+		RegisteredSealProof: sector.SealProof,
+		Proof:               params.Proof,
+		DealIDs:             sector.DealIDs,
+		SectorNumber:        sector.SectorNumber,
+		SealRandEpoch:       params.SealRandEpoch,
+	})
+	err = rt.VerifySeal(*svi)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid re-commit proof for sector %v", sector.SectorNumber)
+
+	rew := requestCurrentEpochBlockReward(rt)
+	pwr := requestCurrentTotalPower(rt)
+	circulatingSupply := rt.TotalFilCircSupply()
+
+	powerDelta := NewPowerPairZero()
+	pledgeDelta := big.Zero()
+	rt.StateTransaction(&st, func() {
+		info := getMinerInfo(rt, &st)
 
-			// Record partitions in deadline expiration queue
-			for _, epoch := range epochsToReschedule {
-				pIdxs := partitionsByNewEpoch[epoch]
-				err := deadline.AddExpirationPartitions(store, epoch, pIdxs, quant)
-				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add expiration partitions to deadline %v epoch %v: %v",
-					dlIdx, epoch, pIdxs)
-			}
+		sectors, err := LoadSectors(store, st.Sectors)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors array")
+		sector, err := sectors.MustGet(params.SectorNumber)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sector %v", params.SectorNumber)
 
-			err = deadlines.UpdateDeadline(store, dlIdx, deadline)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadline %d", dlIdx)
+		// This can happen if the sector should have already expired, but hasn't
+		// because the end of its deadline hasn't passed yet.
+		if sector.Expiration < currEpoch {
+			rt.Abortf(exitcode.ErrForbidden, "cannot re-commit expired sector %v, expired at %d, now %d",
+				sector.SectorNumber, sector.Expiration, currEpoch)
+		}
+		if params.NewExpiration < sector.Expiration {
+			rt.Abortf(exitcode.ErrIllegalArgument, "cannot reduce sector %v's expiration to %d from %d",
+				sector.SectorNumber, params.NewExpiration, sector.Expiration)
 		}
+		validateExpiration(rt, currEpoch, params.NewExpiration, sector.SealProof)
 
+		deadlines, err := st.LoadDeadlines(store)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
+		deadline, err := deadlines.LoadDeadline(store, params.Deadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", params.Deadline)
+		partitions, err := deadline.PartitionsArray(store)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load partitions for deadline %d", params.Deadline)
+		var partition Partition
+		found, err := partitions.Get(params.Partition, &partition)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %v partition %v", params.Deadline, params.Partition)
+		if !found {
+			rt.Abortf(exitcode.ErrNotFound, "no such deadline %v partition %v", params.Deadline, params.Partition)
+		}
+		err = validatePartitionContainsSectors(&partition, bitfield.NewFromSet([]uint64{uint64(sector.SectorNumber)}))
+		builtin.RequireNoErr(rt, err, exitcode.ErrNotFound, "sector %v not in deadline %v partition %v",
+			sector.SectorNumber, params.Deadline, params.Partition)
+
+		// Remove "spent" deal weights, as for an extension, leaving the weight of the remaining deal space-time.
+		newDealWeight := big.Div(
+			big.Mul(sector.DealWeight, big.NewInt(int64(sector.Expiration-currEpoch))),
+			big.NewInt(int64(sector.Expiration-sector.Activation)),
+		)
+		newVerifiedDealWeight := big.Div(
+			big.Mul(sector.VerifiedDealWeight, big.NewInt(int64(sector.Expiration-currEpoch))),
+			big.NewInt(int64(sector.Expiration-sector.Activation)),
+		)
+		qaPower := QAPowerForWeight(info.SectorSize, params.NewExpiration-currEpoch, newDealWeight, newVerifiedDealWeight)
+		initialPledge := InitialPledgeForPower(qaPower, rew.ThisEpochBaselinePower, rew.ThisEpochRewardSmoothed,
+			pwr.QualityAdjPowerSmoothed, circulatingSupply)
+		// Re-commitment never releases pledge.
+		initialPledge = big.Max(initialPledge, sector.InitialPledge)
+
+		newSector := *sector
+		newSector.Activation = currEpoch
+		newSector.Expiration = params.NewExpiration
+		newSector.DealWeight = newDealWeight
+		newSector.VerifiedDealWeight = newVerifiedDealWeight
+		newSector.InitialPledge = initialPledge
+		newSector.ExpectedDayReward = ExpectedRewardForPower(rew.ThisEpochRewardSmoothed, pwr.QualityAdjPowerSmoothed, qaPower, builtin.EpochsInDay())
+		newSector.ExpectedStoragePledge = ExpectedRewardForPower(rew.ThisEpochRewardSmoothed, pwr.QualityAdjPowerSmoothed, qaPower, InitialPledgeProjectionPeriod())
+		newSector.ReplacedSectorAge = currEpoch - sector.Activation
+		newSector.ReplacedDayReward = sector.ExpectedDayReward
+
+		err = sectors.Store(&newSector)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update sector %v", sector.SectorNumber)
 		st.Sectors, err = sectors.Root()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save sectors")
 
+		quant := st.QuantSpecForDeadline(params.Deadline)
+		powerDelta, pledgeDelta, err = partition.ReplaceSectors(store, []*SectorOnChainInfo{sector}, []*SectorOnChainInfo{&newSector}, info.SectorSize, quant)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to replace sector %v in deadline %v partition %v",
+			sector.SectorNumber, params.Deadline, params.Partition)
+		err = partitions.Set(params.Partition, &partition)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadline %v partition %v", params.Deadline, params.Partition)
+		deadline.Partitions, err = partitions.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save partitions for deadline %d", params.Deadline)
+		err = deadline.AddExpirationPartitions(store, params.NewExpiration, []uint64{params.Partition}, quant)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add expiration partition to deadline %v epoch %v", params.Deadline, params.NewExpiration)
+		err = deadlines.UpdateDeadline(store, params.Deadline, deadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadline %d", params.Deadline)
 		err = st.SaveDeadlines(store, deadlines)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
+
+		unlockedBalance, err := st.GetUnlockedBalance(rt.CurrentBalance())
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to calculate unlocked balance")
+		if unlockedBalance.LessThan(pledgeDelta) {
+			rt.Abortf(exitcode.ErrInsufficientFunds, "insufficient funds for re-commit initial pledge increase %s, available: %s", pledgeDelta, unlockedBalance)
+		}
+		err = st.AddInitialPledge(pledgeDelta)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add initial pledge %v", pledgeDelta)
+		err = st.CheckBalanceInvariants(rt.CurrentBalance())
+		builtin.RequireNoErr(rt, err, ErrBalanceInvariantBroken, "balance invariants broken")
 	})
 
 	requestUpdatePower(rt, powerDelta)
-	// Note: the pledge delta is expected to be zero, since pledge is not re-calculated for the extension.
-	// But in case that ever changes, we can do the right thing here.
 	notifyPledgeChanged(rt, pledgeDelta)
 	return nil
 }
 
-//type TerminateSectorsParams struct {
-//	Terminations []TerminationDeclaration
-//}
+//	type TerminateSectorsParams struct {
+//		Terminations []TerminationDeclaration
+//	}
 type TerminateSectorsParams = miner0.TerminateSectorsParams
 
-//type TerminationDeclaration struct {
-//	Deadline  uint64
-//	Partition uint64
-//	Sectors   bitfield.BitField
-//}
+//	type TerminationDeclaration struct {
+//		Deadline  uint64
+//		Partition uint64
+//		Sectors   bitfield.BitField
+//	}
 type TerminationDeclaration = miner0.TerminationDeclaration
 
-//type TerminateSectorsReturn struct {
-//	// Set to true if all early termination work has been completed. When
-//	// false, the miner may choose to repeatedly invoke TerminateSectors
-//	// with no new sectors to process the remainder of the pending
-//	// terminations. While pending terminations are outstanding, the miner
-//	// will not be able to withdraw funds.
-//	Done bool
-//}
+//	type TerminateSectorsReturn struct {
+//		// Set to true if all early termination work has been completed. When
+//		// false, the miner may choose to repeatedly invoke TerminateSectors
+//		// with no new sectors to process the remainder of the pending
+//		// terminations. While pending terminations are outstanding, the miner
+//		// will not be able to withdraw funds.
+//		Done bool
+//	}
 type TerminateSectorsReturn = miner0.TerminateSectorsReturn
 
 // Marks some sectors as terminated at the present epoch, earlier than their
@@ -1494,7 +2159,7 @@ func (a Actor) TerminateSectors(rt Runtime, params *TerminateSectorsParams) *Ter
 		hadEarlyTerminations = havePendingEarlyTerminations(rt, &st)
 
 		info := getMinerInfo(rt, &st)
-		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+		validateCallerPermitted(rt, info, PermitSectorManagement)
 
 		deadlines, err := st.LoadDeadlines(adt.AsStore(rt))
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
@@ -1516,10 +2181,12 @@ func (a Actor) TerminateSectors(rt Runtime, params *TerminateSectorsParams) *Ter
 			deadline, err := deadlines.LoadDeadline(store, dlIdx)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", dlIdx)
 
+			liveBefore := deadline.LiveSectors
 			removedPower, err := deadline.TerminateSectors(store, sectors, currEpoch, partitionSectors, info.SectorSize, quant)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to terminate sectors in deadline %d", dlIdx)
 
 			st.EarlyTerminations.Set(dlIdx)
+			st.Stats.TerminatedEarly += liveBefore - deadline.LiveSectors
 
 			powerDelta = powerDelta.Sub(removedPower)
 
@@ -1560,19 +2227,19 @@ func (a Actor) TerminateSectors(rt Runtime, params *TerminateSectorsParams) *Ter
 // Faults //
 ////////////
 
-//type DeclareFaultsParams struct {
-//	Faults []FaultDeclaration
-//}
+//	type DeclareFaultsParams struct {
+//		Faults []FaultDeclaration
+//	}
 type DeclareFaultsParams = miner0.DeclareFaultsParams
 
-//type FaultDeclaration struct {
-//	// The deadline to which the faulty sectors are assigned, in range [0..WPoStPeriodDeadlines)
-//	Deadline uint64
-//	// Partition index within the deadline containing the faulty sectors.
-//	Partition uint64
-//	// Sectors in the partition being declared faulty.
-//	Sectors bitfield.BitField
-//}
+//	type FaultDeclaration struct {
+//		// The deadline to which the faulty sectors are assigned, in range [0..WPoStPeriodDeadlines)
+//		Deadline uint64
+//		// Partition index within the deadline containing the faulty sectors.
+//		Partition uint64
+//		// Sectors in the partition being declared faulty.
+//		Sectors bitfield.BitField
+//	}
 type FaultDeclaration = miner0.FaultDeclaration
 
 func (a Actor) DeclareFaults(rt Runtime, params *DeclareFaultsParams) *abi.EmptyValue {
@@ -1598,7 +2265,7 @@ func (a Actor) DeclareFaults(rt Runtime, params *DeclareFaultsParams) *abi.Empty
 	powerDelta := NewPowerPairZero()
 	rt.StateTransaction(&st, func() {
 		info := getMinerInfo(rt, &st)
-		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+		validateCallerPermitted(rt, info, PermitWindowPoSt)
 
 		deadlines, err := st.LoadDeadlines(store)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
@@ -1606,28 +2273,7 @@ func (a Actor) DeclareFaults(rt Runtime, params *DeclareFaultsParams) *abi.Empty
 		sectors, err := LoadSectors(store, st.Sectors)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors array")
 
-		currEpoch := rt.CurrEpoch()
-		err = toProcess.ForEach(func(dlIdx uint64, pm PartitionSectorMap) error {
-			targetDeadline, err := declarationDeadlineInfo(st.CurrentProvingPeriodStart(currEpoch), dlIdx, currEpoch)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid fault declaration deadline %d", dlIdx)
-
-			err = validateFRDeclarationDeadline(targetDeadline)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed fault declaration at deadline %d", dlIdx)
-
-			deadline, err := deadlines.LoadDeadline(store, dlIdx)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", dlIdx)
-
-			faultExpirationEpoch := targetDeadline.Last() + FaultMaxAge()
-			deadlinePowerDelta, err := deadline.RecordFaults(store, sectors, info.SectorSize, QuantSpecForDeadline(targetDeadline), faultExpirationEpoch, pm)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to declare faults for deadline %d", dlIdx)
-
-			err = deadlines.UpdateDeadline(store, dlIdx, deadline)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to store deadline %d partitions", dlIdx)
-
-			powerDelta = powerDelta.Add(deadlinePowerDelta)
-			return nil
-		})
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to iterate deadlines")
+		powerDelta = recordDeclaredFaults(rt, &st, info, deadlines, sectors, toProcess)
 
 		err = st.SaveDeadlines(store, deadlines)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
@@ -1643,19 +2289,19 @@ func (a Actor) DeclareFaults(rt Runtime, params *DeclareFaultsParams) *abi.Empty
 	return nil
 }
 
-//type DeclareFaultsRecoveredParams struct {
-//	Recoveries []RecoveryDeclaration
-//}
+//	type DeclareFaultsRecoveredParams struct {
+//		Recoveries []RecoveryDeclaration
+//	}
 type DeclareFaultsRecoveredParams = miner0.DeclareFaultsRecoveredParams
 
-//type RecoveryDeclaration struct {
-//	// The deadline to which the recovered sectors are assigned, in range [0..WPoStPeriodDeadlines)
-//	Deadline uint64
-//	// Partition index within the deadline containing the recovered sectors.
-//	Partition uint64
-//	// Sectors in the partition being declared recovered.
-//	Sectors bitfield.BitField
-//}
+//	type RecoveryDeclaration struct {
+//		// The deadline to which the recovered sectors are assigned, in range [0..WPoStPeriodDeadlines)
+//		Deadline uint64
+//		// Partition index within the deadline containing the recovered sectors.
+//		Partition uint64
+//		// Sectors in the partition being declared recovered.
+//		Sectors bitfield.BitField
+//	}
 type RecoveryDeclaration = miner0.RecoveryDeclaration
 
 func (a Actor) DeclareFaultsRecovered(rt Runtime, params *DeclareFaultsRecoveredParams) *abi.EmptyValue {
@@ -1685,7 +2331,7 @@ func (a Actor) DeclareFaultsRecovered(rt Runtime, params *DeclareFaultsRecovered
 		feeToBurn = RepayDebtsOrAbort(rt, &st)
 
 		info := getMinerInfo(rt, &st)
-		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+		validateCallerPermitted(rt, info, PermitWindowPoSt)
 		if ConsensusFaultActive(info, rt.CurrEpoch()) {
 			rt.Abortf(exitcode.ErrForbidden, "recovery not allowed during active consensus fault")
 		}
@@ -1696,35 +2342,112 @@ func (a Actor) DeclareFaultsRecovered(rt Runtime, params *DeclareFaultsRecovered
 		sectors, err := LoadSectors(store, st.Sectors)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors array")
 
-		currEpoch := rt.CurrEpoch()
-		err = toProcess.ForEach(func(dlIdx uint64, pm PartitionSectorMap) error {
-			targetDeadline, err := declarationDeadlineInfo(st.CurrentProvingPeriodStart(currEpoch), dlIdx, currEpoch)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid recovery declaration deadline %d", dlIdx)
-			err = validateFRDeclarationDeadline(targetDeadline)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed recovery declaration at deadline %d", dlIdx)
+		recordDeclaredRecoveries(rt, &st, info, deadlines, sectors, toProcess)
 
-			deadline, err := deadlines.LoadDeadline(store, dlIdx)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", dlIdx)
+		err = st.SaveDeadlines(store, deadlines)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
+	})
+
+	burnFunds(rt, feeToBurn)
+	rt.StateReadonly(&st)
+	err = st.CheckBalanceInvariants(rt.CurrentBalance())
+	builtin.RequireNoErr(rt, err, ErrBalanceInvariantBroken, "balance invariants broken")
+
+	// Power is not restored yet, but when the recovered sectors are successfully PoSted.
+	return nil
+}
 
-			err = deadline.DeclareFaultsRecovered(store, sectors, info.SectorSize, pm)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to declare recoveries for deadline %d", dlIdx)
+type DeclareFaultsAndRecoveriesParams struct {
+	Faults     []FaultDeclaration
+	Recoveries []RecoveryDeclaration
+}
 
-			err = deadlines.UpdateDeadline(store, dlIdx, deadline)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to store deadline %d", dlIdx)
-			return nil
+// Declares faults and recoveries together, for a miner adjusting many partitions at once.
+// The sectors declared faulty and recovered must be disjoint.
+// Faults are recorded before recoveries, with the same effects as DeclareFaults and DeclareFaultsRecovered.
+// Fee debt must be repaid, and no consensus fault may be active, only if any recoveries are declared.
+func (a Actor) DeclareFaultsAndRecoveries(rt Runtime, params *DeclareFaultsAndRecoveriesParams) *abi.EmptyValue {
+	if len(params.Faults)+len(params.Recoveries) > DeclarationsMax {
+		rt.Abortf(exitcode.ErrIllegalArgument,
+			"too many fault and recovery declarations for a single message: %d > %d",
+			len(params.Faults)+len(params.Recoveries), DeclarationsMax,
+		)
+	}
+
+	faults := make(DeadlineSectorMap)
+	for _, decl := range params.Faults {
+		err := faults.Add(decl.Deadline, decl.Partition, decl.Sectors)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument,
+			"failed to process fault deadline %d, partition %d", decl.Deadline, decl.Partition,
+		)
+	}
+	recoveries := make(DeadlineSectorMap)
+	for _, decl := range params.Recoveries {
+		err := recoveries.Add(decl.Deadline, decl.Partition, decl.Sectors)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument,
+			"failed to process recovery deadline %d, partition %d", decl.Deadline, decl.Partition,
+		)
+	}
+
+	// The limits apply to the declarations together.
+	allDeclared := make(DeadlineSectorMap)
+	for _, dm := range []DeadlineSectorMap{faults, recoveries} {
+		err := dm.ForEach(func(dlIdx uint64, pm PartitionSectorMap) error {
+			return pm.ForEach(func(partIdx uint64, sectorNos bitfield.BitField) error {
+				if prior, ok := allDeclared[dlIdx][partIdx]; ok {
+					overlap, err := BitFieldContainsAny(prior, sectorNos)
+					if err != nil {
+						return err
+					} else if overlap {
+						return xerrors.Errorf("sectors in deadline %d, partition %d declared both faulty and recovered", dlIdx, partIdx)
+					}
+				}
+				return allDeclared.Add(dlIdx, partIdx, sectorNos)
+			})
 		})
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to walk sectors")
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid declarations")
+	}
+	err := allDeclared.Check(AddressedPartitionsMax, AddressedSectorsMax())
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "cannot process requested parameters")
+
+	store := adt.AsStore(rt)
+	var st State
+	powerDelta := NewPowerPairZero()
+	feeToBurn := abi.NewTokenAmount(0)
+	rt.StateTransaction(&st, func() {
+		info := getMinerInfo(rt, &st)
+		validateCallerPermitted(rt, info, PermitWindowPoSt)
+
+		if len(recoveries) > 0 {
+			// Verify unlocked funds cover both InitialPledgeRequirement and FeeDebt
+			// and repay fee debt now.
+			feeToBurn = RepayDebtsOrAbort(rt, &st)
+			if ConsensusFaultActive(info, rt.CurrEpoch()) {
+				rt.Abortf(exitcode.ErrForbidden, "recovery not allowed during active consensus fault")
+			}
+		}
+
+		deadlines, err := st.LoadDeadlines(store)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
+
+		sectors, err := LoadSectors(store, st.Sectors)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load sectors array")
+
+		powerDelta = recordDeclaredFaults(rt, &st, info, deadlines, sectors, faults)
+		recordDeclaredRecoveries(rt, &st, info, deadlines, sectors, recoveries)
 
 		err = st.SaveDeadlines(store, deadlines)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
 	})
 
+	// Remove power for new faulty sectors.
+	// Power for recovered sectors is restored when they are successfully PoSted.
+	requestUpdatePower(rt, powerDelta)
+
 	burnFunds(rt, feeToBurn)
 	rt.StateReadonly(&st)
 	err = st.CheckBalanceInvariants(rt.CurrentBalance())
 	builtin.RequireNoErr(rt, err, ErrBalanceInvariantBroken, "balance invariants broken")
-
-	// Power is not restored yet, but when the recovered sectors are successfully PoSted.
 	return nil
 }
 
@@ -1732,10 +2455,10 @@ func (a Actor) DeclareFaultsRecovered(rt Runtime, params *DeclareFaultsRecovered
 // Maintenance //
 /////////////////
 
-//type CompactPartitionsParams struct {
-//	Deadline   uint64
-//	Partitions bitfield.BitField
-//}
+//	type CompactPartitionsParams struct {
+//		Deadline   uint64
+//		Partitions bitfield.BitField
+//	}
 type CompactPartitionsParams = miner0.CompactPartitionsParams
 
 // Compacts a number of partitions at one deadline by removing terminated sectors, re-ordering the remaining sectors,
@@ -1756,7 +2479,7 @@ func (a Actor) CompactPartitions(rt Runtime, params *CompactPartitionsParams) *a
 	var st State
 	rt.StateTransaction(&st, func() {
 		info := getMinerInfo(rt, &st)
-		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+		validateCallerPermitted(rt, info, PermitSectorManagement)
 
 		if !deadlineAvailableForCompaction(st.CurrentProvingPeriodStart(rt.CurrEpoch()), params.Deadline, rt.CurrEpoch()) {
 			rt.Abortf(exitcode.ErrForbidden,
@@ -1801,9 +2524,9 @@ func (a Actor) CompactPartitions(rt Runtime, params *CompactPartitionsParams) *a
 	return nil
 }
 
-//type CompactSectorNumbersParams struct {
-//	MaskSectorNumbers bitfield.BitField
-//}
+//	type CompactSectorNumbersParams struct {
+//		MaskSectorNumbers bitfield.BitField
+//	}
 type CompactSectorNumbersParams = miner0.CompactSectorNumbersParams
 
 // Compacts sector number allocations to reduce the size of the allocated sector
@@ -1826,7 +2549,7 @@ func (a Actor) CompactSectorNumbers(rt Runtime, params *CompactSectorNumbersPara
 	var st State
 	rt.StateTransaction(&st, func() {
 		info := getMinerInfo(rt, &st)
-		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+		validateCallerPermitted(rt, info, PermitSectorManagement)
 
 		err := st.AllocateSectorNumbers(store, params.MaskSectorNumbers, AllowCollisions)
 
@@ -1835,6 +2558,68 @@ func (a Actor) CompactSectorNumbers(rt Runtime, params *CompactSectorNumbersPara
 	return nil
 }
 
+type CompactTerminatedSectorsParams struct {
+	Deadline   uint64
+	Partitions bitfield.BitField
+	MaxSectors uint64 // Limited to AddressedSectorsMax.
+}
+
+// Removes terminated sectors from partitions of a deadline, and deletes their on-chain info, without moving
+// live sectors between partitions as CompactPartitions does. Stale entries for the partitions in the deadline's
+// expiration queue are also removed.
+//
+// At most MaxSectors sectors are removed by each call, in order of partition then sector number, so that the cost of
+// compacting a miner with many terminated sectors may be spread over multiple calls with the same parameters.
+// The same restrictions on the deadline's challenge window apply as for CompactPartitions, and the deadline must not
+// have any unprocessed early terminations.
+func (a Actor) CompactTerminatedSectors(rt Runtime, params *CompactTerminatedSectorsParams) *abi.EmptyValue {
+	if params.Deadline >= WPoStPeriodDeadlines() {
+		rt.Abortf(exitcode.ErrIllegalArgument, "invalid deadline %v", params.Deadline)
+	}
+	if params.MaxSectors > AddressedSectorsMax() {
+		rt.Abortf(exitcode.ErrIllegalArgument, "too many sectors %d, limit %d", params.MaxSectors, AddressedSectorsMax())
+	}
+
+	partitionCount, err := params.Partitions.Count()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to parse partitions bitfield")
+	if partitionCount > AddressedPartitionsMax {
+		rt.Abortf(exitcode.ErrIllegalArgument, "too many partitions %d, limit %d", partitionCount, AddressedPartitionsMax)
+	}
+
+	store := adt.AsStore(rt)
+	var st State
+	rt.StateTransaction(&st, func() {
+		info := getMinerInfo(rt, &st)
+		validateCallerPermitted(rt, info, PermitSectorManagement)
+
+		if !deadlineAvailableForCompaction(st.CurrentProvingPeriodStart(rt.CurrEpoch()), params.Deadline, rt.CurrEpoch()) {
+			rt.Abortf(exitcode.ErrForbidden,
+				"cannot compact deadline %d during its challenge window, or the prior challenge window, or before %d epochs have passed since its last challenge window ended", params.Deadline, WPoStDisputeWindow())
+		}
+
+		quant := st.QuantSpecForDeadline(params.Deadline)
+
+		deadlines, err := st.LoadDeadlines(store)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadlines")
+
+		deadline, err := deadlines.LoadDeadline(store, params.Deadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", params.Deadline)
+
+		removed, _, err := deadline.RemoveTerminatedSectors(store, params.Partitions, params.MaxSectors, quant)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to remove terminated sectors from deadline %d", params.Deadline)
+
+		err = st.DeleteSectors(store, removed)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete terminated sectors")
+
+		err = deadlines.UpdateDeadline(store, params.Deadline, deadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update deadline %d", params.Deadline)
+
+		err = st.SaveDeadlines(store, deadlines)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save deadlines")
+	})
+	return nil
+}
+
 ///////////////////////
 // Pledge Collateral //
 ///////////////////////
@@ -1892,12 +2677,53 @@ func (a Actor) ApplyRewards(rt Runtime, params *builtin.ApplyRewardParams) *abi.
 	return nil
 }
 
-//type ReportConsensusFaultParams struct {
-//	BlockHeader1     []byte
-//	BlockHeader2     []byte
-//	BlockHeaderExtra []byte
-//}
-type ReportConsensusFaultParams = miner0.ReportConsensusFaultParams
+// Evidence of a consensus fault. Exactly one type of evidence must be provided.
+type ReportConsensusFaultParams struct {
+	DoubleForkMining *DoubleForkMiningEvidence
+	ParentGrinding   *ParentGrindingEvidence
+	TimeOffsetMining *TimeOffsetMiningEvidence
+}
+
+// Two blocks mined by the same miner at the same epoch.
+type DoubleForkMiningEvidence struct {
+	BlockHeader1 []byte
+	BlockHeader2 []byte
+}
+
+// Two blocks mined by the same miner, the second of which has the first as a parent but omits the witness,
+// a block with the same parents and epoch as the first.
+type ParentGrindingEvidence struct {
+	BlockHeader1  []byte
+	BlockHeader2  []byte
+	WitnessHeader []byte
+}
+
+// Two blocks mined by the same miner with the same parents at different epochs.
+type TimeOffsetMiningEvidence struct {
+	BlockHeader1 []byte
+	BlockHeader2 []byte
+}
+
+// Returns the type of fault claimed by the evidence, and the block headers with which to verify it.
+func (p *ReportConsensusFaultParams) evidence() (faultType runtime.ConsensusFaultType, h1, h2, extra []byte, err error) {
+	count := 0
+	if p.DoubleForkMining != nil {
+		count++
+		faultType, h1, h2 = runtime.ConsensusFaultDoubleForkMining, p.DoubleForkMining.BlockHeader1, p.DoubleForkMining.BlockHeader2
+	}
+	if p.ParentGrinding != nil {
+		count++
+		faultType, h1, h2, extra = runtime.ConsensusFaultParentGrinding, p.ParentGrinding.BlockHeader1, p.ParentGrinding.BlockHeader2, p.ParentGrinding.WitnessHeader
+	}
+	if p.TimeOffsetMining != nil {
+		count++
+		faultType, h1, h2 = runtime.ConsensusFaultTimeOffsetMining, p.TimeOffsetMining.BlockHeader1, p.TimeOffsetMining.BlockHeader2
+	}
+	if count != 1 {
+		return 0, nil, nil, nil, xerrors.Errorf("expected exactly one type of evidence, got %d", count)
+	}
+	return faultType, h1, h2, extra, nil
+}
 
 func (a Actor) ReportConsensusFault(rt Runtime, params *ReportConsensusFaultParams) *abi.EmptyValue {
 	// Note: only the first report of any fault is processed because it sets the
@@ -1906,10 +2732,16 @@ func (a Actor) ReportConsensusFault(rt Runtime, params *ReportConsensusFaultPara
 	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
 	reporter := rt.Caller()
 
-	fault, err := rt.VerifyConsensusFault(params.BlockHeader1, params.BlockHeader2, params.BlockHeaderExtra)
+	faultType, h1, h2, extra, err := params.evidence()
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid fault evidence")
+
+	fault, err := rt.VerifyConsensusFault(h1, h2, extra)
 	if err != nil {
 		rt.Abortf(exitcode.ErrIllegalArgument, "fault not verified: %s", err)
 	}
+	if fault.Type != faultType {
+		rt.Abortf(exitcode.ErrIllegalArgument, "evidence for fault type %d verified as fault type %d", faultType, fault.Type)
+	}
 	if fault.Target != rt.Receiver() {
 		rt.Abortf(exitcode.ErrIllegalArgument, "fault by %v reported to miner %v", fault.Target, rt.Receiver())
 	}
@@ -1928,7 +2760,7 @@ func (a Actor) ReportConsensusFault(rt Runtime, params *ReportConsensusFaultPara
 	// The policy amounts we should burn and send to reporter
 	// These may differ from actual funds send when miner goes into fee debt
 	thisEpochReward := rewardStats.ThisEpochRewardSmoothed.Estimate()
-	faultPenalty := ConsensusFaultPenalty(thisEpochReward)
+	faultPenalty := ConsensusFaultPenaltyForType(fault.Type, thisEpochReward)
 	slasherReward := RewardForConsensusSlashReport(thisEpochReward)
 	pledgeDelta := big.Zero()
 
@@ -1968,6 +2800,10 @@ func (a Actor) ReportConsensusFault(rt Runtime, params *ReportConsensusFaultPara
 	burnFunds(rt, burnAmount)
 	notifyPledgeChanged(rt, pledgeDelta)
 
+	// Record the fault in the miner's power claim.
+	code = rt.Send(builtin.StoragePowerActorAddr, builtin.MethodsPower.OnConsensusFault, nil, big.Zero(), &builtin.Discard{})
+	builtin.RequireSuccess(rt, code, "failed to record consensus fault")
+
 	rt.StateReadonly(&st)
 	err = st.CheckBalanceInvariants(rt.CurrentBalance())
 	builtin.RequireNoErr(rt, err, ErrBalanceInvariantBroken, "balance invariants broken")
@@ -1975,9 +2811,9 @@ func (a Actor) ReportConsensusFault(rt Runtime, params *ReportConsensusFaultPara
 	return nil
 }
 
-//type WithdrawBalanceParams struct {
-//	AmountRequested abi.TokenAmount
-//}
+//	type WithdrawBalanceParams struct {
+//		AmountRequested abi.TokenAmount
+//	}
 type WithdrawBalanceParams = miner0.WithdrawBalanceParams
 
 // Attempt to withdraw the specified amount from the miner's available balance.
@@ -1993,12 +2829,17 @@ func (a Actor) WithdrawBalance(rt Runtime, params *WithdrawBalanceParams) *abi.T
 	newlyVested := big.Zero()
 	feeToBurn := big.Zero()
 	availableBalance := big.Zero()
+	amountWithdrawn := big.Zero()
 	rt.StateTransaction(&st, func() {
 		var err error
 		info = getMinerInfo(rt, &st)
-		// Only the owner is allowed to withdraw the balance as it belongs to/is controlled by the owner
-		// and not the worker.
-		rt.ValidateImmediateCallerIs(info.Owner)
+		// Only the owner or beneficiary is allowed to withdraw the balance as it belongs to/is controlled by
+		// the owner and not the worker.
+		if info.Beneficiary == info.Owner {
+			rt.ValidateImmediateCallerIs(info.Owner)
+		} else {
+			rt.ValidateImmediateCallerIs(info.Owner, info.Beneficiary)
+		}
 
 		// Ensure we don't have any pending terminations.
 		if count, err := st.EarlyTerminations.Count(); err != nil {
@@ -2024,14 +2865,24 @@ func (a Actor) WithdrawBalance(rt Runtime, params *WithdrawBalanceParams) *abi.T
 		// Verify unlocked funds cover both InitialPledgeRequirement and FeeDebt
 		// and repay fee debt now.
 		feeToBurn = RepayDebtsOrAbort(rt, &st)
+
+		amountWithdrawn = big.Min(availableBalance, params.AmountRequested)
+		if info.Beneficiary != info.Owner {
+			// A beneficiary other than the owner receives no more than the remaining quota of its term.
+			amountWithdrawn = big.Min(amountWithdrawn, info.BeneficiaryTerm.Available(rt.CurrEpoch()))
+			if amountWithdrawn.GreaterThan(big.Zero()) {
+				info.BeneficiaryTerm.UsedQuota = big.Add(info.BeneficiaryTerm.UsedQuota, amountWithdrawn)
+				err = st.SaveInfo(adt.AsStore(rt), info)
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save miner info")
+			}
+		}
 	})
 
-	amountWithdrawn := big.Min(availableBalance, params.AmountRequested)
 	builtin.RequireState(rt, amountWithdrawn.GreaterThanEqual(big.Zero()), "negative amount to withdraw: %v", amountWithdrawn)
 	builtin.RequireState(rt, amountWithdrawn.LessThanEqual(availableBalance), "amount to withdraw %v < available %v", amountWithdrawn, availableBalance)
 
 	if amountWithdrawn.GreaterThan(abi.NewTokenAmount(0)) {
-		code := rt.Send(info.Owner, builtin.MethodSend, nil, amountWithdrawn, &builtin.Discard{})
+		code := rt.Send(info.Beneficiary, builtin.MethodSend, nil, amountWithdrawn, &builtin.Discard{})
 		builtin.RequireSuccess(rt, code, "failed to withdraw balance")
 	}
 
@@ -2052,7 +2903,7 @@ func (a Actor) RepayDebt(rt Runtime, _ *abi.EmptyValue) *abi.EmptyValue {
 	rt.StateTransaction(&st, func() {
 		var err error
 		info := getMinerInfo(rt, &st)
-		rt.ValidateImmediateCallerIs(append(info.ControlAddresses, info.Owner, info.Worker)...)
+		validateCallerPermitted(rt, info, PermitRepayDebt)
 
 		// Repay as much fee debt as possible.
 		fromVesting, fromBalance, err = st.RepayPartialDebtInPriorityOrder(adt.AsStore(rt), rt.CurrEpoch(), rt.CurrentBalance())
@@ -2067,13 +2918,57 @@ func (a Actor) RepayDebt(rt Runtime, _ *abi.EmptyValue) *abi.EmptyValue {
 	return nil
 }
 
+// Deregisters the miner from the power actor, removing its claim so that it no longer counts toward the
+// network's miners. The miner must have no live sectors, pre-committed sectors, pending early terminations,
+// pledge, locked funds or fee debt. Its remaining balance may still be withdrawn, but it cannot take on power again.
+// May only be invoked by the owner.
+func (a Actor) Deregister(rt Runtime, _ *abi.EmptyValue) *abi.EmptyValue {
+	store := adt.AsStore(rt)
+	var st State
+	rt.StateTransaction(&st, func() {
+		info := getMinerInfo(rt, &st)
+		rt.ValidateImmediateCallerIs(info.Owner)
+
+		if !st.IsDebtFree() {
+			rt.Abortf(exitcode.ErrForbidden, "cannot deregister with fee debt %v", st.FeeDebt)
+		}
+		if st.ContinueDeadlineCron() {
+			rt.Abortf(exitcode.ErrForbidden, "cannot deregister with pre-commit deposits %v, initial pledge %v, locked funds %v",
+				st.PreCommitDeposits, st.InitialPledge, st.LockedFunds)
+		}
+		if count, err := st.EarlyTerminations.Count(); err != nil {
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to count early terminations")
+		} else if count > 0 {
+			rt.Abortf(exitcode.ErrForbidden, "cannot deregister with early terminations pending at %d deadlines", count)
+		}
+
+		precommitted, err := st.HasPrecommittedSectors(store)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to check pre-committed sectors")
+		if precommitted {
+			rt.Abortf(exitcode.ErrForbidden, "cannot deregister with pre-committed sectors")
+		}
+		live, err := st.LiveSectorCount(store)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to count live sectors")
+		if live > 0 {
+			rt.Abortf(exitcode.ErrForbidden, "cannot deregister with %d live sectors", live)
+		}
+
+		// Any deadline cron event already enrolled will be skipped by the power actor.
+		st.DeadlineCronActive = false
+	})
+
+	code := rt.Send(builtin.StoragePowerActorAddr, builtin.MethodsPower.DeregisterMiner, nil, big.Zero(), &builtin.Discard{})
+	builtin.RequireSuccess(rt, code, "failed to deregister from power actor")
+	return nil
+}
+
 //////////
 // Cron //
 //////////
 
-//type CronEventPayload struct {
-//	EventType CronEventType
-//}
+//	type CronEventPayload struct {
+//		EventType CronEventType
+//	}
 type CronEventPayload = miner0.CronEventPayload
 
 type CronEventType = miner0.CronEventType
@@ -2157,7 +3052,7 @@ func processEarlyTerminations(rt Runtime, rewardSmoothed smoothing.FilterEstimat
 				params.DealIDs = append(params.DealIDs, sector.DealIDs...)
 				totalInitialPledge = big.Add(totalInitialPledge, sector.InitialPledge)
 			}
-			penalty = big.Add(penalty, terminationPenalty(info.SectorSize, epoch,
+			penalty = big.Add(penalty, PledgePenaltyForSectorsTermination(info.SectorSize, epoch,
 				rewardSmoothed, qualityAdjPowerSmoothed, sectors))
 			dealsToTerminate = append(dealsToTerminate, params)
 
@@ -2228,11 +3123,9 @@ func handleProvingDeadline(rt Runtime,
 			pledgeDeltaTotal = big.Add(pledgeDeltaTotal, newlyVested.Neg())
 		}
 
-		{
-			// Process pending worker change if any
-			info := getMinerInfo(rt, &st)
-			processPendingWorker(info, rt, &st)
-		}
+		// Process pending worker change if any
+		info := getMinerInfo(rt, &st)
+		processPendingWorker(info, rt, &st)
 
 		{
 			depositToBurn, err := st.CleanUpExpiredPreCommits(store, currEpoch)
@@ -2262,6 +3155,11 @@ func handleProvingDeadline(rt Runtime,
 			powerDeltaTotal = powerDeltaTotal.Add(result.PowerDelta)
 			pledgeDeltaTotal = big.Add(pledgeDeltaTotal, result.PledgeDelta)
 
+			// Sectors paying the continued fault fee are estimated to have been faulty for the whole proving period
+			// just ended.
+			faultySectors := big.Div(result.PreviouslyFaultyPower.Raw, big.NewIntUnsigned(uint64(info.SectorSize)))
+			st.Stats.EstimatedFaultySectorEpochs += faultySectors.Uint64() * uint64(WPoStProvingPeriod())
+
 			err = st.ApplyPenalty(penaltyTarget)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to apply penalty")
 			rt.Log(rtt.DEBUG, "storage provider %s penalized %s for continued fault", rt.Receiver(), penaltyTarget)
@@ -2285,9 +3183,7 @@ func handleProvingDeadline(rt Runtime,
 	// Schedule cron callback for next deadline's last epoch.
 	if continueCron {
 		newDlInfo := st.DeadlineInfo(currEpoch + 1)
-		enrollCronEvent(rt, newDlInfo.Last(), &CronEventPayload{
-			EventType: CronEventProvingDeadline,
-		})
+		enrollProvingDeadlineCronEvent(rt, newDlInfo.Last())
 	} else {
 		rt.Log(rtt.INFO, "miner %s going inactive, deadline cron discontinued", rt.Receiver())
 	}
@@ -2321,10 +3217,16 @@ func validateExpiration(rt Runtime, activation, expiration abi.ChainEpoch, sealP
 			expiration, expiration-activation, MinSectorExpiration(), activation)
 	}
 
-	// expiration cannot exceed MaxSectorExpirationExtension from now
-	if expiration > rt.CurrEpoch()+MaxSectorExpirationExtension() {
+	// expiration cannot exceed MaxSectorExpirationExtension, nor the maximum commitment for the sector's seal proof,
+	// from now
+	maxCommitment, err := builtin.SealProofSectorMaximumCommitment(sealProof)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "unrecognized seal proof type %d", sealProof)
+	if MaxSectorExpirationExtension() < maxCommitment {
+		maxCommitment = MaxSectorExpirationExtension()
+	}
+	if expiration > rt.CurrEpoch()+maxCommitment {
 		rt.Abortf(exitcode.ErrIllegalArgument, "invalid expiration %d, cannot be more than %d past current epoch %d",
-			expiration, MaxSectorExpirationExtension(), rt.CurrEpoch())
+			expiration, maxCommitment, rt.CurrEpoch())
 	}
 
 	// total sector lifetime cannot exceed SectorMaximumLifetime for the sector's seal proof
@@ -2367,6 +3269,19 @@ func validateReplaceSector(rt Runtime, st *State, store adt.Store, params *miner
 }
 
 func enrollCronEvent(rt Runtime, eventEpoch abi.ChainEpoch, callbackPayload *CronEventPayload) {
+	sendCronEnrollment(rt, builtin.MethodsPower.EnrollCronEvent, eventEpoch, callbackPayload)
+}
+
+// Enrolls the end of a proving deadline as a priority cron event, which the power actor invokes at its epoch
+// regardless of the limit on cron events per epoch, since the deadline processed is determined by the epoch
+// of the callback.
+func enrollProvingDeadlineCronEvent(rt Runtime, eventEpoch abi.ChainEpoch) {
+	sendCronEnrollment(rt, builtin.MethodsPower.EnrollPriorityCronEvent, eventEpoch, &CronEventPayload{
+		EventType: CronEventProvingDeadline,
+	})
+}
+
+func sendCronEnrollment(rt Runtime, method abi.MethodNum, eventEpoch abi.ChainEpoch, callbackPayload *CronEventPayload) {
 	payload := new(bytes.Buffer)
 	err := callbackPayload.MarshalCBOR(payload)
 	if err != nil {
@@ -2374,7 +3289,7 @@ func enrollCronEvent(rt Runtime, eventEpoch abi.ChainEpoch, callbackPayload *Cro
 	}
 	code := rt.Send(
 		builtin.StoragePowerActorAddr,
-		builtin.MethodsPower.EnrollCronEvent,
+		method,
 		&power.EnrollCronEventParams{
 			EventEpoch: eventEpoch,
 			Payload:    payload.Bytes(),
@@ -2436,15 +3351,53 @@ func havePendingEarlyTerminations(rt Runtime, st *State) bool {
 }
 
 func verifyWindowedPost(rt Runtime, challengeEpoch abi.ChainEpoch, sectors []*SectorOnChainInfo, proofs []proof.PoStProof) error {
-	minerActorID, err := addr.IDFromAddress(rt.Receiver())
-	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "runtime provided bad receiver address %v", rt.Receiver())
+	postRandomness := windowPoStRandomness(rt, challengeEpoch)
+	pvInfo := windowPoStVerifyInfo(rt, postRandomness, sectors, proofs)
+
+	// Verify the PoSt Proof
+	err := rt.VerifyPoSt(pvInfo)
+	if err != nil {
+		return fmt.Errorf("invalid PoSt %+v: %w", pvInfo, err)
+	}
+	return nil
+}
+
+// Verifies a proof of each of a sequence of partitions with a single syscall.
+// The proof at each index covers the sectors at the same index.
+func batchVerifyWindowedPosts(rt Runtime, challengeEpoch abi.ChainEpoch, partitionSectors [][]*SectorOnChainInfo, proofs []proof.PoStProof) error {
+	postRandomness := windowPoStRandomness(rt, challengeEpoch)
+	pvInfos := make([]proof.WindowPoStVerifyInfo, len(proofs))
+	for i := range proofs {
+		pvInfos[i] = windowPoStVerifyInfo(rt, postRandomness, partitionSectors[i], proofs[i:i+1])
+	}
+
+	// Verify the PoSt Proofs
+	verified, err := rt.BatchVerifyPoSts(pvInfos)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to batch verify PoSts")
+	if len(verified) != len(pvInfos) {
+		rt.Abortf(exitcode.ErrIllegalState, "batch verification returned %d results for %d PoSts", len(verified), len(pvInfos))
+	}
+	for i, ok := range verified {
+		if !ok {
+			return fmt.Errorf("invalid PoSt %d of batch %+v", i, pvInfos[i])
+		}
+	}
+	return nil
+}
 
-	// Regenerate challenge randomness, which must match that generated for the proof.
+// Regenerates challenge randomness, which must match that generated for the proof.
+func windowPoStRandomness(rt Runtime, challengeEpoch abi.ChainEpoch) abi.PoStRandomness {
 	var addrBuf bytes.Buffer
 	receiver := rt.Receiver()
-	err = receiver.MarshalCBOR(&addrBuf)
+	err := receiver.MarshalCBOR(&addrBuf)
 	builtin.RequireNoErr(rt, err, exitcode.ErrSerialization, "failed to marshal address for window post challenge")
-	postRandomness := rt.GetRandomnessFromBeacon(crypto.DomainSeparationTag_WindowedPoStChallengeSeed, challengeEpoch, addrBuf.Bytes())
+	return abi.PoStRandomness(rt.GetRandomnessFromBeacon(crypto.DomainSeparationTag_WindowedPoStChallengeSeed, challengeEpoch, addrBuf.Bytes()))
+}
+
+// Assembles the public inputs for verifying a PoSt of sectors.
+func windowPoStVerifyInfo(rt Runtime, postRandomness abi.PoStRandomness, sectors []*SectorOnChainInfo, proofs []proof.PoStProof) proof.WindowPoStVerifyInfo {
+	minerActorID, err := addr.IDFromAddress(rt.Receiver())
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "runtime provided bad receiver address %v", rt.Receiver())
 
 	sectorProofInfo := make([]proof.SectorInfo, len(sectors))
 	for i, s := range sectors {
@@ -2455,20 +3408,12 @@ func verifyWindowedPost(rt Runtime, challengeEpoch abi.ChainEpoch, sectors []*Se
 		}
 	}
 
-	// Get public inputs
-	pvInfo := proof.WindowPoStVerifyInfo{
-		Randomness:        abi.PoStRandomness(postRandomness),
+	return proof.WindowPoStVerifyInfo{
+		Randomness:        postRandomness,
 		Proofs:            proofs,
 		ChallengedSectors: sectorProofInfo,
 		Prover:            abi.ActorID(minerActorID),
 	}
-
-	// Verify the PoSt Proof
-	err = rt.VerifyPoSt(pvInfo)
-	if err != nil {
-		return fmt.Errorf("invalid PoSt %+v: %w", pvInfo, err)
-	}
-	return nil
 }
 
 // SealVerifyParams is the structure of information that must be sent with a
@@ -2494,7 +3439,11 @@ func getVerifyInfo(rt Runtime, params *SealVerifyStuff) *proof.SealVerifyInfo {
 		SectorType: params.RegisteredSealProof,
 		DealIDs:    params.DealIDs,
 	})
+	return sealVerifyInfo(rt, params, commDs[0])
+}
 
+// Builds the information to verify a sector's seal, given its unsealed CID.
+func sealVerifyInfo(rt Runtime, params *SealVerifyStuff, unsealedCID cid.Cid) *proof.SealVerifyInfo {
 	minerActorID, err := addr.IDFromAddress(rt.Receiver())
 	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "runtime provided non-ID receiver address %v", rt.Receiver())
 
@@ -2517,7 +3466,7 @@ func getVerifyInfo(rt Runtime, params *SealVerifyStuff) *proof.SealVerifyInfo {
 		Proof:                 params.Proof,
 		Randomness:            abi.SealRandomness(svInfoRandomness),
 		SealedCID:             params.SealedCID,
-		UnsealedCID:           commDs[0],
+		UnsealedCID:           unsealedCID,
 	}
 }
 
@@ -2704,14 +3653,23 @@ func zeroReplacedSectorParameters() (pledge abi.TokenAmount, age abi.ChainEpoch,
 	return big.Zero(), abi.ChainEpoch(0), big.Zero()
 }
 
-// Update worker address with pending worker key if exists and delay has passed
+// Update worker address with pending worker keys whose delay has passed, in order.
 func processPendingWorker(info *MinerInfo, rt Runtime, st *State) {
-	if info.PendingWorkerKey == nil || rt.CurrEpoch() < info.PendingWorkerKey.EffectiveAt {
+	applied := 0
+	for _, change := range info.PendingWorkerKeys {
+		if rt.CurrEpoch() < change.EffectiveAt {
+			break
+		}
+		info.Worker = change.NewWorker
+		applied++
+	}
+	if applied == 0 {
 		return
 	}
-
-	info.Worker = info.PendingWorkerKey.NewWorker
-	info.PendingWorkerKey = nil
+	info.PendingWorkerKeys = info.PendingWorkerKeys[applied:]
+	if len(info.PendingWorkerKeys) == 0 {
+		info.PendingWorkerKeys = nil
+	}
 
 	err := st.SaveInfo(adt.AsStore(rt), info)
 	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "could not save miner info")
@@ -2737,6 +3695,60 @@ func validateFRDeclarationDeadline(deadline *dline.Info) error {
 	return nil
 }
 
+// Records declared faults in the deadlines, returning the power lost.
+// The caller must save the deadlines.
+func recordDeclaredFaults(rt Runtime, st *State, info *MinerInfo, deadlines *Deadlines, sectors Sectors, toProcess DeadlineSectorMap) PowerPair {
+	store := adt.AsStore(rt)
+	currEpoch := rt.CurrEpoch()
+	powerDelta := NewPowerPairZero()
+	err := toProcess.ForEach(func(dlIdx uint64, pm PartitionSectorMap) error {
+		targetDeadline, err := declarationDeadlineInfo(st.CurrentProvingPeriodStart(currEpoch), dlIdx, currEpoch)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid fault declaration deadline %d", dlIdx)
+
+		err = validateFRDeclarationDeadline(targetDeadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed fault declaration at deadline %d", dlIdx)
+
+		deadline, err := deadlines.LoadDeadline(store, dlIdx)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", dlIdx)
+
+		faultExpirationEpoch := targetDeadline.Last() + FaultMaxAge()
+		deadlinePowerDelta, err := deadline.RecordFaults(store, sectors, info.SectorSize, QuantSpecForDeadline(targetDeadline), faultExpirationEpoch, pm)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to declare faults for deadline %d", dlIdx)
+
+		err = deadlines.UpdateDeadline(store, dlIdx, deadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to store deadline %d partitions", dlIdx)
+
+		powerDelta = powerDelta.Add(deadlinePowerDelta)
+		return nil
+	})
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to iterate deadlines")
+	return powerDelta
+}
+
+// Records declared recoveries in the deadlines.
+// The caller must save the deadlines.
+func recordDeclaredRecoveries(rt Runtime, st *State, info *MinerInfo, deadlines *Deadlines, sectors Sectors, toProcess DeadlineSectorMap) {
+	store := adt.AsStore(rt)
+	currEpoch := rt.CurrEpoch()
+	err := toProcess.ForEach(func(dlIdx uint64, pm PartitionSectorMap) error {
+		targetDeadline, err := declarationDeadlineInfo(st.CurrentProvingPeriodStart(currEpoch), dlIdx, currEpoch)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid recovery declaration deadline %d", dlIdx)
+		err = validateFRDeclarationDeadline(targetDeadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed recovery declaration at deadline %d", dlIdx)
+
+		deadline, err := deadlines.LoadDeadline(store, dlIdx)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load deadline %d", dlIdx)
+
+		err = deadline.DeclareFaultsRecovered(store, sectors, info.SectorSize, pm)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to declare recoveries for deadline %d", dlIdx)
+
+		err = deadlines.UpdateDeadline(store, dlIdx, deadline)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to store deadline %d", dlIdx)
+		return nil
+	})
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to walk sectors")
+}
+
 // Validates that a partition contains the given sectors.
 func validatePartitionContainsSectors(partition *Partition, sectors bitfield.BitField) error {
 	// Check that the declared sectors are actually assigned to the partition.
@@ -2750,18 +3762,6 @@ func validatePartitionContainsSectors(partition *Partition, sectors bitfield.Bit
 	return nil
 }
 
-func terminationPenalty(sectorSize abi.SectorSize, currEpoch abi.ChainEpoch,
-	rewardEstimate, networkQAPowerEstimate smoothing.FilterEstimate, sectors []*SectorOnChainInfo) abi.TokenAmount {
-	totalFee := big.Zero()
-	for _, s := range sectors {
-		sectorPower := QAPowerForSector(sectorSize, s)
-		fee := PledgePenaltyForTermination(s.ExpectedDayReward, currEpoch-s.Activation, s.ExpectedStoragePledge,
-			networkQAPowerEstimate, sectorPower, rewardEstimate, s.ReplacedDayReward, s.ReplacedSectorAge)
-		totalFee = big.Add(fee, totalFee)
-	}
-	return totalFee
-}
-
 func PowerForSector(sectorSize abi.SectorSize, sector *SectorOnChainInfo) PowerPair {
 	return PowerPair{
 		Raw: big.NewIntUnsigned(uint64(sectorSize)),
@@ -2828,6 +3828,22 @@ func checkControlAddresses(rt Runtime, controlAddrs []addr.Address) {
 	}
 }
 
+func checkControlPermissions(rt Runtime, controlAddrs []addr.Address, permissions []ControlPermissions) {
+	if len(permissions) != len(controlAddrs) {
+		rt.Abortf(exitcode.ErrIllegalArgument, "control permissions length %d does not match control addresses length %d", len(permissions), len(controlAddrs))
+	}
+	for i, p := range permissions {
+		if p&^AllControlPermissions != 0 {
+			rt.Abortf(exitcode.ErrIllegalArgument, "control permissions %d for address %v include unknown permissions", p, controlAddrs[i])
+		}
+	}
+}
+
+// Validates that the immediate caller is the owner, the worker, or a control address with the required permissions.
+func validateCallerPermitted(rt Runtime, info *MinerInfo, required ControlPermissions) {
+	rt.ValidateImmediateCallerIs(append(info.PermittedControlAddresses(required), info.Owner, info.Worker)...)
+}
+
 func checkPeerInfo(rt Runtime, peerID abi.PeerID, multiaddrs []abi.Multiaddrs) {
 	if uint64(len(peerID)) > MaxPeerIDLength() {
 		rt.Abortf(exitcode.ErrIllegalArgument, "peer ID size of %d exceeds maximum size of %d", peerID, MaxPeerIDLength())
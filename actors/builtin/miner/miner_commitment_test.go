@@ -7,6 +7,7 @@ import (
 	"github.com/filecoin-project/go-bitfield"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/crypto"
 	"github.com/filecoin-project/go-state-types/dline"
 	"github.com/filecoin-project/go-state-types/exitcode"
 	"github.com/filecoin-project/go-state-types/network"
@@ -1439,3 +1440,86 @@ func TestBatchMethodNetworkFees(t *testing.T) {
 	})
 
 }
+
+func TestProveCommitWithSignedDeals(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+
+	makeDeal := func(actor *actorHarness, pieceSize abi.PaddedPieceSize, start, end abi.ChainEpoch) market.ClientDealProposal {
+		return market.ClientDealProposal{
+			Proposal: market.DealProposal{
+				PieceCID:             tutil.MakeCID("piece", &market.PieceCIDPrefix),
+				PieceSize:            pieceSize,
+				Client:               tutil.NewIDAddr(t, 200),
+				Provider:             actor.receiver,
+				StartEpoch:           start,
+				EndEpoch:             end,
+				StoragePricePerEpoch: big.Zero(),
+				ProviderCollateral:   big.Zero(),
+				ClientCollateral:     big.Zero(),
+			},
+			ClientSignature: crypto.Signature{Type: crypto.SigTypeBLS},
+		}
+	}
+
+	setup := func(dealIDs []abi.DealID) (*actorHarness, *mock.Runtime, *miner.SectorPreCommitOnChainInfo) {
+		actor := newHarness(t, periodOffset)
+		rt := builderForHarness(actor).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		precommitEpoch := periodOffset + 1
+		rt.SetEpoch(precommitEpoch)
+		actor.constructAndVerify(rt)
+		dlInfo := actor.deadline(rt)
+
+		expiration := dlInfo.PeriodEnd() + defaultSectorExpiration*miner.WPoStProvingPeriod()
+		params := actor.makePreCommit(100, precommitEpoch-1, expiration, dealIDs)
+		precommit := actor.preCommitSector(rt, params, preCommitConf{}, true)
+		return actor, rt, precommit
+	}
+
+	proveWithSignedDeals := func(actor *actorHarness, rt *mock.Runtime, params *miner.ProveCommitWithSignedDealsParams) {
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(append(actor.controlAddrs, actor.owner, actor.worker)...)
+		rt.Call(actor.a.ProveCommitWithSignedDeals, params)
+	}
+
+	t.Run("rejects no deals", func(t *testing.T) {
+		actor, rt, precommit := setup(nil)
+		rt.SetEpoch(precommit.PreCommitEpoch + miner.PreCommitChallengeDelay() + 1)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "no deals to activate", func() {
+			rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+			rt.Call(actor.a.ProveCommitWithSignedDeals, &miner.ProveCommitWithSignedDealsParams{SectorNumber: 100})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects sector pre-committed with deals", func(t *testing.T) {
+		actor, rt, precommit := setup([]abi.DealID{1})
+		rt.SetEpoch(precommit.PreCommitEpoch + miner.PreCommitChallengeDelay() + 1)
+		deal := makeDeal(actor, 1<<20, rt.Epoch(), precommit.Info.Expiration)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "was pre-committed with deals", func() {
+			proveWithSignedDeals(actor, rt, &miner.ProveCommitWithSignedDealsParams{SectorNumber: 100, Deals: []market.ClientDealProposal{deal}})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects proof before the interactive epoch", func(t *testing.T) {
+		actor, rt, precommit := setup(nil)
+		rt.SetEpoch(precommit.PreCommitEpoch + miner.PreCommitChallengeDelay())
+		deal := makeDeal(actor, 1<<20, rt.Epoch()+1, precommit.Info.Expiration)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "too early to prove sector", func() {
+			proveWithSignedDeals(actor, rt, &miner.ProveCommitWithSignedDealsParams{SectorNumber: 100, Deals: []market.ClientDealProposal{deal}})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects deals larger than the sector", func(t *testing.T) {
+		actor, rt, precommit := setup(nil)
+		rt.SetEpoch(precommit.PreCommitEpoch + miner.PreCommitChallengeDelay() + 1)
+		deal := makeDeal(actor, abi.PaddedPieceSize(actor.sectorSize), rt.Epoch(), precommit.Info.Expiration)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "deals too large to fit in sector", func() {
+			proveWithSignedDeals(actor, rt, &miner.ProveCommitWithSignedDealsParams{SectorNumber: 100, Deals: []market.ClientDealProposal{deal, deal}})
+		})
+		actor.checkState(rt)
+	})
+}
@@ -79,6 +79,22 @@ type State struct {
 
 	// True when miner cron is active, false otherwise
 	DeadlineCronActive bool
+
+	// Cumulative counts of sector events over the life of the miner.
+	Stats SectorStats
+}
+
+// Cumulative counts of sector events, maintained so that a miner's history can be assessed without replaying the chain.
+type SectorStats struct {
+	// Sectors terminated before their scheduled expiration, either by the miner or after being faulty for too long.
+	TerminatedEarly uint64
+	// Sectors which reached their scheduled expiration, whether active or faulty at the time.
+	ExpiredOnTime uint64
+	// Coarse estimate of the sum over sectors of the epochs for which each was faulty, counted in whole proving
+	// periods. A whole proving period is added for each sector charged the continued fault fee at its deadline,
+	// whatever the epoch within that period at which the sector became faulty. The period in which a fault is
+	// declared or detected is never counted, nor is the part of a period before a fault is recovered.
+	EstimatedFaultySectorEpochs uint64
 }
 
 // Bitwidth of AMTs determined empirically from mutation patterns and projections of mainnet data.
@@ -98,7 +114,12 @@ type MinerInfo struct {
 	// Additional addresses that are permitted to submit messages controlling this actor (optional).
 	ControlAddresses []addr.Address // Must all be ID addresses.
 
-	PendingWorkerKey *WorkerKeyChange
+	// The methods each control address may invoke, in the same order as ControlAddresses.
+	ControlPermissions []ControlPermissions
+
+	// Scheduled changes of worker address, in order of the epoch at which they take effect.
+	// At most MaxPendingWorkerKeyChanges changes may be pending.
+	PendingWorkerKeys []WorkerKeyChange
 
 	// Byte array representing a Libp2p identity that should be used when connecting to this miner.
 	PeerId abi.PeerID
@@ -126,6 +147,56 @@ type MinerInfo struct {
 	// A proposed new owner account for this miner.
 	// Must be confirmed by a message from the pending address itself.
 	PendingOwnerAddress *addr.Address
+
+	// Account that receives funds withdrawn from this miner.
+	// Defaults to the owner. A beneficiary other than the owner may withdraw up to the quota of its term.
+	Beneficiary addr.Address // Must be an ID-address.
+
+	// The beneficiary's withdrawal quota and expiration.
+	BeneficiaryTerm BeneficiaryTerm
+
+	// A proposed change of beneficiary, awaiting approval by the current beneficiary and the nominee.
+	PendingBeneficiaryTerm *PendingBeneficiaryChange
+}
+
+// A set of groups of methods which a control address may invoke.
+// The owner and worker may always invoke all of them.
+type ControlPermissions uint64
+
+const (
+	// SubmitWindowedPoSt and declarations of faults and recoveries.
+	PermitWindowPoSt ControlPermissions = 1 << iota
+	// Pre-committing, proving and re-committing sectors.
+	PermitSealing
+	// Extending, terminating and compacting sectors and partitions.
+	PermitSectorManagement
+	// Changing the peer ID and multiaddresses.
+	PermitPeerInfo
+	// Repaying fee debt.
+	PermitRepayDebt
+
+	AllControlPermissions = PermitWindowPoSt | PermitSealing | PermitSectorManagement | PermitPeerInfo | PermitRepayDebt
+)
+
+// Returns permissions for a number of control addresses, each permitted all methods.
+func UnrestrictedControlPermissions(count int) []ControlPermissions {
+	permissions := make([]ControlPermissions, count)
+	for i := range permissions {
+		permissions[i] = AllControlPermissions
+	}
+	return permissions
+}
+
+// Returns the control addresses which have all of some permissions.
+// A control address without an entry in ControlPermissions has none.
+func (info *MinerInfo) PermittedControlAddresses(required ControlPermissions) []addr.Address {
+	permitted := make([]addr.Address, 0, len(info.ControlAddresses))
+	for i, a := range info.ControlAddresses {
+		if i < len(info.ControlPermissions) && info.ControlPermissions[i]&required == required {
+			permitted = append(permitted, a)
+		}
+	}
+	return permitted
 }
 
 type WorkerKeyChange struct {
@@ -133,6 +204,23 @@ type WorkerKeyChange struct {
 	EffectiveAt abi.ChainEpoch
 }
 
+type BeneficiaryTerm struct {
+	// Total amount the beneficiary may withdraw.
+	Quota abi.TokenAmount
+	// Amount the beneficiary has withdrawn so far.
+	UsedQuota abi.TokenAmount
+	// Epoch after which the beneficiary may no longer withdraw.
+	Expiration abi.ChainEpoch
+}
+
+type PendingBeneficiaryChange struct {
+	NewBeneficiary        addr.Address // Must be an ID address
+	NewQuota              abi.TokenAmount
+	NewExpiration         abi.ChainEpoch
+	ApprovedByBeneficiary bool
+	ApprovedByNominee     bool
+}
+
 // Information provided by a miner when pre-committing a sector.
 type SectorPreCommitInfo struct {
 	SealProof       abi.RegisteredSealProof
@@ -163,7 +251,7 @@ type SectorOnChainInfo struct {
 	SealProof             abi.RegisteredSealProof // The seal proof type implies the PoSt proof/s
 	SealedCID             cid.Cid                 // CommR
 	DealIDs               []abi.DealID
-	Activation            abi.ChainEpoch  // Epoch during which the sector proof was accepted
+	Activation            abi.ChainEpoch  // Epoch during which the sector proof was accepted, or the sector last re-committed
 	Expiration            abi.ChainEpoch  // Epoch during which the sector expires
 	DealWeight            abi.DealWeight  // Integral of active deals over sector lifetime
 	VerifiedDealWeight    abi.DealWeight  // Integral of active verified deals over sector lifetime
@@ -250,7 +338,8 @@ func ConstructMinerInfo(owner, worker addr.Address, controlAddrs []addr.Address,
 		Owner:                      owner,
 		Worker:                     worker,
 		ControlAddresses:           controlAddrs,
-		PendingWorkerKey:           nil,
+		ControlPermissions:         UnrestrictedControlPermissions(len(controlAddrs)),
+		PendingWorkerKeys:          nil,
 		PeerId:                     pid,
 		Multiaddrs:                 multiAddrs,
 		WindowPoStProofType:        windowPoStProofType,
@@ -258,9 +347,24 @@ func ConstructMinerInfo(owner, worker addr.Address, controlAddrs []addr.Address,
 		WindowPoStPartitionSectors: partitionSectors,
 		ConsensusFaultElapsed:      abi.ChainEpoch(-1),
 		PendingOwnerAddress:        nil,
+		Beneficiary:                owner,
+		BeneficiaryTerm: BeneficiaryTerm{
+			Quota:      big.Zero(),
+			UsedQuota:  big.Zero(),
+			Expiration: 0,
+		},
+		PendingBeneficiaryTerm: nil,
 	}, nil
 }
 
+// Returns the amount the beneficiary may still withdraw at an epoch, which is zero once the term has expired.
+func (t *BeneficiaryTerm) Available(epoch abi.ChainEpoch) abi.TokenAmount {
+	if epoch > t.Expiration {
+		return big.Zero()
+	}
+	return big.Max(big.Sub(t.Quota, t.UsedQuota), big.Zero())
+}
+
 func (st *State) GetInfo(store adt.Store) (*MinerInfo, error) {
 	var info MinerInfo
 	if err := store.Get(store.Context(), st.Info, &info); err != nil {
@@ -375,6 +479,39 @@ func (st *State) GetPrecommittedSector(store adt.Store, sectorNo abi.SectorNumbe
 	return &info, found, nil
 }
 
+// Returns whether any sector is pre-committed.
+func (st *State) HasPrecommittedSectors(store adt.Store) (bool, error) {
+	precommitted, err := adt.AsMap(store, st.PreCommittedSectors, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return false, err
+	}
+	stopErr := xerrors.New("stop error")
+	if err := precommitted.ForEach(nil, func(string) error {
+		return stopErr
+	}); err == stopErr {
+		return true, nil
+	} else if err != nil {
+		return false, xerrors.Errorf("failed to iterate pre-commitments: %w", err)
+	}
+	return false, nil
+}
+
+// Returns the number of live sectors, including faulty and unproven sectors, across all deadlines.
+func (st *State) LiveSectorCount(store adt.Store) (uint64, error) {
+	deadlines, err := st.LoadDeadlines(store)
+	if err != nil {
+		return 0, err
+	}
+	count := uint64(0)
+	if err := deadlines.ForEach(store, func(_ uint64, dl *Deadline) error {
+		count += dl.LiveSectors
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 // Load all precommits or fail trying
 func (st *State) GetAllPrecommittedSectors(store adt.Store, sectorNos bitfield.BitField) ([]*SectorPreCommitOnChainInfo, error) {
 	precommits := make([]*SectorPreCommitOnChainInfo, 0)
@@ -768,11 +905,16 @@ func (st *State) AddInitialPledge(amount abi.TokenAmount) error {
 	return nil
 }
 
-// AddLockedFunds first vests and unlocks the vested funds AND then locks the given funds in the vesting table.
-func (st *State) AddLockedFunds(store adt.Store, currEpoch abi.ChainEpoch, vestingSum abi.TokenAmount, spec *VestSpec) (vested abi.TokenAmount, err error) {
+// AddLockedFunds first vests and unlocks the vested funds AND then locks the given funds in the vesting table,
+// to vest according to a policy.
+func (st *State) AddLockedFunds(store adt.Store, currEpoch abi.ChainEpoch, vestingSum abi.TokenAmount, policy VestingPolicy) (vested abi.TokenAmount, err error) {
 	if vestingSum.LessThan(big.Zero()) {
 		return big.Zero(), xerrors.Errorf("negative amount to lock %s", vestingSum)
 	}
+	schedule := policy.Schedule(currEpoch, vestingSum, st.ProvingPeriodStart)
+	if err := checkVestingSchedule(schedule, vestingSum); err != nil {
+		return big.Zero(), xerrors.Errorf("invalid vesting schedule: %w", err)
+	}
 
 	vestingFunds, err := st.LoadVestingFunds(store)
 	if err != nil {
@@ -787,7 +929,7 @@ func (st *State) AddLockedFunds(store adt.Store, currEpoch abi.ChainEpoch, vesti
 	}
 
 	// add locked funds now
-	vestingFunds.addLockedFunds(currEpoch, vestingSum, st.ProvingPeriodStart, spec)
+	vestingFunds.addLockedFunds(schedule)
 	st.LockedFunds = big.Add(st.LockedFunds, vestingSum)
 
 	// save the updated vesting table state
@@ -1193,6 +1335,17 @@ func (st *State) AdvanceDeadline(store adt.Store, currEpoch abi.ChainEpoch) (*Ad
 		if !noEarlyTerminations {
 			st.EarlyTerminations.Set(dlInfo.Index)
 		}
+
+		onTimeCount, err := expired.OnTimeSectors.Count()
+		if err != nil {
+			return nil, xerrors.Errorf("failed to count on-time expirations: %w", err)
+		}
+		earlyCount, err := expired.EarlySectors.Count()
+		if err != nil {
+			return nil, xerrors.Errorf("failed to count early terminations: %w", err)
+		}
+		st.Stats.ExpiredOnTime += onTimeCount
+		st.Stats.TerminatedEarly += earlyCount
 	}
 
 	// Save new deadline state.
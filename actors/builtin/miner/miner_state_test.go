@@ -462,6 +462,46 @@ func TestVestingFunds_AddLockedFunds(t *testing.T) {
 		assert.Zero(t, harness.s.LockedFunds.Int64())
 		assert.True(t, harness.vestingFundsStoreEmpty())
 	})
+
+	t.Run("Vests according to a custom policy", func(t *testing.T) {
+		harness := constructStateHarness(t, abi.ChainEpoch(0))
+		vestStart := abi.ChainEpoch(10)
+		vestSum := abi.NewTokenAmount(100)
+
+		harness.addLockedFunds(vestStart, vestSum, cliffVesting{delay: 100})
+		assert.Equal(t, vestSum, harness.s.LockedFunds)
+
+		// Nothing vests before the cliff, then everything does.
+		assert.Equal(t, big.Zero(), harness.unlockVestedFunds(110))
+		assert.Equal(t, vestSum, harness.unlockVestedFunds(111))
+		assert.Zero(t, harness.s.LockedFunds.Int64())
+		assert.True(t, harness.vestingFundsStoreEmpty())
+	})
+
+	t.Run("Rejects a policy which doesn't vest the locked amount", func(t *testing.T) {
+		harness := constructStateHarness(t, abi.ChainEpoch(0))
+
+		_, err := harness.s.AddLockedFunds(harness.store, 10, abi.NewTokenAmount(100), shortVesting{})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid vesting schedule")
+		assert.Zero(t, harness.s.LockedFunds.Int64())
+	})
+}
+
+// Vests the whole locked amount at a single epoch.
+type cliffVesting struct {
+	delay abi.ChainEpoch
+}
+
+func (c cliffVesting) Schedule(currEpoch abi.ChainEpoch, vestingSum abi.TokenAmount, _ abi.ChainEpoch) []miner.VestingFund {
+	return []miner.VestingFund{{Epoch: currEpoch + c.delay, Amount: vestingSum}}
+}
+
+// Vests only half of the locked amount.
+type shortVesting struct{}
+
+func (shortVesting) Schedule(currEpoch abi.ChainEpoch, vestingSum abi.TokenAmount, _ abi.ChainEpoch) []miner.VestingFund {
+	return []miner.VestingFund{{Epoch: currEpoch + 1, Amount: big.Div(vestingSum, big.NewInt(2))}}
 }
 
 func TestVestingFunds_UnvestedFunds(t *testing.T) {
@@ -878,8 +918,8 @@ type stateHarness struct {
 // Vesting Store
 //
 
-func (h *stateHarness) addLockedFunds(epoch abi.ChainEpoch, sum abi.TokenAmount, spec *miner.VestSpec) {
-	_, err := h.s.AddLockedFunds(h.store, epoch, sum, spec)
+func (h *stateHarness) addLockedFunds(epoch abi.ChainEpoch, sum abi.TokenAmount, policy miner.VestingPolicy) {
+	_, err := h.s.AddLockedFunds(h.store, epoch, sum, policy)
 	require.NoError(h.t, err)
 }
 
@@ -979,12 +1019,13 @@ func constructStateHarness(t *testing.T, periodBoundary abi.ChainEpoch) *stateHa
 	info := miner.MinerInfo{
 		Owner:                      owner,
 		Worker:                     worker,
-		PendingWorkerKey:           nil,
+		PendingWorkerKeys:          nil,
 		PeerId:                     abi.PeerID("peer"),
 		Multiaddrs:                 testMultiaddrs,
 		WindowPoStProofType:        testWindowPoStProofType,
 		SectorSize:                 sectorSize,
 		WindowPoStPartitionSectors: partitionSectors,
+		Beneficiary:                owner,
 	}
 	infoCid, err := store.Put(context.Background(), &info)
 	require.NoError(t, err)
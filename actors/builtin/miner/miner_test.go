@@ -834,6 +834,217 @@ func TestWindowPost(t *testing.T) {
 		actor.checkState(rt)
 	})
 
+	t.Run("batched proofs of separate partitions are recorded and disputed", func(t *testing.T) {
+
+		actor := newHarness(t, periodOffset)
+		actor.setProofType(abi.RegisteredSealProof_StackedDrg2KiBV1_1)
+		rt := builderForHarness(actor).
+			WithEpoch(precommitEpoch).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		actor.constructAndVerify(rt)
+		store := rt.AdtStore()
+		// Commit more sectors than fit in one partition in every eligible deadline, overflowing to a second partition.
+		sectorsToCommit := ((miner.WPoStPeriodDeadlines() - 2) * actor.partitionSize) + 1
+		sectors := actor.commitAndProveSectors(rt, int(sectorsToCommit), defaultSectorExpiration, nil, true)
+		lastSector := sectors[len(sectors)-1]
+
+		st := getState(rt)
+		dlIdx, pIdx, err := st.FindSector(store, lastSector.SectorNumber)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), pIdx)
+		dlinfo := advanceToDeadline(rt, actor, dlIdx)
+
+		// Prove each partition with its own proof.
+		sectorsToProve := append([]*miner.SectorOnChainInfo{}, sectors[:actor.partitionSize]...)
+		sectorsToProve = append(sectorsToProve, lastSector)
+		pwr := miner.PowerForSectors(actor.sectorSize, sectorsToProve)
+		params := miner.SubmitWindowedPoStParams{
+			Deadline: dlinfo.Index,
+			Partitions: []miner.PoStPartition{
+				{Index: 0, Skipped: bitfield.New()},
+				{Index: 1, Skipped: bitfield.New()},
+			},
+			Proofs:           makePartitionPoStProofs(actor.windowPostProofType, 2),
+			ChainCommitEpoch: dlinfo.Challenge,
+			ChainCommitRand:  abi.Randomness("chaincommitment"),
+		}
+		// Without recoveries, the proofs are accepted optimistically and recorded together.
+		actor.submitWindowPoStRaw(rt, dlinfo, sectorsToProve, &params, &poStConfig{
+			expectedPowerDelta: pwr,
+		})
+		deadline := actor.getDeadline(rt, dlIdx)
+		assertBitfieldEquals(t, deadline.PartitionsPoSted, 0, 1)
+
+		advanceDeadline(rt, actor, &cronConfig{})
+		actor.checkState(rt)
+
+		deadline = actor.getDeadline(rt, dlIdx)
+		post := actor.getSubmittedProof(rt, deadline, 0)
+		assertBitfieldEquals(t, post.Partitions, 0, 1)
+		assert.Equal(t, params.Proofs, post.Proofs)
+
+		// Disputes verify the proof of each partition in a single batch.
+		actor.disputeWindowPoSt(rt, dlinfo, 0, sectorsToProve, nil)
+
+		expectedFee := miner.PledgePenaltyForInvalidWindowPoSt(actor.epochRewardSmooth, actor.epochQAPowerSmooth, pwr.QA)
+		actor.disputeWindowPoSt(rt, dlinfo, 0, sectorsToProve, &poStDisputeResult{
+			expectedPowerDelta:  pwr.Neg(),
+			expectedPenalty:     expectedFee,
+			expectedReward:      miner.BaseRewardForDisputedWindowPoSt(),
+			expectedPledgeDelta: big.Zero(),
+		})
+	})
+
+	t.Run("dispute of some sectors in a partition", func(t *testing.T) {
+		actor := newHarness(t, periodOffset)
+		actor.setProofType(abi.RegisteredSealProof_StackedDrg2KiBV1_1)
+		rt := builderForHarness(actor).
+			WithEpoch(precommitEpoch).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		actor.constructAndVerify(rt)
+		store := rt.AdtStore()
+		// Commit more sectors than fit in one partition in every eligible deadline, overflowing to a second partition.
+		sectorsToCommit := ((miner.WPoStPeriodDeadlines() - 2) * actor.partitionSize) + 1
+		sectors := actor.commitAndProveSectors(rt, int(sectorsToCommit), defaultSectorExpiration, nil, true)
+		lastSector := sectors[len(sectors)-1]
+
+		st := getState(rt)
+		dlIdx, pIdx, err := st.FindSector(store, lastSector.SectorNumber)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), pIdx)
+		dlinfo := advanceToDeadline(rt, actor, dlIdx)
+
+		sectorsToProve := append([]*miner.SectorOnChainInfo{}, sectors[:actor.partitionSize]...)
+		sectorsToProve = append(sectorsToProve, lastSector)
+		actor.submitWindowPoSt(rt, dlinfo, []miner.PoStPartition{
+			{Index: 0, Skipped: bitfield.New()},
+			{Index: 1, Skipped: bitfield.New()},
+		}, sectorsToProve, &poStConfig{
+			expectedPowerDelta: miner.PowerForSectors(actor.sectorSize, sectorsToProve),
+		})
+		advanceDeadline(rt, actor, &cronConfig{})
+
+		// Disputed sectors must be active in a partition proven by the PoSt.
+		disputed := sectors[:2]
+		disputedNos := bf(uint64(disputed[0].SectorNumber), uint64(disputed[1].SectorNumber))
+		for _, tc := range []struct {
+			partition uint64
+			sectors   bitfield.BitField
+			message   string
+		}{
+			{1, disputedNos, "not all active in partition 1"},
+			{2, disputedNos, "partition 2 was not proven"},
+			{0, bf(), "no sectors disputed"},
+		} {
+			rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+			rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+			if tc.message != "no sectors disputed" {
+				expectQueryNetworkInfo(rt, actor)
+			}
+			rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, tc.message, func() {
+				rt.Call(actor.a.DisputeWindowedPoStSectors, &miner.DisputeWindowedPoStSectorsParams{
+					Deadline:  dlinfo.Index,
+					PoStIndex: 0,
+					Partition: tc.partition,
+					Sectors:   tc.sectors,
+				})
+			})
+			rt.Verify()
+		}
+
+		// A dispute of valid proofs fails.
+		actor.disputeWindowPoStSectors(rt, dlinfo, 0, 0, disputedNos, sectorsToProve, nil)
+
+		// A successful dispute penalises only the disputed sectors, which become faulty.
+		pwr := miner.PowerForSectors(actor.sectorSize, disputed)
+		expectedFee := miner.PledgePenaltyForInvalidWindowPoSt(actor.epochRewardSmooth, actor.epochQAPowerSmooth, pwr.QA)
+		actor.disputeWindowPoStSectors(rt, dlinfo, 0, 0, disputedNos, sectorsToProve, &poStDisputeResult{
+			expectedPowerDelta:  pwr.Neg(),
+			expectedPenalty:     expectedFee,
+			expectedReward:      miner.BaseRewardForDisputedWindowPoSt(),
+			expectedPledgeDelta: big.Zero(),
+		})
+		deadline := actor.getDeadline(rt, dlIdx)
+		assertBitfieldEquals(t, actor.getPartition(rt, deadline, 0).Faults, uint64(disputed[0].SectorNumber), uint64(disputed[1].SectorNumber))
+		assertEmptyBitfield(t, actor.getPartition(rt, deadline, 1).Faults)
+		actor.checkState(rt)
+	})
+
+	t.Run("invalid batched proofs", func(t *testing.T) {
+
+		actor := newHarness(t, periodOffset)
+		actor.setProofType(abi.RegisteredSealProof_StackedDrg2KiBV1_1)
+		rt := builderForHarness(actor).
+			WithEpoch(precommitEpoch).
+			WithBalance(bigBalance, big.Zero()).
+			Build(t)
+		actor.constructAndVerify(rt)
+		sectorsToCommit := ((miner.WPoStPeriodDeadlines() - 2) * actor.partitionSize) + 1
+		sectors := actor.commitAndProveSectors(rt, int(sectorsToCommit), defaultSectorExpiration, nil, true)
+		lastSector := sectors[len(sectors)-1]
+
+		st := getState(rt)
+		dlIdx, _, err := st.FindSector(rt.AdtStore(), lastSector.SectorNumber)
+		require.NoError(t, err)
+		dlinfo := advanceToDeadline(rt, actor, dlIdx)
+		sectorsToProve := append([]*miner.SectorOnChainInfo{}, sectors[:actor.partitionSize]...)
+		sectorsToProve = append(sectorsToProve, lastSector)
+
+		submit := func(partitions []miner.PoStPartition, proofs []proof.PoStProof) {
+			params := miner.SubmitWindowedPoStParams{
+				Deadline:         dlinfo.Index,
+				Partitions:       partitions,
+				Proofs:           proofs,
+				ChainCommitEpoch: dlinfo.Challenge,
+				ChainCommitRand:  abi.Randomness("chaincommitment"),
+			}
+			actor.submitWindowPoStRaw(rt, dlinfo, sectorsToProve, &params, nil)
+		}
+		bothPartitions := []miner.PoStPartition{
+			{Index: 0, Skipped: bitfield.New()},
+			{Index: 1, Skipped: bitfield.New()},
+		}
+
+		// More proofs than partitions.
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "got 3 for 2 partitions", func() {
+			submit(bothPartitions, makePartitionPoStProofs(actor.windowPostProofType, 3))
+		})
+		rt.Reset()
+
+		// Partitions out of order.
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "must be in increasing order", func() {
+			submit([]miner.PoStPartition{bothPartitions[1], bothPartitions[0]}, makePartitionPoStProofs(actor.windowPostProofType, 2))
+		})
+		rt.Reset()
+
+		// A per-partition proof too large.
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "expected proof 1 to be smaller", func() {
+			proofs := makePartitionPoStProofs(actor.windowPostProofType, 2)
+			proofs[1].ProofBytes = make([]byte, 192+1)
+			submit(bothPartitions, proofs)
+		})
+		rt.Reset()
+
+		// More proofs than the batch limit.
+		func() {
+			defer func(p miner.Policy) { miner.CurrentMinerPolicy = p }(miner.CurrentMinerPolicy)
+			miner.CurrentMinerPolicy.MaxWindowPoStBatchSize = 1
+			rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "too many proofs 2, batch limit 1", func() {
+				submit(bothPartitions, makePartitionPoStProofs(actor.windowPostProofType, 2))
+			})
+			rt.Reset()
+		}()
+
+		// Separate proofs before the network version verifying them in a batch.
+		rt.SetNetworkVersion(miner.BatchWindowPoStNetworkVersion - 1)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "not accepted before network version", func() {
+			submit(bothPartitions, makePartitionPoStProofs(actor.windowPostProofType, 2))
+		})
+		rt.Reset()
+	})
+
 	t.Run("successful recoveries recover power", func(t *testing.T) {
 		actor := newHarness(t, periodOffset)
 		actor.setProofType(abi.RegisteredSealProof_StackedDrg2KiBV1_1)
@@ -1340,7 +1551,11 @@ func TestDeadlineCron(t *testing.T) {
 		})
 		st = getState(rt)
 		assert.False(t, st.DeadlineCronActive)
-		actor.checkState(rt)
+
+		// the expiration is counted, and reported in the state summary
+		summary, msgs := miner.CheckStateInvariants(st, rt.AdtStore(), rt.Balance())
+		assert.True(t, msgs.IsEmpty(), strings.Join(msgs.Messages(), "\n"))
+		assert.Equal(t, miner.SectorStats{ExpiredOnTime: 1}, summary.Stats)
 	})
 
 	t.Run("sector expires and repays fee debt", func(t *testing.T) {
@@ -1454,6 +1669,11 @@ func TestDeadlineCron(t *testing.T) {
 		// recorded faulty power is unchanged
 		deadline = actor.getDeadline(rt, dlIdx)
 		assert.True(t, totalPower.Equals(deadline.FaultyPower))
+
+		// each sector charged for a continued fault is counted faulty for a proving period
+		st = getState(rt)
+		assert.Equal(t, uint64(len(allSectors))*uint64(miner.WPoStProvingPeriod()), st.Stats.EstimatedFaultySectorEpochs)
+		assert.Zero(t, st.Stats.TerminatedEarly)
 		checkDeadlineInvariants(t, rt.AdtStore(), deadline, st.QuantSpecForDeadline(dlIdx), actor.sectorSize, allSectors)
 		actor.checkState(rt)
 	})
@@ -1805,6 +2025,104 @@ func TestDeclareRecoveries(t *testing.T) {
 	})
 }
 
+func TestDeclareFaultsAndRecoveries(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+
+	t.Run("declares faults and recoveries in one message", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		sectors := actor.commitAndProveSectors(rt, 2, defaultSectorExpiration, nil, true)
+		advanceAndSubmitPoSts(rt, actor, sectors...)
+
+		// The first sector is already faulty.
+		actor.declareFaults(rt, sectors[0])
+
+		st := getState(rt)
+		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), sectors[0].SectorNumber)
+		require.NoError(t, err)
+
+		// Declare the second sector faulty and the first recovered.
+		params := &miner.DeclareFaultsAndRecoveriesParams{
+			Faults: []miner.FaultDeclaration{{
+				Deadline: dlIdx, Partition: pIdx, Sectors: bf(uint64(sectors[1].SectorNumber)),
+			}},
+			Recoveries: []miner.RecoveryDeclaration{{
+				Deadline: dlIdx, Partition: pIdx, Sectors: bf(uint64(sectors[0].SectorNumber)),
+			}},
+		}
+		actor.declareFaultsAndRecoveries(rt, params, miner.PowerForSectors(actor.sectorSize, sectors[1:]).Neg(), big.Zero())
+
+		dl := actor.getDeadline(rt, dlIdx)
+		assert.True(t, dl.FaultyPower.Equals(miner.PowerForSectors(actor.sectorSize, sectors)))
+		p, err := dl.LoadPartition(rt.AdtStore(), pIdx)
+		require.NoError(t, err)
+		assertBitfieldEquals(t, p.Faults, uint64(sectors[0].SectorNumber), uint64(sectors[1].SectorNumber))
+		assertBitfieldEquals(t, p.Recoveries, uint64(sectors[0].SectorNumber))
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects sectors declared both faulty and recovered", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		sectors := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
+		advanceAndSubmitPoSts(rt, actor, sectors...)
+
+		st := getState(rt)
+		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), sectors[0].SectorNumber)
+		require.NoError(t, err)
+
+		params := &miner.DeclareFaultsAndRecoveriesParams{
+			Faults: []miner.FaultDeclaration{{
+				Deadline: dlIdx, Partition: pIdx, Sectors: bf(uint64(sectors[0].SectorNumber)),
+			}},
+			Recoveries: []miner.RecoveryDeclaration{{
+				Deadline: dlIdx, Partition: pIdx, Sectors: bf(uint64(sectors[0].SectorNumber)),
+			}},
+		}
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "declared both faulty and recovered", func() {
+			rt.Call(actor.a.DeclareFaultsAndRecoveries, params)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("recovery fails during active consensus fault", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		sectors := actor.commitAndProveSectors(rt, 2, defaultSectorExpiration, nil, true)
+
+		actor.reportConsensusFault(rt, addr.TestAddress, &runtime.ConsensusFault{
+			Target: actor.receiver,
+			Epoch:  rt.Epoch() - 1,
+			Type:   runtime.ConsensusFaultDoubleForkMining,
+		})
+		advanceAndSubmitPoSts(rt, actor, sectors...)
+		actor.declareFaults(rt, sectors[0])
+
+		st := getState(rt)
+		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), sectors[0].SectorNumber)
+		require.NoError(t, err)
+
+		params := &miner.DeclareFaultsAndRecoveriesParams{
+			Faults: []miner.FaultDeclaration{{
+				Deadline: dlIdx, Partition: pIdx, Sectors: bf(uint64(sectors[1].SectorNumber)),
+			}},
+			Recoveries: []miner.RecoveryDeclaration{{
+				Deadline: dlIdx, Partition: pIdx, Sectors: bf(uint64(sectors[0].SectorNumber)),
+			}},
+		}
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(append(actor.controlAddrs, actor.owner, actor.worker)...)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "recovery not allowed during active consensus fault", func() {
+			rt.Call(actor.a.DeclareFaultsAndRecoveries, params)
+		})
+		actor.checkState(rt)
+	})
+}
+
 func TestExtendSectorExpiration(t *testing.T) {
 	periodOffset := abi.ChainEpoch(100)
 	actor := newHarness(t, periodOffset)
@@ -2117,66 +2435,333 @@ func TestExtendSectorExpiration(t *testing.T) {
 	})
 }
 
-func TestTerminateSectors(t *testing.T) {
+func TestExtendSectorExpirationBatch(t *testing.T) {
 	periodOffset := abi.ChainEpoch(100)
 	actor := newHarness(t, periodOffset)
 	builder := builderForHarness(actor).
-		WithBalance(big.Mul(big.NewInt(1e18), big.NewInt(200000)), big.Zero())
+		WithEpoch(abi.ChainEpoch(1)).
+		WithBalance(bigBalance, big.Zero())
 
-	t.Run("removes sector with correct accounting", func(t *testing.T) {
+	// Returns the sectors of each partition in each deadline.
+	sectorsByPartition := func(t *testing.T, rt *mock.Runtime) map[uint64][][]uint64 {
+		st := getState(rt)
+		deadlines, err := st.LoadDeadlines(rt.AdtStore())
+		require.NoError(t, err)
+		result := map[uint64][][]uint64{}
+		require.NoError(t, deadlines.ForEach(rt.AdtStore(), func(dlIdx uint64, dl *miner.Deadline) error {
+			partitions, err := dl.PartitionsArray(rt.AdtStore())
+			require.NoError(t, err)
+			var partition miner.Partition
+			return partitions.ForEach(&partition, func(partIdx int64) error {
+				sectorNos, err := partition.Sectors.All(miner.AddressedSectorsMax())
+				require.NoError(t, err)
+				result[dlIdx] = append(result[dlIdx], sectorNos)
+				return nil
+			})
+		}))
+		return result
+	}
+
+	t.Run("extends sectors across partitions", func(t *testing.T) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
-		rt.SetEpoch(abi.ChainEpoch(1))
-		sectorInfo := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
-		sector := sectorInfo[0]
-		advanceAndSubmitPoSts(rt, actor, sector)
 
-		// A miner will pay the minimum of termination fee and locked funds. Add some locked funds to ensure
-		// correct fee calculation is used.
-		actor.applyRewards(rt, bigRewards, big.Zero())
-		st := getState(rt)
-		initialLockedFunds := st.LockedFunds
+		// Enough sectors to fill more than one partition.
+		sectorCount := int(actor.partitionSize) + 1
+		sectorInfos := actor.commitAndProveSectors(rt, sectorCount, defaultSectorExpiration, nil, true)
+		advanceAndSubmitPoSts(rt, actor, sectorInfos...)
 
-		sectorSize, err := sector.SealProof.SectorSize()
-		require.NoError(t, err)
-		sectorPower := miner.QAPowerForSector(sectorSize, sector)
-		dayReward := miner.ExpectedRewardForPower(actor.epochRewardSmooth, actor.epochQAPowerSmooth, sectorPower, builtin.EpochsInDay())
-		twentyDayReward := miner.ExpectedRewardForPower(actor.epochRewardSmooth, actor.epochQAPowerSmooth, sectorPower, miner.InitialPledgeProjectionPeriod())
-		sectorAge := rt.Epoch() - sector.Activation
-		expectedFee := miner.PledgePenaltyForTermination(dayReward, sectorAge, twentyDayReward, actor.epochQAPowerSmooth, sectorPower, actor.epochRewardSmooth, big.Zero(), 0)
+		newExpiration := sectorInfos[0].Expiration + 42*miner.WPoStProvingPeriod()
 
-		sectors := bf(uint64(sector.SectorNumber))
-		actor.terminateSectors(rt, sectors, expectedFee)
+		// Extend all odd-numbered sectors, with one group per deadline.
+		var params miner.ExtendSectorExpirationBatchParams
+		partitionCount := 0
+		byPartition := sectorsByPartition(t, rt)
+		for dlIdx := uint64(0); dlIdx < miner.WPoStPeriodDeadlines(); dlIdx++ {
+			partitions := byPartition[dlIdx]
+			if len(partitions) == 0 {
+				continue
+			}
+			var partIdxs, sectorNos []uint64
+			for partIdx, partitionSectors := range partitions {
+				partIdxs = append(partIdxs, uint64(partIdx))
+				for _, sno := range partitionSectors {
+					if sno%2 == 1 {
+						sectorNos = append(sectorNos, sno)
+					}
+				}
+			}
+			partitionCount += len(partIdxs)
+			params.Groups = append(params.Groups, miner.ExpirationExtensionGroup{
+				Deadline:      dlIdx,
+				Partitions:    bf(partIdxs...),
+				Sectors:       bf(sectorNos...),
+				NewExpiration: newExpiration,
+			})
+		}
+		require.GreaterOrEqual(t, partitionCount, 2, "test error: this test should touch more than one partition")
 
-		{
-			st := getState(rt)
+		actor.extendSectorsBatch(rt, &params)
 
-			// expect sector to be marked as terminated and the early termination queue to be empty (having been fully processed)
-			_, partition := actor.findSector(rt, sector.SectorNumber)
-			terminated, err := partition.Terminated.IsSet(uint64(sector.SectorNumber))
-			require.NoError(t, err)
-			assert.True(t, terminated)
-			result, _, err := partition.PopEarlyTerminations(rt.AdtStore(), 1000)
+		st := getState(rt)
+		for _, info := range sectorInfos {
+			sector, found, err := st.GetSector(rt.AdtStore(), info.SectorNumber)
 			require.NoError(t, err)
-			assert.True(t, result.IsEmpty())
-
-			// expect fee to have been unlocked and burnt
-			assert.Equal(t, big.Sub(initialLockedFunds, expectedFee), st.LockedFunds)
-
-			// expect pledge requirement to have been decremented
-			assert.Equal(t, big.Zero(), st.InitialPledge)
+			require.True(t, found)
+			if info.SectorNumber%2 == 1 {
+				assert.Equal(t, newExpiration, sector.Expiration)
+			} else {
+				assert.Equal(t, info.Expiration, sector.Expiration)
+			}
 		}
+
 		actor.checkState(rt)
 	})
 
-	t.Run("cannot terminate a sector when the challenge window is open", func(t *testing.T) {
+	t.Run("rejects sectors outside the declared partitions", func(t *testing.T) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
-		rt.SetEpoch(abi.ChainEpoch(1))
-		sectorInfo := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
-		sector := sectorInfo[0]
 
-		st := getState(rt)
+		sectorInfos := actor.commitAndProveSectors(rt, 2, defaultSectorExpiration, nil, true)
+		advanceAndSubmitPoSts(rt, actor, sectorInfos...)
+
+		st := getState(rt)
+		dlIdx, partIdx, err := st.FindSector(rt.AdtStore(), sectorInfos[0].SectorNumber)
+		require.NoError(t, err)
+
+		params := &miner.ExtendSectorExpirationBatchParams{Groups: []miner.ExpirationExtensionGroup{{
+			Deadline:      dlIdx,
+			Partitions:    bf(partIdx),
+			Sectors:       bf(uint64(sectorInfos[0].SectorNumber), 1000),
+			NewExpiration: sectorInfos[0].Expiration + miner.WPoStProvingPeriod(),
+		}}}
+
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(append(actor.controlAddrs, actor.owner, actor.worker)...)
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "not in its partitions", func() {
+			rt.Call(actor.a.ExtendSectorExpirationBatch, params)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects a partition with none of the sectors", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		sectorInfos := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
+		advanceAndSubmitPoSts(rt, actor, sectorInfos...)
+
+		st := getState(rt)
+		dlIdx, partIdx, err := st.FindSector(rt.AdtStore(), sectorInfos[0].SectorNumber)
+		require.NoError(t, err)
+
+		params := &miner.ExtendSectorExpirationBatchParams{Groups: []miner.ExpirationExtensionGroup{{
+			Deadline:      dlIdx,
+			Partitions:    bf(partIdx),
+			Sectors:       bf(),
+			NewExpiration: sectorInfos[0].Expiration + miner.WPoStProvingPeriod(),
+		}}}
+
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(append(actor.controlAddrs, actor.owner, actor.worker)...)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "declares no sectors", func() {
+			rt.Call(actor.a.ExtendSectorExpirationBatch, params)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects too many partitions", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		partIdxs := make([]uint64, miner.AddressedPartitionsMax+1)
+		for i := range partIdxs {
+			partIdxs[i] = uint64(i)
+		}
+		params := &miner.ExtendSectorExpirationBatchParams{Groups: []miner.ExpirationExtensionGroup{{
+			Deadline:      0,
+			Partitions:    bf(partIdxs...),
+			Sectors:       bf(1),
+			NewExpiration: defaultSectorExpiration,
+		}}}
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "too many partitions", func() {
+			rt.Call(actor.a.ExtendSectorExpirationBatch, params)
+		})
+	})
+}
+
+func TestRecommitSector(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+
+	commitSector := func(t *testing.T, rt *mock.Runtime) (*miner.SectorOnChainInfo, uint64, uint64) {
+		actor.constructAndVerify(rt)
+		sector := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)[0]
+		advanceAndSubmitPoSts(rt, actor, sector)
+		st := getState(rt)
+		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), sector.SectorNumber)
+		require.NoError(t, err)
+		return actor.getSector(rt, sector.SectorNumber), dlIdx, pIdx
+	}
+
+	t.Run("resets activation and extends expiration", func(t *testing.T) {
+		rt := builder.Build(t)
+		sector, dlIdx, pIdx := commitSector(t, rt)
+
+		rt.SetEpoch(rt.Epoch() + 10*miner.WPoStProvingPeriod())
+		newExpiration := sector.Expiration + 10*miner.WPoStProvingPeriod()
+		actor.recommitSector(rt, sector, dlIdx, pIdx, newExpiration)
+
+		recommitted := actor.getSector(rt, sector.SectorNumber)
+		assert.Equal(t, rt.Epoch(), recommitted.Activation)
+		assert.Equal(t, newExpiration, recommitted.Expiration)
+		assert.Equal(t, rt.Epoch()-sector.Activation, recommitted.ReplacedSectorAge)
+		assert.Equal(t, sector.ExpectedDayReward, recommitted.ReplacedDayReward)
+		assert.True(t, recommitted.InitialPledge.GreaterThanEqual(sector.InitialPledge))
+		assert.Equal(t, recommitted.InitialPledge, getState(rt).InitialPledge)
+		actor.checkState(rt)
+	})
+
+	t.Run("permits a lifetime beyond the seal proof maximum from the original activation", func(t *testing.T) {
+		rt := builder.Build(t)
+		sector, dlIdx, pIdx := commitSector(t, rt)
+
+		maxLifetime, err := builtin.SealProofSectorMaximumLifetime(sector.SealProof)
+		require.NoError(t, err)
+
+		// extend sector until just below the maximum lifetime
+		rt.SetEpoch(sector.Expiration)
+		extension := miner.MinSectorExpiration()
+		expiration := sector.Expiration + extension
+		for ; expiration-sector.Activation < maxLifetime; expiration += extension {
+			actor.extendSectors(rt, &miner.ExtendSectorExpirationParams{
+				Extensions: []miner.ExpirationExtension{{
+					Deadline:      dlIdx,
+					Partition:     pIdx,
+					Sectors:       bf(uint64(sector.SectorNumber)),
+					NewExpiration: expiration,
+				}},
+			})
+			rt.SetEpoch(expiration)
+		}
+
+		// a re-committed sector's lifetime runs from the re-commitment
+		actor.recommitSector(rt, actor.getSector(rt, sector.SectorNumber), dlIdx, pIdx, expiration)
+		assert.Equal(t, expiration, actor.getSector(rt, sector.SectorNumber).Expiration)
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects expiration beyond maximum commitment", func(t *testing.T) {
+		rt := builder.Build(t)
+		sector, dlIdx, pIdx := commitSector(t, rt)
+
+		maxCommitment, err := builtin.SealProofSectorMaximumCommitment(sector.SealProof)
+		require.NoError(t, err)
+		newExpiration := rt.Epoch() + maxCommitment + miner.WPoStProvingPeriod()
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "past current epoch", func() {
+			actor.recommitSector(rt, sector, dlIdx, pIdx, newExpiration)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects stale challenge randomness", func(t *testing.T) {
+		rt := builder.Build(t)
+		sector, dlIdx, pIdx := commitSector(t, rt)
+
+		params := &miner.RecommitSectorParams{
+			SectorNumber:     sector.SectorNumber,
+			Deadline:         dlIdx,
+			Partition:        pIdx,
+			SealRandEpoch:    0,
+			InteractiveEpoch: rt.Epoch() - miner.ChainFinality() - 1,
+			NewExpiration:    sector.Expiration,
+			Proof:            make([]byte, 192),
+		}
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "interactive epoch", func() {
+			rt.Call(actor.a.RecommitSector, params)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects invalid proof", func(t *testing.T) {
+		rt := builder.Build(t)
+		sector, dlIdx, pIdx := commitSector(t, rt)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "invalid re-commit proof", func() {
+			actor.recommitSectorWithResult(rt, sector, dlIdx, pIdx, sector.Expiration, fmt.Errorf("invalid seal"))
+		})
+		actor.checkState(rt)
+	})
+}
+
+func TestTerminateSectors(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(big.Mul(big.NewInt(1e18), big.NewInt(200000)), big.Zero())
+
+	t.Run("removes sector with correct accounting", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		rt.SetEpoch(abi.ChainEpoch(1))
+		sectorInfo := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
+		sector := sectorInfo[0]
+		advanceAndSubmitPoSts(rt, actor, sector)
+
+		// A miner will pay the minimum of termination fee and locked funds. Add some locked funds to ensure
+		// correct fee calculation is used.
+		actor.applyRewards(rt, bigRewards, big.Zero())
+		st := getState(rt)
+		initialLockedFunds := st.LockedFunds
+
+		sectorSize, err := sector.SealProof.SectorSize()
+		require.NoError(t, err)
+		sectorPower := miner.QAPowerForSector(sectorSize, sector)
+		dayReward := miner.ExpectedRewardForPower(actor.epochRewardSmooth, actor.epochQAPowerSmooth, sectorPower, builtin.EpochsInDay())
+		twentyDayReward := miner.ExpectedRewardForPower(actor.epochRewardSmooth, actor.epochQAPowerSmooth, sectorPower, miner.InitialPledgeProjectionPeriod())
+		sectorAge := rt.Epoch() - sector.Activation
+		expectedFee := miner.PledgePenaltyForTermination(dayReward, sectorAge, twentyDayReward, actor.epochQAPowerSmooth, sectorPower, actor.epochRewardSmooth, big.Zero(), 0)
+
+		sectors := bf(uint64(sector.SectorNumber))
+		actor.terminateSectors(rt, sectors, expectedFee)
+
+		{
+			st := getState(rt)
+
+			// expect sector to be marked as terminated and the early termination queue to be empty (having been fully processed)
+			_, partition := actor.findSector(rt, sector.SectorNumber)
+			terminated, err := partition.Terminated.IsSet(uint64(sector.SectorNumber))
+			require.NoError(t, err)
+			assert.True(t, terminated)
+			result, _, err := partition.PopEarlyTerminations(rt.AdtStore(), 1000)
+			require.NoError(t, err)
+			assert.True(t, result.IsEmpty())
+
+			// expect fee to have been unlocked and burnt
+			assert.Equal(t, big.Sub(initialLockedFunds, expectedFee), st.LockedFunds)
+
+			// expect pledge requirement to have been decremented
+			assert.Equal(t, big.Zero(), st.InitialPledge)
+
+			// expect the termination to be counted
+			assert.Equal(t, miner.SectorStats{TerminatedEarly: 1}, st.Stats)
+		}
+		actor.checkState(rt)
+	})
+
+	t.Run("cannot terminate a sector when the challenge window is open", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		rt.SetEpoch(abi.ChainEpoch(1))
+		sectorInfo := actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
+		sector := sectorInfo[0]
+
+		st := getState(rt)
 		dlIdx, pIdx, err := st.FindSector(rt.AdtStore(), sector.SectorNumber)
 		require.NoError(t, err)
 
@@ -2334,6 +2919,88 @@ func TestRepayDebts(t *testing.T) {
 	})
 }
 
+func TestDeregister(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+
+	t.Run("deregisters miner without sectors", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		actor.deregister(rt)
+		st := getState(rt)
+		assert.False(t, st.DeadlineCronActive)
+		actor.checkState(rt)
+	})
+
+	t.Run("only owner may deregister", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetCaller(actor.worker, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(actor.owner)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.a.Deregister, nil)
+		})
+	})
+
+	t.Run("fails with fee debt", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		st := getState(rt)
+		st.FeeDebt = big.NewInt(1)
+		rt.ReplaceState(st)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "fee debt", func() {
+			actor.deregister(rt)
+		})
+	})
+
+	t.Run("fails with pledge", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "initial pledge", func() {
+			actor.deregister(rt)
+		})
+	})
+
+	t.Run("fails with pre-committed sector", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		deadline := actor.deadline(rt)
+		expiration := deadline.PeriodEnd() + abi.ChainEpoch(defaultSectorExpiration)*miner.WPoStProvingPeriod()
+		actor.preCommitSector(rt, actor.makePreCommit(100, rt.Epoch()-1, expiration, nil), preCommitConf{}, true)
+
+		// The pre-commitment is checked even without a deposit.
+		st := getState(rt)
+		st.PreCommitDeposits = big.Zero()
+		rt.ReplaceState(st)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "pre-committed sectors", func() {
+			actor.deregister(rt)
+		})
+	})
+
+	t.Run("fails with live sectors", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.commitAndProveSectors(rt, 1, defaultSectorExpiration, nil, true)
+
+		// Live sectors are checked even without pledge.
+		st := getState(rt)
+		st.InitialPledge = big.Zero()
+		rt.ReplaceState(st)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "1 live sectors", func() {
+			actor.deregister(rt)
+		})
+	})
+}
+
 func TestChangePeerID(t *testing.T) {
 	periodOffset := abi.ChainEpoch(100)
 	actor := newHarness(t, periodOffset)
@@ -2528,36 +3195,156 @@ func TestCompactPartitions(t *testing.T) {
 		actor.checkState(rt)
 	})
 
-	disputeEnd := periodOffset + miner.WPoStChallengeWindow() + miner.WPoStDisputeWindow() - 1
-	t.Run("compaction should be forbidden during the dispute window", func(t *testing.T) {
-		rt := builder.Build(t)
-		actor.constructAndVerify(rt)
+	disputeEnd := periodOffset + miner.WPoStChallengeWindow() + miner.WPoStDisputeWindow() - 1
+	t.Run("compaction should be forbidden during the dispute window", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetEpoch(disputeEnd)
+		rt.ExpectAbort(exitcode.ErrForbidden, func() {
+			actor.compactPartitions(rt, 0, bitfield.New())
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("compaction should be allowed following the dispute window", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetEpoch(disputeEnd + 1)
+		actor.compactPartitions(rt, 0, bitfield.New())
+		actor.checkState(rt)
+	})
+
+	t.Run("fails if partition count is above limit", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		// partition limit is 4 for the default construction
+		bf := bitfield.NewFromSet([]uint64{1, 2, 3, 4, 5})
+
+		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+			actor.compactPartitions(rt, 1, bf)
+		})
+		actor.checkState(rt)
+	})
+}
+
+func TestCompactTerminatedSectors(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+
+	// Commits and proves sectors in deadline 0 partition 0, then terminates some of them and waits until the
+	// deadline may be compacted.
+	setup := func(t *testing.T, count, terminated int) (*mock.Runtime, []*miner.SectorOnChainInfo) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		rt.SetEpoch(200)
+		info := actor.commitAndProveSectors(rt, count, defaultSectorExpiration, nil, true)
+		advanceAndSubmitPoSts(rt, actor, info...)
+
+		rt.SetEpoch(rt.Epoch() + 100)
+		actor.applyRewards(rt, bigRewards, big.Zero())
+		var sectorNos []uint64
+		for _, s := range info[:terminated] {
+			sectorNos = append(sectorNos, uint64(s.SectorNumber))
+		}
+		expectedFee := miner.PledgePenaltyForSectorsTermination(actor.sectorSize, rt.Epoch(), actor.epochRewardSmooth, actor.epochQAPowerSmooth, info[:terminated])
+		actor.terminateSectors(rt, bitfield.NewFromSet(sectorNos), expectedFee)
+
+		advanceToEpochWithCron(rt, actor, rt.Epoch()+miner.WPoStDisputeWindow())
+		return rt, info
+	}
+
+	assertSectors := func(rt *mock.Runtime, present, absent []*miner.SectorOnChainInfo) {
+		st := getState(rt)
+		for _, s := range present {
+			_, found, err := st.GetSector(rt.AdtStore(), s.SectorNumber)
+			require.NoError(t, err)
+			assert.True(t, found, "sector %d missing", s.SectorNumber)
+		}
+		for _, s := range absent {
+			_, found, err := st.GetSector(rt.AdtStore(), s.SectorNumber)
+			require.NoError(t, err)
+			assert.False(t, found, "sector %d not deleted", s.SectorNumber)
+			_, _, err = st.FindSector(rt.AdtStore(), s.SectorNumber)
+			assert.Error(t, err)
+		}
+	}
+
+	t.Run("removes terminated sectors and retains live sectors", func(t *testing.T) {
+		rt, info := setup(t, 4, 1)
+
+		actor.compactTerminatedSectors(rt, 0, bitfield.NewFromSet([]uint64{0}), miner.AddressedSectorsMax())
+		assertSectors(rt, info[1:], info[:1])
+
+		deadline, partition := actor.getDeadlineAndPartition(rt, 0, 0)
+		assert.Equal(t, uint64(3), deadline.TotalSectors)
+		assert.Equal(t, uint64(3), deadline.LiveSectors)
+		assertBitfieldEmpty(t, partition.Terminated)
+		actor.checkState(rt)
+	})
+
+	t.Run("removes terminated sectors incrementally", func(t *testing.T) {
+		rt, info := setup(t, 4, 3)
+
+		actor.compactTerminatedSectors(rt, 0, bitfield.NewFromSet([]uint64{0}), 2)
+		assertSectors(rt, info[2:], info[:2])
+		actor.checkState(rt)
+
+		actor.compactTerminatedSectors(rt, 0, bitfield.NewFromSet([]uint64{0}), 2)
+		assertSectors(rt, info[3:], info[:3])
+		deadline := actor.getDeadline(rt, 0)
+		assert.Equal(t, uint64(1), deadline.TotalSectors)
+		actor.checkState(rt)
+	})
+
+	t.Run("prunes expiration queue entries of a partition with no expiring sectors", func(t *testing.T) {
+		rt, info := setup(t, 2, 2)
+
+		deadline := actor.getDeadline(rt, 0)
+		queue, err := miner.LoadBitfieldQueue(rt.AdtStore(), deadline.ExpirationsEpochs, builtin.NoQuantization, miner.DeadlineExpirationAmtBitwidth)
+		require.NoError(t, err)
+		require.NotZero(t, queue.Length())
+
+		actor.compactTerminatedSectors(rt, 0, bitfield.NewFromSet([]uint64{0}), miner.AddressedSectorsMax())
+		assertSectors(rt, nil, info)
+
+		deadline = actor.getDeadline(rt, 0)
+		queue, err = miner.LoadBitfieldQueue(rt.AdtStore(), deadline.ExpirationsEpochs, builtin.NoQuantization, miner.DeadlineExpirationAmtBitwidth)
+		require.NoError(t, err)
+		assert.Zero(t, queue.Length())
+		actor.checkState(rt)
+	})
+
+	t.Run("fails if partition does not exist", func(t *testing.T) {
+		rt, _ := setup(t, 2, 1)
 
-		rt.SetEpoch(disputeEnd)
-		rt.ExpectAbort(exitcode.ErrForbidden, func() {
-			actor.compactPartitions(rt, 0, bitfield.New())
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "failed to find partition 1", func() {
+			actor.compactTerminatedSectors(rt, 0, bitfield.NewFromSet([]uint64{1}), miner.AddressedSectorsMax())
 		})
 		actor.checkState(rt)
 	})
 
-	t.Run("compaction should be allowed following the dispute window", func(t *testing.T) {
+	t.Run("fails if too many sectors requested", func(t *testing.T) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
 
-		rt.SetEpoch(disputeEnd + 1)
-		actor.compactPartitions(rt, 0, bitfield.New())
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "too many sectors", func() {
+			actor.compactTerminatedSectors(rt, 3, bitfield.New(), miner.AddressedSectorsMax()+1)
+		})
 		actor.checkState(rt)
 	})
 
-	t.Run("fails if partition count is above limit", func(t *testing.T) {
+	t.Run("fails if deadline is open for challenging", func(t *testing.T) {
 		rt := builder.Build(t)
 		actor.constructAndVerify(rt)
 
-		// partition limit is 4 for the default construction
-		bf := bitfield.NewFromSet([]uint64{1, 2, 3, 4, 5})
-
-		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
-			actor.compactPartitions(rt, 1, bf)
+		rt.SetEpoch(periodOffset)
+		rt.ExpectAbort(exitcode.ErrForbidden, func() {
+			actor.compactTerminatedSectors(rt, 0, bitfield.New(), miner.AddressedSectorsMax())
 		})
 		actor.checkState(rt)
 	})
@@ -2650,8 +3437,9 @@ func TestChangeWorkerAddress(t *testing.T) {
 
 		// assert change has been made in state
 		info := actor.getInfo(rt)
-		assert.Equal(t, info.PendingWorkerKey.NewWorker, newWorker)
-		assert.Equal(t, info.PendingWorkerKey.EffectiveAt, effectiveEpoch)
+		require.Len(t, info.PendingWorkerKeys, 1)
+		assert.Equal(t, info.PendingWorkerKeys[0].NewWorker, newWorker)
+		assert.Equal(t, info.PendingWorkerKeys[0].EffectiveAt, effectiveEpoch)
 
 		// no change if current epoch is less than effective epoch
 		st := getState(rt)
@@ -2659,7 +3447,7 @@ func TestChangeWorkerAddress(t *testing.T) {
 		rt.SetEpoch(deadline.PeriodEnd())
 
 		info = actor.getInfo(rt)
-		require.NotNil(t, info.PendingWorkerKey)
+		require.NotEmpty(t, info.PendingWorkerKeys)
 		require.EqualValues(t, actor.worker, info.Worker)
 
 		// move to deadline containing effectiveEpoch
@@ -2678,7 +3466,7 @@ func TestChangeWorkerAddress(t *testing.T) {
 		actor.checkState(rt)
 	})
 
-	t.Run("change cannot be overridden", func(t *testing.T) {
+	t.Run("change is queued behind a pending change", func(t *testing.T) {
 		rt, actor := setupFunc()
 		actor.constructAndVerify(rt)
 		originalControlAddrs := actor.controlAddrs
@@ -2690,28 +3478,90 @@ func TestChangeWorkerAddress(t *testing.T) {
 		currentEpoch := abi.ChainEpoch(2970)
 		rt.SetEpoch(currentEpoch)
 
-		effectiveEpoch := currentEpoch + miner.WorkerKeyChangeDelay()
-		actor.changeWorkerAddress(rt, newWorker1, effectiveEpoch, originalControlAddrs)
+		effectiveEpoch1 := currentEpoch + miner.WorkerKeyChangeDelay()
+		actor.changeWorkerAddress(rt, newWorker1, effectiveEpoch1, originalControlAddrs)
 
 		// no change if current epoch is less than effective epoch
 		st := getState(rt)
 		deadline := st.DeadlineInfo(rt.Epoch())
 		rt.SetEpoch(deadline.PeriodEnd())
 
-		// attempt to change address again
-		actor.changeWorkerAddress(rt, newWorker2, rt.Epoch()+miner.WorkerKeyChangeDelay(), originalControlAddrs)
+		// change address again
+		effectiveEpoch2 := rt.Epoch() + miner.WorkerKeyChangeDelay()
+		actor.changeWorkerAddress(rt, newWorker2, effectiveEpoch2, originalControlAddrs)
 
-		// assert change has not been modified
+		// assert first change has not been modified, and the second is queued behind it
 		info := actor.getInfo(rt)
-		assert.Equal(t, info.PendingWorkerKey.NewWorker, newWorker1)
-		assert.Equal(t, info.PendingWorkerKey.EffectiveAt, effectiveEpoch)
+		assert.Equal(t, []miner.WorkerKeyChange{
+			{NewWorker: newWorker1, EffectiveAt: effectiveEpoch1},
+			{NewWorker: newWorker2, EffectiveAt: effectiveEpoch2},
+		}, info.PendingWorkerKeys)
 
-		rt.SetEpoch(effectiveEpoch)
+		rt.SetEpoch(effectiveEpoch1)
 		actor.confirmUpdateWorkerKey(rt)
 
 		// assert original change is effected
 		info = actor.getInfo(rt)
 		assert.Equal(t, newWorker1, info.Worker)
+		assert.Len(t, info.PendingWorkerKeys, 1)
+		actor.checkState(rt)
+
+		rt.SetEpoch(effectiveEpoch2)
+		actor.confirmUpdateWorkerKey(rt)
+		info = actor.getInfo(rt)
+		assert.Equal(t, newWorker2, info.Worker)
+		assert.Empty(t, info.PendingWorkerKeys)
+		actor.checkState(rt)
+	})
+
+	t.Run("all changes which have taken effect are applied in order", func(t *testing.T) {
+		rt, actor := setupFunc()
+		actor.constructAndVerify(rt)
+		rt.SetEpoch(abi.ChainEpoch(2970))
+
+		newWorker1 := tutil.NewIDAddr(t, 999)
+		newWorker2 := tutil.NewIDAddr(t, 1023)
+		newWorker3 := tutil.NewIDAddr(t, 1024)
+		actor.changeWorkerAddress(rt, newWorker1, rt.Epoch()+miner.WorkerKeyChangeDelay(), actor.controlAddrs)
+		rt.SetEpoch(rt.Epoch() + 1)
+		actor.changeWorkerAddress(rt, newWorker2, rt.Epoch()+miner.WorkerKeyChangeDelay(), actor.controlAddrs)
+		rt.SetEpoch(rt.Epoch() + 1)
+		actor.changeWorkerAddress(rt, newWorker3, rt.Epoch()+miner.WorkerKeyChangeDelay(), actor.controlAddrs)
+
+		// the second change has taken effect but not the third
+		rt.SetEpoch(rt.Epoch() - 1 + miner.WorkerKeyChangeDelay())
+		actor.confirmUpdateWorkerKey(rt)
+		info := actor.getInfo(rt)
+		assert.Equal(t, newWorker2, info.Worker)
+		assert.Equal(t, []miner.WorkerKeyChange{{NewWorker: newWorker3, EffectiveAt: rt.Epoch() + 1}}, info.PendingWorkerKeys)
+		actor.checkState(rt)
+	})
+
+	t.Run("change to the last scheduled worker is not queued", func(t *testing.T) {
+		rt, actor := setupFunc()
+		actor.constructAndVerify(rt)
+
+		newWorker := tutil.NewIDAddr(t, 999)
+		actor.changeWorkerAddress(rt, newWorker, rt.Epoch()+miner.WorkerKeyChangeDelay(), actor.controlAddrs)
+		actor.changeWorkerAddress(rt, newWorker, rt.Epoch()+miner.WorkerKeyChangeDelay(), actor.controlAddrs)
+		assert.Len(t, actor.getInfo(rt).PendingWorkerKeys, 1)
+
+		// a change back to the current worker is queued
+		actor.changeWorkerAddress(rt, actor.worker, rt.Epoch()+miner.WorkerKeyChangeDelay(), actor.controlAddrs)
+		assert.Len(t, actor.getInfo(rt).PendingWorkerKeys, 2)
+		actor.checkState(rt)
+	})
+
+	t.Run("fails when too many changes are pending", func(t *testing.T) {
+		rt, actor := setupFunc()
+		actor.constructAndVerify(rt)
+
+		for i := 0; i < miner.MaxPendingWorkerKeyChanges; i++ {
+			actor.changeWorkerAddress(rt, tutil.NewIDAddr(t, uint64(1000+i)), rt.Epoch()+miner.WorkerKeyChangeDelay(), actor.controlAddrs)
+		}
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "too many pending worker key changes", func() {
+			actor.changeWorkerAddress(rt, tutil.NewIDAddr(t, 2000), rt.Epoch()+miner.WorkerKeyChangeDelay(), actor.controlAddrs)
+		})
 		actor.checkState(rt)
 	})
 
@@ -2735,7 +3585,7 @@ func TestChangeWorkerAddress(t *testing.T) {
 		info, err := st.GetInfo(adt.AsStore(rt))
 		require.NoError(t, err)
 		require.Equal(t, actor.worker, info.Worker)
-		require.Nil(t, info.PendingWorkerKey)
+		require.Empty(t, info.PendingWorkerKeys)
 		actor.checkState(rt)
 	})
 
@@ -2893,6 +3743,118 @@ func TestChangeWorkerAddress(t *testing.T) {
 	})
 }
 
+func TestControlPermissions(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+
+	changeControlPermissions := func(rt *mock.Runtime, permissions []miner.ControlPermissions) {
+		rt.ExpectSend(actor.worker, builtin.MethodsAccount.PubkeyAddress, nil, big.Zero(), &actor.key, exitcode.Ok)
+		rt.ExpectValidateCallerAddr(actor.owner)
+		rt.SetCaller(actor.owner, builtin.AccountActorCodeID)
+		rt.Call(actor.a.ChangeWorkerAddressWithPermissions, &miner.ChangeWorkerAddressWithPermissionsParams{
+			NewWorker:             actor.worker,
+			NewControlAddrs:       actor.controlAddrs,
+			NewControlPermissions: permissions,
+		})
+		rt.Verify()
+	}
+
+	t.Run("control addresses are initially unrestricted", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		info := actor.getInfo(rt)
+		assert.Equal(t, miner.UnrestrictedControlPermissions(len(actor.controlAddrs)), info.ControlPermissions)
+		assert.Equal(t, actor.controlAddrs, info.PermittedControlAddresses(miner.AllControlPermissions))
+		actor.checkState(rt)
+	})
+
+	t.Run("restricts control addresses to permitted methods", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		postOnly, peerInfo, unrestricted := actor.controlAddrs[0], actor.controlAddrs[1], actor.controlAddrs[2]
+		changeControlPermissions(rt, []miner.ControlPermissions{
+			miner.PermitWindowPoSt,
+			miner.PermitPeerInfo | miner.PermitRepayDebt,
+			miner.AllControlPermissions,
+		})
+
+		info := actor.getInfo(rt)
+		assert.Equal(t, []addr.Address{postOnly, unrestricted}, info.PermittedControlAddresses(miner.PermitWindowPoSt))
+		assert.Equal(t, []addr.Address{peerInfo, unrestricted}, info.PermittedControlAddresses(miner.PermitPeerInfo))
+
+		// A permitted control address may change the peer ID.
+		params := &miner.ChangePeerIDParams{NewID: abi.PeerID("new peer")}
+		rt.SetCaller(peerInfo, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(peerInfo, unrestricted, actor.owner, actor.worker)
+		rt.Call(actor.a.ChangePeerID, params)
+		rt.Verify()
+
+		// One without the permission may not.
+		rt.SetCaller(postOnly, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(peerInfo, unrestricted, actor.owner, actor.worker)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.a.ChangePeerID, params)
+		})
+		rt.Verify()
+		actor.checkState(rt)
+	})
+
+	t.Run("changing control addresses without permissions leaves them unrestricted", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		changeControlPermissions(rt, []miner.ControlPermissions{
+			miner.PermitWindowPoSt, miner.PermitWindowPoSt, miner.PermitWindowPoSt,
+		})
+
+		rt.ExpectSend(actor.worker, builtin.MethodsAccount.PubkeyAddress, nil, big.Zero(), &actor.key, exitcode.Ok)
+		rt.ExpectValidateCallerAddr(actor.owner)
+		rt.SetCaller(actor.owner, builtin.AccountActorCodeID)
+		rt.Call(actor.a.ChangeWorkerAddress, &miner.ChangeWorkerAddressParams{
+			NewWorker:       actor.worker,
+			NewControlAddrs: actor.controlAddrs,
+		})
+		rt.Verify()
+
+		info := actor.getInfo(rt)
+		assert.Equal(t, miner.UnrestrictedControlPermissions(len(actor.controlAddrs)), info.ControlPermissions)
+		assert.Equal(t, actor.controlAddrs, info.PermittedControlAddresses(miner.PermitPeerInfo))
+		actor.checkState(rt)
+	})
+
+	t.Run("a control address without permissions is permitted nothing", func(t *testing.T) {
+		info := miner.MinerInfo{
+			ControlAddresses:   actor.controlAddrs,
+			ControlPermissions: []miner.ControlPermissions{miner.AllControlPermissions},
+		}
+		assert.Equal(t, actor.controlAddrs[:1], info.PermittedControlAddresses(miner.PermitWindowPoSt))
+	})
+
+	t.Run("rejects permissions not matching control addresses", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "does not match control addresses length", func() {
+			changeControlPermissions(rt, []miner.ControlPermissions{miner.PermitWindowPoSt})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects unknown permissions", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "unknown permissions", func() {
+			changeControlPermissions(rt, []miner.ControlPermissions{
+				miner.PermitWindowPoSt, miner.AllControlPermissions, miner.AllControlPermissions + 1,
+			})
+		})
+		actor.checkState(rt)
+	})
+}
+
 func TestConfirmUpdateWorkerKey(t *testing.T) {
 	periodOffset := abi.ChainEpoch(100)
 	newWorker := tutil.NewIDAddr(t, 999)
@@ -2917,7 +3879,7 @@ func TestConfirmUpdateWorkerKey(t *testing.T) {
 		info, err := st.GetInfo(adt.AsStore(rt))
 		require.NoError(t, err)
 		require.Equal(t, info.Worker, newWorker)
-		require.Nil(t, info.PendingWorkerKey)
+		require.Empty(t, info.PendingWorkerKeys)
 		actor.checkState(rt)
 	})
 
@@ -2937,7 +3899,7 @@ func TestConfirmUpdateWorkerKey(t *testing.T) {
 		info, err := st.GetInfo(adt.AsStore(rt))
 		require.NoError(t, err)
 		require.Equal(t, actor.worker, info.Worker)
-		require.NotNil(t, info.PendingWorkerKey)
+		require.NotEmpty(t, info.PendingWorkerKeys)
 		actor.checkState(rt)
 	})
 
@@ -2952,7 +3914,7 @@ func TestConfirmUpdateWorkerKey(t *testing.T) {
 		info, err := st.GetInfo(adt.AsStore(rt))
 		require.NoError(t, err)
 		require.Equal(t, actor.worker, info.Worker)
-		require.Nil(t, info.PendingWorkerKey)
+		require.Empty(t, info.PendingWorkerKeys)
 		actor.checkState(rt)
 	})
 }
@@ -3111,6 +4073,209 @@ func TestChangeOwnerAddress(t *testing.T) {
 	})
 }
 
+func TestChangeBeneficiary(t *testing.T) {
+	actor := newHarness(t, 0)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+	beneficiary := tutil.NewIDAddr(t, 1001)
+	otherAddr := tutil.NewIDAddr(t, 1002)
+	quota := abi.NewTokenAmount(1e18)
+	expiration := abi.ChainEpoch(1000)
+
+	// Nominates and confirms a beneficiary other than the owner.
+	setBeneficiary := func(rt *mock.Runtime, nominee addr.Address) {
+		params := &miner.ChangeBeneficiaryParams{NewBeneficiary: nominee, NewQuota: quota, NewExpiration: expiration}
+		rt.SetCaller(actor.owner, builtin.AccountActorCodeID)
+		actor.changeBeneficiary(rt, params)
+		rt.SetCaller(nominee, builtin.AccountActorCodeID)
+		actor.changeBeneficiary(rt, params)
+	}
+
+	// Withdraws funds as the current beneficiary, expecting an amount to be sent to it.
+	withdrawAsBeneficiary := func(rt *mock.Runtime, requested, expected abi.TokenAmount) {
+		rt.SetCaller(beneficiary, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(actor.owner, beneficiary)
+		if expected.GreaterThan(big.Zero()) {
+			rt.ExpectSend(beneficiary, builtin.MethodSend, nil, expected, nil, exitcode.Ok)
+		}
+		ret := rt.Call(actor.a.WithdrawBalance, &miner.WithdrawBalanceParams{AmountRequested: requested})
+		rt.Verify()
+		withdrawn := *ret.(*abi.TokenAmount)
+		assert.True(t, expected.Equals(withdrawn), "withdrew %v, expected %v", withdrawn, expected)
+	}
+
+	t.Run("owner nominates beneficiary who accepts", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		info := actor.getInfo(rt)
+		assert.Equal(t, actor.owner, info.Beneficiary)
+
+		params := &miner.ChangeBeneficiaryParams{NewBeneficiary: beneficiary, NewQuota: quota, NewExpiration: expiration}
+		rt.SetCaller(actor.owner, builtin.AccountActorCodeID)
+		actor.changeBeneficiary(rt, params)
+		info = actor.getInfo(rt)
+		assert.Equal(t, actor.owner, info.Beneficiary)
+		require.NotNil(t, info.PendingBeneficiaryTerm)
+		assert.Equal(t, beneficiary, info.PendingBeneficiaryTerm.NewBeneficiary)
+		assert.True(t, info.PendingBeneficiaryTerm.ApprovedByBeneficiary)
+		assert.False(t, info.PendingBeneficiaryTerm.ApprovedByNominee)
+
+		rt.SetCaller(beneficiary, builtin.AccountActorCodeID)
+		actor.changeBeneficiary(rt, params)
+		info = actor.getInfo(rt)
+		assert.Equal(t, beneficiary, info.Beneficiary)
+		assert.Equal(t, miner.BeneficiaryTerm{Quota: quota, UsedQuota: big.Zero(), Expiration: expiration}, info.BeneficiaryTerm)
+		assert.Nil(t, info.PendingBeneficiaryTerm)
+		actor.checkState(rt)
+	})
+
+	t.Run("approval must match proposal", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetCaller(actor.owner, builtin.AccountActorCodeID)
+		actor.changeBeneficiary(rt, &miner.ChangeBeneficiaryParams{NewBeneficiary: beneficiary, NewQuota: quota, NewExpiration: expiration})
+
+		rt.SetCaller(beneficiary, builtin.AccountActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "expected approval", func() {
+			actor.changeBeneficiary(rt, &miner.ChangeBeneficiaryParams{NewBeneficiary: beneficiary, NewQuota: big.Mul(quota, big.NewInt(2)), NewExpiration: expiration})
+		})
+
+		rt.SetCaller(otherAddr, builtin.AccountActorCodeID)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			actor.changeBeneficiary(rt, &miner.ChangeBeneficiaryParams{NewBeneficiary: beneficiary, NewQuota: quota, NewExpiration: expiration})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("rejects invalid terms", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetCaller(actor.owner, builtin.AccountActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "quota must be positive", func() {
+			actor.changeBeneficiary(rt, &miner.ChangeBeneficiaryParams{NewBeneficiary: beneficiary, NewQuota: big.Zero(), NewExpiration: expiration})
+		})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "zero quota and expiration", func() {
+			actor.changeBeneficiary(rt, &miner.ChangeBeneficiaryParams{NewBeneficiary: actor.owner, NewQuota: quota, NewExpiration: expiration})
+		})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "must be after current epoch", func() {
+			actor.changeBeneficiary(rt, &miner.ChangeBeneficiaryParams{NewBeneficiary: beneficiary, NewQuota: quota, NewExpiration: rt.Epoch()})
+		})
+		rt.SetCaller(beneficiary, builtin.AccountActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "no pending beneficiary change", func() {
+			actor.changeBeneficiary(rt, &miner.ChangeBeneficiaryParams{NewBeneficiary: beneficiary, NewQuota: quota, NewExpiration: expiration})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("expired proposal cannot be approved", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		params := &miner.ChangeBeneficiaryParams{NewBeneficiary: beneficiary, NewQuota: quota, NewExpiration: expiration}
+		rt.SetCaller(actor.owner, builtin.AccountActorCodeID)
+		actor.changeBeneficiary(rt, params)
+
+		rt.SetEpoch(expiration)
+		rt.SetCaller(beneficiary, builtin.AccountActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "must be after current epoch", func() {
+			actor.changeBeneficiary(rt, params)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("beneficiary withdraws up to quota", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		setBeneficiary(rt, beneficiary)
+
+		half := big.Div(quota, big.NewInt(2))
+		withdrawAsBeneficiary(rt, half, half)
+		withdrawAsBeneficiary(rt, quota, big.Sub(quota, half))
+		withdrawAsBeneficiary(rt, quota, big.Zero())
+		assert.Equal(t, quota, actor.getInfo(rt).BeneficiaryTerm.UsedQuota)
+
+		// The owner's withdrawals are also paid to the beneficiary, so are limited by the quota.
+		rt.SetCaller(actor.owner, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(actor.owner, beneficiary)
+		ret := rt.Call(actor.a.WithdrawBalance, &miner.WithdrawBalanceParams{AmountRequested: quota})
+		rt.Verify()
+		assert.True(t, ret.(*abi.TokenAmount).IsZero())
+		actor.checkState(rt)
+	})
+
+	t.Run("beneficiary cannot withdraw after expiration", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		setBeneficiary(rt, beneficiary)
+
+		rt.SetEpoch(expiration + 1)
+		withdrawAsBeneficiary(rt, quota, big.Zero())
+		actor.checkState(rt)
+	})
+
+	t.Run("active beneficiary must approve a change", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		setBeneficiary(rt, beneficiary)
+		withdrawAsBeneficiary(rt, big.Div(quota, big.NewInt(2)), big.Div(quota, big.NewInt(2)))
+
+		params := &miner.ChangeBeneficiaryParams{NewBeneficiary: otherAddr, NewQuota: quota, NewExpiration: expiration}
+		rt.SetCaller(actor.owner, builtin.AccountActorCodeID)
+		actor.changeBeneficiary(rt, params)
+		rt.SetCaller(otherAddr, builtin.AccountActorCodeID)
+		actor.changeBeneficiary(rt, params)
+
+		// Still awaiting the current beneficiary.
+		info := actor.getInfo(rt)
+		assert.Equal(t, beneficiary, info.Beneficiary)
+		require.NotNil(t, info.PendingBeneficiaryTerm)
+		assert.False(t, info.PendingBeneficiaryTerm.ApprovedByBeneficiary)
+		assert.True(t, info.PendingBeneficiaryTerm.ApprovedByNominee)
+
+		rt.SetCaller(beneficiary, builtin.AccountActorCodeID)
+		actor.changeBeneficiary(rt, params)
+		info = actor.getInfo(rt)
+		assert.Equal(t, otherAddr, info.Beneficiary)
+		assert.Equal(t, miner.BeneficiaryTerm{Quota: quota, UsedQuota: big.Zero(), Expiration: expiration}, info.BeneficiaryTerm)
+		assert.Nil(t, info.PendingBeneficiaryTerm)
+		actor.checkState(rt)
+	})
+
+	t.Run("owner reclaims beneficiary after expiration", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		setBeneficiary(rt, beneficiary)
+
+		rt.SetEpoch(expiration + 1)
+		rt.SetCaller(actor.owner, builtin.AccountActorCodeID)
+		actor.changeBeneficiary(rt, &miner.ChangeBeneficiaryParams{NewBeneficiary: actor.owner, NewQuota: big.Zero(), NewExpiration: 0})
+		info := actor.getInfo(rt)
+		assert.Equal(t, actor.owner, info.Beneficiary)
+		assert.Nil(t, info.PendingBeneficiaryTerm)
+
+		// The owner withdraws to itself again.
+		actor.withdrawFunds(rt, quota, quota, big.Zero())
+		actor.checkState(rt)
+	})
+
+	t.Run("owner beneficiary follows owner change", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetCaller(actor.owner, builtin.AccountActorCodeID)
+		actor.changeOwnerAddress(rt, otherAddr)
+		rt.SetCaller(otherAddr, builtin.AccountActorCodeID)
+		actor.changeOwnerAddress(rt, otherAddr)
+
+		info := actor.getInfo(rt)
+		assert.Equal(t, otherAddr, info.Owner)
+		assert.Equal(t, otherAddr, info.Beneficiary)
+		actor.checkState(rt)
+	})
+}
+
 func TestReportConsensusFault(t *testing.T) {
 	periodOffset := abi.ChainEpoch(100)
 	actor := newHarness(t, periodOffset)
@@ -3222,26 +4387,104 @@ func TestReportConsensusFault(t *testing.T) {
 		})
 		rt.Reset()
 
-		// a new consensus fault can be reported for blocks once original has expired
-		rt.SetEpoch(endInfo.ConsensusFaultElapsed + 1)
-		fault3 := endInfo.ConsensusFaultElapsed
+		// a new consensus fault can be reported for blocks once original has expired
+		rt.SetEpoch(endInfo.ConsensusFaultElapsed + 1)
+		fault3 := endInfo.ConsensusFaultElapsed
+		actor.reportConsensusFault(rt, addr.TestAddress, &runtime.ConsensusFault{
+			Target: actor.receiver,
+			Epoch:  fault3,
+			Type:   runtime.ConsensusFaultDoubleForkMining,
+		})
+		endInfo = actor.getInfo(rt)
+		assert.Equal(t, rt.Epoch()+miner.ConsensusFaultIneligibilityDuration(), endInfo.ConsensusFaultElapsed)
+
+		// old fault still cannot be reported after fault interval has elapsed
+		fault4 := fault1 + 1
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "too old", func() {
+			actor.reportConsensusFault(rt, addr.TestAddress, &runtime.ConsensusFault{
+				Target: actor.receiver,
+				Epoch:  fault4,
+				Type:   runtime.ConsensusFaultDoubleForkMining,
+			})
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("penalty depends on the type of fault", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		rt.SetEpoch(abi.ChainEpoch(1))
+
+		defaultFactor := miner.ConsensusFaultFactors[runtime.ConsensusFaultTimeOffsetMining]
+		miner.ConsensusFaultFactors[runtime.ConsensusFaultTimeOffsetMining] = 2 * defaultFactor
+		defer func() { miner.ConsensusFaultFactors[runtime.ConsensusFaultTimeOffsetMining] = defaultFactor }()
+
+		thisEpochReward := actor.epochRewardSmooth.Estimate()
+		assert.Equal(t, big.Mul(big.NewInt(2), miner.ConsensusFaultPenalty(thisEpochReward)),
+			miner.ConsensusFaultPenaltyForType(runtime.ConsensusFaultTimeOffsetMining, thisEpochReward))
+
+		// The harness expects the typed penalty to be burnt.
+		actor.reportConsensusFault(rt, addr.TestAddress, &runtime.ConsensusFault{
+			Target: actor.receiver,
+			Epoch:  rt.Epoch() - 1,
+			Type:   runtime.ConsensusFaultTimeOffsetMining,
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("parent grinding evidence is verified with the witness", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		rt.SetEpoch(abi.ChainEpoch(1))
+
 		actor.reportConsensusFault(rt, addr.TestAddress, &runtime.ConsensusFault{
 			Target: actor.receiver,
-			Epoch:  fault3,
+			Epoch:  rt.Epoch() - 1,
+			Type:   runtime.ConsensusFaultParentGrinding,
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("evidence verified as a different type of fault is rejected", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		rt.SetEpoch(abi.ChainEpoch(1))
+
+		rt.SetCaller(addr.TestAddress, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+		params := &miner.ReportConsensusFaultParams{
+			TimeOffsetMining: &miner.TimeOffsetMiningEvidence{BlockHeader1: []byte("header1"), BlockHeader2: []byte("header2")},
+		}
+		rt.ExpectVerifyConsensusFault(params.TimeOffsetMining.BlockHeader1, params.TimeOffsetMining.BlockHeader2, nil, &runtime.ConsensusFault{
+			Target: actor.receiver,
+			Epoch:  rt.Epoch() - 1,
 			Type:   runtime.ConsensusFaultDoubleForkMining,
+		}, nil)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "verified as fault type", func() {
+			rt.Call(actor.a.ReportConsensusFault, params)
 		})
-		endInfo = actor.getInfo(rt)
-		assert.Equal(t, rt.Epoch()+miner.ConsensusFaultIneligibilityDuration(), endInfo.ConsensusFaultElapsed)
+		actor.checkState(rt)
+	})
 
-		// old fault still cannot be reported after fault interval has elapsed
-		fault4 := fault1 + 1
-		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "too old", func() {
-			actor.reportConsensusFault(rt, addr.TestAddress, &runtime.ConsensusFault{
-				Target: actor.receiver,
-				Epoch:  fault4,
-				Type:   runtime.ConsensusFaultDoubleForkMining,
+	t.Run("evidence of more or less than one type is rejected", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		rt.SetEpoch(abi.ChainEpoch(1))
+
+		for _, params := range []*miner.ReportConsensusFaultParams{
+			{},
+			{
+				DoubleForkMining: &miner.DoubleForkMiningEvidence{},
+				TimeOffsetMining: &miner.TimeOffsetMiningEvidence{},
+			},
+		} {
+			rt.SetCaller(addr.TestAddress, builtin.AccountActorCodeID)
+			rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
+			rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "exactly one type of evidence", func() {
+				rt.Call(actor.a.ReportConsensusFault, params)
 			})
-		})
+			rt.Reset()
+		}
 		actor.checkState(rt)
 	})
 }
@@ -3852,6 +5095,17 @@ func (h *actorHarness) changeOwnerAddress(rt *mock.Runtime, newAddr addr.Address
 	rt.Verify()
 }
 
+func (h *actorHarness) changeBeneficiary(rt *mock.Runtime, params *miner.ChangeBeneficiaryParams) {
+	info := h.getInfo(rt)
+	if rt.Caller() == info.Owner {
+		rt.ExpectValidateCallerAddr(info.Owner)
+	} else if info.PendingBeneficiaryTerm != nil {
+		rt.ExpectValidateCallerAddr(info.Beneficiary, info.PendingBeneficiaryTerm.NewBeneficiary)
+	}
+	rt.Call(h.a.ChangeBeneficiary, params)
+	rt.Verify()
+}
+
 func (h *actorHarness) checkSectorProven(rt *mock.Runtime, sectorNum abi.SectorNumber) {
 	param := &miner.CheckSectorProvenParams{SectorNumber: sectorNum}
 
@@ -3948,7 +5202,7 @@ func (h *actorHarness) preCommitSector(rt *mock.Runtime, params *miner.PreCommit
 	if first {
 		dlInfo := miner.NewDeadlineInfoFromOffsetAndEpoch(st.ProvingPeriodStart, rt.Epoch())
 		cronParams := makeDeadlineCronEventParams(h.t, dlInfo.Last())
-		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.EnrollCronEvent, cronParams, big.Zero(), nil, exitcode.Ok)
+		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.EnrollPriorityCronEvent, cronParams, big.Zero(), nil, exitcode.Ok)
 	}
 
 	rt.Call(h.a.PreCommitSector, params)
@@ -4015,7 +5269,7 @@ func (h *actorHarness) preCommitSectorBatch(rt *mock.Runtime, params *miner.PreC
 	if conf.firstForMiner {
 		dlInfo := miner.NewDeadlineInfoFromOffsetAndEpoch(st.ProvingPeriodStart, rt.Epoch())
 		cronParams := makeDeadlineCronEventParams(h.t, dlInfo.Last())
-		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.EnrollCronEvent, cronParams, big.Zero(), nil, exitcode.Ok)
+		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.EnrollPriorityCronEvent, cronParams, big.Zero(), nil, exitcode.Ok)
 	}
 
 	rt.Call(h.a.PreCommitSectorBatch, params)
@@ -4335,6 +5589,41 @@ type poStDisputeResult struct {
 }
 
 func (h *actorHarness) disputeWindowPoSt(rt *mock.Runtime, deadline *dline.Info, proofIndex uint64, infos []*miner.SectorOnChainInfo, expectSuccess *poStDisputeResult) {
+	h.expectDisputeWindowPoSt(rt, deadline, proofIndex, infos, expectSuccess)
+	params := miner.DisputeWindowedPoStParams{
+		Deadline:  deadline.Index,
+		PoStIndex: proofIndex,
+	}
+	if expectSuccess == nil {
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "failed to dispute valid post", func() {
+			rt.Call(h.a.DisputeWindowedPoSt, &params)
+		})
+	} else {
+		rt.Call(h.a.DisputeWindowedPoSt, &params)
+	}
+	rt.Verify()
+}
+
+func (h *actorHarness) disputeWindowPoStSectors(rt *mock.Runtime, deadline *dline.Info, proofIndex uint64, partition uint64, sectors bitfield.BitField,
+	infos []*miner.SectorOnChainInfo, expectSuccess *poStDisputeResult) {
+	h.expectDisputeWindowPoSt(rt, deadline, proofIndex, infos, expectSuccess)
+	params := miner.DisputeWindowedPoStSectorsParams{
+		Deadline:  deadline.Index,
+		PoStIndex: proofIndex,
+		Partition: partition,
+		Sectors:   sectors,
+	}
+	if expectSuccess == nil {
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "failed to dispute valid post", func() {
+			rt.Call(h.a.DisputeWindowedPoStSectors, &params)
+		})
+	} else {
+		rt.Call(h.a.DisputeWindowedPoStSectors, &params)
+	}
+	rt.Verify()
+}
+
+func (h *actorHarness) expectDisputeWindowPoSt(rt *mock.Runtime, deadline *dline.Info, proofIndex uint64, infos []*miner.SectorOnChainInfo, expectSuccess *poStDisputeResult) {
 	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
 	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
 
@@ -4381,33 +5670,45 @@ func (h *actorHarness) disputeWindowPoSt(rt *mock.Runtime, deadline *dline.Info,
 	actorId, err := addr.IDFromAddress(h.receiver)
 	require.NoError(h.t, err)
 
-	proofInfos := make([]proof.SectorInfo, len(infos))
-	for i, ci := range infos {
-		si := ci
-		contains, err := allIgnored.IsSet(uint64(ci.SectorNumber))
+	if len(post.Proofs) > 1 {
+		// Each proof covers one partition, in increasing partition order.
+		var partitionSectors []bitfield.BitField
+		err = post.Partitions.ForEach(func(idx uint64) error {
+			partitionSectors = append(partitionSectors, h.getPartitionSnapshot(rt, dln, idx).Sectors)
+			return nil
+		})
 		require.NoError(h.t, err)
-		if contains {
-			si = goodInfo
-		}
-		proofInfos[i] = proof.SectorInfo{
-			SealProof:    si.SealProof,
-			SectorNumber: si.SectorNumber,
-			SealedCID:    si.SealedCID,
+		// if we succeed at challenging, proof verification needs to fail.
+		h.expectBatchVerifyPoSts(rt, abi.PoStRandomness(challengeRand), partitionSectors, allIgnored, infos, post.Proofs, expectSuccess == nil)
+	} else {
+		proofInfos := make([]proof.SectorInfo, len(infos))
+		for i, ci := range infos {
+			si := ci
+			contains, err := allIgnored.IsSet(uint64(ci.SectorNumber))
+			require.NoError(h.t, err)
+			if contains {
+				si = goodInfo
+			}
+			proofInfos[i] = proof.SectorInfo{
+				SealProof:    si.SealProof,
+				SectorNumber: si.SectorNumber,
+				SealedCID:    si.SealedCID,
+			}
 		}
-	}
 
-	vi := proof.WindowPoStVerifyInfo{
-		Randomness:        abi.PoStRandomness(challengeRand),
-		Proofs:            post.Proofs,
-		ChallengedSectors: proofInfos,
-		Prover:            abi.ActorID(actorId),
-	}
-	var verifResult error
-	if expectSuccess != nil {
-		// if we succeed at challenging, proof verification needs to fail.
-		verifResult = fmt.Errorf("invalid post")
+		vi := proof.WindowPoStVerifyInfo{
+			Randomness:        abi.PoStRandomness(challengeRand),
+			Proofs:            post.Proofs,
+			ChallengedSectors: proofInfos,
+			Prover:            abi.ActorID(actorId),
+		}
+		var verifResult error
+		if expectSuccess != nil {
+			// if we succeed at challenging, proof verification needs to fail.
+			verifResult = fmt.Errorf("invalid post")
+		}
+		rt.ExpectVerifyPoSt(vi, verifResult)
 	}
-	rt.ExpectVerifyPoSt(vi, verifResult)
 
 	if expectSuccess != nil {
 		// expect power update
@@ -4433,19 +5734,6 @@ func (h *actorHarness) disputeWindowPoSt(rt *mock.Runtime, deadline *dline.Info,
 				&expectSuccess.expectedPledgeDelta, abi.NewTokenAmount(0), nil, exitcode.Ok)
 		}
 	}
-
-	params := miner.DisputeWindowedPoStParams{
-		Deadline:  deadline.Index,
-		PoStIndex: proofIndex,
-	}
-	if expectSuccess == nil {
-		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "failed to dispute valid post", func() {
-			rt.Call(h.a.DisputeWindowedPoSt, &params)
-		})
-	} else {
-		rt.Call(h.a.DisputeWindowedPoSt, &params)
-	}
-	rt.Verify()
 }
 
 type poStConfig struct {
@@ -4520,33 +5808,45 @@ func (h *actorHarness) submitWindowPoStRaw(rt *mock.Runtime, deadline *dline.Inf
 		actorId, err := addr.IDFromAddress(h.receiver)
 		require.NoError(h.t, err)
 
-		// if not all sectors are skipped
-		proofInfos := make([]proof.SectorInfo, len(infos))
-		for i, ci := range infos {
-			si := ci
-			contains, err := allIgnored.IsSet(uint64(ci.SectorNumber))
-			require.NoError(h.t, err)
-			if contains {
-				si = goodInfo
+		if len(params.Proofs) > 1 {
+			// Each proof covers one partition.
+			var partitionSectors []bitfield.BitField
+			for _, p := range params.Partitions {
+				partition, err := dln.LoadPartition(rt.AdtStore(), p.Index)
+				require.NoError(h.t, err)
+				partitionSectors = append(partitionSectors, partition.Sectors)
 			}
-			proofInfos[i] = proof.SectorInfo{
-				SealProof:    si.SealProof,
-				SectorNumber: si.SectorNumber,
-				SealedCID:    si.SealedCID,
+			verified := poStCfg == nil || poStCfg.verificationError == nil
+			h.expectBatchVerifyPoSts(rt, abi.PoStRandomness(challengeRand), partitionSectors, allIgnored, infos, params.Proofs, verified)
+		} else {
+			// if not all sectors are skipped
+			proofInfos := make([]proof.SectorInfo, len(infos))
+			for i, ci := range infos {
+				si := ci
+				contains, err := allIgnored.IsSet(uint64(ci.SectorNumber))
+				require.NoError(h.t, err)
+				if contains {
+					si = goodInfo
+				}
+				proofInfos[i] = proof.SectorInfo{
+					SealProof:    si.SealProof,
+					SectorNumber: si.SectorNumber,
+					SealedCID:    si.SealedCID,
+				}
 			}
-		}
 
-		vi := proof.WindowPoStVerifyInfo{
-			Randomness:        abi.PoStRandomness(challengeRand),
-			Proofs:            params.Proofs,
-			ChallengedSectors: proofInfos,
-			Prover:            abi.ActorID(actorId),
-		}
-		var verifResult error
-		if poStCfg != nil {
-			verifResult = poStCfg.verificationError
+			vi := proof.WindowPoStVerifyInfo{
+				Randomness:        abi.PoStRandomness(challengeRand),
+				Proofs:            params.Proofs,
+				ChallengedSectors: proofInfos,
+				Prover:            abi.ActorID(actorId),
+			}
+			var verifResult error
+			if poStCfg != nil {
+				verifResult = poStCfg.verificationError
+			}
+			rt.ExpectVerifyPoSt(vi, verifResult)
 		}
-		rt.ExpectVerifyPoSt(vi, verifResult)
 	}
 
 	if poStCfg != nil {
@@ -4565,6 +5865,57 @@ func (h *actorHarness) submitWindowPoStRaw(rt *mock.Runtime, deadline *dline.Inf
 	rt.Verify()
 }
 
+// Expects a batch verification of one proof per partition. Each proof covers the sectors of its partition, with
+// ignored sectors replaced by the partition's first non-ignored sector.
+func (h *actorHarness) expectBatchVerifyPoSts(rt *mock.Runtime, randomness abi.PoStRandomness, partitionSectors []bitfield.BitField,
+	ignored bitfield.BitField, infos []*miner.SectorOnChainInfo, proofs []proof.PoStProof, verified bool) {
+	actorId, err := addr.IDFromAddress(h.receiver)
+	require.NoError(h.t, err)
+
+	vis := make([]proof.WindowPoStVerifyInfo, len(proofs))
+	results := make([]bool, len(proofs))
+	for i, sectors := range partitionSectors {
+		var partitionInfos []*miner.SectorOnChainInfo
+		var goodInfo *miner.SectorOnChainInfo
+		for _, ci := range infos {
+			inPartition, err := sectors.IsSet(uint64(ci.SectorNumber))
+			require.NoError(h.t, err)
+			if !inPartition {
+				continue
+			}
+			partitionInfos = append(partitionInfos, ci)
+			isIgnored, err := ignored.IsSet(uint64(ci.SectorNumber))
+			require.NoError(h.t, err)
+			if goodInfo == nil && !isIgnored {
+				goodInfo = ci
+			}
+		}
+
+		proofInfos := make([]proof.SectorInfo, len(partitionInfos))
+		for j, ci := range partitionInfos {
+			si := ci
+			isIgnored, err := ignored.IsSet(uint64(ci.SectorNumber))
+			require.NoError(h.t, err)
+			if isIgnored {
+				si = goodInfo
+			}
+			proofInfos[j] = proof.SectorInfo{
+				SealProof:    si.SealProof,
+				SectorNumber: si.SectorNumber,
+				SealedCID:    si.SealedCID,
+			}
+		}
+		vis[i] = proof.WindowPoStVerifyInfo{
+			Randomness:        randomness,
+			Proofs:            proofs[i : i+1],
+			ChallengedSectors: proofInfos,
+			Prover:            abi.ActorID(actorId),
+		}
+		results[i] = verified
+	}
+	rt.ExpectBatchVerifyPoSts(vis, results, nil)
+}
+
 func (h *actorHarness) declareFaults(rt *mock.Runtime, faultSectorInfos ...*miner.SectorOnChainInfo) miner.PowerPair {
 	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
 	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
@@ -4617,6 +5968,96 @@ func (h *actorHarness) declareRecoveries(rt *mock.Runtime, deadlineIdx uint64, p
 	rt.Verify()
 }
 
+func (h *actorHarness) declareFaultsAndRecoveries(rt *mock.Runtime, params *miner.DeclareFaultsAndRecoveriesParams, expectedPowerDelta miner.PowerPair, expectedDebtRepaid abi.TokenAmount) {
+	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
+
+	if !expectedPowerDelta.IsZero() {
+		rt.ExpectSend(
+			builtin.StoragePowerActorAddr,
+			builtin.MethodsPower.UpdateClaimedPower,
+			&power.UpdateClaimedPowerParams{
+				RawByteDelta:         expectedPowerDelta.Raw,
+				QualityAdjustedDelta: expectedPowerDelta.QA,
+			},
+			abi.NewTokenAmount(0),
+			nil,
+			exitcode.Ok,
+		)
+	}
+	if expectedDebtRepaid.GreaterThan(big.Zero()) {
+		rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, expectedDebtRepaid, nil, exitcode.Ok)
+	}
+
+	rt.Call(h.a.DeclareFaultsAndRecoveries, params)
+	rt.Verify()
+}
+
+func (h *actorHarness) recommitSector(rt *mock.Runtime, sector *miner.SectorOnChainInfo, dlIdx, pIdx uint64, newExpiration abi.ChainEpoch) {
+	h.recommitSectorWithResult(rt, sector, dlIdx, pIdx, newExpiration, nil)
+}
+
+// Re-commits a sector, with the seal verification returning verifyResult.
+// Power and pledge changes are expected only if the verification succeeds.
+func (h *actorHarness) recommitSectorWithResult(rt *mock.Runtime, sector *miner.SectorOnChainInfo, dlIdx, pIdx uint64,
+	newExpiration abi.ChainEpoch, verifyResult error) {
+	commd := cbg.CborCid(tutil.MakeCID("commd", &market.PieceCIDPrefix))
+	sealRand := abi.SealRandomness([]byte{1, 2, 3, 4})
+	sealIntRand := abi.InteractiveSealRandomness([]byte{5, 6, 7, 8})
+	params := &miner.RecommitSectorParams{
+		SectorNumber:     sector.SectorNumber,
+		Deadline:         dlIdx,
+		Partition:        pIdx,
+		SealRandEpoch:    sector.Activation - 10,
+		InteractiveEpoch: rt.Epoch() - 1,
+		NewExpiration:    newExpiration,
+		Proof:            make([]byte, 192),
+	}
+
+	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
+	rt.ExpectSend(builtin.StorageMarketActorAddr, builtin.MethodsMarket.ComputeDataCommitment,
+		&market.ComputeDataCommitmentParams{Inputs: []*market.SectorDataSpec{{
+			DealIDs:    sector.DealIDs,
+			SectorType: sector.SealProof,
+		}}},
+		big.Zero(), &market.ComputeDataCommitmentReturn{CommDs: []cbg.CborCid{commd}}, exitcode.Ok)
+
+	var buf bytes.Buffer
+	receiver := rt.Receiver()
+	require.NoError(h.t, receiver.MarshalCBOR(&buf))
+	rt.ExpectGetRandomnessTickets(crypto.DomainSeparationTag_SealRandomness, params.SealRandEpoch, buf.Bytes(), abi.Randomness(sealRand))
+	rt.ExpectGetRandomnessBeacon(crypto.DomainSeparationTag_InteractiveSealChallengeSeed, params.InteractiveEpoch, buf.Bytes(), abi.Randomness(sealIntRand))
+
+	actorId, err := addr.IDFromAddress(h.receiver)
+	require.NoError(h.t, err)
+	rt.ExpectVerifySeal(proof.SealVerifyInfo{
+		SealProof:             sector.SealProof,
+		SectorID:              abi.SectorID{Miner: abi.ActorID(actorId), Number: sector.SectorNumber},
+		DealIDs:               sector.DealIDs,
+		Randomness:            sealRand,
+		InteractiveRandomness: sealIntRand,
+		Proof:                 params.Proof,
+		SealedCID:             sector.SealedCID,
+		UnsealedCID:           cid.Cid(commd),
+	}, verifyResult)
+
+	if verifyResult == nil {
+		expectQueryNetworkInfo(rt, h)
+
+		// A sector without deals keeps its power, but its pledge is recomputed and never reduced.
+		qaPower := miner.QAPowerForWeight(h.sectorSize, newExpiration-rt.Epoch(), big.Zero(), big.Zero())
+		pledge := miner.InitialPledgeForPower(qaPower, h.baselinePower, h.epochRewardSmooth, h.epochQAPowerSmooth, rt.TotalFilCircSupply())
+		pledgeDelta := big.Sub(big.Max(pledge, sector.InitialPledge), sector.InitialPledge)
+		if !pledgeDelta.IsZero() {
+			rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.UpdatePledgeTotal, &pledgeDelta, big.Zero(), nil, exitcode.Ok)
+		}
+	}
+
+	rt.Call(h.a.RecommitSector, params)
+	rt.Verify()
+}
+
 func (h *actorHarness) extendSectors(rt *mock.Runtime, params *miner.ExtendSectorExpirationParams) {
 	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
 	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
@@ -4651,6 +6092,40 @@ func (h *actorHarness) extendSectors(rt *mock.Runtime, params *miner.ExtendSecto
 	rt.Verify()
 }
 
+func (h *actorHarness) extendSectorsBatch(rt *mock.Runtime, params *miner.ExtendSectorExpirationBatchParams) {
+	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
+
+	qaDelta := big.Zero()
+	for _, group := range params.Groups {
+		err := group.Sectors.ForEach(func(sno uint64) error {
+			sector := h.getSector(rt, abi.SectorNumber(sno))
+			newSector := *sector
+			newSector.Expiration = group.NewExpiration
+			qaDelta = big.Sum(qaDelta,
+				miner.QAPowerForSector(h.sectorSize, &newSector),
+				miner.QAPowerForSector(h.sectorSize, sector).Neg(),
+			)
+			return nil
+		})
+		require.NoError(h.t, err)
+	}
+	if !qaDelta.IsZero() {
+		rt.ExpectSend(builtin.StoragePowerActorAddr,
+			builtin.MethodsPower.UpdateClaimedPower,
+			&power.UpdateClaimedPowerParams{
+				RawByteDelta:         big.Zero(),
+				QualityAdjustedDelta: qaDelta,
+			},
+			abi.NewTokenAmount(0),
+			nil,
+			exitcode.Ok,
+		)
+	}
+	rt.Call(h.a.ExtendSectorExpirationBatch, params)
+	rt.Verify()
+}
+
 func (h *actorHarness) terminateSectors(rt *mock.Runtime, sectors bitfield.BitField, expectedFee abi.TokenAmount) (miner.PowerPair, abi.TokenAmount) {
 	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
 	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
@@ -4731,16 +6206,22 @@ func (h *actorHarness) terminateSectors(rt *mock.Runtime, sectors bitfield.BitFi
 func (h *actorHarness) reportConsensusFault(rt *mock.Runtime, from addr.Address, fault *runtime.ConsensusFault) {
 	rt.SetCaller(from, builtin.AccountActorCodeID)
 	rt.ExpectValidateCallerType(builtin.CallerTypesSignable...)
-	params := &miner.ReportConsensusFaultParams{
-		BlockHeader1:     nil,
-		BlockHeader2:     nil,
-		BlockHeaderExtra: nil,
+	h1, h2, extra := []byte("header1"), []byte("header2"), []byte(nil)
+	params := &miner.ReportConsensusFaultParams{}
+	switch {
+	case fault == nil || fault.Type == runtime.ConsensusFaultDoubleForkMining:
+		params.DoubleForkMining = &miner.DoubleForkMiningEvidence{BlockHeader1: h1, BlockHeader2: h2}
+	case fault.Type == runtime.ConsensusFaultParentGrinding:
+		extra = []byte("witness")
+		params.ParentGrinding = &miner.ParentGrindingEvidence{BlockHeader1: h1, BlockHeader2: h2, WitnessHeader: extra}
+	case fault.Type == runtime.ConsensusFaultTimeOffsetMining:
+		params.TimeOffsetMining = &miner.TimeOffsetMiningEvidence{BlockHeader1: h1, BlockHeader2: h2}
 	}
 
 	if fault != nil {
-		rt.ExpectVerifyConsensusFault(params.BlockHeader1, params.BlockHeader2, params.BlockHeaderExtra, fault, nil)
+		rt.ExpectVerifyConsensusFault(h1, h2, extra, fault, nil)
 	} else {
-		rt.ExpectVerifyConsensusFault(params.BlockHeader1, params.BlockHeader2, params.BlockHeaderExtra, nil, fmt.Errorf("no fault"))
+		rt.ExpectVerifyConsensusFault(h1, h2, extra, nil, fmt.Errorf("no fault"))
 	}
 
 	currentReward := reward.ThisEpochRewardReturn{
@@ -4751,12 +6232,16 @@ func (h *actorHarness) reportConsensusFault(rt *mock.Runtime, from addr.Address,
 
 	thisEpochReward := h.epochRewardSmooth.Estimate()
 	penaltyTotal := miner.ConsensusFaultPenalty(thisEpochReward)
+	if fault != nil {
+		penaltyTotal = miner.ConsensusFaultPenaltyForType(fault.Type, thisEpochReward)
+	}
 	rewardTotal := miner.RewardForConsensusSlashReport(thisEpochReward)
 	rt.ExpectSend(from, builtin.MethodSend, nil, rewardTotal, nil, exitcode.Ok)
 
 	// pay fault fee
 	toBurn := big.Sub(penaltyTotal, rewardTotal)
 	rt.ExpectSend(builtin.BurntFundsActorAddr, builtin.MethodSend, nil, toBurn, nil, exitcode.Ok)
+	rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.OnConsensusFault, nil, big.Zero(), nil, exitcode.Ok)
 
 	rt.Call(h.a.ReportConsensusFault, params)
 	rt.Verify()
@@ -4868,7 +6353,7 @@ func (h *actorHarness) onDeadlineCron(rt *mock.Runtime, config *cronConfig) {
 
 	// Re-enrollment for next period.
 	if !config.noEnrollment {
-		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.EnrollCronEvent,
+		rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.EnrollPriorityCronEvent,
 			makeDeadlineCronEventParams(h.t, config.expectedEnrollment), big.Zero(), nil, exitcode.Ok)
 	}
 
@@ -4925,6 +6410,14 @@ func (h *actorHarness) repayDebt(rt *mock.Runtime, value, expectedRepayedFromVes
 	rt.Verify()
 }
 
+func (h *actorHarness) deregister(rt *mock.Runtime) {
+	rt.SetCaller(h.owner, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAddr(h.owner)
+	rt.ExpectSend(builtin.StoragePowerActorAddr, builtin.MethodsPower.DeregisterMiner, nil, big.Zero(), nil, exitcode.Ok)
+	rt.Call(h.a.Deregister, nil)
+	rt.Verify()
+}
+
 func (h *actorHarness) compactPartitions(rt *mock.Runtime, deadline uint64, partitions bitfield.BitField) {
 	param := miner.CompactPartitionsParams{Deadline: deadline, Partitions: partitions}
 
@@ -4935,6 +6428,16 @@ func (h *actorHarness) compactPartitions(rt *mock.Runtime, deadline uint64, part
 	rt.Verify()
 }
 
+func (h *actorHarness) compactTerminatedSectors(rt *mock.Runtime, deadline uint64, partitions bitfield.BitField, maxSectors uint64) {
+	param := miner.CompactTerminatedSectorsParams{Deadline: deadline, Partitions: partitions, MaxSectors: maxSectors}
+
+	rt.ExpectValidateCallerAddr(append(h.controlAddrs, h.owner, h.worker)...)
+	rt.SetCaller(h.worker, builtin.AccountActorCodeID)
+
+	rt.Call(h.a.CompactTerminatedSectors, &param)
+	rt.Verify()
+}
+
 func (h *actorHarness) continuedFaultPenalty(sectors []*miner.SectorOnChainInfo) abi.TokenAmount {
 	_, qa := powerForSectors(h.sectorSize, sectors)
 	return miner.PledgePenaltyForContinuedFault(h.epochRewardSmooth, h.epochQAPowerSmooth, qa)
@@ -5199,6 +6702,16 @@ func makePoStProofs(registeredPoStProof abi.RegisteredPoStProof) []proof.PoStPro
 	return proofs
 }
 
+// Makes a distinct proof of each of a number of partitions.
+func makePartitionPoStProofs(registeredPoStProof abi.RegisteredPoStProof, count int) []proof.PoStProof {
+	proofs := make([]proof.PoStProof, count)
+	for i := range proofs {
+		proofs[i].PoStProof = registeredPoStProof
+		proofs[i].ProofBytes = []byte(fmt.Sprintf("proof%d", i))
+	}
+	return proofs
+}
+
 func makeFaultParamsFromFaultingSectors(t testing.TB, st *miner.State, store adt.Store, faultSectorInfos []*miner.SectorOnChainInfo) *miner.DeclareFaultsParams {
 	deadlines, err := st.LoadDeadlines(store)
 	require.NoError(t, err)
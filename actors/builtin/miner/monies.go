@@ -7,6 +7,7 @@ import (
 	rtt "github.com/filecoin-project/go-state-types/rt"
 
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/runtime"
 	"github.com/filecoin-project/specs-actors/v7/actors/util/math"
 	"github.com/filecoin-project/specs-actors/v7/actors/util/smoothing"
 )
@@ -80,10 +81,130 @@ var DefaultMoniesPolicy = MoniesPolicy{
 
 var CurrentMoniesPolicy = DefaultMoniesPolicy
 
+// Evaluates the pledge, deposit and penalty formulas under explicit monies and network policies.
+// The methods are pure functions of the policies and their arguments, so that tools modeling the network's
+// economics outside the VM can evaluate the consensus formulas under any policy rather than copying them.
+// The package-level functions of the same names evaluate them under the current policies.
+type MoniesCalculator struct {
+	Monies  MoniesPolicy
+	Network builtin.NetworkPolicy
+}
+
+// A calculator for CurrentMoniesPolicy and builtin.CurrentNetworkPolicy.
+func CurrentMoniesCalculator() MoniesCalculator {
+	return MoniesCalculator{Monies: CurrentMoniesPolicy, Network: builtin.CurrentNetworkPolicy}
+}
+
+func (c MoniesCalculator) PreCommitDepositProjectionPeriod() abi.ChainEpoch {
+	return abi.ChainEpoch(c.Monies.PreCommitDepositFactor) * c.Network.EpochsInDay()
+}
+
+func (c MoniesCalculator) InitialPledgeProjectionPeriod() abi.ChainEpoch {
+	return abi.ChainEpoch(c.Monies.InitialPledgeFactor) * c.Network.EpochsInDay()
+}
+
+func (c MoniesCalculator) ContinuedFaultProjectionPeriod() abi.ChainEpoch {
+	return abi.ChainEpoch((uint64(c.Network.EpochsInDay()) * c.Monies.ContinuedFaultFactorNum) / c.Monies.ContinuedFaultFactorDenom)
+}
+
+// FF + 2BR
+func (c MoniesCalculator) InvalidWindowPoStProjectionPeriod() abi.ChainEpoch {
+	return c.ContinuedFaultProjectionPeriod() + 2*c.Network.EpochsInDay()
+}
+
+// The penalty for a sector continuing faulty for another proving period.
+// It is a projection of the expected reward earned by the sector.
+// Also known as "FF(t)"
+func (c MoniesCalculator) PledgePenaltyForContinuedFault(rewardEstimate, networkQAPowerEstimate smoothing.FilterEstimate, qaSectorPower abi.StoragePower) abi.TokenAmount {
+	return ExpectedRewardForPower(rewardEstimate, networkQAPowerEstimate, qaSectorPower, c.ContinuedFaultProjectionPeriod())
+}
+
+// Lower bound on the penalty for a terminating sector.
+// It is a projection of the expected reward earned by the sector.
+// Also known as "SP(t)"
+func (c MoniesCalculator) PledgePenaltyForTerminationLowerBound(rewardEstimate, networkQAPowerEstimate smoothing.FilterEstimate, qaSectorPower abi.StoragePower) abi.TokenAmount {
+	return ExpectedRewardForPower(rewardEstimate, networkQAPowerEstimate, qaSectorPower, c.Monies.TerminationPenaltyLowerBoundProjectionPeriod)
+}
+
+// Penalty to locked pledge collateral for the termination of a sector before scheduled expiry.
+// SectorAge is the time between the sector's activation and termination.
+// replacedDayReward and replacedSectorAge are the day reward and age of the replaced sector in a capacity upgrade.
+// They must be zero if no upgrade occurred.
+func (c MoniesCalculator) PledgePenaltyForTermination(dayReward abi.TokenAmount, sectorAge abi.ChainEpoch,
+	twentyDayRewardAtActivation abi.TokenAmount, networkQAPowerEstimate smoothing.FilterEstimate,
+	qaSectorPower abi.StoragePower, rewardEstimate smoothing.FilterEstimate, replacedDayReward abi.TokenAmount,
+	replacedSectorAge abi.ChainEpoch) abi.TokenAmount {
+	// max(SP(t), BR(StartEpoch, 20d) + BR(StartEpoch, 1d) * terminationRewardFactor * min(SectorAgeInDays, 140))
+	// and sectorAgeInDays = sectorAge / EpochsInDay
+	lifetimeCap := abi.ChainEpoch(c.Monies.TerminationLifetimeCap) * c.Network.EpochsInDay()
+	cappedSectorAge := minEpoch(sectorAge, lifetimeCap)
+	// expected reward for lifetime of new sector (epochs*AttoFIL/day)
+	expectedReward := big.Mul(dayReward, big.NewInt(int64(cappedSectorAge)))
+	// if lifetime under cap and this sector replaced capacity, add expected reward for old sector's lifetime up to cap
+	relevantReplacedAge := minEpoch(replacedSectorAge, lifetimeCap-cappedSectorAge)
+	expectedReward = big.Add(expectedReward, big.Mul(replacedDayReward, big.NewInt(int64(relevantReplacedAge))))
+
+	penalizedReward := big.Mul(expectedReward, c.Monies.TerminationRewardFactor.Numerator)
+
+	return big.Max(
+		c.PledgePenaltyForTerminationLowerBound(rewardEstimate, networkQAPowerEstimate, qaSectorPower),
+		big.Add(
+			twentyDayRewardAtActivation,
+			big.Div(
+				penalizedReward,
+				big.Mul(big.NewInt(int64(c.Network.EpochsInDay())), c.Monies.TerminationRewardFactor.Denominator)))) // (epochs*AttoFIL/day -> AttoFIL)
+}
+
+// The penalty for optimistically proving a sector with an invalid window PoSt.
+func (c MoniesCalculator) PledgePenaltyForInvalidWindowPoSt(rewardEstimate, networkQAPowerEstimate smoothing.FilterEstimate, qaSectorPower abi.StoragePower) abi.TokenAmount {
+	return big.Add(
+		ExpectedRewardForPower(rewardEstimate, networkQAPowerEstimate, qaSectorPower, c.InvalidWindowPoStProjectionPeriod()),
+		c.Monies.BasePenaltyForDisputedWindowPoSt,
+	)
+}
+
+// Computes the PreCommit deposit given sector qa weight and current network conditions.
+// PreCommit Deposit = BR(PreCommitDepositProjectionPeriod)
+func (c MoniesCalculator) PreCommitDepositForPower(rewardEstimate, networkQAPowerEstimate smoothing.FilterEstimate, qaSectorPower abi.StoragePower) abi.TokenAmount {
+	return ExpectedRewardForPowerClampedAtAttoFIL(rewardEstimate, networkQAPowerEstimate, qaSectorPower, c.PreCommitDepositProjectionPeriod())
+}
+
+// Computes the pledge requirement for committing new quality-adjusted power to the network, given the current
+// network total and baseline power, per-epoch  reward, and circulating token supply.
+// The pledge comprises two parts:
+// - storage pledge, aka IP base: a multiple of the reward expected to be earned by newly-committed power
+// - consensus pledge, aka additional IP: a pro-rata fraction of the circulating money supply
+//
+// IP = IPBase(t) + AdditionalIP(t)
+// IPBase(t) = BR(t, InitialPledgeProjectionPeriod)
+// AdditionalIP(t) = LockTarget(t)*PledgeShare(t)
+// LockTarget = (LockTargetFactorNum / LockTargetFactorDenom) * FILCirculatingSupply(t)
+// PledgeShare(t) = sectorQAPower / max(BaselinePower(t), NetworkQAPower(t))
+func (c MoniesCalculator) InitialPledgeForPower(qaPower, baselinePower abi.StoragePower, rewardEstimate, networkQAPowerEstimate smoothing.FilterEstimate, circulatingSupply abi.TokenAmount) abi.TokenAmount {
+	ipBase := ExpectedRewardForPowerClampedAtAttoFIL(rewardEstimate, networkQAPowerEstimate, qaPower, c.InitialPledgeProjectionPeriod())
+
+	lockTargetNum := big.Mul(c.Monies.InitialPledgeLockTarget.Numerator, circulatingSupply)
+	lockTargetDenom := c.Monies.InitialPledgeLockTarget.Denominator
+	pledgeShareNum := qaPower
+	networkQAPower := networkQAPowerEstimate.Estimate()
+	pledgeShareDenom := big.Max(big.Max(networkQAPower, baselinePower), qaPower) // use qaPower in case others are 0
+	additionalIPNum := big.Mul(lockTargetNum, pledgeShareNum)
+	additionalIPDenom := big.Mul(lockTargetDenom, pledgeShareDenom)
+	additionalIP := big.Div(additionalIPNum, additionalIPDenom)
+
+	nominalPledge := big.Add(ipBase, additionalIP)
+	spaceRacePledgeCap := big.Mul(InitialPledgeMaxPerByte, qaPower)
+	return big.Min(nominalPledge, spaceRacePledgeCap)
+}
+
 func BaseRewardForDisputedWindowPoSt() big.Int {
 	return CurrentMoniesPolicy.BaseRewardForDisputedWindowPoSt
 }
 
+func BasePenaltyForDisputedWindowPoSt() big.Int {
+	return CurrentMoniesPolicy.BasePenaltyForDisputedWindowPoSt
+}
+
 func BatchBalancer() big.Int {
 	return CurrentMoniesPolicy.BatchBalancer
 }
@@ -98,11 +219,11 @@ func TerminationRewardFactor() builtin.BigFrac {
 }
 
 func PreCommitDepositProjectionPeriod() abi.ChainEpoch {
-	return abi.ChainEpoch(CurrentMoniesPolicy.PreCommitDepositFactor) * builtin.EpochsInDay()
+	return CurrentMoniesCalculator().PreCommitDepositProjectionPeriod()
 }
 
 func InitialPledgeProjectionPeriod() abi.ChainEpoch {
-	return abi.ChainEpoch(CurrentMoniesPolicy.InitialPledgeFactor) * builtin.EpochsInDay()
+	return CurrentMoniesCalculator().InitialPledgeProjectionPeriod()
 }
 
 // Cap on initial pledge requirement for sectors.
@@ -111,18 +232,27 @@ func InitialPledgeProjectionPeriod() abi.ChainEpoch {
 var InitialPledgeMaxPerByte = big.Div(big.NewInt(1e18), big.NewInt(32<<30))
 
 func ContinuedFaultProjectionPeriod() abi.ChainEpoch {
-	return abi.ChainEpoch((uint64(builtin.EpochsInDay()) * CurrentMoniesPolicy.ContinuedFaultFactorNum) / CurrentMoniesPolicy.ContinuedFaultFactorDenom)
+	return CurrentMoniesCalculator().ContinuedFaultProjectionPeriod()
 }
 
 // FF + 2BR
 // TODO: Do we need configure it?
 func InvalidWindowPoStProjectionPeriod() abi.ChainEpoch {
-	return ContinuedFaultProjectionPeriod() + 2*builtin.EpochsInDay()
+	return CurrentMoniesCalculator().InvalidWindowPoStProjectionPeriod()
 }
 
 // Multiplier of whole per-winner rewards for a consensus fault penalty.
 const ConsensusFaultFactor = 5
 
+// Multipliers of whole per-winner rewards for a consensus fault penalty, by type of fault.
+// Types not listed are penalized with ConsensusFaultFactor.
+// This is mutable to allow configuration of testing and development networks.
+var ConsensusFaultFactors = map[runtime.ConsensusFaultType]int64{
+	runtime.ConsensusFaultDoubleForkMining: ConsensusFaultFactor,
+	runtime.ConsensusFaultParentGrinding:   ConsensusFaultFactor,
+	runtime.ConsensusFaultTimeOffsetMining: ConsensusFaultFactor,
+}
+
 // Fraction of total reward (block reward + gas reward) to be locked up as of V6
 var LockedRewardFactorNum = big.NewInt(75)
 var LockedRewardFactorDenom = big.NewInt(100)
@@ -155,89 +285,86 @@ func ExpectedRewardForPowerClampedAtAttoFIL(rewardEstimate, networkQAPowerEstima
 	return br
 }
 
-// The penalty for a sector continuing faulty for another proving period.
-// It is a projection of the expected reward earned by the sector.
-// Also known as "FF(t)"
+// The penalty for a sector continuing faulty for another proving period, under the current policies.
+// See MoniesCalculator.PledgePenaltyForContinuedFault.
 func PledgePenaltyForContinuedFault(rewardEstimate, networkQAPowerEstimate smoothing.FilterEstimate, qaSectorPower abi.StoragePower) abi.TokenAmount {
-	return ExpectedRewardForPower(rewardEstimate, networkQAPowerEstimate, qaSectorPower, ContinuedFaultProjectionPeriod())
+	return CurrentMoniesCalculator().PledgePenaltyForContinuedFault(rewardEstimate, networkQAPowerEstimate, qaSectorPower)
 }
 
-// Lower bound on the penalty for a terminating sector.
-// It is a projection of the expected reward earned by the sector.
-// Also known as "SP(t)"
+// Lower bound on the penalty for a terminating sector, under the current policies.
+// See MoniesCalculator.PledgePenaltyForTerminationLowerBound.
 func PledgePenaltyForTerminationLowerBound(rewardEstimate, networkQAPowerEstimate smoothing.FilterEstimate, qaSectorPower abi.StoragePower) abi.TokenAmount {
-	return ExpectedRewardForPower(rewardEstimate, networkQAPowerEstimate, qaSectorPower, CurrentMoniesPolicy.TerminationPenaltyLowerBoundProjectionPeriod)
+	return CurrentMoniesCalculator().PledgePenaltyForTerminationLowerBound(rewardEstimate, networkQAPowerEstimate, qaSectorPower)
 }
 
-// Penalty to locked pledge collateral for the termination of a sector before scheduled expiry.
-// SectorAge is the time between the sector's activation and termination.
-// replacedDayReward and replacedSectorAge are the day reward and age of the replaced sector in a capacity upgrade.
-// They must be zero if no upgrade occurred.
+// Penalty to locked pledge collateral for the termination of a sector before scheduled expiry, under the current
+// policies. See MoniesCalculator.PledgePenaltyForTermination.
 func PledgePenaltyForTermination(dayReward abi.TokenAmount, sectorAge abi.ChainEpoch,
 	twentyDayRewardAtActivation abi.TokenAmount, networkQAPowerEstimate smoothing.FilterEstimate,
 	qaSectorPower abi.StoragePower, rewardEstimate smoothing.FilterEstimate, replacedDayReward abi.TokenAmount,
 	replacedSectorAge abi.ChainEpoch) abi.TokenAmount {
-	// max(SP(t), BR(StartEpoch, 20d) + BR(StartEpoch, 1d) * terminationRewardFactor * min(SectorAgeInDays, 140))
-	// and sectorAgeInDays = sectorAge / EpochsInDay
-	lifetimeCap := abi.ChainEpoch(CurrentMoniesPolicy.TerminationLifetimeCap) * builtin.EpochsInDay()
-	cappedSectorAge := minEpoch(sectorAge, lifetimeCap)
-	// expected reward for lifetime of new sector (epochs*AttoFIL/day)
-	expectedReward := big.Mul(dayReward, big.NewInt(int64(cappedSectorAge)))
-	// if lifetime under cap and this sector replaced capacity, add expected reward for old sector's lifetime up to cap
-	relevantReplacedAge := minEpoch(replacedSectorAge, lifetimeCap-cappedSectorAge)
-	expectedReward = big.Add(expectedReward, big.Mul(replacedDayReward, big.NewInt(int64(relevantReplacedAge))))
+	return CurrentMoniesCalculator().PledgePenaltyForTermination(dayReward, sectorAge, twentyDayRewardAtActivation,
+		networkQAPowerEstimate, qaSectorPower, rewardEstimate, replacedDayReward, replacedSectorAge)
+}
 
-	penalizedReward := big.Mul(expectedReward, CurrentMoniesPolicy.TerminationRewardFactor.Numerator)
+// The total penalty for terminating sectors at an epoch, as charged by TerminateSectors and by the termination of
+// sectors that have been faulty for too long.
+// The reward and network power estimates are the smoothed estimates at the epoch of termination.
+func PledgePenaltyForSectorsTermination(sectorSize abi.SectorSize, currEpoch abi.ChainEpoch,
+	rewardEstimate, networkQAPowerEstimate smoothing.FilterEstimate, sectors []*SectorOnChainInfo) abi.TokenAmount {
+	totalFee := big.Zero()
+	for _, s := range sectors {
+		sectorPower := QAPowerForSector(sectorSize, s)
+		fee := PledgePenaltyForTermination(s.ExpectedDayReward, currEpoch-s.Activation, s.ExpectedStoragePledge,
+			networkQAPowerEstimate, sectorPower, rewardEstimate, s.ReplacedDayReward, s.ReplacedSectorAge)
+		totalFee = big.Add(fee, totalFee)
+	}
+	return totalFee
+}
 
-	return big.Max(
-		PledgePenaltyForTerminationLowerBound(rewardEstimate, networkQAPowerEstimate, qaSectorPower),
-		big.Add(
-			twentyDayRewardAtActivation,
-			big.Div(
-				penalizedReward,
-				big.Mul(big.NewInt(int64(builtin.EpochsInDay())), CurrentMoniesPolicy.TerminationRewardFactor.Denominator)))) // (epochs*AttoFIL/day -> AttoFIL)
+// The costs to a miner of terminating a sector, or of leaving it faulty.
+type SectorTerminationCost struct {
+	// Penalty for terminating the sector, deducted from the miner's funds (see PledgePenaltyForTermination).
+	TerminationFee abi.TokenAmount
+	// Lower bound on the termination fee, "SP(t)".
+	TerminationFeeLowerBound abi.TokenAmount
+	// Expected reward per day for the sector, as recorded at activation, from which the fee is projected.
+	ExpectedDayReward abi.TokenAmount
+	// Pledge locked for the sector, which is released on termination.
+	InitialPledge abi.TokenAmount
+	// Penalty for the sector remaining faulty for another proving period, "FF(t)".
+	ContinuedFaultFee abi.TokenAmount
 }
 
-// The penalty for optimistically proving a sector with an invalid window PoSt.
+// Estimates the costs of terminating a sector at an epoch, from the smoothed reward and network power estimates
+// at that epoch.
+func SectorTerminationCostAt(sectorSize abi.SectorSize, currEpoch abi.ChainEpoch,
+	rewardEstimate, networkQAPowerEstimate smoothing.FilterEstimate, sector *SectorOnChainInfo) SectorTerminationCost {
+	sectorPower := QAPowerForSector(sectorSize, sector)
+	return SectorTerminationCost{
+		TerminationFee:           PledgePenaltyForSectorsTermination(sectorSize, currEpoch, rewardEstimate, networkQAPowerEstimate, []*SectorOnChainInfo{sector}),
+		TerminationFeeLowerBound: PledgePenaltyForTerminationLowerBound(rewardEstimate, networkQAPowerEstimate, sectorPower),
+		ExpectedDayReward:        sector.ExpectedDayReward,
+		InitialPledge:            sector.InitialPledge,
+		ContinuedFaultFee:        PledgePenaltyForContinuedFault(rewardEstimate, networkQAPowerEstimate, sectorPower),
+	}
+}
+
+// The penalty for optimistically proving a sector with an invalid window PoSt, under the current policies.
+// See MoniesCalculator.PledgePenaltyForInvalidWindowPoSt.
 func PledgePenaltyForInvalidWindowPoSt(rewardEstimate, networkQAPowerEstimate smoothing.FilterEstimate, qaSectorPower abi.StoragePower) abi.TokenAmount {
-	return big.Add(
-		ExpectedRewardForPower(rewardEstimate, networkQAPowerEstimate, qaSectorPower, InvalidWindowPoStProjectionPeriod()),
-		CurrentMoniesPolicy.BasePenaltyForDisputedWindowPoSt,
-	)
+	return CurrentMoniesCalculator().PledgePenaltyForInvalidWindowPoSt(rewardEstimate, networkQAPowerEstimate, qaSectorPower)
 }
 
-// Computes the PreCommit deposit given sector qa weight and current network conditions.
-// PreCommit Deposit = BR(PreCommitDepositProjectionPeriod)
+// Computes the PreCommit deposit under the current policies. See MoniesCalculator.PreCommitDepositForPower.
 func PreCommitDepositForPower(rewardEstimate, networkQAPowerEstimate smoothing.FilterEstimate, qaSectorPower abi.StoragePower) abi.TokenAmount {
-	return ExpectedRewardForPowerClampedAtAttoFIL(rewardEstimate, networkQAPowerEstimate, qaSectorPower, PreCommitDepositProjectionPeriod())
+	return CurrentMoniesCalculator().PreCommitDepositForPower(rewardEstimate, networkQAPowerEstimate, qaSectorPower)
 }
 
-// Computes the pledge requirement for committing new quality-adjusted power to the network, given the current
-// network total and baseline power, per-epoch  reward, and circulating token supply.
-// The pledge comprises two parts:
-// - storage pledge, aka IP base: a multiple of the reward expected to be earned by newly-committed power
-// - consensus pledge, aka additional IP: a pro-rata fraction of the circulating money supply
-//
-// IP = IPBase(t) + AdditionalIP(t)
-// IPBase(t) = BR(t, InitialPledgeProjectionPeriod)
-// AdditionalIP(t) = LockTarget(t)*PledgeShare(t)
-// LockTarget = (LockTargetFactorNum / LockTargetFactorDenom) * FILCirculatingSupply(t)
-// PledgeShare(t) = sectorQAPower / max(BaselinePower(t), NetworkQAPower(t))
+// Computes the pledge requirement for committing new quality-adjusted power under the current policies.
+// See MoniesCalculator.InitialPledgeForPower.
 func InitialPledgeForPower(qaPower, baselinePower abi.StoragePower, rewardEstimate, networkQAPowerEstimate smoothing.FilterEstimate, circulatingSupply abi.TokenAmount) abi.TokenAmount {
-	ipBase := ExpectedRewardForPowerClampedAtAttoFIL(rewardEstimate, networkQAPowerEstimate, qaPower, InitialPledgeProjectionPeriod())
-
-	lockTargetNum := big.Mul(CurrentMoniesPolicy.InitialPledgeLockTarget.Numerator, circulatingSupply)
-	lockTargetDenom := CurrentMoniesPolicy.InitialPledgeLockTarget.Denominator
-	pledgeShareNum := qaPower
-	networkQAPower := networkQAPowerEstimate.Estimate()
-	pledgeShareDenom := big.Max(big.Max(networkQAPower, baselinePower), qaPower) // use qaPower in case others are 0
-	additionalIPNum := big.Mul(lockTargetNum, pledgeShareNum)
-	additionalIPDenom := big.Mul(lockTargetDenom, pledgeShareDenom)
-	additionalIP := big.Div(additionalIPNum, additionalIPDenom)
-
-	nominalPledge := big.Add(ipBase, additionalIP)
-	spaceRacePledgeCap := big.Mul(InitialPledgeMaxPerByte, qaPower)
-	return big.Min(nominalPledge, spaceRacePledgeCap)
+	return CurrentMoniesCalculator().InitialPledgeForPower(qaPower, baselinePower, rewardEstimate, networkQAPowerEstimate, circulatingSupply)
 }
 
 // Repays all fee debt and then verifies that the miner has amount needed to cover
@@ -256,8 +383,21 @@ func RepayDebtsOrAbort(rt Runtime, st *State) abi.TokenAmount {
 }
 
 func ConsensusFaultPenalty(thisEpochReward abi.TokenAmount) abi.TokenAmount {
+	return consensusFaultPenalty(ConsensusFaultFactor, thisEpochReward)
+}
+
+// The penalty for a consensus fault of some type.
+func ConsensusFaultPenaltyForType(faultType runtime.ConsensusFaultType, thisEpochReward abi.TokenAmount) abi.TokenAmount {
+	factor, ok := ConsensusFaultFactors[faultType]
+	if !ok {
+		factor = ConsensusFaultFactor
+	}
+	return consensusFaultPenalty(factor, thisEpochReward)
+}
+
+func consensusFaultPenalty(factor int64, thisEpochReward abi.TokenAmount) abi.TokenAmount {
 	return big.Div(
-		big.Mul(thisEpochReward, big.NewInt(ConsensusFaultFactor)),
+		big.Mul(thisEpochReward, big.NewInt(factor)),
 		big.NewInt(builtin.ExpectedLeadersPerEpoch()),
 	)
 }
@@ -1,11 +1,14 @@
 package miner_test
 
 import (
+	"bytes"
+	"fmt"
 	"testing"
 
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/stretchr/testify/assert"
+	"github.com/xorcare/golden"
 
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
@@ -143,6 +146,41 @@ func TestPledgePenaltyForTermination(t *testing.T) {
 	})
 }
 
+func TestSectorTerminationCost(t *testing.T) {
+	rewardEstimate := smoothing.TestingConstantEstimate(abi.NewTokenAmount(1 << 50))
+	powerEstimate := smoothing.TestingConstantEstimate(abi.NewStoragePower(1 << 50))
+	sectorSize := abi.SectorSize(32 << 30)
+
+	dayReward := abi.NewTokenAmount(1 << 40)
+	sector := &miner.SectorOnChainInfo{
+		SectorNumber:          1,
+		Activation:            100,
+		Expiration:            100 + 365*builtin.EpochsInDay(),
+		DealWeight:            big.Zero(),
+		VerifiedDealWeight:    big.Zero(),
+		InitialPledge:         big.Mul(dayReward, big.NewInt(miner.InitialPledgeFactor())),
+		ExpectedDayReward:     dayReward,
+		ExpectedStoragePledge: big.Mul(dayReward, big.NewInt(20)),
+		ReplacedDayReward:     big.Zero(),
+	}
+	epoch := sector.Activation + 30*builtin.EpochsInDay()
+	sectorPower := miner.QAPowerForSector(sectorSize, sector)
+
+	cost := miner.SectorTerminationCostAt(sectorSize, epoch, rewardEstimate, powerEstimate, sector)
+	expectedFee := miner.PledgePenaltyForTermination(dayReward, epoch-sector.Activation, sector.ExpectedStoragePledge,
+		powerEstimate, sectorPower, rewardEstimate, big.Zero(), 0)
+	assert.Equal(t, expectedFee, cost.TerminationFee)
+	assert.Equal(t, miner.PledgePenaltyForTerminationLowerBound(rewardEstimate, powerEstimate, sectorPower), cost.TerminationFeeLowerBound)
+	assert.Equal(t, dayReward, cost.ExpectedDayReward)
+	assert.Equal(t, sector.InitialPledge, cost.InitialPledge)
+	assert.Equal(t, miner.PledgePenaltyForContinuedFault(rewardEstimate, powerEstimate, sectorPower), cost.ContinuedFaultFee)
+	assert.True(t, cost.TerminationFee.GreaterThanEqual(cost.TerminationFeeLowerBound))
+
+	// The fee for many sectors is the sum of their fees.
+	total := miner.PledgePenaltyForSectorsTermination(sectorSize, epoch, rewardEstimate, powerEstimate, []*miner.SectorOnChainInfo{sector, sector})
+	assert.Equal(t, big.Mul(cost.TerminationFee, big.NewInt(2)), total)
+}
+
 func TestNegativeBRClamp(t *testing.T) {
 	epochTargetReward := abi.NewTokenAmount(1 << 50)
 	qaSectorPower := abi.NewStoragePower(1 << 36)
@@ -282,3 +320,85 @@ func TestAggregateNetworkFee(t *testing.T) {
 		assert.Equal(t, atTwentyBaseFeeProve, big.Mul(big.NewInt(3), atTwentyBaseFeePre))
 	})
 }
+
+func TestMoniesCalculator(t *testing.T) {
+	rewardEstimate := smoothing.TestingEstimate(abi.NewTokenAmount(1<<50), abi.NewTokenAmount(-1<<20))
+	powerEstimate := smoothing.TestingEstimate(abi.NewStoragePower(1<<60), abi.NewStoragePower(1<<40))
+	qaSectorPower := abi.NewStoragePower(1 << 36)
+	baselinePower := abi.NewStoragePower(1 << 59)
+	circulatingSupply := big.Mul(big.NewInt(300_000_000), builtin.TokenPrecision)
+
+	t.Run("current calculator evaluates the package functions", func(t *testing.T) {
+		calc := miner.CurrentMoniesCalculator()
+		assert.Equal(t, miner.InitialPledgeForPower(qaSectorPower, baselinePower, rewardEstimate, powerEstimate, circulatingSupply),
+			calc.InitialPledgeForPower(qaSectorPower, baselinePower, rewardEstimate, powerEstimate, circulatingSupply))
+		assert.Equal(t, miner.PreCommitDepositForPower(rewardEstimate, powerEstimate, qaSectorPower),
+			calc.PreCommitDepositForPower(rewardEstimate, powerEstimate, qaSectorPower))
+		assert.Equal(t, miner.PledgePenaltyForContinuedFault(rewardEstimate, powerEstimate, qaSectorPower),
+			calc.PledgePenaltyForContinuedFault(rewardEstimate, powerEstimate, qaSectorPower))
+		assert.Equal(t, miner.PledgePenaltyForInvalidWindowPoSt(rewardEstimate, powerEstimate, qaSectorPower),
+			calc.PledgePenaltyForInvalidWindowPoSt(rewardEstimate, powerEstimate, qaSectorPower))
+	})
+
+	t.Run("evaluates under explicit policies", func(t *testing.T) {
+		// Halving the epoch duration doubles the epochs in each projection period, as does doubling the factor.
+		shortEpochs := miner.CurrentMoniesCalculator()
+		shortEpochs.Network = builtin.MakeNetworkPolicy(15, 5)
+		doubleFactor := miner.CurrentMoniesCalculator()
+		doubleFactor.Monies.PreCommitDepositFactor *= 2
+
+		assert.Equal(t, 2*miner.PreCommitDepositProjectionPeriod(), shortEpochs.PreCommitDepositProjectionPeriod())
+		assert.Equal(t, 2*miner.PreCommitDepositProjectionPeriod(), doubleFactor.PreCommitDepositProjectionPeriod())
+		deposit := shortEpochs.PreCommitDepositForPower(rewardEstimate, powerEstimate, qaSectorPower)
+		assert.Equal(t, deposit, doubleFactor.PreCommitDepositForPower(rewardEstimate, powerEstimate, qaSectorPower))
+		assert.True(t, deposit.GreaterThan(miner.PreCommitDepositForPower(rewardEstimate, powerEstimate, qaSectorPower)))
+
+		// The current policies are unchanged.
+		assert.Equal(t, miner.DefaultMoniesPolicy.PreCommitDepositFactor, miner.CurrentMoniesPolicy.PreCommitDepositFactor)
+		assert.Equal(t, builtin.DefaultNetworkPolicy.EpochsInDay(), builtin.EpochsInDay())
+	})
+}
+
+// Records the pledge, deposit and penalty formulas over a range of inputs, to detect any change in their values.
+func TestPledgeAndPenaltyFormulas(t *testing.T) {
+	calc := miner.MoniesCalculator{Monies: miner.DefaultMoniesPolicy, Network: builtin.DefaultNetworkPolicy}
+	b := &bytes.Buffer{}
+	b.WriteString("qa_power,network_qa_power,epoch_reward,circulating_supply,sector_age," +
+		"initial_pledge,precommit_deposit,continued_fault,termination_lower_bound,termination,invalid_window_post\n")
+	for _, qaPower := range []abi.StoragePower{
+		abi.NewStoragePower(32 << 30),
+		abi.NewStoragePower(10 * 64 << 30),
+	} {
+		for _, networkQAPower := range []abi.StoragePower{
+			abi.NewStoragePower(1 << 60),
+			big.Mul(big.NewInt(10), abi.NewStoragePower(1<<60)),
+			big.Mul(big.NewInt(20), abi.NewStoragePower(1<<60)),
+		} {
+			for _, epochReward := range []abi.TokenAmount{
+				big.Mul(big.NewInt(20), builtin.TokenPrecision),
+				big.Mul(big.NewInt(100), builtin.TokenPrecision),
+			} {
+				circulatingSupply := big.Mul(big.NewInt(300_000_000), builtin.TokenPrecision)
+				baselinePower := abi.NewStoragePower(1 << 60)
+				rewardEstimate := smoothing.TestingEstimate(epochReward, big.Div(epochReward, big.NewInt(-1_000_000)))
+				powerEstimate := smoothing.TestingEstimate(networkQAPower, big.Div(networkQAPower, big.NewInt(1_000_000)))
+
+				initialPledge := calc.InitialPledgeForPower(qaPower, baselinePower, rewardEstimate, powerEstimate, circulatingSupply)
+				deposit := calc.PreCommitDepositForPower(rewardEstimate, powerEstimate, qaPower)
+				continuedFault := calc.PledgePenaltyForContinuedFault(rewardEstimate, powerEstimate, qaPower)
+				lowerBound := calc.PledgePenaltyForTerminationLowerBound(rewardEstimate, powerEstimate, qaPower)
+				invalidPoSt := calc.PledgePenaltyForInvalidWindowPoSt(rewardEstimate, powerEstimate, qaPower)
+				dayReward := miner.ExpectedRewardForPower(rewardEstimate, powerEstimate, qaPower, calc.Network.EpochsInDay())
+				twentyDayReward := miner.ExpectedRewardForPower(rewardEstimate, powerEstimate, qaPower, calc.InitialPledgeProjectionPeriod())
+				for _, sectorAge := range []abi.ChainEpoch{0, 180 * calc.Network.EpochsInDay(), 540 * calc.Network.EpochsInDay()} {
+					termination := calc.PledgePenaltyForTermination(dayReward, sectorAge, twentyDayReward, powerEstimate, qaPower,
+						rewardEstimate, big.Zero(), 0)
+					fmt.Fprintf(b, "%s,%s,%s,%s,%d,%s,%s,%s,%s,%s,%s\n", qaPower, networkQAPower, epochReward, circulatingSupply,
+						sectorAge, initialPledge, deposit, continuedFault, lowerBound, termination, invalidPoSt)
+				}
+			}
+		}
+	}
+
+	golden.Assert(t, b.Bytes())
+}
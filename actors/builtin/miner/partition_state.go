@@ -772,6 +772,38 @@ func (p *Partition) PopEarlyTerminations(store adt.Store, maxSectors uint64) (re
 	return result, earlyTerminatedQ.Length() > 0, nil
 }
 
+// Removes up to maxSectors terminated sectors from the partition entirely, returning the sectors removed and
+// whether any terminated sectors remain.
+// Terminated sectors hold no power and are not in the expiration queue, so only the sector bitfields change.
+// The caller must ensure none of the sectors have pending early terminations.
+func (p *Partition) RemoveTerminatedSectors(maxSectors uint64) (removed bitfield.BitField, hasMore bool, err error) {
+	count, err := p.Terminated.Count()
+	if err != nil {
+		return bitfield.BitField{}, false, xerrors.Errorf("failed to count terminated sectors: %w", err)
+	}
+	removed = p.Terminated
+	if count > maxSectors {
+		if removed, err = p.Terminated.Slice(0, maxSectors); err != nil {
+			return bitfield.BitField{}, false, xerrors.Errorf("failed to slice terminated sectors: %w", err)
+		}
+		hasMore = true
+	}
+
+	if p.Sectors, err = bitfield.SubtractBitField(p.Sectors, removed); err != nil {
+		return bitfield.BitField{}, false, xerrors.Errorf("failed to remove terminated sectors: %w", err)
+	}
+	if p.Terminated, err = bitfield.SubtractBitField(p.Terminated, removed); err != nil {
+		return bitfield.BitField{}, false, xerrors.Errorf("failed to remove terminated sectors: %w", err)
+	}
+
+	// check invariants
+	if err := p.ValidateState(); err != nil {
+		return bitfield.BitField{}, false, err
+	}
+
+	return removed, hasMore, nil
+}
+
 // Discovers how skipped faults declared during post intersect with existing faults and recoveries, records the
 // new faults in state.
 // Returns the amount of power newly faulty, or declared recovered but faulty again.
@@ -5,10 +5,12 @@ import (
 
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/network"
 	"github.com/ipfs/go-cid"
 	mh "github.com/multiformats/go-multihash"
 
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
 )
 
 type Policy struct {
@@ -90,6 +92,18 @@ type Policy struct {
 
 	// The vesting schedule for total rewards (block reward + gas reward) earned by a block producer.
 	RewardVestingSpec VestSpec
+
+	// The minimum and maximum number of sectors which may be proven by a single aggregate prove-commit.
+	// The minimum prevents aggregation of batches too small to benefit from it.
+	MinAggregatedSectors uint64
+	MaxAggregatedSectors uint64
+
+	// The maximum size in bytes of an aggregate prove-commit proof.
+	MaxAggregateProofSize uint64
+
+	// The maximum number of per-partition Window PoSt proofs which may be verified in a single batch.
+	// This bounds the verification work of a single submission.
+	MaxWindowPoStBatchSize uint64
 }
 
 func checkParams(wPoStProvingPeriod abi.ChainEpoch, wPoStChallengeWindow abi.ChainEpoch, wPoStPeriodDeadlines uint64,
@@ -188,11 +202,17 @@ func MakePolicy(wPoStProvingPeriod abi.ChainEpoch,
 	minSectorExpiration abi.ChainEpoch,
 	maxSectorExpirationExtension abi.ChainEpoch,
 	dealLimitDenominator uint64,
-	rewardVestingSpec VestSpec) Policy {
+	rewardVestingSpec VestSpec,
+	minAggregatedSectors uint64,
+	maxAggregatedSectors uint64,
+	maxAggregateProofSize uint64,
+	maxWindowPoStBatchSize uint64) Policy {
 
 	checkParams(wPoStProvingPeriod, wPoStChallengeWindow,
 		wPoStPeriodDeadlines, wPoStDisputeWindow,
 		chainFinality, wPoStChallengeLookback)
+	checkAggregateParams(minAggregatedSectors, maxAggregatedSectors)
+	checkVestSpec(rewardVestingSpec)
 
 	return Policy{
 		wPoStProvingPeriod,
@@ -213,6 +233,10 @@ func MakePolicy(wPoStProvingPeriod abi.ChainEpoch,
 		maxSectorExpirationExtension,
 		dealLimitDenominator,
 		rewardVestingSpec,
+		minAggregatedSectors,
+		maxAggregatedSectors,
+		maxAggregateProofSize,
+		maxWindowPoStBatchSize,
 	}
 }
 
@@ -245,6 +269,10 @@ var DefaultMinerPolicy = MakePolicy(DefaultWPoStProvingPeriod,
 		StepDuration: builtin.DefaultNetworkPolicy.EpochsInDay(),
 		Quantization: 12 * builtin.DefaultNetworkPolicy.EpochsInHour(),
 	},
+	4,
+	819,
+	81960,
+	10,
 )
 
 var CurrentMinerPolicy = DefaultMinerPolicy
@@ -316,6 +344,9 @@ const DeclarationsMax = AddressedPartitionsMax
 // Maximum number of control addresses a miner may register.
 const MaxControlAddresses = 10
 
+// Maximum number of worker address changes a miner may have scheduled at once.
+const MaxPendingWorkerKeyChanges = 4
+
 // The maximum number of partitions that may be required to be loaded in a single invocation,
 // when all the sector infos for the partitions will be loaded.
 func loadPartitionsSectorsMax(partitionSectorCount uint64) uint64 {
@@ -343,6 +374,27 @@ func CanWindowPoStProof(s abi.RegisteredPoStProof) bool {
 	return ok
 }
 
+// The network version from which a miner may submit a separate Window PoSt proof of each partition,
+// verified together with the BatchVerifyPoSts syscall.
+// Every node verifies such proofs in the same way, and charges the same gas, from the same epoch.
+const BatchWindowPoStNetworkVersion = network.Version(16)
+
+// Checks whether proofs of a PoSt proof type may be verified in a batch.
+// Every Window PoSt proof type may be, since the proofs of a batch are verified independently.
+// This is fixed, rather than configured per network, since the syscall must handle every type accepted.
+func CanBatchWindowPoStProof(s abi.RegisteredPoStProof) bool {
+	switch s {
+	case abi.RegisteredPoStProof_StackedDrgWindow2KiBV1,
+		abi.RegisteredPoStProof_StackedDrgWindow8MiBV1,
+		abi.RegisteredPoStProof_StackedDrgWindow512MiBV1,
+		abi.RegisteredPoStProof_StackedDrgWindow32GiBV1,
+		abi.RegisteredPoStProof_StackedDrgWindow64GiBV1:
+		return true
+	default:
+		return false
+	}
+}
+
 // List of proof types which may be used when pre-committing a new sector.
 // This is mutable to allow configuration of testing and development networks.
 // From network version 8, sectors sealed with the V1 seal proof types cannot be committed.
@@ -429,6 +481,13 @@ func QAPowerForWeight(size abi.SectorSize, duration abi.ChainEpoch, dealWeight,
 	return big.Rsh(big.Mul(big.NewIntUnsigned(uint64(size)), quality), builtin.SectorQualityPrecision)
 }
 
+// The power for a sector size and committed duration holding deals, computed from the deals' sizes and durations
+// as the market weighs them on activation.
+func QAPowerForDeals(size abi.SectorSize, duration abi.ChainEpoch, deals []market.DealSpec) abi.StoragePower {
+	weights := market.SectorWeightsForDeals(deals)
+	return QAPowerForWeight(size, duration, weights.DealWeight, weights.VerifiedDealWeight)
+}
+
 // The quality-adjusted power for a sector.
 func QAPowerForSector(size abi.SectorSize, sector *SectorOnChainInfo) abi.StoragePower {
 	duration := sector.Expiration - sector.Activation
@@ -452,6 +511,16 @@ type VestSpec struct {
 	Quantization abi.ChainEpoch // Maximum precision of vesting table (limits cardinality of table).
 }
 
+var _ VestingPolicy = (*VestSpec)(nil)
+
+// A policy determining when funds locked by a miner vest. VestSpec is the linear policy used for block rewards,
+// but test networks may supply others, e.g. to shorten vesting.
+type VestingPolicy interface {
+	// Returns the amounts vesting at each epoch for an amount locked at an epoch, by a miner whose proving
+	// period starts at an offset. The amounts must be non-negative and sum to the locked amount.
+	Schedule(currEpoch abi.ChainEpoch, vestingSum abi.TokenAmount, provingPeriodStart abi.ChainEpoch) []VestingFund
+}
+
 // When an actor reports a consensus fault, they earn a share of the penalty paid by the miner.
 func RewardForConsensusSlashReport(epochReward abi.TokenAmount) abi.TokenAmount {
 	return big.Div(epochReward,
@@ -466,9 +535,89 @@ func RewardForDisputedWindowPoSt(proofType abi.RegisteredPoStProof, disputedPowe
 	return CurrentMoniesPolicy.BaseRewardForDisputedWindowPoSt
 }
 
-const MaxAggregatedSectors = 819
-const MinAggregatedSectors = 4
-const MaxAggregateProofSize = 81960
+func MaxAggregatedSectors() uint64 {
+	return CurrentMinerPolicy.MaxAggregatedSectors
+}
+func MinAggregatedSectors() uint64 {
+	return CurrentMinerPolicy.MinAggregatedSectors
+}
+func MaxAggregateProofSize() uint64 {
+	return CurrentMinerPolicy.MaxAggregateProofSize
+}
+func MaxWindowPoStBatchSize() uint64 {
+	return CurrentMinerPolicy.MaxWindowPoStBatchSize
+}
+
+// Configures the aggregate prove-commit policy. See SetAggregatePolicy.
+type AggregatePolicyOption func(p *Policy, m *MoniesPolicy)
+
+// Sets the minimum and maximum number of sectors in an aggregate prove-commit.
+func WithAggregatedSectorsRange(min, max uint64) AggregatePolicyOption {
+	return func(p *Policy, _ *MoniesPolicy) {
+		p.MinAggregatedSectors = min
+		p.MaxAggregatedSectors = max
+	}
+}
+
+// Sets the maximum size of an aggregate prove-commit proof.
+func WithMaxAggregateProofSize(size uint64) AggregatePolicyOption {
+	return func(p *Policy, _ *MoniesPolicy) {
+		p.MaxAggregateProofSize = size
+	}
+}
+
+// Sets the fraction of the estimated gas cost of individual proofs charged as the aggregate network fee.
+func WithBatchDiscount(discount builtin.BigFrac) AggregatePolicyOption {
+	return func(_ *Policy, m *MoniesPolicy) {
+		m.BatchDiscount = discount
+	}
+}
+
+// Sets the minimum base fee at which the aggregate network fee is calculated.
+func WithBatchBalancer(balancer abi.TokenAmount) AggregatePolicyOption {
+	return func(_ *Policy, m *MoniesPolicy) {
+		m.BatchBalancer = balancer
+	}
+}
+
+// Sets the estimated gas used to verify a single pre-commit and prove-commit, from which the aggregate network fees
+// are calculated.
+func WithEstimatedSingleGasUsage(preCommit, proveCommit big.Int) AggregatePolicyOption {
+	return func(_ *Policy, m *MoniesPolicy) {
+		m.EstimatedSinglePreCommitGasUsage = preCommit
+		m.EstimatedSingleProveCommitGasUsage = proveCommit
+	}
+}
+
+// Applies options to the current miner and monies policies.
+// This allows testing and development networks, and simulations, to model alternative aggregation economics.
+func SetAggregatePolicy(opts ...AggregatePolicyOption) {
+	p, m := CurrentMinerPolicy, CurrentMoniesPolicy
+	for _, opt := range opts {
+		opt(&p, &m)
+	}
+	checkAggregateParams(p.MinAggregatedSectors, p.MaxAggregatedSectors)
+	if m.BatchDiscount.Denominator.IsZero() {
+		panic("the batch discount denominator must not be zero")
+	}
+	CurrentMinerPolicy, CurrentMoniesPolicy = p, m
+}
+
+func checkAggregateParams(minAggregatedSectors, maxAggregatedSectors uint64) {
+	if minAggregatedSectors < 1 || minAggregatedSectors > maxAggregatedSectors {
+		panic(fmt.Sprintf("invalid aggregated sectors range [%d, %d]", minAggregatedSectors, maxAggregatedSectors))
+	}
+}
+
+func checkVestSpec(spec VestSpec) {
+	if spec.InitialDelay < 0 || spec.VestPeriod < 0 {
+		panic(fmt.Sprintf("negative vesting delay %d or period %d", spec.InitialDelay, spec.VestPeriod))
+	}
+	// Vesting would never progress with a zero step, and quantization divides by its unit.
+	if spec.StepDuration <= 0 || spec.Quantization <= 0 {
+		panic(fmt.Sprintf("non-positive vesting step %d or quantization %d", spec.StepDuration, spec.Quantization))
+	}
+}
 
 // The delay between pre commit expiration and clean up from state. This enforces that expired pre-commits
 // stay in state for a period of time creating a grace period during which a late-running aggregated prove-commit
@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
 )
 
@@ -132,6 +133,70 @@ func TestPower(t *testing.T) {
 			assert.Equal(t, halfVerifiedPower, miner.QAPowerForWeight(sectorSize, sectorDuration, big.Zero(), big.Div(sectorWeight, big.NewInt(2))))
 		}
 	})
+
+	t.Run("power for deals matches power for their weights", func(t *testing.T) {
+		sectorSize := abi.SectorSize(32 << 30)
+		sectorDuration := abi.ChainEpoch(180 * builtin.EpochsInDay())
+		dealDuration := sectorDuration / 2
+		half := abi.PaddedPieceSize(sectorSize / 2)
+
+		assert.Equal(t, miner.QAPowerForWeight(sectorSize, sectorDuration, big.Zero(), big.Zero()),
+			miner.QAPowerForDeals(sectorSize, sectorDuration, nil))
+
+		deals := []market.DealSpec{
+			{PieceSize: half, Duration: dealDuration, Verified: true},
+			{PieceSize: half, Duration: sectorDuration, Verified: false},
+		}
+		dealWeight := weight(abi.SectorSize(half), sectorDuration)
+		verifiedWeight := weight(abi.SectorSize(half), dealDuration)
+		assert.Equal(t, miner.QAPowerForWeight(sectorSize, sectorDuration, dealWeight, verifiedWeight),
+			miner.QAPowerForDeals(sectorSize, sectorDuration, deals))
+	})
+}
+
+func TestSetAggregatePolicy(t *testing.T) {
+	restore := func(p miner.Policy, m miner.MoniesPolicy) func() {
+		return func() { miner.CurrentMinerPolicy, miner.CurrentMoniesPolicy = p, m }
+	}
+
+	t.Run("options update the current policies", func(t *testing.T) {
+		defer restore(miner.CurrentMinerPolicy, miner.CurrentMoniesPolicy)()
+		baseFee := big.NewInt(100)
+		defaultFee := miner.AggregateProveCommitNetworkFee(10, baseFee)
+
+		miner.SetAggregatePolicy(
+			miner.WithAggregatedSectorsRange(2, 100),
+			miner.WithMaxAggregateProofSize(1000),
+			miner.WithBatchDiscount(builtin.BigFrac{Numerator: big.NewInt(1), Denominator: big.NewInt(10)}),
+			miner.WithBatchBalancer(big.Zero()),
+		)
+		assert.Equal(t, uint64(2), miner.MinAggregatedSectors())
+		assert.Equal(t, uint64(100), miner.MaxAggregatedSectors())
+		assert.Equal(t, uint64(1000), miner.MaxAggregateProofSize())
+		assert.True(t, big.Zero().Equals(miner.BatchBalancer()))
+		// A tenth of the estimated gas cost at the base fee, which now exceeds the balancer.
+		expected := big.Product(baseFee, miner.DefaultMoniesPolicy.EstimatedSingleProveCommitGasUsage, big.NewInt(10))
+		expected = big.Div(expected, big.NewInt(10))
+		assert.Equal(t, expected, miner.AggregateProveCommitNetworkFee(10, baseFee))
+		assert.NotEqual(t, defaultFee, miner.AggregateProveCommitNetworkFee(10, baseFee))
+
+		miner.SetAggregatePolicy(miner.WithEstimatedSingleGasUsage(big.NewInt(1), big.NewInt(2)))
+		assert.Equal(t, big.NewInt(1), miner.AggregatePreCommitNetworkFee(1, big.NewInt(10)))
+		assert.Equal(t, big.NewInt(2), miner.AggregateProveCommitNetworkFee(1, big.NewInt(10)))
+	})
+
+	t.Run("invalid options are rejected without change", func(t *testing.T) {
+		defer restore(miner.CurrentMinerPolicy, miner.CurrentMoniesPolicy)()
+
+		assert.Panics(t, func() { miner.SetAggregatePolicy(miner.WithAggregatedSectorsRange(10, 5)) })
+		assert.Panics(t, func() { miner.SetAggregatePolicy(miner.WithAggregatedSectorsRange(0, 5)) })
+		assert.Panics(t, func() {
+			miner.SetAggregatePolicy(miner.WithBatchDiscount(builtin.BigFrac{Numerator: big.NewInt(1), Denominator: big.Zero()}))
+		})
+		assert.Equal(t, miner.DefaultMinerPolicy.MinAggregatedSectors, miner.MinAggregatedSectors())
+		assert.Equal(t, miner.DefaultMinerPolicy.MaxAggregatedSectors, miner.MaxAggregatedSectors())
+		assert.Equal(t, miner.DefaultMoniesPolicy.BatchDiscount, miner.CurrentMoniesPolicy.BatchDiscount)
+	})
 }
 
 func weight(size abi.SectorSize, duration abi.ChainEpoch) big.Int {
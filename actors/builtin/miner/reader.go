@@ -0,0 +1,114 @@
+package miner
+
+import (
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/dline"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// Read-only queries of a miner's state, for tools outside the VM which locate sectors and schedule proofs.
+// Deadlines are loaded on first use and reused, so sector locations reflect the head the reader was created from.
+// Sectors move between partitions when deadlines are compacted, so a reader should not be kept across heads.
+type StateReader struct {
+	store     adt.Store
+	st        State
+	deadlines *Deadlines // Loaded on first use.
+}
+
+// Loads a miner's state from its head.
+func NewStateReader(store adt.Store, head cid.Cid) (*StateReader, error) {
+	r := &StateReader{store: store}
+	if err := store.Get(store.Context(), head, &r.st); err != nil {
+		return nil, xerrors.Errorf("failed to load miner state %v: %w", head, err)
+	}
+	return r, nil
+}
+
+// The state loaded from the head. Queries read through it, so it must not be modified.
+func (r *StateReader) State() *State {
+	return &r.st
+}
+
+func (r *StateReader) Info() (*MinerInfo, error) {
+	return r.st.GetInfo(r.store)
+}
+
+func (r *StateReader) loadDeadlines() (*Deadlines, error) {
+	if r.deadlines == nil {
+		deadlines, err := r.st.LoadDeadlines(r.store)
+		if err != nil {
+			return nil, err
+		}
+		r.deadlines = deadlines
+	}
+	return r.deadlines, nil
+}
+
+// Returns the vesting table: the amounts of the miner's locked funds vesting at each epoch, in increasing epoch
+// order. Amounts at epochs which have passed have vested, but remain locked until the miner's next cron.
+func (r *StateReader) VestingSchedule() ([]VestingFund, error) {
+	funds, err := r.st.LoadVestingFunds(r.store)
+	if err != nil {
+		return nil, err
+	}
+	return funds.Funds, nil
+}
+
+// Loads a deadline by index.
+func (r *StateReader) LoadDeadline(dlIdx uint64) (*Deadline, error) {
+	if dlIdx >= WPoStPeriodDeadlines() {
+		return nil, xerrors.Errorf("invalid deadline %d of %d", dlIdx, WPoStPeriodDeadlines())
+	}
+	deadlines, err := r.loadDeadlines()
+	if err != nil {
+		return nil, err
+	}
+	return deadlines.LoadDeadline(r.store, dlIdx)
+}
+
+// Loads a partition by deadline and partition index.
+func (r *StateReader) LoadPartition(dlIdx, partIdx uint64) (*Partition, error) {
+	deadline, err := r.LoadDeadline(dlIdx)
+	if err != nil {
+		return nil, err
+	}
+	return deadline.LoadPartition(r.store, partIdx)
+}
+
+// Returns all sectors assigned to a partition, including faulty and terminated sectors.
+func (r *StateReader) SectorsInPartition(dlIdx, partIdx uint64) (bitfield.BitField, error) {
+	partition, err := r.LoadPartition(dlIdx, partIdx)
+	if err != nil {
+		return bitfield.BitField{}, err
+	}
+	return partition.Sectors, nil
+}
+
+// Returns the deadline and partition indices to which a sector is assigned.
+func (r *StateReader) PartitionForSector(sno abi.SectorNumber) (dlIdx, partIdx uint64, err error) {
+	deadlines, err := r.loadDeadlines()
+	if err != nil {
+		return 0, 0, err
+	}
+	return FindSector(r.store, deadlines, sno)
+}
+
+// Returns the challenge window of a deadline in the proving period containing an epoch.
+// The window may already have elapsed at the epoch.
+func (r *StateReader) DeadlineInfo(dlIdx uint64, epoch abi.ChainEpoch) *dline.Info {
+	return NewDeadlineInfo(r.st.CurrentProvingPeriodStart(epoch), dlIdx, epoch)
+}
+
+// Returns the next challenge window in which a sector must be proven: the window of the sector's deadline which
+// is open at an epoch, or otherwise the next to open after it.
+func (r *StateReader) NextPoStDeadlineForSector(sno abi.SectorNumber, epoch abi.ChainEpoch) (*dline.Info, error) {
+	dlIdx, _, err := r.PartitionForSector(sno)
+	if err != nil {
+		return nil, err
+	}
+	return r.DeadlineInfo(dlIdx, epoch).NextNotElapsed(), nil
+}
@@ -0,0 +1,102 @@
+package miner_test
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
+)
+
+func TestStateReader(t *testing.T) {
+	periodOffset := abi.ChainEpoch(100)
+	actor := newHarness(t, periodOffset)
+	builder := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero())
+
+	rt := builder.Build(t)
+	actor.constructAndVerify(rt)
+	sectorInfos := actor.commitAndProveSectors(rt, 3, defaultSectorExpiration, nil, true)
+
+	r, err := miner.NewStateReader(rt.AdtStore(), rt.StateRoot())
+	require.NoError(t, err)
+	st := getState(rt)
+
+	info, err := r.Info()
+	require.NoError(t, err)
+	assert.Equal(t, actor.owner, info.Owner)
+
+	for _, sector := range sectorInfos {
+		expectedDl, expectedPart, err := st.FindSector(rt.AdtStore(), sector.SectorNumber)
+		require.NoError(t, err)
+
+		dlIdx, partIdx, err := r.PartitionForSector(sector.SectorNumber)
+		require.NoError(t, err)
+		assert.Equal(t, expectedDl, dlIdx)
+		assert.Equal(t, expectedPart, partIdx)
+
+		sectors, err := r.SectorsInPartition(dlIdx, partIdx)
+		require.NoError(t, err)
+		set, err := sectors.IsSet(uint64(sector.SectorNumber))
+		require.NoError(t, err)
+		assert.True(t, set)
+
+		// The next deadline is the sector's deadline, open at or after the epoch.
+		epoch := rt.Epoch()
+		dlInfo, err := r.NextPoStDeadlineForSector(sector.SectorNumber, epoch)
+		require.NoError(t, err)
+		assert.Equal(t, dlIdx, dlInfo.Index)
+		assert.False(t, dlInfo.HasElapsed())
+		assert.True(t, dlInfo.IsOpen() || dlInfo.Open > epoch)
+		assert.True(t, dlInfo.Open-epoch < miner.WPoStProvingPeriod())
+
+		// From the window's last epoch, the next window is a full proving period later.
+		next, err := r.NextPoStDeadlineForSector(sector.SectorNumber, dlInfo.Last())
+		require.NoError(t, err)
+		assert.Equal(t, dlInfo.Open, next.Open)
+		next, err = r.NextPoStDeadlineForSector(sector.SectorNumber, dlInfo.Close)
+		require.NoError(t, err)
+		assert.Equal(t, dlInfo.Open+miner.WPoStProvingPeriod(), next.Open)
+	}
+
+	_, _, err = r.PartitionForSector(abi.SectorNumber(1000))
+	assert.Error(t, err)
+	_, err = r.SectorsInPartition(miner.WPoStPeriodDeadlines(), 0)
+	assert.Error(t, err)
+}
+
+func TestStateReaderVestingSchedule(t *testing.T) {
+	actor := newHarness(t, abi.ChainEpoch(100))
+	rt := builderForHarness(actor).
+		WithBalance(bigBalance, big.Zero()).
+		Build(t)
+	actor.constructAndVerify(rt)
+
+	r, err := miner.NewStateReader(rt.AdtStore(), rt.StateRoot())
+	require.NoError(t, err)
+	schedule, err := r.VestingSchedule()
+	require.NoError(t, err)
+	assert.Empty(t, schedule)
+
+	// Locked rewards vest over the reward vesting period, in increasing epoch order.
+	actor.applyRewards(rt, bigRewards, big.Zero())
+	st := getState(rt)
+	r, err = miner.NewStateReader(rt.AdtStore(), rt.StateRoot())
+	require.NoError(t, err)
+	schedule, err = r.VestingSchedule()
+	require.NoError(t, err)
+	require.NotEmpty(t, schedule)
+	total := big.Zero()
+	for i, vf := range schedule {
+		if i > 0 {
+			assert.Greater(t, vf.Epoch, schedule[i-1].Epoch)
+		}
+		assert.Greater(t, vf.Epoch, rt.Epoch())
+		total = big.Add(total, vf.Amount)
+	}
+	assert.Equal(t, st.LockedFunds, total)
+	assert.LessOrEqual(t, schedule[len(schedule)-1].Epoch, rt.Epoch()+miner.RewardVestingSpec().VestPeriod+miner.RewardVestingSpec().Quantization)
+}
@@ -23,6 +23,13 @@ type StateSummary struct {
 	Deals               map[abi.DealID]DealSummary
 	WindowPoStProofType abi.RegisteredPoStProof
 	DeadlineCronActive  bool
+	// Numbers of sectors in each state, across all deadlines.
+	LiveSectors       uint64
+	FaultySectors     uint64
+	RecoveringSectors uint64
+	UnprovenSectors   uint64
+	// Cumulative counts of sector events.
+	Stats SectorStats
 }
 
 // Checks internal invariants of init state.
@@ -35,6 +42,7 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount) (
 		FaultyPower:         NewPowerPairZero(),
 		WindowPoStProofType: 0,
 		DeadlineCronActive:  st.DeadlineCronActive,
+		Stats:               st.Stats,
 	}
 
 	// Load data from linked structures.
@@ -108,6 +116,22 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount) (
 			minerSummary.LivePower = minerSummary.LivePower.Add(dlSummary.LivePower)
 			minerSummary.ActivePower = minerSummary.ActivePower.Add(dlSummary.ActivePower)
 			minerSummary.FaultyPower = minerSummary.FaultyPower.Add(dlSummary.FaultyPower)
+			for _, c := range []struct {
+				sectors bitfield.BitField
+				count   *uint64
+			}{
+				{dlSummary.LiveSectors, &minerSummary.LiveSectors},
+				{dlSummary.FaultySectors, &minerSummary.FaultySectors},
+				{dlSummary.RecoveringSectors, &minerSummary.RecoveringSectors},
+				{dlSummary.UnprovenSectors, &minerSummary.UnprovenSectors},
+			} {
+				count, err := c.sectors.Count()
+				if err != nil {
+					acc.Addf("error counting sectors: %v", err)
+					continue
+				}
+				*c.count += count
+			}
 			return nil
 		})
 		acc.RequireNoError(err, "error iterating deadlines")
@@ -173,6 +197,15 @@ func CheckDeadlineStateInvariants(deadline *Deadline, store adt.Store, quant bui
 		acc := acc.WithPrefix("partition %d: ", pIdx) // Shadow
 		summary := CheckPartitionStateInvariants(&partition, store, quant, ssize, sectors, acc)
 
+		// A partition which isn't dirty must have no power to prove.
+		if dirty, err := deadline.DirtyPartitions.IsSet(pIdx); err != nil {
+			acc.Addf("error checking dirty partitions: %v", err)
+		} else if !dirty {
+			acc.Require(partition.RecoveringPower.IsZero(), "partition not marked dirty has recovering power %v", partition.RecoveringPower)
+			acc.Require(partition.FaultyPower.Equals(partition.LivePower), "partition not marked dirty has faulty power %v less than live power %v",
+				partition.FaultyPower, partition.LivePower)
+		}
+
 		if contains, err := util.BitFieldContainsAny(allSectors, summary.AllSectors); err != nil {
 			acc.Addf("error checking bitfield contains: %v", err)
 		} else {
@@ -215,6 +248,15 @@ func CheckDeadlineStateInvariants(deadline *Deadline, store adt.Store, quant bui
 		}
 	}
 
+	// Check invariants on dirty partitions.
+	if lastDirty, err := deadline.DirtyPartitions.Last(); err != nil {
+		if err != bitfield.ErrNoBitsSet {
+			acc.Addf("error determining the last dirty partition: %v", err)
+		}
+	} else {
+		acc.Require(partitionCount >= (lastDirty+1), "expected at least %d partitions for dirty partitions, found %d", lastDirty+1, partitionCount)
+	}
+
 	// Check partitions snapshot to make sure we take the snapshot after
 	// dealing with recovering power and unproven power.
 	partitionsSnapshot, err := deadline.PartitionsSnapshotArray(store)
@@ -688,12 +730,23 @@ func CheckMinerInfo(info *MinerInfo, acc *builtin.MessageAccumulator) {
 	for _, a := range info.ControlAddresses {
 		acc.Require(a.Protocol() == addr.ID, "control address %v is not an ID address", a)
 	}
+	acc.Require(len(info.ControlPermissions) == len(info.ControlAddresses),
+		"%d control permissions for %d control addresses", len(info.ControlPermissions), len(info.ControlAddresses))
+	for _, p := range info.ControlPermissions {
+		acc.Require(p&^AllControlPermissions == 0, "control permissions %d include unknown permissions", p)
+	}
 
-	if info.PendingWorkerKey != nil {
-		acc.Require(info.PendingWorkerKey.NewWorker.Protocol() == addr.ID,
-			"pending worker address %v is not an ID address", info.PendingWorkerKey.NewWorker)
-		acc.Require(info.PendingWorkerKey.NewWorker != info.Worker,
-			"pending worker key %v is same as existing worker %v", info.PendingWorkerKey.NewWorker, info.Worker)
+	acc.Require(len(info.PendingWorkerKeys) <= MaxPendingWorkerKeyChanges,
+		"%d pending worker keys exceeds limit %d", len(info.PendingWorkerKeys), MaxPendingWorkerKeyChanges)
+	prevWorker, prevEffectiveAt := info.Worker, abi.ChainEpoch(0)
+	for _, change := range info.PendingWorkerKeys {
+		acc.Require(change.NewWorker.Protocol() == addr.ID,
+			"pending worker address %v is not an ID address", change.NewWorker)
+		acc.Require(change.NewWorker != prevWorker,
+			"pending worker key %v is same as preceding worker %v", change.NewWorker, prevWorker)
+		acc.Require(change.EffectiveAt >= prevEffectiveAt,
+			"pending worker key %v effective at %d before preceding change at %d", change.NewWorker, change.EffectiveAt, prevEffectiveAt)
+		prevWorker, prevEffectiveAt = change.NewWorker, change.EffectiveAt
 	}
 
 	if info.PendingOwnerAddress != nil {
@@ -703,6 +756,12 @@ func CheckMinerInfo(info *MinerInfo, acc *builtin.MessageAccumulator) {
 			"pending owner address %v is same as existing owner %v", info.PendingOwnerAddress, info.Owner)
 	}
 
+	acc.Require(info.Beneficiary.Protocol() == addr.ID, "beneficiary address %v is not an ID address", info.Beneficiary)
+	if info.PendingBeneficiaryTerm != nil {
+		acc.Require(info.PendingBeneficiaryTerm.NewBeneficiary.Protocol() == addr.ID,
+			"pending beneficiary address %v is not an ID address", info.PendingBeneficiaryTerm.NewBeneficiary)
+	}
+
 	windowPoStProofInfo, found := abi.PoStProofInfos[info.WindowPoStProofType]
 	acc.Require(found, "miner has unrecognized Window PoSt proof type %d", info.WindowPoStProofType)
 	if found {
@@ -7,6 +7,7 @@ import (
 
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
+	"golang.org/x/xerrors"
 )
 
 // VestingFunds represents the vesting table state for the miner.
@@ -37,43 +38,24 @@ func (v *VestingFunds) unlockVestedFunds(currEpoch abi.ChainEpoch) abi.TokenAmou
 	return amountUnlocked
 }
 
-func (v *VestingFunds) addLockedFunds(currEpoch abi.ChainEpoch, vestingSum abi.TokenAmount,
-	provingPeriodStart abi.ChainEpoch, spec *VestSpec) {
+// Adds a vesting schedule to the table, merging amounts vesting at the same epoch.
+func (v *VestingFunds) addLockedFunds(schedule []VestingFund) {
 	// maps the epochs in VestingFunds to their indices in the slice
 	epochToIndex := make(map[abi.ChainEpoch]int, len(v.Funds))
 	for i, vf := range v.Funds {
 		epochToIndex[vf.Epoch] = i
 	}
 
-	// Quantization is aligned with when regular cron will be invoked, in the last epoch of deadlines.
-	vestBegin := currEpoch + spec.InitialDelay // Nothing unlocks here, this is just the start of the clock.
-	vestPeriod := big.NewInt(int64(spec.VestPeriod))
-	vestedSoFar := big.Zero()
-	for e := vestBegin + spec.StepDuration; vestedSoFar.LessThan(vestingSum); e += spec.StepDuration {
-		vestEpoch := builtin.QuantizeUp(e, spec.Quantization, provingPeriodStart)
-		elapsed := vestEpoch - vestBegin
-
-		targetVest := big.Zero() //nolint:ineffassign
-		if elapsed < spec.VestPeriod {
-			// Linear vesting
-			targetVest = big.Div(big.Mul(vestingSum, big.NewInt(int64(elapsed))), vestPeriod)
-		} else {
-			targetVest = vestingSum
-		}
-
-		vestThisTime := big.Sub(targetVest, vestedSoFar)
-		vestedSoFar = targetVest
-
+	for _, vf := range schedule {
 		// epoch already exists. Load existing entry
 		// and update amount.
-		if index, ok := epochToIndex[vestEpoch]; ok {
+		if index, ok := epochToIndex[vf.Epoch]; ok {
 			currentAmt := v.Funds[index].Amount
-			v.Funds[index].Amount = big.Add(currentAmt, vestThisTime)
+			v.Funds[index].Amount = big.Add(currentAmt, vf.Amount)
 		} else {
 			// append a new entry -> slice will be sorted by epoch later.
-			entry := VestingFund{Epoch: vestEpoch, Amount: vestThisTime}
-			v.Funds = append(v.Funds, entry)
-			epochToIndex[vestEpoch] = len(v.Funds) - 1
+			v.Funds = append(v.Funds, vf)
+			epochToIndex[vf.Epoch] = len(v.Funds) - 1
 		}
 	}
 
@@ -129,3 +111,50 @@ func ConstructVestingFunds() *VestingFunds {
 	v.Funds = nil
 	return v
 }
+
+// Schedule returns the linear vesting of an amount locked at an epoch, in increasing epoch order.
+// Quantization is aligned with when regular cron will be invoked, in the last epoch of deadlines.
+func (spec *VestSpec) Schedule(currEpoch abi.ChainEpoch, vestingSum abi.TokenAmount, provingPeriodStart abi.ChainEpoch) []VestingFund {
+	var schedule []VestingFund
+	vestBegin := currEpoch + spec.InitialDelay // Nothing unlocks here, this is just the start of the clock.
+	vestPeriod := big.NewInt(int64(spec.VestPeriod))
+	vestedSoFar := big.Zero()
+	for e := vestBegin + spec.StepDuration; vestedSoFar.LessThan(vestingSum); e += spec.StepDuration {
+		vestEpoch := builtin.QuantizeUp(e, spec.Quantization, provingPeriodStart)
+		elapsed := vestEpoch - vestBegin
+
+		targetVest := big.Zero() //nolint:ineffassign
+		if elapsed < spec.VestPeriod {
+			// Linear vesting
+			targetVest = big.Div(big.Mul(vestingSum, big.NewInt(int64(elapsed))), vestPeriod)
+		} else {
+			targetVest = vestingSum
+		}
+
+		vestThisTime := big.Sub(targetVest, vestedSoFar)
+		vestedSoFar = targetVest
+
+		// Quantized epochs never decrease, so successive steps may only share the last entry's epoch.
+		if last := len(schedule) - 1; last >= 0 && schedule[last].Epoch == vestEpoch {
+			schedule[last].Amount = big.Add(schedule[last].Amount, vestThisTime)
+		} else {
+			schedule = append(schedule, VestingFund{Epoch: vestEpoch, Amount: vestThisTime})
+		}
+	}
+	return schedule
+}
+
+// Checks that a vesting schedule vests exactly the locked amount, in non-negative amounts.
+func checkVestingSchedule(schedule []VestingFund, vestingSum abi.TokenAmount) error {
+	total := big.Zero()
+	for _, vf := range schedule {
+		if vf.Amount.LessThan(big.Zero()) {
+			return xerrors.Errorf("negative amount %v vesting at epoch %d", vf.Amount, vf.Epoch)
+		}
+		total = big.Add(total, vf.Amount)
+	}
+	if !total.Equals(vestingSum) {
+		return xerrors.Errorf("schedule vests %v, not the locked amount %v", total, vestingSum)
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/cbor"
 	"github.com/filecoin-project/go-state-types/exitcode"
+	rtt "github.com/filecoin-project/go-state-types/rt"
 	multisig0 "github.com/filecoin-project/specs-actors/actors/builtin/multisig"
 	multisig2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/multisig"
 
@@ -21,16 +22,24 @@ import (
 
 type TxnID = multisig0.TxnID
 
-//type Transaction struct {
-//	To     addr.Address
-//	Value  abi.TokenAmount
-//	Method abi.MethodNum
-//	Params []byte
-//
-//	// This address at index 0 is the transaction proposer, order of this slice must be preserved.
-//	Approved []addr.Address
-//}
-type Transaction = multisig0.Transaction
+type Transaction struct {
+	To     addr.Address
+	Value  abi.TokenAmount
+	Method abi.MethodNum
+	Params []byte
+
+	// This address at index 0 is the transaction proposer, order of this slice must be preserved.
+	Approved []addr.Address
+
+	// The last epoch at which the transaction may be approved, after which anyone may remove it.
+	// Zero for a transaction which does not expire.
+	Expiration abi.ChainEpoch
+}
+
+// Tests whether a transaction has expired at an epoch, voiding its approvals.
+func (t *Transaction) IsExpired(epoch abi.ChainEpoch) bool {
+	return t.Expiration != 0 && epoch > t.Expiration
+}
 
 // Data for a BLAKE2B-256 to be attached to methods referencing proposals via TXIDs.
 // Ensures the existence of a cryptographic reference to the original proposal. Useful
@@ -60,6 +69,11 @@ func (a Actor) Exports() []interface{} {
 		7:                         a.SwapSigner,
 		8:                         a.ChangeNumApprovalsThreshold,
 		9:                         a.LockBalance,
+		10:                        a.ProposeWithExpiration,
+		11:                        a.RemoveExpiredTransaction,
+		12:                        a.ApproveBatch,
+		13:                        a.SetSignerWeights,
+		14:                        a.SetSpendingLimit,
 	}
 }
 
@@ -129,6 +143,8 @@ func (a Actor) Constructor(rt runtime.Runtime, params *ConstructorParams) *abi.E
 	st.NumApprovalsThreshold = params.NumApprovalsThreshold
 	st.PendingTxns = pending
 	st.InitialBalance = abi.NewTokenAmount(0)
+	st.SpendingLimit = abi.NewTokenAmount(0)
+	st.SpentInPeriod = abi.NewTokenAmount(0)
 	if params.UnlockDuration != 0 {
 		st.SetLocked(params.StartEpoch, params.UnlockDuration, rt.ValueReceived())
 	}
@@ -158,12 +174,38 @@ type ProposeParams = multisig0.ProposeParams
 type ProposeReturn = multisig0.ProposeReturn
 
 func (a Actor) Propose(rt runtime.Runtime, params *ProposeParams) *ProposeReturn {
+	return a.propose(rt, &ProposeWithExpirationParams{
+		To:     params.To,
+		Value:  params.Value,
+		Method: params.Method,
+		Params: params.Params,
+	})
+}
+
+type ProposeWithExpirationParams struct {
+	To     addr.Address
+	Value  abi.TokenAmount
+	Method abi.MethodNum
+	Params []byte
+	// The last epoch at which the transaction may be approved, or zero for a transaction which does not expire.
+	Expiration abi.ChainEpoch
+}
+
+// Proposes a transaction as Propose, which can no longer be approved after its expiration epoch.
+func (a Actor) ProposeWithExpiration(rt runtime.Runtime, params *ProposeWithExpirationParams) *ProposeReturn {
+	return a.propose(rt, params)
+}
+
+func (a Actor) propose(rt runtime.Runtime, params *ProposeWithExpirationParams) *ProposeReturn {
 	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
 	proposer := rt.Caller()
 
 	if params.Value.Sign() < 0 {
 		rt.Abortf(exitcode.ErrIllegalArgument, "proposed value must be non-negative, was %v", params.Value)
 	}
+	if params.Expiration != 0 && params.Expiration < rt.CurrEpoch() {
+		rt.Abortf(exitcode.ErrIllegalArgument, "expiration %d before current epoch %d", params.Expiration, rt.CurrEpoch())
+	}
 
 	var txnID TxnID
 	var st State
@@ -179,11 +221,12 @@ func (a Actor) Propose(rt runtime.Runtime, params *ProposeParams) *ProposeReturn
 		txnID = st.NextTxnID
 		st.NextTxnID += 1
 		txn = &Transaction{
-			To:       params.To,
-			Value:    params.Value,
-			Method:   params.Method,
-			Params:   params.Params,
-			Approved: []addr.Address{},
+			To:         params.To,
+			Value:      params.Value,
+			Method:     params.Method,
+			Params:     params.Params,
+			Approved:   []addr.Address{},
+			Expiration: params.Expiration,
 		}
 
 		if err := ptx.Put(txnID, txn); err != nil {
@@ -239,6 +282,9 @@ func (a Actor) Approve(rt runtime.Runtime, params *TxnIDParams) *ApproveReturn {
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load pending transactions")
 
 		txn = getTransaction(rt, ptx, params.ID, params.ProposalHash)
+		if txn.IsExpired(rt.CurrEpoch()) {
+			rt.Abortf(exitcode.ErrForbidden, "transaction %v expired at %d", params.ID, txn.Expiration)
+		}
 	})
 
 	// if the transaction already has enough approvers, execute it without "processing" this approval.
@@ -256,6 +302,108 @@ func (a Actor) Approve(rt runtime.Runtime, params *TxnIDParams) *ApproveReturn {
 	}
 }
 
+type ApproveBatchParams struct {
+	Approvals []TxnIDParams
+}
+
+type ApproveBatchReturn struct {
+	// The result of each approval, in the order of the parameters.
+	Results []ApproveBatchResult
+}
+
+type ApproveBatchResult struct {
+	// The exit code of the approval, which is recorded only if this is Ok.
+	ApprovalCode exitcode.ExitCode
+	// As ApproveReturn, if the approval was recorded.
+	Applied bool
+	Code    exitcode.ExitCode
+	Ret     []byte
+}
+
+// Approves a number of transactions as Approve, in order.
+// An approval which would fail is not recorded and does not abort the batch, but instead sets the exit code
+// with which Approve would abort in its result.
+func (a Actor) ApproveBatch(rt runtime.Runtime, params *ApproveBatchParams) *ApproveBatchReturn {
+	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
+
+	if len(params.Approvals) > ApproveBatchMax {
+		rt.Abortf(exitcode.ErrIllegalArgument, "batch of %d approvals exceeds max %d", len(params.Approvals), ApproveBatchMax)
+	}
+
+	results := make([]ApproveBatchResult, 0, len(params.Approvals))
+	for i := range params.Approvals {
+		results = append(results, a.tryApprove(rt, &params.Approvals[i]))
+	}
+	return &ApproveBatchReturn{Results: results}
+}
+
+// Approves a transaction as Approve, but returns the exit code of an approval which would fail rather than aborting.
+func (a Actor) tryApprove(rt runtime.Runtime, params *TxnIDParams) ApproveBatchResult {
+	approver := rt.Caller()
+	fail := func(code exitcode.ExitCode, msg string, args ...interface{}) ApproveBatchResult {
+		rt.Log(rtt.INFO, "failed to approve transaction %v: %s", params.ID, fmt.Sprintf(msg, args...))
+		return ApproveBatchResult{ApprovalCode: code}
+	}
+
+	// Signers and funds may have been changed by a transaction executed earlier in the batch.
+	var st State
+	rt.StateReadonly(&st)
+	if !st.IsSigner(approver) {
+		return fail(exitcode.ErrForbidden, "%s is not a signer", approver)
+	}
+
+	ptx, err := adt.AsMap(adt.AsStore(rt), st.PendingTxns, builtin.DefaultHamtBitwidth)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load pending transactions")
+
+	var txn Transaction
+	found, err := ptx.Get(params.ID, &txn)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load transaction %v for approval", params.ID)
+	if !found {
+		return fail(exitcode.ErrNotFound, "no such transaction")
+	}
+	if params.ProposalHash != nil {
+		calculatedHash, err := ComputeProposalHash(&txn, rt.HashBlake2b)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to compute proposal hash for %v", params.ID)
+		if !bytes.Equal(params.ProposalHash, calculatedHash[:]) {
+			return fail(exitcode.ErrIllegalArgument, "hash does not match proposal params")
+		}
+	}
+	if txn.IsExpired(rt.CurrEpoch()) {
+		return fail(exitcode.ErrForbidden, "expired at %d", txn.Expiration)
+	}
+
+	approvalWeight := st.ApprovalWeight(txn.Approved)
+	thresholdMet := approvalWeight >= st.NumApprovalsThreshold
+	if !thresholdMet {
+		for _, previousApprover := range txn.Approved {
+			if previousApprover == approver {
+				return fail(exitcode.ErrForbidden, "%s already approved this message", approver)
+			}
+		}
+		thresholdMet = approvalWeight+st.SignerWeight(approver) >= st.NumApprovalsThreshold
+	}
+	if thresholdMet {
+		if err := st.assertAvailable(rt.CurrentBalance(), txn.Value, rt.CurrEpoch()); err != nil {
+			return fail(exitcode.ErrInsufficientFunds, "insufficient funds unlocked: %v", err)
+		}
+		if err := st.assertSpendable(txn.Value, rt.CurrEpoch()); err != nil {
+			return fail(exitcode.ErrForbidden, "spending limit exceeded: %v", err)
+		}
+	}
+
+	// The approval can no longer fail.
+	applied, ret, code := executeTransactionIfApproved(rt, st, params.ID, &txn)
+	if !applied {
+		applied, ret, code = a.approveTransaction(rt, params.ID, &txn)
+	}
+	return ApproveBatchResult{
+		ApprovalCode: exitcode.Ok,
+		Applied:      applied,
+		Code:         code,
+		Ret:          ret,
+	}
+}
+
 func (a Actor) Cancel(rt runtime.Runtime, params *TxnIDParams) *abi.EmptyValue {
 	rt.ValidateImmediateCallerType(builtin.CallerTypesSignable...)
 	callerAddr := rt.Caller()
@@ -295,6 +443,40 @@ func (a Actor) Cancel(rt runtime.Runtime, params *TxnIDParams) *abi.EmptyValue {
 	return nil
 }
 
+// Removes a transaction which has expired. May be invoked by anyone.
+func (a Actor) RemoveExpiredTransaction(rt runtime.Runtime, params *TxnIDParams) *abi.EmptyValue {
+	rt.ValidateImmediateCallerAcceptAny()
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		ptx, err := adt.AsMap(adt.AsStore(rt), st.PendingTxns, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load pending txns")
+
+		var txn Transaction
+		found, err := ptx.Pop(params.ID, &txn)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to pop transaction %v for removal", params.ID)
+		if !found {
+			rt.Abortf(exitcode.ErrNotFound, "no such transaction %v to remove", params.ID)
+		}
+		if !txn.IsExpired(rt.CurrEpoch()) {
+			rt.Abortf(exitcode.ErrForbidden, "transaction %v has not expired", params.ID)
+		}
+
+		// confirm the hashes match, if present.
+		if params.ProposalHash != nil {
+			calculatedHash, err := ComputeProposalHash(&txn, rt.HashBlake2b)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to compute proposal hash for %v", params.ID)
+			if !bytes.Equal(params.ProposalHash, calculatedHash[:]) {
+				rt.Abortf(exitcode.ErrIllegalArgument, "hash does not match proposal params (ensure requester is an ID address)")
+			}
+		}
+
+		st.PendingTxns, err = ptx.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush pending transactions")
+	})
+	return nil
+}
+
 //type AddSignerParams struct {
 //	Signer   addr.Address
 //	Increase bool
@@ -318,6 +500,10 @@ func (a Actor) AddSigner(rt runtime.Runtime, params *AddSignerParams) *abi.Empty
 		}
 
 		st.Signers = append(st.Signers, resolvedNewSigner)
+		// A new signer's approval has weight one.
+		if len(st.SignerWeights) > 0 {
+			st.SignerWeights = append(st.SignerWeights, 1)
+		}
 		if params.Increase {
 			st.NumApprovalsThreshold = st.NumApprovalsThreshold + 1
 		}
@@ -348,32 +534,39 @@ func (a Actor) RemoveSigner(rt runtime.Runtime, params *RemoveSignerParams) *abi
 			rt.Abortf(exitcode.ErrForbidden, "cannot remove only signer")
 		}
 
+		oldWeight := st.SignerWeight(resolvedOldSigner)
 		newSigners := make([]addr.Address, 0, len(st.Signers))
+		var newWeights []uint64
 		// signers have already been resolved
-		for _, s := range st.Signers {
+		for i, s := range st.Signers {
 			if resolvedOldSigner != s {
 				newSigners = append(newSigners, s)
+				if len(st.SignerWeights) > 0 {
+					newWeights = append(newWeights, st.SignerWeights[i])
+				}
 			}
 		}
 
-		// if the number of signers is below the threshold after removing the given signer,
-		// we should decrease the threshold by 1. This means that decrease should NOT be set to false
+		// if the weight of the remaining signers is below the threshold after removing the given signer,
+		// we should decrease the threshold by the signer's weight. This means that decrease should NOT be set to false
 		// in such a scenario.
-		if !params.Decrease && uint64(len(st.Signers)-1) < st.NumApprovalsThreshold {
-			rt.Abortf(exitcode.ErrIllegalArgument, "can't reduce signers to %d below threshold %d with decrease=false", len(st.Signers)-1, st.NumApprovalsThreshold)
+		remainingWeight := st.TotalWeight() - oldWeight
+		if !params.Decrease && remainingWeight < st.NumApprovalsThreshold {
+			rt.Abortf(exitcode.ErrIllegalArgument, "can't reduce signer weight to %d below threshold %d with decrease=false", remainingWeight, st.NumApprovalsThreshold)
 		}
 
 		if params.Decrease {
-			if st.NumApprovalsThreshold < 2 {
-				rt.Abortf(exitcode.ErrIllegalArgument, "can't decrease approvals from %d to %d", st.NumApprovalsThreshold, st.NumApprovalsThreshold-1)
+			if st.NumApprovalsThreshold <= oldWeight {
+				rt.Abortf(exitcode.ErrIllegalArgument, "can't decrease approvals from %d by %d", st.NumApprovalsThreshold, oldWeight)
 			}
-			st.NumApprovalsThreshold = st.NumApprovalsThreshold - 1
+			st.NumApprovalsThreshold = st.NumApprovalsThreshold - oldWeight
 		}
 
 		err := st.PurgeApprovals(store, resolvedOldSigner)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to purge approvals of removed signer")
 
 		st.Signers = newSigners
+		st.SignerWeights = newWeights
 	})
 
 	return nil
@@ -406,14 +599,24 @@ func (a Actor) SwapSigner(rt runtime.Runtime, params *SwapSignerParams) *abi.Emp
 			rt.Abortf(exitcode.ErrIllegalArgument, "%s already a signer", toResolved)
 		}
 
+		// The new signer takes the old signer's weight.
+		fromWeight := st.SignerWeight(fromResolved)
 		newSigners := make([]addr.Address, 0, len(st.Signers))
-		for _, s := range st.Signers {
+		var newWeights []uint64
+		for i, s := range st.Signers {
 			if s != fromResolved {
 				newSigners = append(newSigners, s)
+				if len(st.SignerWeights) > 0 {
+					newWeights = append(newWeights, st.SignerWeights[i])
+				}
 			}
 		}
 		newSigners = append(newSigners, toResolved)
+		if len(st.SignerWeights) > 0 {
+			newWeights = append(newWeights, fromWeight)
+		}
 		st.Signers = newSigners
+		st.SignerWeights = newWeights
 
 		err := st.PurgeApprovals(store, fromResolved)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to purge approvals of removed signer")
@@ -433,7 +636,7 @@ func (a Actor) ChangeNumApprovalsThreshold(rt runtime.Runtime, params *ChangeNum
 
 	var st State
 	rt.StateTransaction(&st, func() {
-		if params.NewThreshold == 0 || params.NewThreshold > uint64(len(st.Signers)) {
+		if params.NewThreshold == 0 || params.NewThreshold > st.TotalWeight() {
 			rt.Abortf(exitcode.ErrIllegalArgument, "New threshold value not supported")
 		}
 
@@ -442,6 +645,99 @@ func (a Actor) ChangeNumApprovalsThreshold(rt runtime.Runtime, params *ChangeNum
 	return nil
 }
 
+type WeightedSigner struct {
+	Signer addr.Address
+	Weight uint64
+}
+
+type SetSignerWeightsParams struct {
+	// The weight of every signer's approval, or empty to give every signer's approval weight one.
+	Signers []WeightedSigner
+	// The total weight of the approvals required to execute a transaction.
+	NewThreshold uint64
+}
+
+// Sets the weights of the signers' approvals and the approval threshold in units of weight.
+// Approvals of pending transactions count with the new weights.
+func (a Actor) SetSignerWeights(rt runtime.Runtime, params *SetSignerWeightsParams) *abi.EmptyValue {
+	// Can only be called by the multisig wallet itself.
+	rt.ValidateImmediateCallerIs(rt.Receiver())
+
+	weights := make(map[addr.Address]uint64, len(params.Signers))
+	for _, ws := range params.Signers {
+		resolved, err := builtin.ResolveToIDAddr(rt, ws.Signer)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to resolve address %v", ws.Signer)
+		if _, ok := weights[resolved]; ok {
+			rt.Abortf(exitcode.ErrIllegalArgument, "duplicate signer %s", resolved)
+		}
+		if ws.Weight == 0 || ws.Weight > SignerWeightMax {
+			rt.Abortf(exitcode.ErrIllegalArgument, "weight %d of signer %s out of range [1, %d]", ws.Weight, resolved, SignerWeightMax)
+		}
+		weights[resolved] = ws.Weight
+	}
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		var newWeights []uint64
+		if len(weights) > 0 {
+			if len(weights) != len(st.Signers) {
+				rt.Abortf(exitcode.ErrIllegalArgument, "must set the weights of all %d signers, got %d", len(st.Signers), len(weights))
+			}
+			equal := true
+			newWeights = make([]uint64, 0, len(st.Signers))
+			for _, s := range st.Signers {
+				w, ok := weights[s]
+				if !ok {
+					rt.Abortf(exitcode.ErrIllegalArgument, "missing weight of signer %s", s)
+				}
+				equal = equal && w == 1
+				newWeights = append(newWeights, w)
+			}
+			// Equal weights are recorded as the legacy empty list.
+			if equal {
+				newWeights = nil
+			}
+		}
+		st.SignerWeights = newWeights
+
+		if params.NewThreshold == 0 || params.NewThreshold > st.TotalWeight() {
+			rt.Abortf(exitcode.ErrIllegalArgument, "threshold %d out of range [1, %d]", params.NewThreshold, st.TotalWeight())
+		}
+		st.NumApprovalsThreshold = params.NewThreshold
+	})
+	return nil
+}
+
+type SetSpendingLimitParams struct {
+	// The maximum value which executed transactions may send in each period.
+	Limit abi.TokenAmount
+	// The length of the periods, or zero to remove the limit.
+	Period abi.ChainEpoch
+}
+
+// Sets or removes a limit on the value sent by executed transactions in each period of epochs.
+// The value already sent in the current period is forgotten.
+func (a Actor) SetSpendingLimit(rt runtime.Runtime, params *SetSpendingLimitParams) *abi.EmptyValue {
+	// Can only be called by the multisig wallet itself.
+	rt.ValidateImmediateCallerIs(rt.Receiver())
+
+	if params.Period < 0 {
+		rt.Abortf(exitcode.ErrIllegalArgument, "negative spending period %d", params.Period)
+	}
+	if params.Limit.LessThan(big.Zero()) {
+		rt.Abortf(exitcode.ErrIllegalArgument, "negative spending limit %v", params.Limit)
+	}
+	if params.Period == 0 && !params.Limit.IsZero() {
+		rt.Abortf(exitcode.ErrIllegalArgument, "spending limit %v without a period", params.Limit)
+	}
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		st.SetSpendingLimit(params.Limit, params.Period, rt.CurrEpoch())
+	})
+	return nil
+}
+
 //type LockBalanceParams struct {
 //	StartEpoch abi.ChainEpoch
 //	UnlockDuration abi.ChainEpoch
@@ -526,11 +822,14 @@ func executeTransactionIfApproved(rt runtime.Runtime, st State, txnID TxnID, txn
 	var code exitcode.ExitCode
 	applied := false
 
-	thresholdMet := uint64(len(txn.Approved)) >= st.NumApprovalsThreshold
+	thresholdMet := st.ApprovalWeight(txn.Approved) >= st.NumApprovalsThreshold
 	if thresholdMet {
 		if err := st.assertAvailable(rt.CurrentBalance(), txn.Value, rt.CurrEpoch()); err != nil {
 			rt.Abortf(exitcode.ErrInsufficientFunds, "insufficient funds unlocked: %v", err)
 		}
+		if err := st.assertSpendable(txn.Value, rt.CurrEpoch()); err != nil {
+			rt.Abortf(exitcode.ErrForbidden, "spending limit exceeded: %v", err)
+		}
 
 		// A sufficient number of approvals have arrived and sufficient funds have been unlocked: relay the message and delete from pending queue.
 		code = rt.Send(
@@ -555,6 +854,13 @@ func executeTransactionIfApproved(rt runtime.Runtime, st State, txnID TxnID, txn
 
 			st.PendingTxns, err = ptx.Root()
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush pending transactions")
+
+			// The limit is checked again in case the send re-entered this actor and spent from the same period.
+			if code.IsSuccess() {
+				if err := st.recordSpend(txn.Value, rt.CurrEpoch()); err != nil {
+					rt.Abortf(exitcode.ErrForbidden, "spending limit exceeded: %v", err)
+				}
+			}
 		})
 	}
 
@@ -12,7 +12,10 @@ import (
 )
 
 type State struct {
-	Signers               []address.Address // Signers must be canonical ID-addresses.
+	Signers []address.Address // Signers must be canonical ID-addresses.
+	// Weights of the signers' approvals, in the order of Signers, or empty if every signer's approval has weight one.
+	SignerWeights []uint64
+	// Total weight of the approvals required to execute a transaction.
 	NumApprovalsThreshold uint64
 	NextTxnID             TxnID
 
@@ -21,6 +24,15 @@ type State struct {
 	StartEpoch     abi.ChainEpoch
 	UnlockDuration abi.ChainEpoch
 
+	// Spending limit
+	// The maximum value which executed transactions may send in each period, if SpendingPeriod is positive.
+	SpendingLimit abi.TokenAmount
+	// The length of the periods, aligned to multiples of the length from epoch zero, or zero for no limit.
+	SpendingPeriod abi.ChainEpoch
+	// The start of the period in which SpentInPeriod was sent.
+	SpendingPeriodStart abi.ChainEpoch
+	SpentInPeriod       abi.TokenAmount
+
 	PendingTxns cid.Cid // HAMT[TxnID]Transaction
 }
 
@@ -34,6 +46,40 @@ func (st *State) IsSigner(address address.Address) bool {
 	return false
 }
 
+// Returns the weight of a signer's approval, which is zero for an address which is not a signer.
+func (st *State) SignerWeight(address address.Address) uint64 {
+	for i, signer := range st.Signers {
+		if signer == address {
+			if len(st.SignerWeights) == 0 {
+				return 1
+			}
+			return st.SignerWeights[i]
+		}
+	}
+	return 0
+}
+
+// Returns the total weight of all signers' approvals.
+func (st *State) TotalWeight() uint64 {
+	if len(st.SignerWeights) == 0 {
+		return uint64(len(st.Signers))
+	}
+	total := uint64(0)
+	for _, w := range st.SignerWeights {
+		total += w
+	}
+	return total
+}
+
+// Returns the total weight of a list of approvals.
+func (st *State) ApprovalWeight(approvers []address.Address) uint64 {
+	total := uint64(0)
+	for _, approver := range approvers {
+		total += st.SignerWeight(approver)
+	}
+	return total
+}
+
 func (st *State) SetLocked(startEpoch abi.ChainEpoch, unlockDuration abi.ChainEpoch, lockedAmount abi.TokenAmount) {
 	st.StartEpoch = startEpoch
 	st.UnlockDuration = unlockDuration
@@ -143,6 +189,61 @@ func (st *State) assertAvailable(currBalance abi.TokenAmount, amountToSpend abi.
 	return nil
 }
 
+// Sets the spending limit, starting a new period at the current epoch's period.
+// A zero period removes the limit.
+func (st *State) SetSpendingLimit(limit abi.TokenAmount, period abi.ChainEpoch, currEpoch abi.ChainEpoch) {
+	st.SpendingLimit = limit
+	st.SpendingPeriod = period
+	st.SpendingPeriodStart = 0
+	st.SpentInPeriod = big.Zero()
+	if period > 0 {
+		st.SpendingPeriodStart = st.spendingPeriodStart(currEpoch)
+	}
+}
+
+func (st *State) spendingPeriodStart(epoch abi.ChainEpoch) abi.ChainEpoch {
+	return epoch - epoch%st.SpendingPeriod
+}
+
+// Returns the value which executed transactions may still send in the spending period containing an epoch,
+// and false if there is no spending limit.
+func (st *State) SpendableInPeriod(epoch abi.ChainEpoch) (abi.TokenAmount, bool) {
+	if st.SpendingPeriod <= 0 {
+		return big.Zero(), false
+	}
+	spent := big.Zero()
+	if st.spendingPeriodStart(epoch) == st.SpendingPeriodStart {
+		spent = st.SpentInPeriod
+	}
+	return big.Max(big.Sub(st.SpendingLimit, spent), big.Zero()), true
+}
+
+func (st *State) assertSpendable(amountToSpend abi.TokenAmount, currEpoch abi.ChainEpoch) error {
+	spendable, limited := st.SpendableInPeriod(currEpoch)
+	if limited && amountToSpend.GreaterThan(spendable) {
+		return xerrors.Errorf("amount to spend %s exceeds %s remaining of the spending limit %s in the period from epoch %d",
+			amountToSpend, spendable, st.SpendingLimit, st.spendingPeriodStart(currEpoch))
+	}
+	return nil
+}
+
+// Records value sent by an executed transaction against the spending limit.
+func (st *State) recordSpend(amount abi.TokenAmount, currEpoch abi.ChainEpoch) error {
+	if st.SpendingPeriod <= 0 || amount.IsZero() {
+		return nil
+	}
+	if err := st.assertSpendable(amount, currEpoch); err != nil {
+		return err
+	}
+	periodStart := st.spendingPeriodStart(currEpoch)
+	if periodStart != st.SpendingPeriodStart {
+		st.SpendingPeriodStart = periodStart
+		st.SpentInPeriod = big.Zero()
+	}
+	st.SpentInPeriod = big.Add(st.SpentInPeriod, amount)
+	return nil
+}
+
 // An adt.Map key that just preserves the underlying string.
 type StringKey string
 
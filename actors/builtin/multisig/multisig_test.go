@@ -1921,6 +1921,582 @@ func TestLockBalance(t *testing.T) {
 // Helper methods for calling multisig actor methods
 //
 
+func TestTransactionExpiration(t *testing.T) {
+	actor := msActorHarness{multisig.Actor{}, t}
+	startEpoch := abi.ChainEpoch(0)
+
+	receiver := tutil.NewIDAddr(t, 100)
+	anne := tutil.NewIDAddr(t, 101)
+	bob := tutil.NewIDAddr(t, 102)
+	chuck := tutil.NewIDAddr(t, 103)
+	darlene := tutil.NewIDAddr(t, 104)
+
+	const noUnlockDuration = abi.ChainEpoch(0)
+	const numApprovals = uint64(2)
+	const txnID = int64(0)
+	const fakeMethod = abi.MethodNum(42)
+	const expiration = abi.ChainEpoch(100)
+	var sendValue = abi.NewTokenAmount(10)
+	var signers = []addr.Address{anne, bob}
+
+	builder := mock.NewBuilder(receiver).
+		WithCaller(builtin.InitActorAddr, builtin.InitActorCodeID).
+		WithHasher(blake2b.Sum256)
+
+	expiringTxn := multisig.Transaction{
+		To:         chuck,
+		Value:      sendValue,
+		Method:     fakeMethod,
+		Params:     nil,
+		Approved:   []addr.Address{anne},
+		Expiration: expiration,
+	}
+
+	t.Run("transaction can be approved until its expiration", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, numApprovals, noUnlockDuration, startEpoch, signers...)
+
+		rt.SetCaller(anne, builtin.AccountActorCodeID)
+		actor.proposeWithExpirationOK(rt, chuck, sendValue, fakeMethod, nil, expiration)
+		actor.assertTransactions(rt, expiringTxn)
+
+		rt.SetEpoch(expiration)
+		rt.SetBalance(sendValue)
+		rt.SetCaller(bob, builtin.AccountActorCodeID)
+		rt.ExpectSend(chuck, fakeMethod, nil, sendValue, nil, 0)
+		actor.approveOK(rt, txnID, makeProposalHash(t, &expiringTxn), nil)
+		actor.assertTransactions(rt)
+		actor.checkState(rt)
+	})
+
+	t.Run("expired transaction cannot be approved", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, numApprovals, noUnlockDuration, startEpoch, signers...)
+
+		rt.SetCaller(anne, builtin.AccountActorCodeID)
+		actor.proposeWithExpirationOK(rt, chuck, sendValue, fakeMethod, nil, expiration)
+
+		rt.SetEpoch(expiration + 1)
+		rt.SetBalance(sendValue)
+		rt.SetCaller(bob, builtin.AccountActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "expired", func() {
+			actor.approve(rt, txnID, nil, nil)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("transaction without expiration does not expire", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, numApprovals, noUnlockDuration, startEpoch, signers...)
+
+		rt.SetCaller(anne, builtin.AccountActorCodeID)
+		actor.proposeOK(rt, chuck, sendValue, fakeMethod, nil, nil)
+
+		rt.SetEpoch(1 << 30)
+		rt.SetCaller(darlene, builtin.AccountActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "has not expired", func() {
+			actor.removeExpiredTransaction(rt, txnID, nil)
+		})
+		rt.Reset()
+
+		rt.SetBalance(sendValue)
+		rt.SetCaller(bob, builtin.AccountActorCodeID)
+		rt.ExpectSend(chuck, fakeMethod, nil, sendValue, nil, 0)
+		actor.approveOK(rt, txnID, nil, nil)
+		actor.checkState(rt)
+	})
+
+	t.Run("anyone can remove an expired transaction", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, numApprovals, noUnlockDuration, startEpoch, signers...)
+
+		rt.SetCaller(anne, builtin.AccountActorCodeID)
+		actor.proposeWithExpirationOK(rt, chuck, sendValue, fakeMethod, nil, expiration)
+
+		rt.SetCaller(darlene, builtin.AccountActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "has not expired", func() {
+			actor.removeExpiredTransaction(rt, txnID, nil)
+		})
+		rt.Reset()
+
+		rt.SetEpoch(expiration + 1)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "hash does not match", func() {
+			actor.removeExpiredTransaction(rt, txnID, makeProposalHash(t, &multisig.Transaction{
+				To:       bob, // mismatched To
+				Value:    sendValue,
+				Method:   fakeMethod,
+				Approved: []addr.Address{anne},
+			}))
+		})
+		rt.Reset()
+
+		actor.removeExpiredTransaction(rt, txnID, makeProposalHash(t, &expiringTxn))
+		actor.assertTransactions(rt)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no such transaction", func() {
+			actor.removeExpiredTransaction(rt, txnID, nil)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("fail to propose a transaction which has already expired", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, numApprovals, noUnlockDuration, startEpoch, signers...)
+
+		rt.SetEpoch(expiration + 1)
+		rt.SetCaller(anne, builtin.AccountActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "before current epoch", func() {
+			actor.proposeWithExpiration(rt, chuck, sendValue, fakeMethod, nil, expiration)
+		})
+		actor.assertTransactions(rt)
+	})
+}
+
+func TestApproveBatch(t *testing.T) {
+	actor := msActorHarness{multisig.Actor{}, t}
+	startEpoch := abi.ChainEpoch(0)
+
+	receiver := tutil.NewIDAddr(t, 100)
+	anne := tutil.NewIDAddr(t, 101)
+	bob := tutil.NewIDAddr(t, 102)
+	chuck := tutil.NewIDAddr(t, 103)
+	darlene := tutil.NewIDAddr(t, 104)
+
+	const noUnlockDuration = abi.ChainEpoch(0)
+	const fakeMethod = abi.MethodNum(42)
+	var sendValue = abi.NewTokenAmount(10)
+	var signers = []addr.Address{anne, bob, chuck}
+
+	builder := mock.NewBuilder(receiver).
+		WithCaller(builtin.InitActorAddr, builtin.InitActorCodeID).
+		WithHasher(blake2b.Sum256)
+
+	t.Run("approvals succeed or fail individually", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, 2, noUnlockDuration, startEpoch, signers...)
+
+		rt.SetCaller(anne, builtin.AccountActorCodeID)
+		hash0 := actor.proposeOK(rt, darlene, sendValue, fakeMethod, nil, nil)
+		actor.proposeOK(rt, darlene, sendValue, fakeMethod, []byte{1}, nil)
+
+		rt.SetBalance(sendValue)
+		rt.SetCaller(bob, builtin.AccountActorCodeID)
+		rt.ExpectSend(darlene, fakeMethod, nil, sendValue, nil, exitcode.ErrIllegalArgument)
+		ret := actor.approveBatch(rt,
+			multisig.TxnIDParams{ID: 0, ProposalHash: hash0},
+			multisig.TxnIDParams{ID: 1, ProposalHash: hash0},
+			multisig.TxnIDParams{ID: 2},
+		)
+		assert.Equal(t, []multisig.ApproveBatchResult{
+			{ApprovalCode: exitcode.Ok, Applied: true, Code: exitcode.ErrIllegalArgument, Ret: []byte{}},
+			{ApprovalCode: exitcode.ErrIllegalArgument},
+			{ApprovalCode: exitcode.ErrNotFound},
+		}, ret.Results)
+
+		// The failed approval was not recorded.
+		actor.assertTransactions(rt, multisig.Transaction{
+			To:       darlene,
+			Value:    sendValue,
+			Method:   fakeMethod,
+			Params:   []byte{1},
+			Approved: []addr.Address{anne},
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("approval below threshold is recorded and duplicate approval fails", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, 3, noUnlockDuration, startEpoch, signers...)
+
+		rt.SetCaller(anne, builtin.AccountActorCodeID)
+		actor.proposeOK(rt, darlene, sendValue, fakeMethod, nil, nil)
+
+		rt.SetCaller(bob, builtin.AccountActorCodeID)
+		ret := actor.approveBatch(rt, multisig.TxnIDParams{ID: 0}, multisig.TxnIDParams{ID: 0})
+		assert.Equal(t, []multisig.ApproveBatchResult{
+			{ApprovalCode: exitcode.Ok, Applied: false, Code: exitcode.Ok},
+			{ApprovalCode: exitcode.ErrForbidden},
+		}, ret.Results)
+		actor.assertTransactions(rt, multisig.Transaction{
+			To:       darlene,
+			Value:    sendValue,
+			Method:   fakeMethod,
+			Approved: []addr.Address{anne, bob},
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("approval which would execute without sufficient funds is not recorded", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, 2, noUnlockDuration, startEpoch, signers...)
+
+		rt.SetCaller(anne, builtin.AccountActorCodeID)
+		actor.proposeOK(rt, darlene, sendValue, fakeMethod, nil, nil)
+
+		rt.SetBalance(big.Sub(sendValue, big.NewInt(1)))
+		rt.SetCaller(bob, builtin.AccountActorCodeID)
+		ret := actor.approveBatch(rt, multisig.TxnIDParams{ID: 0})
+		assert.Equal(t, []multisig.ApproveBatchResult{{ApprovalCode: exitcode.ErrInsufficientFunds}}, ret.Results)
+		actor.assertTransactions(rt, multisig.Transaction{
+			To:       darlene,
+			Value:    sendValue,
+			Method:   fakeMethod,
+			Approved: []addr.Address{anne},
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("non-signer approvals fail", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, 2, noUnlockDuration, startEpoch, signers...)
+
+		rt.SetCaller(anne, builtin.AccountActorCodeID)
+		actor.proposeOK(rt, darlene, sendValue, fakeMethod, nil, nil)
+
+		rt.SetCaller(darlene, builtin.AccountActorCodeID)
+		ret := actor.approveBatch(rt, multisig.TxnIDParams{ID: 0})
+		assert.Equal(t, []multisig.ApproveBatchResult{{ApprovalCode: exitcode.ErrForbidden}}, ret.Results)
+		actor.checkState(rt)
+	})
+
+	t.Run("fail with too many approvals", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, 2, noUnlockDuration, startEpoch, signers...)
+
+		rt.SetCaller(bob, builtin.AccountActorCodeID)
+		approvals := make([]multisig.TxnIDParams, multisig.ApproveBatchMax+1)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "exceeds max", func() {
+			actor.approveBatch(rt, approvals...)
+		})
+	})
+}
+
+func TestSignerWeights(t *testing.T) {
+	actor := msActorHarness{multisig.Actor{}, t}
+	startEpoch := abi.ChainEpoch(0)
+
+	receiver := tutil.NewIDAddr(t, 100)
+	anne := tutil.NewIDAddr(t, 101)
+	bob := tutil.NewIDAddr(t, 102)
+	chuck := tutil.NewIDAddr(t, 103)
+	darlene := tutil.NewIDAddr(t, 104)
+	eve := tutil.NewIDAddr(t, 105)
+
+	const noUnlockDuration = abi.ChainEpoch(0)
+	const fakeMethod = abi.MethodNum(42)
+	var sendValue = abi.NewTokenAmount(10)
+	var signers = []addr.Address{anne, bob, chuck}
+
+	builder := mock.NewBuilder(receiver).WithCaller(builtin.InitActorAddr, builtin.InitActorCodeID)
+
+	weighted := func(anneWeight, bobWeight, chuckWeight uint64) []multisig.WeightedSigner {
+		return []multisig.WeightedSigner{{Signer: anne, Weight: anneWeight}, {Signer: bob, Weight: bobWeight}, {Signer: chuck, Weight: chuckWeight}}
+	}
+
+	t.Run("transaction executes when approvals reach the weight threshold", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, 1, noUnlockDuration, startEpoch, signers...)
+		rt.SetCaller(receiver, builtin.MultisigActorCodeID)
+		actor.setSignerWeights(rt, weighted(3, 1, 1), 3)
+
+		var st multisig.State
+		rt.GetState(&st)
+		assert.Equal(t, []uint64{3, 1, 1}, st.SignerWeights)
+		assert.Equal(t, uint64(3), st.NumApprovalsThreshold)
+		assert.Equal(t, uint64(5), st.TotalWeight())
+
+		// Approvals of weight two do not meet the threshold.
+		rt.SetCaller(bob, builtin.AccountActorCodeID)
+		actor.proposeOK(rt, darlene, sendValue, fakeMethod, nil, nil)
+		rt.SetCaller(chuck, builtin.AccountActorCodeID)
+		actor.approveOK(rt, 0, nil, nil)
+		actor.assertTransactions(rt, multisig.Transaction{
+			To:       darlene,
+			Value:    sendValue,
+			Method:   fakeMethod,
+			Approved: []addr.Address{bob, chuck},
+		})
+
+		// A proposal from anne alone executes immediately.
+		rt.SetBalance(sendValue)
+		rt.SetCaller(anne, builtin.AccountActorCodeID)
+		rt.ExpectSend(darlene, fakeMethod, nil, sendValue, nil, exitcode.Ok)
+		actor.proposeOK(rt, darlene, sendValue, fakeMethod, nil, nil)
+		actor.checkState(rt)
+	})
+
+	t.Run("equal weights are recorded as no weights", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, 2, noUnlockDuration, startEpoch, signers...)
+		rt.SetCaller(receiver, builtin.MultisigActorCodeID)
+		actor.setSignerWeights(rt, weighted(2, 1, 1), 2)
+		actor.setSignerWeights(rt, weighted(1, 1, 1), 3)
+
+		var st multisig.State
+		rt.GetState(&st)
+		assert.Empty(t, st.SignerWeights)
+		assert.Equal(t, uint64(3), st.NumApprovalsThreshold)
+
+		actor.setSignerWeights(rt, weighted(2, 1, 1), 2)
+		actor.setSignerWeights(rt, nil, 1)
+		rt.GetState(&st)
+		assert.Empty(t, st.SignerWeights)
+		assert.Equal(t, uint64(1), st.NumApprovalsThreshold)
+		actor.checkState(rt)
+	})
+
+	t.Run("fail to set invalid weights", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, 2, noUnlockDuration, startEpoch, signers...)
+		rt.SetCaller(receiver, builtin.MultisigActorCodeID)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "must set the weights of all", func() {
+			actor.setSignerWeights(rt, weighted(2, 1, 1)[:2], 2)
+		})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "missing weight of signer", func() {
+			actor.setSignerWeights(rt, append(weighted(2, 1, 1)[:2], multisig.WeightedSigner{Signer: darlene, Weight: 1}), 2)
+		})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "duplicate signer", func() {
+			actor.setSignerWeights(rt, append(weighted(2, 1, 1)[:2], multisig.WeightedSigner{Signer: anne, Weight: 1}), 2)
+		})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "out of range", func() {
+			actor.setSignerWeights(rt, weighted(2, 0, 1), 2)
+		})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "out of range", func() {
+			actor.setSignerWeights(rt, weighted(multisig.SignerWeightMax+1, 1, 1), 2)
+		})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "threshold 5 out of range", func() {
+			actor.setSignerWeights(rt, weighted(2, 1, 1), 5)
+		})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "threshold 0 out of range", func() {
+			actor.setSignerWeights(rt, weighted(2, 1, 1), 0)
+		})
+
+		rt.SetCaller(anne, builtin.AccountActorCodeID)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			actor.setSignerWeights(rt, weighted(2, 1, 1), 2)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("signer changes preserve weights", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, 1, noUnlockDuration, startEpoch, signers...)
+		rt.SetCaller(receiver, builtin.MultisigActorCodeID)
+		actor.setSignerWeights(rt, weighted(3, 2, 1), 4)
+
+		// A new signer's approval has weight one.
+		actor.addSigner(rt, darlene, true)
+		var st multisig.State
+		rt.GetState(&st)
+		assert.Equal(t, []addr.Address{anne, bob, chuck, darlene}, st.Signers)
+		assert.Equal(t, []uint64{3, 2, 1, 1}, st.SignerWeights)
+		assert.Equal(t, uint64(5), st.NumApprovalsThreshold)
+
+		// A swapped-in signer takes the weight of the signer it replaces.
+		actor.swapSigners(rt, anne, eve)
+		rt.GetState(&st)
+		assert.Equal(t, []addr.Address{bob, chuck, darlene, eve}, st.Signers)
+		assert.Equal(t, []uint64{2, 1, 1, 3}, st.SignerWeights)
+
+		// Removing a signer must leave enough weight to meet the threshold.
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "below threshold", func() {
+			actor.removeSigner(rt, eve, false)
+		})
+		// Decreasing the threshold decreases it by the signer's weight.
+		actor.removeSigner(rt, eve, true)
+		rt.GetState(&st)
+		assert.Equal(t, []addr.Address{bob, chuck, darlene}, st.Signers)
+		assert.Equal(t, []uint64{2, 1, 1}, st.SignerWeights)
+		assert.Equal(t, uint64(2), st.NumApprovalsThreshold)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "can't decrease approvals", func() {
+			actor.removeSigner(rt, bob, true)
+		})
+
+		// The threshold may be raised to the total weight.
+		actor.changeNumApprovalsThreshold(rt, 4)
+		rt.ExpectAbort(exitcode.ErrIllegalArgument, func() {
+			actor.changeNumApprovalsThreshold(rt, 5)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("removing a weighted signer discounts its approvals", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, 1, noUnlockDuration, startEpoch, signers...)
+		rt.SetCaller(receiver, builtin.MultisigActorCodeID)
+		actor.setSignerWeights(rt, weighted(2, 3, 1), 4)
+
+		rt.SetCaller(anne, builtin.AccountActorCodeID)
+		actor.proposeOK(rt, darlene, sendValue, fakeMethod, nil, nil)
+		rt.SetCaller(chuck, builtin.AccountActorCodeID)
+		actor.approveOK(rt, 0, nil, nil)
+
+		rt.SetCaller(receiver, builtin.MultisigActorCodeID)
+		actor.removeSigner(rt, anne, false)
+		actor.assertTransactions(rt, multisig.Transaction{
+			To:       darlene,
+			Value:    sendValue,
+			Method:   fakeMethod,
+			Approved: []addr.Address{chuck},
+		})
+
+		// Bob's approval with chuck's meets the threshold and executes the transaction.
+		rt.SetBalance(sendValue)
+		rt.SetCaller(bob, builtin.AccountActorCodeID)
+		rt.ExpectSend(darlene, fakeMethod, nil, sendValue, nil, exitcode.Ok)
+		actor.approveOK(rt, 0, nil, nil)
+		actor.assertTransactions(rt)
+		actor.checkState(rt)
+	})
+}
+
+func TestSpendingLimit(t *testing.T) {
+	actor := msActorHarness{multisig.Actor{}, t}
+	startEpoch := abi.ChainEpoch(0)
+
+	receiver := tutil.NewIDAddr(t, 100)
+	anne := tutil.NewIDAddr(t, 101)
+	bob := tutil.NewIDAddr(t, 102)
+	darlene := tutil.NewIDAddr(t, 104)
+
+	const noUnlockDuration = abi.ChainEpoch(0)
+	const period = abi.ChainEpoch(100)
+	var limit = abi.NewTokenAmount(15)
+	var signers = []addr.Address{anne, bob}
+
+	builder := mock.NewBuilder(receiver).
+		WithCaller(builtin.InitActorAddr, builtin.InitActorCodeID).
+		WithHasher(blake2b.Sum256).
+		WithBalance(abi.NewTokenAmount(100), big.Zero())
+
+	t.Run("executed transactions are limited in each period", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, 1, noUnlockDuration, startEpoch, signers...)
+		rt.SetEpoch(150)
+		rt.SetCaller(receiver, builtin.MultisigActorCodeID)
+		actor.setSpendingLimit(rt, limit, period)
+
+		rt.SetCaller(anne, builtin.AccountActorCodeID)
+		rt.ExpectSend(darlene, builtin.MethodSend, nil, abi.NewTokenAmount(10), nil, exitcode.Ok)
+		actor.proposeOK(rt, darlene, abi.NewTokenAmount(10), builtin.MethodSend, nil, nil)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "spending limit exceeded", func() {
+			actor.proposeOK(rt, darlene, abi.NewTokenAmount(10), builtin.MethodSend, nil, nil)
+		})
+
+		// The rest of the limit may be spent.
+		rt.ExpectSend(darlene, builtin.MethodSend, nil, abi.NewTokenAmount(5), nil, exitcode.Ok)
+		actor.proposeOK(rt, darlene, abi.NewTokenAmount(5), builtin.MethodSend, nil, nil)
+
+		var st multisig.State
+		rt.GetState(&st)
+		assert.Equal(t, abi.ChainEpoch(100), st.SpendingPeriodStart)
+		assert.Equal(t, limit, st.SpentInPeriod)
+		spendable, limited := st.SpendableInPeriod(199)
+		assert.True(t, limited)
+		assert.True(t, spendable.IsZero())
+
+		// The limit applies afresh in the next period.
+		rt.SetEpoch(200)
+		rt.ExpectSend(darlene, builtin.MethodSend, nil, abi.NewTokenAmount(10), nil, exitcode.Ok)
+		actor.proposeOK(rt, darlene, abi.NewTokenAmount(10), builtin.MethodSend, nil, nil)
+		rt.GetState(&st)
+		assert.Equal(t, abi.ChainEpoch(200), st.SpendingPeriodStart)
+		assert.Equal(t, abi.NewTokenAmount(10), st.SpentInPeriod)
+		actor.checkState(rt)
+	})
+
+	t.Run("failed sends and transactions without value are not limited", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, 1, noUnlockDuration, startEpoch, signers...)
+		rt.SetCaller(receiver, builtin.MultisigActorCodeID)
+		actor.setSpendingLimit(rt, big.Zero(), period)
+
+		rt.SetCaller(anne, builtin.AccountActorCodeID)
+		rt.ExpectSend(darlene, abi.MethodNum(42), nil, big.Zero(), nil, exitcode.Ok)
+		actor.proposeOK(rt, darlene, big.Zero(), abi.MethodNum(42), nil, nil)
+
+		rt.SetCaller(receiver, builtin.MultisigActorCodeID)
+		actor.setSpendingLimit(rt, limit, period)
+		rt.SetCaller(anne, builtin.AccountActorCodeID)
+		rt.ExpectSend(darlene, builtin.MethodSend, nil, limit, nil, exitcode.ErrIllegalArgument)
+		code := actor.propose(rt, darlene, limit, builtin.MethodSend, nil, nil)
+		assert.Equal(t, exitcode.ErrIllegalArgument, code)
+
+		var st multisig.State
+		rt.GetState(&st)
+		assert.True(t, st.SpentInPeriod.IsZero())
+		actor.checkState(rt)
+	})
+
+	t.Run("batch approvals exceeding the limit are not recorded", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, 2, noUnlockDuration, startEpoch, signers...)
+		rt.SetCaller(receiver, builtin.MultisigActorCodeID)
+		actor.setSpendingLimit(rt, limit, period)
+
+		rt.SetCaller(anne, builtin.AccountActorCodeID)
+		actor.proposeOK(rt, darlene, abi.NewTokenAmount(10), builtin.MethodSend, nil, nil)
+		actor.proposeOK(rt, darlene, abi.NewTokenAmount(10), builtin.MethodSend, nil, nil)
+
+		rt.SetCaller(bob, builtin.AccountActorCodeID)
+		rt.ExpectSend(darlene, builtin.MethodSend, nil, abi.NewTokenAmount(10), nil, exitcode.Ok)
+		ret := actor.approveBatch(rt, multisig.TxnIDParams{ID: 0}, multisig.TxnIDParams{ID: 1})
+		assert.Equal(t, []multisig.ApproveBatchResult{
+			{ApprovalCode: exitcode.Ok, Applied: true, Code: exitcode.Ok, Ret: []byte{}},
+			{ApprovalCode: exitcode.ErrForbidden},
+		}, ret.Results)
+		actor.assertTransactions(rt, multisig.Transaction{
+			To:       darlene,
+			Value:    abi.NewTokenAmount(10),
+			Method:   builtin.MethodSend,
+			Approved: []addr.Address{anne},
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("limit can be removed", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, 1, noUnlockDuration, startEpoch, signers...)
+		rt.SetCaller(receiver, builtin.MultisigActorCodeID)
+		actor.setSpendingLimit(rt, big.Zero(), period)
+		actor.setSpendingLimit(rt, big.Zero(), 0)
+
+		var st multisig.State
+		rt.GetState(&st)
+		_, limited := st.SpendableInPeriod(0)
+		assert.False(t, limited)
+
+		rt.SetCaller(anne, builtin.AccountActorCodeID)
+		rt.ExpectSend(darlene, builtin.MethodSend, nil, abi.NewTokenAmount(100), nil, exitcode.Ok)
+		actor.proposeOK(rt, darlene, abi.NewTokenAmount(100), builtin.MethodSend, nil, nil)
+		actor.checkState(rt)
+	})
+
+	t.Run("fail to set invalid limit", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt, 1, noUnlockDuration, startEpoch, signers...)
+		rt.SetCaller(receiver, builtin.MultisigActorCodeID)
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "negative spending period", func() {
+			actor.setSpendingLimit(rt, limit, -1)
+		})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "negative spending limit", func() {
+			actor.setSpendingLimit(rt, abi.NewTokenAmount(-1), period)
+		})
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "without a period", func() {
+			actor.setSpendingLimit(rt, limit, 0)
+		})
+
+		rt.SetCaller(anne, builtin.AccountActorCodeID)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			actor.setSpendingLimit(rt, limit, period)
+		})
+		actor.checkState(rt)
+	})
+}
+
 type msActorHarness struct {
 	a multisig.Actor
 	t testing.TB
@@ -2010,6 +2586,13 @@ func (h *msActorHarness) approveOK(rt *mock.Runtime, txnID int64, proposalParams
 	}
 }
 
+func (h *msActorHarness) approveBatch(rt *mock.Runtime, approvals ...multisig.TxnIDParams) *multisig.ApproveBatchReturn {
+	rt.ExpectValidateCallerType(builtin.AccountActorCodeID, builtin.MultisigActorCodeID)
+	ret := rt.Call(h.a.ApproveBatch, &multisig.ApproveBatchParams{Approvals: approvals})
+	rt.Verify()
+	return ret.(*multisig.ApproveBatchReturn)
+}
+
 func (h *msActorHarness) cancel(rt *mock.Runtime, txnID int64, proposalParams []byte) {
 	rt.ExpectValidateCallerType(builtin.AccountActorCodeID, builtin.MultisigActorCodeID)
 	rt.Call(h.a.Cancel, &multisig.TxnIDParams{
@@ -2019,6 +2602,35 @@ func (h *msActorHarness) cancel(rt *mock.Runtime, txnID int64, proposalParams []
 	rt.Verify()
 }
 
+func (h *msActorHarness) proposeWithExpiration(rt *mock.Runtime, to addr.Address, value abi.TokenAmount, method abi.MethodNum, params []byte, expiration abi.ChainEpoch) exitcode.ExitCode {
+	rt.ExpectValidateCallerType(builtin.AccountActorCodeID, builtin.MultisigActorCodeID)
+	ret := rt.Call(h.a.ProposeWithExpiration, &multisig.ProposeWithExpirationParams{
+		To:         to,
+		Value:      value,
+		Method:     method,
+		Params:     params,
+		Expiration: expiration,
+	})
+	rt.Verify()
+	return ret.(*multisig.ProposeReturn).Code
+}
+
+func (h *msActorHarness) proposeWithExpirationOK(rt *mock.Runtime, to addr.Address, value abi.TokenAmount, method abi.MethodNum, params []byte, expiration abi.ChainEpoch) {
+	code := h.proposeWithExpiration(rt, to, value, method, params, expiration)
+	if code != exitcode.Ok {
+		h.t.Fatalf("unexpected exitcode %d from propose", code)
+	}
+}
+
+func (h *msActorHarness) removeExpiredTransaction(rt *mock.Runtime, txnID int64, proposalParams []byte) {
+	rt.ExpectValidateCallerAny()
+	rt.Call(h.a.RemoveExpiredTransaction, &multisig.TxnIDParams{
+		ID:           multisig.TxnID(txnID),
+		ProposalHash: proposalParams,
+	})
+	rt.Verify()
+}
+
 func (h *msActorHarness) addSigner(rt *mock.Runtime, signer addr.Address, increase bool) {
 	rt.ExpectValidateCallerAddr(rt.Receiver())
 	rt.Call(h.a.AddSigner, &multisig.AddSignerParams{
@@ -2054,6 +2666,24 @@ func (h *msActorHarness) changeNumApprovalsThreshold(rt *mock.Runtime, newThresh
 	rt.Verify()
 }
 
+func (h *msActorHarness) setSignerWeights(rt *mock.Runtime, signers []multisig.WeightedSigner, newThreshold uint64) {
+	rt.ExpectValidateCallerAddr(rt.Receiver())
+	rt.Call(h.a.SetSignerWeights, &multisig.SetSignerWeightsParams{
+		Signers:      signers,
+		NewThreshold: newThreshold,
+	})
+	rt.Verify()
+}
+
+func (h *msActorHarness) setSpendingLimit(rt *mock.Runtime, limit abi.TokenAmount, period abi.ChainEpoch) {
+	rt.ExpectValidateCallerAddr(rt.Receiver())
+	rt.Call(h.a.SetSpendingLimit, &multisig.SetSpendingLimitParams{
+		Limit:  limit,
+		Period: period,
+	})
+	rt.Verify()
+}
+
 func (h *msActorHarness) lockBalance(rt *mock.Runtime, start, duration abi.ChainEpoch, amount abi.TokenAmount) {
 	rt.ExpectValidateCallerAddr(rt.Receiver())
 	rt.Call(h.a.LockBalance, &multisig.LockBalanceParams{
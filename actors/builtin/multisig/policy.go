@@ -3,3 +3,9 @@ package multisig
 // SignersMax is the maximum number of signers allowed in a multisig. If more
 // are required, please use a combining tree of multisigs.
 const SignersMax = 256
+
+// ApproveBatchMax is the maximum number of transactions which may be approved in one batch.
+const ApproveBatchMax = 64
+
+// SignerWeightMax is the maximum weight of a signer's approval.
+const SignerWeightMax = 1 << 32
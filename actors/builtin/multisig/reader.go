@@ -0,0 +1,112 @@
+package multisig
+
+import (
+	"sort"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/minio/blake2b-simd"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// Read-only queries of a multisig's state, for wallets which render pending transactions for approval.
+// A reader answers from the head it was created with. A wallet should create a new reader for each head it renders,
+// as a transaction may have been approved or cancelled since.
+type StateReader struct {
+	store adt.Store
+	st    State
+}
+
+// A pending transaction, with the hash of its proposal to bind approvals to it.
+type PendingTransaction struct {
+	ID          TxnID
+	Transaction Transaction
+	// The hash of the proposal, as computed by ComputeProposalHash.
+	ProposalHash []byte
+	// The transaction's parameters decoded for its method, or nil if the method is not known to the decoder
+	// or the parameters are not valid for it.
+	DecodedParams interface{}
+}
+
+// Decodes the parameters of a method to be invoked on an actor.
+// Returns nil, with no error, if the method is not known.
+type ParamsDecoder func(to addr.Address, method abi.MethodNum, params []byte) (interface{}, error)
+
+// Loads a multisig's state from its head.
+func NewStateReader(store adt.Store, head cid.Cid) (*StateReader, error) {
+	r := &StateReader{store: store}
+	if err := store.Get(store.Context(), head, &r.st); err != nil {
+		return nil, xerrors.Errorf("failed to load multisig state %v: %w", head, err)
+	}
+	return r, nil
+}
+
+// The state loaded from the head, shared with the reader's queries.
+func (r *StateReader) State() *State {
+	return &r.st
+}
+
+// Returns the pending transactions in increasing order of ID, decoding their parameters with a decoder,
+// which may be nil.
+func (r *StateReader) PendingTransactions(decode ParamsDecoder) ([]PendingTransaction, error) {
+	txns, err := adt.AsMap(r.store, r.st.PendingTxns, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load pending transactions: %w", err)
+	}
+	pending := []PendingTransaction{}
+	var txn Transaction
+	if err := txns.ForEach(&txn, func(k string) error {
+		id, err := ParseTxnIDKey(k)
+		if err != nil {
+			return xerrors.Errorf("invalid transaction key %x: %w", k, err)
+		}
+		p, err := newPendingTransaction(id, txn, decode)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, *p)
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to iterate pending transactions: %w", err)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].ID < pending[j].ID })
+	return pending, nil
+}
+
+// Returns a pending transaction, if it exists, decoding its parameters with a decoder, which may be nil.
+func (r *StateReader) PendingTransaction(id TxnID, decode ParamsDecoder) (*PendingTransaction, bool, error) {
+	txns, err := adt.AsMap(r.store, r.st.PendingTxns, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, false, xerrors.Errorf("failed to load pending transactions: %w", err)
+	}
+	var txn Transaction
+	if found, err := txns.Get(id, &txn); err != nil {
+		return nil, false, xerrors.Errorf("failed to get transaction %v: %w", id, err)
+	} else if !found {
+		return nil, false, nil
+	}
+	p, err := newPendingTransaction(id, txn, decode)
+	if err != nil {
+		return nil, false, err
+	}
+	return p, true, nil
+}
+
+func newPendingTransaction(id TxnID, txn Transaction, decode ParamsDecoder) (*PendingTransaction, error) {
+	hash, err := ComputeProposalHash(&txn, blake2b.Sum256)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to compute proposal hash for %v: %w", id, err)
+	}
+	p := PendingTransaction{ID: id, Transaction: txn, ProposalHash: hash}
+	if decode != nil {
+		// Parameters which fail to decode are not an error; the transaction would fail when executed.
+		if params, err := decode(txn.To, txn.Method, txn.Params); err == nil {
+			p.DecodedParams = params
+		}
+	}
+	return &p, nil
+}
@@ -0,0 +1,87 @@
+package multisig_test
+
+import (
+	"bytes"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/minio/blake2b-simd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/exported"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/multisig"
+	"github.com/filecoin-project/specs-actors/v7/support/mock"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestStateReader(t *testing.T) {
+	actor := msActorHarness{multisig.Actor{}, t}
+	receiver := tutil.NewIDAddr(t, 100)
+	anne := tutil.NewIDAddr(t, 101)
+	bob := tutil.NewIDAddr(t, 102)
+	chuck := tutil.NewIDAddr(t, 103)
+
+	rt := mock.NewBuilder(receiver).
+		WithCaller(builtin.InitActorAddr, builtin.InitActorCodeID).
+		WithHasher(blake2b.Sum256).
+		Build(t)
+	actor.constructAndVerify(rt, 2, 0, 0, anne, bob)
+
+	addSigner := multisig.AddSignerParams{Signer: chuck, Increase: false}
+	buf := bytes.Buffer{}
+	require.NoError(t, addSigner.MarshalCBOR(&buf))
+
+	rt.SetCaller(anne, builtin.AccountActorCodeID)
+	sendHash := actor.proposeOK(rt, chuck, abi.NewTokenAmount(10), builtin.MethodSend, nil, nil)
+	addSignerHash := actor.proposeOK(rt, receiver, abi.NewTokenAmount(0), builtin.MethodsMultisig.AddSigner, buf.Bytes(), nil)
+	actor.proposeOK(rt, receiver, abi.NewTokenAmount(0), builtin.MethodsMultisig.AddSigner, []byte{0x80}, nil)
+
+	r, err := multisig.NewStateReader(rt.AdtStore(), rt.StateRoot())
+	require.NoError(t, err)
+	decode := exported.MultisigParamsDecoder(func(a addr.Address) (cid.Cid, bool, error) {
+		if a == receiver {
+			return builtin.MultisigActorCodeID, true, nil
+		}
+		return cid.Undef, false, nil
+	})
+
+	t.Run("pending transactions", func(t *testing.T) {
+		pending, err := r.PendingTransactions(decode)
+		require.NoError(t, err)
+		require.Len(t, pending, 3)
+
+		assert.Equal(t, multisig.TxnID(0), pending[0].ID)
+		assert.Equal(t, chuck, pending[0].Transaction.To)
+		assert.Equal(t, sendHash, pending[0].ProposalHash)
+		assert.Nil(t, pending[0].DecodedParams)
+
+		assert.Equal(t, multisig.TxnID(1), pending[1].ID)
+		assert.Equal(t, addSignerHash, pending[1].ProposalHash)
+		assert.Equal(t, &addSigner, pending[1].DecodedParams)
+
+		// Parameters which are invalid for the method are not decoded.
+		assert.Equal(t, multisig.TxnID(2), pending[2].ID)
+		assert.Nil(t, pending[2].DecodedParams)
+
+		// Parameters are not decoded without a decoder.
+		pending, err = r.PendingTransactions(nil)
+		require.NoError(t, err)
+		assert.Nil(t, pending[1].DecodedParams)
+	})
+
+	t.Run("pending transaction", func(t *testing.T) {
+		p, found, err := r.PendingTransaction(1, decode)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, addSignerHash, p.ProposalHash)
+		assert.Equal(t, &addSigner, p.DecodedParams)
+
+		_, found, err = r.PendingTransaction(3, decode)
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
 	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
 )
@@ -20,8 +21,26 @@ func CheckStateInvariants(st *State, store adt.Store) (*StateSummary, *builtin.M
 
 	// assert invariants involving signers
 	acc.Require(len(st.Signers) <= SignersMax, "multisig has too many signers: %d", len(st.Signers))
-	acc.Require(uint64(len(st.Signers)) >= st.NumApprovalsThreshold,
-		"multisig has insufficient signers to meet threshold (%d < %d)", len(st.Signers), st.NumApprovalsThreshold)
+	acc.Require(len(st.SignerWeights) == 0 || len(st.SignerWeights) == len(st.Signers),
+		"multisig has %d signer weights for %d signers", len(st.SignerWeights), len(st.Signers))
+	for i, w := range st.SignerWeights {
+		acc.Require(w > 0 && w <= SignerWeightMax, "signer weight %d at index %d out of range", w, i)
+	}
+	acc.Require(st.TotalWeight() >= st.NumApprovalsThreshold,
+		"multisig has insufficient signer weight to meet threshold (%d < %d)", st.TotalWeight(), st.NumApprovalsThreshold)
+
+	acc.Require(st.SpendingPeriod >= 0, "negative spending period %d", st.SpendingPeriod)
+	acc.Require(st.SpendingLimit.GreaterThanEqual(big.Zero()), "negative spending limit %v", st.SpendingLimit)
+	acc.Require(st.SpentInPeriod.GreaterThanEqual(big.Zero()), "negative spent in period %v", st.SpentInPeriod)
+	if st.SpendingPeriod == 0 {
+		acc.Require(st.SpendingLimit.IsZero(), "non-zero spending limit %v with zero spending period", st.SpendingLimit)
+		acc.Require(st.SpentInPeriod.IsZero(), "non-zero spent %v with zero spending period", st.SpentInPeriod)
+	} else {
+		acc.Require(st.SpendingPeriodStart%st.SpendingPeriod == 0, "spending period start %d not aligned to period %d",
+			st.SpendingPeriodStart, st.SpendingPeriod)
+		acc.Require(st.SpentInPeriod.LessThanEqual(st.SpendingLimit), "spent %v exceeds spending limit %v",
+			st.SpentInPeriod, st.SpendingLimit)
+	}
 
 	if st.UnlockDuration == 0 { // See https://github.com/filecoin-project/specs-actors/issues/1185
 		acc.Require(st.StartEpoch == 0, "non-zero start epoch %d with zero unlock duration", st.StartEpoch)
@@ -50,6 +69,8 @@ func CheckStateInvariants(st *State, store adt.Store) (*StateSummary, *builtin.M
 				maxTxnID = txnID
 			}
 
+			acc.Require(txn.Expiration >= 0, "transaction %d has negative expiration %d", txnID, txn.Expiration)
+
 			seenApprovals := make(map[address.Address]struct{})
 			for _, approval := range txn.Approved {
 				_, found := signers[approval]
@@ -6,14 +6,16 @@ import (
 	"fmt"
 	"io"
 
+	address "github.com/filecoin-project/go-address"
 	abi "github.com/filecoin-project/go-state-types/abi"
+	paych "github.com/filecoin-project/specs-actors/v2/actors/builtin/paych"
 	cbg "github.com/whyrusleeping/cbor-gen"
 	xerrors "golang.org/x/xerrors"
 )
 
 var _ = xerrors.Errorf
 
-var lengthBufState = []byte{134}
+var lengthBufState = []byte{135}
 
 func (t *State) MarshalCBOR(w io.Writer) error {
 	if t == nil {
@@ -36,6 +38,11 @@ func (t *State) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
+	// t.Watcher (address.Address) (struct)
+	if err := t.Watcher.MarshalCBOR(w); err != nil {
+		return err
+	}
+
 	// t.ToSend (big.Int) (struct)
 	if err := t.ToSend.MarshalCBOR(w); err != nil {
 		return err
@@ -86,7 +93,7 @@ func (t *State) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 6 {
+	if extra != 7 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
@@ -107,6 +114,25 @@ func (t *State) UnmarshalCBOR(r io.Reader) error {
 			return xerrors.Errorf("unmarshaling t.To: %w", err)
 		}
 
+	}
+	// t.Watcher (address.Address) (struct)
+
+	{
+
+		b, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != cbg.CborNull[0] {
+			if err := br.UnreadByte(); err != nil {
+				return err
+			}
+			t.Watcher = new(address.Address)
+			if err := t.Watcher.UnmarshalCBOR(br); err != nil {
+				return xerrors.Errorf("unmarshaling t.Watcher pointer: %w", err)
+			}
+		}
+
 	}
 	// t.ToSend (big.Int) (struct)
 
@@ -252,3 +278,140 @@ func (t *LaneState) UnmarshalCBOR(r io.Reader) error {
 	}
 	return nil
 }
+
+var lengthBufUpdateChannelStateBatchParams = []byte{129}
+
+func (t *UpdateChannelStateBatchParams) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufUpdateChannelStateBatchParams); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.Updates ([]paych.UpdateChannelStateParams) (slice)
+	if len(t.Updates) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.Updates was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, uint64(len(t.Updates))); err != nil {
+		return err
+	}
+	for _, v := range t.Updates {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *UpdateChannelStateBatchParams) UnmarshalCBOR(r io.Reader) error {
+	*t = UpdateChannelStateBatchParams{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Updates ([]paych.UpdateChannelStateParams) (slice)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.Updates: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.Updates = make([]paych.UpdateChannelStateParams, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		var v paych.UpdateChannelStateParams
+		if err := v.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+		t.Updates[i] = v
+	}
+
+	return nil
+}
+
+var lengthBufSetWatcherParams = []byte{129}
+
+func (t *SetWatcherParams) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufSetWatcherParams); err != nil {
+		return err
+	}
+
+	// t.Watcher (address.Address) (struct)
+	if err := t.Watcher.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *SetWatcherParams) UnmarshalCBOR(r io.Reader) error {
+	*t = SetWatcherParams{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Watcher (address.Address) (struct)
+
+	{
+
+		b, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != cbg.CborNull[0] {
+			if err := br.UnreadByte(); err != nil {
+				return err
+			}
+			t.Watcher = new(address.Address)
+			if err := t.Watcher.UnmarshalCBOR(br); err != nil {
+				return xerrors.Errorf("unmarshaling t.Watcher pointer: %w", err)
+			}
+		}
+
+	}
+	return nil
+}
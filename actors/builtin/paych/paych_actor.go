@@ -1,11 +1,8 @@
 package paych
 
 import (
-	"bytes"
-
 	addr "github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
-	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/cbor"
 	"github.com/filecoin-project/go-state-types/exitcode"
 	paych0 "github.com/filecoin-project/specs-actors/actors/builtin/paych"
@@ -30,6 +27,8 @@ func (a Actor) Exports() []interface{} {
 		2:                         a.UpdateChannelState,
 		3:                         a.Settle,
 		4:                         a.Collect,
+		5:                         a.UpdateChannelStateBatch,
+		6:                         a.SetWatcher,
 	}
 }
 
@@ -155,63 +154,65 @@ func (pca Actor) UpdateChannelState(rt runtime.Runtime, params *UpdateChannelSta
 	rt.StateReadonly(&st)
 
 	// both parties must sign voucher: one who submits it, the other explicitly signs it
-	rt.ValidateImmediateCallerIs(st.From, st.To)
-	var signer addr.Address
-	if rt.Caller() == st.From {
-		signer = st.To
-	} else {
-		signer = st.From
-	}
-	sv := params.Sv
+	rt.ValidateImmediateCallerIs(st.voucherSubmitters()...)
+	verifyVoucher(rt, &st, params)
 
-	if sv.Signature == nil {
-		rt.Abortf(exitcode.ErrIllegalArgument, "voucher has no signature")
-	}
+	rt.StateTransaction(&st, func() {
+		lstates, err := adt.AsArray(adt.AsStore(rt), st.LaneStates, LaneStatesAmtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load lanes")
 
-	if st.SettlingAt != 0 && rt.CurrEpoch() >= st.SettlingAt {
-		rt.Abortf(ErrChannelStateUpdateAfterSettled, "no vouchers can be processed after SettlingAt epoch")
-	}
+		redeemVoucher(rt, &st, lstates, &params.Sv)
 
-	if len(params.Secret) > MaxSecretSize {
-		rt.Abortf(exitcode.ErrIllegalArgument, "secret must be at most 256 bytes long")
-	}
+		st.LaneStates, err = lstates.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save lanes")
+	})
+	return nil
+}
 
-	vb, err := sv.SigningBytes()
-	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "failed to serialize signedvoucher")
+type UpdateChannelStateBatchParams struct {
+	Updates []UpdateChannelStateParams
+}
 
-	err = rt.VerifySignature(*sv.Signature, signer, vb)
-	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "voucher signature invalid")
+// Redeems a batch of vouchers, which may be for different lanes, as if by UpdateChannelState for each in turn.
+// The batch fails if any voucher is invalid. The lane states are loaded and saved once for the whole batch.
+func (pca Actor) UpdateChannelStateBatch(rt runtime.Runtime, params *UpdateChannelStateBatchParams) *abi.EmptyValue {
+	var st State
+	rt.StateReadonly(&st)
 
-	pchAddr := rt.Receiver()
-	svpchIDAddr, found := rt.ResolveAddress(sv.ChannelAddr)
-	if !found {
-		rt.Abortf(exitcode.ErrIllegalArgument, "voucher payment channel address %s does not resolve to an ID address", sv.ChannelAddr)
+	// both parties must sign vouchers: one who submits them, the other explicitly signs them
+	rt.ValidateImmediateCallerIs(st.voucherSubmitters()...)
+	if len(params.Updates) == 0 {
+		rt.Abortf(exitcode.ErrIllegalArgument, "no vouchers to redeem")
 	}
-	if pchAddr != svpchIDAddr {
-		rt.Abortf(exitcode.ErrIllegalArgument, "voucher payment channel address %s does not match receiver %s", svpchIDAddr, pchAddr)
+	if len(params.Updates) > UpdateChannelStateBatchMax {
+		rt.Abortf(exitcode.ErrIllegalArgument, "batch of %d vouchers exceeds max %d", len(params.Updates), UpdateChannelStateBatchMax)
 	}
-
-	if rt.CurrEpoch() < sv.TimeLockMin {
-		rt.Abortf(exitcode.ErrIllegalArgument, "cannot use this voucher yet!")
+	for i := range params.Updates {
+		verifyVoucher(rt, &st, &params.Updates[i])
 	}
 
-	if sv.TimeLockMax != 0 && rt.CurrEpoch() > sv.TimeLockMax {
-		rt.Abortf(exitcode.ErrIllegalArgument, "this voucher has expired!")
-	}
-
-	if sv.Amount.Sign() < 0 {
-		rt.Abortf(exitcode.ErrIllegalArgument, "voucher amount must be non-negative, was %v", sv.Amount)
-	}
+	rt.StateTransaction(&st, func() {
+		lstates, err := adt.AsArray(adt.AsStore(rt), st.LaneStates, LaneStatesAmtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load lanes")
 
-	if len(sv.SecretPreimage) > 0 {
-		hashedSecret := rt.HashBlake2b(params.Secret)
-		if !bytes.Equal(hashedSecret[:], sv.SecretPreimage) {
-			rt.Abortf(exitcode.ErrIllegalArgument, "incorrect secret!")
+		for i := range params.Updates {
+			redeemVoucher(rt, &st, lstates, &params.Updates[i].Sv)
 		}
-	}
 
-	if sv.Extra != nil {
+		st.LaneStates, err = lstates.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save lanes")
+	})
+	return nil
+}
 
+// Checks a voucher's signature and the conditions for its redemption which do not depend on lane states,
+// then invokes its verification method, if any.
+func verifyVoucher(rt runtime.Runtime, st *State, params *UpdateChannelStateParams) {
+	err := checkVoucher(st, runtimeVoucherEnv(rt), st.voucherSigner(rt.Caller()), params)
+	builtin.RequireNoErr(rt, err, exitcode.Unwrap(err, exitcode.ErrIllegalState), "invalid voucher")
+
+	sv := params.Sv
+	if sv.Extra != nil {
 		code := rt.Send(
 			sv.Extra.Actor,
 			sv.Extra.Method,
@@ -221,91 +222,50 @@ func (pca Actor) UpdateChannelState(rt runtime.Runtime, params *UpdateChannelSta
 		)
 		builtin.RequireSuccess(rt, code, "spend voucher verification failed")
 	}
+}
 
-	rt.StateTransaction(&st, func() {
-		laneFound := true
-
-		lstates, err := adt.AsArray(adt.AsStore(rt), st.LaneStates, LaneStatesAmtBitwidth)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load lanes")
-
-		// Find the voucher lane, creating if necessary.
-		laneId := sv.Lane
-		laneState := findLane(rt, lstates, sv.Lane)
-
-		if laneState == nil {
-			laneState = &LaneState{
-				Redeemed: big.Zero(),
-				Nonce:    0,
-			}
-			laneFound = false
-		}
-
-		if laneFound {
-			if laneState.Nonce >= sv.Nonce {
-				rt.Abortf(exitcode.ErrIllegalArgument, "voucher has an outdated nonce, existing nonce: %d, voucher nonce: %d, cannot redeem",
-					laneState.Nonce, sv.Nonce)
-			}
-		}
-
-		// The next section actually calculates the payment amounts to update the payment channel state
-		// 1. (optional) sum already redeemed value of all merging lanes
-		redeemedFromOthers := big.Zero()
-		for _, merge := range sv.Merges {
-			if merge.Lane == sv.Lane {
-				rt.Abortf(exitcode.ErrIllegalArgument, "voucher cannot merge lanes into its own lane")
-			}
-
-			otherls := findLane(rt, lstates, merge.Lane)
-			if otherls == nil {
-				rt.Abortf(exitcode.ErrIllegalArgument, "voucher specifies invalid merge lane %v", merge.Lane)
-				return // makes linters happy
-			}
-
-			if otherls.Nonce >= merge.Nonce {
-				rt.Abortf(exitcode.ErrIllegalArgument, "merged lane in voucher has outdated nonce, cannot redeem")
-			}
-
-			redeemedFromOthers = big.Add(redeemedFromOthers, otherls.Redeemed)
-			otherls.Nonce = merge.Nonce
-			err = lstates.Set(merge.Lane, otherls)
-			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to store lane %d", merge.Lane)
-		}
-
-		// 2. To prevent double counting, remove already redeemed amounts (from
-		// voucher or other lanes) from the voucher amount
-		laneState.Nonce = sv.Nonce
-		balanceDelta := big.Sub(sv.Amount, big.Add(redeemedFromOthers, laneState.Redeemed))
-		// 3. set new redeemed value for merged-into lane
-		laneState.Redeemed = sv.Amount
+// Redeems a verified voucher, updating its lane, the lanes it merges and the amount to send.
+func redeemVoucher(rt runtime.Runtime, st *State, lstates *adt.Array, sv *SignedVoucher) {
+	err := applyVoucher(st, lstates, rt.CurrentBalance(), sv)
+	builtin.RequireNoErr(rt, err, exitcode.Unwrap(err, exitcode.ErrIllegalState), "failed to redeem voucher")
+}
 
-		newSendBalance := big.Add(st.ToSend, balanceDelta)
+// The context in which the runtime redeems vouchers.
+func runtimeVoucherEnv(rt runtime.Runtime) *VoucherEnv {
+	return &VoucherEnv{
+		Channel:         rt.Receiver(),
+		Epoch:           rt.CurrEpoch(),
+		Balance:         rt.CurrentBalance(),
+		ResolveAddress:  rt.ResolveAddress,
+		VerifySignature: rt.VerifySignature,
+		HashBlake2b:     rt.HashBlake2b,
+	}
+}
 
-		// 4. check operation validity
-		if newSendBalance.LessThan(big.Zero()) {
-			rt.Abortf(exitcode.ErrIllegalArgument, "voucher would leave channel balance negative")
-		}
-		if newSendBalance.GreaterThan(rt.CurrentBalance()) {
-			rt.Abortf(exitcode.ErrIllegalArgument, "not enough funds in channel to cover voucher")
-		}
+type SetWatcherParams struct {
+	// The watcher, or nil to remove the watcher.
+	Watcher *addr.Address
+}
 
-		// 5. add new redemption ToSend
-		st.ToSend = newSendBalance
-
-		// update channel settlingAt and MinSettleHeight if delayed by voucher
-		if sv.MinSettleHeight != 0 {
-			if st.SettlingAt != 0 && st.SettlingAt < sv.MinSettleHeight {
-				st.SettlingAt = sv.MinSettleHeight
-			}
-			if st.MinSettleHeight < sv.MinSettleHeight {
-				st.MinSettleHeight = sv.MinSettleHeight
-			}
+// Registers or removes the watcher, which may submit vouchers on behalf of the recipient while it is offline.
+// Only the recipient may set the watcher, since the watcher acts in its interest.
+func (pca Actor) SetWatcher(rt runtime.Runtime, params *SetWatcherParams) *abi.EmptyValue {
+	var st State
+	rt.StateReadonly(&st)
+	rt.ValidateImmediateCallerIs(st.To)
+
+	var watcher *addr.Address
+	if params.Watcher != nil {
+		resolved, err := builtin.ResolveToIDAddr(rt, *params.Watcher)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to resolve watcher address %v", *params.Watcher)
+		if resolved == st.From || resolved == st.To {
+			rt.Abortf(exitcode.ErrIllegalArgument, "watcher %v must not be a channel party", resolved)
 		}
+		watcher = &resolved
+	}
 
-		err = lstates.Set(laneId, laneState)
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to store lane", laneId)
-
-		st.LaneStates, err = lstates.Root()
-		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to save lanes")
+	rt.StateTransaction(&st, func() {
+		st.Watcher = watcher
 	})
 	return nil
 }
@@ -351,20 +311,3 @@ func (pca Actor) Collect(rt runtime.Runtime, _ *abi.EmptyValue) *abi.EmptyValue
 
 	return nil
 }
-
-// Returns the insertion index for a lane ID, with the matching lane state if found, or nil.
-func findLane(rt runtime.Runtime, ls *adt.Array, id uint64) *LaneState {
-	if id > MaxLane {
-		rt.Abortf(exitcode.ErrIllegalArgument, "maximum lane ID is 2^63-1")
-	}
-
-	var out LaneState
-	found, err := ls.Get(id, &out)
-	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load lane %d", id)
-
-	if !found {
-		return nil
-	}
-
-	return &out
-}
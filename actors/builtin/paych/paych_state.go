@@ -15,6 +15,8 @@ type State struct {
 	From addr.Address
 	// Recipient of payouts from channel
 	To addr.Address
+	// (optional) Address permitted to submit vouchers signed by `From` on behalf of `To`, but not to settle or collect
+	Watcher *addr.Address
 
 	// Amount successfully redeemed through the payment channel, paid out on `Collect()`
 	ToSend abi.TokenAmount
@@ -47,3 +49,20 @@ func ConstructState(from addr.Address, to addr.Address, emptyArrCid cid.Cid) *St
 		LaneStates:      emptyArrCid,
 	}
 }
+
+// Returns the addresses permitted to submit vouchers: the channel parties and the watcher, if any.
+func (st *State) voucherSubmitters() []addr.Address {
+	if st.Watcher == nil {
+		return []addr.Address{st.From, st.To}
+	}
+	return []addr.Address{st.From, st.To, *st.Watcher}
+}
+
+// Returns the party who must have signed the vouchers submitted by an address.
+// Vouchers submitted by the watcher must be signed by `From`, as if submitted by `To`.
+func (st *State) voucherSigner(submitter addr.Address) addr.Address {
+	if submitter == st.From {
+		return st.To
+	}
+	return st.From
+}
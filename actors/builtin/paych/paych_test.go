@@ -704,6 +704,218 @@ func TestActor_UpdateChannelStateSecretPreimage(t *testing.T) {
 	})
 }
 
+func TestActor_UpdateChannelStateBatch(t *testing.T) {
+	voucher := func(sv *SignedVoucher, lane, nonce uint64, amount int64, merges ...Merge) UpdateChannelStateParams {
+		v := *sv
+		v.Lane = lane
+		v.Nonce = nonce
+		v.Amount = big.NewInt(amount)
+		v.Merges = merges
+		return UpdateChannelStateParams{Sv: v}
+	}
+
+	t.Run("redeems vouchers for several lanes", func(t *testing.T) {
+		rt, actor, sv := requireCreateChannelWithLanes(t, 3)
+		var st1 State
+		rt.GetState(&st1)
+
+		// Lane i has redeemed i+1 at nonce i+1.
+		updates := []UpdateChannelStateParams{
+			voucher(sv, 0, 2, 5),
+			voucher(sv, 2, 4, 7),
+			voucher(sv, 3, 1, 1),
+		}
+		rt.SetCaller(actor.payee, builtin.AccountActorCodeID)
+		actor.updateChannelStateBatch(rt, updates...)
+
+		expState := st1
+		expState.ToSend = big.NewInt(6 + 4 + 4 + 1)
+		expState.LaneStates = constructLaneStateAMT(t, rt, []*LaneState{
+			{Redeemed: big.NewInt(5), Nonce: 2},
+			{Redeemed: big.NewInt(2), Nonce: 2},
+			{Redeemed: big.NewInt(7), Nonce: 4},
+			{Redeemed: big.NewInt(1), Nonce: 1},
+		})
+		verifyState(t, rt, 4, expState)
+		actor.checkState(rt)
+	})
+
+	t.Run("vouchers see lanes redeemed earlier in the batch", func(t *testing.T) {
+		rt, actor, sv := requireCreateChannelWithLanes(t, 2)
+		var st1 State
+		rt.GetState(&st1)
+
+		updates := []UpdateChannelStateParams{
+			voucher(sv, 1, 3, 4),
+			voucher(sv, 0, 5, 10, Merge{Lane: 1, Nonce: 4}),
+		}
+		rt.SetCaller(actor.payer, builtin.AccountActorCodeID)
+		actor.updateChannelStateBatch(rt, updates...)
+
+		// 3 redeemed before, plus 2 more on lane 1, plus 10 on lane 0 less the 4 redeemed by lane 1 and 1 by lane 0.
+		expState := st1
+		expState.ToSend = big.NewInt(3 + 2 + 5)
+		expState.LaneStates = constructLaneStateAMT(t, rt, []*LaneState{
+			{Redeemed: big.NewInt(10), Nonce: 5},
+			{Redeemed: big.NewInt(4), Nonce: 4},
+		})
+		verifyState(t, rt, 2, expState)
+		actor.checkState(rt)
+	})
+
+	t.Run("fails if a voucher reuses a nonce from earlier in the batch", func(t *testing.T) {
+		rt, actor, sv := requireCreateChannelWithLanes(t, 1)
+		var st1 State
+		rt.GetState(&st1)
+
+		updates := []UpdateChannelStateParams{voucher(sv, 0, 2, 5), voucher(sv, 0, 2, 6)}
+		rt.SetCaller(actor.payee, builtin.AccountActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "outdated nonce", func() {
+			actor.updateChannelStateBatch(rt, updates...)
+		})
+		verifyState(t, rt, 1, st1)
+		actor.checkState(rt)
+	})
+
+	t.Run("fails if any voucher signature is invalid", func(t *testing.T) {
+		rt, actor, sv := requireCreateChannelWithLanes(t, 1)
+		var st1 State
+		rt.GetState(&st1)
+
+		updates := []UpdateChannelStateParams{voucher(sv, 0, 2, 5), voucher(sv, 1, 1, 6)}
+		rt.SetCaller(actor.payee, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(st1.From, st1.To)
+		rt.ExpectVerifySignature(*updates[0].Sv.Signature, actor.payer, voucherBytes(t, &updates[0].Sv), nil)
+		rt.ExpectVerifySignature(*updates[1].Sv.Signature, actor.payer, voucherBytes(t, &updates[1].Sv), fmt.Errorf("bad signature"))
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "voucher signature invalid", func() {
+			rt.Call(actor.UpdateChannelStateBatch, &UpdateChannelStateBatchParams{Updates: updates})
+		})
+		rt.Verify()
+		verifyState(t, rt, 1, st1)
+	})
+
+	t.Run("fails with no vouchers or too many vouchers", func(t *testing.T) {
+		rt, actor, sv := requireCreateChannelWithLanes(t, 1)
+		var st1 State
+		rt.GetState(&st1)
+		rt.SetCaller(actor.payee, builtin.AccountActorCodeID)
+
+		rt.ExpectValidateCallerAddr(st1.From, st1.To)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "no vouchers", func() {
+			rt.Call(actor.UpdateChannelStateBatch, &UpdateChannelStateBatchParams{})
+		})
+		rt.Verify()
+
+		updates := make([]UpdateChannelStateParams, UpdateChannelStateBatchMax+1)
+		for i := range updates {
+			updates[i] = voucher(sv, uint64(i+1), 1, 1)
+		}
+		rt.ExpectValidateCallerAddr(st1.From, st1.To)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "exceeds max", func() {
+			rt.Call(actor.UpdateChannelStateBatch, &UpdateChannelStateBatchParams{Updates: updates})
+		})
+		rt.Verify()
+	})
+}
+
+func TestActor_SetWatcher(t *testing.T) {
+	watcher := tutil.NewIDAddr(t, 104)
+
+	t.Run("watcher redeems vouchers signed by the payer", func(t *testing.T) {
+		rt, actor, sv := requireCreateChannelWithLanes(t, 1)
+		rt.SetCaller(actor.payee, builtin.AccountActorCodeID)
+		actor.setWatcher(rt, &watcher)
+
+		var st State
+		rt.GetState(&st)
+		require.NotNil(t, st.Watcher)
+		assert.Equal(t, watcher, *st.Watcher)
+
+		ucp := &UpdateChannelStateParams{Sv: *sv}
+		ucp.Sv.Amount = big.NewInt(9)
+		rt.SetCaller(watcher, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(st.From, st.To, watcher)
+		rt.ExpectVerifySignature(*ucp.Sv.Signature, actor.payer, voucherBytes(t, &ucp.Sv), nil)
+		ret := rt.Call(actor.UpdateChannelState, ucp)
+		require.Nil(t, ret)
+		rt.Verify()
+
+		rt.GetState(&st)
+		assert.Equal(t, big.NewInt(9), st.ToSend)
+
+		// Vouchers may also be redeemed in a batch.
+		second := *sv
+		second.Lane = 1
+		second.Amount = big.NewInt(1)
+		actor.updateChannelStateBatch(rt, UpdateChannelStateParams{Sv: second})
+		rt.GetState(&st)
+		assert.Equal(t, big.NewInt(10), st.ToSend)
+		actor.checkState(rt)
+	})
+
+	t.Run("watcher cannot settle or collect", func(t *testing.T) {
+		rt, actor, _ := requireCreateChannelWithLanes(t, 1)
+		rt.SetCaller(actor.payee, builtin.AccountActorCodeID)
+		actor.setWatcher(rt, &watcher)
+
+		rt.SetCaller(watcher, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(actor.payer, actor.payee)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.Settle, nil)
+		})
+		rt.Verify()
+
+		rt.ExpectValidateCallerAddr(actor.payer, actor.payee)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.Collect, nil)
+		})
+		rt.Verify()
+		actor.checkState(rt)
+	})
+
+	t.Run("removed watcher cannot redeem vouchers", func(t *testing.T) {
+		rt, actor, sv := requireCreateChannelWithLanes(t, 1)
+		rt.SetCaller(actor.payee, builtin.AccountActorCodeID)
+		actor.setWatcher(rt, &watcher)
+		actor.setWatcher(rt, nil)
+
+		var st State
+		rt.GetState(&st)
+		assert.Nil(t, st.Watcher)
+
+		rt.SetCaller(watcher, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(actor.payer, actor.payee)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.UpdateChannelState, &UpdateChannelStateParams{Sv: *sv})
+		})
+		rt.Verify()
+		actor.checkState(rt)
+	})
+
+	t.Run("only the recipient can set the watcher", func(t *testing.T) {
+		rt, actor, _ := requireCreateChannelWithLanes(t, 1)
+		rt.SetCaller(actor.payer, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(actor.payee)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.SetWatcher, &SetWatcherParams{Watcher: &watcher})
+		})
+		rt.Verify()
+	})
+
+	t.Run("fails if the watcher is a channel party", func(t *testing.T) {
+		rt, actor, _ := requireCreateChannelWithLanes(t, 1)
+		rt.SetCaller(actor.payee, builtin.AccountActorCodeID)
+		for _, party := range []addr.Address{actor.payer, actor.payee} {
+			party := party
+			rt.ExpectValidateCallerAddr(actor.payee)
+			rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "must not be a channel party", func() {
+				rt.Call(actor.SetWatcher, &SetWatcherParams{Watcher: &party})
+			})
+			rt.Verify()
+		}
+	})
+}
+
 func TestActor_Settle(t *testing.T) {
 	ep := abi.ChainEpoch(10)
 
@@ -951,6 +1163,34 @@ func (h *pcActorHarness) constructAndVerify(t *testing.T, rt *mock.Runtime, send
 	verifyInitialState(t, rt, senderId, receiverId)
 }
 
+func (h *pcActorHarness) setWatcher(rt *mock.Runtime, watcher *addr.Address) {
+	rt.ExpectValidateCallerAddr(h.payee)
+	ret := rt.Call(h.Actor.SetWatcher, &SetWatcherParams{Watcher: watcher})
+	require.Nil(h.t, ret)
+	rt.Verify()
+}
+
+// Redeems a batch of vouchers submitted by the current caller, expecting them to be signed by the other party.
+func (h *pcActorHarness) updateChannelStateBatch(rt *mock.Runtime, updates ...UpdateChannelStateParams) {
+	var st State
+	rt.GetState(&st)
+	signer := h.payer
+	if rt.Caller() == h.payer {
+		signer = h.payee
+	}
+	submitters := []addr.Address{st.From, st.To}
+	if st.Watcher != nil {
+		submitters = append(submitters, *st.Watcher)
+	}
+	rt.ExpectValidateCallerAddr(submitters...)
+	for i := range updates {
+		rt.ExpectVerifySignature(*updates[i].Sv.Signature, signer, voucherBytes(h.t, &updates[i].Sv), nil)
+	}
+	ret := rt.Call(h.Actor.UpdateChannelStateBatch, &UpdateChannelStateBatchParams{Updates: updates})
+	require.Nil(h.t, ret)
+	rt.Verify()
+}
+
 func (h *pcActorHarness) checkState(rt *mock.Runtime) {
 	var st State
 	rt.GetState(&st)
@@ -985,7 +1225,7 @@ func verifyState(t *testing.T, rt *mock.Runtime, expLanes int, expectedState Sta
 	}
 }
 
-func voucherBytes(t *testing.T, sv *SignedVoucher) []byte {
+func voucherBytes(t testing.TB, sv *SignedVoucher) []byte {
 	bytes, err := sv.SigningBytes()
 	require.NoError(t, err)
 	return bytes
@@ -16,3 +16,6 @@ func SettleDelay() abi.ChainEpoch {
 
 // Maximum size of a secret that can be submitted with a payment channel update (in bytes).
 const MaxSecretSize = 256
+
+// Maximum number of vouchers that can be redeemed in one UpdateChannelStateBatch.
+const UpdateChannelStateBatchMax = 32
@@ -0,0 +1,76 @@
+package paych
+
+import (
+	addr "github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// Read-only queries of a payment channel's state, for clients which track the lanes they redeem vouchers on.
+// Vouchers are validated against the lanes at the head the reader was created from, so a voucher accepted by one
+// reader may be rejected on chain if another voucher on its lane is redeemed first.
+type StateReader struct {
+	store adt.Store
+	st    State
+}
+
+// The state of a lane, with its ID.
+type Lane struct {
+	ID    uint64
+	State LaneState
+}
+
+// Loads a payment channel's state from its head.
+func NewStateReader(store adt.Store, head cid.Cid) (*StateReader, error) {
+	r := &StateReader{store: store}
+	if err := store.Get(store.Context(), head, &r.st); err != nil {
+		return nil, xerrors.Errorf("failed to load payment channel state %v: %w", head, err)
+	}
+	return r, nil
+}
+
+// The state loaded from the head, against which vouchers are validated. It must not be modified.
+func (r *StateReader) State() *State {
+	return &r.st
+}
+
+// Returns the states of all lanes, in increasing order of lane ID.
+func (r *StateReader) Lanes() ([]Lane, error) {
+	lanes, err := adt.AsArray(r.store, r.st.LaneStates, LaneStatesAmtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load lanes: %w", err)
+	}
+	all := []Lane{}
+	var ls LaneState
+	if err := lanes.ForEach(&ls, func(i int64) error {
+		all = append(all, Lane{ID: uint64(i), State: ls})
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to iterate lanes: %w", err)
+	}
+	return all, nil
+}
+
+// Returns the state of a lane, if it exists.
+func (r *StateReader) Lane(id uint64) (*LaneState, bool, error) {
+	lanes, err := adt.AsArray(r.store, r.st.LaneStates, LaneStatesAmtBitwidth)
+	if err != nil {
+		return nil, false, xerrors.Errorf("failed to load lanes: %w", err)
+	}
+	var ls LaneState
+	found, err := lanes.Get(id, &ls)
+	if err != nil {
+		return nil, false, xerrors.Errorf("failed to load lane %d: %w", id, err)
+	}
+	if !found {
+		return nil, false, nil
+	}
+	return &ls, true, nil
+}
+
+// Validates a voucher submitted by an address against the loaded state, as ValidateVoucher.
+func (r *StateReader) ValidateVoucher(env *VoucherEnv, submitter addr.Address, params *UpdateChannelStateParams) (*VoucherRedemption, error) {
+	return ValidateVoucher(r.store, &r.st, env, submitter, params)
+}
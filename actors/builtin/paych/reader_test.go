@@ -0,0 +1,41 @@
+package paych_test
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/paych"
+)
+
+func TestStateReader(t *testing.T) {
+	rt, _, _ := requireCreateChannelWithLanes(t, 3)
+
+	r, err := paych.NewStateReader(rt.AdtStore(), rt.StateRoot())
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(6), r.State().ToSend)
+
+	t.Run("lanes", func(t *testing.T) {
+		lanes, err := r.Lanes()
+		require.NoError(t, err)
+		require.Len(t, lanes, 3)
+		for i, lane := range lanes {
+			assert.Equal(t, uint64(i), lane.ID)
+			assert.Equal(t, big.NewInt(int64(i+1)), lane.State.Redeemed)
+			assert.Equal(t, uint64(i+1), lane.State.Nonce)
+		}
+	})
+
+	t.Run("lane", func(t *testing.T) {
+		lane, found, err := r.Lane(1)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, paych.LaneState{Redeemed: big.NewInt(2), Nonce: 2}, *lane)
+
+		_, found, err = r.Lane(3)
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+}
@@ -21,6 +21,10 @@ func CheckStateInvariants(st *State, store adt.Store, balance abi.TokenAmount) (
 
 	acc.Require(st.From.Protocol() == address.ID, "from address is not ID address %v", st.From)
 	acc.Require(st.To.Protocol() == address.ID, "to address is not ID address %v", st.To)
+	if st.Watcher != nil {
+		acc.Require(st.Watcher.Protocol() == address.ID, "watcher address is not ID address %v", *st.Watcher)
+		acc.Require(*st.Watcher != st.From && *st.Watcher != st.To, "watcher %v is a channel party", *st.Watcher)
+	}
 	acc.Require(st.SettlingAt >= st.MinSettleHeight,
 		"channel is setting at epoch %d before min settle height %d", st.SettlingAt, st.MinSettleHeight)
 
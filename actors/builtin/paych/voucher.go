@@ -0,0 +1,230 @@
+package paych
+
+import (
+	"bytes"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// The context in which a voucher is redeemed, which the actor takes from its runtime.
+// Clients validating vouchers off-chain provide it from their view of the chain.
+type VoucherEnv struct {
+	// The channel's ID address.
+	Channel addr.Address
+	// The epoch at which the voucher is redeemed.
+	Epoch abi.ChainEpoch
+	// The channel's balance.
+	Balance abi.TokenAmount
+	// Resolves an address to an ID address, returning false if it has none.
+	ResolveAddress func(addr.Address) (addr.Address, bool)
+	// Verifies a signature over a plaintext by the account at an ID address.
+	// Off-chain, this must resolve the account's key address to verify the signature with.
+	VerifySignature func(sig crypto.Signature, signer addr.Address, plaintext []byte) error
+	// Computes the BLAKE2b-256 digest of a voucher's secret.
+	HashBlake2b func(data []byte) [32]byte
+}
+
+// The effect on a channel of redeeming a voucher.
+type VoucherRedemption struct {
+	// The amount by which the voucher increases the amount to send to `To`.
+	Delta abi.TokenAmount
+	// The channel's ToSend, SettlingAt and MinSettleHeight after redemption.
+	ToSend          abi.TokenAmount
+	SettlingAt      abi.ChainEpoch
+	MinSettleHeight abi.ChainEpoch
+}
+
+// Validates a voucher submitted to a channel by `submitter`, by the rules which UpdateChannelState applies on chain,
+// and returns the effect of redeeming it. Neither the state nor the store is modified.
+// The voucher's Extra verification method, if any, is not invoked, since it requires sending a message;
+// the voucher may yet be rejected by that method when it is redeemed.
+// Errors which invalidate the voucher carry the exit code with which UpdateChannelState would abort.
+func ValidateVoucher(store adt.Store, st *State, env *VoucherEnv, submitter addr.Address, params *UpdateChannelStateParams) (*VoucherRedemption, error) {
+	permitted := false
+	for _, a := range st.voucherSubmitters() {
+		permitted = permitted || a == submitter
+	}
+	if !permitted {
+		return nil, exitcode.SysErrForbidden.Wrapf("%v is not permitted to submit vouchers", submitter)
+	}
+	if err := checkVoucher(st, env, st.voucherSigner(submitter), params); err != nil {
+		return nil, err
+	}
+
+	lstates, err := adt.AsArray(store, st.LaneStates, LaneStatesAmtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load lanes: %w", err)
+	}
+	// The lanes are modified in memory only, and never flushed to the store.
+	redeemed := *st
+	if err := applyVoucher(&redeemed, lstates, env.Balance, &params.Sv); err != nil {
+		return nil, err
+	}
+	return &VoucherRedemption{
+		Delta:           big.Sub(redeemed.ToSend, st.ToSend),
+		ToSend:          redeemed.ToSend,
+		SettlingAt:      redeemed.SettlingAt,
+		MinSettleHeight: redeemed.MinSettleHeight,
+	}, nil
+}
+
+// Checks a voucher's signature and the conditions for its redemption which do not depend on lane states.
+func checkVoucher(st *State, env *VoucherEnv, signer addr.Address, params *UpdateChannelStateParams) error {
+	sv := params.Sv
+
+	if sv.Signature == nil {
+		return exitcode.ErrIllegalArgument.Wrapf("voucher has no signature")
+	}
+
+	if st.SettlingAt != 0 && env.Epoch >= st.SettlingAt {
+		return ErrChannelStateUpdateAfterSettled.Wrapf("no vouchers can be processed after SettlingAt epoch")
+	}
+
+	if len(params.Secret) > MaxSecretSize {
+		return exitcode.ErrIllegalArgument.Wrapf("secret must be at most 256 bytes long")
+	}
+
+	vb, err := sv.SigningBytes()
+	if err != nil {
+		return exitcode.ErrIllegalArgument.Wrapf("failed to serialize signedvoucher: %w", err)
+	}
+
+	if err := env.VerifySignature(*sv.Signature, signer, vb); err != nil {
+		return exitcode.ErrIllegalArgument.Wrapf("voucher signature invalid: %w", err)
+	}
+
+	svpchIDAddr, found := env.ResolveAddress(sv.ChannelAddr)
+	if !found {
+		return exitcode.ErrIllegalArgument.Wrapf("voucher payment channel address %s does not resolve to an ID address", sv.ChannelAddr)
+	}
+	if env.Channel != svpchIDAddr {
+		return exitcode.ErrIllegalArgument.Wrapf("voucher payment channel address %s does not match receiver %s", svpchIDAddr, env.Channel)
+	}
+
+	if env.Epoch < sv.TimeLockMin {
+		return exitcode.ErrIllegalArgument.Wrapf("cannot use this voucher yet!")
+	}
+
+	if sv.TimeLockMax != 0 && env.Epoch > sv.TimeLockMax {
+		return exitcode.ErrIllegalArgument.Wrapf("this voucher has expired!")
+	}
+
+	if sv.Amount.Sign() < 0 {
+		return exitcode.ErrIllegalArgument.Wrapf("voucher amount must be non-negative, was %v", sv.Amount)
+	}
+
+	if len(sv.SecretPreimage) > 0 {
+		hashedSecret := env.HashBlake2b(params.Secret)
+		if !bytes.Equal(hashedSecret[:], sv.SecretPreimage) {
+			return exitcode.ErrIllegalArgument.Wrapf("incorrect secret!")
+		}
+	}
+	return nil
+}
+
+// Redeems a checked voucher, updating its lane, the lanes it merges and the channel's amount to send.
+func applyVoucher(st *State, lstates *adt.Array, balance abi.TokenAmount, sv *SignedVoucher) error {
+	// Find the voucher lane, creating if necessary.
+	laneState, err := findLane(lstates, sv.Lane)
+	if err != nil {
+		return err
+	}
+
+	if laneState == nil {
+		laneState = &LaneState{
+			Redeemed: big.Zero(),
+			Nonce:    0,
+		}
+	} else if laneState.Nonce >= sv.Nonce {
+		return exitcode.ErrIllegalArgument.Wrapf("voucher has an outdated nonce, existing nonce: %d, voucher nonce: %d, cannot redeem",
+			laneState.Nonce, sv.Nonce)
+	}
+
+	// The next section actually calculates the payment amounts to update the payment channel state
+	// 1. (optional) sum already redeemed value of all merging lanes
+	redeemedFromOthers := big.Zero()
+	for _, merge := range sv.Merges {
+		if merge.Lane == sv.Lane {
+			return exitcode.ErrIllegalArgument.Wrapf("voucher cannot merge lanes into its own lane")
+		}
+
+		otherls, err := findLane(lstates, merge.Lane)
+		if err != nil {
+			return err
+		}
+		if otherls == nil {
+			return exitcode.ErrIllegalArgument.Wrapf("voucher specifies invalid merge lane %v", merge.Lane)
+		}
+
+		if otherls.Nonce >= merge.Nonce {
+			return exitcode.ErrIllegalArgument.Wrapf("merged lane in voucher has outdated nonce, cannot redeem")
+		}
+
+		redeemedFromOthers = big.Add(redeemedFromOthers, otherls.Redeemed)
+		otherls.Nonce = merge.Nonce
+		if err := lstates.Set(merge.Lane, otherls); err != nil {
+			return xerrors.Errorf("failed to store lane %d: %w", merge.Lane, err)
+		}
+	}
+
+	// 2. To prevent double counting, remove already redeemed amounts (from
+	// voucher or other lanes) from the voucher amount
+	laneState.Nonce = sv.Nonce
+	balanceDelta := big.Sub(sv.Amount, big.Add(redeemedFromOthers, laneState.Redeemed))
+	// 3. set new redeemed value for merged-into lane
+	laneState.Redeemed = sv.Amount
+
+	newSendBalance := big.Add(st.ToSend, balanceDelta)
+
+	// 4. check operation validity
+	if newSendBalance.LessThan(big.Zero()) {
+		return exitcode.ErrIllegalArgument.Wrapf("voucher would leave channel balance negative")
+	}
+	if newSendBalance.GreaterThan(balance) {
+		return exitcode.ErrIllegalArgument.Wrapf("not enough funds in channel to cover voucher")
+	}
+
+	// 5. add new redemption ToSend
+	st.ToSend = newSendBalance
+
+	// update channel settlingAt and MinSettleHeight if delayed by voucher
+	if sv.MinSettleHeight != 0 {
+		if st.SettlingAt != 0 && st.SettlingAt < sv.MinSettleHeight {
+			st.SettlingAt = sv.MinSettleHeight
+		}
+		if st.MinSettleHeight < sv.MinSettleHeight {
+			st.MinSettleHeight = sv.MinSettleHeight
+		}
+	}
+
+	if err := lstates.Set(sv.Lane, laneState); err != nil {
+		return xerrors.Errorf("failed to store lane %d: %w", sv.Lane, err)
+	}
+	return nil
+}
+
+// Returns the state of a lane, or nil if it does not exist.
+func findLane(ls *adt.Array, id uint64) (*LaneState, error) {
+	if id > MaxLane {
+		return nil, exitcode.ErrIllegalArgument.Wrapf("maximum lane ID is 2^63-1")
+	}
+
+	var out LaneState
+	found, err := ls.Get(id, &out)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load lane %d: %w", id, err)
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	return &out, nil
+}
@@ -0,0 +1,122 @@
+package paych_test
+
+import (
+	"fmt"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	. "github.com/filecoin-project/specs-actors/v7/actors/builtin/paych"
+)
+
+func TestValidateVoucher(t *testing.T) {
+	// Lane i has redeemed i+1 at nonce i+1, for a total of 3 to send.
+	rt, actor, sv := requireCreateChannelWithLanes(t, 2)
+	r, err := NewStateReader(rt.AdtStore(), rt.StateRoot())
+	require.NoError(t, err)
+
+	env := &VoucherEnv{
+		Channel: actor.addr,
+		Epoch:   rt.Epoch(),
+		Balance: rt.Balance(),
+		ResolveAddress: func(a addr.Address) (addr.Address, bool) {
+			return a, a.Protocol() == addr.ID
+		},
+		VerifySignature: func(sig crypto.Signature, signer addr.Address, plaintext []byte) error {
+			if signer != actor.payer {
+				return fmt.Errorf("not signed by %v", signer)
+			}
+			return nil
+		},
+		HashBlake2b: func(data []byte) [32]byte { return [32]byte{} },
+	}
+	voucher := func(lane, nonce uint64, amount int64, merges ...Merge) *UpdateChannelStateParams {
+		v := *sv
+		v.Lane = lane
+		v.Nonce = nonce
+		v.Amount = big.NewInt(amount)
+		v.Merges = merges
+		return &UpdateChannelStateParams{Sv: v}
+	}
+	requireInvalid := func(t *testing.T, code exitcode.ExitCode, msg string, submitter addr.Address, params *UpdateChannelStateParams) {
+		_, err := r.ValidateVoucher(env, submitter, params)
+		require.Error(t, err)
+		assert.Equal(t, code, exitcode.Unwrap(err, exitcode.Ok))
+		assert.Contains(t, err.Error(), msg)
+	}
+
+	t.Run("valid voucher", func(t *testing.T) {
+		redemption, err := r.ValidateVoucher(env, actor.payee, voucher(0, 2, 5))
+		require.NoError(t, err)
+		assert.Equal(t, big.NewInt(4), redemption.Delta)
+		assert.Equal(t, big.NewInt(7), redemption.ToSend)
+	})
+
+	t.Run("voucher merging lanes", func(t *testing.T) {
+		redemption, err := r.ValidateVoucher(env, actor.payee, voucher(0, 5, 10, Merge{Lane: 1, Nonce: 3}))
+		require.NoError(t, err)
+		assert.Equal(t, big.NewInt(7), redemption.Delta)
+		assert.Equal(t, big.NewInt(10), redemption.ToSend)
+
+		requireInvalid(t, exitcode.ErrIllegalArgument, "merged lane in voucher has outdated nonce", actor.payee,
+			voucher(0, 5, 10, Merge{Lane: 1, Nonce: 2}))
+		requireInvalid(t, exitcode.ErrIllegalArgument, "invalid merge lane", actor.payee,
+			voucher(0, 5, 10, Merge{Lane: 7, Nonce: 2}))
+	})
+
+	t.Run("voucher extending min settle height", func(t *testing.T) {
+		params := voucher(2, 1, 1)
+		params.Sv.MinSettleHeight = 50
+		redemption, err := r.ValidateVoucher(env, actor.payee, params)
+		require.NoError(t, err)
+		assert.Equal(t, abi.ChainEpoch(50), redemption.MinSettleHeight)
+		assert.Equal(t, abi.ChainEpoch(0), redemption.SettlingAt)
+	})
+
+	t.Run("invalid vouchers", func(t *testing.T) {
+		requireInvalid(t, exitcode.ErrIllegalArgument, "outdated nonce", actor.payee, voucher(1, 2, 5))
+		requireInvalid(t, exitcode.ErrIllegalArgument, "not enough funds", actor.payee, voucher(2, 1, 1_000_000))
+		// A voucher submitted by the payer must be signed by the payee.
+		requireInvalid(t, exitcode.ErrIllegalArgument, "voucher signature invalid", actor.payer, voucher(0, 2, 5))
+		requireInvalid(t, exitcode.SysErrForbidden, "not permitted to submit vouchers", actor.addr, voucher(0, 2, 5))
+
+		settled := *r.State()
+		settled.SettlingAt = env.Epoch
+		_, err := ValidateVoucher(rt.AdtStore(), &settled, env, actor.payee, voucher(0, 2, 5))
+		assert.Equal(t, ErrChannelStateUpdateAfterSettled, exitcode.Unwrap(err, exitcode.Ok))
+	})
+
+	t.Run("validation does not modify the state", func(t *testing.T) {
+		_, err := r.ValidateVoucher(env, actor.payee, voucher(0, 9, 50, Merge{Lane: 1, Nonce: 9}))
+		require.NoError(t, err)
+		assert.Equal(t, big.NewInt(3), r.State().ToSend)
+		lanes, err := r.Lanes()
+		require.NoError(t, err)
+		assert.Equal(t, []Lane{
+			{ID: 0, State: LaneState{Redeemed: big.NewInt(1), Nonce: 1}},
+			{ID: 1, State: LaneState{Redeemed: big.NewInt(2), Nonce: 2}},
+		}, lanes)
+	})
+
+	t.Run("redemption on chain matches validation", func(t *testing.T) {
+		params := voucher(0, 5, 10, Merge{Lane: 1, Nonce: 3})
+		params.Sv.MinSettleHeight = 50
+		redemption, err := r.ValidateVoucher(env, actor.payee, params)
+		require.NoError(t, err)
+
+		rt.SetCaller(actor.payee, builtin.AccountActorCodeID)
+		actor.updateChannelStateBatch(rt, *params)
+		var st State
+		rt.GetState(&st)
+		assert.Equal(t, redemption.ToSend, st.ToSend)
+		assert.Equal(t, redemption.MinSettleHeight, st.MinSettleHeight)
+		assert.Equal(t, redemption.SettlingAt, st.SettlingAt)
+	})
+}
@@ -14,7 +14,7 @@ import (
 
 var _ = xerrors.Errorf
 
-var lengthBufState = []byte{143}
+var lengthBufState = []byte{144}
 
 func (t *State) MarshalCBOR(w io.Writer) error {
 	if t == nil {
@@ -129,6 +129,12 @@ func (t *State) MarshalCBOR(w io.Writer) error {
 		}
 	}
 
+	// t.PowerSnapshots (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.PowerSnapshots); err != nil {
+		return xerrors.Errorf("failed to write cid field t.PowerSnapshots: %w", err)
+	}
+
 	return nil
 }
 
@@ -146,7 +152,7 @@ func (t *State) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 15 {
+	if extra != 16 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
@@ -351,11 +357,23 @@ func (t *State) UnmarshalCBOR(r io.Reader) error {
 			t.ProofValidationBatch = &c
 		}
 
+	}
+	// t.PowerSnapshots (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.PowerSnapshots: %w", err)
+		}
+
+		t.PowerSnapshots = c
+
 	}
 	return nil
 }
 
-var lengthBufClaim = []byte{131}
+var lengthBufClaim = []byte{133}
 
 func (t *Claim) MarshalCBOR(w io.Writer) error {
 	if t == nil {
@@ -388,6 +406,23 @@ func (t *Claim) MarshalCBOR(w io.Writer) error {
 	if err := t.QualityAdjPower.MarshalCBOR(w); err != nil {
 		return err
 	}
+
+	// t.ConsensusFaultCount (uint64) (uint64)
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.ConsensusFaultCount)); err != nil {
+		return err
+	}
+
+	// t.LastConsensusFaultEpoch (abi.ChainEpoch) (int64)
+	if t.LastConsensusFaultEpoch >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.LastConsensusFaultEpoch)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.LastConsensusFaultEpoch-1)); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -405,7 +440,7 @@ func (t *Claim) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 3 {
+	if extra != 5 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
@@ -452,10 +487,49 @@ func (t *Claim) UnmarshalCBOR(r io.Reader) error {
 		}
 
 	}
+	// t.ConsensusFaultCount (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.ConsensusFaultCount = uint64(extra)
+
+	}
+	// t.LastConsensusFaultEpoch (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.LastConsensusFaultEpoch = abi.ChainEpoch(extraI)
+	}
 	return nil
 }
 
-var lengthBufCronEvent = []byte{130}
+var lengthBufCronEvent = []byte{131}
 
 func (t *CronEvent) MarshalCBOR(w io.Writer) error {
 	if t == nil {
@@ -485,6 +559,11 @@ func (t *CronEvent) MarshalCBOR(w io.Writer) error {
 	if _, err := w.Write(t.CallbackPayload[:]); err != nil {
 		return err
 	}
+
+	// t.Priority (bool) (bool)
+	if err := cbg.WriteBool(w, t.Priority); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -502,7 +581,7 @@ func (t *CronEvent) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 2 {
+	if extra != 3 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
@@ -536,6 +615,137 @@ func (t *CronEvent) UnmarshalCBOR(r io.Reader) error {
 	if _, err := io.ReadFull(br, t.CallbackPayload[:]); err != nil {
 		return err
 	}
+	// t.Priority (bool) (bool)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajOther {
+		return fmt.Errorf("booleans must be major type 7")
+	}
+	switch extra {
+	case 20:
+		t.Priority = false
+	case 21:
+		t.Priority = true
+	default:
+		return fmt.Errorf("booleans are either major type 7, value 20 or 21 (got %d)", extra)
+	}
+	return nil
+}
+
+var lengthBufPowerSnapshot = []byte{132}
+
+func (t *PowerSnapshot) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufPowerSnapshot); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.Epoch (abi.ChainEpoch) (int64)
+	if t.Epoch >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.Epoch)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.Epoch-1)); err != nil {
+			return err
+		}
+	}
+
+	// t.RawBytePower (big.Int) (struct)
+	if err := t.RawBytePower.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.QualityAdjPower (big.Int) (struct)
+	if err := t.QualityAdjPower.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.PledgeCollateral (big.Int) (struct)
+	if err := t.PledgeCollateral.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *PowerSnapshot) UnmarshalCBOR(r io.Reader) error {
+	*t = PowerSnapshot{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 4 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Epoch (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.Epoch = abi.ChainEpoch(extraI)
+	}
+	// t.RawBytePower (big.Int) (struct)
+
+	{
+
+		if err := t.RawBytePower.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.RawBytePower: %w", err)
+		}
+
+	}
+	// t.QualityAdjPower (big.Int) (struct)
+
+	{
+
+		if err := t.QualityAdjPower.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.QualityAdjPower: %w", err)
+		}
+
+	}
+	// t.PledgeCollateral (big.Int) (struct)
+
+	{
+
+		if err := t.PledgeCollateral.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.PledgeCollateral: %w", err)
+		}
+
+	}
 	return nil
 }
 
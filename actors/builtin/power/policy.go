@@ -1,5 +1,11 @@
 package power
 
+import (
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+)
+
 type Policy struct {
 	// The number of miners that must meet the consensus minimum miner power before that minimum power is enforced
 	// as a condition of leader election.
@@ -11,11 +17,31 @@ type Policy struct {
 	// This limits the number of proof partitions we may need to load in the cron call path.
 	// Onboarding 1EiB/year requires at least 32 prove-commits per epoch.
 	MaxMinerProveCommitsPerEpoch int64
+
+	// Maximum number of deferred cron events invoked in one epoch.
+	//
+	// This bounds the number of miner callbacks in the cron call path, so that a burst of events
+	// enrolled for the same epoch cannot exceed the block gas limit. Events beyond the limit are moved,
+	// in order, to the next epoch, where they are invoked ahead of the events enrolled for it.
+	// Priority events, which miners enroll for the ends of proving deadlines, are never deferred,
+	// but count towards the limit.
+	MaxCronEventsPerEpoch int64
+
+	// Number of epochs between snapshots of network power, and the number of snapshots retained.
+	//
+	// Cron records the first snapshot in each interval, replacing the snapshot from PowerSnapshotCount intervals
+	// before. Changing either value reassigns snapshots' positions in the ring buffer, so the retained snapshots
+	// are then overwritten out of order until the buffer has been refilled.
+	PowerSnapshotInterval abi.ChainEpoch
+	PowerSnapshotCount    uint64
 }
 
 var DefaultPowerPolicy = Policy{
 	4,
 	200,
+	2000,
+	builtin.EpochsInHour(),
+	24,
 }
 
 var CurrentPowerPolicy = DefaultPowerPolicy
@@ -27,3 +53,15 @@ func ConsensusMinerMinMiners() int64 {
 func MaxMinerProveCommitsPerEpoch() int64 {
 	return CurrentPowerPolicy.MaxMinerProveCommitsPerEpoch
 }
+
+func MaxCronEventsPerEpoch() int64 {
+	return CurrentPowerPolicy.MaxCronEventsPerEpoch
+}
+
+func PowerSnapshotInterval() abi.ChainEpoch {
+	return CurrentPowerPolicy.PowerSnapshotInterval
+}
+
+func PowerSnapshotCount() uint64 {
+	return CurrentPowerPolicy.PowerSnapshotCount
+}
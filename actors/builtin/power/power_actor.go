@@ -44,6 +44,9 @@ func (a Actor) Exports() []interface{} {
 		7:                         nil, // deprecated
 		8:                         a.SubmitPoRepForBulkVerify,
 		9:                         a.CurrentTotalPower,
+		10:                        a.DeregisterMiner,
+		11:                        a.OnConsensusFault,
+		12:                        a.EnrollPriorityCronEvent,
 	}
 }
 
@@ -135,7 +138,11 @@ func (a Actor) CreateMiner(rt Runtime, params *CreateMinerParams) *CreateMinerRe
 		claims, err := adt.AsMap(adt.AsStore(rt), st.Claims, builtin.DefaultHamtBitwidth)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load claims")
 
-		err = setClaim(claims, addresses.IDAddress, &Claim{params.WindowPoStProofType, abi.NewStoragePower(0), abi.NewStoragePower(0)})
+		err = setClaim(claims, addresses.IDAddress, &Claim{
+			WindowPoStProofType: params.WindowPoStProofType,
+			RawBytePower:        abi.NewStoragePower(0),
+			QualityAdjPower:     abi.NewStoragePower(0),
+		})
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to put power in claimed table while creating miner")
 
 		st.MinerCount += 1
@@ -185,11 +192,24 @@ func (a Actor) UpdateClaimedPower(rt Runtime, params *UpdateClaimedPowerParams)
 type EnrollCronEventParams = power0.EnrollCronEventParams
 
 func (a Actor) EnrollCronEvent(rt Runtime, params *EnrollCronEventParams) *abi.EmptyValue {
+	enrollCronEvent(rt, params, false)
+	return nil
+}
+
+// Enrolls a cron event which is invoked at its epoch regardless of the per-epoch limit on cron events.
+// A miner enrolls the end of each proving deadline with this, since it must process a deadline as it ends.
+func (a Actor) EnrollPriorityCronEvent(rt Runtime, params *EnrollCronEventParams) *abi.EmptyValue {
+	enrollCronEvent(rt, params, true)
+	return nil
+}
+
+func enrollCronEvent(rt Runtime, params *EnrollCronEventParams, priority bool) {
 	rt.ValidateImmediateCallerType(builtin.StorageMinerActorCodeID)
 	minerAddr := rt.Caller()
 	minerEvent := CronEvent{
 		MinerAddr:       minerAddr,
 		CallbackPayload: params.Payload,
+		Priority:        priority,
 	}
 
 	// Ensure it is not possible to enter a large negative number which would cause problems in cron processing.
@@ -208,7 +228,6 @@ func (a Actor) EnrollCronEvent(rt Runtime, params *EnrollCronEventParams) *abi.E
 		st.CronEventQueue, err = events.Root()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush cron events")
 	})
-	return nil
 }
 
 // Called by Cron.
@@ -235,6 +254,9 @@ func (a Actor) OnEpochTickEnd(rt Runtime, _ *abi.EmptyValue) *abi.EmptyValue {
 		st.ThisEpochRawBytePower = rawBytePower
 		// we can now assume delta is one since cron is invoked on every epoch.
 		st.updateSmoothedEstimate(abi.ChainEpoch(1))
+
+		err := st.recordPowerSnapshot(adt.AsStore(rt), rt.CurrEpoch())
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record power snapshot")
 	})
 
 	// update network KPI in RewardActor
@@ -331,6 +353,88 @@ func (a Actor) CurrentTotalPower(rt Runtime, _ *abi.EmptyValue) *CurrentTotalPow
 	}
 }
 
+// Removes the calling miner's claim and decrements the miner count, after which the power actor no longer
+// accepts power, pledge or proofs from the miner, and skips its cron events when due.
+// May only be invoked by a miner actor, which must have no power and no proofs awaiting batch verification.
+// The miner actor is responsible for checking it has no sectors, pre-commitments or locked funds.
+func (a Actor) DeregisterMiner(rt Runtime, _ *abi.EmptyValue) *abi.EmptyValue {
+	rt.ValidateImmediateCallerType(builtin.StorageMinerActorCodeID)
+	minerAddr := rt.Caller()
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		store := adt.AsStore(rt)
+		claims, err := adt.AsMap(store, st.Claims, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load claims")
+
+		claim, found, err := getClaim(claims, minerAddr)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to look up claim for %s", minerAddr)
+		if !found {
+			rt.Abortf(exitcode.ErrForbidden, "unknown miner %s forbidden to interact with power actor", minerAddr)
+		}
+		if !claim.RawBytePower.IsZero() || !claim.QualityAdjPower.IsZero() {
+			rt.Abortf(exitcode.ErrForbidden, "miner %s cannot deregister with power raw %v, qa %v",
+				minerAddr, claim.RawBytePower, claim.QualityAdjPower)
+		}
+
+		if st.ProofValidationBatch != nil {
+			proofs, err := adt.AsMultimap(store, *st.ProofValidationBatch, builtin.DefaultHamtBitwidth, ProofValidationBatchAmtBitwidth)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load proof batch")
+			_, pending, err := proofs.Get(abi.AddrKey(minerAddr))
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to look up proofs for %s", minerAddr)
+			if pending {
+				rt.Abortf(exitcode.ErrForbidden, "miner %s cannot deregister with proofs awaiting verification", minerAddr)
+			}
+		}
+
+		// A claim without power counts toward the miners above the minimum only if the minimum is not positive.
+		minPower, err := builtin.ConsensusMinerMinPower(claim.WindowPoStProofType)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "could not get consensus miner min power")
+		if claim.RawBytePower.GreaterThanEqual(minPower) {
+			st.MinerAboveMinPowerCount--
+		}
+
+		_, err = st.deleteClaim(claims, minerAddr)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete claim for %s", minerAddr)
+		st.MinerCount--
+
+		st.Claims, err = claims.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush claims")
+	})
+	rt.Log(rtt.INFO, "deregistered miner %s", minerAddr)
+	return nil
+}
+
+// Records a consensus fault by the calling miner in its claim, for the penalty the miner has charged at
+// the current epoch.
+// May only be invoked by a miner actor. A miner without a claim, e.g. one which has deregistered, has no
+// record to update, and the call does nothing, so that the miner's fault can still be penalized.
+func (a Actor) OnConsensusFault(rt Runtime, _ *abi.EmptyValue) *abi.EmptyValue {
+	rt.ValidateImmediateCallerType(builtin.StorageMinerActorCodeID)
+	minerAddr := rt.Caller()
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		claims, err := adt.AsMap(adt.AsStore(rt), st.Claims, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load claims")
+
+		claim, found, err := getClaim(claims, minerAddr)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to look up claim for %s", minerAddr)
+		if !found {
+			rt.Log(rtt.INFO, "no claim to record consensus fault for miner %s", minerAddr)
+			return
+		}
+		claim.ConsensusFaultCount++
+		claim.LastConsensusFaultEpoch = rt.CurrEpoch()
+		err = setClaim(claims, minerAddr, claim)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set claim for %s", minerAddr)
+
+		st.Claims, err = claims.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush claims")
+	})
+	return nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // Method utility functions
 ////////////////////////////////////////////////////////////////////////////////
@@ -346,6 +450,11 @@ func validateMinerHasClaim(rt Runtime, st State, minerAddr addr.Address) {
 	}
 }
 
+// Verifies the seal proofs queued by SubmitPoRepForBulkVerify together, with a single BatchVerifySeals syscall,
+// and confirms the valid sectors to each miner.
+// The queued proofs can't instead be checked with aggregate verification, which needs an aggregate proof
+// produced by the prover over all the seals. Miners wanting that submit ProveCommitAggregate, which verifies
+// the aggregate proof in the miner actor without queueing here.
 func (a Actor) processBatchProofVerifies(rt Runtime, rewret reward.ThisEpochRewardReturn) error {
 	var st State
 
@@ -480,11 +589,21 @@ func (a Actor) processDeferredCronEvents(rt Runtime, rewret reward.ThisEpochRewa
 		claims, err := adt.AsMap(adt.AsStore(rt), st.Claims, builtin.DefaultHamtBitwidth)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load claims")
 
+		// Events are invoked in order of epoch and then enrollment, up to the per-epoch limit.
+		// Priority events are always invoked at their epoch, but count towards the limit.
+		// Other events beyond the limit are moved to the next epoch, ahead of the events enrolled for it,
+		// so that each is invoked exactly once and none is left at an epoch that has been run.
+		limit := MaxCronEventsPerEpoch()
+		var deferred []CronEvent
 		for epoch := st.FirstCronEpoch; epoch <= rtEpoch; epoch++ {
 			epochEvents, err := loadCronEvents(events, epoch)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load cron events at %v", epoch)
 
 			for _, evt := range epochEvents {
+				if int64(len(cronEvents)) >= limit && !evt.Priority {
+					deferred = append(deferred, evt)
+					continue
+				}
 				// refuse to process proofs for miner with no claim
 				found, err := claims.Has(abi.AddrKey(evt.MinerAddr))
 				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to look up claim")
@@ -503,7 +622,21 @@ func (a Actor) processDeferredCronEvents(rt Runtime, rewret reward.ThisEpochRewa
 			}
 		}
 
-		st.FirstCronEpoch = rtEpoch + 1
+		nextCronEpoch := rtEpoch + 1
+		if len(deferred) > 0 {
+			later, err := loadCronEvents(events, nextCronEpoch)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load cron events at %v", nextCronEpoch)
+			err = events.RemoveAll(epochKey(nextCronEpoch))
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to clear cron events at %v", nextCronEpoch)
+
+			queued := append(deferred, later...)
+			for i := range queued {
+				err = events.Add(epochKey(nextCronEpoch), &queued[i])
+				builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to store deferred cron event at %v", nextCronEpoch)
+			}
+			rt.Log(rtt.WARN, "deferring %d cron events to epoch %d beyond limit of %d events per epoch", len(deferred), nextCronEpoch, limit)
+		}
+		st.FirstCronEpoch = nextCronEpoch
 
 		st.CronEventQueue, err = events.Root()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush events")
@@ -34,6 +34,9 @@ const CronQueueAmtBitwidth = 6
 // pattersn and projections of mainnet data.
 const ProofValidationBatchAmtBitwidth = 4
 
+// Bitwidth of PowerSnapshots AMT, which holds at most PowerSnapshotCount entries.
+const PowerSnapshotsAmtBitwidth = 3
+
 type State struct {
 	TotalRawBytePower abi.StoragePower
 	// TotalBytesCommitted includes claims from miners below min power threshold
@@ -59,12 +62,17 @@ type State struct {
 
 	// First epoch in which a cron task may be stored.
 	// Cron will iterate every epoch between this and the current epoch inclusively to find tasks to execute.
+	// Every epoch before this one has been run, and tasks deferred by the per-epoch limit are stored at this one.
 	FirstCronEpoch abi.ChainEpoch
 
 	// Claimed power for each miner.
 	Claims cid.Cid // Map, HAMT[address]Claim
 
 	ProofValidationBatch *cid.Cid // Multimap, (HAMT[Address]AMT[SealVerifyInfo])
+
+	// Recent snapshots of network power and pledge, recorded by cron once per snapshot interval.
+	// Each is stored at the index of its interval modulo the number of snapshots retained.
+	PowerSnapshots cid.Cid // Array, AMT[uint64]PowerSnapshot
 }
 
 type Claim struct {
@@ -76,11 +84,28 @@ type Claim struct {
 
 	// Sum of quality adjusted power for a miner's sectors.
 	QualityAdjPower abi.StoragePower
+
+	// Number of consensus faults for which the miner has been penalized.
+	ConsensusFaultCount uint64
+
+	// Epoch at which the miner was last penalized for a consensus fault, if ConsensusFaultCount is non-zero.
+	LastConsensusFaultEpoch abi.ChainEpoch
+}
+
+// The network's power and pledge following cron at an epoch, which are the values used by the next epoch.
+type PowerSnapshot struct {
+	Epoch            abi.ChainEpoch
+	RawBytePower     abi.StoragePower
+	QualityAdjPower  abi.StoragePower
+	PledgeCollateral abi.TokenAmount
 }
 
 type CronEvent struct {
 	MinerAddr       addr.Address
 	CallbackPayload []byte
+	// Whether the event is invoked at its epoch regardless of the per-epoch limit on cron events.
+	// Set by a miner enrolling an event it can't handle late.
+	Priority bool
 }
 
 func ConstructState(store adt.Store) (*State, error) {
@@ -92,6 +117,10 @@ func ConstructState(store adt.Store) (*State, error) {
 	if err != nil {
 		return nil, xerrors.Errorf("failed to create empty multimap: %w", err)
 	}
+	emptySnapshotsCid, err := adt.StoreEmptyArray(store, PowerSnapshotsAmtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create empty array: %w", err)
+	}
 
 	return &State{
 		TotalRawBytePower:         abi.NewStoragePower(0),
@@ -108,6 +137,7 @@ func ConstructState(store adt.Store) (*State, error) {
 		Claims:                    emptyClaimsMapCid,
 		MinerCount:                0,
 		MinerAboveMinPowerCount:   0,
+		PowerSnapshots:            emptySnapshotsCid,
 	}, nil
 }
 
@@ -193,6 +223,9 @@ func (st *State) addToClaim(claims *adt.Map, miner addr.Address, power abi.Stora
 		WindowPoStProofType: oldClaim.WindowPoStProofType,
 		RawBytePower:        big.Add(oldClaim.RawBytePower, power),
 		QualityAdjPower:     big.Add(oldClaim.QualityAdjPower, qapower),
+
+		ConsensusFaultCount:     oldClaim.ConsensusFaultCount,
+		LastConsensusFaultEpoch: oldClaim.LastConsensusFaultEpoch,
 	}
 
 	minPower, err := builtin.ConsensusMinerMinPower(oldClaim.WindowPoStProofType)
@@ -264,6 +297,34 @@ func (st *State) deleteClaim(claims *adt.Map, miner addr.Address) (bool, error)
 	return true, claims.Delete(abi.AddrKey(miner))
 }
 
+// Removes the claims of miners, subtracting their power from the totals and decrementing the miner count for
+// each claim removed. Miners without claims are ignored. Returns the number of claims removed.
+// This is intended for state migrations which remove miner actors.
+func (st *State) DeleteClaims(s adt.Store, miners []addr.Address) (int, error) {
+	claims, err := adt.AsMap(s, st.Claims, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to load claims: %w", err)
+	}
+	removed := 0
+	for _, miner := range miners {
+		found, err := st.deleteClaim(claims, miner)
+		if err != nil {
+			return 0, xerrors.Errorf("failed to delete claim for %s: %w", miner, err)
+		}
+		if found {
+			st.MinerCount--
+			removed++
+		}
+	}
+	if st.MinerCount < 0 {
+		return 0, xerrors.Errorf("negative miner count: %d", st.MinerCount)
+	}
+	if st.Claims, err = claims.Root(); err != nil {
+		return 0, xerrors.Errorf("failed to flush claims: %w", err)
+	}
+	return removed, nil
+}
+
 func getClaim(claims *adt.Map, a addr.Address) (*Claim, bool, error) {
 	var out Claim
 	found, err := claims.Get(abi.AddrKey(a), &out)
@@ -281,9 +342,9 @@ func (st *State) addPledgeTotal(amount abi.TokenAmount) {
 }
 
 func (st *State) appendCronEvent(events *adt.Multimap, epoch abi.ChainEpoch, event *CronEvent) error {
-	// if event is in past, alter FirstCronEpoch so it will be found.
+	// If the event's epoch has already been run by cron, store it at the first epoch yet to run so it will be found.
 	if epoch < st.FirstCronEpoch {
-		st.FirstCronEpoch = epoch
+		epoch = st.FirstCronEpoch
 	}
 
 	if err := events.Add(epochKey(epoch), event); err != nil {
@@ -293,6 +354,36 @@ func (st *State) appendCronEvent(events *adt.Multimap, epoch abi.ChainEpoch, eve
 	return nil
 }
 
+// Records a snapshot of this epoch's power and pledge, unless one has already been recorded in the epoch's interval.
+func (st *State) recordPowerSnapshot(s adt.Store, epoch abi.ChainEpoch) error {
+	snapshots, err := adt.AsArray(s, st.PowerSnapshots, PowerSnapshotsAmtBitwidth)
+	if err != nil {
+		return xerrors.Errorf("failed to load power snapshots: %w", err)
+	}
+	interval := epoch / PowerSnapshotInterval()
+	index := uint64(interval) % PowerSnapshotCount()
+	var prev PowerSnapshot
+	found, err := snapshots.Get(index, &prev)
+	if err != nil {
+		return xerrors.Errorf("failed to load power snapshot %d: %w", index, err)
+	}
+	if found && prev.Epoch/PowerSnapshotInterval() == interval {
+		return nil
+	}
+	if err := snapshots.Set(index, &PowerSnapshot{
+		Epoch:            epoch,
+		RawBytePower:     st.ThisEpochRawBytePower,
+		QualityAdjPower:  st.ThisEpochQualityAdjPower,
+		PledgeCollateral: st.ThisEpochPledgeCollateral,
+	}); err != nil {
+		return xerrors.Errorf("failed to store power snapshot %d: %w", index, err)
+	}
+	if st.PowerSnapshots, err = snapshots.Root(); err != nil {
+		return xerrors.Errorf("failed to flush power snapshots: %w", err)
+	}
+	return nil
+}
+
 func (st *State) updateSmoothedEstimate(delta abi.ChainEpoch) {
 	filterQAPower := smoothing.LoadFilter(st.ThisEpochQAPowerSmoothed, smoothing.DefaultAlpha, smoothing.DefaultBeta)
 	st.ThisEpochQAPowerSmoothed = filterQAPower.NextEstimate(st.ThisEpochQualityAdjPower, delta)
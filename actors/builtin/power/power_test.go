@@ -70,7 +70,7 @@ func TestConstruction(t *testing.T) {
 		found, err_ := claim.Get(asKey(keys[0]), &actualClaim)
 		require.NoError(t, err_)
 		assert.True(t, found)
-		assert.Equal(t, power.Claim{abi.RegisteredPoStProof_StackedDrgWindow32GiBV1, big.Zero(), big.Zero()}, actualClaim) // miner has not proven anything
+		assert.Equal(t, power.Claim{WindowPoStProofType: abi.RegisteredPoStProof_StackedDrgWindow32GiBV1, RawBytePower: big.Zero(), QualityAdjPower: big.Zero()}, actualClaim) // miner has not proven anything
 
 		verifyEmptyMap(t, rt, st.CronEventQueue)
 		actor.checkState(rt)
@@ -830,6 +830,336 @@ func TestCron(t *testing.T) {
 		rt.Verify()
 		actor.checkState(rt)
 	})
+
+	t.Run("events beyond the per-epoch limit are deferred in order", func(t *testing.T) {
+		defer func(limit int64) { power.CurrentPowerPolicy.MaxCronEventsPerEpoch = limit }(power.MaxCronEventsPerEpoch())
+		power.CurrentPowerPolicy.MaxCronEventsPerEpoch = 2
+
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetEpoch(1)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		actor.createMinerBasic(rt, owner, owner, miner2)
+
+		actor.enrollCronEvent(rt, miner1, 2, []byte{0x1})
+		actor.enrollCronEvent(rt, miner2, 2, []byte{0x2})
+		actor.enrollCronEvent(rt, miner1, 2, []byte{0x3})
+		actor.enrollCronEvent(rt, miner2, 3, []byte{0x4})
+		actor.enrollCronEvent(rt, miner1, 6, []byte{0x5})
+
+		tick := func(epoch abi.ChainEpoch, expected ...power.CronEvent) {
+			rt.SetEpoch(epoch)
+			rt.ExpectValidateCallerAddr(builtin.CronActorAddr)
+			expectQueryNetworkInfo(rt, actor)
+			st := getState(rt)
+			for _, evt := range expected {
+				params := builtin.DeferredCronEventParams{
+					EventPayload:            evt.CallbackPayload,
+					RewardSmoothed:          actor.thisEpochRewardSmoothed,
+					QualityAdjPowerSmoothed: st.ThisEpochQAPowerSmoothed,
+				}
+				rt.ExpectSend(evt.MinerAddr, builtin.MethodsMiner.OnDeferredCronEvent, &params, big.Zero(), nil, exitcode.Ok)
+			}
+			expectedPower := big.Zero()
+			rt.ExpectSend(builtin.RewardActorAddr, builtin.MethodsReward.UpdateNetworkKPI, &expectedPower, big.Zero(), nil, exitcode.Ok)
+			rt.SetCaller(builtin.CronActorAddr, builtin.CronActorCodeID)
+			rt.ExpectBatchVerifySeals(nil, nil, nil)
+
+			rt.Call(actor.Actor.OnEpochTickEnd, nil)
+			rt.Verify()
+			actor.checkState(rt)
+		}
+
+		// The last event at epoch 2 and the event at epoch 3 are moved to the next epoch.
+		tick(4, power.CronEvent{MinerAddr: miner1, CallbackPayload: []byte{0x1}},
+			power.CronEvent{MinerAddr: miner2, CallbackPayload: []byte{0x2}})
+		rt.ExpectLogsContain("deferring 2 cron events to epoch 5")
+
+		st := getState(rt)
+		assert.Equal(t, abi.ChainEpoch(5), st.FirstCronEpoch)
+
+		// An event enrolled for an epoch that has been run is queued behind the deferred events.
+		actor.enrollCronEvent(rt, miner2, 3, []byte{0x6})
+
+		r, err := power.NewStateReader(rt.AdtStore(), rt.StateRoot())
+		require.NoError(t, err)
+		queue, err := r.CronEvents(0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []power.EpochCronEvents{
+			{Epoch: 5, Events: []power.CronEvent{
+				{MinerAddr: miner1, CallbackPayload: []byte{0x3}},
+				{MinerAddr: miner2, CallbackPayload: []byte{0x4}},
+				{MinerAddr: miner2, CallbackPayload: []byte{0x6}},
+			}},
+			{Epoch: 6, Events: []power.CronEvent{{MinerAddr: miner1, CallbackPayload: []byte{0x5}}}},
+		}, queue)
+
+		// Deferred events are invoked ahead of events at later epochs, up to the limit.
+		tick(6, power.CronEvent{MinerAddr: miner1, CallbackPayload: []byte{0x3}},
+			power.CronEvent{MinerAddr: miner2, CallbackPayload: []byte{0x4}})
+		rt.ExpectLogsContain("deferring 2 cron events to epoch 7")
+		st = getState(rt)
+		assert.Equal(t, abi.ChainEpoch(7), st.FirstCronEpoch)
+
+		tick(7, power.CronEvent{MinerAddr: miner2, CallbackPayload: []byte{0x6}},
+			power.CronEvent{MinerAddr: miner1, CallbackPayload: []byte{0x5}})
+		st = getState(rt)
+		assert.Equal(t, abi.ChainEpoch(8), st.FirstCronEpoch)
+		verifyEmptyMap(t, rt, st.CronEventQueue)
+	})
+
+	t.Run("priority events are never deferred", func(t *testing.T) {
+		defer func(limit int64) { power.CurrentPowerPolicy.MaxCronEventsPerEpoch = limit }(power.MaxCronEventsPerEpoch())
+		power.CurrentPowerPolicy.MaxCronEventsPerEpoch = 1
+
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+
+		rt.SetEpoch(1)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		actor.createMinerBasic(rt, owner, owner, miner2)
+
+		actor.enrollCronEvent(rt, miner1, 2, []byte{0x1})
+		actor.enrollPriorityCronEvent(rt, miner2, 2, []byte{0x2})
+		actor.enrollPriorityCronEvent(rt, miner1, 3, []byte{0x3})
+		actor.enrollCronEvent(rt, miner2, 3, []byte{0x4})
+
+		tick := func(epoch abi.ChainEpoch, expected ...power.CronEvent) {
+			rt.SetEpoch(epoch)
+			rt.ExpectValidateCallerAddr(builtin.CronActorAddr)
+			expectQueryNetworkInfo(rt, actor)
+			st := getState(rt)
+			for _, evt := range expected {
+				params := builtin.DeferredCronEventParams{
+					EventPayload:            evt.CallbackPayload,
+					RewardSmoothed:          actor.thisEpochRewardSmoothed,
+					QualityAdjPowerSmoothed: st.ThisEpochQAPowerSmoothed,
+				}
+				rt.ExpectSend(evt.MinerAddr, builtin.MethodsMiner.OnDeferredCronEvent, &params, big.Zero(), nil, exitcode.Ok)
+			}
+			expectedPower := big.Zero()
+			rt.ExpectSend(builtin.RewardActorAddr, builtin.MethodsReward.UpdateNetworkKPI, &expectedPower, big.Zero(), nil, exitcode.Ok)
+			rt.SetCaller(builtin.CronActorAddr, builtin.CronActorCodeID)
+			rt.ExpectBatchVerifySeals(nil, nil, nil)
+
+			rt.Call(actor.Actor.OnEpochTickEnd, nil)
+			rt.Verify()
+			actor.checkState(rt)
+		}
+
+		// The priority events are invoked beyond the limit, the other event at epoch 3 is deferred.
+		tick(3, power.CronEvent{MinerAddr: miner1, CallbackPayload: []byte{0x1}},
+			power.CronEvent{MinerAddr: miner2, CallbackPayload: []byte{0x2}},
+			power.CronEvent{MinerAddr: miner1, CallbackPayload: []byte{0x3}})
+		rt.ExpectLogsContain("deferring 1 cron events to epoch 4")
+		st := getState(rt)
+		assert.Equal(t, abi.ChainEpoch(4), st.FirstCronEpoch)
+
+		// A priority event enrolled behind a deferred event is still invoked at its own epoch.
+		actor.enrollPriorityCronEvent(rt, miner2, 4, []byte{0x5})
+		actor.enrollCronEvent(rt, miner1, 4, []byte{0x6})
+		tick(4, power.CronEvent{MinerAddr: miner2, CallbackPayload: []byte{0x4}},
+			power.CronEvent{MinerAddr: miner2, CallbackPayload: []byte{0x5}})
+		rt.ExpectLogsContain("deferring 1 cron events to epoch 5")
+		st = getState(rt)
+		assert.Equal(t, abi.ChainEpoch(5), st.FirstCronEpoch)
+
+		tick(5, power.CronEvent{MinerAddr: miner1, CallbackPayload: []byte{0x6}})
+		st = getState(rt)
+		assert.Equal(t, abi.ChainEpoch(6), st.FirstCronEpoch)
+		verifyEmptyMap(t, rt, st.CronEventQueue)
+	})
+}
+
+func TestPowerSnapshots(t *testing.T) {
+	defer func(policy power.Policy) { power.CurrentPowerPolicy = policy }(power.CurrentPowerPolicy)
+	power.CurrentPowerPolicy.PowerSnapshotInterval = 10
+	power.CurrentPowerPolicy.PowerSnapshotCount = 3
+
+	actor := newHarness(t)
+	miner := tutil.NewIDAddr(t, 101)
+	owner := tutil.NewIDAddr(t, 102)
+	powerUnit, err := builtin.ConsensusMinerMinPower(abi.RegisteredPoStProof_StackedDrgWindow32GiBV1)
+	require.NoError(t, err)
+
+	rt := mock.NewBuilder(builtin.StoragePowerActorAddr).WithCaller(builtin.SystemActorAddr, builtin.SystemActorCodeID).Build(t)
+	actor.constructAndVerify(rt)
+	actor.createMinerBasic(rt, owner, owner, miner)
+
+	// Each tick adds another unit of power and pledge.
+	tick := func(epoch abi.ChainEpoch, units int64) {
+		actor.updateClaimedPower(rt, miner, powerUnit, powerUnit)
+		actor.updatePledgeTotal(rt, miner, abi.NewTokenAmount(1))
+		actor.onEpochTickEnd(rt, epoch, big.Mul(powerUnit, big.NewInt(units)), nil, nil)
+		actor.checkState(rt)
+	}
+	snapshot := func(epoch abi.ChainEpoch, units int64) power.PowerSnapshot {
+		return power.PowerSnapshot{
+			Epoch:            epoch,
+			RawBytePower:     big.Mul(powerUnit, big.NewInt(units)),
+			QualityAdjPower:  big.Mul(powerUnit, big.NewInt(units)),
+			PledgeCollateral: abi.NewTokenAmount(units),
+		}
+	}
+	snapshots := func() []power.PowerSnapshot {
+		r, err := power.NewStateReader(rt.AdtStore(), rt.StateRoot())
+		require.NoError(t, err)
+		snapshots, err := r.PowerSnapshots()
+		require.NoError(t, err)
+		return snapshots
+	}
+
+	// Only the first tick in each interval is recorded.
+	tick(1, 1)
+	tick(5, 2)
+	tick(12, 3)
+	tick(25, 4)
+	assert.Equal(t, []power.PowerSnapshot{snapshot(1, 1), snapshot(12, 3), snapshot(25, 4)}, snapshots())
+
+	// The oldest snapshot is replaced once the buffer is full.
+	tick(31, 5)
+	assert.Equal(t, []power.PowerSnapshot{snapshot(12, 3), snapshot(25, 4), snapshot(31, 5)}, snapshots())
+
+	r, err := power.NewStateReader(rt.AdtStore(), rt.StateRoot())
+	require.NoError(t, err)
+	found, ok, err := r.PowerSnapshotAt(29)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, snapshot(25, 4), *found)
+
+	_, ok, err = r.PowerSnapshotAt(11)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestDeregisterMiner(t *testing.T) {
+	actor := newHarness(t)
+	owner := tutil.NewIDAddr(t, 101)
+	miner1 := tutil.NewIDAddr(t, 111)
+	miner2 := tutil.NewIDAddr(t, 112)
+	builder := mock.NewBuilder(builtin.StoragePowerActorAddr).WithCaller(builtin.SystemActorAddr, builtin.SystemActorCodeID)
+
+	t.Run("removes claim of miner without power", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		actor.createMinerBasic(rt, owner, owner, miner2)
+
+		actor.deregisterMiner(rt, miner1)
+		rt.ExpectLogsContain("deregistered miner")
+
+		st := getState(rt)
+		assert.Equal(t, int64(1), st.MinerCount)
+		_, found, err := st.GetClaim(rt.AdtStore(), miner1)
+		require.NoError(t, err)
+		assert.False(t, found)
+		actor.checkState(rt)
+
+		// The miner may no longer interact with the power actor.
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "unknown miner", func() {
+			actor.updatePledgeTotal(rt, miner1, abi.NewTokenAmount(1))
+		})
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "unknown miner", func() {
+			actor.deregisterMiner(rt, miner1)
+		})
+	})
+
+	t.Run("fails for miner with power", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		actor.updateClaimedPower(rt, miner1, big.NewInt(1), big.NewInt(1))
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "cannot deregister with power", func() {
+			actor.deregisterMiner(rt, miner1)
+		})
+		actor.checkState(rt)
+	})
+
+	t.Run("fails for miner with proofs awaiting verification", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		actor.submitPoRepForBulkVerify(rt, miner1, &proof.SealVerifyInfo{
+			SealProof:   actor.sealProof,
+			SealedCID:   tutil.MakeCID("commR", &mineract.SealedCIDPrefix),
+			UnsealedCID: tutil.MakeCID("commD", &market.PieceCIDPrefix),
+		})
+
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "proofs awaiting verification", func() {
+			actor.deregisterMiner(rt, miner1)
+		})
+	})
+
+	t.Run("fails if caller is not a miner", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+
+		rt.SetCaller(miner1, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.DeregisterMiner, nil)
+		})
+	})
+}
+
+func TestOnConsensusFault(t *testing.T) {
+	actor := newHarness(t)
+	owner := tutil.NewIDAddr(t, 101)
+	miner1 := tutil.NewIDAddr(t, 111)
+	builder := mock.NewBuilder(builtin.StoragePowerActorAddr).WithCaller(builtin.SystemActorAddr, builtin.SystemActorCodeID)
+
+	t.Run("records faults in claim", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		actor.updateClaimedPower(rt, miner1, big.NewInt(100), big.NewInt(200))
+
+		rt.SetEpoch(100)
+		actor.onConsensusFault(rt, miner1)
+		claim := actor.getClaim(rt, miner1)
+		assert.Equal(t, uint64(1), claim.ConsensusFaultCount)
+		assert.Equal(t, abi.ChainEpoch(100), claim.LastConsensusFaultEpoch)
+		assert.Equal(t, big.NewInt(100), claim.RawBytePower)
+		assert.Equal(t, big.NewInt(200), claim.QualityAdjPower)
+
+		rt.SetEpoch(200)
+		actor.onConsensusFault(rt, miner1)
+
+		// Changes in power keep the record of faults.
+		actor.updateClaimedPower(rt, miner1, big.NewInt(-100), big.NewInt(-200))
+		claim = actor.getClaim(rt, miner1)
+		assert.Equal(t, uint64(2), claim.ConsensusFaultCount)
+		assert.Equal(t, abi.ChainEpoch(200), claim.LastConsensusFaultEpoch)
+		actor.checkState(rt)
+	})
+
+	t.Run("does nothing for a miner without a claim", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+		actor.deregisterMiner(rt, miner1)
+
+		actor.onConsensusFault(rt, miner1)
+		rt.ExpectLogsContain("no claim to record consensus fault")
+		st := getState(rt)
+		verifyEmptyMap(t, rt, st.Claims)
+		actor.checkState(rt)
+	})
+
+	t.Run("fails if caller is not a miner", func(t *testing.T) {
+		rt := builder.Build(t)
+		actor.constructAndVerify(rt)
+		actor.createMinerBasic(rt, owner, owner, miner1)
+
+		rt.SetCaller(miner1, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(actor.OnConsensusFault, nil)
+		})
+	})
 }
 
 func TestSubmitPoRepForBulkVerify(t *testing.T) {
@@ -1369,6 +1699,26 @@ func (h *spActorHarness) updatePledgeTotal(rt *mock.Runtime, miner addr.Address,
 	require.EqualValues(h.t, big.Add(prev, delta), new)
 }
 
+func (h *spActorHarness) deregisterMiner(rt *mock.Runtime, miner addr.Address) {
+	st := getState(rt)
+	prevCount := st.MinerCount
+
+	rt.SetCaller(miner, builtin.StorageMinerActorCodeID)
+	rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+	rt.Call(h.DeregisterMiner, nil)
+	rt.Verify()
+
+	st = getState(rt)
+	require.Equal(h.t, prevCount-1, st.MinerCount)
+}
+
+func (h *spActorHarness) onConsensusFault(rt *mock.Runtime, miner addr.Address) {
+	rt.SetCaller(miner, builtin.StorageMinerActorCodeID)
+	rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+	rt.Call(h.OnConsensusFault, nil)
+	rt.Verify()
+}
+
 func (h *spActorHarness) currentPowerTotal(rt *mock.Runtime) *power.CurrentTotalPowerReturn {
 	rt.ExpectValidateCallerAny()
 	ret := rt.Call(h.CurrentTotalPower, nil).(*power.CurrentTotalPowerReturn)
@@ -1387,6 +1737,16 @@ func (h *spActorHarness) enrollCronEvent(rt *mock.Runtime, miner addr.Address, e
 
 }
 
+func (h *spActorHarness) enrollPriorityCronEvent(rt *mock.Runtime, miner addr.Address, epoch abi.ChainEpoch, payload []byte) {
+	rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
+	rt.SetCaller(miner, builtin.StorageMinerActorCodeID)
+	rt.Call(h.Actor.EnrollPriorityCronEvent, &power.EnrollCronEventParams{
+		EventEpoch: epoch,
+		Payload:    payload,
+	})
+	rt.Verify()
+}
+
 func (h *spActorHarness) submitPoRepForBulkVerify(rt *mock.Runtime, minerAddr addr.Address, sealInfo *proof.SealVerifyInfo) {
 	rt.ExpectGasCharged(power.GasOnSubmitVerifySeal)
 	rt.ExpectValidateCallerType(builtin.StorageMinerActorCodeID)
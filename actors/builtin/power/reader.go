@@ -0,0 +1,225 @@
+package power
+
+import (
+	"bytes"
+	"sort"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// Read-only queries of the power actor's state, for tools outside the VM which report on network power.
+// Claims are loaded and sorted on the first paged query, and later pages are served from them, so paging through
+// one reader gives a consistent view of a single head. A cursor given to a reader of another head continues in
+// address order over that head's claims, which may have changed.
+type StateReader struct {
+	store adt.Store
+	st    State
+	// Loaded on first use, in increasing order of miner address bytes.
+	claims []MinerClaim
+}
+
+// A miner's claim of power.
+type MinerClaim struct {
+	Miner addr.Address
+	Claim Claim
+}
+
+// A page of claims, in increasing order of miner address bytes.
+type ClaimPage struct {
+	Claims []MinerClaim
+	// The cursor from which to query the next page, if there are more claims.
+	Next addr.Address
+	More bool
+}
+
+// The claims of the miners using a WindowPoSt proof type.
+type ProofTypePower struct {
+	MinerCount int64
+	// Number of the miners having proven the minimum consensus power.
+	MinerAboveMinPowerCount int64
+	// Total power claimed, including claims from miners below the minimum consensus power.
+	RawBytePower    abi.StoragePower
+	QualityAdjPower abi.StoragePower
+}
+
+// The events in the cron queue at an epoch, in the order they will be invoked.
+type EpochCronEvents struct {
+	Epoch  abi.ChainEpoch
+	Events []CronEvent
+}
+
+// Loads the power actor's state from its head.
+func NewStateReader(store adt.Store, head cid.Cid) (*StateReader, error) {
+	r := &StateReader{store: store}
+	if err := store.Get(store.Context(), head, &r.st); err != nil {
+		return nil, xerrors.Errorf("failed to load power state %v: %w", head, err)
+	}
+	return r, nil
+}
+
+// The state loaded from the head, from which claims, cron events and power snapshots are read. It must not be modified.
+func (r *StateReader) State() *State {
+	return &r.st
+}
+
+// Returns a miner's claim, if it has one.
+func (r *StateReader) Claim(miner addr.Address) (*Claim, bool, error) {
+	return r.st.GetClaim(r.store, miner)
+}
+
+func (r *StateReader) loadClaims() ([]MinerClaim, error) {
+	if r.claims == nil {
+		claims, err := adt.AsMap(r.store, r.st.Claims, builtin.DefaultHamtBitwidth)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load claims: %w", err)
+		}
+		all := []MinerClaim{}
+		var claim Claim
+		if err := claims.ForEach(&claim, func(k string) error {
+			miner, err := addr.NewFromBytes([]byte(k))
+			if err != nil {
+				return xerrors.Errorf("invalid claim key %x: %w", k, err)
+			}
+			all = append(all, MinerClaim{Miner: miner, Claim: claim})
+			return nil
+		}); err != nil {
+			return nil, xerrors.Errorf("failed to iterate claims: %w", err)
+		}
+		sort.Slice(all, func(i, j int) bool {
+			return bytes.Compare(all[i].Miner.Bytes(), all[j].Miner.Bytes()) < 0
+		})
+		r.claims = all
+	}
+	return r.claims, nil
+}
+
+// Returns up to `limit` claims, starting from the claim of the miner `cursor` or the next miner in order.
+// The first page is given by the cursor addr.Undef.
+func (r *StateReader) Claims(cursor addr.Address, limit int) (*ClaimPage, error) {
+	if limit <= 0 {
+		return nil, xerrors.Errorf("invalid page limit %d", limit)
+	}
+	claims, err := r.loadClaims()
+	if err != nil {
+		return nil, err
+	}
+	start := sort.Search(len(claims), func(i int) bool {
+		return bytes.Compare(claims[i].Miner.Bytes(), cursor.Bytes()) >= 0
+	})
+	end := start + limit
+	page := ClaimPage{}
+	if end < len(claims) {
+		page.Next = claims[end].Miner
+		page.More = true
+	} else {
+		end = len(claims)
+	}
+	page.Claims = append([]MinerClaim{}, claims[start:end]...)
+	return &page, nil
+}
+
+// Returns the claims aggregated by the miners' WindowPoSt proof types.
+func (r *StateReader) PowerByProofType() (map[abi.RegisteredPoStProof]ProofTypePower, error) {
+	claims, err := r.loadClaims()
+	if err != nil {
+		return nil, err
+	}
+	byType := make(map[abi.RegisteredPoStProof]ProofTypePower)
+	for _, c := range claims {
+		minPower, err := builtin.ConsensusMinerMinPower(c.Claim.WindowPoStProofType)
+		if err != nil {
+			return nil, xerrors.Errorf("could not get consensus miner min power for %v: %w", c.Miner, err)
+		}
+		agg, ok := byType[c.Claim.WindowPoStProofType]
+		if !ok {
+			agg = ProofTypePower{RawBytePower: big.Zero(), QualityAdjPower: big.Zero()}
+		}
+		agg.MinerCount++
+		if c.Claim.RawBytePower.GreaterThanEqual(minPower) {
+			agg.MinerAboveMinPowerCount++
+		}
+		agg.RawBytePower = big.Add(agg.RawBytePower, c.Claim.RawBytePower)
+		agg.QualityAdjPower = big.Add(agg.QualityAdjPower, c.Claim.QualityAdjPower)
+		byType[c.Claim.WindowPoStProofType] = agg
+	}
+	return byType, nil
+}
+
+// Returns the claims of miners last penalized for a consensus fault at or after an epoch,
+// in increasing order of miner address bytes.
+func (r *StateReader) ConsensusFaultsSince(epoch abi.ChainEpoch) ([]MinerClaim, error) {
+	claims, err := r.loadClaims()
+	if err != nil {
+		return nil, err
+	}
+	faulted := []MinerClaim{}
+	for _, c := range claims {
+		if c.Claim.ConsensusFaultCount > 0 && c.Claim.LastConsensusFaultEpoch >= epoch {
+			faulted = append(faulted, c)
+		}
+	}
+	return faulted, nil
+}
+
+// Returns the events in the cron queue at each epoch from `from` until before `to`,
+// omitting epochs at which no event is enrolled.
+// Events remaining at epochs which cron has already passed were deferred by the per-epoch limit,
+// and will be invoked ahead of any events at later epochs.
+func (r *StateReader) CronEvents(from, to abi.ChainEpoch) ([]EpochCronEvents, error) {
+	if to < from {
+		return nil, xerrors.Errorf("invalid epoch range [%d, %d)", from, to)
+	}
+	events, err := adt.AsMultimap(r.store, r.st.CronEventQueue, CronQueueHamtBitwidth, CronQueueAmtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load cron events: %w", err)
+	}
+	var queue []EpochCronEvents
+	for epoch := from; epoch < to; epoch++ {
+		epochEvents, err := loadCronEvents(events, epoch)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load cron events at epoch %d: %w", epoch, err)
+		}
+		if len(epochEvents) > 0 {
+			queue = append(queue, EpochCronEvents{Epoch: epoch, Events: epochEvents})
+		}
+	}
+	return queue, nil
+}
+
+// Returns the retained snapshots of network power, in increasing order of epoch.
+func (r *StateReader) PowerSnapshots() ([]PowerSnapshot, error) {
+	snapshots, err := adt.AsArray(r.store, r.st.PowerSnapshots, PowerSnapshotsAmtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load power snapshots: %w", err)
+	}
+	all := []PowerSnapshot{}
+	var snapshot PowerSnapshot
+	if err := snapshots.ForEach(&snapshot, func(i int64) error {
+		all = append(all, snapshot)
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to iterate power snapshots: %w", err)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Epoch < all[j].Epoch })
+	return all, nil
+}
+
+// Returns the latest retained snapshot of network power at or before an epoch, if any.
+func (r *StateReader) PowerSnapshotAt(epoch abi.ChainEpoch) (*PowerSnapshot, bool, error) {
+	snapshots, err := r.PowerSnapshots()
+	if err != nil {
+		return nil, false, err
+	}
+	i := sort.Search(len(snapshots), func(i int) bool { return snapshots[i].Epoch > epoch })
+	if i == 0 {
+		return nil, false, nil
+	}
+	return &snapshots[i-1], true, nil
+}
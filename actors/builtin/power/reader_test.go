@@ -0,0 +1,141 @@
+package power_test
+
+import (
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/power"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestStateReader(t *testing.T) {
+	owner := tutil.NewIDAddr(t, 101)
+	worker := tutil.NewIDAddr(t, 102)
+	miner1 := tutil.NewIDAddr(t, 111)
+	miner2 := tutil.NewIDAddr(t, 112)
+	miner3 := tutil.NewIDAddr(t, 113)
+	miner64 := tutil.NewIDAddr(t, 114)
+	powerUnit, err := builtin.ConsensusMinerMinPower(abi.RegisteredPoStProof_StackedDrgWindow32GiBV1)
+	require.NoError(t, err)
+
+	rt, actor := basicPowerSetup(t)
+	actor.createMinerBasic(rt, owner, worker, miner1)
+	actor.createMinerBasic(rt, owner, worker, miner2)
+	actor.createMinerBasic(rt, owner, worker, miner3)
+	actor.createMiner(rt, owner, worker, miner64, tutil.NewActorAddr(t, "64"), abi.PeerID("64"), nil,
+		abi.RegisteredPoStProof_StackedDrgWindow64GiBV1, big.Zero())
+
+	actor.updateClaimedPower(rt, miner1, powerUnit, big.Mul(powerUnit, big.NewInt(10)))
+	actor.updateClaimedPower(rt, miner2, big.NewInt(1<<30), big.NewInt(1<<30))
+	actor.updateClaimedPower(rt, miner64, powerUnit, powerUnit)
+	actor.enrollCronEvent(rt, miner1, 5, []byte{0x1})
+	actor.enrollCronEvent(rt, miner2, 5, []byte{0x2})
+	actor.enrollCronEvent(rt, miner1, 9, []byte{0x3})
+	rt.SetEpoch(3)
+	actor.onConsensusFault(rt, miner2)
+	rt.SetEpoch(4)
+	actor.onConsensusFault(rt, miner3)
+
+	r, err := power.NewStateReader(rt.AdtStore(), rt.StateRoot())
+	require.NoError(t, err)
+
+	miners := func(claims []power.MinerClaim) []addr.Address {
+		var out []addr.Address
+		for _, c := range claims {
+			out = append(out, c.Miner)
+		}
+		return out
+	}
+
+	t.Run("claim", func(t *testing.T) {
+		claim, found, err := r.Claim(miner1)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, powerUnit, claim.RawBytePower)
+
+		_, found, err = r.Claim(tutil.NewIDAddr(t, 999))
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("claims are paginated", func(t *testing.T) {
+		page, err := r.Claims(addr.Undef, 3)
+		require.NoError(t, err)
+		assert.Equal(t, []addr.Address{miner1, miner2, miner3}, miners(page.Claims))
+		assert.Equal(t, powerUnit, page.Claims[0].Claim.RawBytePower)
+		require.True(t, page.More)
+		assert.Equal(t, miner64, page.Next)
+
+		page, err = r.Claims(page.Next, 3)
+		require.NoError(t, err)
+		assert.Equal(t, []addr.Address{miner64}, miners(page.Claims))
+		assert.False(t, page.More)
+
+		// A cursor need not be the address of a miner.
+		page, err = r.Claims(tutil.NewIDAddr(t, 112), 10)
+		require.NoError(t, err)
+		assert.Equal(t, []addr.Address{miner2, miner3, miner64}, miners(page.Claims))
+
+		_, err = r.Claims(addr.Undef, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("power by proof type", func(t *testing.T) {
+		byType, err := r.PowerByProofType()
+		require.NoError(t, err)
+		assert.Equal(t, map[abi.RegisteredPoStProof]power.ProofTypePower{
+			abi.RegisteredPoStProof_StackedDrgWindow32GiBV1: {
+				MinerCount:              3,
+				MinerAboveMinPowerCount: 1,
+				RawBytePower:            big.Add(powerUnit, big.NewInt(1<<30)),
+				QualityAdjPower:         big.Add(big.Mul(powerUnit, big.NewInt(10)), big.NewInt(1<<30)),
+			},
+			abi.RegisteredPoStProof_StackedDrgWindow64GiBV1: {
+				MinerCount:              1,
+				MinerAboveMinPowerCount: 1,
+				RawBytePower:            powerUnit,
+				QualityAdjPower:         powerUnit,
+			},
+		}, byType)
+	})
+
+	t.Run("consensus faults", func(t *testing.T) {
+		claim, _, err := r.Claim(miner3)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), claim.ConsensusFaultCount)
+		assert.Equal(t, abi.ChainEpoch(4), claim.LastConsensusFaultEpoch)
+
+		faulted, err := r.ConsensusFaultsSince(0)
+		require.NoError(t, err)
+		assert.Equal(t, []addr.Address{miner2, miner3}, miners(faulted))
+
+		faulted, err = r.ConsensusFaultsSince(4)
+		require.NoError(t, err)
+		assert.Equal(t, []addr.Address{miner3}, miners(faulted))
+	})
+
+	t.Run("cron events", func(t *testing.T) {
+		queue, err := r.CronEvents(0, 100)
+		require.NoError(t, err)
+		assert.Equal(t, []power.EpochCronEvents{
+			{Epoch: 5, Events: []power.CronEvent{
+				{MinerAddr: miner1, CallbackPayload: []byte{0x1}},
+				{MinerAddr: miner2, CallbackPayload: []byte{0x2}},
+			}},
+			{Epoch: 9, Events: []power.CronEvent{{MinerAddr: miner1, CallbackPayload: []byte{0x3}}}},
+		}, queue)
+
+		queue, err = r.CronEvents(6, 9)
+		require.NoError(t, err)
+		assert.Empty(t, queue)
+
+		_, err = r.CronEvents(10, 5)
+		assert.Error(t, err)
+	})
+}
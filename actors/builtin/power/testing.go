@@ -11,8 +11,9 @@ import (
 )
 
 type MinerCronEvent struct {
-	Epoch   abi.ChainEpoch
-	Payload []byte
+	Epoch    abi.ChainEpoch
+	Payload  []byte
+	Priority bool
 }
 
 type CronEventsByAddress map[address.Address][]MinerCronEvent
@@ -47,6 +48,7 @@ func CheckStateInvariants(st *State, store adt.Store) (*StateSummary, *builtin.M
 	crons := CheckCronInvariants(st, store, acc)
 	claims := CheckClaimInvariants(st, store, acc)
 	proofs := CheckProofValidationInvariants(st, store, claims, acc)
+	CheckPowerSnapshotInvariants(st, store, acc)
 
 	return &StateSummary{
 		Crons:  crons,
@@ -71,14 +73,19 @@ func CheckCronInvariants(st *State, store adt.Store, acc *builtin.MessageAccumul
 			return nil // error noted above
 		}
 
+		// Events enrolled or deferred are never stored at an epoch that cron has already run.
 		acc.Require(abi.ChainEpoch(epoch) >= st.FirstCronEpoch, "cron event at epoch %d before FirstCronEpoch %d",
 			epoch, st.FirstCronEpoch)
 
+		// Epochs from which all events have been invoked must be removed, including those with deferred events.
+		acc.Require(arr.Length() > 0, "empty cron event array at epoch %d", epoch)
+
 		var event CronEvent
 		return arr.ForEach(&event, func(i int64) error {
 			byAddress[event.MinerAddr] = append(byAddress[event.MinerAddr], MinerCronEvent{
-				Epoch:   abi.ChainEpoch(epoch),
-				Payload: event.CallbackPayload,
+				Epoch:    abi.ChainEpoch(epoch),
+				Payload:  event.CallbackPayload,
+				Priority: event.Priority,
 			})
 
 			return nil
@@ -187,3 +194,27 @@ func CheckProofValidationInvariants(st *State, store adt.Store, claims ClaimsByA
 	}
 	return proofs
 }
+
+func CheckPowerSnapshotInvariants(st *State, store adt.Store, acc *builtin.MessageAccumulator) {
+	snapshots, err := adt.AsArray(store, st.PowerSnapshots, PowerSnapshotsAmtBitwidth)
+	if err != nil {
+		acc.Addf("error loading power snapshots: %v", err)
+		return
+	}
+	acc.Require(snapshots.Length() <= PowerSnapshotCount(), "%d power snapshots exceed the limit of %d",
+		snapshots.Length(), PowerSnapshotCount())
+
+	var snapshot PowerSnapshot
+	err = snapshots.ForEach(&snapshot, func(i int64) error {
+		acc.Require(snapshot.Epoch >= 0, "power snapshot %d at negative epoch %d", i, snapshot.Epoch)
+		index := uint64(snapshot.Epoch/PowerSnapshotInterval()) % PowerSnapshotCount()
+		acc.Require(uint64(i) == index, "power snapshot at epoch %d stored at index %d, expected %d", snapshot.Epoch, i, index)
+		acc.Require(snapshot.RawBytePower.GreaterThanEqual(big.Zero()), "power snapshot at epoch %d has negative raw power %v",
+			snapshot.Epoch, snapshot.RawBytePower)
+		acc.Require(snapshot.RawBytePower.LessThanEqual(snapshot.QualityAdjPower),
+			"power snapshot at epoch %d has raw power %v greater than quality adjusted power %v",
+			snapshot.Epoch, snapshot.RawBytePower, snapshot.QualityAdjPower)
+		return nil
+	})
+	acc.RequireNoError(err, "error iterating power snapshots")
+}
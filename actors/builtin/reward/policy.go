@@ -0,0 +1,106 @@
+package reward
+
+import (
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/util/math"
+)
+
+// An emission schedule for storage mining rewards.
+// The reward for an epoch is the sum of a simple component, minted on a schedule of epochs alone, and a baseline
+// component, minted as the network's effective network time advances by realizing its baseline power.
+// Implementations must be deterministic, since every node computes the same rewards.
+type RewardCurve interface {
+	// Returns the baseline power at epoch 0.
+	InitialBaselinePower() abi.StoragePower
+	// Returns the baseline power at epoch -1, from which the baseline power at epoch 0 is computed.
+	InitBaselinePower() abi.StoragePower
+	// Returns the baseline power at an epoch from the baseline power at the previous epoch.
+	BaselinePowerFromPrev(prevEpochBaselinePower abi.StoragePower) abi.StoragePower
+	// Returns the simple reward for all expected leaders at an epoch, in Q.128 format.
+	SimpleReward(epoch abi.ChainEpoch, simpleTotal abi.TokenAmount) big.Int
+	// Returns the total baseline reward minted by an effective network time theta, in Q.128 format.
+	// Theta is in Q.128 format.
+	BaselineSupply(theta big.Int, baselineTotal abi.TokenAmount) big.Int
+}
+
+// A reward curve with exponentially growing baseline power and exponentially decaying minting.
+// The simple reward at epoch t is simpleTotal * (e^lambda - 1) * e^(-lambda * t), and the baseline supply at
+// effective network time theta is baselineTotal * (1 - e^(-lambda * theta)).
+type ExponentialCurve struct {
+	// Baseline power at epoch 0 (Q.0).
+	BaselineInitialValue abi.StoragePower
+	// Per-epoch growth factor of baseline power (Q.128).
+	BaselineExponent big.Int
+	// Per-epoch decay rate of minting (Q.128).
+	Lambda big.Int
+	// e^Lambda - 1 (Q.128).
+	ExpLamSubOne big.Int
+}
+
+var _ RewardCurve = ExponentialCurve{}
+
+// The mainnet reward curve: baseline power doubling each year, and minting halving every six years.
+var MainnetRewardCurve = ExponentialCurve{
+	BaselineInitialValue: BaselineInitialValue,
+	BaselineExponent:     BaselineExponent,
+	Lambda:               Lambda,
+	ExpLamSubOne:         ExpLamSubOne,
+}
+
+func (c ExponentialCurve) InitialBaselinePower() abi.StoragePower {
+	return c.BaselineInitialValue
+}
+
+func (c ExponentialCurve) InitBaselinePower() abi.StoragePower {
+	baselineInitialValue256 := big.Lsh(c.BaselineInitialValue, 2*math.Precision128) // Q.0 => Q.256
+	baselineAtMinusOne := big.Div(baselineInitialValue256, c.BaselineExponent)      // Q.256 / Q.128 => Q.128
+	return big.Rsh(baselineAtMinusOne, math.Precision128)                           // Q.128 => Q.0
+}
+
+// Multiplies the previous baseline power by the base exponent.
+func (c ExponentialCurve) BaselinePowerFromPrev(prevEpochBaselinePower abi.StoragePower) abi.StoragePower {
+	thisEpochBaselinePower := big.Mul(prevEpochBaselinePower, c.BaselineExponent) // Q.0 * Q.128 => Q.128
+	return big.Rsh(thisEpochBaselinePower, math.Precision128)                     // Q.128 => Q.0
+}
+
+func (c ExponentialCurve) SimpleReward(epoch abi.ChainEpoch, simpleTotal abi.TokenAmount) big.Int {
+	simpleReward := big.Mul(simpleTotal, c.ExpLamSubOne)    // Q.0 * Q.128 =>  Q.128
+	epochLam := big.Mul(big.NewInt(int64(epoch)), c.Lambda) // Q.0 * Q.128 => Q.128
+
+	simpleReward = big.Mul(simpleReward, big.NewFromGo(math.ExpNeg(epochLam.Int))) // Q.128 * Q.128 => Q.256
+	return big.Rsh(simpleReward, math.Precision128)                                // Q.256 >> 128 => Q.128
+}
+
+func (c ExponentialCurve) BaselineSupply(theta big.Int, baselineTotal abi.TokenAmount) big.Int {
+	thetaLam := big.Mul(theta, c.Lambda)            // Q.128 * Q.128 => Q.256
+	thetaLam = big.Rsh(thetaLam, math.Precision128) // Q.256 >> 128 => Q.128
+
+	eTL := big.NewFromGo(math.ExpNeg(thetaLam.Int)) // Q.128
+
+	one := big.NewInt(1)
+	one = big.Lsh(one, math.Precision128) // Q.0 => Q.128
+	oneSub := big.Sub(one, eTL)           // Q.128
+
+	return big.Mul(baselineTotal, oneSub) // Q.0 * Q.128 => Q.128
+}
+
+type Policy struct {
+	// The emission schedule by which rewards are computed.
+	//
+	// Mainnet uses MainnetRewardCurve. Test networks and simulations may substitute another schedule; changing it
+	// on a running network changes the baseline power and reward from the next epoch, but not the state's
+	// cumulative baseline and realized power.
+	Curve RewardCurve
+}
+
+var DefaultRewardPolicy = Policy{
+	MainnetRewardCurve,
+}
+
+var CurrentRewardPolicy = DefaultRewardPolicy
+
+func CurrentRewardCurve() RewardCurve {
+	return CurrentRewardPolicy.Curve
+}
@@ -21,18 +21,14 @@ var BaselineExponent = big.MustFromString("3402825912986410784659641899263134736
 var BaselineInitialValue = big.NewInt(2_888_888_880_000_000_000) // Q.0
 
 // Initialize baseline power for epoch -1 so that baseline power at epoch 0 is
-// BaselineInitialValue.
+// the current reward curve's initial baseline power.
 func InitBaselinePower() abi.StoragePower {
-	baselineInitialValue256 := big.Lsh(BaselineInitialValue, 2*math.Precision128) // Q.0 => Q.256
-	baselineAtMinusOne := big.Div(baselineInitialValue256, BaselineExponent)      // Q.256 / Q.128 => Q.128
-	return big.Rsh(baselineAtMinusOne, math.Precision128)                         // Q.128 => Q.0
+	return CurrentRewardCurve().InitBaselinePower()
 }
 
-// Compute BaselinePower(t) from BaselinePower(t-1) with an additional multiplication
-// of the base exponent.
+// Compute BaselinePower(t) from BaselinePower(t-1) under the current reward curve.
 func BaselinePowerFromPrev(prevEpochBaselinePower abi.StoragePower) abi.StoragePower {
-	thisEpochBaselinePower := big.Mul(prevEpochBaselinePower, BaselineExponent) // Q.0 * Q.128 => Q.128
-	return big.Rsh(thisEpochBaselinePower, math.Precision128)                   // Q.128 => Q.0
+	return CurrentRewardCurve().BaselinePowerFromPrev(prevEpochBaselinePower)
 }
 
 // These numbers are estimates of the onchain constants.  They are good for initializing state in
@@ -76,35 +72,16 @@ var (
 
 // Computes a reward for all expected leaders when effective network time changes from prevTheta to currTheta
 // Inputs are in Q.128 format
-func computeReward(epoch abi.ChainEpoch, prevTheta, currTheta, simpleTotal, baselineTotal big.Int) abi.TokenAmount {
-	simpleReward := big.Mul(simpleTotal, ExpLamSubOne)    //Q.0 * Q.128 =>  Q.128
-	epochLam := big.Mul(big.NewInt(int64(epoch)), Lambda) // Q.0 * Q.128 => Q.128
+func computeReward(curve RewardCurve, epoch abi.ChainEpoch, prevTheta, currTheta, simpleTotal, baselineTotal big.Int) abi.TokenAmount {
+	simpleReward := curve.SimpleReward(epoch, simpleTotal) // Q.128
 
-	simpleReward = big.Mul(simpleReward, big.NewFromGo(math.ExpNeg(epochLam.Int))) // Q.128 * Q.128 => Q.256
-	simpleReward = big.Rsh(simpleReward, math.Precision128)                        // Q.256 >> 128 => Q.128
-
-	baselineReward := big.Sub(computeBaselineSupply(currTheta, baselineTotal), computeBaselineSupply(prevTheta, baselineTotal)) // Q.128
+	baselineReward := big.Sub(curve.BaselineSupply(currTheta, baselineTotal), curve.BaselineSupply(prevTheta, baselineTotal)) // Q.128
 
 	reward := big.Add(simpleReward, baselineReward) // Q.128
 
 	return big.Rsh(reward, math.Precision128) // Q.128 => Q.0
 }
 
-// Computes baseline supply based on theta in Q.128 format.
-// Return is in Q.128 format
-func computeBaselineSupply(theta, baselineTotal big.Int) big.Int {
-	thetaLam := big.Mul(theta, Lambda)              // Q.128 * Q.128 => Q.256
-	thetaLam = big.Rsh(thetaLam, math.Precision128) // Q.256 >> 128 => Q.128
-
-	eTL := big.NewFromGo(math.ExpNeg(thetaLam.Int)) // Q.128
-
-	one := big.NewInt(1)
-	one = big.Lsh(one, math.Precision128) // Q.0 => Q.128
-	oneSub := big.Sub(one, eTL)           // Q.128
-
-	return big.Mul(baselineTotal, oneSub) // Q.0 * Q.128 => Q.128
-}
-
 // SlowConvenientBaselineForEpoch computes baseline power for use in epoch t
 // by calculating the value of ThisEpochBaselinePower that shows up in block at t - 1
 // It multiplies ~t times so it should not be used in actor code directly.  It is exported as
@@ -54,10 +54,10 @@ func TestBaselineReward(t *testing.T) {
 
 	b := &bytes.Buffer{}
 	b.WriteString("t0, t1, y\n")
-	simple := computeReward(0, big.Zero(), big.Zero(), DefaultSimpleTotal, DefaultBaselineTotal)
+	simple := computeReward(MainnetRewardCurve, 0, big.Zero(), big.Zero(), DefaultSimpleTotal, DefaultBaselineTotal)
 
 	for i := 0; i < 512; i++ {
-		reward := computeReward(0, big.NewFromGo(prevTheta), big.NewFromGo(theta), DefaultSimpleTotal, DefaultBaselineTotal)
+		reward := computeReward(MainnetRewardCurve, 0, big.NewFromGo(prevTheta), big.NewFromGo(theta), DefaultSimpleTotal, DefaultBaselineTotal)
 		reward = big.Sub(reward, simple)
 		fmt.Fprintf(b, "%s,%s,%s\n", prevTheta, theta, reward.Int)
 		prevTheta = prevTheta.Add(prevTheta, step)
@@ -72,13 +72,39 @@ func TestSimpleReward(t *testing.T) {
 	b.WriteString("x, y\n")
 	for i := int64(0); i < 512; i++ {
 		x := i * 5000
-		reward := computeReward(abi.ChainEpoch(x), big.Zero(), big.Zero(), DefaultSimpleTotal, DefaultBaselineTotal)
+		reward := computeReward(MainnetRewardCurve, abi.ChainEpoch(x), big.Zero(), big.Zero(), DefaultSimpleTotal, DefaultBaselineTotal)
 		fmt.Fprintf(b, "%d,%s\n", x, reward.Int)
 	}
 
 	golden.Assert(t, b.Bytes())
 }
 
+func TestBaselinePower(t *testing.T) {
+	b := &bytes.Buffer{}
+	b.WriteString("x, y\n")
+	baseline := MainnetRewardCurve.BaselinePowerFromPrev(MainnetRewardCurve.InitBaselinePower()) // epoch 0
+	for i := int64(0); i < 256; i++ {
+		x := i * 10000
+		fmt.Fprintf(b, "%d,%s\n", x, baseline.Int)
+		for j := 0; j < 10000; j++ {
+			baseline = MainnetRewardCurve.BaselinePowerFromPrev(baseline)
+		}
+	}
+
+	golden.Assert(t, b.Bytes())
+}
+
+func TestDefaultRewardCurve(t *testing.T) {
+	// The actor computes mainnet rewards unless a network's policy substitutes another curve.
+	assert.Equal(t, MainnetRewardCurve, DefaultRewardPolicy.Curve)
+	assert.Equal(t, BaselineInitialValue, MainnetRewardCurve.InitialBaselinePower())
+
+	// The simple reward at epoch 0 is InitialRewardPositionEstimate, less rounding.
+	simple := big.Rsh(MainnetRewardCurve.SimpleReward(0, DefaultSimpleTotal), math.Precision128)
+	assert.True(t, big.Sub(InitialRewardPositionEstimate, simple).LessThanEqual(big.NewInt(1)), "simple reward %v", simple)
+	assert.Equal(t, big.Zero(), MainnetRewardCurve.BaselineSupply(big.Zero(), DefaultBaselineTotal))
+}
+
 func TestBaselineRewardGrowth(t *testing.T) {
 
 	baselineInYears := func(start abi.StoragePower, x abi.ChainEpoch) abi.StoragePower {
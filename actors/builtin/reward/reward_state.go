@@ -65,14 +65,15 @@ type State struct {
 }
 
 func ConstructState(currRealizedPower abi.StoragePower) *State {
+	curve := CurrentRewardCurve()
 	st := &State{
 		CumsumBaseline:         big.Zero(),
 		CumsumRealized:         big.Zero(),
 		EffectiveNetworkTime:   0,
-		EffectiveBaselinePower: BaselineInitialValue,
+		EffectiveBaselinePower: curve.InitialBaselinePower(),
 
 		ThisEpochReward:        big.Zero(),
-		ThisEpochBaselinePower: InitBaselinePower(),
+		ThisEpochBaselinePower: curve.InitBaselinePower(),
 		Epoch:                  -1,
 
 		ThisEpochRewardSmoothed: smoothing.NewEstimate(InitialRewardPositionEstimate, InitialRewardVelocityEstimate),
@@ -109,7 +110,7 @@ func (st *State) updateToNextEpochWithReward(currRealizedPower abi.StoragePower)
 	st.updateToNextEpoch(currRealizedPower)
 	currRewardTheta := ComputeRTheta(st.EffectiveNetworkTime, st.EffectiveBaselinePower, st.CumsumRealized, st.CumsumBaseline)
 
-	st.ThisEpochReward = computeReward(st.Epoch, prevRewardTheta, currRewardTheta, st.SimpleTotal, st.BaselineTotal)
+	st.ThisEpochReward = computeReward(CurrentRewardCurve(), st.Epoch, prevRewardTheta, currRewardTheta, st.SimpleTotal, st.BaselineTotal)
 }
 
 func (st *State) updateSmoothedEstimates(delta abi.ChainEpoch) {
@@ -12,6 +12,7 @@ import (
 
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin/reward"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/math"
 	"github.com/filecoin-project/specs-actors/v7/support/mock"
 	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
 )
@@ -252,6 +253,79 @@ func TestSuccessiveKPIUpdates(t *testing.T) {
 
 }
 
+func TestRewardCurvePolicy(t *testing.T) {
+	actor := rewardHarness{reward.Actor{}, t}
+	builder := mock.NewBuilder(builtin.RewardActorAddr).
+		WithCaller(builtin.SystemActorAddr, builtin.SystemActorCodeID)
+	curve := flatCurve{
+		baselinePower:  abi.NewStoragePower(1 << 40),
+		simpleReward:   abi.NewTokenAmount(1000),
+		baselineReward: abi.NewTokenAmount(400),
+	}
+	defer func(policy reward.Policy) { reward.CurrentRewardPolicy = policy }(reward.CurrentRewardPolicy)
+	reward.CurrentRewardPolicy.Curve = curve
+
+	t.Run("computes baseline power and reward with the policy's curve", func(t *testing.T) {
+		rt := builder.Build(t)
+		power := curve.baselinePower
+		actor.constructAndVerify(rt, &power)
+		st := getState(rt)
+		assert.Equal(t, curve.baselinePower, st.ThisEpochBaselinePower)
+		assert.Equal(t, curve.baselinePower, st.EffectiveBaselinePower)
+		// Realizing the baseline advances effective network time by one epoch.
+		assert.Equal(t, abi.NewTokenAmount(1400), st.ThisEpochReward)
+
+		rt.SetEpoch(1)
+		actor.updateNetworkKPI(rt, &power)
+		st = getState(rt)
+		assert.Equal(t, curve.baselinePower, st.ThisEpochBaselinePower)
+		assert.Equal(t, abi.NewTokenAmount(1400), st.ThisEpochReward)
+	})
+
+	t.Run("baseline reward follows realized power", func(t *testing.T) {
+		rt := builder.Build(t)
+		power := big.Div(curve.baselinePower, big.NewInt(2))
+		actor.constructAndVerify(rt, &power)
+		st := getState(rt)
+		assert.Equal(t, abi.NewTokenAmount(1200), st.ThisEpochReward)
+
+		// Realized power is capped at the baseline.
+		power = big.Mul(curve.baselinePower, big.NewInt(2))
+		rt.SetEpoch(1)
+		actor.updateNetworkKPI(rt, &power)
+		st = getState(rt)
+		assert.Equal(t, abi.NewTokenAmount(1400), st.ThisEpochReward)
+	})
+}
+
+// A reward curve with constant baseline power, a constant simple reward, and a baseline supply linear in
+// effective network time.
+type flatCurve struct {
+	baselinePower  abi.StoragePower
+	simpleReward   abi.TokenAmount
+	baselineReward abi.TokenAmount
+}
+
+func (c flatCurve) InitialBaselinePower() abi.StoragePower {
+	return c.baselinePower
+}
+
+func (c flatCurve) InitBaselinePower() abi.StoragePower {
+	return c.baselinePower
+}
+
+func (c flatCurve) BaselinePowerFromPrev(prevEpochBaselinePower abi.StoragePower) abi.StoragePower {
+	return prevEpochBaselinePower
+}
+
+func (c flatCurve) SimpleReward(_ abi.ChainEpoch, _ abi.TokenAmount) big.Int {
+	return big.Lsh(c.simpleReward, math.Precision128)
+}
+
+func (c flatCurve) BaselineSupply(theta big.Int, _ abi.TokenAmount) big.Int {
+	return big.Mul(theta, c.baselineReward)
+}
+
 type rewardHarness struct {
 	reward.Actor
 	t testing.TB
@@ -7,7 +7,12 @@ import (
 
 // Policy values associated with a seal proof type.
 type SealProofPolicy struct {
+	// Maximum duration between a sector's activation and its expiration.
+	// A sector's activation is reset when it is re-committed.
 	SectorMaxLifetime stabi.ChainEpoch
+	// Maximum duration for which a proof of replication is trusted: a sector's expiration may be set no further
+	// than this beyond the epoch at which it is committed, re-committed or extended.
+	SectorMaxCommitment stabi.ChainEpoch
 }
 
 // For V1 Stacked DRG sectors, the max is 540 days since Network Version 11
@@ -25,35 +30,45 @@ func EpochsInFiveYears() stabi.ChainEpoch {
 func SealProofPoliciesV11() map[stabi.RegisteredSealProof]*SealProofPolicy {
 	return map[stabi.RegisteredSealProof]*SealProofPolicy{
 		stabi.RegisteredSealProof_StackedDrg2KiBV1: {
-			SectorMaxLifetime: EpochsIn540Days(),
+			SectorMaxLifetime:   EpochsIn540Days(),
+			SectorMaxCommitment: EpochsIn540Days(),
 		},
 		stabi.RegisteredSealProof_StackedDrg8MiBV1: {
-			SectorMaxLifetime: EpochsIn540Days(),
+			SectorMaxLifetime:   EpochsIn540Days(),
+			SectorMaxCommitment: EpochsIn540Days(),
 		},
 		stabi.RegisteredSealProof_StackedDrg512MiBV1: {
-			SectorMaxLifetime: EpochsIn540Days(),
+			SectorMaxLifetime:   EpochsIn540Days(),
+			SectorMaxCommitment: EpochsIn540Days(),
 		},
 		stabi.RegisteredSealProof_StackedDrg32GiBV1: {
-			SectorMaxLifetime: EpochsIn540Days(),
+			SectorMaxLifetime:   EpochsIn540Days(),
+			SectorMaxCommitment: EpochsIn540Days(),
 		},
 		stabi.RegisteredSealProof_StackedDrg64GiBV1: {
-			SectorMaxLifetime: EpochsIn540Days(),
+			SectorMaxLifetime:   EpochsIn540Days(),
+			SectorMaxCommitment: EpochsIn540Days(),
 		},
 
 		stabi.RegisteredSealProof_StackedDrg2KiBV1_1: {
-			SectorMaxLifetime: EpochsInFiveYears(),
+			SectorMaxLifetime:   EpochsInFiveYears(),
+			SectorMaxCommitment: EpochsIn540Days(),
 		},
 		stabi.RegisteredSealProof_StackedDrg8MiBV1_1: {
-			SectorMaxLifetime: EpochsInFiveYears(),
+			SectorMaxLifetime:   EpochsInFiveYears(),
+			SectorMaxCommitment: EpochsIn540Days(),
 		},
 		stabi.RegisteredSealProof_StackedDrg512MiBV1_1: {
-			SectorMaxLifetime: EpochsInFiveYears(),
+			SectorMaxLifetime:   EpochsInFiveYears(),
+			SectorMaxCommitment: EpochsIn540Days(),
 		},
 		stabi.RegisteredSealProof_StackedDrg32GiBV1_1: {
-			SectorMaxLifetime: EpochsInFiveYears(),
+			SectorMaxLifetime:   EpochsInFiveYears(),
+			SectorMaxCommitment: EpochsIn540Days(),
 		},
 		stabi.RegisteredSealProof_StackedDrg64GiBV1_1: {
-			SectorMaxLifetime: EpochsInFiveYears(),
+			SectorMaxLifetime:   EpochsInFiveYears(),
+			SectorMaxCommitment: EpochsIn540Days(),
 		},
 	}
 }
@@ -77,6 +92,16 @@ func SealProofSectorMaximumLifetime(p stabi.RegisteredSealProof) (stabi.ChainEpo
 	return info.SectorMaxLifetime, nil
 }
 
+// SealProofSectorMaximumCommitment is the maximum duration beyond the current epoch to which a sector sealed with
+// this proof may commit, when it is committed, re-committed or extended.
+func SealProofSectorMaximumCommitment(p stabi.RegisteredSealProof) (stabi.ChainEpoch, error) {
+	info, ok := SealProofPoliciesV11()[p]
+	if !ok {
+		return 0, xerrors.Errorf("unsupported proof type: %v", p)
+	}
+	return info.SectorMaxCommitment, nil
+}
+
 // The minimum power of an individual miner to meet the threshold for leader election (in bytes).
 // Motivation:
 // - Limits sybil generation
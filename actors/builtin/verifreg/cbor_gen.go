@@ -6,13 +6,15 @@ import (
 	"fmt"
 	"io"
 
+	address "github.com/filecoin-project/go-address"
+	abi "github.com/filecoin-project/go-state-types/abi"
 	cbg "github.com/whyrusleeping/cbor-gen"
 	xerrors "golang.org/x/xerrors"
 )
 
 var _ = xerrors.Errorf
 
-var lengthBufState = []byte{131}
+var lengthBufState = []byte{138}
 
 func (t *State) MarshalCBOR(w io.Writer) error {
 	if t == nil {
@@ -36,12 +38,54 @@ func (t *State) MarshalCBOR(w io.Writer) error {
 		return xerrors.Errorf("failed to write cid field t.Verifiers: %w", err)
 	}
 
+	// t.VerifierSubKeys (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.VerifierSubKeys); err != nil {
+		return xerrors.Errorf("failed to write cid field t.VerifierSubKeys: %w", err)
+	}
+
 	// t.VerifiedClients (cid.Cid) (struct)
 
 	if err := cbg.WriteCidBuf(scratch, w, t.VerifiedClients); err != nil {
 		return xerrors.Errorf("failed to write cid field t.VerifiedClients: %w", err)
 	}
 
+	// t.ClientExpirations (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.ClientExpirations); err != nil {
+		return xerrors.Errorf("failed to write cid field t.ClientExpirations: %w", err)
+	}
+
+	// t.RemoveDataCapProposalIDs (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.RemoveDataCapProposalIDs); err != nil {
+		return xerrors.Errorf("failed to write cid field t.RemoveDataCapProposalIDs: %w", err)
+	}
+
+	// t.VerifierAllocations (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.VerifierAllocations); err != nil {
+		return xerrors.Errorf("failed to write cid field t.VerifierAllocations: %w", err)
+	}
+
+	// t.GrantEvents (cid.Cid) (struct)
+
+	if err := cbg.WriteCidBuf(scratch, w, t.GrantEvents); err != nil {
+		return xerrors.Errorf("failed to write cid field t.GrantEvents: %w", err)
+	}
+
+	// t.FirstGrantEvent (uint64) (uint64)
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.FirstGrantEvent)); err != nil {
+		return err
+	}
+
+	// t.NextGrantEvent (uint64) (uint64)
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.NextGrantEvent)); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -59,7 +103,7 @@ func (t *State) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 3 {
+	if extra != 10 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
@@ -83,6 +127,18 @@ func (t *State) UnmarshalCBOR(r io.Reader) error {
 
 		t.Verifiers = c
 
+	}
+	// t.VerifierSubKeys (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.VerifierSubKeys: %w", err)
+		}
+
+		t.VerifierSubKeys = c
+
 	}
 	// t.VerifiedClients (cid.Cid) (struct)
 
@@ -95,6 +151,991 @@ func (t *State) UnmarshalCBOR(r io.Reader) error {
 
 		t.VerifiedClients = c
 
+	}
+	// t.ClientExpirations (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.ClientExpirations: %w", err)
+		}
+
+		t.ClientExpirations = c
+
+	}
+	// t.RemoveDataCapProposalIDs (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.RemoveDataCapProposalIDs: %w", err)
+		}
+
+		t.RemoveDataCapProposalIDs = c
+
+	}
+	// t.VerifierAllocations (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.VerifierAllocations: %w", err)
+		}
+
+		t.VerifierAllocations = c
+
+	}
+	// t.GrantEvents (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.GrantEvents: %w", err)
+		}
+
+		t.GrantEvents = c
+
+	}
+	// t.FirstGrantEvent (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.FirstGrantEvent = uint64(extra)
+
+	}
+	// t.NextGrantEvent (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.NextGrantEvent = uint64(extra)
+
+	}
+	return nil
+}
+
+var lengthBufRemoveDataCapParams = []byte{133}
+
+func (t *RemoveDataCapParams) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufRemoveDataCapParams); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.VerifiedClientToRemove (address.Address) (struct)
+	if err := t.VerifiedClientToRemove.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.DataCapAmountToRemove (big.Int) (struct)
+	if err := t.DataCapAmountToRemove.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Expiration (abi.ChainEpoch) (int64)
+	if t.Expiration >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.Expiration)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.Expiration-1)); err != nil {
+			return err
+		}
+	}
+
+	// t.VerifierRequest1 (verifreg.RemoveDataCapRequest) (struct)
+	if err := t.VerifierRequest1.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.VerifierRequest2 (verifreg.RemoveDataCapRequest) (struct)
+	if err := t.VerifierRequest2.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *RemoveDataCapParams) UnmarshalCBOR(r io.Reader) error {
+	*t = RemoveDataCapParams{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 5 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.VerifiedClientToRemove (address.Address) (struct)
+
+	{
+
+		if err := t.VerifiedClientToRemove.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.VerifiedClientToRemove: %w", err)
+		}
+
+	}
+	// t.DataCapAmountToRemove (big.Int) (struct)
+
+	{
+
+		if err := t.DataCapAmountToRemove.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.DataCapAmountToRemove: %w", err)
+		}
+
+	}
+	// t.Expiration (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.Expiration = abi.ChainEpoch(extraI)
+	}
+	// t.VerifierRequest1 (verifreg.RemoveDataCapRequest) (struct)
+
+	{
+
+		if err := t.VerifierRequest1.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.VerifierRequest1: %w", err)
+		}
+
+	}
+	// t.VerifierRequest2 (verifreg.RemoveDataCapRequest) (struct)
+
+	{
+
+		if err := t.VerifierRequest2.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.VerifierRequest2: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufRemoveDataCapReturn = []byte{130}
+
+func (t *RemoveDataCapReturn) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufRemoveDataCapReturn); err != nil {
+		return err
+	}
+
+	// t.VerifiedClient (address.Address) (struct)
+	if err := t.VerifiedClient.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.DataCapRemoved (big.Int) (struct)
+	if err := t.DataCapRemoved.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *RemoveDataCapReturn) UnmarshalCBOR(r io.Reader) error {
+	*t = RemoveDataCapReturn{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.VerifiedClient (address.Address) (struct)
+
+	{
+
+		if err := t.VerifiedClient.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.VerifiedClient: %w", err)
+		}
+
+	}
+	// t.DataCapRemoved (big.Int) (struct)
+
+	{
+
+		if err := t.DataCapRemoved.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.DataCapRemoved: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufRemoveExpiredDataCapParams = []byte{129}
+
+func (t *RemoveExpiredDataCapParams) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufRemoveExpiredDataCapParams); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.VerifiedClients ([]address.Address) (slice)
+	if len(t.VerifiedClients) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.VerifiedClients was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, uint64(len(t.VerifiedClients))); err != nil {
+		return err
+	}
+	for _, v := range t.VerifiedClients {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *RemoveExpiredDataCapParams) UnmarshalCBOR(r io.Reader) error {
+	*t = RemoveExpiredDataCapParams{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.VerifiedClients ([]address.Address) (slice)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.VerifiedClients: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.VerifiedClients = make([]address.Address, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		var v address.Address
+		if err := v.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+		t.VerifiedClients[i] = v
+	}
+
+	return nil
+}
+
+var lengthBufRemoveExpiredDataCapReturn = []byte{130}
+
+func (t *RemoveExpiredDataCapReturn) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufRemoveExpiredDataCapReturn); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.Removed ([]address.Address) (slice)
+	if len(t.Removed) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.Removed was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, uint64(len(t.Removed))); err != nil {
+		return err
+	}
+	for _, v := range t.Removed {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+
+	// t.DataCapRemoved (big.Int) (struct)
+	if err := t.DataCapRemoved.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *RemoveExpiredDataCapReturn) UnmarshalCBOR(r io.Reader) error {
+	*t = RemoveExpiredDataCapReturn{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Removed ([]address.Address) (slice)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.Removed: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.Removed = make([]address.Address, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		var v address.Address
+		if err := v.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+		t.Removed[i] = v
+	}
+
+	// t.DataCapRemoved (big.Int) (struct)
+
+	{
+
+		if err := t.DataCapRemoved.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.DataCapRemoved: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufAddVerifierSubKeyParams = []byte{130}
+
+func (t *AddVerifierSubKeyParams) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufAddVerifierSubKeyParams); err != nil {
+		return err
+	}
+
+	// t.SubKey (address.Address) (struct)
+	if err := t.SubKey.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Cap (big.Int) (struct)
+	if err := t.Cap.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *AddVerifierSubKeyParams) UnmarshalCBOR(r io.Reader) error {
+	*t = AddVerifierSubKeyParams{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.SubKey (address.Address) (struct)
+
+	{
+
+		if err := t.SubKey.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.SubKey: %w", err)
+		}
+
+	}
+	// t.Cap (big.Int) (struct)
+
+	{
+
+		if err := t.Cap.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Cap: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufRemoveDataCapProposal = []byte{132}
+
+func (t *RemoveDataCapProposal) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufRemoveDataCapProposal); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.VerifiedClient (address.Address) (struct)
+	if err := t.VerifiedClient.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.DataCapAmount (big.Int) (struct)
+	if err := t.DataCapAmount.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.RemovalProposalID (verifreg.RmDcProposalID) (struct)
+	if err := t.RemovalProposalID.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Expiration (abi.ChainEpoch) (int64)
+	if t.Expiration >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.Expiration)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.Expiration-1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *RemoveDataCapProposal) UnmarshalCBOR(r io.Reader) error {
+	*t = RemoveDataCapProposal{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 4 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.VerifiedClient (address.Address) (struct)
+
+	{
+
+		if err := t.VerifiedClient.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.VerifiedClient: %w", err)
+		}
+
+	}
+	// t.DataCapAmount (big.Int) (struct)
+
+	{
+
+		if err := t.DataCapAmount.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.DataCapAmount: %w", err)
+		}
+
+	}
+	// t.RemovalProposalID (verifreg.RmDcProposalID) (struct)
+
+	{
+
+		if err := t.RemovalProposalID.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.RemovalProposalID: %w", err)
+		}
+
+	}
+	// t.Expiration (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.Expiration = abi.ChainEpoch(extraI)
+	}
+	return nil
+}
+
+var lengthBufRemoveDataCapRequest = []byte{130}
+
+func (t *RemoveDataCapRequest) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufRemoveDataCapRequest); err != nil {
+		return err
+	}
+
+	// t.Verifier (address.Address) (struct)
+	if err := t.Verifier.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.VerifierSignature (crypto.Signature) (struct)
+	if err := t.VerifierSignature.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *RemoveDataCapRequest) UnmarshalCBOR(r io.Reader) error {
+	*t = RemoveDataCapRequest{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Verifier (address.Address) (struct)
+
+	{
+
+		if err := t.Verifier.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Verifier: %w", err)
+		}
+
+	}
+	// t.VerifierSignature (crypto.Signature) (struct)
+
+	{
+
+		if err := t.VerifierSignature.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.VerifierSignature: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufRmDcProposalID = []byte{129}
+
+func (t *RmDcProposalID) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufRmDcProposalID); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.ProposalID (uint64) (uint64)
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.ProposalID)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *RmDcProposalID) UnmarshalCBOR(r io.Reader) error {
+	*t = RmDcProposalID{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.ProposalID (uint64) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.ProposalID = uint64(extra)
+
+	}
+	return nil
+}
+
+var lengthBufAddrPairKey = []byte{130}
+
+func (t *AddrPairKey) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufAddrPairKey); err != nil {
+		return err
+	}
+
+	// t.First (address.Address) (struct)
+	if err := t.First.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Second (address.Address) (struct)
+	if err := t.Second.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *AddrPairKey) UnmarshalCBOR(r io.Reader) error {
+	*t = AddrPairKey{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.First (address.Address) (struct)
+
+	{
+
+		if err := t.First.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.First: %w", err)
+		}
+
+	}
+	// t.Second (address.Address) (struct)
+
+	{
+
+		if err := t.Second.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Second: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufGrantEvent = []byte{133}
+
+func (t *GrantEvent) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufGrantEvent); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.Epoch (abi.ChainEpoch) (int64)
+	if t.Epoch >= 0 {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajUnsignedInt, uint64(t.Epoch)); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajNegativeInt, uint64(-t.Epoch-1)); err != nil {
+			return err
+		}
+	}
+
+	// t.Verifier (address.Address) (struct)
+	if err := t.Verifier.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Granter (address.Address) (struct)
+	if err := t.Granter.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Client (address.Address) (struct)
+	if err := t.Client.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Amount (big.Int) (struct)
+	if err := t.Amount.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *GrantEvent) UnmarshalCBOR(r io.Reader) error {
+	*t = GrantEvent{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 5 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Epoch (abi.ChainEpoch) (int64)
+	{
+		maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+		var extraI int64
+		if err != nil {
+			return err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 positive overflow")
+			}
+		case cbg.MajNegativeInt:
+			extraI = int64(extra)
+			if extraI < 0 {
+				return fmt.Errorf("int64 negative oveflow")
+			}
+			extraI = -1 - extraI
+		default:
+			return fmt.Errorf("wrong type for int64 field: %d", maj)
+		}
+
+		t.Epoch = abi.ChainEpoch(extraI)
+	}
+	// t.Verifier (address.Address) (struct)
+
+	{
+
+		if err := t.Verifier.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Verifier: %w", err)
+		}
+
+	}
+	// t.Granter (address.Address) (struct)
+
+	{
+
+		if err := t.Granter.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Granter: %w", err)
+		}
+
+	}
+	// t.Client (address.Address) (struct)
+
+	{
+
+		if err := t.Client.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Client: %w", err)
+		}
+
+	}
+	// t.Amount (big.Int) (struct)
+
+	{
+
+		if err := t.Amount.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Amount: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufSubKey = []byte{130}
+
+func (t *SubKey) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufSubKey); err != nil {
+		return err
+	}
+
+	// t.Verifier (address.Address) (struct)
+	if err := t.Verifier.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Cap (big.Int) (struct)
+	if err := t.Cap.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *SubKey) UnmarshalCBOR(r io.Reader) error {
+	*t = SubKey{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Verifier (address.Address) (struct)
+
+	{
+
+		if err := t.Verifier.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Verifier: %w", err)
+		}
+
+	}
+	// t.Cap (big.Int) (struct)
+
+	{
+
+		if err := t.Cap.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Cap: %w", err)
+		}
+
 	}
 	return nil
 }
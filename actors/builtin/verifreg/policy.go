@@ -0,0 +1,50 @@
+package verifreg
+
+import (
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+)
+
+type Policy struct {
+	// Number of epochs for which events in the log of DataCap grants are retained.
+	GrantEventRetention abi.ChainEpoch
+
+	// Maximum number of expired grant events pruned when a grant is recorded.
+	//
+	// This bounds the cost of recording a grant. Since a grant may prune more events than it adds,
+	// the log catches up with the retention window after a burst of grants expires.
+	MaxGrantEventsPruned uint64
+
+	// Number of epochs after a grant to a verified client at which the client's remaining DataCap expires.
+	// Each grant sets the expiration of all the client's DataCap. Zero means grants do not expire.
+	ClientDataCapLifetime abi.ChainEpoch
+
+	// Maximum number of clients whose expired DataCap may be removed in one call to RemoveExpiredDataCap.
+	MaxExpiredDataCapRemovals int
+}
+
+var DefaultVerifregPolicy = Policy{
+	90 * builtin.EpochsInDay(),
+	4,
+	180 * builtin.EpochsInDay(),
+	100,
+}
+
+var CurrentVerifregPolicy = DefaultVerifregPolicy
+
+func GrantEventRetention() abi.ChainEpoch {
+	return CurrentVerifregPolicy.GrantEventRetention
+}
+
+func MaxGrantEventsPruned() uint64 {
+	return CurrentVerifregPolicy.MaxGrantEventsPruned
+}
+
+func ClientDataCapLifetime() abi.ChainEpoch {
+	return CurrentVerifregPolicy.ClientDataCapLifetime
+}
+
+func MaxExpiredDataCapRemovals() int {
+	return CurrentVerifregPolicy.MaxExpiredDataCapRemovals
+}
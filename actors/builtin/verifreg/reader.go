@@ -0,0 +1,233 @@
+package verifreg
+
+import (
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// Read-only queries of the verified registry's state, for tools outside the VM which audit DataCap grants.
+type StateReader struct {
+	store adt.Store
+	st    State
+}
+
+// Loads the verified registry's state from its head.
+func NewStateReader(store adt.Store, head cid.Cid) (*StateReader, error) {
+	r := &StateReader{store: store}
+	if err := store.Get(store.Context(), head, &r.st); err != nil {
+		return nil, xerrors.Errorf("failed to load verified registry state %v: %w", head, err)
+	}
+	return r, nil
+}
+
+// The state loaded from the head, which Totals reconciles. It must not be modified.
+func (r *StateReader) State() *State {
+	return &r.st
+}
+
+// Totals of the DataCap held and granted in the verified registry.
+type DataCapTotals struct {
+	// DataCap remaining to verifiers and to verified clients.
+	Verifiers DataCap
+	Clients   DataCap
+	// Cumulative DataCap granted by verifiers, and the part of it recorded by retained grant events.
+	Allocated      DataCap
+	RetainedGrants DataCap
+}
+
+// Returns the remaining DataCap of each verifier.
+func (r *StateReader) Verifiers() (map[addr.Address]DataCap, error) {
+	return loadDataCaps(r.store, r.st.Verifiers)
+}
+
+// Returns the remaining DataCap of each verified client, including clients whose DataCap has expired
+// but not yet been removed.
+func (r *StateReader) Clients() (map[addr.Address]DataCap, error) {
+	return loadDataCaps(r.store, r.st.VerifiedClients)
+}
+
+// Returns the totals of DataCap held and granted, after checking that they reconcile (see ReconcileTotals).
+func (r *StateReader) Totals() (*DataCapTotals, error) {
+	return ReconcileTotals(r.store, &r.st)
+}
+
+// Computes the totals of DataCap held and granted, and checks that each verifier's retained grant events
+// sum to no more than its cumulative allocation. Allocations and grant events are recorded together,
+// so until any event is pruned each verifier's events must sum to exactly its allocation.
+// Client balances are not reconciled against grants, since grants before the allocations were first
+// recorded are unknown, and clients' DataCap is consumed by deals.
+func ReconcileTotals(store adt.Store, st *State) (*DataCapTotals, error) {
+	totals := DataCapTotals{
+		Verifiers:      big.Zero(),
+		Clients:        big.Zero(),
+		Allocated:      big.Zero(),
+		RetainedGrants: big.Zero(),
+	}
+	verifiers, err := loadDataCaps(store, st.Verifiers)
+	if err != nil {
+		return nil, err
+	}
+	for _, dc := range verifiers { //nolint:nomaprange
+		totals.Verifiers = big.Add(totals.Verifiers, dc)
+	}
+	clients, err := loadDataCaps(store, st.VerifiedClients)
+	if err != nil {
+		return nil, err
+	}
+	for _, dc := range clients { //nolint:nomaprange
+		totals.Clients = big.Add(totals.Clients, dc)
+	}
+	allocations, err := loadDataCaps(store, st.VerifierAllocations)
+	if err != nil {
+		return nil, err
+	}
+	for _, dc := range allocations { //nolint:nomaprange
+		totals.Allocated = big.Add(totals.Allocated, dc)
+	}
+
+	events, err := adt.AsArray(store, st.GrantEvents, GrantEventsAmtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load grant events: %w", err)
+	}
+	granted := make(map[addr.Address]DataCap)
+	var event GrantEvent
+	if err := events.ForEach(&event, func(i int64) error {
+		prior, ok := granted[event.Verifier]
+		if !ok {
+			prior = big.Zero()
+		}
+		granted[event.Verifier] = big.Add(prior, event.Amount)
+		totals.RetainedGrants = big.Add(totals.RetainedGrants, event.Amount)
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to iterate grant events: %w", err)
+	}
+
+	for verifier, sum := range granted { //nolint:nomaprange
+		allocated, ok := allocations[verifier]
+		if !ok {
+			allocated = big.Zero()
+		}
+		if sum.GreaterThan(allocated) {
+			return nil, xerrors.Errorf("verifier %v retained grants %v exceed allocation %v", verifier, sum, allocated)
+		}
+	}
+	if st.FirstGrantEvent == 0 {
+		for verifier, allocated := range allocations { //nolint:nomaprange
+			sum, ok := granted[verifier]
+			if !ok {
+				sum = big.Zero()
+			}
+			if !sum.Equals(allocated) {
+				return nil, xerrors.Errorf("verifier %v grants %v differ from allocation %v with no events pruned", verifier, sum, allocated)
+			}
+		}
+	}
+	return &totals, nil
+}
+
+func loadDataCaps(store adt.Store, root cid.Cid) (map[addr.Address]DataCap, error) {
+	m, err := adt.AsMap(store, root, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load DataCap map: %w", err)
+	}
+	all := make(map[addr.Address]DataCap)
+	var dc DataCap
+	if err := m.ForEach(&dc, func(k string) error {
+		a, err := addr.NewFromBytes([]byte(k))
+		if err != nil {
+			return xerrors.Errorf("invalid DataCap key %x: %w", k, err)
+		}
+		all[a] = dc
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to iterate DataCap map: %w", err)
+	}
+	return all, nil
+}
+
+// Returns the cumulative DataCap a verifier has granted to verified clients, which is zero if it has granted none.
+// The allocation of a removed verifier is retained.
+func (r *StateReader) VerifierAllocation(verifier addr.Address) (DataCap, error) {
+	allocations, err := adt.AsMap(r.store, r.st.VerifierAllocations, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return big.Zero(), xerrors.Errorf("failed to load verifier allocations: %w", err)
+	}
+	allocated := big.Zero()
+	if _, err := allocations.Get(abi.AddrKey(verifier), &allocated); err != nil {
+		return big.Zero(), xerrors.Errorf("failed to get allocation of verifier %v: %w", verifier, err)
+	}
+	return allocated, nil
+}
+
+// Returns the cumulative DataCap granted by each verifier which has granted any.
+func (r *StateReader) VerifierAllocations() (map[addr.Address]DataCap, error) {
+	return loadDataCaps(r.store, r.st.VerifierAllocations)
+}
+
+// Returns the sub-keys registered by a verifier, with their remaining caps.
+// The sub-keys of a removed verifier are retained until the verifier revokes them.
+func (r *StateReader) VerifierSubKeys(verifier addr.Address) (map[addr.Address]DataCap, error) {
+	subKeys, err := adt.AsMap(r.store, r.st.VerifierSubKeys, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load verifier sub-keys: %w", err)
+	}
+	caps := make(map[addr.Address]DataCap)
+	var subKey SubKey
+	if err := subKeys.ForEach(&subKey, func(k string) error {
+		if subKey.Verifier != verifier {
+			return nil
+		}
+		sk, err := addr.NewFromBytes([]byte(k))
+		if err != nil {
+			return xerrors.Errorf("invalid verifier sub-key %x: %w", k, err)
+		}
+		caps[sk] = subKey.Cap
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to iterate verifier sub-keys: %w", err)
+	}
+	return caps, nil
+}
+
+// Returns the retained grant events recorded from epoch `from` until before `to`, in order of recording.
+// Events before the retention window may be retained until they are pruned by later grants.
+func (r *StateReader) GrantEvents(from, to abi.ChainEpoch) ([]GrantEvent, error) {
+	if to < from {
+		return nil, xerrors.Errorf("invalid epoch range [%d, %d)", from, to)
+	}
+	return r.grantEvents(func(e *GrantEvent) bool {
+		return e.Epoch >= from && e.Epoch < to
+	})
+}
+
+// Returns the retained grant events to a verified client, in order of recording.
+func (r *StateReader) ClientGrants(client addr.Address) ([]GrantEvent, error) {
+	return r.grantEvents(func(e *GrantEvent) bool {
+		return e.Client == client
+	})
+}
+
+func (r *StateReader) grantEvents(include func(e *GrantEvent) bool) ([]GrantEvent, error) {
+	events, err := adt.AsArray(r.store, r.st.GrantEvents, GrantEventsAmtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load grant events: %w", err)
+	}
+	matched := []GrantEvent{}
+	var event GrantEvent
+	if err := events.ForEach(&event, func(i int64) error {
+		if include(&event) {
+			matched = append(matched, event)
+		}
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to iterate grant events: %w", err)
+	}
+	return matched, nil
+}
@@ -0,0 +1,156 @@
+package verifreg_test
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/verifreg"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestStateReader(t *testing.T) {
+	root := tutil.NewIDAddr(t, 101)
+	client1 := tutil.NewIDAddr(t, 201)
+	client2 := tutil.NewIDAddr(t, 202)
+	verifier1 := tutil.NewIDAddr(t, 301)
+	verifier2 := tutil.NewIDAddr(t, 302)
+	subKey := tutil.NewIDAddr(t, 401)
+	vallow := big.Mul(verifreg.MinVerifiedDealSize, big.NewInt(10))
+	allowance := verifreg.MinVerifiedDealSize
+	allowance2 := big.Mul(verifreg.MinVerifiedDealSize, big.NewInt(2))
+
+	rt, ac := basicVerifRegSetup(t, root)
+	ac.addVerifier(rt, verifier1, vallow)
+	ac.addVerifier(rt, verifier2, vallow)
+	rt.SetEpoch(5)
+	ac.addVerifiedClient(rt, verifier1, client1, allowance, allowance)
+	rt.SetEpoch(6)
+	ac.addVerifiedClient(rt, verifier2, client2, allowance2, allowance2)
+	rt.SetEpoch(7)
+	ac.addVerifiedClient(rt, verifier1, client2, allowance, big.Add(allowance2, allowance))
+	ac.removeVerifier(rt, verifier1)
+	ac.addVerifierSubKey(rt, verifier2, subKey, allowance2)
+	rt.SetEpoch(8)
+	ac.addVerifiedClient(rt, subKey, client1, allowance, big.Mul(allowance, big.NewInt(2)))
+
+	r, err := verifreg.NewStateReader(rt.AdtStore(), rt.StateRoot())
+	require.NoError(t, err)
+
+	t.Run("verifier allocations", func(t *testing.T) {
+		// The allocation of a removed verifier is retained.
+		allocated, err := r.VerifierAllocation(verifier1)
+		require.NoError(t, err)
+		assert.Equal(t, big.Mul(allowance, big.NewInt(2)), allocated)
+
+		allocated, err = r.VerifierAllocation(root)
+		require.NoError(t, err)
+		assert.Equal(t, big.Zero(), allocated)
+
+		all, err := r.VerifierAllocations()
+		require.NoError(t, err)
+		assert.Equal(t, map[address.Address]verifreg.DataCap{
+			verifier1: big.Mul(allowance, big.NewInt(2)),
+			verifier2: big.Add(allowance2, allowance),
+		}, all)
+	})
+
+	t.Run("grant events", func(t *testing.T) {
+		events, err := r.GrantEvents(0, 100)
+		require.NoError(t, err)
+		assert.Equal(t, []verifreg.GrantEvent{
+			{Epoch: 5, Verifier: verifier1, Granter: verifier1, Client: client1, Amount: allowance},
+			{Epoch: 6, Verifier: verifier2, Granter: verifier2, Client: client2, Amount: allowance2},
+			{Epoch: 7, Verifier: verifier1, Granter: verifier1, Client: client2, Amount: allowance},
+			{Epoch: 8, Verifier: verifier2, Granter: subKey, Client: client1, Amount: allowance},
+		}, events)
+
+		events, err = r.GrantEvents(6, 7)
+		require.NoError(t, err)
+		assert.Equal(t, []verifreg.GrantEvent{{Epoch: 6, Verifier: verifier2, Granter: verifier2, Client: client2, Amount: allowance2}}, events)
+
+		_, err = r.GrantEvents(abi.ChainEpoch(7), abi.ChainEpoch(6))
+		assert.Error(t, err)
+	})
+
+	t.Run("client grants", func(t *testing.T) {
+		events, err := r.ClientGrants(client2)
+		require.NoError(t, err)
+		assert.Equal(t, []verifreg.GrantEvent{
+			{Epoch: 6, Verifier: verifier2, Granter: verifier2, Client: client2, Amount: allowance2},
+			{Epoch: 7, Verifier: verifier1, Granter: verifier1, Client: client2, Amount: allowance},
+		}, events)
+
+		events, err = r.ClientGrants(tutil.NewIDAddr(t, 203))
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("verifier sub-keys", func(t *testing.T) {
+		subKeys, err := r.VerifierSubKeys(verifier2)
+		require.NoError(t, err)
+		assert.Equal(t, map[address.Address]verifreg.DataCap{subKey: big.Sub(allowance2, allowance)}, subKeys)
+
+		subKeys, err = r.VerifierSubKeys(verifier1)
+		require.NoError(t, err)
+		assert.Empty(t, subKeys)
+	})
+	t.Run("balances", func(t *testing.T) {
+		verifiers, err := r.Verifiers()
+		require.NoError(t, err)
+		assert.Equal(t, map[address.Address]verifreg.DataCap{
+			verifier2: big.Sub(vallow, big.Add(allowance2, allowance)),
+		}, verifiers)
+
+		clients, err := r.Clients()
+		require.NoError(t, err)
+		assert.Equal(t, map[address.Address]verifreg.DataCap{
+			client1: big.Mul(allowance, big.NewInt(2)),
+			client2: big.Add(allowance2, allowance),
+		}, clients)
+	})
+
+	t.Run("totals", func(t *testing.T) {
+		totals, err := r.Totals()
+		require.NoError(t, err)
+		granted := big.Add(allowance2, big.Mul(allowance, big.NewInt(3)))
+		assert.Equal(t, &verifreg.DataCapTotals{
+			Verifiers:      big.Sub(vallow, big.Add(allowance2, allowance)),
+			Clients:        granted,
+			Allocated:      granted,
+			RetainedGrants: granted,
+		}, totals)
+	})
+
+	t.Run("totals do not reconcile with an allocation differing from grants", func(t *testing.T) {
+		st := *r.State()
+		allocations, err := adt.AsMap(rt.AdtStore(), st.VerifierAllocations, builtin.DefaultHamtBitwidth)
+		require.NoError(t, err)
+		require.NoError(t, allocations.Put(abi.AddrKey(verifier1), &allowance))
+		st.VerifierAllocations, err = allocations.Root()
+		require.NoError(t, err)
+
+		_, err = verifreg.ReconcileTotals(rt.AdtStore(), &st)
+		assert.Error(t, err)
+		_, acc := verifreg.CheckStateInvariants(&st, rt.AdtStore())
+		assert.False(t, acc.IsEmpty())
+
+		// Once events are pruned, retained grants may fall short of the allocation but not exceed it.
+		st.FirstGrantEvent = 1
+		_, err = verifreg.ReconcileTotals(rt.AdtStore(), &st)
+		assert.Error(t, err)
+
+		exceeding := big.Mul(allowance, big.NewInt(3))
+		require.NoError(t, allocations.Put(abi.AddrKey(verifier1), &exceeding))
+		st.VerifierAllocations, err = allocations.Root()
+		require.NoError(t, err)
+		_, err = verifreg.ReconcileTotals(rt.AdtStore(), &st)
+		assert.NoError(t, err)
+	})
+}
@@ -1,9 +1,12 @@
 package verifreg
 
 import (
+	"strings"
+
 	addr "github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
+	cbg "github.com/whyrusleeping/cbor-gen"
 
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
 	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
@@ -21,18 +24,17 @@ func CheckStateInvariants(st *State, store adt.Store) (*StateSummary, *builtin.M
 
 	// Check verifiers
 	allVerifiers := map[addr.Address]DataCap{}
-	if verifiers, err := adt.AsMap(store, st.Verifiers, builtin.DefaultHamtBitwidth); err != nil {
+	if verifiers, err := adt.AsTypedMap[DataCap](store, st.Verifiers, builtin.DefaultHamtBitwidth); err != nil {
 		acc.Addf("error loading verifiers: %v", err)
 	} else {
-		var vcap abi.StoragePower
-		err = verifiers.ForEach(&vcap, func(key string) error {
+		err = verifiers.ForEach(func(key string, vcap *DataCap) error {
 			verifier, err := addr.NewFromBytes([]byte(key))
 			if err != nil {
 				return err
 			}
 			acc.Require(verifier.Protocol() == addr.ID, "verifier %v should have ID protocol", verifier)
 			acc.Require(vcap.GreaterThanEqual(big.Zero()), "verifier %v cap %v is negative", verifier, vcap)
-			allVerifiers[verifier] = vcap.Copy()
+			allVerifiers[verifier] = *vcap
 			return nil
 		})
 		acc.RequireNoError(err, "error iterating verifiers")
@@ -40,23 +42,117 @@ func CheckStateInvariants(st *State, store adt.Store) (*StateSummary, *builtin.M
 
 	// Check clients
 	allClients := map[addr.Address]DataCap{}
-	if clients, err := adt.AsMap(store, st.VerifiedClients, builtin.DefaultHamtBitwidth); err != nil {
+	if clients, err := adt.AsTypedMap[DataCap](store, st.VerifiedClients, builtin.DefaultHamtBitwidth); err != nil {
 		acc.Addf("error loading clients: %v", err)
 	} else {
-		var ccap abi.StoragePower
-		err = clients.ForEach(&ccap, func(key string) error {
+		err = clients.ForEach(func(key string, ccap *DataCap) error {
 			client, err := addr.NewFromBytes([]byte(key))
 			if err != nil {
 				return err
 			}
 			acc.Require(client.Protocol() == addr.ID, "client %v should have ID protocol", client)
 			acc.Require(ccap.GreaterThanEqual(big.Zero()), "client %v cap %v is negative", client, ccap)
-			allClients[client] = ccap.Copy()
+			allClients[client] = *ccap
 			return nil
 		})
 		acc.RequireNoError(err, "error iterating clients")
 	}
 
+	// Check verifier sub-keys
+	allSubKeys := map[addr.Address]SubKey{}
+	if subKeys, err := adt.AsTypedMap[SubKey](store, st.VerifierSubKeys, builtin.DefaultHamtBitwidth); err != nil {
+		acc.Addf("error loading verifier sub-keys: %v", err)
+	} else {
+		err = subKeys.ForEach(func(key string, subKey *SubKey) error {
+			sk, err := addr.NewFromBytes([]byte(key))
+			if err != nil {
+				return err
+			}
+			acc.Require(sk.Protocol() == addr.ID, "verifier sub-key %v should have ID protocol", sk)
+			acc.Require(subKey.Verifier.Protocol() == addr.ID, "sub-key %v verifier %v should have ID protocol", sk, subKey.Verifier)
+			acc.Require(subKey.Cap.GreaterThanEqual(big.Zero()), "sub-key %v cap %v is negative", sk, subKey.Cap)
+			allSubKeys[sk] = *subKey
+			return nil
+		})
+		acc.RequireNoError(err, "error iterating verifier sub-keys")
+	}
+
+	// Check DataCap removal proposal IDs
+	if proposalIDs, err := adt.AsTypedMap[RmDcProposalID](store, st.RemoveDataCapProposalIDs, builtin.DefaultHamtBitwidth); err != nil {
+		acc.Addf("error loading DataCap removal proposal IDs: %v", err)
+	} else {
+		err = proposalIDs.ForEach(func(key string, id *RmDcProposalID) error {
+			var pair AddrPairKey
+			if err := pair.UnmarshalCBOR(strings.NewReader(key)); err != nil {
+				return err
+			}
+			acc.Require(pair.First.Protocol() == addr.ID, "DataCap removal verifier %v should have ID protocol", pair.First)
+			acc.Require(pair.Second.Protocol() == addr.ID, "DataCap removal client %v should have ID protocol", pair.Second)
+			acc.Require(id.ProposalID > 0, "DataCap removal proposal ID for verifier %v, client %v is zero", pair.First, pair.Second)
+			return nil
+		})
+		acc.RequireNoError(err, "error iterating DataCap removal proposal IDs")
+	}
+
+	// Check verifier allocations
+	if allocations, err := adt.AsTypedMap[DataCap](store, st.VerifierAllocations, builtin.DefaultHamtBitwidth); err != nil {
+		acc.Addf("error loading verifier allocations: %v", err)
+	} else {
+		err = allocations.ForEach(func(key string, allocated *DataCap) error {
+			verifier, err := addr.NewFromBytes([]byte(key))
+			if err != nil {
+				return err
+			}
+			acc.Require(verifier.Protocol() == addr.ID, "allocating verifier %v should have ID protocol", verifier)
+			acc.Require(allocated.GreaterThan(big.Zero()), "verifier %v allocation %v is not positive", verifier, allocated)
+			return nil
+		})
+		acc.RequireNoError(err, "error iterating verifier allocations")
+	}
+
+	// Check grant events are retained contiguously, in order of epoch.
+	acc.Require(st.FirstGrantEvent <= st.NextGrantEvent, "first grant event %d after next %d", st.FirstGrantEvent, st.NextGrantEvent)
+	if events, err := adt.AsArray(store, st.GrantEvents, GrantEventsAmtBitwidth); err != nil {
+		acc.Addf("error loading grant events: %v", err)
+	} else {
+		expected := st.FirstGrantEvent
+		prevEpoch := abi.ChainEpoch(-1)
+		var event GrantEvent
+		err = events.ForEach(&event, func(i int64) error {
+			acc.Require(uint64(i) == expected, "grant event %d retained, expected %d", i, expected)
+			expected = uint64(i) + 1
+			acc.Require(event.Epoch >= prevEpoch, "grant event %d at epoch %d before previous at %d", i, event.Epoch, prevEpoch)
+			prevEpoch = event.Epoch
+			acc.Require(event.Verifier.Protocol() == addr.ID, "grant event %d verifier %v should have ID protocol", i, event.Verifier)
+			acc.Require(event.Granter.Protocol() == addr.ID, "grant event %d granter %v should have ID protocol", i, event.Granter)
+			acc.Require(event.Client.Protocol() == addr.ID, "grant event %d client %v should have ID protocol", i, event.Client)
+			acc.Require(event.Amount.GreaterThan(big.Zero()), "grant event %d amount %v is not positive", i, event.Amount)
+			return nil
+		})
+		acc.RequireNoError(err, "error iterating grant events")
+		acc.Require(expected == st.NextGrantEvent, "grant events end at %d, expected %d", expected, st.NextGrantEvent)
+	}
+
+	// Check client expirations
+	if expirations, err := adt.AsTypedMap[cbg.CborInt](store, st.ClientExpirations, builtin.DefaultHamtBitwidth); err != nil {
+		acc.Addf("error loading client expirations: %v", err)
+	} else {
+		err = expirations.ForEach(func(key string, _ *cbg.CborInt) error {
+			client, err := addr.NewFromBytes([]byte(key))
+			if err != nil {
+				return err
+			}
+			_, found := allClients[client]
+			acc.Require(found, "expiration for %v which is not a client", client)
+			return nil
+		})
+		acc.RequireNoError(err, "error iterating client expirations")
+	}
+
+	// Check retained grant events reconcile with verifier allocations.
+	_, err := ReconcileTotals(store, st)
+	acc.RequireNoError(err, "DataCap totals do not reconcile")
+
 	// Check verifiers and clients are disjoint.
 	for v := range allVerifiers { //nolint:nomaprange
 		_, found := allClients[v]
@@ -64,6 +160,14 @@ func CheckStateInvariants(st *State, store adt.Store) (*StateSummary, *builtin.M
 	}
 	// No need to iterate all clients; any overlap must have been one of all verifiers.
 
+	// Check sub-keys are neither verifiers nor clients.
+	for sk := range allSubKeys { //nolint:nomaprange
+		_, found := allVerifiers[sk]
+		acc.Require(!found, "verifier sub-key %v is also a verifier", sk)
+		_, found = allClients[sk]
+		acc.Require(!found, "verifier sub-key %v is also a client", sk)
+	}
+
 	return &StateSummary{
 		Verifiers: allVerifiers,
 		Clients:   allClients,
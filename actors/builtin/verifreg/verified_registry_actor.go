@@ -1,10 +1,14 @@
 package verifreg
 
 import (
+	"bytes"
+
 	addr "github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/cbor"
+	"github.com/filecoin-project/go-state-types/crypto"
 	"github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
 
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/exitcode"
@@ -25,6 +29,10 @@ func (a Actor) Exports() []interface{} {
 		4:                         a.AddVerifiedClient,
 		5:                         a.UseBytes,
 		6:                         a.RestoreBytes,
+		7:                         a.RemoveVerifiedClientDataCap,
+		8:                         a.RemoveExpiredDataCap,
+		9:                         a.AddVerifierSubKey,
+		10:                        a.RemoveVerifierSubKey,
 	}
 }
 
@@ -94,6 +102,15 @@ func (a Actor) AddVerifier(rt runtime.Runtime, params *AddVerifierParams) *abi.E
 			rt.Abortf(exitcode.ErrIllegalArgument, "verified client %v cannot become a verifier", verifier)
 		}
 
+		// A verifier's sub-key cannot become a verifier
+		subKeys, err := adt.AsMap(adt.AsStore(rt), st.VerifierSubKeys, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load verifier sub-keys")
+		found, err = subKeys.Get(abi.AddrKey(verifier), nil)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get verifier sub-key %v", verifier)
+		if found {
+			rt.Abortf(exitcode.ErrIllegalArgument, "verifier sub-key %v cannot become a verifier", verifier)
+		}
+
 		err = verifiers.Put(abi.AddrKey(verifier), &params.Allowance)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add verifier")
 
@@ -157,8 +174,17 @@ func (a Actor) AddVerifiedClient(rt runtime.Runtime, params *AddVerifiedClientPa
 		verifiedClients, err := adt.AsMap(adt.AsStore(rt), st.VerifiedClients, builtin.DefaultHamtBitwidth)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load verified clients")
 
-		// Validate caller is one of the verifiers.
+		subKeys, err := adt.AsMap(adt.AsStore(rt), st.VerifierSubKeys, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load verifier sub-keys")
+
+		// Validate caller is one of the verifiers, or a sub-key granting on behalf of one.
 		verifier := rt.Caller()
+		var subKey SubKey
+		isSubKey, err := subKeys.Get(abi.AddrKey(rt.Caller()), &subKey)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get verifier sub-key %v", rt.Caller())
+		if isSubKey {
+			verifier = subKey.Verifier
+		}
 		var verifierCap DataCap
 		found, err := verifiers.Get(abi.AddrKey(verifier), &verifierCap)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get verifier %v", verifier)
@@ -172,6 +198,11 @@ func (a Actor) AddVerifiedClient(rt runtime.Runtime, params *AddVerifiedClientPa
 		if found {
 			rt.Abortf(exitcode.ErrIllegalArgument, "verifier %v cannot be added as a verified client", client)
 		}
+		found, err = subKeys.Get(abi.AddrKey(client), nil)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get verifier sub-key")
+		if found {
+			rt.Abortf(exitcode.ErrIllegalArgument, "verifier sub-key %v cannot be added as a verified client", client)
+		}
 
 		// Compute new verifier cap and update.
 		if verifierCap.LessThan(params.Allowance) {
@@ -182,13 +213,29 @@ func (a Actor) AddVerifiedClient(rt runtime.Runtime, params *AddVerifiedClientPa
 		err = verifiers.Put(abi.AddrKey(verifier), &newVerifierCap)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update new verifier cap (%d) for %v", newVerifierCap, verifier)
 
+		// A sub-key's grants are also limited by its own cap.
+		if isSubKey {
+			if subKey.Cap.LessThan(params.Allowance) {
+				rt.Abortf(exitcode.ErrIllegalArgument, "add more DataCap (%d) for VerifiedClient than sub-key %v cap %d", params.Allowance, rt.Caller(), subKey.Cap)
+			}
+			subKey.Cap = big.Sub(subKey.Cap, params.Allowance)
+			err = subKeys.Put(abi.AddrKey(rt.Caller()), &subKey)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update cap of verifier sub-key %v", rt.Caller())
+			st.VerifierSubKeys, err = subKeys.Root()
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush verifier sub-keys")
+		}
+
+		expirations, err := adt.AsMap(adt.AsStore(rt), st.ClientExpirations, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load client expirations")
+
 		var clientCap DataCap
 		found, err = verifiedClients.Get(abi.AddrKey(client), &clientCap)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get verified client %v", client)
 
 		// if verified client exists, add allowance to existing cap
 		// otherwise, create new client with allownace
-		if found {
+		// An expired cap is replaced by the allowance.
+		if found && !clientDataCapExpired(rt, expirations, client) {
 			clientCap = big.Add(clientCap, params.Allowance)
 		} else {
 			clientCap = params.Allowance
@@ -196,6 +243,19 @@ func (a Actor) AddVerifiedClient(rt runtime.Runtime, params *AddVerifiedClientPa
 		err = verifiedClients.Put(abi.AddrKey(client), &clientCap)
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add verified client %v with cap %d", client, clientCap)
 
+		setClientExpiration(rt, expirations, client)
+		st.ClientExpirations, err = expirations.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush client expirations")
+
+		err = st.recordGrant(adt.AsStore(rt), &GrantEvent{
+			Epoch:    rt.CurrEpoch(),
+			Verifier: verifier,
+			Granter:  rt.Caller(),
+			Client:   client,
+			Amount:   params.Allowance,
+		})
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to record grant to verified client %v", client)
+
 		st.Verifiers, err = verifiers.Root()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush verifiers")
 
@@ -238,6 +298,12 @@ func (a Actor) UseBytes(rt runtime.Runtime, params *UseBytesParams) *abi.EmptyVa
 		}
 		builtin.RequireState(rt, vcCap.GreaterThanEqual(big.Zero()), "negative cap for client %v: %v", client, vcCap)
 
+		expirations, err := adt.AsMap(adt.AsStore(rt), st.ClientExpirations, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load client expirations")
+		if clientDataCapExpired(rt, expirations, client) {
+			rt.Abortf(exitcode.ErrForbidden, "DataCap of verified client %v has expired", client)
+		}
+
 		if params.DealSize.GreaterThan(vcCap) {
 			rt.Abortf(exitcode.ErrIllegalArgument, "DealSize %d exceeds allowable cap: %d for VerifiedClient %v", params.DealSize, vcCap, client)
 		}
@@ -251,6 +317,8 @@ func (a Actor) UseBytes(rt runtime.Runtime, params *UseBytesParams) *abi.EmptyVa
 			// See: https://github.com/filecoin-project/specs-actors/issues/727
 			err = verifiedClients.Delete(abi.AddrKey(client))
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete verified client %v", client)
+			_, err = expirations.TryDelete(abi.AddrKey(client))
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete expiration of verified client %v", client)
 		} else {
 			err = verifiedClients.Put(abi.AddrKey(client), &newVcCap)
 			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update verified client %v with %v", client, newVcCap)
@@ -258,6 +326,8 @@ func (a Actor) UseBytes(rt runtime.Runtime, params *UseBytesParams) *abi.EmptyVa
 
 		st.VerifiedClients, err = verifiedClients.Root()
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush verified clients")
+		st.ClientExpirations, err = expirations.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush client expirations")
 	})
 
 	return nil
@@ -306,6 +376,13 @@ func (a Actor) RestoreBytes(rt runtime.Runtime, params *RestoreBytesParams) *abi
 		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get verified client %v", client)
 		if !found {
 			vcCap = big.Zero()
+
+			// DataCap restored to a deleted client expires as if newly granted.
+			expirations, err := adt.AsMap(adt.AsStore(rt), st.ClientExpirations, builtin.DefaultHamtBitwidth)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load client expirations")
+			setClientExpiration(rt, expirations, client)
+			st.ClientExpirations, err = expirations.Root()
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush client expirations")
 		}
 
 		newVcCap := big.Add(vcCap, params.DealSize)
@@ -318,3 +395,329 @@ func (a Actor) RestoreBytes(rt runtime.Runtime, params *RestoreBytesParams) *abi
 
 	return nil
 }
+
+// Domain separation tag prefixed to a DataCap removal proposal when it is signed by a verifier.
+const SignatureDomainSeparation_RemoveDataCap = "fil_removedatacap:"
+
+// A verifier's proposal to remove DataCap from a verified client, signed by the verifier.
+type RemoveDataCapProposal struct {
+	VerifiedClient    addr.Address
+	DataCapAmount     DataCap
+	RemovalProposalID RmDcProposalID
+	// The last epoch at which the removal may be executed.
+	Expiration abi.ChainEpoch
+}
+
+type RemoveDataCapRequest struct {
+	Verifier          addr.Address
+	VerifierSignature crypto.Signature
+}
+
+type RemoveDataCapParams struct {
+	VerifiedClientToRemove addr.Address
+	DataCapAmountToRemove  DataCap
+	Expiration             abi.ChainEpoch
+	VerifierRequest1       RemoveDataCapRequest
+	VerifierRequest2       RemoveDataCapRequest
+}
+
+type RemoveDataCapReturn struct {
+	VerifiedClient addr.Address
+	DataCapRemoved DataCap
+}
+
+// Removes DataCap from a verified client, deleting the client if none remains.
+// May only be invoked by the root key, with the proposals of two different verifiers to remove the same amount.
+// Each verifier signs a proposal with its next proposal ID for the client, which the removal uses up so that
+// the proposal cannot be replayed. The removal fails after the proposals' expiration epoch.
+func (a Actor) RemoveVerifiedClientDataCap(rt runtime.Runtime, params *RemoveDataCapParams) *RemoveDataCapReturn {
+	var st State
+	rt.StateReadonly(&st)
+	rt.ValidateImmediateCallerIs(st.RootKey)
+
+	if params.DataCapAmountToRemove.LessThanEqual(big.Zero()) {
+		rt.Abortf(exitcode.ErrIllegalArgument, "non-positive DataCap %v to remove", params.DataCapAmountToRemove)
+	}
+	if rt.CurrEpoch() > params.Expiration {
+		rt.Abortf(exitcode.ErrIllegalArgument, "DataCap removal expired at %d", params.Expiration)
+	}
+
+	client, err := builtin.ResolveToIDAddr(rt, params.VerifiedClientToRemove)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to resolve client address %v to ID address", params.VerifiedClientToRemove)
+	verifier1, err := builtin.ResolveToIDAddr(rt, params.VerifierRequest1.Verifier)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to resolve verifier address %v to ID address", params.VerifierRequest1.Verifier)
+	verifier2, err := builtin.ResolveToIDAddr(rt, params.VerifierRequest2.Verifier)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to resolve verifier address %v to ID address", params.VerifierRequest2.Verifier)
+	if verifier1 == verifier2 {
+		rt.Abortf(exitcode.ErrIllegalArgument, "DataCap removal needs two different verifiers, got %v twice", verifier1)
+	}
+
+	removed := params.DataCapAmountToRemove
+	rt.StateTransaction(&st, func() {
+		verifiers, err := adt.AsMap(adt.AsStore(rt), st.Verifiers, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load verifiers")
+		for _, verifier := range []addr.Address{verifier1, verifier2} {
+			found, err := verifiers.Get(abi.AddrKey(verifier), nil)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get verifier %v", verifier)
+			if !found {
+				rt.Abortf(exitcode.ErrNotFound, "%v is not a verifier", verifier)
+			}
+		}
+
+		verifiedClients, err := adt.AsMap(adt.AsStore(rt), st.VerifiedClients, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load verified clients")
+		var vcCap DataCap
+		found, err := verifiedClients.Get(abi.AddrKey(client), &vcCap)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get verified client %v", client)
+		if !found {
+			rt.Abortf(exitcode.ErrNotFound, "%v is not a verified client", client)
+		}
+
+		proposalIDs, err := adt.AsMap(adt.AsStore(rt), st.RemoveDataCapProposalIDs, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load DataCap removal proposal IDs")
+		for _, req := range []struct {
+			verifier addr.Address
+			request  RemoveDataCapRequest
+		}{{verifier1, params.VerifierRequest1}, {verifier2, params.VerifierRequest2}} {
+			proposal := RemoveDataCapProposal{
+				VerifiedClient:    client,
+				DataCapAmount:     params.DataCapAmountToRemove,
+				RemovalProposalID: useProposalID(rt, proposalIDs, req.verifier, client),
+				Expiration:        params.Expiration,
+			}
+			verifyRemoveDataCapRequest(rt, &req.request, &proposal)
+		}
+		st.RemoveDataCapProposalIDs, err = proposalIDs.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush DataCap removal proposal IDs")
+
+		newVcCap := big.Sub(vcCap, params.DataCapAmountToRemove)
+		if newVcCap.LessThanEqual(big.Zero()) {
+			// Delete the client, removing no more than the DataCap it had.
+			removed = vcCap
+			err = verifiedClients.Delete(abi.AddrKey(client))
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete verified client %v", client)
+
+			expirations, err := adt.AsMap(adt.AsStore(rt), st.ClientExpirations, builtin.DefaultHamtBitwidth)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load client expirations")
+			_, err = expirations.TryDelete(abi.AddrKey(client))
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete expiration of verified client %v", client)
+			st.ClientExpirations, err = expirations.Root()
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush client expirations")
+		} else {
+			err = verifiedClients.Put(abi.AddrKey(client), &newVcCap)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to update verified client %v with %v", client, newVcCap)
+		}
+
+		st.VerifiedClients, err = verifiedClients.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush verified clients")
+	})
+
+	return &RemoveDataCapReturn{
+		VerifiedClient: client,
+		DataCapRemoved: removed,
+	}
+}
+
+type RemoveExpiredDataCapParams struct {
+	VerifiedClients []addr.Address
+}
+
+type RemoveExpiredDataCapReturn struct {
+	// The clients whose DataCap was removed, as ID addresses.
+	Removed        []addr.Address
+	DataCapRemoved DataCap
+}
+
+// Removes the verified clients among those listed whose DataCap has expired, along with their remaining DataCap.
+// Clients which are not verified clients or whose DataCap has not expired are ignored.
+// May be invoked by anyone, for up to MaxExpiredDataCapRemovals clients.
+func (a Actor) RemoveExpiredDataCap(rt runtime.Runtime, params *RemoveExpiredDataCapParams) *RemoveExpiredDataCapReturn {
+	rt.ValidateImmediateCallerAcceptAny()
+
+	if len(params.VerifiedClients) > MaxExpiredDataCapRemovals() {
+		rt.Abortf(exitcode.ErrIllegalArgument, "too many clients %d, max %d", len(params.VerifiedClients), MaxExpiredDataCapRemovals())
+	}
+
+	ret := RemoveExpiredDataCapReturn{Removed: []addr.Address{}, DataCapRemoved: big.Zero()}
+	var st State
+	rt.StateTransaction(&st, func() {
+		verifiedClients, err := adt.AsMap(adt.AsStore(rt), st.VerifiedClients, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load verified clients")
+		expirations, err := adt.AsMap(adt.AsStore(rt), st.ClientExpirations, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load client expirations")
+
+		for _, clientAddr := range params.VerifiedClients {
+			client, ok := rt.ResolveAddress(clientAddr)
+			if !ok || !clientDataCapExpired(rt, expirations, client) {
+				continue
+			}
+			var vcCap DataCap
+			found, err := verifiedClients.Pop(abi.AddrKey(client), &vcCap)
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete verified client %v", client)
+			builtin.RequireState(rt, found, "expiration for missing verified client %v", client)
+			err = expirations.Delete(abi.AddrKey(client))
+			builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete expiration of verified client %v", client)
+
+			ret.Removed = append(ret.Removed, client)
+			ret.DataCapRemoved = big.Add(ret.DataCapRemoved, vcCap)
+		}
+
+		st.VerifiedClients, err = verifiedClients.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush verified clients")
+		st.ClientExpirations, err = expirations.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush client expirations")
+	})
+	return &ret
+}
+
+type AddVerifierSubKeyParams struct {
+	SubKey addr.Address
+	Cap    DataCap
+}
+
+// Registers a sub-key which may grant DataCap on behalf of the calling verifier, up to a cap.
+// Registering an existing sub-key of the verifier replaces its cap.
+// The sub-key's grants are deducted from both its cap and the verifier's DataCap, so the cap may exceed
+// the verifier's remaining DataCap.
+func (a Actor) AddVerifierSubKey(rt runtime.Runtime, params *AddVerifierSubKeyParams) *abi.EmptyValue {
+	// The caller will be verified by checking the verifiers table below.
+	rt.ValidateImmediateCallerAcceptAny()
+
+	if params.Cap.LessThan(big.Zero()) {
+		rt.Abortf(exitcode.ErrIllegalArgument, "negative cap %v for verifier sub-key %v", params.Cap, params.SubKey)
+	}
+
+	subKeyAddr, err := builtin.ResolveToIDAddr(rt, params.SubKey)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to resolve verifier sub-key address %v", params.SubKey)
+
+	var st State
+	rt.StateReadonly(&st)
+	if subKeyAddr == st.RootKey {
+		rt.Abortf(exitcode.ErrIllegalArgument, "Rootkey cannot be added as a verifier sub-key")
+	}
+
+	rt.StateTransaction(&st, func() {
+		verifiers, err := adt.AsMap(adt.AsStore(rt), st.Verifiers, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load verifiers")
+
+		verifiedClients, err := adt.AsMap(adt.AsStore(rt), st.VerifiedClients, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load verified clients")
+
+		subKeys, err := adt.AsMap(adt.AsStore(rt), st.VerifierSubKeys, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load verifier sub-keys")
+
+		// Validate caller is one of the verifiers.
+		verifier := rt.Caller()
+		found, err := verifiers.Get(abi.AddrKey(verifier), nil)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get verifier %v", verifier)
+		if !found {
+			rt.Abortf(exitcode.ErrNotFound, "no such verifier %v", verifier)
+		}
+
+		// Validate sub-key isn't a verifier, a verified client or another verifier's sub-key.
+		found, err = verifiers.Get(abi.AddrKey(subKeyAddr), nil)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get verifier")
+		if found {
+			rt.Abortf(exitcode.ErrIllegalArgument, "verifier %v cannot be added as a verifier sub-key", subKeyAddr)
+		}
+		found, err = verifiedClients.Get(abi.AddrKey(subKeyAddr), nil)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get verified client")
+		if found {
+			rt.Abortf(exitcode.ErrIllegalArgument, "verified client %v cannot be added as a verifier sub-key", subKeyAddr)
+		}
+		var existing SubKey
+		found, err = subKeys.Get(abi.AddrKey(subKeyAddr), &existing)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get verifier sub-key %v", subKeyAddr)
+		if found && existing.Verifier != verifier {
+			rt.Abortf(exitcode.ErrForbidden, "%v is already a sub-key of verifier %v", subKeyAddr, existing.Verifier)
+		}
+
+		err = subKeys.Put(abi.AddrKey(subKeyAddr), &SubKey{Verifier: verifier, Cap: params.Cap})
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to add verifier sub-key %v", subKeyAddr)
+
+		st.VerifierSubKeys, err = subKeys.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush verifier sub-keys")
+	})
+
+	return nil
+}
+
+// Revokes a sub-key of the calling verifier.
+// A verifier may revoke its sub-keys after its own removal.
+func (a Actor) RemoveVerifierSubKey(rt runtime.Runtime, subKeyAddr *addr.Address) *abi.EmptyValue {
+	// The caller will be verified by checking the sub-key's verifier below.
+	rt.ValidateImmediateCallerAcceptAny()
+
+	subKey, ok := rt.ResolveAddress(*subKeyAddr)
+	if !ok {
+		rt.Abortf(exitcode.ErrNotFound, "no such verifier sub-key %v", subKeyAddr)
+	}
+
+	var st State
+	rt.StateTransaction(&st, func() {
+		subKeys, err := adt.AsMap(adt.AsStore(rt), st.VerifierSubKeys, builtin.DefaultHamtBitwidth)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to load verifier sub-keys")
+
+		var existing SubKey
+		found, err := subKeys.Get(abi.AddrKey(subKey), &existing)
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get verifier sub-key %v", subKey)
+		if !found {
+			rt.Abortf(exitcode.ErrNotFound, "no such verifier sub-key %v", subKey)
+		}
+		if existing.Verifier != rt.Caller() {
+			rt.Abortf(exitcode.ErrForbidden, "%v is not a sub-key of caller %v", subKey, rt.Caller())
+		}
+
+		err = subKeys.Delete(abi.AddrKey(subKey))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to remove verifier sub-key %v", subKey)
+
+		st.VerifierSubKeys, err = subKeys.Root()
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to flush verifier sub-keys")
+	})
+
+	return nil
+}
+
+// Returns whether a verified client's DataCap has expired.
+func clientDataCapExpired(rt runtime.Runtime, expirations *adt.Map, client addr.Address) bool {
+	var expiration cbg.CborInt
+	found, err := expirations.Get(abi.AddrKey(client), &expiration)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get expiration of verified client %v", client)
+	return found && rt.CurrEpoch() > abi.ChainEpoch(expiration)
+}
+
+// Sets a verified client's DataCap to expire ClientDataCapLifetime epochs after the current epoch,
+// or not to expire if the lifetime is zero.
+func setClientExpiration(rt runtime.Runtime, expirations *adt.Map, client addr.Address) {
+	if ClientDataCapLifetime() == 0 {
+		_, err := expirations.TryDelete(abi.AddrKey(client))
+		builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to delete expiration of verified client %v", client)
+		return
+	}
+	expiration := cbg.CborInt(rt.CurrEpoch() + ClientDataCapLifetime())
+	err := expirations.Put(abi.AddrKey(client), &expiration)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to set expiration of verified client %v", client)
+}
+
+// Returns a verifier's next DataCap removal proposal ID for a client, and increments it.
+func useProposalID(rt runtime.Runtime, proposalIDs *adt.Map, verifier, client addr.Address) RmDcProposalID {
+	key := NewAddrPairKey(verifier, client)
+	var id RmDcProposalID
+	_, err := proposalIDs.Get(key, &id)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to get DataCap removal proposal ID for verifier %v, client %v", verifier, client)
+
+	next := RmDcProposalID{ProposalID: id.ProposalID + 1}
+	err = proposalIDs.Put(key, &next)
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalState, "failed to put DataCap removal proposal ID for verifier %v, client %v", verifier, client)
+	return id
+}
+
+func verifyRemoveDataCapRequest(rt runtime.Runtime, request *RemoveDataCapRequest, proposal *RemoveDataCapProposal) {
+	buf := bytes.Buffer{}
+	buf.WriteString(SignatureDomainSeparation_RemoveDataCap)
+	err := proposal.MarshalCBOR(&buf)
+	builtin.RequireNoErr(rt, err, exitcode.ErrSerialization, "failed to marshal DataCap removal proposal")
+
+	err = rt.VerifySignature(request.VerifierSignature, request.Verifier, buf.Bytes())
+	builtin.RequireNoErr(rt, err, exitcode.ErrIllegalArgument, "invalid signature for DataCap removal by verifier %v", request.Verifier)
+}
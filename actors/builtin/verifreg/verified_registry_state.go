@@ -1,8 +1,11 @@
 package verifreg
 
 import (
+	"bytes"
+
 	addr "github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
 	cid "github.com/ipfs/go-cid"
 	"golang.org/x/xerrors"
 
@@ -23,8 +26,70 @@ type State struct {
 	// Verifiers delegate their DataCap.
 	Verifiers cid.Cid // HAMT[addr.Address]DataCap
 
+	// Sub-keys registered by verifiers to grant DataCap on their behalf, each limited by its own cap.
+	VerifierSubKeys cid.Cid // HAMT[addr.Address]SubKey
+
 	// VerifiedClients can add VerifiedClientData, up to DataCap.
 	VerifiedClients cid.Cid // HAMT[addr.Address]DataCap
+
+	// Epoch after which each verified client's remaining DataCap expires and can no longer be used.
+	// The DataCap of a client without an entry does not expire.
+	ClientExpirations cid.Cid // HAMT[addr.Address]abi.ChainEpoch
+
+	// Next ID of a proposal by a verifier to remove a client's DataCap.
+	// Each removal uses the IDs of its verifiers' proposals, so that a signed proposal cannot be replayed.
+	RemoveDataCapProposalIDs cid.Cid // HAMT[AddrPairKey]RmDcProposalID
+
+	// Cumulative DataCap each verifier has granted to verified clients.
+	VerifierAllocations cid.Cid // HAMT[addr.Address]DataCap
+
+	// Log of DataCap granted to verified clients, in order of recording.
+	// Events recorded before the retention window are pruned as later grants are recorded.
+	GrantEvents cid.Cid // AMT[uint64]GrantEvent
+	// Index of the oldest retained event, and of the next event to be recorded.
+	FirstGrantEvent uint64
+	NextGrantEvent  uint64
+}
+
+const GrantEventsAmtBitwidth = 5
+
+// A grant of DataCap by a verifier to a verified client.
+type GrantEvent struct {
+	Epoch    abi.ChainEpoch
+	Verifier addr.Address
+	// The verifier, or the verifier's sub-key which made the grant.
+	Granter addr.Address
+	Client  addr.Address
+	Amount  DataCap
+}
+
+// A key which may grant DataCap on behalf of a verifier, up to its remaining cap.
+// Grants by the sub-key are also deducted from the verifier's DataCap.
+type SubKey struct {
+	Verifier addr.Address
+	Cap      DataCap
+}
+
+// The ID of a proposal by a verifier to remove a client's DataCap.
+type RmDcProposalID struct {
+	ProposalID uint64
+}
+
+// A key of a verifier and client pair.
+type AddrPairKey struct {
+	First  addr.Address
+	Second addr.Address
+}
+
+func NewAddrPairKey(first addr.Address, second addr.Address) *AddrPairKey {
+	return &AddrPairKey{First: first, Second: second}
+}
+
+func (k *AddrPairKey) Key() string {
+	buf := new(bytes.Buffer)
+	// Marshalling fails only for an undefined address, which is never part of a key.
+	_ = k.MarshalCBOR(buf)
+	return buf.String()
 }
 
 var MinVerifiedDealSize = abi.NewStoragePower(1 << 20)
@@ -35,10 +100,70 @@ func ConstructState(store adt.Store, rootKeyAddress addr.Address) (*State, error
 	if err != nil {
 		return nil, xerrors.Errorf("failed to create empty map: %w", err)
 	}
+	emptyEventsCid, err := adt.StoreEmptyArray(store, GrantEventsAmtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create empty array: %w", err)
+	}
 
 	return &State{
-		RootKey:         rootKeyAddress,
-		Verifiers:       emptyMapCid,
-		VerifiedClients: emptyMapCid,
+		RootKey:                  rootKeyAddress,
+		Verifiers:                emptyMapCid,
+		VerifierSubKeys:          emptyMapCid,
+		VerifiedClients:          emptyMapCid,
+		ClientExpirations:        emptyMapCid,
+		RemoveDataCapProposalIDs: emptyMapCid,
+		VerifierAllocations:      emptyMapCid,
+		GrantEvents:              emptyEventsCid,
 	}, nil
 }
+
+// Adds a grant to its verifier's cumulative allocation and appends it to the log of grant events.
+// Prunes up to MaxGrantEventsPruned events recorded more than GrantEventRetention epochs before the grant.
+func (st *State) recordGrant(s adt.Store, event *GrantEvent) error {
+	allocations, err := adt.AsMap(s, st.VerifierAllocations, builtin.DefaultHamtBitwidth)
+	if err != nil {
+		return xerrors.Errorf("failed to load verifier allocations: %w", err)
+	}
+	allocated := big.Zero()
+	if _, err := allocations.Get(abi.AddrKey(event.Verifier), &allocated); err != nil {
+		return xerrors.Errorf("failed to get allocation of verifier %v: %w", event.Verifier, err)
+	}
+	allocated = big.Add(allocated, event.Amount)
+	if err := allocations.Put(abi.AddrKey(event.Verifier), &allocated); err != nil {
+		return xerrors.Errorf("failed to put allocation of verifier %v: %w", event.Verifier, err)
+	}
+	if st.VerifierAllocations, err = allocations.Root(); err != nil {
+		return xerrors.Errorf("failed to flush verifier allocations: %w", err)
+	}
+
+	events, err := adt.AsArray(s, st.GrantEvents, GrantEventsAmtBitwidth)
+	if err != nil {
+		return xerrors.Errorf("failed to load grant events: %w", err)
+	}
+	if err := events.Set(st.NextGrantEvent, event); err != nil {
+		return xerrors.Errorf("failed to set grant event %d: %w", st.NextGrantEvent, err)
+	}
+	st.NextGrantEvent++
+
+	var oldest GrantEvent
+	for pruned := uint64(0); pruned < MaxGrantEventsPruned(); pruned++ {
+		found, err := events.Get(st.FirstGrantEvent, &oldest)
+		if err != nil {
+			return xerrors.Errorf("failed to get grant event %d: %w", st.FirstGrantEvent, err)
+		}
+		if !found {
+			return xerrors.Errorf("missing grant event %d", st.FirstGrantEvent)
+		}
+		if oldest.Epoch+GrantEventRetention() >= event.Epoch {
+			break
+		}
+		if err := events.Delete(st.FirstGrantEvent); err != nil {
+			return xerrors.Errorf("failed to delete grant event %d: %w", st.FirstGrantEvent, err)
+		}
+		st.FirstGrantEvent++
+	}
+	if st.GrantEvents, err = events.Root(); err != nil {
+		return xerrors.Errorf("failed to flush grant events: %w", err)
+	}
+	return nil
+}
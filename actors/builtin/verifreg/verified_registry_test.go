@@ -1,15 +1,19 @@
 package verifreg_test
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/crypto"
 	"github.com/filecoin-project/go-state-types/exitcode"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin/verifreg"
@@ -749,6 +753,430 @@ func TestRestoreBytes(t *testing.T) {
 	})
 }
 
+func TestGrantEvents(t *testing.T) {
+	root := tutil.NewIDAddr(t, 101)
+	clientAddr := tutil.NewIDAddr(t, 201)
+	verifierAddr := tutil.NewIDAddr(t, 301)
+	vallow := big.Mul(verifreg.MinVerifiedDealSize, big.NewInt(10))
+
+	t.Run("events before the retention window are pruned by later grants", func(t *testing.T) {
+		defer func(policy verifreg.Policy) { verifreg.CurrentVerifregPolicy = policy }(verifreg.CurrentVerifregPolicy)
+		verifreg.CurrentVerifregPolicy.GrantEventRetention = 10
+		verifreg.CurrentVerifregPolicy.MaxGrantEventsPruned = 2
+
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.addVerifier(rt, verifierAddr, vallow)
+		allowance := verifreg.MinVerifiedDealSize
+		for i := int64(1); i <= 3; i++ {
+			ac.addVerifiedClient(rt, verifierAddr, clientAddr, allowance, big.Mul(allowance, big.NewInt(i)))
+		}
+
+		// An event is retained for the window after its epoch.
+		rt.SetEpoch(10)
+		ac.addVerifiedClient(rt, verifierAddr, clientAddr, allowance, big.Mul(allowance, big.NewInt(4)))
+		st := ac.state(rt)
+		assert.Equal(t, uint64(0), st.FirstGrantEvent)
+		assert.Equal(t, uint64(4), st.NextGrantEvent)
+
+		// A grant prunes a limited number of expired events.
+		rt.SetEpoch(11)
+		ac.addVerifiedClient(rt, verifierAddr, clientAddr, allowance, big.Mul(allowance, big.NewInt(5)))
+		st = ac.state(rt)
+		assert.Equal(t, uint64(2), st.FirstGrantEvent)
+		assert.Equal(t, uint64(5), st.NextGrantEvent)
+		ac.checkState(rt)
+
+		ac.addVerifiedClient(rt, verifierAddr, clientAddr, allowance, big.Mul(allowance, big.NewInt(6)))
+		st = ac.state(rt)
+		assert.Equal(t, uint64(3), st.FirstGrantEvent)
+		assert.Equal(t, uint64(6), st.NextGrantEvent)
+		ac.checkState(rt)
+
+		// Pruning does not reduce the verifier's cumulative allocation.
+		r, err := verifreg.NewStateReader(rt.AdtStore(), rt.StateRoot())
+		require.NoError(t, err)
+		allocated, err := r.VerifierAllocation(verifierAddr)
+		require.NoError(t, err)
+		assert.Equal(t, big.Mul(allowance, big.NewInt(6)), allocated)
+	})
+}
+
+func TestClientDataCapExpiration(t *testing.T) {
+	root := tutil.NewIDAddr(t, 101)
+	clientAddr := tutil.NewIDAddr(t, 201)
+	clientAddr2 := tutil.NewIDAddr(t, 202)
+	verifierAddr := tutil.NewIDAddr(t, 301)
+	vallow := big.Mul(verifreg.MinVerifiedDealSize, big.NewInt(10))
+	allowance := big.Mul(verifreg.MinVerifiedDealSize, big.NewInt(2))
+
+	setup := func(t *testing.T) (*mock.Runtime, *verifRegActorTestHarness) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.addVerifier(rt, verifierAddr, vallow)
+		return rt, ac
+	}
+
+	t.Run("expired DataCap cannot be used", func(t *testing.T) {
+		defer func(policy verifreg.Policy) { verifreg.CurrentVerifregPolicy = policy }(verifreg.CurrentVerifregPolicy)
+		verifreg.CurrentVerifregPolicy.ClientDataCapLifetime = 100
+
+		rt, ac := setup(t)
+		ac.addVerifiedClient(rt, verifierAddr, clientAddr, allowance, allowance)
+		assert.Equal(t, abi.ChainEpoch(100), ac.getClientExpiration(rt, clientAddr))
+
+		rt.SetEpoch(100)
+		ac.useBytes(rt, clientAddr, verifreg.MinVerifiedDealSize, &capExpectation{expectedCap: verifreg.MinVerifiedDealSize})
+
+		rt.SetEpoch(101)
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "has expired", func() {
+			ac.useBytes(rt, clientAddr, verifreg.MinVerifiedDealSize, nil)
+		})
+		ac.checkState(rt)
+	})
+
+	t.Run("a grant renews the expiration and replaces expired DataCap", func(t *testing.T) {
+		defer func(policy verifreg.Policy) { verifreg.CurrentVerifregPolicy = policy }(verifreg.CurrentVerifregPolicy)
+		verifreg.CurrentVerifregPolicy.ClientDataCapLifetime = 100
+
+		rt, ac := setup(t)
+		ac.addVerifiedClient(rt, verifierAddr, clientAddr, allowance, allowance)
+		rt.SetEpoch(50)
+		ac.addVerifiedClient(rt, verifierAddr, clientAddr, allowance, big.Mul(allowance, big.NewInt(2)))
+		assert.Equal(t, abi.ChainEpoch(150), ac.getClientExpiration(rt, clientAddr))
+
+		rt.SetEpoch(151)
+		ac.addVerifiedClient(rt, verifierAddr, clientAddr, allowance, allowance)
+		assert.Equal(t, abi.ChainEpoch(251), ac.getClientExpiration(rt, clientAddr))
+		ac.checkState(rt)
+	})
+
+	t.Run("grants do not expire with zero lifetime", func(t *testing.T) {
+		defer func(policy verifreg.Policy) { verifreg.CurrentVerifregPolicy = policy }(verifreg.CurrentVerifregPolicy)
+		verifreg.CurrentVerifregPolicy.ClientDataCapLifetime = 100
+
+		rt, ac := setup(t)
+		ac.addVerifiedClient(rt, verifierAddr, clientAddr, allowance, allowance)
+
+		verifreg.CurrentVerifregPolicy.ClientDataCapLifetime = 0
+		ac.addVerifiedClient(rt, verifierAddr, clientAddr, allowance, big.Mul(allowance, big.NewInt(2)))
+		assert.Equal(t, abi.ChainEpoch(-1), ac.getClientExpiration(rt, clientAddr))
+
+		rt.SetEpoch(1000)
+		ac.useBytes(rt, clientAddr, verifreg.MinVerifiedDealSize, &capExpectation{expectedCap: big.Sub(big.Mul(allowance, big.NewInt(2)), verifreg.MinVerifiedDealSize)})
+		ac.checkState(rt)
+	})
+
+	t.Run("anyone can remove expired DataCap", func(t *testing.T) {
+		defer func(policy verifreg.Policy) { verifreg.CurrentVerifregPolicy = policy }(verifreg.CurrentVerifregPolicy)
+		verifreg.CurrentVerifregPolicy.ClientDataCapLifetime = 100
+
+		rt, ac := setup(t)
+		ac.addVerifiedClient(rt, verifierAddr, clientAddr, allowance, allowance)
+		rt.SetEpoch(80)
+		ac.addVerifiedClient(rt, verifierAddr, clientAddr2, allowance, allowance)
+
+		rt.SetEpoch(101)
+		ret := ac.removeExpiredDataCap(rt, clientAddr, clientAddr2, tutil.NewIDAddr(t, 203))
+		assert.Equal(t, []address.Address{clientAddr}, ret.Removed)
+		assert.Equal(t, allowance, ret.DataCapRemoved)
+		ac.assertClientRemoved(rt, clientAddr)
+		assert.Equal(t, allowance, ac.getClientCap(rt, clientAddr2))
+		ac.checkState(rt)
+	})
+
+	t.Run("fails to remove expired DataCap of too many clients", func(t *testing.T) {
+		defer func(policy verifreg.Policy) { verifreg.CurrentVerifregPolicy = policy }(verifreg.CurrentVerifregPolicy)
+		verifreg.CurrentVerifregPolicy.MaxExpiredDataCapRemovals = 1
+
+		rt, ac := setup(t)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "too many clients", func() {
+			ac.removeExpiredDataCap(rt, clientAddr, clientAddr2)
+		})
+	})
+}
+
+func TestVerifierSubKeys(t *testing.T) {
+	root := tutil.NewIDAddr(t, 101)
+	clientAddr := tutil.NewIDAddr(t, 201)
+	verifierAddr := tutil.NewIDAddr(t, 301)
+	verifierAddr2 := tutil.NewIDAddr(t, 302)
+	subKeyAddr := tutil.NewIDAddr(t, 401)
+	vallow := big.Mul(verifreg.MinVerifiedDealSize, big.NewInt(10))
+	allowance := verifreg.MinVerifiedDealSize
+	subKeyCap := big.Mul(verifreg.MinVerifiedDealSize, big.NewInt(3))
+
+	setup := func(t *testing.T) (*mock.Runtime, *verifRegActorTestHarness) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.addVerifier(rt, verifierAddr, vallow)
+		ac.addVerifierSubKey(rt, verifierAddr, subKeyAddr, subKeyCap)
+		return rt, ac
+	}
+
+	t.Run("sub-key grants DataCap on behalf of its verifier", func(t *testing.T) {
+		rt, ac := setup(t)
+		ac.addVerifiedClient(rt, subKeyAddr, clientAddr, allowance, allowance)
+
+		assert.Equal(t, big.Sub(vallow, allowance), ac.getVerifierCap(rt, verifierAddr))
+		subKey, found := ac.getSubKey(rt, subKeyAddr)
+		require.True(t, found)
+		assert.Equal(t, verifreg.SubKey{Verifier: verifierAddr, Cap: big.Sub(subKeyCap, allowance)}, subKey)
+
+		// The grant is recorded against the verifier.
+		r, err := verifreg.NewStateReader(rt.AdtStore(), rt.StateRoot())
+		require.NoError(t, err)
+		events, err := r.ClientGrants(clientAddr)
+		require.NoError(t, err)
+		assert.Equal(t, []verifreg.GrantEvent{{Verifier: verifierAddr, Granter: subKeyAddr, Client: clientAddr, Amount: allowance}}, events)
+		ac.checkState(rt)
+	})
+
+	t.Run("sub-key grants are limited by its cap", func(t *testing.T) {
+		rt, ac := setup(t)
+		rt.SetCaller(subKeyAddr, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "than sub-key", func() {
+			rt.Call(ac.AddVerifiedClient, mkClientParams(clientAddr, big.Add(subKeyCap, big.NewInt(1))))
+		})
+		ac.checkState(rt)
+	})
+
+	t.Run("sub-key grants are limited by its verifier's DataCap", func(t *testing.T) {
+		rt, ac := setup(t)
+		ac.addVerifierSubKey(rt, verifierAddr, subKeyAddr, big.Add(vallow, big.NewInt(1)))
+		rt.SetCaller(subKeyAddr, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "than allocated", func() {
+			rt.Call(ac.AddVerifiedClient, mkClientParams(clientAddr, big.Add(vallow, big.NewInt(1))))
+		})
+		ac.checkState(rt)
+	})
+
+	t.Run("re-adding a sub-key replaces its cap", func(t *testing.T) {
+		rt, ac := setup(t)
+		ac.addVerifierSubKey(rt, verifierAddr, subKeyAddr, allowance)
+		subKey, found := ac.getSubKey(rt, subKeyAddr)
+		require.True(t, found)
+		assert.Equal(t, allowance, subKey.Cap)
+		ac.checkState(rt)
+	})
+
+	t.Run("revoked sub-key cannot grant DataCap", func(t *testing.T) {
+		rt, ac := setup(t)
+		ac.removeVerifierSubKey(rt, verifierAddr, subKeyAddr)
+		_, found := ac.getSubKey(rt, subKeyAddr)
+		assert.False(t, found)
+
+		rt.SetCaller(subKeyAddr, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no such verifier", func() {
+			rt.Call(ac.AddVerifiedClient, mkClientParams(clientAddr, allowance))
+		})
+		ac.checkState(rt)
+	})
+
+	t.Run("sub-key of a removed verifier cannot grant DataCap but can be revoked", func(t *testing.T) {
+		rt, ac := setup(t)
+		ac.removeVerifier(rt, verifierAddr)
+
+		rt.SetCaller(subKeyAddr, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no such verifier", func() {
+			rt.Call(ac.AddVerifiedClient, mkClientParams(clientAddr, allowance))
+		})
+		rt.Reset()
+
+		ac.removeVerifierSubKey(rt, verifierAddr, subKeyAddr)
+		ac.checkState(rt)
+	})
+
+	t.Run("only the verifier can revoke its sub-key", func(t *testing.T) {
+		rt, ac := setup(t)
+		ac.addVerifier(rt, verifierAddr2, vallow)
+		rt.SetCaller(verifierAddr2, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "is not a sub-key of caller", func() {
+			rt.Call(ac.RemoveVerifierSubKey, &subKeyAddr)
+		})
+		rt.Reset()
+
+		rt.SetCaller(verifierAddr, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAny()
+		unknown := tutil.NewIDAddr(t, 402)
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no such verifier sub-key", func() {
+			rt.Call(ac.RemoveVerifierSubKey, &unknown)
+		})
+	})
+
+	t.Run("only a verifier can add a sub-key", func(t *testing.T) {
+		rt, ac := setup(t)
+		rt.SetCaller(clientAddr, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no such verifier", func() {
+			rt.Call(ac.AddVerifierSubKey, &verifreg.AddVerifierSubKeyParams{SubKey: tutil.NewIDAddr(t, 402), Cap: allowance})
+		})
+		rt.Reset()
+
+		// A sub-key cannot register sub-keys of its own.
+		rt.SetCaller(subKeyAddr, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "no such verifier", func() {
+			rt.Call(ac.AddVerifierSubKey, &verifreg.AddVerifierSubKeyParams{SubKey: tutil.NewIDAddr(t, 402), Cap: allowance})
+		})
+	})
+
+	t.Run("fails to add an invalid sub-key", func(t *testing.T) {
+		rt, ac := setup(t)
+		ac.addVerifier(rt, verifierAddr2, vallow)
+		ac.addVerifiedClient(rt, verifierAddr, clientAddr, allowance, allowance)
+
+		for _, tc := range []struct {
+			subKey address.Address
+			cap    verifreg.DataCap
+			code   exitcode.ExitCode
+			msg    string
+		}{
+			{root, allowance, exitcode.ErrIllegalArgument, "Rootkey cannot be added"},
+			{verifierAddr2, allowance, exitcode.ErrIllegalArgument, "verifier"},
+			{clientAddr, allowance, exitcode.ErrIllegalArgument, "verified client"},
+			{tutil.NewIDAddr(t, 402), big.NewInt(-1), exitcode.ErrIllegalArgument, "negative cap"},
+		} {
+			rt.SetCaller(verifierAddr, builtin.AccountActorCodeID)
+			rt.ExpectValidateCallerAny()
+			rt.ExpectAbortContainsMessage(tc.code, tc.msg, func() {
+				rt.Call(ac.AddVerifierSubKey, &verifreg.AddVerifierSubKeyParams{SubKey: tc.subKey, Cap: tc.cap})
+			})
+			rt.Reset()
+		}
+
+		// Another verifier's sub-key.
+		rt.SetCaller(verifierAddr2, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbortContainsMessage(exitcode.ErrForbidden, "already a sub-key", func() {
+			rt.Call(ac.AddVerifierSubKey, &verifreg.AddVerifierSubKeyParams{SubKey: subKeyAddr, Cap: allowance})
+		})
+		ac.checkState(rt)
+	})
+
+	t.Run("sub-key cannot become a verifier or verified client", func(t *testing.T) {
+		rt, ac := setup(t)
+		rt.SetCaller(root, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAddr(root)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "cannot become a verifier", func() {
+			rt.Call(ac.AddVerifier, mkVerifierParams(subKeyAddr, vallow))
+		})
+		rt.Reset()
+
+		rt.SetCaller(verifierAddr, builtin.AccountActorCodeID)
+		rt.ExpectValidateCallerAny()
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "cannot be added as a verified client", func() {
+			rt.Call(ac.AddVerifiedClient, mkClientParams(subKeyAddr, allowance))
+		})
+		ac.checkState(rt)
+	})
+}
+
+func TestRemoveVerifiedClientDataCap(t *testing.T) {
+	root := tutil.NewIDAddr(t, 101)
+	clientAddr := tutil.NewIDAddr(t, 201)
+	verifierAddr := tutil.NewIDAddr(t, 301)
+	verifierAddr2 := tutil.NewIDAddr(t, 302)
+	vallow := big.Mul(verifreg.MinVerifiedDealSize, big.NewInt(10))
+	clientAllowance := big.Mul(verifreg.MinVerifiedDealSize, big.NewInt(3))
+	expiration := abi.ChainEpoch(100)
+
+	setup := func(t *testing.T) (*mock.Runtime, *verifRegActorTestHarness) {
+		rt, ac := basicVerifRegSetup(t, root)
+		ac.addVerifier(rt, verifierAddr, vallow)
+		ac.addVerifier(rt, verifierAddr2, vallow)
+		ac.addVerifiedClient(rt, verifierAddr, clientAddr, clientAllowance, clientAllowance)
+		return rt, ac
+	}
+
+	t.Run("removes DataCap with proposals of two verifiers", func(t *testing.T) {
+		rt, ac := setup(t)
+
+		ret := ac.removeDataCap(rt, mkRemoveDataCapParams(clientAddr, verifreg.MinVerifiedDealSize, expiration, verifierAddr, verifierAddr2), 0, 0)
+		assert.Equal(t, clientAddr, ret.VerifiedClient)
+		assert.Equal(t, verifreg.MinVerifiedDealSize, ret.DataCapRemoved)
+		assert.Equal(t, big.Sub(clientAllowance, verifreg.MinVerifiedDealSize), ac.getClientCap(rt, clientAddr))
+		ac.checkState(rt)
+
+		// The next removal uses the verifiers' next proposal IDs.
+		// Removing more than the client's DataCap removes the client.
+		ret = ac.removeDataCap(rt, mkRemoveDataCapParams(clientAddr, clientAllowance, expiration, verifierAddr2, verifierAddr), 1, 1)
+		assert.Equal(t, big.Sub(clientAllowance, verifreg.MinVerifiedDealSize), ret.DataCapRemoved)
+		ac.assertClientRemoved(rt, clientAddr)
+		ac.checkState(rt)
+	})
+
+	t.Run("fails with a replayed proposal", func(t *testing.T) {
+		rt, ac := setup(t)
+		params := mkRemoveDataCapParams(clientAddr, verifreg.MinVerifiedDealSize, expiration, verifierAddr, verifierAddr2)
+		ac.removeDataCap(rt, params, 0, 0)
+
+		// The signatures were made for proposal ID 0, so do not verify for ID 1.
+		rt.ExpectValidateCallerAddr(ac.rootkey)
+		rt.SetCaller(ac.rootkey, builtin.VerifiedRegistryActorCodeID)
+		rt.ExpectVerifySignature(params.VerifierRequest1.VerifierSignature, verifierAddr,
+			removeDataCapSigningBytes(t, clientAddr, params.DataCapAmountToRemove, 1, expiration), xerrors.New("bad signature"))
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "invalid signature", func() {
+			rt.Call(ac.RemoveVerifiedClientDataCap, params)
+		})
+		assert.Equal(t, big.Sub(clientAllowance, verifreg.MinVerifiedDealSize), ac.getClientCap(rt, clientAddr))
+		ac.checkState(rt)
+	})
+
+	t.Run("fails with the same verifier twice", func(t *testing.T) {
+		rt, ac := setup(t)
+		rt.ExpectValidateCallerAddr(ac.rootkey)
+		rt.SetCaller(ac.rootkey, builtin.VerifiedRegistryActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "two different verifiers", func() {
+			rt.Call(ac.RemoveVerifiedClientDataCap, mkRemoveDataCapParams(clientAddr, verifreg.MinVerifiedDealSize, expiration, verifierAddr, verifierAddr))
+		})
+		ac.checkState(rt)
+	})
+
+	t.Run("fails if a proposer is not a verifier", func(t *testing.T) {
+		rt, ac := setup(t)
+		rt.ExpectValidateCallerAddr(ac.rootkey)
+		rt.SetCaller(ac.rootkey, builtin.VerifiedRegistryActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "is not a verifier", func() {
+			rt.Call(ac.RemoveVerifiedClientDataCap, mkRemoveDataCapParams(clientAddr, verifreg.MinVerifiedDealSize, expiration, verifierAddr, tutil.NewIDAddr(t, 303)))
+		})
+		ac.checkState(rt)
+	})
+
+	t.Run("fails for an unknown client", func(t *testing.T) {
+		rt, ac := setup(t)
+		rt.ExpectValidateCallerAddr(ac.rootkey)
+		rt.SetCaller(ac.rootkey, builtin.VerifiedRegistryActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrNotFound, "is not a verified client", func() {
+			rt.Call(ac.RemoveVerifiedClientDataCap, mkRemoveDataCapParams(tutil.NewIDAddr(t, 202), verifreg.MinVerifiedDealSize, expiration, verifierAddr, verifierAddr2))
+		})
+		ac.checkState(rt)
+	})
+
+	t.Run("fails after expiration", func(t *testing.T) {
+		rt, ac := setup(t)
+		rt.SetEpoch(expiration + 1)
+		rt.ExpectValidateCallerAddr(ac.rootkey)
+		rt.SetCaller(ac.rootkey, builtin.VerifiedRegistryActorCodeID)
+		rt.ExpectAbortContainsMessage(exitcode.ErrIllegalArgument, "expired", func() {
+			rt.Call(ac.RemoveVerifiedClientDataCap, mkRemoveDataCapParams(clientAddr, verifreg.MinVerifiedDealSize, expiration, verifierAddr, verifierAddr2))
+		})
+		ac.checkState(rt)
+	})
+
+	t.Run("fails when caller is not the root key", func(t *testing.T) {
+		rt, ac := setup(t)
+		rt.ExpectValidateCallerAddr(ac.rootkey)
+		rt.SetCaller(verifierAddr, builtin.VerifiedRegistryActorCodeID)
+		rt.ExpectAbort(exitcode.SysErrForbidden, func() {
+			rt.Call(ac.RemoveVerifiedClientDataCap, mkRemoveDataCapParams(clientAddr, verifreg.MinVerifiedDealSize, expiration, verifierAddr, verifierAddr2))
+		})
+		ac.checkState(rt)
+	})
+}
+
 type verifRegActorTestHarness struct {
 	rootkey address.Address
 	verifreg.Actor
@@ -846,6 +1274,21 @@ func (h *verifRegActorTestHarness) removeVerifier(rt *mock.Runtime, verifier add
 	h.assertVerifierRemoved(rt, verifier)
 }
 
+func (h *verifRegActorTestHarness) removeDataCap(rt *mock.Runtime, params *verifreg.RemoveDataCapParams, proposalID1, proposalID2 uint64) *verifreg.RemoveDataCapReturn {
+	rt.ExpectValidateCallerAddr(h.rootkey)
+	rt.SetCaller(h.rootkey, builtin.VerifiedRegistryActorCodeID)
+	for _, r := range []struct {
+		request    verifreg.RemoveDataCapRequest
+		proposalID uint64
+	}{{params.VerifierRequest1, proposalID1}, {params.VerifierRequest2, proposalID2}} {
+		rt.ExpectVerifySignature(r.request.VerifierSignature, r.request.Verifier,
+			removeDataCapSigningBytes(h.t, params.VerifiedClientToRemove, params.DataCapAmountToRemove, r.proposalID, params.Expiration), nil)
+	}
+	ret := rt.Call(h.RemoveVerifiedClientDataCap, params).(*verifreg.RemoveDataCapReturn)
+	rt.Verify()
+	return ret
+}
+
 type capExpectation struct {
 	expectedCap verifreg.DataCap
 	removed     bool
@@ -917,6 +1360,60 @@ func (h *verifRegActorTestHarness) getClientCap(rt *mock.Runtime, a address.Addr
 	return dc
 }
 
+func (h *verifRegActorTestHarness) removeExpiredDataCap(rt *mock.Runtime, clients ...address.Address) *verifreg.RemoveExpiredDataCapReturn {
+	rt.ExpectValidateCallerAny()
+	rt.SetCaller(tutil.NewIDAddr(h.t, 1000), builtin.AccountActorCodeID)
+	ret := rt.Call(h.RemoveExpiredDataCap, &verifreg.RemoveExpiredDataCapParams{VerifiedClients: clients}).(*verifreg.RemoveExpiredDataCapReturn)
+	rt.Verify()
+	return ret
+}
+
+// Returns the epoch after which a client's DataCap expires, or -1 if it does not expire.
+func (h *verifRegActorTestHarness) getClientExpiration(rt *mock.Runtime, a address.Address) abi.ChainEpoch {
+	var st verifreg.State
+	rt.GetState(&st)
+
+	expirations, err := adt.AsMap(adt.AsStore(rt), st.ClientExpirations, builtin.DefaultHamtBitwidth)
+	require.NoError(h.t, err)
+
+	var expiration cbg.CborInt
+	found, err := expirations.Get(abi.AddrKey(a), &expiration)
+	require.NoError(h.t, err)
+	if !found {
+		return -1
+	}
+	return abi.ChainEpoch(expiration)
+}
+
+func (h *verifRegActorTestHarness) addVerifierSubKey(rt *mock.Runtime, verifier, subKey address.Address, cap verifreg.DataCap) {
+	rt.SetCaller(verifier, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.AddVerifierSubKey, &verifreg.AddVerifierSubKeyParams{SubKey: subKey, Cap: cap})
+	rt.Verify()
+	assert.Nil(h.t, ret)
+}
+
+func (h *verifRegActorTestHarness) removeVerifierSubKey(rt *mock.Runtime, verifier, subKey address.Address) {
+	rt.SetCaller(verifier, builtin.AccountActorCodeID)
+	rt.ExpectValidateCallerAny()
+	ret := rt.Call(h.RemoveVerifierSubKey, &subKey)
+	rt.Verify()
+	assert.Nil(h.t, ret)
+}
+
+func (h *verifRegActorTestHarness) getSubKey(rt *mock.Runtime, a address.Address) (verifreg.SubKey, bool) {
+	var st verifreg.State
+	rt.GetState(&st)
+
+	subKeys, err := adt.AsMap(adt.AsStore(rt), st.VerifierSubKeys, builtin.DefaultHamtBitwidth)
+	require.NoError(h.t, err)
+
+	var subKey verifreg.SubKey
+	found, err := subKeys.Get(abi.AddrKey(a), &subKey)
+	require.NoError(h.t, err)
+	return subKey, found
+}
+
 func (h *verifRegActorTestHarness) assertVerifierRemoved(rt *mock.Runtime, a address.Address) {
 	var st verifreg.State
 	rt.GetState(&st)
@@ -950,3 +1447,25 @@ func mkVerifierParams(a address.Address, allowance verifreg.DataCap) *verifreg.A
 func mkClientParams(a address.Address, cap verifreg.DataCap) *verifreg.AddVerifiedClientParams {
 	return &verifreg.AddVerifiedClientParams{Address: a, Allowance: cap}
 }
+
+func mkRemoveDataCapParams(client address.Address, amount verifreg.DataCap, expiration abi.ChainEpoch, verifier1, verifier2 address.Address) *verifreg.RemoveDataCapParams {
+	return &verifreg.RemoveDataCapParams{
+		VerifiedClientToRemove: client,
+		DataCapAmountToRemove:  amount,
+		Expiration:             expiration,
+		VerifierRequest1:       verifreg.RemoveDataCapRequest{Verifier: verifier1, VerifierSignature: crypto.Signature{Type: crypto.SigTypeBLS, Data: verifier1.Bytes()}},
+		VerifierRequest2:       verifreg.RemoveDataCapRequest{Verifier: verifier2, VerifierSignature: crypto.Signature{Type: crypto.SigTypeBLS, Data: verifier2.Bytes()}},
+	}
+}
+
+func removeDataCapSigningBytes(t testing.TB, client address.Address, amount verifreg.DataCap, proposalID uint64, expiration abi.ChainEpoch) []byte {
+	proposal := verifreg.RemoveDataCapProposal{
+		VerifiedClient:    client,
+		DataCapAmount:     amount,
+		RemovalProposalID: verifreg.RmDcProposalID{ProposalID: proposalID},
+		Expiration:        expiration,
+	}
+	buf := bytes.NewBufferString(verifreg.SignatureDomainSeparation_RemoveDataCap)
+	require.NoError(t, proposal.MarshalCBOR(buf))
+	return buf.Bytes()
+}
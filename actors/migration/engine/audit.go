@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+)
+
+// Kinds of audit record.
+const (
+	AuditActorDeleted       = "actor_deleted"
+	AuditClaimRemoved       = "claim_removed"
+	AuditBalanceTransferred = "balance_transferred"
+)
+
+// A record of state removed or mutated by a migration, e.g. to explain to users why an actor disappeared.
+// Fields which don't apply to the kind of record are omitted.
+type AuditRecord struct {
+	Event string `json:"event"`
+	// The actor deleted, whose claim was removed, or from which a balance was transferred (if known).
+	Address string `json:"address,omitempty"`
+	// Prior code CID of a deleted actor.
+	Code string `json:"code,omitempty"`
+	// Balance of a deleted actor, or the amount transferred.
+	Amount string `json:"amount,omitempty"`
+	// The intended recipient of a transfer (e.g. a deleted miner's owner), and the actor actually credited,
+	// which differs if the intended recipient was ineligible.
+	Recipient  string `json:"recipient,omitempty"`
+	CreditedTo string `json:"credited_to,omitempty"`
+	// Power of a removed claim.
+	RawBytePower    string `json:"raw_byte_power,omitempty"`
+	QualityAdjPower string `json:"quality_adj_power,omitempty"`
+}
+
+// Writes audit records as JSON lines, one object per line.
+// Safe for concurrent use. A nil log records nothing.
+type AuditLog struct {
+	lk  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{enc: json.NewEncoder(w)}
+}
+
+func (l *AuditLog) Record(r AuditRecord) error {
+	if l == nil {
+		return nil
+	}
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	if err := l.enc.Encode(r); err != nil {
+		return xerrors.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+func (l *AuditLog) actorDeleted(addr address.Address, code cid.Cid, balance abi.TokenAmount) error {
+	return l.Record(AuditRecord{
+		Event:   AuditActorDeleted,
+		Address: addr.String(),
+		Code:    code.String(),
+		Amount:  balance.String(),
+	})
+}
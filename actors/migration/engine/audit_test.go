@@ -0,0 +1,68 @@
+package engine_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	actorsIn := newTree(t, store, map[uint64]cid.Cid{99: newCode, 100: oldCode, 101: oldDeferred})
+	doomed := tutil.NewIDAddr(t, 101)
+	require.NoError(t, actorsIn.SetActor(doomed, &states.Actor{Code: oldDeferred, Head: oldDeferred, Balance: abi.NewTokenAmount(7)}))
+
+	var buf bytes.Buffer
+	audit := engine.NewAuditLog(&buf)
+	m := &engine.Migration{Migrations: map[cid.Cid]engine.ActorMigration{
+		newCode:     engine.CodeMigrator{OutCodeCID: newCode},
+		oldCode:     engine.CodeMigrator{OutCodeCID: newCode},
+		oldDeferred: deleteMigrator{},
+	}}
+	actorsOut, err := states.NewTree(store)
+	require.NoError(t, err)
+	cfg := engine.Config{MaxWorkers: 2, AuditLog: audit}
+	_, _, err = m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+	require.NoError(t, err)
+
+	// Transfer the deleted actor's balance to an absent owner, so it is credited to the fallback.
+	fallback := tutil.NewIDAddr(t, 99)
+	owner := tutil.NewIDAddr(t, 200)
+	transfers := engine.NewTransferAccumulator(fallback, nil)
+	transfers.SetAuditLog(audit)
+	require.NoError(t, transfers.AddFrom(doomed, owner, abi.NewTokenAmount(7)))
+	require.NoError(t, transfers.Apply(actorsOut))
+
+	var records []engine.AuditRecord
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var r engine.AuditRecord
+		require.NoError(t, dec.Decode(&r))
+		records = append(records, r)
+	}
+	assert.Equal(t, []engine.AuditRecord{{
+		Event:   engine.AuditActorDeleted,
+		Address: doomed.String(),
+		Code:    oldDeferred.String(),
+		Amount:  "7",
+	}, {
+		Event:      engine.AuditBalanceTransferred,
+		Address:    doomed.String(),
+		Amount:     "7",
+		Recipient:  owner.String(),
+		CreditedTo: fallback.String(),
+	}}, records)
+}
@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"context"
+
+	"golang.org/x/sync/semaphore"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+)
+
+// An ActorMigration may implement SizeEstimator to inform the in-flight memory budget (see Config.MaxInFlightBytes).
+// Migrations which don't implement it are charged the encoded size of the actor record.
+type SizeEstimator interface {
+	// Returns the approximate number of bytes held in memory while migrating an actor, until its result is written.
+	EstimateSize(actor *states.Actor) uint64
+}
+
+// Limits the approximate total size of jobs between creation and their results being written.
+// A nil budget imposes no limit.
+type inFlightBudget struct {
+	sem *semaphore.Weighted
+	max uint64
+}
+
+func newInFlightBudget(maxBytes uint64) *inFlightBudget {
+	if maxBytes == 0 {
+		return nil
+	}
+	return &inFlightBudget{sem: semaphore.NewWeighted(int64(maxBytes)), max: maxBytes}
+}
+
+// Blocks until size bytes are available in the budget, returning the amount reserved.
+// A job larger than the whole budget reserves all of it, so it runs alone rather than never.
+func (b *inFlightBudget) acquire(ctx context.Context, size uint64) (uint64, error) {
+	if b == nil {
+		return 0, nil
+	}
+	if size > b.max {
+		size = b.max
+	}
+	if err := b.sem.Acquire(ctx, int64(size)); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+func (b *inFlightBudget) release(size uint64) {
+	if b == nil || size == 0 {
+		return
+	}
+	b.sem.Release(int64(size))
+}
+
+// Estimates the memory held by a job for an actor.
+func estimateJobSize(m ActorMigration, actor *states.Actor) uint64 {
+	if est, ok := m.(SizeEstimator); ok {
+		return est.EstimateSize(actor)
+	}
+	var w countingWriter
+	if err := actor.MarshalCBOR(&w); err != nil {
+		return 0
+	}
+	return uint64(w)
+}
@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"sync/atomic"
+
+	"github.com/ipfs/go-cid"
+)
+
+// A MigrationCache wrapper which counts lookups served from the cache (hits) and not (misses), and the entries
+// written. The migration engine wraps the cache it is given, reporting these counts in MigrationStats.
+// Safe for concurrent use if the underlying cache is.
+type MeteredCache struct {
+	MigrationCache
+	hits       uint64
+	misses     uint64
+	writes     uint64
+	writeBytes uint64
+}
+
+// Wraps a cache to count lookups and writes.
+func NewMeteredCache(cache MigrationCache) *MeteredCache {
+	return &MeteredCache{MigrationCache: cache}
+}
+
+func (c *MeteredCache) Write(key string, value cid.Cid) error {
+	if err := c.MigrationCache.Write(key, value); err != nil {
+		return err
+	}
+	c.recordWrite(key, value)
+	return nil
+}
+
+func (c *MeteredCache) Read(key string) (bool, cid.Cid, error) {
+	found, value, err := c.MigrationCache.Read(key)
+	if err != nil {
+		return found, value, err
+	}
+	if found {
+		atomic.AddUint64(&c.hits, 1)
+	} else {
+		atomic.AddUint64(&c.misses, 1)
+	}
+	return found, value, nil
+}
+
+func (c *MeteredCache) Load(key string, loadFunc func() (cid.Cid, error)) (cid.Cid, error) {
+	loaded := false
+	value, err := c.MigrationCache.Load(key, func() (cid.Cid, error) {
+		loaded = true
+		return loadFunc()
+	})
+	if err != nil {
+		return value, err
+	}
+	if loaded {
+		atomic.AddUint64(&c.misses, 1)
+		c.recordWrite(key, value)
+	} else {
+		atomic.AddUint64(&c.hits, 1)
+	}
+	return value, nil
+}
+
+func (c *MeteredCache) Hits() uint64 {
+	return atomic.LoadUint64(&c.hits)
+}
+
+func (c *MeteredCache) Misses() uint64 {
+	return atomic.LoadUint64(&c.misses)
+}
+
+func (c *MeteredCache) WriteCount() uint64 {
+	return atomic.LoadUint64(&c.writes)
+}
+
+// Total size of the keys and values written.
+func (c *MeteredCache) WriteSize() uint64 {
+	return atomic.LoadUint64(&c.writeBytes)
+}
+
+// Fraction of lookups served from the cache, or zero if there were none.
+func (c *MeteredCache) WarmRate() float64 {
+	return warmRate(c.Hits(), c.Misses())
+}
+
+func (c *MeteredCache) recordWrite(key string, value cid.Cid) {
+	size := uint64(len(key))
+	if value.Defined() {
+		size += uint64(value.ByteLen())
+	}
+	atomic.AddUint64(&c.writes, 1)
+	atomic.AddUint64(&c.writeBytes, size)
+}
+
+func warmRate(hits, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
@@ -0,0 +1,70 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestMeteredCache(t *testing.T) {
+	cache := engine.NewMeteredCache(engine.NewMemMigrationCache())
+	cid1 := tutil.MakeCID("1", nil)
+	assert.Zero(t, cache.WarmRate())
+
+	found, _, err := cache.Read("a")
+	require.NoError(t, err)
+	assert.False(t, found)
+	require.NoError(t, cache.Write("a", cid1))
+	found, _, err = cache.Read("a")
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	_, err = cache.Load("b", func() (cid.Cid, error) { return cid.Undef, nil })
+	require.NoError(t, err)
+	_, err = cache.Load("b", func() (cid.Cid, error) { return cid1, nil })
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(2), cache.Hits())
+	assert.Equal(t, uint64(2), cache.Misses())
+	assert.Equal(t, 0.5, cache.WarmRate())
+	assert.Equal(t, uint64(2), cache.WriteCount())
+	assert.Equal(t, uint64(2+cid1.ByteLen()), cache.WriteSize())
+}
+
+func TestMigrationCacheStats(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	codes := map[uint64]cid.Cid{}
+	for id := uint64(100); id < 110; id++ {
+		codes[id] = oldCode
+	}
+	actorsIn := newTree(t, store, codes)
+	var calls int32
+	m := &engine.Migration{Migrations: map[cid.Cid]engine.ActorMigration{oldCode: headMigrator{&calls}}}
+	cache := engine.NewMemMigrationCache()
+	log := engine.TestLogger{TB: t}
+	require.NoError(t, m.PreMigrateStateTree(ctx, store, actorsIn, 0, engine.Config{MaxWorkers: 2}, log, cache))
+
+	// Change the heads of two actors after the pre-migration.
+	for id := uint64(100); id < 102; id++ {
+		require.NoError(t, actorsIn.SetActor(tutil.NewIDAddr(t, id), &states.Actor{Code: oldCode, Head: oldCode, Balance: big.Zero()}))
+	}
+	actorsOut, err := states.NewTree(store)
+	require.NoError(t, err)
+	_, stats, err := m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, engine.Config{MaxWorkers: 2}, log, cache)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(8), stats.CacheHits)
+	assert.Equal(t, uint64(2), stats.CacheMisses)
+	assert.Equal(t, uint64(2), stats.CacheWrites)
+	assert.Equal(t, 0.8, stats.CacheWarmRate())
+}
@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// An IpldStore wrapper which caches the raw bytes of recently read blocks, up to a bounded total size, evicting
+// the least recently used. Blocks shared between actors (such as the interior nodes of shared HAMTs) are then
+// fetched once rather than by every worker which reads them.
+// Only reads into a cbg.CBORUnmarshaler are cached; others pass through to the underlying store, as do writes.
+// Cached objects are decoded afresh on each read, so callers may mutate the objects they read.
+// The store is safe for concurrent use if the underlying store is.
+type CachingStore struct {
+	cbor.IpldStore
+	maxBytes uint64
+
+	lk      sync.Mutex
+	entries map[cid.Cid]*list.Element
+	lru     *list.List // Of *cachedBlock, most recently used at the front.
+	size    uint64
+
+	hits   uint64
+	misses uint64
+}
+
+type cachedBlock struct {
+	c   cid.Cid
+	raw []byte
+}
+
+// Wraps a store with a read cache holding up to maxBytes of block data.
+func NewCachingStore(store cbor.IpldStore, maxBytes uint64) *CachingStore {
+	return &CachingStore{
+		IpldStore: store,
+		maxBytes:  maxBytes,
+		entries:   map[cid.Cid]*list.Element{},
+		lru:       list.New(),
+	}
+}
+
+func (s *CachingStore) Get(ctx context.Context, c cid.Cid, out interface{}) error {
+	um, ok := out.(cbg.CBORUnmarshaler)
+	if !ok {
+		return s.IpldStore.Get(ctx, c, out)
+	}
+	if raw, found := s.lookup(c); found {
+		atomic.AddUint64(&s.hits, 1)
+		return um.UnmarshalCBOR(bytes.NewReader(raw))
+	}
+	atomic.AddUint64(&s.misses, 1)
+	var d cbg.Deferred
+	if err := s.IpldStore.Get(ctx, c, &d); err != nil {
+		return err
+	}
+	s.insert(c, d.Raw)
+	return um.UnmarshalCBOR(bytes.NewReader(d.Raw))
+}
+
+// Number of cacheable reads served from the cache.
+func (s *CachingStore) Hits() uint64 {
+	return atomic.LoadUint64(&s.hits)
+}
+
+// Number of cacheable reads passed to the underlying store.
+func (s *CachingStore) Misses() uint64 {
+	return atomic.LoadUint64(&s.misses)
+}
+
+// Total size of the blocks currently cached.
+func (s *CachingStore) Size() uint64 {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.size
+}
+
+func (s *CachingStore) lookup(c cid.Cid) ([]byte, bool) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	elem, found := s.entries[c]
+	if !found {
+		return nil, false
+	}
+	s.lru.MoveToFront(elem)
+	return elem.Value.(*cachedBlock).raw, true
+}
+
+func (s *CachingStore) insert(c cid.Cid, raw []byte) {
+	size := uint64(len(raw))
+	if size > s.maxBytes {
+		return
+	}
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	// Another worker may have read the same block concurrently.
+	if _, found := s.entries[c]; found {
+		return
+	}
+	for s.size+size > s.maxBytes {
+		oldest := s.lru.Back()
+		blk := s.lru.Remove(oldest).(*cachedBlock)
+		delete(s.entries, blk.c)
+		s.size -= uint64(len(blk.raw))
+	}
+	s.entries[c] = s.lru.PushFront(&cachedBlock{c: c, raw: raw})
+	s.size += size
+}
@@ -0,0 +1,104 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+)
+
+func TestCachingStore(t *testing.T) {
+	ctx := context.Background()
+	underlying := engine.NewMeteredStore(adt.WrapBlockStore(ctx, ipld.NewBlockStoreInMemory()))
+	put := func(nonce uint64) cid.Cid {
+		c, err := underlying.Put(ctx, &states.Actor{Code: oldCode, Head: oldCode, CallSeqNum: nonce, Balance: big.Zero()})
+		require.NoError(t, err)
+		return c
+	}
+	a, b, c := put(1), put(2), put(3)
+
+	// Room for two blocks.
+	var blockSize uint64
+	{
+		var actor states.Actor
+		require.NoError(t, underlying.Get(ctx, a, &actor))
+		blockSize = underlying.ReadSize()
+	}
+	store := engine.NewCachingStore(underlying, 2*blockSize)
+	get := func(k cid.Cid) *states.Actor {
+		var actor states.Actor
+		require.NoError(t, store.Get(ctx, k, &actor))
+		return &actor
+	}
+
+	get(a)
+	get(b)
+	assert.Equal(t, uint64(0), store.Hits())
+	assert.Equal(t, uint64(2), store.Misses())
+	assert.Equal(t, 2*blockSize, store.Size())
+
+	// Reads are decoded afresh, so mutating a result doesn't affect the cache.
+	actor := get(a)
+	assert.Equal(t, uint64(1), actor.CallSeqNum)
+	actor.CallSeqNum = 10
+	assert.Equal(t, uint64(1), get(a).CallSeqNum)
+	assert.Equal(t, uint64(2), store.Hits())
+
+	// Reading a third block evicts the least recently used.
+	get(c)
+	assert.Equal(t, uint64(3), store.Misses())
+	get(a)
+	assert.Equal(t, uint64(3), store.Hits())
+	get(b)
+	assert.Equal(t, uint64(4), store.Misses())
+	assert.Equal(t, 2*blockSize, store.Size())
+}
+
+// Migrator which reads a state object shared by all actors.
+type sharedReadMigrator struct {
+	shared cid.Cid
+}
+
+func (m sharedReadMigrator) MigrateState(ctx context.Context, store cbor.IpldStore, in engine.ActorMigrationInput) (*engine.ActorMigrationResult, error) {
+	var shared states.Actor
+	if err := store.Get(ctx, m.shared, &shared); err != nil {
+		return nil, err
+	}
+	return &engine.ActorMigrationResult{NewCodeCID: newCode, NewHead: in.Head}, nil
+}
+
+func (m sharedReadMigrator) MigratedCodeCID() cid.Cid {
+	return newCode
+}
+
+func TestMigrateStateTreeReadCache(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	codes := map[uint64]cid.Cid{}
+	for id := uint64(100); id < 120; id++ {
+		codes[id] = oldCode
+	}
+	actorsIn := newTree(t, store, codes)
+	shared, err := store.Put(ctx, &states.Actor{Code: oldCode, Head: oldCode, Balance: big.Zero()})
+	require.NoError(t, err)
+	m := &engine.Migration{Migrations: map[cid.Cid]engine.ActorMigration{
+		oldCode: sharedReadMigrator{shared},
+	}}
+
+	actorsOut, err := states.NewTree(store)
+	require.NoError(t, err)
+	cfg := engine.Config{MaxWorkers: 1, ReadCacheBytes: 1 << 20}
+	_, stats, err := m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), stats.ReadCacheMisses)
+	assert.Equal(t, uint64(19), stats.ReadCacheHits)
+}
@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	block "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/ipld/go-car"
+	"golang.org/x/xerrors"
+)
+
+// A blockstore populated by streaming a CAR file, such as a chain snapshot, in the background.
+// Migrating from it can begin as soon as the header has been read: a read of a block which has not yet
+// arrived blocks until the stream reaches it, rather than requiring the whole snapshot to be imported first.
+// Since the state tree is reached top-down, and snapshots are written in traversal order, the migration then
+// proceeds close behind the stream.
+//
+// Blocks are retained in a destination blockstore, which also receives any blocks written by the migration.
+// The destination must be safe for concurrent use.
+type CarStreamBlockstore struct {
+	dest cbor.IpldBlockstore
+
+	lk      sync.Mutex
+	arrived *sync.Cond // Broadcast whenever a block arrives or the stream ends.
+	done    bool
+	err     error // Error which terminated the stream, if any.
+	roots   []cid.Cid
+	count   uint64
+}
+
+var _ cbor.IpldBlockstore = (*CarStreamBlockstore)(nil)
+
+// Reads the header of a CAR from r, then streams its blocks into dest until the end of the stream or the
+// context is cancelled.
+// A typical use migrates a snapshot directly:
+//
+//	bs, err := StreamCar(ctx, f, dest)
+//	...
+//	newRoot, err := nv15.MigrateStateTree(ctx, cbor.NewCborStore(bs), actorsRoot, epoch, cfg, log, cache)
+func StreamCar(ctx context.Context, r io.Reader, dest cbor.IpldBlockstore) (*CarStreamBlockstore, error) {
+	cr, err := car.NewCarReader(r)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read car header: %w", err)
+	}
+	s := &CarStreamBlockstore{
+		dest:  dest,
+		roots: cr.Header.Roots,
+	}
+	s.arrived = sync.NewCond(&s.lk)
+	go s.stream(ctx, cr)
+	return s, nil
+}
+
+// The roots listed in the CAR header.
+func (s *CarStreamBlockstore) Roots() []cid.Cid {
+	return s.roots
+}
+
+// Returns a block, waiting for it to arrive if necessary.
+// Returns the destination blockstore's error for a block which is not present when the stream has ended.
+func (s *CarStreamBlockstore) Get(c cid.Cid) (block.Block, error) {
+	if blk, err := s.dest.Get(c); err == nil {
+		return blk, nil
+	}
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	for {
+		// Re-read under the lock so that an arrival can't be missed between the read and waiting.
+		blk, err := s.dest.Get(c)
+		if err == nil {
+			return blk, nil
+		}
+		if s.done {
+			if s.err != nil {
+				return nil, xerrors.Errorf("block %s not found before car stream failed: %w", c, s.err)
+			}
+			return nil, err
+		}
+		s.arrived.Wait()
+	}
+}
+
+func (s *CarStreamBlockstore) Put(b block.Block) error {
+	return s.dest.Put(b)
+}
+
+// Blocks until the stream has been fully read, returning the number of blocks read and any error which
+// terminated the stream.
+func (s *CarStreamBlockstore) Wait() (uint64, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	for !s.done {
+		s.arrived.Wait()
+	}
+	return s.count, s.err
+}
+
+func (s *CarStreamBlockstore) stream(ctx context.Context, cr *car.CarReader) {
+	err := func() error {
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			blk, err := cr.Next()
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return xerrors.Errorf("failed to read car block: %w", err)
+			}
+			s.lk.Lock()
+			err = s.dest.Put(blk)
+			if err == nil {
+				s.count++
+				s.arrived.Broadcast()
+			}
+			s.lk.Unlock()
+			if err != nil {
+				return xerrors.Errorf("failed to store car block %s: %w", blk.Cid(), err)
+			}
+		}
+	}()
+
+	s.lk.Lock()
+	s.done = true
+	s.err = err
+	s.arrived.Broadcast()
+	s.lk.Unlock()
+}
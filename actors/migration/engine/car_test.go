@@ -0,0 +1,109 @@
+package engine_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+
+	block "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+)
+
+// Blockstore which records the order in which blocks are written.
+type orderedBlockStore struct {
+	*ipld.SyncBlockStore
+	lk     sync.Mutex
+	blocks []block.Block
+}
+
+func (bs *orderedBlockStore) Put(b block.Block) error {
+	bs.lk.Lock()
+	bs.blocks = append(bs.blocks, b)
+	bs.lk.Unlock()
+	return bs.SyncBlockStore.Put(b)
+}
+
+func TestStreamCar(t *testing.T) {
+	ctx := context.Background()
+	src := &orderedBlockStore{SyncBlockStore: ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory())}
+	srcStore := adt.WrapBlockStore(ctx, src)
+	codes := map[uint64]cid.Cid{}
+	for id := uint64(100); id < 200; id++ {
+		codes[id] = oldCode
+	}
+	actorsIn := newTree(t, srcStore, codes)
+	rootIn, err := actorsIn.Flush()
+	require.NoError(t, err)
+
+	var calls int32
+	m := &engine.Migration{
+		Migrations: map[cid.Cid]engine.ActorMigration{oldCode: headMigrator{&calls}},
+	}
+	cfg := engine.Config{MaxWorkers: 4}
+	migrate := func(store cbor.IpldStore) cid.Cid {
+		adtStore := adt.WrapStore(ctx, store)
+		in, err := states.LoadTree(adtStore, rootIn)
+		require.NoError(t, err)
+		out, err := states.NewTree(adtStore)
+		require.NoError(t, err)
+		root, _, err := m.MigrateStateTree(ctx, store, in, out, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+		require.NoError(t, err)
+		return root
+	}
+	expected := migrate(srcStore)
+
+	t.Run("migrates while streaming", func(t *testing.T) {
+		// Write blocks parent-first, as a snapshot would, one at a time through a pipe.
+		pr, pw := io.Pipe()
+		go func() {
+			err := car.WriteHeader(&car.CarHeader{Roots: []cid.Cid{rootIn}, Version: 1}, pw)
+			for i := len(src.blocks) - 1; i >= 0 && err == nil; i-- {
+				b := src.blocks[i]
+				err = carutil.LdWrite(pw, b.Cid().Bytes(), b.RawData())
+			}
+			_ = pw.CloseWithError(err)
+		}()
+
+		bs, err := engine.StreamCar(ctx, pr, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+		require.NoError(t, err)
+		assert.Equal(t, []cid.Cid{rootIn}, bs.Roots())
+		assert.Equal(t, expected, migrate(cbor.NewCborStore(bs)))
+
+		count, err := bs.Wait()
+		require.NoError(t, err)
+		assert.Equal(t, uint64(len(src.blocks)), count)
+	})
+
+	t.Run("missing block fails after stream ends", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, car.WriteHeader(&car.CarHeader{Roots: []cid.Cid{rootIn}, Version: 1}, &buf))
+		bs, err := engine.StreamCar(ctx, &buf, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+		require.NoError(t, err)
+		_, err = bs.Get(rootIn)
+		assert.Error(t, err)
+	})
+
+	t.Run("stream error is reported", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, car.WriteHeader(&car.CarHeader{Roots: []cid.Cid{rootIn}, Version: 1}, &buf))
+		buf.Write([]byte{0x80}) // Truncated section length.
+		bs, err := engine.StreamCar(ctx, &buf, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+		require.NoError(t, err)
+		_, err = bs.Wait()
+		assert.Error(t, err)
+		_, err = bs.Get(rootIn)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,389 @@
+// Code generated by github.com/whyrusleeping/cbor-gen. DO NOT EDIT.
+
+package engine
+
+import (
+	"fmt"
+	"io"
+
+	address "github.com/filecoin-project/go-address"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	xerrors "golang.org/x/xerrors"
+)
+
+var _ = xerrors.Errorf
+
+var lengthBufRollbackRecord = []byte{131}
+
+func (t *RollbackRecord) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufRollbackRecord); err != nil {
+		return err
+	}
+
+	scratch := make([]byte, 9)
+
+	// t.DeletedActors ([]engine.DeletedActor) (slice)
+	if len(t.DeletedActors) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.DeletedActors was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, uint64(len(t.DeletedActors))); err != nil {
+		return err
+	}
+	for _, v := range t.DeletedActors {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+
+	// t.BalanceTransfers ([]engine.BalanceTransfer) (slice)
+	if len(t.BalanceTransfers) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.BalanceTransfers was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, uint64(len(t.BalanceTransfers))); err != nil {
+		return err
+	}
+	for _, v := range t.BalanceTransfers {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+
+	// t.RemovedClaims ([]engine.RemovedClaim) (slice)
+	if len(t.RemovedClaims) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.RemovedClaims was too long")
+	}
+
+	if err := cbg.WriteMajorTypeHeaderBuf(scratch, w, cbg.MajArray, uint64(len(t.RemovedClaims))); err != nil {
+		return err
+	}
+	for _, v := range t.RemovedClaims {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *RollbackRecord) UnmarshalCBOR(r io.Reader) error {
+	*t = RollbackRecord{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 3 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.DeletedActors ([]engine.DeletedActor) (slice)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.DeletedActors: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.DeletedActors = make([]DeletedActor, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		var v DeletedActor
+		if err := v.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+		t.DeletedActors[i] = v
+	}
+
+	// t.BalanceTransfers ([]engine.BalanceTransfer) (slice)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.BalanceTransfers: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.BalanceTransfers = make([]BalanceTransfer, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		var v BalanceTransfer
+		if err := v.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+		t.BalanceTransfers[i] = v
+	}
+
+	// t.RemovedClaims ([]engine.RemovedClaim) (slice)
+
+	maj, extra, err = cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.RemovedClaims: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.RemovedClaims = make([]RemovedClaim, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		var v RemovedClaim
+		if err := v.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+		t.RemovedClaims[i] = v
+	}
+
+	return nil
+}
+
+var lengthBufDeletedActor = []byte{130}
+
+func (t *DeletedActor) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufDeletedActor); err != nil {
+		return err
+	}
+
+	// t.Address (address.Address) (struct)
+	if err := t.Address.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Actor (states.Actor) (struct)
+	if err := t.Actor.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *DeletedActor) UnmarshalCBOR(r io.Reader) error {
+	*t = DeletedActor{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Address (address.Address) (struct)
+
+	{
+
+		if err := t.Address.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Address: %w", err)
+		}
+
+	}
+	// t.Actor (states.Actor) (struct)
+
+	{
+
+		if err := t.Actor.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Actor: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufBalanceTransfer = []byte{131}
+
+func (t *BalanceTransfer) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufBalanceTransfer); err != nil {
+		return err
+	}
+
+	// t.From (address.Address) (struct)
+	if err := t.From.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.To (address.Address) (struct)
+	if err := t.To.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Amount (big.Int) (struct)
+	if err := t.Amount.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *BalanceTransfer) UnmarshalCBOR(r io.Reader) error {
+	*t = BalanceTransfer{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 3 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.From (address.Address) (struct)
+
+	{
+
+		b, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != cbg.CborNull[0] {
+			if err := br.UnreadByte(); err != nil {
+				return err
+			}
+			t.From = new(address.Address)
+			if err := t.From.UnmarshalCBOR(br); err != nil {
+				return xerrors.Errorf("unmarshaling t.From pointer: %w", err)
+			}
+		}
+
+	}
+	// t.To (address.Address) (struct)
+
+	{
+
+		if err := t.To.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.To: %w", err)
+		}
+
+	}
+	// t.Amount (big.Int) (struct)
+
+	{
+
+		if err := t.Amount.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Amount: %w", err)
+		}
+
+	}
+	return nil
+}
+
+var lengthBufRemovedClaim = []byte{130}
+
+func (t *RemovedClaim) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write(lengthBufRemovedClaim); err != nil {
+		return err
+	}
+
+	// t.Address (address.Address) (struct)
+	if err := t.Address.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Claim (power.Claim) (struct)
+	if err := t.Claim.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *RemovedClaim) UnmarshalCBOR(r io.Reader) error {
+	*t = RemovedClaim{}
+
+	br := cbg.GetPeeker(r)
+	scratch := make([]byte, 8)
+
+	maj, extra, err := cbg.CborReadHeaderBuf(br, scratch)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Address (address.Address) (struct)
+
+	{
+
+		if err := t.Address.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Address: %w", err)
+		}
+
+	}
+	// t.Claim (power.Claim) (struct)
+
+	{
+
+		if err := t.Claim.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Claim: %w", err)
+		}
+
+	}
+	return nil
+}
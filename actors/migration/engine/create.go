@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+)
+
+// Returns the balance remaining to an actor after funding the actors it creates.
+func balanceAfterCreation(balance abi.TokenAmount, created []CreatedActor) (abi.TokenAmount, error) {
+	for _, c := range created {
+		if c.Balance.LessThan(big.Zero()) {
+			return big.Zero(), xerrors.Errorf("negative balance %v for new actor %s", c.Balance, c.Address)
+		}
+		balance = big.Sub(balance, c.Balance)
+	}
+	if balance.LessThan(big.Zero()) {
+		return big.Zero(), xerrors.Errorf("insufficient balance to fund %d new actors", len(created))
+	}
+	return balance, nil
+}
+
+// Adds new actors to the output tree, checking that their addresses are unused in either tree.
+func writeCreatedActors(actorsIn InputTree, actorsOut OutputTree, created []CreatedActor, observer MigrationObserver, stats *statsCollector) error {
+	for _, c := range created {
+		for _, tree := range []InputTree{actorsIn, actorsOut} {
+			_, found, err := tree.GetActor(c.Address)
+			if err != nil {
+				return xerrors.Errorf("failed to check address %s of new actor: %w", c.Address, err)
+			}
+			if found {
+				return xerrors.Errorf("new actor address %s is already in use", c.Address)
+			}
+		}
+		if err := actorsOut.SetActor(c.Address, &states.Actor{
+			Code:       c.Code,
+			Head:       c.Head,
+			CallSeqNum: 0,
+			Balance:    c.Balance,
+		}); err != nil {
+			return err
+		}
+		stats.recordCreated()
+		observer.OnActorCreated(c.Address, c.Code)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+)
+
+// Results of the first migration phase, available to deferred migrations.
+type PhaseOneResults struct {
+	// The output state tree, containing all actors migrated in the first phase.
+	ActorsOut InputTree
+	// Addresses of actors removed from the state tree in the first phase, with their prior version code CIDs.
+	Deleted map[address.Address]cid.Cid
+}
+
+// Migrates a deferred actor, with access to the results of the first phase of migration.
+type DeferredActorMigration interface {
+	MigrateState(ctx context.Context, store cbor.IpldStore, input ActorMigrationInput, phaseOne *PhaseOneResults) (*ActorMigrationResult, error)
+	MigratedCodeCID() cid.Cid
+}
+
+// Specifies the migration of all actors with a prior version code CID in the second phase of migration.
+type DeferredMigration struct {
+	Code      cid.Cid
+	Migration DeferredActorMigration
+}
+
+type deferredActor struct {
+	address.Address
+	states.Actor
+}
+
+func (m *Migration) isDeferred(code cid.Cid) bool {
+	if _, ok := m.DeferredCodeIDs[code]; ok {
+		return true
+	}
+	for _, d := range m.DeferredMigrations {
+		if d.Code.Equals(code) {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs the deferred migrations in order, each over all deferred actors with its code CID, in input tree order.
+// Deferred actors with no deferred migration are left for the post-migrations.
+func (m *Migration) runDeferredMigrations(ctx context.Context, store cbor.IpldStore, actors []deferredActor, actorsIn InputTree, actorsOut OutputTree,
+	deleter ActorDeleter, priorEpoch abi.ChainEpoch, cache MigrationCache, phaseOne *PhaseOneResults, observer MigrationObserver, stats *statsCollector,
+	rollback *RollbackRecorder, audit *AuditLog) error {
+	for _, d := range m.DeferredMigrations {
+		for _, a := range actors {
+			if !a.Actor.Code.Equals(d.Code) {
+				continue
+			}
+			observer.OnJobCreated(a.Address, a.Actor.Code)
+			start := time.Now()
+			result, err := d.Migration.MigrateState(ctx, store, ActorMigrationInput{
+				Address:    a.Address,
+				Balance:    a.Actor.Balance,
+				Head:       a.Actor.Head,
+				PriorEpoch: priorEpoch,
+				Cache:      cache,
+			}, phaseOne)
+			if err != nil {
+				return xerrors.Errorf("deferred state migration failed for %s actor, addr %s: %w",
+					m.actorName(a.Actor.Code), a.Address, err)
+			}
+			elapsed := time.Since(start)
+			stats.recordJob(a.Actor.Code, elapsed)
+			observer.OnJobDone(a.Address, a.Actor.Code, elapsed)
+
+			balance, err := balanceAfterCreation(a.Actor.Balance, result.Created)
+			if err != nil {
+				return xerrors.Errorf("deferred state migration failed for %s actor, addr %s: %w",
+					m.actorName(a.Actor.Code), a.Address, err)
+			}
+			if err := writeCreatedActors(actorsIn, actorsOut, result.Created, observer, stats); err != nil {
+				return err
+			}
+
+			if result.Deleted {
+				stats.recordDeleted(a.Actor.Code)
+				observer.OnActorDeleted(a.Address, a.Actor.Code)
+				rollback.recordDeleted(a.Address, &a.Actor)
+				if err := audit.actorDeleted(a.Address, a.Actor.Code, a.Actor.Balance); err != nil {
+					return err
+				}
+				if deleter != nil {
+					if err := deleter.DeleteActor(a.Address); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := actorsOut.SetActor(a.Address, &states.Actor{
+				Code:       result.NewCodeCID,
+				Head:       result.NewHead,
+				CallSeqNum: a.Actor.CallSeqNum, // Unchanged
+				Balance:    balance,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
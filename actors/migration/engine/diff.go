@@ -0,0 +1,182 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/account"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/cron"
+	init_ "github.com/filecoin-project/specs-actors/v7/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/multisig"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/paych"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/power"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/reward"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/system"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/verifreg"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// Differences between two state trees.
+type StateDiff struct {
+	// Actors present only in the second tree.
+	Added []address.Address
+	// Actors present only in the first tree.
+	Removed []address.Address
+	// Actors present in both trees with differing records, in first tree iteration order.
+	Modified []ActorDiff
+}
+
+// Differences between the records of an actor in two state trees.
+type ActorDiff struct {
+	Address       address.Address
+	CodeBefore    cid.Cid
+	CodeAfter     cid.Cid
+	HeadBefore    cid.Cid
+	HeadAfter     cid.Cid
+	NonceBefore   uint64
+	NonceAfter    uint64
+	BalanceBefore abi.TokenAmount
+	BalanceAfter  abi.TokenAmount
+	// Differing top-level fields of the actor's state, if both heads decode as the state type of a v7 built-in
+	// actor (determined by the code after). Nested collections are compared by root CID only.
+	// Nil if the heads are equal or either fails to decode.
+	Fields []FieldDiff
+}
+
+type FieldDiff struct {
+	Name   string
+	Before string
+	After  string
+}
+
+// Walks two state trees, reporting the actors added, removed and modified between them.
+// The trees may be of different versions, so long as the actor record and tree format are unchanged.
+func DiffStateTrees(ctx context.Context, store cbor.IpldStore, rootA, rootB cid.Cid) (*StateDiff, error) {
+	adtStore := adt.WrapStore(ctx, store)
+	treeA, err := states.LoadTree(adtStore, rootA)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load state tree %s: %w", rootA, err)
+	}
+	treeB, err := states.LoadTree(adtStore, rootB)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load state tree %s: %w", rootB, err)
+	}
+	if rootA.Equals(rootB) {
+		return &StateDiff{}, nil
+	}
+
+	diff := &StateDiff{}
+	if err := treeA.ForEach(func(addr address.Address, a *states.Actor) error {
+		b, found, err := treeB.GetActor(addr)
+		if err != nil {
+			return err
+		}
+		if !found {
+			diff.Removed = append(diff.Removed, addr)
+			return nil
+		}
+		if actorRecordsEqual(a, b) {
+			return nil
+		}
+		actorDiff := ActorDiff{
+			Address:       addr,
+			CodeBefore:    a.Code,
+			CodeAfter:     b.Code,
+			HeadBefore:    a.Head,
+			HeadAfter:     b.Head,
+			NonceBefore:   a.CallSeqNum,
+			NonceAfter:    b.CallSeqNum,
+			BalanceBefore: a.Balance,
+			BalanceAfter:  b.Balance,
+		}
+		if !a.Head.Equals(b.Head) {
+			actorDiff.Fields = diffActorStates(ctx, store, b.Code, a.Head, b.Head)
+		}
+		diff.Modified = append(diff.Modified, actorDiff)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := treeB.ForEach(func(addr address.Address, _ *states.Actor) error {
+		_, found, err := treeA.GetActor(addr)
+		if err != nil {
+			return err
+		}
+		if !found {
+			diff.Added = append(diff.Added, addr)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// Returns a new, empty state object for a built-in actor code CID, or nil if the code is unknown.
+func newActorState(code cid.Cid) cbg.CBORUnmarshaler {
+	switch code {
+	case builtin.SystemActorCodeID:
+		return &system.State{}
+	case builtin.InitActorCodeID:
+		return &init_.State{}
+	case builtin.CronActorCodeID:
+		return &cron.State{}
+	case builtin.AccountActorCodeID:
+		return &account.State{}
+	case builtin.StoragePowerActorCodeID:
+		return &power.State{}
+	case builtin.StorageMinerActorCodeID:
+		return &miner.State{}
+	case builtin.StorageMarketActorCodeID:
+		return &market.State{}
+	case builtin.PaymentChannelActorCodeID:
+		return &paych.State{}
+	case builtin.MultisigActorCodeID:
+		return &multisig.State{}
+	case builtin.RewardActorCodeID:
+		return &reward.State{}
+	case builtin.VerifiedRegistryActorCodeID:
+		return &verifreg.State{}
+	}
+	return nil
+}
+
+func diffActorStates(ctx context.Context, store cbor.IpldStore, code, headA, headB cid.Cid) []FieldDiff {
+	stateA, stateB := newActorState(code), newActorState(code)
+	if stateA == nil {
+		return nil
+	}
+	if err := store.Get(ctx, headA, stateA); err != nil {
+		return nil
+	}
+	if err := store.Get(ctx, headB, stateB); err != nil {
+		return nil
+	}
+
+	valA, valB := reflect.ValueOf(stateA).Elem(), reflect.ValueOf(stateB).Elem()
+	var fields []FieldDiff
+	for i := 0; i < valA.NumField(); i++ {
+		fieldA, fieldB := valA.Field(i).Interface(), valB.Field(i).Interface()
+		if !reflect.DeepEqual(fieldA, fieldB) {
+			fields = append(fields, FieldDiff{
+				Name:   valA.Type().Field(i).Name,
+				Before: fmt.Sprintf("%v", fieldA),
+				After:  fmt.Sprintf("%v", fieldB),
+			})
+		}
+	}
+	return fields
+}
@@ -0,0 +1,77 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/account"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestDiffStateTrees(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewBlockStoreInMemory())
+
+	putAccount := func(tree *states.Tree, id uint64, pubkey address.Address, balance int64) {
+		head, err := store.Put(ctx, &account.State{Address: pubkey})
+		require.NoError(t, err)
+		require.NoError(t, tree.SetActor(tutil.NewIDAddr(t, id), &states.Actor{
+			Code:    builtin.AccountActorCodeID,
+			Head:    head,
+			Balance: abi.NewTokenAmount(balance),
+		}))
+	}
+	keyA, keyB := tutil.NewBLSAddr(t, 1), tutil.NewBLSAddr(t, 2)
+
+	treeA, err := states.NewTree(store)
+	require.NoError(t, err)
+	putAccount(treeA, 100, keyA, 1) // Unchanged
+	putAccount(treeA, 101, keyA, 1) // Balance changed
+	putAccount(treeA, 102, keyA, 1) // State changed
+	putAccount(treeA, 103, keyA, 1) // Removed
+	rootA, err := treeA.Flush()
+	require.NoError(t, err)
+
+	treeB, err := states.NewTree(store)
+	require.NoError(t, err)
+	putAccount(treeB, 100, keyA, 1)
+	putAccount(treeB, 101, keyA, 2)
+	putAccount(treeB, 102, keyB, 1)
+	putAccount(treeB, 104, keyA, 1) // Added
+	rootB, err := treeB.Flush()
+	require.NoError(t, err)
+
+	diff, err := engine.DiffStateTrees(ctx, store, rootA, rootB)
+	require.NoError(t, err)
+	assert.Equal(t, []address.Address{tutil.NewIDAddr(t, 103)}, diff.Removed)
+	assert.Equal(t, []address.Address{tutil.NewIDAddr(t, 104)}, diff.Added)
+	require.Len(t, diff.Modified, 2)
+	modified := map[address.Address]engine.ActorDiff{}
+	for _, d := range diff.Modified {
+		modified[d.Address] = d
+	}
+
+	balanceDiff := modified[tutil.NewIDAddr(t, 101)]
+	assert.Equal(t, big.NewInt(1), balanceDiff.BalanceBefore)
+	assert.Equal(t, big.NewInt(2), balanceDiff.BalanceAfter)
+	assert.Nil(t, balanceDiff.Fields)
+
+	stateDiff := modified[tutil.NewIDAddr(t, 102)]
+	assert.Equal(t, []engine.FieldDiff{{Name: "Address", Before: keyA.String(), After: keyB.String()}}, stateDiff.Fields)
+
+	// Identical roots have no differences.
+	diff, err = engine.DiffStateTrees(ctx, store, rootA, rootA)
+	require.NoError(t, err)
+	assert.Empty(t, diff.Modified)
+}
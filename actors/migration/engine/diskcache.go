@@ -0,0 +1,327 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+)
+
+// Options for a DiskMigrationCache.
+type DiskCacheOptions struct {
+	// Number of writes to buffer in memory before appending them to the file.
+	// Zero (the default) selects DefaultDiskCacheBatchSize.
+	BatchSize int
+	// Age after which entries are evicted. Expired entries are ignored on read and dropped when the cache
+	// is opened or compacted.
+	// Zero (the default) means entries never expire.
+	TTL time.Duration
+	// Returns the current time. Optional, defaults to time.Now.
+	Now func() time.Time
+}
+
+const DefaultDiskCacheBatchSize = 1000
+
+// A MigrationCache persisted to a file, so that pre-migration results survive process restarts.
+// The full cache is held in memory. Writes are appended to the file in batches; entries written since the
+// last batch was flushed are lost if the process exits without calling Flush or Close.
+// The file is an append-only log of records, which Compact rewrites to drop superseded and expired entries.
+// A truncated record at the end of the file (e.g. from a crash mid-write) is discarded when the cache is opened.
+type DiskMigrationCache struct {
+	path string
+	opts DiskCacheOptions
+
+	lk      sync.Mutex
+	entries map[string]diskCacheEntry
+	file    *os.File
+	w       *bufio.Writer
+	pending int // Number of records buffered in w.
+}
+
+type diskCacheEntry struct {
+	value   cid.Cid
+	written int64 // Unix nanoseconds
+}
+
+var _ InvalidatableCache = (*DiskMigrationCache)(nil)
+
+// Opens a cache file, creating it if it doesn't exist, and loads its unexpired entries.
+func OpenDiskMigrationCache(path string, opts DiskCacheOptions) (*DiskMigrationCache, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultDiskCacheBatchSize
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to open migration cache %s: %w", path, err)
+	}
+	c := &DiskMigrationCache{
+		path:    path,
+		opts:    opts,
+		entries: map[string]diskCacheEntry{},
+		file:    f,
+	}
+	validLen, err := c.load()
+	if err != nil {
+		_ = f.Close()
+		return nil, xerrors.Errorf("failed to load migration cache %s: %w", path, err)
+	}
+	// Drop any partial trailing record and position for appending.
+	if err := f.Truncate(validLen); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(validLen, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	c.w = bufio.NewWriter(f)
+	return c, nil
+}
+
+func (c *DiskMigrationCache) Write(key string, value cid.Cid) error {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return c.write(key, value)
+}
+
+func (c *DiskMigrationCache) Read(key string) (bool, cid.Cid, error) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	e, found := c.entries[key]
+	if !found || c.expired(e) {
+		return false, cid.Undef, nil
+	}
+	return true, e.value, nil
+}
+
+func (c *DiskMigrationCache) Load(key string, loadFunc func() (cid.Cid, error)) (cid.Cid, error) {
+	found, value, err := c.Read(key)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if found {
+		return value, nil
+	}
+	// The lock is not held while loading, so concurrent loads of the same key may both run.
+	// They compute the same value, so the duplicate write is harmless.
+	value, err = loadFunc()
+	if err != nil {
+		return cid.Undef, err
+	}
+	if err := c.Write(key, value); err != nil {
+		return cid.Undef, err
+	}
+	return value, nil
+}
+
+// Writes any buffered entries to the file and syncs it.
+func (c *DiskMigrationCache) Flush() error {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return c.flush()
+}
+
+// Rewrites the file with only the current, unexpired entries.
+func (c *DiskMigrationCache) Compact() error {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	if err := c.flush(); err != nil {
+		return err
+	}
+
+	tmpPath := c.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return xerrors.Errorf("failed to create compacted migration cache: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	for key, e := range c.entries { // nolint:nomaprange // Record order is insignificant.
+		if c.expired(e) {
+			delete(c.entries, key)
+			continue
+		}
+		if err := writeDiskCacheRecord(w, key, e); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		_ = tmp.Close()
+		return xerrors.Errorf("failed to replace migration cache: %w", err)
+	}
+	_ = c.file.Close()
+	c.file = tmp
+	c.w = bufio.NewWriter(tmp)
+	return nil
+}
+
+// Discards all entries, truncating the file.
+func (c *DiskMigrationCache) InvalidateAll() error {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	c.pending = 0
+	c.entries = map[string]diskCacheEntry{}
+	if err := c.file.Truncate(0); err != nil {
+		return xerrors.Errorf("failed to truncate migration cache: %w", err)
+	}
+	if _, err := c.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return c.file.Sync()
+}
+
+// Flushes buffered entries and closes the file. The cache must not be used after closing.
+func (c *DiskMigrationCache) Close() error {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	if err := c.flush(); err != nil {
+		_ = c.file.Close()
+		return err
+	}
+	return c.file.Close()
+}
+
+func (c *DiskMigrationCache) write(key string, value cid.Cid) error {
+	e := diskCacheEntry{value: value, written: c.opts.Now().UnixNano()}
+	c.entries[key] = e
+	if err := writeDiskCacheRecord(c.w, key, e); err != nil {
+		return xerrors.Errorf("failed to write migration cache entry: %w", err)
+	}
+	c.pending++
+	if c.pending >= c.opts.BatchSize {
+		return c.flush()
+	}
+	return nil
+}
+
+func (c *DiskMigrationCache) flush() error {
+	if c.pending == 0 {
+		return nil
+	}
+	if err := c.w.Flush(); err != nil {
+		return xerrors.Errorf("failed to flush migration cache: %w", err)
+	}
+	if err := c.file.Sync(); err != nil {
+		return xerrors.Errorf("failed to sync migration cache: %w", err)
+	}
+	c.pending = 0
+	return nil
+}
+
+func (c *DiskMigrationCache) expired(e diskCacheEntry) bool {
+	return c.opts.TTL > 0 && c.opts.Now().UnixNano()-e.written > int64(c.opts.TTL)
+}
+
+// Reads all records from the file, returning the length of the valid prefix.
+func (c *DiskMigrationCache) load() (int64, error) {
+	r := &countingReader{r: bufio.NewReader(c.file)}
+	var validLen int64
+	for {
+		key, e, err := readDiskCacheRecord(r)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return validLen, nil
+		} else if err != nil {
+			return 0, err
+		}
+		validLen = r.n
+		if c.expired(e) {
+			delete(c.entries, key)
+		} else {
+			c.entries[key] = e
+		}
+	}
+}
+
+// Records are encoded as: uvarint key length, key, uvarint CID length, CID bytes, varint write time.
+// An undefined CID is encoded with zero length.
+func writeDiskCacheRecord(w io.Writer, key string, e diskCacheEntry) error {
+	var valueBytes []byte
+	if e.value.Defined() {
+		valueBytes = e.value.Bytes()
+	}
+	var scratch [binary.MaxVarintLen64]byte
+	buf := make([]byte, 0, 3*binary.MaxVarintLen64+len(key)+len(valueBytes))
+	buf = append(buf, scratch[:binary.PutUvarint(scratch[:], uint64(len(key)))]...)
+	buf = append(buf, key...)
+	buf = append(buf, scratch[:binary.PutUvarint(scratch[:], uint64(len(valueBytes)))]...)
+	buf = append(buf, valueBytes...)
+	buf = append(buf, scratch[:binary.PutVarint(scratch[:], e.written)]...)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readDiskCacheRecord(r *countingReader) (string, diskCacheEntry, error) {
+	keyLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", diskCacheEntry{}, err
+	}
+	key := make([]byte, keyLen)
+	if _, err := io.ReadFull(r, key); err != nil {
+		return "", diskCacheEntry{}, unexpectedEOF(err)
+	}
+	valueLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", diskCacheEntry{}, unexpectedEOF(err)
+	}
+	e := diskCacheEntry{value: cid.Undef}
+	if valueLen > 0 {
+		valueBytes := make([]byte, valueLen)
+		if _, err := io.ReadFull(r, valueBytes); err != nil {
+			return "", diskCacheEntry{}, unexpectedEOF(err)
+		}
+		if e.value, err = cid.Cast(valueBytes); err != nil {
+			return "", diskCacheEntry{}, xerrors.Errorf("invalid CID for key %s: %w", key, err)
+		}
+	}
+	if e.written, err = binary.ReadVarint(r); err != nil {
+		return "", diskCacheEntry{}, unexpectedEOF(err)
+	}
+	return string(key), e, nil
+}
+
+// Converts EOF in the middle of a record to ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// A byte reader which counts the bytes read.
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
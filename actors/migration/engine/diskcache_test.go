@@ -0,0 +1,146 @@
+package engine_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestDiskMigrationCache(t *testing.T) {
+	cid1 := tutil.MakeCID("1", nil)
+	cid2 := tutil.MakeCID("2", nil)
+
+	t.Run("entries survive reopening", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache")
+		cache, err := engine.OpenDiskMigrationCache(path, engine.DiskCacheOptions{BatchSize: 2})
+		require.NoError(t, err)
+		require.NoError(t, cache.Write("a", cid1))
+		require.NoError(t, cache.Write("b", cid2))
+		require.NoError(t, cache.Write("a", cid2)) // Supersedes the first write.
+		require.NoError(t, cache.Write("deleted", cid.Undef))
+		require.NoError(t, cache.Close())
+
+		cache, err = engine.OpenDiskMigrationCache(path, engine.DiskCacheOptions{})
+		require.NoError(t, err)
+		defer func() { require.NoError(t, cache.Close()) }()
+		assertCached(t, cache, "a", cid2)
+		assertCached(t, cache, "b", cid2)
+		assertCached(t, cache, "deleted", cid.Undef)
+		found, _, err := cache.Read("c")
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		// Load computes only missing values.
+		loaded, err := cache.Load("a", func() (cid.Cid, error) {
+			t.Fatal("unexpected load")
+			return cid.Undef, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, cid2, loaded)
+		loaded, err = cache.Load("c", func() (cid.Cid, error) { return cid1, nil })
+		require.NoError(t, err)
+		assert.Equal(t, cid1, loaded)
+		assertCached(t, cache, "c", cid1)
+	})
+
+	t.Run("entries expire after TTL", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache")
+		now := time.Unix(1000, 0)
+		opts := engine.DiskCacheOptions{TTL: time.Hour, Now: func() time.Time { return now }}
+		cache, err := engine.OpenDiskMigrationCache(path, opts)
+		require.NoError(t, err)
+		require.NoError(t, cache.Write("old", cid1))
+		now = now.Add(30 * time.Minute)
+		require.NoError(t, cache.Write("new", cid2))
+
+		now = now.Add(45 * time.Minute)
+		found, _, err := cache.Read("old")
+		require.NoError(t, err)
+		assert.False(t, found)
+		assertCached(t, cache, "new", cid2)
+		require.NoError(t, cache.Close())
+
+		// Expired entries are dropped on reopening and compaction.
+		cache, err = engine.OpenDiskMigrationCache(path, opts)
+		require.NoError(t, err)
+		found, _, err = cache.Read("old")
+		require.NoError(t, err)
+		assert.False(t, found)
+		require.NoError(t, cache.Compact())
+		require.NoError(t, cache.Write("newer", cid1))
+		require.NoError(t, cache.Close())
+
+		cache, err = engine.OpenDiskMigrationCache(path, opts)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, cache.Close()) }()
+		assertCached(t, cache, "new", cid2)
+		assertCached(t, cache, "newer", cid1)
+	})
+
+	t.Run("invalidate all", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache")
+		cache, err := engine.OpenDiskMigrationCache(path, engine.DiskCacheOptions{})
+		require.NoError(t, err)
+		require.NoError(t, cache.Write("a", cid1))
+		require.NoError(t, cache.Flush())
+		require.NoError(t, cache.Write("b", cid1))
+		require.NoError(t, cache.InvalidateAll())
+		require.NoError(t, cache.Write("c", cid2))
+		require.NoError(t, cache.Close())
+
+		cache, err = engine.OpenDiskMigrationCache(path, engine.DiskCacheOptions{})
+		require.NoError(t, err)
+		defer func() { require.NoError(t, cache.Close()) }()
+		for _, key := range []string{"a", "b"} {
+			found, _, err := cache.Read(key)
+			require.NoError(t, err)
+			assert.False(t, found)
+		}
+		assertCached(t, cache, "c", cid2)
+	})
+
+	t.Run("truncated record is discarded", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache")
+		cache, err := engine.OpenDiskMigrationCache(path, engine.DiskCacheOptions{})
+		require.NoError(t, err)
+		require.NoError(t, cache.Write("a", cid1))
+		require.NoError(t, cache.Flush())
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.NoError(t, cache.Write("b", cid2))
+		require.NoError(t, cache.Close())
+
+		// Simulate a crash part way through writing the second record.
+		require.NoError(t, os.Truncate(path, info.Size()+3))
+		cache, err = engine.OpenDiskMigrationCache(path, engine.DiskCacheOptions{})
+		require.NoError(t, err)
+		assertCached(t, cache, "a", cid1)
+		found, _, err := cache.Read("b")
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		// New writes follow the last complete record.
+		require.NoError(t, cache.Write("c", cid2))
+		require.NoError(t, cache.Close())
+		cache, err = engine.OpenDiskMigrationCache(path, engine.DiskCacheOptions{})
+		require.NoError(t, err)
+		defer func() { require.NoError(t, cache.Close()) }()
+		assertCached(t, cache, "a", cid1)
+		assertCached(t, cache, "c", cid2)
+	})
+}
+
+func assertCached(t *testing.T, cache engine.MigrationCache, key string, expected cid.Cid) {
+	found, value, err := cache.Read(key)
+	require.NoError(t, err)
+	assert.True(t, found, "key %s not found", key)
+	assert.Equal(t, expected, value)
+}
@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"context"
+	"sync"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	block "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+)
+
+// Describes the changes a migration made to a state tree.
+type DryRunReport struct {
+	// Actors whose code or head CID changed, in input tree iteration order.
+	Changed []ActorChange
+	// Number of actors present in both trees with identical code and head.
+	Unchanged int
+	// Actors present in the input tree but not in the output tree.
+	Removed []address.Address
+	// Actors present in the output tree but not in the input tree.
+	Added []address.Address
+	// Actors whose balance changed, including removed and added actors.
+	BalanceChanges []BalanceChange
+}
+
+type ActorChange struct {
+	Address    address.Address
+	CodeBefore cid.Cid
+	CodeAfter  cid.Cid
+	HeadBefore cid.Cid
+	HeadAfter  cid.Cid
+}
+
+type BalanceChange struct {
+	Address address.Address
+	Before  abi.TokenAmount
+	After   abi.TokenAmount
+}
+
+// Compares the actors in a migration's input and output trees to build a report of the changes made.
+func BuildDryRunReport(actorsIn InputTree, actorsOut InputTree) (*DryRunReport, error) {
+	report := &DryRunReport{}
+	if err := actorsIn.ForEach(func(addr address.Address, actorIn *states.Actor) error {
+		actorOut, found, err := actorsOut.GetActor(addr)
+		if err != nil {
+			return xerrors.Errorf("failed to load output actor %s: %w", addr, err)
+		}
+		if !found {
+			report.Removed = append(report.Removed, addr)
+			if !actorIn.Balance.IsZero() {
+				report.BalanceChanges = append(report.BalanceChanges, BalanceChange{addr, actorIn.Balance, abi.NewTokenAmount(0)})
+			}
+			return nil
+		}
+		if actorIn.Code.Equals(actorOut.Code) && actorIn.Head.Equals(actorOut.Head) {
+			report.Unchanged++
+		} else {
+			report.Changed = append(report.Changed, ActorChange{
+				Address:    addr,
+				CodeBefore: actorIn.Code,
+				CodeAfter:  actorOut.Code,
+				HeadBefore: actorIn.Head,
+				HeadAfter:  actorOut.Head,
+			})
+		}
+		if !actorIn.Balance.Equals(actorOut.Balance) {
+			report.BalanceChanges = append(report.BalanceChanges, BalanceChange{addr, actorIn.Balance, actorOut.Balance})
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := actorsOut.ForEach(func(addr address.Address, actorOut *states.Actor) error {
+		_, found, err := actorsIn.GetActor(addr)
+		if err != nil {
+			return xerrors.Errorf("failed to load input actor %s: %w", addr, err)
+		}
+		if !found {
+			report.Added = append(report.Added, addr)
+			if !actorOut.Balance.IsZero() {
+				report.BalanceChanges = append(report.BalanceChanges, BalanceChange{addr, abi.NewTokenAmount(0), actorOut.Balance})
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Returns a store which reads through to an underlying store, but buffers all writes in memory.
+// Nothing is ever written to the underlying store, so a migration run against this store leaves no trace.
+// The returned store is safe for concurrent use if the underlying store is.
+func NewDryRunStore(base cbor.IpldStore) cbor.IpldStore {
+	return NewStagingStore(base, cbor.NewCborStore(&memBlockstore{blocks: map[cid.Cid]block.Block{}}))
+}
+
+// Returns a store which writes only to a staging store, and reads from the staging store falling back to a
+// read-only input store. This allows a migration to read from a chain blockstore which is read-only (or remote)
+// while its output goes to a separate store, which may be merged into the chain store after validation.
+// The returned store is safe for concurrent use if both stores are.
+func NewStagingStore(input, staging cbor.IpldStore) cbor.IpldStore {
+	return &stagingStore{input: input, staging: staging}
+}
+
+type stagingStore struct {
+	input   cbor.IpldStore
+	staging cbor.IpldStore
+}
+
+func (s *stagingStore) Get(ctx context.Context, c cid.Cid, out interface{}) error {
+	if err := s.staging.Get(ctx, c, out); err == nil {
+		return nil
+	}
+	return s.input.Get(ctx, c, out)
+}
+
+func (s *stagingStore) Put(ctx context.Context, v interface{}) (cid.Cid, error) {
+	return s.staging.Put(ctx, v)
+}
+
+// A minimal synchronized in-memory block store.
+type memBlockstore struct {
+	mu     sync.RWMutex
+	blocks map[cid.Cid]block.Block
+}
+
+func (bs *memBlockstore) Get(c cid.Cid) (block.Block, error) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+	blk, ok := bs.blocks[c]
+	if !ok {
+		return nil, xerrors.Errorf("block %s not found", c)
+	}
+	return blk, nil
+}
+
+func (bs *memBlockstore) Put(b block.Block) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.blocks[b.Cid()] = b
+	return nil
+}
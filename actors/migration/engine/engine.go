@@ -0,0 +1,729 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/rt"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+)
+
+// Config parameterizes a state tree migration
+type Config struct {
+	// Number of migration worker goroutines to run.
+	// More workers enables higher CPU utilization doing migration computations (including state encoding)
+	MaxWorkers uint
+	// Capacity of the queue of jobs available to workers (zero for unbuffered).
+	// A queue length of hundreds to thousands improves throughput at the cost of memory.
+	JobQueueSize uint
+	// Capacity of the queue receiving migration results from workers, for persisting (zero for unbuffered).
+	// A queue length of tens to hundreds improves throughput at the cost of memory.
+	ResultQueueSize uint
+	// Time between progress logs to emit.
+	// Zero (the default) results in no progress logs.
+	ProgressLogPeriod time.Duration
+	// Whether to run the migration without persisting any state.
+	// When set, new state is buffered in memory (see NewDryRunStore) and discarded after the migration.
+	// The resulting root CID is computed but cannot be loaded from the store.
+	DryRun bool
+	// Receives notifications of migration progress. Optional.
+	Observer MigrationObserver
+	// Whether to count the blocks written by the migration, reported in MigrationStats.
+	// Measuring the size of each block has some cost.
+	MeterWrites bool
+	// Approximate limit on the memory held by jobs between their creation and their results being written, in bytes.
+	// Job creation blocks while the budget is exhausted. Sizes are estimated (see SizeEstimator), so the limit
+	// is not exact. Zero (the default) means no limit.
+	MaxInFlightBytes uint64
+	// Whether to write results to the output tree ordered by address bytes, rather than the order workers complete them.
+	// Results are buffered until all workers are done, so two runs over the same input make an identical
+	// sequence of writes. This is slower, and intended for debugging divergence between runs.
+	DeterministicOrder bool
+	// Number of results to write to the output tree between intermediate flushes of it to the store.
+	// Intermediate roots are discarded, but flushing periodically spreads the block writes over the migration
+	// and leaves less to write in the final flush.
+	// Zero (the default) means only the final flush.
+	FlushPeriod uint
+	// Whether to measure the blocks read and written by each actor's migration, reported per code CID
+	// in MigrationStats. Measurement re-encodes every block, so slows the migration.
+	ProfileActors bool
+	// Collects a RollbackRecord of the actors removed by the migration, to be written to the store so that the
+	// removal can be audited or reversed. Its CID is reported in MigrationStats. Optional.
+	// Give the same recorder to the migration's TransferAccumulators and PowerClaimsCleanup to record the balances
+	// they move and the claims they remove.
+	Rollback *RollbackRecorder
+	// Number of goroutines inserting results into the state tree. With more than one, results are inserted into
+	// per-shard trees in parallel, each holding a disjoint set of the slots in the root node of the tree's HAMT,
+	// which are merged into the output tree after all workers are done. If the output tree is a *states.Tree,
+	// the merge assembles the shard trees' root pointers into its root directly; otherwise every actor is
+	// inserted into it. Values of zero and one both mean a single writer inserting directly into the output tree.
+	// At most the number of root slots (32) are used.
+	ResultWriters uint
+	// Size in bytes of a read cache of recently used blocks, shared by the migration workers (see CachingStore).
+	// Hit and miss counts are reported in MigrationStats.
+	// Zero (the default) means no cache.
+	ReadCacheBytes uint64
+	// Whether the output tree was loaded from the same root as the input tree, to be updated in place rather than
+	// built up from empty. Results which leave an actor's record unchanged are not written, and removed actors are
+	// deleted from the output tree, which must then implement ActorDeleter.
+	// Deferred actors which are left for post-migrations remain in the output tree with their prior records
+	// until overwritten.
+	InPlace bool
+	// Numbers of workers dedicated to migrating actors with particular (prior) code CIDs, in addition to MaxWorkers.
+	// Jobs for these actors are run only by their dedicated workers, so expensive actor types (e.g. miners)
+	// don't wait behind many cheap ones, nor occupy all the shared workers.
+	// Each pool has its own queue of JobQueueSize. Jobs are created sequentially, so creation blocks while
+	// the queue for the next actor is full, whatever the state of the other pools.
+	DedicatedWorkers map[cid.Cid]uint
+	// Code CIDs of actors to migrate first. Jobs for these actors are created in a first pass over the input tree,
+	// ahead of all others, at the cost of iterating the tree twice.
+	PriorityCodes []cid.Cid
+	// Receives a record of each actor removed by the migration. Optional.
+	AuditLog *AuditLog
+	// Where balances with no eligible recipient are credited (see FallbackRecipient), e.g. the balances of removed
+	// actors whose owners are missing. The default burns them.
+	FallbackPolicy FallbackPolicy
+	// The reserve actor credited under FallbackReserve. Must be an ID address of an actor in the input tree.
+	// Must be unset under FallbackBurn.
+	FallbackAddress address.Address
+}
+
+type Logger interface {
+	// This is the same logging interface provided by the Runtime
+	Log(level rt.LogLevel, msg string, args ...interface{})
+}
+
+func ActorHeadKey(addr address.Address, head cid.Cid) string {
+	return addr.String() + "-h-" + head.String()
+}
+
+// Returns the cache key for an actor head migrated by a particular version of a migration.
+// An empty version yields the unversioned ActorHeadKey.
+func VersionedActorHeadKey(version string, addr address.Address, head cid.Cid) string {
+	if version == "" {
+		return ActorHeadKey(addr, head)
+	}
+	return version + "-" + ActorHeadKey(addr, head)
+}
+
+// MigrationCache stores and loads cached data. Its implementation must be threadsafe
+type MigrationCache interface {
+	Write(key string, newCid cid.Cid) error
+	Read(key string) (bool, cid.Cid, error)
+	Load(key string, loadFunc func() (cid.Cid, error)) (cid.Cid, error)
+}
+
+// A MigrationCache which can discard all its entries, e.g. when entries from a prior version of a migration
+// will never be read again.
+type InvalidatableCache interface {
+	MigrationCache
+	InvalidateAll() error
+}
+
+// The state tree of the prior version, from which actors are read.
+// The actor record type has not changed across versions, so any versioned states.Tree satisfies this.
+type InputTree interface {
+	GetActor(addr address.Address) (*states.Actor, bool, error)
+	ForEach(fn func(addr address.Address, actor *states.Actor) error) error
+}
+
+// The state tree of the new version, to which migrated actors are written.
+type OutputTree interface {
+	InputTree
+	SetActor(addr address.Address, actor *states.Actor) error
+	Flush() (cid.Cid, error)
+}
+
+// An output tree from which actors may be removed, as required for in-place migration (see Config.InPlace).
+type ActorDeleter interface {
+	DeleteActor(addr address.Address) error
+}
+
+type ActorMigrationInput struct {
+	Address    address.Address // actor's address
+	Balance    abi.TokenAmount // actor's balance
+	Head       cid.Cid         // actor's state head CID
+	PriorEpoch abi.ChainEpoch  // epoch of last state transition prior to migration
+	Cache      MigrationCache  // cache of existing cid -> cid migrations for this actor
+}
+
+type ActorMigrationResult struct {
+	NewCodeCID cid.Cid
+	NewHead    cid.Cid
+	// Whether to remove the actor from the state tree, in which case the code and head are ignored.
+	Deleted bool
+	// New actors to add to the state tree. Their balances are drawn from the migrated actor's balance.
+	// The results of cacheable migrations cannot include new actors (see CacheableMigration).
+	Created []CreatedActor
+}
+
+// An actor to be added to the state tree by a migration.
+// The address must not be that of any actor in the input tree, or of any other new actor.
+type CreatedActor struct {
+	Address address.Address
+	Code    cid.Cid
+	Head    cid.Cid
+	Balance abi.TokenAmount
+}
+
+// An ActorMigration may implement CacheableMigration to override whether its results are cached.
+// By default, the results of all migrations except CodeMigrator are cached.
+type CacheableMigration interface {
+	Cacheable() bool
+}
+
+type ActorMigration interface {
+	// Loads an actor's state from an input store and writes new state to an output store.
+	// Returns the new state head CID.
+	MigrateState(ctx context.Context, store cbor.IpldStore, input ActorMigrationInput) (result *ActorMigrationResult, err error)
+	MigratedCodeCID() cid.Cid
+}
+
+// A step run after all non-deferred actors have been migrated and written to the output tree.
+// Post-migrations migrate the deferred actors and perform any updates that span multiple actors.
+type PostMigration func(ctx context.Context, store cbor.IpldStore, actorsIn InputTree, actorsOut OutputTree, priorEpoch abi.ChainEpoch) error
+
+// Migration specifies how to migrate each actor in a state tree between two versions.
+type Migration struct {
+	// Maps prior version code CIDs to migration functions.
+	Migrations map[cid.Cid]ActorMigration
+	// Set of prior version code CIDs for actors to defer during iteration, for explicit migration by a post-migration.
+	// Actors with a code CID in DeferredMigrations are also deferred, and need not be listed here.
+	DeferredCodeIDs map[cid.Cid]struct{}
+	// Migrations to run in order, in a second phase after all non-deferred actors have been migrated and
+	// before the post-migrations.
+	DeferredMigrations []DeferredMigration
+	// Steps to run in order after all non-deferred actors have been migrated.
+	PostMigrations []PostMigration
+	// Returns a human-readable name for a prior version code CID, for error messages.
+	// Optional; the CID is printed if nil.
+	ActorNameByCode func(code cid.Cid) string
+	// Identifies the version of the migration code in cache keys, so that results cached by a different
+	// version (e.g. a pre-release with a bug since fixed) are not reused. Change it whenever an actor migration
+	// changes its output. Optional; if empty, cache keys are unversioned.
+	CacheVersion string
+}
+
+// Migrates all actors from an input tree to an output tree, according to the migration specification,
+// then flushes the output tree and returns its root along with statistics about the migration.
+// Write metrics are reported only if the store is a MeteredStore.
+// The store must support concurrent writes (even if the configured worker count is 1).
+func (m *Migration) MigrateStateTree(ctx context.Context, store cbor.IpldStore, actorsIn InputTree, actorsOut OutputTree, priorEpoch abi.ChainEpoch, cfg Config, log Logger, cache MigrationCache) (cid.Cid, *MigrationStats, error) {
+	if cfg.MaxWorkers <= 0 {
+		return cid.Undef, nil, xerrors.Errorf("invalid migration config with %d workers", cfg.MaxWorkers)
+	}
+	if err := validateFallback(cfg, actorsIn); err != nil {
+		return cid.Undef, nil, xerrors.Errorf("invalid migration config: %w", err)
+	}
+	var deleter ActorDeleter
+	if cfg.InPlace {
+		var ok bool
+		if deleter, ok = actorsOut.(ActorDeleter); !ok {
+			return cid.Undef, nil, xerrors.Errorf("in-place migration requires an output tree supporting deletion, got %T", actorsOut)
+		}
+	}
+	startTime := time.Now()
+	observer := observerOrDefault(cfg.Observer)
+	stats := newStatsCollector()
+	meteredCache := NewMeteredCache(cache)
+	cache = meteredCache
+	budget := newInFlightBudget(cfg.MaxInFlightBytes)
+	phaseOne := &PhaseOneResults{ActorsOut: actorsOut, Deleted: map[address.Address]cid.Cid{}}
+	rollback := cfg.Rollback
+	var created []CreatedActor // Collected by the result writer.
+	var deferredActors []deferredActor
+
+	// Setup synchronization
+	parentCtx := ctx
+	grp, ctx := errgroup.WithContext(ctx)
+	// Input and output queues for workers.
+	queues := newJobQueues(cfg)
+	jobResultCh := make(chan *migrationJobResult, cfg.ResultQueueSize)
+	// Atomically-modified counters for logging progress
+	var jobCount uint32
+	var doneCount uint32
+
+	// Iterate all actors in old state root to create migration jobs for each non-deferred actor.
+	grp.Go(func() error {
+		defer queues.close()
+		log.Log(rt.INFO, "Creating migration jobs")
+		if err := m.createJobs(ctx, actorsIn, cache, false, codeSet(cfg.PriorityCodes), observer, budget, queues, &jobCount, func(addr address.Address, actor *states.Actor) {
+			deferredActors = append(deferredActors, deferredActor{addr, *actor})
+		}); err != nil {
+			return err
+		}
+		log.Log(rt.INFO, "Done creating %d migration jobs after %v", jobCount, time.Since(startTime))
+		return nil
+	})
+
+	// Worker threads run jobs, sharing a read cache if configured.
+	workerStore := store
+	var readCache *CachingStore
+	if cfg.ReadCacheBytes > 0 {
+		readCache = NewCachingStore(store, cfg.ReadCacheBytes)
+		workerStore = readCache
+	}
+	var workerWg sync.WaitGroup
+	startWorker := func(jobCh <-chan *migrationJob, workerId int) {
+		workerWg.Add(1)
+		grp.Go(func() error {
+			defer workerWg.Done()
+			for job := range jobCh {
+				jobStart := time.Now()
+				jobStore := workerStore
+				var metered *MeteredStore
+				if cfg.ProfileActors {
+					metered = NewMeteredStore(workerStore)
+					jobStore = metered
+				}
+				result, err := job.run(ctx, jobStore, priorEpoch)
+				if err != nil {
+					return err
+				}
+				jobElapsed := time.Since(jobStart)
+				stats.recordJob(job.Actor.Code, jobElapsed)
+				if metered != nil {
+					stats.recordIO(job.Actor.Code, metered)
+				}
+				observer.OnJobDone(job.Address, job.Actor.Code, jobElapsed)
+				select {
+				case jobResultCh <- result:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				atomic.AddUint32(&doneCount, 1)
+			}
+			log.Log(rt.INFO, "Worker %d done", workerId)
+			return nil
+		})
+	}
+	workerCount := 0
+	for _, pool := range queues.pools() {
+		for i := uint(0); i < pool.workers; i++ {
+			startWorker(pool.queue, workerCount)
+			workerCount++
+		}
+	}
+	log.Log(rt.INFO, "Started %d workers", workerCount)
+
+	// Monitor the job queue. This non-critical goroutine is outside the errgroup and exits when
+	// workersFinished is closed, or the context done.
+	workersFinished := make(chan struct{}) // Closed when waitgroup is emptied.
+	if cfg.ProgressLogPeriod > 0 {
+		go func() {
+			defer log.Log(rt.DEBUG, "Job queue monitor done")
+			for {
+				select {
+				case <-time.After(cfg.ProgressLogPeriod):
+					jobsNow := atomic.LoadUint32(&jobCount) // Snapshot values to avoid incorrect-looking arithmetic if they change.
+					doneNow := atomic.LoadUint32(&doneCount)
+					pendingNow := jobsNow - doneNow
+					elapsed := time.Since(startTime)
+					rate := float64(doneNow) / elapsed.Seconds()
+					log.Log(rt.INFO, "%d jobs created, %d done, %d pending after %v (%.0f/s)",
+						jobsNow, doneNow, pendingNow, elapsed, rate)
+				case <-workersFinished:
+					return
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// Close result channel when workers are done sending to it.
+	grp.Go(func() error {
+		workerWg.Wait()
+		close(jobResultCh)
+		close(workersFinished)
+		log.Log(rt.INFO, "All workers done after %v", time.Since(startTime))
+		return nil
+	})
+
+	// Insert migrated records in output state tree and accumulators.
+	grp.Go(func() error {
+		log.Log(rt.INFO, "Result writer started")
+		resultCount := 0
+		setActor := func(addr address.Address, actor *states.Actor) error {
+			if err := actorsOut.SetActor(addr, actor); err != nil {
+				return err
+			}
+			resultCount++
+			if cfg.FlushPeriod > 0 && resultCount%int(cfg.FlushPeriod) == 0 {
+				flushStart := time.Now()
+				root, err := actorsOut.Flush()
+				if err != nil {
+					return xerrors.Errorf("failed to flush output state tree: %w", err)
+				}
+				observer.OnFlush(root, time.Since(flushStart))
+			}
+			return nil
+		}
+		var shards *shardedWriter
+		if cfg.ResultWriters > 1 {
+			var err error
+			if shards, err = startShardedWriter(ctx, store, actorsOut, cfg.ResultWriters); err != nil {
+				return err
+			}
+		}
+		writeResult := func(result *migrationJobResult) error {
+			created = append(created, result.created...)
+			if result.deleted {
+				stats.recordDeleted(result.prior.Code)
+				observer.OnActorDeleted(result.Address, result.prior.Code)
+				phaseOne.Deleted[result.Address] = result.prior.Code
+				rollback.recordDeleted(result.Address, &result.prior)
+				if err := cfg.AuditLog.actorDeleted(result.Address, result.prior.Code, result.prior.Balance); err != nil {
+					return err
+				}
+				if deleter != nil {
+					if shards != nil {
+						return shards.delete(ctx, result.Address, deleter)
+					}
+					return deleter.DeleteActor(result.Address)
+				}
+				return nil
+			}
+			if deleter != nil && actorRecordsEqual(&result.Actor, &result.prior) {
+				return nil
+			}
+			if shards != nil {
+				return shards.write(ctx, result)
+			}
+			return setActor(result.Address, &result.Actor)
+		}
+		var buffered []*migrationJobResult
+		for result := range jobResultCh {
+			budget.release(result.reserved)
+			if cfg.DeterministicOrder {
+				buffered = append(buffered, result)
+				continue
+			}
+			if err := writeResult(result); err != nil {
+				return err
+			}
+		}
+		sort.Slice(buffered, func(i, j int) bool {
+			return bytes.Compare(buffered[i].Address.Bytes(), buffered[j].Address.Bytes()) < 0
+		})
+		for _, result := range buffered {
+			if err := writeResult(result); err != nil {
+				return err
+			}
+		}
+		if shards != nil {
+			merged, err := shards.merge(ctx, setActor)
+			if err != nil {
+				return err
+			}
+			resultCount += merged
+		}
+		log.Log(rt.INFO, "Result writer wrote %d results to state tree after %v", resultCount, time.Since(startTime))
+		return nil
+	})
+
+	if err := grp.Wait(); err != nil {
+		return cid.Undef, nil, partialMigrationError(parentCtx, err, jobCount, doneCount, startTime)
+	}
+
+	elapsed := time.Since(startTime)
+	rate := float64(doneCount) / elapsed.Seconds()
+	log.Log(rt.INFO, "All %d done after %v (%.0f/s)", doneCount, elapsed, rate)
+
+	// New actors are written only after all others, so that their addresses can be checked against the input tree
+	// without contending with job creation.
+	sort.Slice(created, func(i, j int) bool {
+		return bytes.Compare(created[i].Address.Bytes(), created[j].Address.Bytes()) < 0
+	})
+	if err := writeCreatedActors(actorsIn, actorsOut, created, observer, stats); err != nil {
+		return cid.Undef, nil, err
+	}
+
+	if err := m.runDeferredMigrations(ctx, store, deferredActors, actorsIn, actorsOut, deleter, priorEpoch, cache, phaseOne, observer, stats, rollback, cfg.AuditLog); err != nil {
+		return cid.Undef, nil, err
+	}
+	if len(m.DeferredMigrations) > 0 {
+		log.Log(rt.INFO, "Ran %d deferred migrations after %v", len(m.DeferredMigrations), time.Since(startTime))
+	}
+
+	for i, post := range m.PostMigrations {
+		if err := post(ctx, store, actorsIn, actorsOut, priorEpoch); err != nil {
+			return cid.Undef, nil, xerrors.Errorf("post-migration %d failed: %w", i, err)
+		}
+	}
+	if len(m.PostMigrations) > 0 {
+		log.Log(rt.INFO, "Ran %d post-migrations after %v", len(m.PostMigrations), time.Since(startTime))
+	}
+
+	rollbackRoot, err := rollback.write(ctx, store)
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+
+	flushStart := time.Now()
+	root, err := actorsOut.Flush()
+	if err != nil {
+		return cid.Undef, nil, xerrors.Errorf("failed to flush output state tree: %w", err)
+	}
+	flushElapsed := time.Since(flushStart)
+	observer.OnFlush(root, flushElapsed)
+	finalStats := stats.finish(store, time.Since(startTime), flushElapsed)
+	finalStats.RollbackRecord = rollbackRoot
+	if readCache != nil {
+		finalStats.ReadCacheHits = readCache.Hits()
+		finalStats.ReadCacheMisses = readCache.Misses()
+	}
+	finalStats.CacheHits = meteredCache.Hits()
+	finalStats.CacheMisses = meteredCache.Misses()
+	finalStats.CacheWrites = meteredCache.WriteCount()
+	finalStats.CacheWriteBytes = meteredCache.WriteSize()
+	log.Log(rt.INFO, "Migration cache served %d of %d lookups (%.1f%% warm), %d entries written",
+		finalStats.CacheHits, finalStats.CacheHits+finalStats.CacheMisses, 100*finalStats.CacheWarmRate(), finalStats.CacheWrites)
+	return root, finalStats, nil
+}
+
+// Runs the cacheable actor migrations against a state tree from an epoch prior to the upgrade, populating
+// the cache with their results.
+// When the same cache is subsequently passed to MigrateStateTree, only actors whose state heads have changed
+// since the pre-migration need to be migrated again.
+// Migrations which only replace the actor code CID are cheap and are skipped here.
+// The store must support concurrent writes (even if the configured worker count is 1).
+func (m *Migration) PreMigrateStateTree(ctx context.Context, store cbor.IpldStore, actorsIn InputTree, priorEpoch abi.ChainEpoch, cfg Config, log Logger, cache MigrationCache) error {
+	if cfg.MaxWorkers <= 0 {
+		return xerrors.Errorf("invalid migration config with %d workers", cfg.MaxWorkers)
+	}
+	startTime := time.Now()
+	observer := observerOrDefault(cfg.Observer)
+	meteredCache := NewMeteredCache(cache)
+	cache = meteredCache
+	budget := newInFlightBudget(cfg.MaxInFlightBytes)
+
+	parentCtx := ctx
+	grp, ctx := errgroup.WithContext(ctx)
+	queues := newJobQueues(cfg)
+	var jobCount uint32
+	var doneCount uint32
+
+	// Iterate all actors in the tree to create pre-migration jobs for each cacheable, non-deferred actor.
+	grp.Go(func() error {
+		defer queues.close()
+		log.Log(rt.INFO, "Creating pre-migration jobs")
+		if err := m.createJobs(ctx, actorsIn, cache, true, codeSet(cfg.PriorityCodes), observer, budget, queues, &jobCount, nil); err != nil {
+			return err
+		}
+		log.Log(rt.INFO, "Done creating %d pre-migration jobs after %v", jobCount, time.Since(startTime))
+		return nil
+	})
+
+	// Worker threads run jobs, discarding the results which are retained only in the cache.
+	for _, pool := range queues.pools() {
+		jobCh := pool.queue
+		for i := uint(0); i < pool.workers; i++ {
+			grp.Go(func() error {
+				for job := range jobCh {
+					jobStart := time.Now()
+					if _, err := job.run(ctx, store, priorEpoch); err != nil {
+						return err
+					}
+					observer.OnJobDone(job.Address, job.Actor.Code, time.Since(jobStart))
+					budget.release(job.reserved)
+					atomic.AddUint32(&doneCount, 1)
+				}
+				return nil
+			})
+		}
+	}
+
+	if err := grp.Wait(); err != nil {
+		return partialMigrationError(parentCtx, err, jobCount, doneCount, startTime)
+	}
+	log.Log(rt.INFO, "Pre-migrated %d actors after %v, %d already cached (%.1f%% warm)",
+		doneCount, time.Since(startTime), meteredCache.Hits(), 100*meteredCache.WarmRate())
+	return nil
+}
+
+// Sends a job for each non-deferred actor in the tree (optionally, only those with cacheable migrations)
+// to the queue for its code, counting them. Deferred actors are passed to onDeferred, if not nil.
+// If isPriority is not nil, jobs for the actors it matches are sent in a first pass over the tree.
+func (m *Migration) createJobs(ctx context.Context, actorsIn InputTree, cache MigrationCache, cacheableOnly bool,
+	isPriority func(code cid.Cid) bool, observer MigrationObserver, budget *inFlightBudget, queues *jobQueues,
+	jobCount *uint32, onDeferred func(addr address.Address, actor *states.Actor)) error {
+	if isPriority == nil {
+		return m.createJobsPass(ctx, actorsIn, cache, cacheableOnly, nil, observer, budget, queues, jobCount, onDeferred)
+	}
+	if err := m.createJobsPass(ctx, actorsIn, cache, cacheableOnly, isPriority, observer, budget, queues, jobCount, nil); err != nil {
+		return err
+	}
+	isRemaining := func(code cid.Cid) bool { return !isPriority(code) }
+	return m.createJobsPass(ctx, actorsIn, cache, cacheableOnly, isRemaining, observer, budget, queues, jobCount, onDeferred)
+}
+
+// Sends jobs for the non-deferred actors matching a filter (or all, if the filter is nil).
+func (m *Migration) createJobsPass(ctx context.Context, actorsIn InputTree, cache MigrationCache, cacheableOnly bool,
+	filter func(code cid.Cid) bool, observer MigrationObserver, budget *inFlightBudget, queues *jobQueues,
+	jobCount *uint32, onDeferred func(addr address.Address, actor *states.Actor)) error {
+	return actorsIn.ForEach(func(addr address.Address, actorIn *states.Actor) error {
+		if m.isDeferred(actorIn.Code) {
+			if onDeferred != nil {
+				onDeferred(addr, actorIn)
+			}
+			return nil // Deferred for explicit migration later.
+		}
+		if filter != nil && !filter(actorIn.Code) {
+			return nil
+		}
+		migration, ok := m.Migrations[actorIn.Code]
+		if !ok {
+			return xerrors.Errorf("actor with code %s has no registered migration function", actorIn.Code)
+		}
+		if cacheableOnly && !isCacheable(migration) {
+			return nil
+		}
+		reserved, err := budget.acquire(ctx, estimateJobSize(migration, actorIn))
+		if err != nil {
+			return err
+		}
+		nextInput := &migrationJob{
+			Address:        addr,
+			Actor:          *actorIn, // Must take a copy, the pointer is not stable.
+			cache:          cache,
+			actorMigration: migration,
+			actorName:      m.actorName(actorIn.Code),
+			cacheKey:       VersionedActorHeadKey(m.CacheVersion, addr, actorIn.Head),
+			reserved:       reserved,
+		}
+		select {
+		case queues.forCode(actorIn.Code) <- nextInput:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		atomic.AddUint32(jobCount, 1)
+		observer.OnJobCreated(addr, actorIn.Code)
+		return nil
+	})
+}
+
+func (m *Migration) actorName(code cid.Cid) string {
+	if m.ActorNameByCode == nil {
+		return code.String()
+	}
+	return m.ActorNameByCode(code)
+}
+
+type migrationJob struct {
+	address.Address
+	states.Actor
+	actorMigration ActorMigration
+	cache          MigrationCache
+	actorName      string
+	cacheKey       string
+	reserved       uint64 // Bytes reserved from the in-flight budget.
+}
+
+type migrationJobResult struct {
+	address.Address
+	states.Actor
+	prior    states.Actor // The actor's record before migration.
+	deleted  bool
+	created  []CreatedActor
+	reserved uint64
+}
+
+func (job *migrationJob) run(ctx context.Context, store cbor.IpldStore, priorEpoch abi.ChainEpoch) (*migrationJobResult, error) {
+	input := ActorMigrationInput{
+		Address:    job.Address,
+		Balance:    job.Actor.Balance,
+		Head:       job.Actor.Head,
+		PriorEpoch: priorEpoch,
+		Cache:      job.cache,
+	}
+	var result *ActorMigrationResult
+	var err error
+	if isCacheable(job.actorMigration) {
+		// Reuse the new head computed by a pre-migration (or prior run) if the actor's state is unchanged since.
+		// A deletion is cached as an undefined head.
+		var newHead cid.Cid
+		newHead, err = job.cache.Load(job.cacheKey, func() (cid.Cid, error) {
+			res, err := job.actorMigration.MigrateState(ctx, store, input)
+			if err != nil {
+				return cid.Undef, err
+			}
+			if len(res.Created) > 0 {
+				return cid.Undef, xerrors.Errorf("cacheable migration cannot create actors")
+			}
+			if res.Deleted {
+				return cid.Undef, nil
+			}
+			return res.NewHead, nil
+		})
+		result = &ActorMigrationResult{NewCodeCID: job.actorMigration.MigratedCodeCID(), NewHead: newHead, Deleted: !newHead.Defined()}
+	} else {
+		result, err = job.actorMigration.MigrateState(ctx, store, input)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("state migration failed for %s actor, addr %s: %w",
+			job.actorName, job.Address, err)
+	}
+
+	balance, err := balanceAfterCreation(job.Actor.Balance, result.Created)
+	if err != nil {
+		return nil, xerrors.Errorf("state migration failed for %s actor, addr %s: %w",
+			job.actorName, job.Address, err)
+	}
+
+	// Set up new actor record with the migrated state.
+	return &migrationJobResult{
+		job.Address, // Unchanged
+		states.Actor{
+			Code:       result.NewCodeCID,
+			Head:       result.NewHead,
+			CallSeqNum: job.Actor.CallSeqNum, // Unchanged
+			Balance:    balance,
+		},
+		job.Actor,
+		result.Deleted,
+		result.Created,
+		job.reserved,
+	}, nil
+}
+
+// Whether the results of a migration are worth caching across runs.
+// Migrations which only replace the code CID are cheaper to re-run than to look up.
+func isCacheable(m ActorMigration) bool {
+	if c, ok := m.(CacheableMigration); ok {
+		return c.Cacheable()
+	}
+	_, isCodeOnly := m.(CodeMigrator)
+	return !isCodeOnly
+}
+
+// Migrator which preserves the head CID and provides a fixed result code CID.
+type CodeMigrator struct {
+	OutCodeCID cid.Cid
+}
+
+func (n CodeMigrator) MigrateState(_ context.Context, _ cbor.IpldStore, in ActorMigrationInput) (*ActorMigrationResult, error) {
+	return &ActorMigrationResult{
+		NewCodeCID: n.OutCodeCID,
+		NewHead:    in.Head,
+	}, nil
+}
+
+func (n CodeMigrator) MigratedCodeCID() cid.Cid {
+	return n.OutCodeCID
+}
+
+func actorRecordsEqual(a, b *states.Actor) bool {
+	return a.Code.Equals(b.Code) && a.Head.Equals(b.Head) && a.CallSeqNum == b.CallSeqNum && a.Balance.Equals(b.Balance)
+}
@@ -0,0 +1,874 @@
+package engine_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+var (
+	oldCode      = tutil.MakeCID("old", nil)
+	oldDeferred  = tutil.MakeCID("old-deferred", nil)
+	newCode      = tutil.MakeCID("new", nil)
+	newDeferred  = tutil.MakeCID("new-deferred", nil)
+	migratedHead = tutil.MakeCID("migrated", nil)
+)
+
+// Migrator which replaces every head with a fixed CID, counting invocations.
+type headMigrator struct {
+	calls *int32
+}
+
+func (m headMigrator) MigrateState(_ context.Context, _ cbor.IpldStore, _ engine.ActorMigrationInput) (*engine.ActorMigrationResult, error) {
+	atomic.AddInt32(m.calls, 1)
+	return &engine.ActorMigrationResult{NewCodeCID: newCode, NewHead: migratedHead}, nil
+}
+
+func (m headMigrator) MigratedCodeCID() cid.Cid {
+	return newCode
+}
+
+func TestMigrateStateTree(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	actorsIn := newTree(t, store, map[uint64]cid.Cid{100: oldCode, 101: oldCode, 102: oldDeferred})
+
+	var calls int32
+	deferredAddr := tutil.NewIDAddr(t, 102)
+	m := &engine.Migration{
+		Migrations:      map[cid.Cid]engine.ActorMigration{oldCode: headMigrator{&calls}},
+		DeferredCodeIDs: map[cid.Cid]struct{}{oldDeferred: {}},
+		PostMigrations: []engine.PostMigration{
+			func(_ context.Context, _ cbor.IpldStore, in engine.InputTree, out engine.OutputTree, _ abi.ChainEpoch) error {
+				// The deferred actor has not been written yet.
+				_, found, err := out.GetActor(deferredAddr)
+				require.NoError(t, err)
+				assert.False(t, found)
+
+				actor, found, err := in.GetActor(deferredAddr)
+				require.NoError(t, err)
+				require.True(t, found)
+				actor.Code = newDeferred
+				return out.SetActor(deferredAddr, actor)
+			},
+		},
+	}
+
+	cache := engine.NewMemMigrationCache()
+	actorsOut, err := states.NewTree(store)
+	require.NoError(t, err)
+	_, _, err = m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, engine.Config{MaxWorkers: 2}, engine.TestLogger{TB: t}, cache)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	expected := map[uint64]cid.Cid{100: newCode, 101: newCode, 102: newDeferred}
+	count := 0
+	require.NoError(t, actorsOut.ForEach(func(addr address.Address, actor *states.Actor) error {
+		id, err := address.IDFromAddress(addr)
+		require.NoError(t, err)
+		assert.Equal(t, expected[id], actor.Code)
+		if actor.Code == newCode {
+			assert.Equal(t, migratedHead, actor.Head)
+		}
+		count++
+		return nil
+	}))
+	assert.Equal(t, 3, count)
+
+	// A second run with the same cache reuses the cached heads.
+	actorsOut, err = states.NewTree(store)
+	require.NoError(t, err)
+	_, _, err = m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, engine.Config{MaxWorkers: 1}, engine.TestLogger{TB: t}, cache)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestMigrateStateTreeMissingMigration(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	actorsIn := newTree(t, store, map[uint64]cid.Cid{100: oldCode, 101: oldDeferred})
+	actorsOut, err := states.NewTree(store)
+	require.NoError(t, err)
+
+	m := &engine.Migration{Migrations: map[cid.Cid]engine.ActorMigration{
+		oldCode: engine.CodeMigrator{OutCodeCID: newCode},
+	}}
+	_, _, err = m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, engine.Config{MaxWorkers: 1}, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+	require.Error(t, err)
+}
+
+func newTree(t *testing.T, store adt.Store, codes map[uint64]cid.Cid) *states.Tree {
+	tree, err := states.NewTree(store)
+	require.NoError(t, err)
+	for id, code := range codes { // nolint:nomaprange
+		require.NoError(t, tree.SetActor(tutil.NewIDAddr(t, id), &states.Actor{
+			Code:    code,
+			Head:    builtin.AccountActorCodeID,
+			Balance: big.Zero(),
+		}))
+	}
+	return tree
+}
+
+func TestBuildDryRunReport(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewBlockStoreInMemory())
+	actorsIn := newTree(t, store, map[uint64]cid.Cid{100: oldCode, 101: oldCode, 102: oldCode})
+	actorsOut := newTree(t, store, map[uint64]cid.Cid{100: oldCode, 101: newCode, 103: newCode})
+
+	// Move a balance from a removed actor to a new one.
+	require.NoError(t, actorsIn.SetActor(tutil.NewIDAddr(t, 102), &states.Actor{
+		Code:    oldCode,
+		Head:    builtin.AccountActorCodeID,
+		Balance: abi.NewTokenAmount(10),
+	}))
+	require.NoError(t, actorsOut.SetActor(tutil.NewIDAddr(t, 103), &states.Actor{
+		Code:    newCode,
+		Head:    builtin.AccountActorCodeID,
+		Balance: abi.NewTokenAmount(10),
+	}))
+
+	report, err := engine.BuildDryRunReport(actorsIn, actorsOut)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Unchanged)
+	require.Len(t, report.Changed, 1)
+	assert.Equal(t, tutil.NewIDAddr(t, 101), report.Changed[0].Address)
+	assert.Equal(t, oldCode, report.Changed[0].CodeBefore)
+	assert.Equal(t, newCode, report.Changed[0].CodeAfter)
+	assert.Equal(t, []address.Address{tutil.NewIDAddr(t, 102)}, report.Removed)
+	assert.Equal(t, []address.Address{tutil.NewIDAddr(t, 103)}, report.Added)
+	assert.Equal(t, []engine.BalanceChange{
+		{Address: tutil.NewIDAddr(t, 102), Before: abi.NewTokenAmount(10), After: abi.NewTokenAmount(0)},
+		{Address: tutil.NewIDAddr(t, 103), Before: abi.NewTokenAmount(0), After: abi.NewTokenAmount(10)},
+	}, report.BalanceChanges)
+}
+
+// Migrator which removes every actor.
+type deleteMigrator struct{}
+
+func (deleteMigrator) MigrateState(_ context.Context, _ cbor.IpldStore, _ engine.ActorMigrationInput) (*engine.ActorMigrationResult, error) {
+	return &engine.ActorMigrationResult{Deleted: true}, nil
+}
+
+func (deleteMigrator) MigratedCodeCID() cid.Cid {
+	return cid.Undef
+}
+
+type countingObserver struct {
+	created, done, deleted, added, flushed int32
+	root                                   cid.Cid
+}
+
+func (o *countingObserver) OnJobCreated(address.Address, cid.Cid) {
+	atomic.AddInt32(&o.created, 1)
+}
+
+func (o *countingObserver) OnJobDone(address.Address, cid.Cid, time.Duration) {
+	atomic.AddInt32(&o.done, 1)
+}
+
+func (o *countingObserver) OnActorDeleted(address.Address, cid.Cid) {
+	atomic.AddInt32(&o.deleted, 1)
+}
+
+func (o *countingObserver) OnActorCreated(address.Address, cid.Cid) {
+	atomic.AddInt32(&o.added, 1)
+}
+
+func (o *countingObserver) OnFlush(root cid.Cid, _ time.Duration) {
+	atomic.AddInt32(&o.flushed, 1)
+	o.root = root
+}
+
+func TestMigrationObserverAndStats(t *testing.T) {
+	ctx := context.Background()
+	store := engine.NewMeteredStore(adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory())))
+	actorsIn := newTree(t, adt.WrapStore(ctx, store), map[uint64]cid.Cid{100: oldCode, 101: oldCode, 102: oldDeferred})
+	actorsOut, err := states.NewTree(adt.WrapStore(ctx, store))
+	require.NoError(t, err)
+
+	m := &engine.Migration{Migrations: map[cid.Cid]engine.ActorMigration{
+		oldCode:     engine.CodeMigrator{OutCodeCID: newCode},
+		oldDeferred: deleteMigrator{},
+	}}
+	observer := &countingObserver{}
+	cfg := engine.Config{MaxWorkers: 2, Observer: observer}
+	root, stats, err := m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(3), observer.created)
+	assert.Equal(t, int32(3), observer.done)
+	assert.Equal(t, int32(1), observer.deleted)
+	assert.Equal(t, int32(1), observer.flushed)
+	assert.Equal(t, root, observer.root)
+
+	assert.Equal(t, uint64(3), stats.JobCount)
+	assert.Equal(t, uint64(1), stats.DeletedCount)
+	assert.Equal(t, uint64(2), stats.ActorTypes[oldCode].Count)
+	assert.Equal(t, uint64(1), stats.ActorTypes[oldDeferred].Count)
+	assert.Equal(t, uint64(1), stats.ActorTypes[oldDeferred].Deleted)
+	assert.True(t, stats.WriteCount > 0)
+	assert.True(t, stats.WriteBytes > 0)
+
+	_, found, err := actorsOut.GetActor(tutil.NewIDAddr(t, 102))
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+// Migrator which claims a large memory footprint, recording the maximum number of concurrent migrations.
+type sizedMigrator struct {
+	active, maxActive *int32
+}
+
+func (m sizedMigrator) MigrateState(_ context.Context, _ cbor.IpldStore, in engine.ActorMigrationInput) (*engine.ActorMigrationResult, error) {
+	n := atomic.AddInt32(m.active, 1)
+	defer atomic.AddInt32(m.active, -1)
+	for {
+		max := atomic.LoadInt32(m.maxActive)
+		if n <= max || atomic.CompareAndSwapInt32(m.maxActive, max, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	return &engine.ActorMigrationResult{NewCodeCID: newCode, NewHead: in.Head}, nil
+}
+
+func (m sizedMigrator) MigratedCodeCID() cid.Cid {
+	return newCode
+}
+
+func (m sizedMigrator) EstimateSize(_ *states.Actor) uint64 {
+	return 100
+}
+
+func TestMigrationInFlightBudget(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	codes := map[uint64]cid.Cid{}
+	for id := uint64(100); id < 120; id++ {
+		codes[id] = oldCode
+	}
+	actorsIn := newTree(t, store, codes)
+
+	run := func(maxBytes uint64) int32 {
+		var active, maxActive int32
+		m := &engine.Migration{Migrations: map[cid.Cid]engine.ActorMigration{
+			oldCode: sizedMigrator{&active, &maxActive},
+		}}
+		actorsOut, err := states.NewTree(store)
+		require.NoError(t, err)
+		cfg := engine.Config{MaxWorkers: 8, JobQueueSize: 20, ResultQueueSize: 20, MaxInFlightBytes: maxBytes}
+		_, stats, err := m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+		require.NoError(t, err)
+		assert.Equal(t, uint64(20), stats.JobCount)
+		return maxActive
+	}
+
+	// A budget for two jobs limits concurrency, even with more workers available.
+	assert.LessOrEqual(t, run(250), int32(2))
+	// A budget smaller than a single job still allows progress.
+	assert.Equal(t, int32(1), run(50))
+}
+
+// Output tree which records the order of writes.
+type recordingTree struct {
+	*states.Tree
+	written []address.Address
+}
+
+func (r *recordingTree) SetActor(addr address.Address, actor *states.Actor) error {
+	r.written = append(r.written, addr)
+	return r.Tree.SetActor(addr, actor)
+}
+
+func TestMigrateStateTreeDeterministicOrder(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	codes := map[uint64]cid.Cid{}
+	for id := uint64(100); id < 150; id++ {
+		codes[id] = oldCode
+	}
+	actorsIn := newTree(t, store, codes)
+
+	var active, maxActive int32
+	m := &engine.Migration{Migrations: map[cid.Cid]engine.ActorMigration{
+		oldCode: sizedMigrator{&active, &maxActive},
+	}}
+	tree, err := states.NewTree(store)
+	require.NoError(t, err)
+	actorsOut := &recordingTree{Tree: tree}
+	cfg := engine.Config{MaxWorkers: 4, DeterministicOrder: true}
+	_, _, err = m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+	require.NoError(t, err)
+
+	require.Len(t, actorsOut.written, 50)
+	for i, addr := range actorsOut.written {
+		assert.Equal(t, tutil.NewIDAddr(t, uint64(100+i)), addr)
+	}
+}
+
+func TestMigrateStateTreeIncrementalFlush(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	codes := map[uint64]cid.Cid{}
+	for id := uint64(100); id < 150; id++ {
+		codes[id] = oldCode
+	}
+	actorsIn := newTree(t, store, codes)
+	m := &engine.Migration{Migrations: map[cid.Cid]engine.ActorMigration{
+		oldCode: engine.CodeMigrator{OutCodeCID: newCode},
+	}}
+
+	run := func(cfg engine.Config) (cid.Cid, *countingObserver) {
+		observer := &countingObserver{}
+		cfg.Observer = observer
+		actorsOut, err := states.NewTree(store)
+		require.NoError(t, err)
+		root, _, err := m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+		require.NoError(t, err)
+		return root, observer
+	}
+
+	expectedRoot, observer := run(engine.Config{MaxWorkers: 2})
+	assert.Equal(t, int32(1), observer.flushed)
+
+	// Five intermediate flushes and the final one produce the same root.
+	root, observer := run(engine.Config{MaxWorkers: 2, FlushPeriod: 10})
+	assert.Equal(t, int32(6), observer.flushed)
+	assert.Equal(t, expectedRoot, root)
+	assert.Equal(t, expectedRoot, observer.root)
+}
+
+// Migrator which writes a new state object and reads it back.
+type ioMigrator struct{}
+
+func (ioMigrator) MigrateState(ctx context.Context, store cbor.IpldStore, in engine.ActorMigrationInput) (*engine.ActorMigrationResult, error) {
+	newHead, err := store.Put(ctx, &states.Actor{Code: newCode, Head: in.Head, Balance: in.Balance})
+	if err != nil {
+		return nil, err
+	}
+	var check states.Actor
+	if err := store.Get(ctx, newHead, &check); err != nil {
+		return nil, err
+	}
+	return &engine.ActorMigrationResult{NewCodeCID: newCode, NewHead: newHead}, nil
+}
+
+func (ioMigrator) MigratedCodeCID() cid.Cid {
+	return newCode
+}
+
+func TestMigrateStateTreeProfileActors(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	actorsIn := newTree(t, store, map[uint64]cid.Cid{100: oldCode, 101: oldCode, 102: oldDeferred})
+	m := &engine.Migration{Migrations: map[cid.Cid]engine.ActorMigration{
+		oldCode:     ioMigrator{},
+		oldDeferred: engine.CodeMigrator{OutCodeCID: newDeferred},
+	}}
+
+	actorsOut, err := states.NewTree(store)
+	require.NoError(t, err)
+	cfg := engine.Config{MaxWorkers: 2, ProfileActors: true}
+	_, stats, err := m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+	require.NoError(t, err)
+
+	ioStats := stats.ActorTypes[oldCode]
+	assert.Equal(t, uint64(2), ioStats.Count)
+	assert.Equal(t, uint64(2), ioStats.Reads)
+	assert.Equal(t, uint64(2), ioStats.Writes)
+	assert.True(t, ioStats.WriteBytes > 0)
+	assert.Equal(t, ioStats.WriteBytes, ioStats.ReadBytes)
+
+	codeStats := stats.ActorTypes[oldDeferred]
+	assert.Equal(t, uint64(1), codeStats.Count)
+	assert.Zero(t, codeStats.Reads)
+	assert.Zero(t, codeStats.Writes)
+}
+
+// Deferred migrator which records the order of its invocations and the deletions from phase one.
+type recordingDeferredMigrator struct {
+	name    string
+	calls   *[]string
+	deleted *[]address.Address
+}
+
+func (m recordingDeferredMigrator) MigrateState(_ context.Context, _ cbor.IpldStore, in engine.ActorMigrationInput, phaseOne *engine.PhaseOneResults) (*engine.ActorMigrationResult, error) {
+	*m.calls = append(*m.calls, m.name)
+	for addr := range phaseOne.Deleted { // nolint:nomaprange
+		*m.deleted = append(*m.deleted, addr)
+	}
+	return &engine.ActorMigrationResult{NewCodeCID: newDeferred, NewHead: in.Head}, nil
+}
+
+func (m recordingDeferredMigrator) MigratedCodeCID() cid.Cid {
+	return newDeferred
+}
+
+func TestMigrateStateTreeDeferredPhase(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	oldDeleted := tutil.MakeCID("old-deleted", nil)
+	oldSecond := tutil.MakeCID("old-second", nil)
+	actorsIn := newTree(t, store, map[uint64]cid.Cid{100: oldCode, 101: oldDeleted, 102: oldSecond, 103: oldDeferred})
+
+	var calls []string
+	var deleted []address.Address
+	m := &engine.Migration{
+		Migrations: map[cid.Cid]engine.ActorMigration{
+			oldCode:    engine.CodeMigrator{OutCodeCID: newCode},
+			oldDeleted: deleteMigrator{},
+		},
+		// Run in the order specified, regardless of the order of actors in the tree.
+		DeferredMigrations: []engine.DeferredMigration{
+			{Code: oldSecond, Migration: recordingDeferredMigrator{"second", &calls, &deleted}},
+			{Code: oldDeferred, Migration: recordingDeferredMigrator{"first", &calls, &deleted}},
+		},
+	}
+	actorsOut, err := states.NewTree(store)
+	require.NoError(t, err)
+	_, stats, err := m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, engine.Config{MaxWorkers: 2}, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"second", "first"}, calls)
+	assert.Equal(t, []address.Address{tutil.NewIDAddr(t, 101), tutil.NewIDAddr(t, 101)}, deleted)
+	assert.Equal(t, uint64(4), stats.JobCount)
+	for _, id := range []uint64{102, 103} {
+		actor, found, err := actorsOut.GetActor(tutil.NewIDAddr(t, id))
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, newDeferred, actor.Code)
+	}
+}
+
+func TestMigrateStateTreeRollbackRecord(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	actorsIn := newTree(t, store, map[uint64]cid.Cid{100: oldCode, 101: oldDeferred, 102: oldDeferred})
+	m := &engine.Migration{Migrations: map[cid.Cid]engine.ActorMigration{
+		oldCode:     engine.CodeMigrator{OutCodeCID: newCode},
+		oldDeferred: deleteMigrator{},
+	}}
+
+	actorsOut, err := states.NewTree(store)
+	require.NoError(t, err)
+	cfg := engine.Config{MaxWorkers: 2, Rollback: engine.NewRollbackRecorder()}
+	_, stats, err := m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+	require.NoError(t, err)
+	require.True(t, stats.RollbackRecord.Defined())
+
+	var record engine.RollbackRecord
+	require.NoError(t, store.Get(ctx, stats.RollbackRecord, &record))
+	require.Len(t, record.DeletedActors, 2)
+	for i, id := range []uint64{101, 102} {
+		deleted := record.DeletedActors[i]
+		assert.Equal(t, tutil.NewIDAddr(t, id), deleted.Address)
+		expected, found, err := actorsIn.GetActor(deleted.Address)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, *expected, deleted.Actor)
+	}
+	assert.Empty(t, record.BalanceTransfers)
+
+	// No record is written by default.
+	actorsOut, err = states.NewTree(store)
+	require.NoError(t, err)
+	_, stats, err = m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, engine.Config{MaxWorkers: 2}, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+	require.NoError(t, err)
+	assert.False(t, stats.RollbackRecord.Defined())
+}
+
+func TestMigrateStateTreeShardedWriters(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	codes := map[uint64]cid.Cid{}
+	for id := uint64(100); id < 200; id++ {
+		codes[id] = oldCode
+	}
+	codes[200] = oldDeferred
+	actorsIn := newTree(t, store, codes)
+	m := &engine.Migration{Migrations: map[cid.Cid]engine.ActorMigration{
+		oldCode:     engine.CodeMigrator{OutCodeCID: newCode},
+		oldDeferred: deleteMigrator{},
+	}}
+
+	run := func(cfg engine.Config) (cid.Cid, *engine.MigrationStats) {
+		actorsOut, err := states.NewTree(store)
+		require.NoError(t, err)
+		root, stats, err := m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+		require.NoError(t, err)
+		return root, stats
+	}
+
+	expectedRoot, _ := run(engine.Config{MaxWorkers: 4})
+	root, stats := run(engine.Config{MaxWorkers: 4, ResultWriters: 3, FlushPeriod: 7})
+	assert.Equal(t, expectedRoot, root)
+	assert.Equal(t, uint64(1), stats.DeletedCount)
+
+	// More writers than slots in the tree's root node.
+	root, _ = run(engine.Config{MaxWorkers: 4, ResultWriters: 40})
+	assert.Equal(t, expectedRoot, root)
+
+	// An output tree other than a *states.Tree has the shard trees inserted into it.
+	actorsOut, err := states.NewTree(store)
+	require.NoError(t, err)
+	cfg := engine.Config{MaxWorkers: 4, ResultWriters: 3}
+	root, _, err = m.MigrateStateTree(ctx, store, actorsIn, struct{ engine.OutputTree }{actorsOut}, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+	require.NoError(t, err)
+	assert.Equal(t, expectedRoot, root)
+}
+
+func TestMigrateStateTreeInPlace(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	codes := map[uint64]cid.Cid{}
+	for id := uint64(100); id < 120; id++ {
+		codes[id] = oldCode
+	}
+	for id := uint64(120); id < 130; id++ {
+		codes[id] = newCode
+	}
+	codes[130] = oldDeferred
+	actorsIn := newTree(t, store, codes)
+	rootIn, err := actorsIn.Flush()
+	require.NoError(t, err)
+	m := &engine.Migration{Migrations: map[cid.Cid]engine.ActorMigration{
+		oldCode:     engine.CodeMigrator{OutCodeCID: newCode},
+		newCode:     engine.CodeMigrator{OutCodeCID: newCode},
+		oldDeferred: deleteMigrator{},
+	}}
+	emptyTree, err := states.NewTree(store)
+	require.NoError(t, err)
+	expectedRoot, _, err := m.MigrateStateTree(ctx, store, actorsIn, emptyTree, 0, engine.Config{MaxWorkers: 4}, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+	require.NoError(t, err)
+
+	for _, writers := range []uint{1, 3} {
+		tree, err := states.LoadTree(store, rootIn)
+		require.NoError(t, err)
+		actorsOut := &recordingTree{Tree: tree}
+		cfg := engine.Config{MaxWorkers: 4, InPlace: true, ResultWriters: writers}
+		root, stats, err := m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+		require.NoError(t, err)
+		assert.Equal(t, expectedRoot, root)
+		assert.Equal(t, uint64(1), stats.DeletedCount)
+		// Unchanged actors are not rewritten.
+		assert.Len(t, actorsOut.written, 20)
+	}
+
+	// A plain state tree is merged into directly, the shard trees starting from its pointers.
+	tree, err := states.LoadTree(store, rootIn)
+	require.NoError(t, err)
+	cfg := engine.Config{MaxWorkers: 4, InPlace: true, ResultWriters: 3}
+	root, stats, err := m.MigrateStateTree(ctx, store, actorsIn, tree, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+	require.NoError(t, err)
+	assert.Equal(t, expectedRoot, root)
+	assert.Equal(t, uint64(1), stats.DeletedCount)
+
+	// The output tree must support deletion.
+	tree, err = states.LoadTree(store, rootIn)
+	require.NoError(t, err)
+	cfg = engine.Config{MaxWorkers: 4, InPlace: true}
+	_, _, err = m.MigrateStateTree(ctx, store, actorsIn, struct{ engine.OutputTree }{tree}, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+	assert.Error(t, err)
+}
+
+// Migrator which records the code CIDs of the actors it migrates, in order.
+type orderMigrator struct {
+	lk    *sync.Mutex
+	order *[]cid.Cid
+	code  cid.Cid
+}
+
+func (m orderMigrator) MigrateState(_ context.Context, _ cbor.IpldStore, in engine.ActorMigrationInput) (*engine.ActorMigrationResult, error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	*m.order = append(*m.order, m.code)
+	return &engine.ActorMigrationResult{NewCodeCID: newCode, NewHead: in.Head}, nil
+}
+
+func (m orderMigrator) MigratedCodeCID() cid.Cid {
+	return newCode
+}
+
+// Migrator which blocks until a channel is closed.
+type waitingMigrator struct {
+	wait <-chan struct{}
+}
+
+func (m waitingMigrator) MigrateState(ctx context.Context, _ cbor.IpldStore, in engine.ActorMigrationInput) (*engine.ActorMigrationResult, error) {
+	select {
+	case <-m.wait:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &engine.ActorMigrationResult{NewCodeCID: newCode, NewHead: in.Head}, nil
+}
+
+func (m waitingMigrator) MigratedCodeCID() cid.Cid {
+	return newCode
+}
+
+// Migrator which closes a channel after migrating a number of actors.
+type signallingMigrator struct {
+	remaining *int32
+	done      chan struct{}
+}
+
+func (m signallingMigrator) MigrateState(_ context.Context, _ cbor.IpldStore, in engine.ActorMigrationInput) (*engine.ActorMigrationResult, error) {
+	if atomic.AddInt32(m.remaining, -1) == 0 {
+		close(m.done)
+	}
+	return &engine.ActorMigrationResult{NewCodeCID: newCode, NewHead: in.Head}, nil
+}
+
+func (m signallingMigrator) MigratedCodeCID() cid.Cid {
+	return newCode
+}
+
+func TestMigrateStateTreeScheduling(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	expensiveCode := tutil.MakeCID("expensive", nil)
+	codes := map[uint64]cid.Cid{}
+	for id := uint64(100); id < 150; id++ {
+		codes[id] = oldCode
+		if id%10 == 0 {
+			codes[id] = expensiveCode
+		}
+	}
+	actorsIn := newTree(t, store, codes)
+
+	t.Run("dedicated workers", func(t *testing.T) {
+		// The shared worker blocks until all the expensive actors are migrated, which requires a dedicated worker.
+		// The job queue is large enough that creation doesn't block behind the shared worker.
+		remaining := int32(5)
+		done := make(chan struct{})
+		m := &engine.Migration{Migrations: map[cid.Cid]engine.ActorMigration{
+			oldCode:       waitingMigrator{done},
+			expensiveCode: signallingMigrator{&remaining, done},
+		}}
+		actorsOut, err := states.NewTree(store)
+		require.NoError(t, err)
+		cfg := engine.Config{MaxWorkers: 1, JobQueueSize: 50, DedicatedWorkers: map[cid.Cid]uint{expensiveCode: 1}}
+		_, stats, err := m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+		require.NoError(t, err)
+		assert.Equal(t, uint64(50), stats.JobCount)
+	})
+
+	t.Run("priority codes", func(t *testing.T) {
+		var lk sync.Mutex
+		var order []cid.Cid
+		m := &engine.Migration{Migrations: map[cid.Cid]engine.ActorMigration{
+			oldCode:       orderMigrator{&lk, &order, oldCode},
+			expensiveCode: orderMigrator{&lk, &order, expensiveCode},
+		}}
+		actorsOut, err := states.NewTree(store)
+		require.NoError(t, err)
+		cfg := engine.Config{MaxWorkers: 1, PriorityCodes: []cid.Cid{expensiveCode}}
+		_, _, err = m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+		require.NoError(t, err)
+		require.Len(t, order, 50)
+		for i, code := range order {
+			assert.Equal(t, i < 5, code.Equals(expensiveCode), "job %d", i)
+		}
+	})
+}
+
+// Migrator which cancels a context on its first invocation.
+type cancellingMigrator struct {
+	cancel context.CancelFunc
+}
+
+func (m cancellingMigrator) MigrateState(ctx context.Context, _ cbor.IpldStore, in engine.ActorMigrationInput) (*engine.ActorMigrationResult, error) {
+	m.cancel()
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (m cancellingMigrator) MigratedCodeCID() cid.Cid {
+	return newCode
+}
+
+func TestMigrateStateTreeCancelled(t *testing.T) {
+	store := adt.WrapBlockStore(context.Background(), ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	actorsIn := newTree(t, store, map[uint64]cid.Cid{100: oldCode, 101: oldCode, 102: oldCode})
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &engine.Migration{Migrations: map[cid.Cid]engine.ActorMigration{
+		oldCode: cancellingMigrator{cancel},
+	}}
+
+	actorsOut, err := states.NewTree(store)
+	require.NoError(t, err)
+	_, _, err = m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, engine.Config{MaxWorkers: 1}, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+	var partial *engine.PartialMigrationError
+	require.True(t, xerrors.As(err, &partial), "unexpected error %v", err)
+	assert.True(t, xerrors.Is(err, context.Canceled))
+	assert.Equal(t, uint32(0), partial.JobsCompleted)
+	assert.True(t, partial.JobsCreated >= 1)
+
+	// Other failures are returned unchanged.
+	err = m.PreMigrateStateTree(context.Background(), store, actorsIn, 0, engine.Config{}, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+	require.Error(t, err)
+	assert.False(t, xerrors.As(err, &partial))
+}
+
+func TestMigrateStateTreeCacheVersion(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	actorsIn := newTree(t, store, map[uint64]cid.Cid{100: oldCode, 101: oldCode})
+
+	var calls int32
+	cache := engine.NewMemMigrationCache()
+	run := func(version string) {
+		m := &engine.Migration{
+			Migrations:   map[cid.Cid]engine.ActorMigration{oldCode: headMigrator{&calls}},
+			CacheVersion: version,
+		}
+		actorsOut, err := states.NewTree(store)
+		require.NoError(t, err)
+		_, _, err = m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, engine.Config{MaxWorkers: 2}, engine.TestLogger{TB: t}, cache)
+		require.NoError(t, err)
+	}
+
+	run("v1")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	run("v1")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// Entries cached by a different version are not reused.
+	run("v2")
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+	found, _, err := cache.Read(engine.VersionedActorHeadKey("v2", tutil.NewIDAddr(t, 100), builtin.AccountActorCodeID))
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	require.NoError(t, cache.InvalidateAll())
+	run("v2")
+	assert.Equal(t, int32(6), atomic.LoadInt32(&calls))
+}
+
+func TestMigrateActors(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	oldDeleted := tutil.MakeCID("old-deleted", nil)
+	actorsIn := newTree(t, store, map[uint64]cid.Cid{100: oldCode, 101: oldCode, 102: oldDeleted, 103: oldDeferred})
+
+	var calls int32
+	m := &engine.Migration{
+		Migrations: map[cid.Cid]engine.ActorMigration{
+			oldCode:    headMigrator{&calls},
+			oldDeleted: deleteMigrator{},
+		},
+		DeferredCodeIDs: map[cid.Cid]struct{}{oldDeferred: {}},
+	}
+
+	results, err := m.MigrateActors(ctx, store, actorsIn, []address.Address{tutil.NewIDAddr(t, 102), tutil.NewIDAddr(t, 101)}, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	require.Len(t, results, 2)
+	assert.Equal(t, tutil.NewIDAddr(t, 102), results[0].Address)
+	assert.True(t, results[0].Deleted)
+	assert.Equal(t, oldDeleted, results[0].Before.Code)
+	assert.Equal(t, tutil.NewIDAddr(t, 101), results[1].Address)
+	assert.False(t, results[1].Deleted)
+	assert.Equal(t, newCode, results[1].After.Code)
+	assert.Equal(t, migratedHead, results[1].After.Head)
+
+	// Missing and deferred actors are rejected.
+	_, err = m.MigrateActors(ctx, store, actorsIn, []address.Address{tutil.NewIDAddr(t, 999)}, 0, nil)
+	assert.Error(t, err)
+	_, err = m.MigrateActors(ctx, store, actorsIn, []address.Address{tutil.NewIDAddr(t, 103)}, 0, nil)
+	assert.Error(t, err)
+}
+
+// Migrator which creates a new actor funded by the migrated actor, at an address offset from its own.
+type creatingMigrator struct {
+	offset  uint64
+	balance abi.TokenAmount
+}
+
+func (m creatingMigrator) MigrateState(_ context.Context, _ cbor.IpldStore, in engine.ActorMigrationInput) (*engine.ActorMigrationResult, error) {
+	id, err := address.IDFromAddress(in.Address)
+	if err != nil {
+		return nil, err
+	}
+	newAddr, err := address.NewIDAddress(id + m.offset)
+	if err != nil {
+		return nil, err
+	}
+	return &engine.ActorMigrationResult{
+		NewCodeCID: newCode,
+		NewHead:    in.Head,
+		Created:    []engine.CreatedActor{{Address: newAddr, Code: newDeferred, Head: in.Head, Balance: m.balance}},
+	}, nil
+}
+
+func (m creatingMigrator) MigratedCodeCID() cid.Cid {
+	return newCode
+}
+
+func (m creatingMigrator) Cacheable() bool {
+	return false
+}
+
+func TestMigrateStateTreeCreatesActors(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	actorsIn := newTree(t, store, map[uint64]cid.Cid{100: oldCode, 101: oldCode})
+	for _, id := range []uint64{100, 101} {
+		require.NoError(t, actorsIn.SetActor(tutil.NewIDAddr(t, id), &states.Actor{
+			Code: oldCode, Head: builtin.AccountActorCodeID, Balance: abi.NewTokenAmount(10),
+		}))
+	}
+
+	run := func(migrator engine.ActorMigration) (*states.Tree, *engine.MigrationStats, *countingObserver, error) {
+		m := &engine.Migration{Migrations: map[cid.Cid]engine.ActorMigration{oldCode: migrator}}
+		actorsOut, err := states.NewTree(store)
+		require.NoError(t, err)
+		observer := &countingObserver{}
+		cfg := engine.Config{MaxWorkers: 2, Observer: observer}
+		_, stats, err := m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+		return actorsOut, stats, observer, err
+	}
+
+	actorsOut, stats, observer, err := run(creatingMigrator{offset: 100, balance: abi.NewTokenAmount(3)})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), stats.CreatedCount)
+	assert.Equal(t, int32(2), observer.added)
+	for _, id := range []uint64{100, 101} {
+		creator, found, err := actorsOut.GetActor(tutil.NewIDAddr(t, id))
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, abi.NewTokenAmount(7), creator.Balance)
+		created, found, err := actorsOut.GetActor(tutil.NewIDAddr(t, id+100))
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, newDeferred, created.Code)
+		assert.Equal(t, abi.NewTokenAmount(3), created.Balance)
+	}
+
+	// Creating an actor at an existing address fails.
+	_, _, _, err = run(creatingMigrator{offset: 1, balance: abi.NewTokenAmount(3)})
+	require.Error(t, err)
+	// Creating an actor with more balance than its creator fails.
+	_, _, _, err = run(creatingMigrator{offset: 100, balance: abi.NewTokenAmount(11)})
+	require.Error(t, err)
+}
@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Returned when a migration is interrupted by cancellation of its context before all actors were migrated.
+// Cacheable migrations completed before the interruption are retained in the MigrationCache, so a subsequent
+// run with the same cache resumes rather than repeats that work.
+type PartialMigrationError struct {
+	// Number of migration jobs created before the interruption.
+	JobsCreated uint32
+	// Number of migration jobs completed before the interruption.
+	JobsCompleted uint32
+	// Time from the start of the migration to the interruption.
+	Elapsed time.Duration
+	// The cause of the interruption.
+	Err error
+}
+
+func (e *PartialMigrationError) Error() string {
+	return fmt.Sprintf("migration interrupted after %d of %d jobs completed in %v: %v",
+		e.JobsCompleted, e.JobsCreated, e.Elapsed, e.Err)
+}
+
+func (e *PartialMigrationError) Unwrap() error {
+	return e.Err
+}
+
+// Wraps err in a PartialMigrationError if the context was cancelled, otherwise returns it unchanged.
+func partialMigrationError(ctx context.Context, err error, jobCount, doneCount uint32, startTime time.Time) error {
+	if ctx.Err() == nil {
+		return err
+	}
+	return &PartialMigrationError{
+		JobsCreated:   jobCount,
+		JobsCompleted: doneCount,
+		Elapsed:       time.Since(startTime),
+		Err:           ctx.Err(),
+	}
+}
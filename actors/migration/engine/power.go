@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"sort"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/power"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// A deferred migration of the power actor which removes the claims of exactly the miners removed in the first
+// phase of migration, keeping the miner counts and power totals consistent.
+// Register it as the DeferredMigration for the prior power actor code CID, in any migration whose miner
+// migration may delete miners. The prior power state must have the layout of the v7 state.
+type PowerClaimsCleanup struct {
+	// Code CID of the migrated power actor.
+	OutCodeCID cid.Cid
+	// Prior version code CIDs of miner actors. Claims are removed only for deleted actors with these codes.
+	MinerCodeIDs map[cid.Cid]struct{}
+	// Receives a record of each claim removed. Optional.
+	AuditLog *AuditLog
+	// Receives each claim removed, for the migration's rollback record. Optional.
+	Rollback *RollbackRecorder
+}
+
+var _ DeferredActorMigration = PowerClaimsCleanup{}
+
+func (m PowerClaimsCleanup) MigrateState(ctx context.Context, store cbor.IpldStore, in ActorMigrationInput, phaseOne *PhaseOneResults) (*ActorMigrationResult, error) {
+	var miners []address.Address
+	for addr, code := range phaseOne.Deleted { // nolint:nomaprange // Sorted below.
+		if _, ok := m.MinerCodeIDs[code]; ok {
+			miners = append(miners, addr)
+		}
+	}
+	if len(miners) == 0 {
+		return &ActorMigrationResult{NewCodeCID: m.OutCodeCID, NewHead: in.Head}, nil
+	}
+	sort.Slice(miners, func(i, j int) bool {
+		return bytes.Compare(miners[i].Bytes(), miners[j].Bytes()) < 0
+	})
+
+	adtStore := adt.WrapStore(ctx, store)
+	var st power.State
+	if err := store.Get(ctx, in.Head, &st); err != nil {
+		return nil, xerrors.Errorf("failed to load power state: %w", err)
+	}
+
+	// Count the claims to be removed, and those above the consensus minimum, to cross-check the counts after.
+	expectedRemoved, expectedAboveMin := 0, 0
+	var records []AuditRecord
+	var removed []RemovedClaim
+	for _, miner := range miners {
+		claim, found, err := st.GetClaim(adtStore, miner)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load claim for %s: %w", miner, err)
+		}
+		if !found {
+			continue
+		}
+		expectedRemoved++
+		removed = append(removed, RemovedClaim{Address: miner, Claim: *claim})
+		records = append(records, AuditRecord{
+			Event:           AuditClaimRemoved,
+			Address:         miner.String(),
+			RawBytePower:    claim.RawBytePower.String(),
+			QualityAdjPower: claim.QualityAdjPower.String(),
+		})
+		minPower, err := builtin.ConsensusMinerMinPower(claim.WindowPoStProofType)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to get consensus minimum power for %s: %w", miner, err)
+		}
+		if minPower.GreaterThan(big.Zero()) && claim.RawBytePower.GreaterThanEqual(minPower) {
+			expectedAboveMin++
+		}
+	}
+
+	minerCount, aboveMinCount := st.MinerCount, st.MinerAboveMinPowerCount
+	removedCount, err := st.DeleteClaims(adtStore, miners)
+	if err != nil {
+		return nil, err
+	}
+	if removedCount != expectedRemoved {
+		return nil, xerrors.Errorf("removed %d claims, expected %d", removedCount, expectedRemoved)
+	}
+	if minerCount-st.MinerCount != int64(expectedRemoved) {
+		return nil, xerrors.Errorf("miner count decreased by %d, expected %d", minerCount-st.MinerCount, expectedRemoved)
+	}
+	if aboveMinCount-st.MinerAboveMinPowerCount != int64(expectedAboveMin) {
+		return nil, xerrors.Errorf("miner above min power count decreased by %d, expected %d",
+			aboveMinCount-st.MinerAboveMinPowerCount, expectedAboveMin)
+	}
+
+	newHead, err := store.Put(ctx, &st)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to write power state: %w", err)
+	}
+	for _, c := range removed {
+		m.Rollback.recordClaim(c.Address, &c.Claim)
+	}
+	for _, r := range records {
+		if err := m.AuditLog.Record(r); err != nil {
+			return nil, err
+		}
+	}
+	return &ActorMigrationResult{NewCodeCID: m.OutCodeCID, NewHead: newHead}, nil
+}
+
+func (m PowerClaimsCleanup) MigratedCodeCID() cid.Cid {
+	return m.OutCodeCID
+}
@@ -0,0 +1,106 @@
+package engine_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/power"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestPowerClaimsCleanup(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	doomedCode := tutil.MakeCID("doomed-miner", nil)
+	otherCode := tutil.MakeCID("doomed-other", nil)
+	powerAddr := tutil.NewIDAddr(t, 99)
+	bigMiner, smallMiner := tutil.NewIDAddr(t, 100), tutil.NewIDAddr(t, 101)
+	keptMiner, other := tutil.NewIDAddr(t, 103), tutil.NewIDAddr(t, 104)
+
+	// Build power state with claims for all but one of the miners (102), and for a non-miner actor.
+	st, err := power.ConstructState(store)
+	require.NoError(t, err)
+	claims, err := adt.AsMap(store, st.Claims, builtin.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	proof := abi.RegisteredPoStProof_StackedDrgWindow32GiBV1
+	minPower, err := builtin.ConsensusMinerMinPower(proof)
+	require.NoError(t, err)
+	addClaim := func(addr address.Address, raw abi.StoragePower) {
+		require.NoError(t, claims.Put(abi.AddrKey(addr), &power.Claim{WindowPoStProofType: proof, RawBytePower: raw, QualityAdjPower: raw}))
+		st.MinerCount++
+		st.TotalBytesCommitted = big.Add(st.TotalBytesCommitted, raw)
+		st.TotalQABytesCommitted = big.Add(st.TotalQABytesCommitted, raw)
+		if raw.GreaterThanEqual(minPower) {
+			st.MinerAboveMinPowerCount++
+			st.TotalRawBytePower = big.Add(st.TotalRawBytePower, raw)
+			st.TotalQualityAdjPower = big.Add(st.TotalQualityAdjPower, raw)
+		}
+	}
+	addClaim(bigMiner, big.Mul(minPower, big.NewInt(2)))
+	addClaim(smallMiner, big.NewInt(1<<30))
+	addClaim(keptMiner, minPower)
+	addClaim(other, big.NewInt(1<<30))
+	st.Claims, err = claims.Root()
+	require.NoError(t, err)
+	powerHead, err := store.Put(ctx, st)
+	require.NoError(t, err)
+
+	actorsIn := newTree(t, store, map[uint64]cid.Cid{100: doomedCode, 101: doomedCode, 102: doomedCode, 103: oldCode, 104: otherCode})
+	require.NoError(t, actorsIn.SetActor(powerAddr, &states.Actor{Code: oldDeferred, Head: powerHead, Balance: big.Zero()}))
+
+	var audit bytes.Buffer
+	m := &engine.Migration{
+		Migrations: map[cid.Cid]engine.ActorMigration{
+			oldCode:    engine.CodeMigrator{OutCodeCID: newCode},
+			doomedCode: deleteMigrator{},
+			otherCode:  deleteMigrator{},
+		},
+		DeferredMigrations: []engine.DeferredMigration{{
+			Code: oldDeferred,
+			Migration: engine.PowerClaimsCleanup{
+				OutCodeCID:   newDeferred,
+				MinerCodeIDs: map[cid.Cid]struct{}{oldCode: {}, doomedCode: {}},
+				AuditLog:     engine.NewAuditLog(&audit),
+			},
+		}},
+	}
+	actorsOut, err := states.NewTree(store)
+	require.NoError(t, err)
+	_, _, err = m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, engine.Config{MaxWorkers: 2}, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+	require.NoError(t, err)
+
+	powerActor, found, err := actorsOut.GetActor(powerAddr)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, newDeferred, powerActor.Code)
+	var stOut power.State
+	require.NoError(t, store.Get(ctx, powerActor.Head, &stOut))
+	assert.Equal(t, int64(2), stOut.MinerCount)
+	assert.Equal(t, int64(1), stOut.MinerAboveMinPowerCount)
+	assert.Equal(t, minPower, stOut.TotalRawBytePower)
+	assert.Equal(t, big.Add(minPower, big.NewInt(1<<30)), stOut.TotalBytesCommitted)
+
+	for addr, expected := range map[address.Address]bool{bigMiner: false, smallMiner: false, keptMiner: true, other: true} { // nolint:nomaprange
+		_, found, err := stOut.GetClaim(store, addr)
+		require.NoError(t, err)
+		assert.Equal(t, expected, found, "claim for %s", addr)
+	}
+
+	// Only the miners' claims removed are audited.
+	assert.Equal(t, 2, bytes.Count(audit.Bytes(), []byte(engine.AuditClaimRemoved)))
+	assert.Contains(t, audit.String(), bigMiner.String())
+	assert.Contains(t, audit.String(), smallMiner.String())
+}
@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/power"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+)
+
+// A record of the state removed or moved by a migration, sufficient to audit or reverse those changes.
+// Changes made directly to the output tree by post-migrations are not recorded, except for balance transfers
+// applied by a TransferAccumulator and claims removed by PowerClaimsCleanup given the same RollbackRecorder.
+type RollbackRecord struct {
+	// Actors removed from the state tree, with their records prior to migration, ordered by address bytes.
+	DeletedActors []DeletedActor
+	// Balances moved between actors by the migration, in the order applied.
+	BalanceTransfers []BalanceTransfer
+	// Power claims removed by the migration, with their values prior to migration, ordered by address bytes.
+	RemovedClaims []RemovedClaim
+}
+
+type DeletedActor struct {
+	Address address.Address
+	Actor   states.Actor
+}
+
+type BalanceTransfer struct {
+	// The actor from which the balance was moved, or nil if not known.
+	From *address.Address
+	// The actor credited, which is the fallback recipient if the intended recipient was ineligible.
+	To     address.Address
+	Amount abi.TokenAmount
+}
+
+type RemovedClaim struct {
+	Address address.Address
+	Claim   power.Claim
+}
+
+// Collects the rollback record during a migration, from the engine and from the migration's transfer accumulators
+// and claims cleanup. A nil recorder records nothing.
+// A recorder should be used for a single migration. Safe for concurrent use.
+type RollbackRecorder struct {
+	lk     sync.Mutex
+	record RollbackRecord
+}
+
+func NewRollbackRecorder() *RollbackRecorder {
+	return &RollbackRecorder{}
+}
+
+func (r *RollbackRecorder) recordDeleted(addr address.Address, prior *states.Actor) {
+	if r == nil {
+		return
+	}
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.record.DeletedActors = append(r.record.DeletedActors, DeletedActor{Address: addr, Actor: *prior})
+}
+
+func (r *RollbackRecorder) recordTransfer(t BalanceTransfer) {
+	if r == nil {
+		return
+	}
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.record.BalanceTransfers = append(r.record.BalanceTransfers, t)
+}
+
+func (r *RollbackRecorder) recordClaim(addr address.Address, prior *power.Claim) {
+	if r == nil {
+		return
+	}
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.record.RemovedClaims = append(r.record.RemovedClaims, RemovedClaim{Address: addr, Claim: *prior})
+}
+
+// Writes the record to the store, returning its CID, or cid.Undef if nothing is being recorded.
+func (r *RollbackRecorder) write(ctx context.Context, store cbor.IpldStore) (cid.Cid, error) {
+	if r == nil {
+		return cid.Undef, nil
+	}
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	deleted := r.record.DeletedActors
+	sort.Slice(deleted, func(i, j int) bool {
+		return bytes.Compare(deleted[i].Address.Bytes(), deleted[j].Address.Bytes()) < 0
+	})
+	claims := r.record.RemovedClaims
+	sort.Slice(claims, func(i, j int) bool {
+		return bytes.Compare(claims[i].Address.Bytes(), claims[j].Address.Bytes()) < 0
+	})
+	root, err := store.Put(ctx, &r.record)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to write rollback record: %w", err)
+	}
+	return root, nil
+}
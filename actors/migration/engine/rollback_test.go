@@ -0,0 +1,167 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/power"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+// Migrator which removes every actor, transferring its balance to a recipient.
+type transferMigrator struct {
+	transfers *engine.TransferAccumulator
+	to        address.Address
+}
+
+func (m transferMigrator) MigrateState(_ context.Context, _ cbor.IpldStore, in engine.ActorMigrationInput) (*engine.ActorMigrationResult, error) {
+	if err := m.transfers.AddFrom(in.Address, m.to, in.Balance); err != nil {
+		return nil, err
+	}
+	return &engine.ActorMigrationResult{Deleted: true}, nil
+}
+
+func (transferMigrator) MigratedCodeCID() cid.Cid {
+	return cid.Undef
+}
+
+func TestRollbackRecordRebuildsPriorState(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	doomedCode := tutil.MakeCID("doomed-miner", nil)
+	strandedCode := tutil.MakeCID("stranded-miner", nil)
+	powerAddr := tutil.NewIDAddr(t, 98)
+	doomed, stranded, kept := tutil.NewIDAddr(t, 100), tutil.NewIDAddr(t, 101), tutil.NewIDAddr(t, 102)
+	absent := tutil.NewIDAddr(t, 200)
+
+	// Build power state with a claim for each miner.
+	st, err := power.ConstructState(store)
+	require.NoError(t, err)
+	claims, err := adt.AsMap(store, st.Claims, builtin.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	for i, addr := range []address.Address{doomed, stranded, kept} {
+		raw := big.NewInt(int64(i+1) << 30)
+		require.NoError(t, claims.Put(abi.AddrKey(addr), &power.Claim{
+			WindowPoStProofType: abi.RegisteredPoStProof_StackedDrgWindow32GiBV1,
+			RawBytePower:        raw,
+			QualityAdjPower:     raw,
+		}))
+		st.MinerCount++
+		st.TotalBytesCommitted = big.Add(st.TotalBytesCommitted, raw)
+		st.TotalQABytesCommitted = big.Add(st.TotalQABytesCommitted, raw)
+	}
+	st.Claims, err = claims.Root()
+	require.NoError(t, err)
+	powerHead, err := store.Put(ctx, st)
+	require.NoError(t, err)
+
+	actorsIn := newTree(t, store, map[uint64]cid.Cid{102: oldCode})
+	require.NoError(t, actorsIn.SetActor(powerAddr, &states.Actor{Code: oldDeferred, Head: powerHead, Balance: big.Zero()}))
+	require.NoError(t, actorsIn.SetActor(builtin.BurntFundsActorAddr, &states.Actor{Code: oldCode, Head: oldCode, Balance: abi.NewTokenAmount(1)}))
+	require.NoError(t, actorsIn.SetActor(doomed, &states.Actor{Code: doomedCode, Head: doomedCode, Balance: abi.NewTokenAmount(10)}))
+	require.NoError(t, actorsIn.SetActor(stranded, &states.Actor{Code: strandedCode, Head: strandedCode, Balance: abi.NewTokenAmount(20)}))
+
+	// The doomed miner's balance goes to the kept miner, and the stranded miner's to the fallback.
+	// A further transfer with no known source is also credited to the kept miner.
+	rollback := engine.NewRollbackRecorder()
+	toKept := engine.NewTransferAccumulator(builtin.BurntFundsActorAddr, nil)
+	toKept.SetRollbackRecorder(rollback)
+	toAbsent := engine.NewTransferAccumulator(builtin.BurntFundsActorAddr, nil)
+	toAbsent.SetRollbackRecorder(rollback)
+	require.NoError(t, toKept.Add(kept, abi.NewTokenAmount(3)))
+	m := &engine.Migration{
+		Migrations: map[cid.Cid]engine.ActorMigration{
+			oldCode:      engine.CodeMigrator{OutCodeCID: newCode},
+			doomedCode:   transferMigrator{transfers: toKept, to: kept},
+			strandedCode: transferMigrator{transfers: toAbsent, to: absent},
+		},
+		DeferredMigrations: []engine.DeferredMigration{{
+			Code: oldDeferred,
+			Migration: engine.PowerClaimsCleanup{
+				OutCodeCID:   newDeferred,
+				MinerCodeIDs: map[cid.Cid]struct{}{oldCode: {}, doomedCode: {}, strandedCode: {}},
+				Rollback:     rollback,
+			},
+		}},
+		PostMigrations: []engine.PostMigration{
+			func(_ context.Context, _ cbor.IpldStore, _ engine.InputTree, actorsOut engine.OutputTree, _ abi.ChainEpoch) error {
+				if err := toKept.Apply(actorsOut); err != nil {
+					return err
+				}
+				return toAbsent.Apply(actorsOut)
+			},
+		},
+	}
+	actorsOut, err := states.NewTree(store)
+	require.NoError(t, err)
+	cfg := engine.Config{MaxWorkers: 2, Rollback: rollback}
+	_, stats, err := m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+	require.NoError(t, err)
+
+	var record engine.RollbackRecord
+	require.NoError(t, store.Get(ctx, stats.RollbackRecord, &record))
+	require.Len(t, record.BalanceTransfers, 3)
+	require.Len(t, record.RemovedClaims, 2)
+
+	// Rebuild the prior balances by reversing each transfer, crediting back the actors deleted.
+	balances := map[address.Address]abi.TokenAmount{}
+	require.NoError(t, actorsOut.ForEach(func(addr address.Address, actor *states.Actor) error {
+		balances[addr] = actor.Balance
+		return nil
+	}))
+	for _, transfer := range record.BalanceTransfers {
+		balances[transfer.To] = big.Sub(balances[transfer.To], transfer.Amount)
+		if transfer.From == nil {
+			// Not taken from an actor in the tree.
+			continue
+		}
+		prior, ok := balances[*transfer.From]
+		if !ok {
+			prior = big.Zero()
+		}
+		balances[*transfer.From] = big.Add(prior, transfer.Amount)
+	}
+	require.Len(t, record.DeletedActors, 2)
+	for _, deleted := range record.DeletedActors {
+		expected, found, err := actorsIn.GetActor(deleted.Address)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, *expected, deleted.Actor)
+		assert.Equal(t, deleted.Actor.Balance.String(), balances[deleted.Address].String())
+	}
+	require.NoError(t, actorsIn.ForEach(func(addr address.Address, actor *states.Actor) error {
+		assert.Equal(t, actor.Balance.String(), balances[addr].String(), "balance of %s", addr)
+		delete(balances, addr)
+		return nil
+	}))
+	assert.Empty(t, balances)
+
+	// Rebuild the prior claims by restoring each claim removed.
+	powerActor, found, err := actorsOut.GetActor(powerAddr)
+	require.NoError(t, err)
+	require.True(t, found)
+	var stOut power.State
+	require.NoError(t, store.Get(ctx, powerActor.Head, &stOut))
+	claimsOut, err := adt.AsMap(store, stOut.Claims, builtin.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	for _, removed := range record.RemovedClaims {
+		claim := removed.Claim
+		require.NoError(t, claimsOut.Put(abi.AddrKey(removed.Address), &claim))
+	}
+	rebuiltClaims, err := claimsOut.Root()
+	require.NoError(t, err)
+	assert.Equal(t, st.Claims, rebuiltClaims)
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"github.com/ipfs/go-cid"
+)
+
+// A queue of jobs and the number of workers consuming it.
+type workerPool struct {
+	queue   chan *migrationJob
+	workers uint
+}
+
+// Routes jobs to worker pools: one for each code CID with dedicated workers (see Config.DedicatedWorkers),
+// and a shared pool for all other actors.
+type jobQueues struct {
+	shared    workerPool
+	dedicated map[cid.Cid]workerPool
+}
+
+func newJobQueues(cfg Config) *jobQueues {
+	q := &jobQueues{
+		shared:    workerPool{queue: make(chan *migrationJob, cfg.JobQueueSize), workers: cfg.MaxWorkers},
+		dedicated: map[cid.Cid]workerPool{},
+	}
+	for code, workers := range cfg.DedicatedWorkers { // nolint:nomaprange // Pools are independent.
+		if workers > 0 {
+			q.dedicated[code] = workerPool{queue: make(chan *migrationJob, cfg.JobQueueSize), workers: workers}
+		}
+	}
+	return q
+}
+
+// Returns the queue for jobs migrating actors with a code CID.
+func (q *jobQueues) forCode(code cid.Cid) chan<- *migrationJob {
+	if pool, ok := q.dedicated[code]; ok {
+		return pool.queue
+	}
+	return q.shared.queue
+}
+
+// Returns all pools, the shared pool first.
+func (q *jobQueues) pools() []workerPool {
+	pools := []workerPool{q.shared}
+	for _, pool := range q.dedicated { // nolint:nomaprange // Pools are independent.
+		pools = append(pools, pool)
+	}
+	return pools
+}
+
+// Closes all queues, after the last job has been sent.
+func (q *jobQueues) close() {
+	for _, pool := range q.pools() {
+		close(pool.queue)
+	}
+}
+
+// Returns a predicate matching the codes in a list, or nil for an empty list.
+func codeSet(codes []cid.Cid) func(code cid.Cid) bool {
+	if len(codes) == 0 {
+		return nil
+	}
+	set := make(map[cid.Cid]struct{}, len(codes))
+	for _, c := range codes {
+		set[c] = struct{}{}
+	}
+	return func(code cid.Cid) bool {
+		_, ok := set[code]
+		return ok
+	}
+}
@@ -0,0 +1,236 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"math/big"
+
+	"github.com/filecoin-project/go-address"
+	hamt "github.com/filecoin-project/go-hamt-ipld/v3"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// Number of slots in the root node of a state tree's HAMT.
+const rootSlots = 1 << builtin.DefaultHamtBitwidth
+
+// Inserts results into a set of state trees in parallel, each holding the actors whose keys hash to a disjoint
+// set of slots in the root node of the state tree's HAMT.
+//
+// The subtree under a root slot depends only on the keys hashed to that slot, so when the output tree is a
+// *states.Tree the shard trees are flushed in parallel and their root pointers assembled directly into the
+// output tree's root. Each shard tree then starts from the output tree's pointers at its slots, so that results
+// and deletions apply to the actors already in it.
+// Any other output tree has the actors in the shard trees inserted into it one at a time.
+type shardedWriter struct {
+	// The output tree whose root is replaced by the merge, or nil to merge by insertion.
+	out     *states.Tree
+	trees   []*states.Tree
+	roots   []cid.Cid
+	written []int
+	chans   []chan shardOp
+	grp     *errgroup.Group
+}
+
+// An actor to set in a shard tree, or remove if actor is nil.
+type shardOp struct {
+	addr  address.Address
+	actor *states.Actor
+}
+
+func startShardedWriter(ctx context.Context, store cbor.IpldStore, out OutputTree, n uint) (*shardedWriter, error) {
+	if n > rootSlots {
+		n = rootSlots
+	}
+	var seeds []*hamt.Node
+	outTree, direct := out.(*states.Tree)
+	if direct {
+		root, err := outTree.Flush()
+		if err != nil {
+			return nil, xerrors.Errorf("failed to flush output state tree: %w", err)
+		}
+		var rootNode hamt.Node
+		if err := store.Get(ctx, root, &rootNode); err != nil {
+			return nil, xerrors.Errorf("failed to load output state tree root: %w", err)
+		}
+		seeds = splitRootNode(&rootNode, n)
+	}
+
+	grp, ctx := errgroup.WithContext(ctx)
+	w := &shardedWriter{
+		out:     outTree,
+		trees:   make([]*states.Tree, n),
+		roots:   make([]cid.Cid, n),
+		written: make([]int, n),
+		chans:   make([]chan shardOp, n),
+		grp:     grp,
+	}
+	for i := range w.trees {
+		i := i
+		tree, err := newShardTree(ctx, store, seeds, i)
+		if err != nil {
+			return nil, err
+		}
+		ch := make(chan shardOp, 64)
+		w.trees[i], w.chans[i] = tree, ch
+		grp.Go(func() error {
+			for {
+				select {
+				case op, ok := <-ch:
+					if !ok {
+						if !direct {
+							return nil
+						}
+						// Flush the shard while others are still being written.
+						root, err := tree.Flush()
+						if err != nil {
+							return xerrors.Errorf("failed to flush shard tree: %w", err)
+						}
+						w.roots[i] = root
+						return nil
+					}
+					if op.actor == nil {
+						if err := tree.DeleteActor(op.addr); err != nil {
+							return err
+						}
+						continue
+					}
+					if err := tree.SetActor(op.addr, op.actor); err != nil {
+						return err
+					}
+					w.written[i]++
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		})
+	}
+	return w, nil
+}
+
+// Creates the tree for the i'th shard, starting from its seed root node if seeds are given.
+func newShardTree(ctx context.Context, store cbor.IpldStore, seeds []*hamt.Node, i int) (*states.Tree, error) {
+	adtStore := adt.WrapStore(ctx, store)
+	if seeds == nil {
+		tree, err := states.NewTree(adtStore)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to create shard tree: %w", err)
+		}
+		return tree, nil
+	}
+	seed, err := store.Put(ctx, seeds[i])
+	if err != nil {
+		return nil, xerrors.Errorf("failed to store shard tree root: %w", err)
+	}
+	tree, err := states.LoadTree(adtStore, seed)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load shard tree: %w", err)
+	}
+	return tree, nil
+}
+
+func (w *shardedWriter) write(ctx context.Context, result *migrationJobResult) error {
+	return w.send(ctx, shardOp{addr: result.Address, actor: &result.Actor})
+}
+
+// Removes an actor from the output tree, through its shard if the shards are merged into the output tree's root.
+func (w *shardedWriter) delete(ctx context.Context, addr address.Address, deleter ActorDeleter) error {
+	if w.out == nil {
+		return deleter.DeleteActor(addr)
+	}
+	return w.send(ctx, shardOp{addr: addr})
+}
+
+func (w *shardedWriter) send(ctx context.Context, op shardOp) error {
+	select {
+	case w.chans[rootSlot(op.addr)%len(w.chans)] <- op:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Waits for the shard writers to finish, then merges the shard trees into the output tree.
+// When merging by insertion, passes every actor in each shard tree, in order, to set.
+// Returns the number of actors written to the output tree without passing through set.
+func (w *shardedWriter) merge(ctx context.Context, set func(addr address.Address, actor *states.Actor) error) (int, error) {
+	for _, ch := range w.chans {
+		close(ch)
+	}
+	if err := w.grp.Wait(); err != nil {
+		return 0, err
+	}
+	if w.out == nil {
+		for _, tree := range w.trees {
+			if err := tree.ForEach(set); err != nil {
+				return 0, err
+			}
+		}
+		return 0, nil
+	}
+
+	var slots [rootSlots]*hamt.Pointer
+	written := 0
+	for i, root := range w.roots {
+		var node hamt.Node
+		if err := w.out.Store.Get(ctx, root, &node); err != nil {
+			return 0, xerrors.Errorf("failed to load shard tree root: %w", err)
+		}
+		k := 0
+		for slot := 0; slot < rootSlots; slot++ {
+			if node.Bitfield.Bit(slot) == 1 {
+				slots[slot] = node.Pointers[k]
+				k++
+			}
+		}
+		written += w.written[i]
+	}
+	merged := hamt.Node{Bitfield: big.NewInt(0), Pointers: []*hamt.Pointer{}}
+	for slot, p := range slots {
+		if p != nil {
+			merged.Bitfield.SetBit(merged.Bitfield, slot, 1)
+			merged.Pointers = append(merged.Pointers, p)
+		}
+	}
+	root, err := w.out.Store.Put(ctx, &merged)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to store merged state tree root: %w", err)
+	}
+	tree, err := states.LoadTree(w.out.Store, root)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to load merged state tree: %w", err)
+	}
+	*w.out = *tree
+	return written, nil
+}
+
+// Splits a root node into n root nodes, the i'th holding the pointers at the slots assigned to the i'th shard.
+func splitRootNode(node *hamt.Node, n uint) []*hamt.Node {
+	split := make([]*hamt.Node, n)
+	for i := range split {
+		split[i] = &hamt.Node{Bitfield: big.NewInt(0), Pointers: []*hamt.Pointer{}}
+	}
+	k := 0
+	for slot := 0; slot < rootSlots; slot++ {
+		if node.Bitfield.Bit(slot) == 1 {
+			shard := split[slot%int(n)]
+			shard.Bitfield.SetBit(shard.Bitfield, slot, 1)
+			shard.Pointers = append(shard.Pointers, node.Pointers[k])
+			k++
+		}
+	}
+	return split
+}
+
+// The slot in the root node of a state tree's HAMT under which an address is stored.
+// This is the first bits of the SHA-256 hash of the address, as used for keys by adt.Map.
+func rootSlot(addr address.Address) int {
+	h := sha256.Sum256(addr.Bytes())
+	return int(h[0] >> (8 - builtin.DefaultHamtBitwidth))
+}
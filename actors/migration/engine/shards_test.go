@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	hamt "github.com/filecoin-project/go-hamt-ipld/v3"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+)
+
+func TestRootSlotMatchesStateTree(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewBlockStoreInMemory())
+	for id := uint64(100); id < 200; id++ {
+		addr, err := address.NewIDAddress(id)
+		require.NoError(t, err)
+		tree, err := states.NewTree(store)
+		require.NoError(t, err)
+		require.NoError(t, tree.SetActor(addr, &states.Actor{Code: builtin.AccountActorCodeID, Head: builtin.AccountActorCodeID, Balance: big.Zero()}))
+		root, err := tree.Flush()
+		require.NoError(t, err)
+
+		var node hamt.Node
+		require.NoError(t, store.Get(ctx, root, &node))
+		require.Len(t, node.Pointers, 1)
+		assert.Equal(t, uint(1), node.Bitfield.Bit(rootSlot(addr)), "address %v", addr)
+	}
+}
+
+// Compares inserting results into the output tree from a single goroutine with sharded writers
+// merged by insertion and by assembling the shard trees' roots.
+func BenchmarkShardedWriterMerge(b *testing.B) {
+	const actorCount = 100_000
+	const writers = 8
+	ctx := context.Background()
+	results := make([]*migrationJobResult, actorCount)
+	for i := range results {
+		addr, err := address.NewIDAddress(uint64(100 + i))
+		require.NoError(b, err)
+		results[i] = &migrationJobResult{
+			Address: addr,
+			Actor:   states.Actor{Code: builtin.AccountActorCodeID, Head: builtin.AccountActorCodeID, Balance: big.Zero()},
+		}
+	}
+
+	for _, bm := range []struct {
+		name  string
+		write func(store adt.Store, out *states.Tree) error
+	}{
+		{"single", func(_ adt.Store, out *states.Tree) error {
+			for _, result := range results {
+				if err := out.SetActor(result.Address, &result.Actor); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"sharded-insert", func(store adt.Store, out *states.Tree) error {
+			w, err := startShardedWriter(ctx, store, struct{ OutputTree }{out}, writers)
+			if err != nil {
+				return err
+			}
+			for _, result := range results {
+				if err := w.write(ctx, result); err != nil {
+					return err
+				}
+			}
+			_, err = w.merge(ctx, out.SetActor)
+			return err
+		}},
+		{"sharded-merge", func(store adt.Store, out *states.Tree) error {
+			w, err := startShardedWriter(ctx, store, out, writers)
+			if err != nil {
+				return err
+			}
+			for _, result := range results {
+				if err := w.write(ctx, result); err != nil {
+					return err
+				}
+			}
+			_, err = w.merge(ctx, out.SetActor)
+			return err
+		}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+				out, err := states.NewTree(store)
+				require.NoError(b, err)
+				require.NoError(b, bm.write(store, out))
+				_, err = out.Flush()
+				require.NoError(b, err)
+			}
+		})
+	}
+}
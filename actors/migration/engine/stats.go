@@ -0,0 +1,233 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// MigrationObserver receives notifications of migration progress, e.g. for export as metrics.
+// Methods may be called concurrently from multiple goroutines, and should return quickly.
+type MigrationObserver interface {
+	// Called when a migration job is created for an actor with a prior version code CID.
+	OnJobCreated(addr address.Address, code cid.Cid)
+	// Called when a worker has finished migrating an actor, with the time taken.
+	OnJobDone(addr address.Address, code cid.Cid, elapsed time.Duration)
+	// Called when an actor's migration removes it from the state tree.
+	OnActorDeleted(addr address.Address, code cid.Cid)
+	// Called when a migration adds a new actor to the state tree, with its new code CID.
+	OnActorCreated(addr address.Address, code cid.Cid)
+	// Called when the output state tree has been flushed to the store, with the time taken for the flush.
+	// This includes intermediate flushes (see Config.FlushPeriod), whose roots are discarded.
+	OnFlush(root cid.Cid, elapsed time.Duration)
+}
+
+// MigrationStats summarizes a completed migration.
+type MigrationStats struct {
+	// Statistics for each prior version actor code CID.
+	ActorTypes map[cid.Cid]*ActorTypeStats
+	// Total number of migration jobs run.
+	JobCount uint64
+	// Total number of actors removed from the state tree.
+	DeletedCount uint64
+	// Total number of actors added to the state tree.
+	CreatedCount uint64
+	// Wall time for the whole migration, including the final flush.
+	Elapsed time.Duration
+	// Wall time for the final flush of the output state tree.
+	FlushDuration time.Duration
+	// Number and total encoded size of blocks written, if the store was wrapped with NewMeteredStore.
+	WriteCount uint64
+	WriteBytes uint64
+	// Number of reads served from and missing the read cache, if Config.ReadCacheBytes is set.
+	ReadCacheHits   uint64
+	ReadCacheMisses uint64
+	// Number of migration cache lookups served from the cache and not, and the number and total size of
+	// entries written to it (see MeteredCache).
+	CacheHits       uint64
+	CacheMisses     uint64
+	CacheWrites     uint64
+	CacheWriteBytes uint64
+	// CID of the RollbackRecord written to the store, if Config.Rollback is set.
+	RollbackRecord cid.Cid
+}
+
+// Fraction of migration cache lookups served from the cache, or zero if there were none.
+func (s *MigrationStats) CacheWarmRate() float64 {
+	return warmRate(s.CacheHits, s.CacheMisses)
+}
+
+// Statistics for migrations of actors with a single code CID.
+type ActorTypeStats struct {
+	// Number of actors migrated.
+	Count uint64
+	// Number of actors removed from the state tree.
+	Deleted uint64
+	// Cumulative time spent by workers migrating these actors.
+	Duration time.Duration
+	// Number and total encoded size of blocks read and written by these actors' migrations.
+	// Measured only if Config.ProfileActors is set.
+	Reads      uint64
+	ReadBytes  uint64
+	Writes     uint64
+	WriteBytes uint64
+}
+
+// A no-op observer, used when none is configured.
+type nilObserver struct{}
+
+func (nilObserver) OnJobCreated(address.Address, cid.Cid)             {}
+func (nilObserver) OnJobDone(address.Address, cid.Cid, time.Duration) {}
+func (nilObserver) OnActorDeleted(address.Address, cid.Cid)           {}
+func (nilObserver) OnActorCreated(address.Address, cid.Cid)           {}
+func (nilObserver) OnFlush(cid.Cid, time.Duration)                    {}
+
+func observerOrDefault(o MigrationObserver) MigrationObserver {
+	if o == nil {
+		return nilObserver{}
+	}
+	return o
+}
+
+// Accumulates statistics concurrently during a migration.
+type statsCollector struct {
+	mu    sync.Mutex
+	stats MigrationStats
+}
+
+func newStatsCollector() *statsCollector {
+	return &statsCollector{stats: MigrationStats{ActorTypes: map[cid.Cid]*ActorTypeStats{}}}
+}
+
+func (c *statsCollector) typeStats(code cid.Cid) *ActorTypeStats {
+	ts, ok := c.stats.ActorTypes[code]
+	if !ok {
+		ts = &ActorTypeStats{}
+		c.stats.ActorTypes[code] = ts
+	}
+	return ts
+}
+
+func (c *statsCollector) recordJob(code cid.Cid, elapsed time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ts := c.typeStats(code)
+	ts.Count++
+	ts.Duration += elapsed
+	c.stats.JobCount++
+}
+
+func (c *statsCollector) recordIO(code cid.Cid, store *MeteredStore) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ts := c.typeStats(code)
+	ts.Reads += store.ReadCount()
+	ts.ReadBytes += store.ReadSize()
+	ts.Writes += store.WriteCount()
+	ts.WriteBytes += store.WriteSize()
+}
+
+func (c *statsCollector) recordCreated() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.CreatedCount++
+}
+
+func (c *statsCollector) recordDeleted(code cid.Cid) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.typeStats(code).Deleted++
+	c.stats.DeletedCount++
+}
+
+// Returns the final statistics, reading write metrics from the store if available.
+func (c *statsCollector) finish(store cbor.IpldStore, elapsed, flushDuration time.Duration) *MigrationStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Elapsed = elapsed
+	stats.FlushDuration = flushDuration
+	if ms, ok := store.(*MeteredStore); ok {
+		stats.WriteCount = ms.WriteCount()
+		stats.WriteBytes = ms.WriteSize()
+	}
+	return &stats
+}
+
+// An IpldStore wrapper which counts the number and encoded size of objects read and written.
+// The size of each object is measured by encoding it a second time, so metering has some cost.
+type MeteredStore struct {
+	cbor.IpldStore
+	reads      uint64
+	readBytes  uint64
+	writes     uint64
+	writeBytes uint64
+}
+
+// Wraps a store to count reads and writes.
+// The returned store is safe for concurrent use if the underlying store is.
+func NewMeteredStore(store cbor.IpldStore) *MeteredStore {
+	return &MeteredStore{IpldStore: store}
+}
+
+func (s *MeteredStore) Get(ctx context.Context, c cid.Cid, out interface{}) error {
+	if err := s.IpldStore.Get(ctx, c, out); err != nil {
+		return err
+	}
+	atomic.AddUint64(&s.reads, 1)
+	atomic.AddUint64(&s.readBytes, encodedSize(out))
+	return nil
+}
+
+func (s *MeteredStore) Put(ctx context.Context, v interface{}) (cid.Cid, error) {
+	c, err := s.IpldStore.Put(ctx, v)
+	if err != nil {
+		return c, err
+	}
+	atomic.AddUint64(&s.writes, 1)
+	atomic.AddUint64(&s.writeBytes, encodedSize(v))
+	return c, nil
+}
+
+func (s *MeteredStore) ReadCount() uint64 {
+	return atomic.LoadUint64(&s.reads)
+}
+
+func (s *MeteredStore) ReadSize() uint64 {
+	return atomic.LoadUint64(&s.readBytes)
+}
+
+func (s *MeteredStore) WriteCount() uint64 {
+	return atomic.LoadUint64(&s.writes)
+}
+
+func (s *MeteredStore) WriteSize() uint64 {
+	return atomic.LoadUint64(&s.writeBytes)
+}
+
+// Returns the encoded size of an object, or zero if it is not a CBORMarshaler.
+func encodedSize(v interface{}) uint64 {
+	m, ok := v.(cbg.CBORMarshaler)
+	if !ok {
+		return 0
+	}
+	var w countingWriter
+	if err := m.MarshalCBOR(&w); err != nil {
+		return 0
+	}
+	return uint64(w)
+}
+
+// An io.Writer which discards data, counting its length.
+type countingWriter uint64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+)
+
+// The outcome of migrating a single actor.
+type MigratedActor struct {
+	Address address.Address
+	// The actor's record in the input tree.
+	Before states.Actor
+	// The actor's migrated record. Undefined if the actor was deleted.
+	After states.Actor
+	// Whether the migration removed the actor from the state tree.
+	Deleted bool
+	// New actors the migration added to the state tree.
+	Created []CreatedActor
+}
+
+// Runs the registered migrations for only the given actors, returning their migrated records in the
+// same order. No output tree is built; new actor state is written to the store.
+// Deferred actors cannot be migrated individually, since their migration may depend on all other actors.
+// The cache may be nil, in which case nothing is cached.
+func (m *Migration) MigrateActors(ctx context.Context, store cbor.IpldStore, actorsIn InputTree, addrs []address.Address,
+	priorEpoch abi.ChainEpoch, cache MigrationCache) ([]MigratedActor, error) {
+	if cache == nil {
+		cache = NewMemMigrationCache()
+	}
+	results := make([]MigratedActor, 0, len(addrs))
+	for _, addr := range addrs {
+		actorIn, found, err := actorsIn.GetActor(addr)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to load actor %s: %w", addr, err)
+		}
+		if !found {
+			return nil, xerrors.Errorf("actor %s not found", addr)
+		}
+		if m.isDeferred(actorIn.Code) {
+			return nil, xerrors.Errorf("actor %s with code %s is deferred and cannot be migrated individually", addr, actorIn.Code)
+		}
+		migration, ok := m.Migrations[actorIn.Code]
+		if !ok {
+			return nil, xerrors.Errorf("actor with code %s has no registered migration function", actorIn.Code)
+		}
+		job := &migrationJob{
+			Address:        addr,
+			Actor:          *actorIn,
+			actorMigration: migration,
+			cache:          cache,
+			actorName:      m.actorName(actorIn.Code),
+			cacheKey:       VersionedActorHeadKey(m.CacheVersion, addr, actorIn.Head),
+		}
+		result, err := job.run(ctx, store, priorEpoch)
+		if err != nil {
+			return nil, err
+		}
+		migrated := MigratedActor{Address: addr, Before: *actorIn, Deleted: result.deleted, Created: result.created}
+		if !result.deleted {
+			migrated.After = result.Actor
+		}
+		results = append(results, migrated)
+	}
+	return results, nil
+}
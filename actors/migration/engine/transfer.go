@@ -0,0 +1,236 @@
+package engine
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+)
+
+// Determines where balances with no eligible recipient are credited.
+type FallbackPolicy int
+
+const (
+	// Credit the burnt funds actor.
+	FallbackBurn FallbackPolicy = iota
+	// Credit a reserve actor (Config.FallbackAddress), so the funds may be recovered.
+	FallbackReserve
+)
+
+// Returns the address to credit with balances with no eligible recipient, according to the fallback policy.
+// Migrations should pass this to NewTransferAccumulator, rather than hard-coding an address, so that networks
+// with different reserved addresses may reuse them.
+func (cfg Config) FallbackRecipient() (address.Address, error) {
+	switch cfg.FallbackPolicy {
+	case FallbackBurn:
+		if cfg.FallbackAddress != address.Undef {
+			return address.Undef, xerrors.Errorf("fallback address %s set with burn policy", cfg.FallbackAddress)
+		}
+		return builtin.BurntFundsActorAddr, nil
+	case FallbackReserve:
+		if cfg.FallbackAddress.Protocol() != address.ID {
+			return address.Undef, xerrors.Errorf("reserve fallback address %s is not an ID address", addressString(cfg.FallbackAddress))
+		}
+		return cfg.FallbackAddress, nil
+	}
+	return address.Undef, xerrors.Errorf("unknown fallback policy %d", cfg.FallbackPolicy)
+}
+
+// Checks the fallback configuration, and that a reserve actor is present in the input tree.
+// The burnt funds actor is not required, since migrations which never transfer funds don't need it.
+func validateFallback(cfg Config, actorsIn InputTree) error {
+	addr, err := cfg.FallbackRecipient()
+	if err != nil {
+		return err
+	}
+	if cfg.FallbackPolicy != FallbackReserve {
+		return nil
+	}
+	if _, found, err := actorsIn.GetActor(addr); err != nil {
+		return xerrors.Errorf("failed to load reserve fallback actor %s: %w", addr, err)
+	} else if !found {
+		return xerrors.Errorf("reserve fallback actor %s not found", addr)
+	}
+	return nil
+}
+
+// Decides whether an actor may receive a balance transfer.
+type RecipientPolicy func(addr address.Address, actor *states.Actor) bool
+
+// Accumulates balance transfers to actors, such as the balances of actors removed by a migration, to be credited
+// to the output state tree once all actors have been migrated.
+// Transfers to a recipient which is absent from the tree, or rejected by the recipient policy, are credited to
+// a fallback address instead.
+// Add is safe for concurrent use, e.g. by migration workers.
+type TransferAccumulator struct {
+	fallback address.Address
+	policy   RecipientPolicy
+
+	audit    *AuditLog
+	rollback *RollbackRecorder
+
+	lk        sync.Mutex
+	transfers []pendingTransfer
+	total     abi.TokenAmount
+	applied   bool
+}
+
+type pendingTransfer struct {
+	from   address.Address // Undefined if not known.
+	to     address.Address
+	amount abi.TokenAmount
+}
+
+// Creates an accumulator redirecting transfers to the fallback address.
+// The policy is optional; if nil, any recipient present in the tree is accepted.
+func NewTransferAccumulator(fallback address.Address, policy RecipientPolicy) *TransferAccumulator {
+	return &TransferAccumulator{
+		fallback: fallback,
+		policy:   policy,
+		total:    big.Zero(),
+	}
+}
+
+// Sets a log to receive a record of each transfer when it is applied.
+func (a *TransferAccumulator) SetAuditLog(log *AuditLog) {
+	a.audit = log
+}
+
+// Sets a recorder to receive a record of each transfer when it is applied, for the migration's rollback record.
+func (a *TransferAccumulator) SetRollbackRecorder(r *RollbackRecorder) {
+	a.rollback = r
+}
+
+// Records a transfer of amount to an address.
+func (a *TransferAccumulator) Add(addr address.Address, amount abi.TokenAmount) error {
+	return a.AddFrom(address.Undef, addr, amount)
+}
+
+// Records a transfer of amount to an address from an actor (e.g. one removed by the migration), which is
+// noted in the audit log.
+func (a *TransferAccumulator) AddFrom(from, addr address.Address, amount abi.TokenAmount) error {
+	if amount.LessThan(big.Zero()) {
+		return xerrors.Errorf("negative transfer of %v to %s", amount, addr)
+	}
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	if a.applied {
+		return xerrors.Errorf("transfer to %s after transfers were applied", addr)
+	}
+	a.transfers = append(a.transfers, pendingTransfer{from: from, to: addr, amount: amount})
+	a.total = big.Add(a.total, amount)
+	return nil
+}
+
+// The sum of all transfers added.
+func (a *TransferAccumulator) Total() abi.TokenAmount {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	return a.total
+}
+
+// Credits the accumulated transfers to actors in a state tree, in order of recipient address bytes.
+// Fails if the fallback actor is needed but absent, or if the balances credited don't sum to the total added.
+// Transfers may be applied only once.
+func (a *TransferAccumulator) Apply(tree OutputTree) error {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+	if a.applied {
+		return xerrors.Errorf("transfers already applied")
+	}
+	a.applied = true
+
+	// Redirect transfers to ineligible recipients.
+	eligible := map[address.Address]bool{}
+	credits := map[address.Address]abi.TokenAmount{}
+	records := make([]AuditRecord, 0, len(a.transfers))
+	applied := make([]BalanceTransfer, 0, len(a.transfers))
+	for _, t := range a.transfers {
+		ok, checked := eligible[t.to]
+		if !checked {
+			actor, found, err := tree.GetActor(t.to)
+			if err != nil {
+				return xerrors.Errorf("failed to load transfer recipient %s: %w", t.to, err)
+			}
+			ok = found && (a.policy == nil || a.policy(t.to, actor))
+			eligible[t.to] = ok
+		}
+		creditTo := t.to
+		if !ok {
+			creditTo = a.fallback
+		}
+		prior, found := credits[creditTo]
+		if !found {
+			prior = big.Zero()
+		}
+		credits[creditTo] = big.Add(prior, t.amount)
+		records = append(records, AuditRecord{
+			Event:      AuditBalanceTransferred,
+			Address:    addressString(t.from),
+			Amount:     t.amount.String(),
+			Recipient:  t.to.String(),
+			CreditedTo: creditTo.String(),
+		})
+		transfer := BalanceTransfer{To: creditTo, Amount: t.amount}
+		if t.from != address.Undef {
+			from := t.from
+			transfer.From = &from
+		}
+		applied = append(applied, transfer)
+	}
+
+	recipients := make([]address.Address, 0, len(credits))
+	for addr := range credits { // nolint:nomaprange // Sorted below.
+		recipients = append(recipients, addr)
+	}
+	sort.Slice(recipients, func(i, j int) bool {
+		return bytes.Compare(recipients[i].Bytes(), recipients[j].Bytes()) < 0
+	})
+
+	balanceBefore, balanceAfter := big.Zero(), big.Zero()
+	for _, addr := range recipients {
+		actor, found, err := tree.GetActor(addr)
+		if err != nil {
+			return xerrors.Errorf("failed to load transfer recipient %s: %w", addr, err)
+		}
+		if !found {
+			return xerrors.Errorf("fallback transfer recipient %s not found", addr)
+		}
+		balanceBefore = big.Add(balanceBefore, actor.Balance)
+		actor.Balance = big.Add(actor.Balance, credits[addr])
+		if actor.Balance.LessThan(big.Zero()) {
+			return xerrors.Errorf("negative balance %v for transfer recipient %s", actor.Balance, addr)
+		}
+		balanceAfter = big.Add(balanceAfter, actor.Balance)
+		if err := tree.SetActor(addr, actor); err != nil {
+			return xerrors.Errorf("failed to credit transfer recipient %s: %w", addr, err)
+		}
+	}
+	if credited := big.Sub(balanceAfter, balanceBefore); !credited.Equals(a.total) {
+		return xerrors.Errorf("transfers credited %v, expected %v", credited, a.total)
+	}
+	for _, t := range applied {
+		a.rollback.recordTransfer(t)
+	}
+	for _, r := range records {
+		if err := a.audit.Record(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Formats an address, or the empty string if it is undefined.
+func addressString(addr address.Address) string {
+	if addr == address.Undef {
+		return ""
+	}
+	return addr.String()
+}
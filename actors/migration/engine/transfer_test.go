@@ -0,0 +1,104 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestTransferAccumulator(t *testing.T) {
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewBlockStoreInMemory())
+	fallback := tutil.NewIDAddr(t, 99)
+	recipient := tutil.NewIDAddr(t, 100)
+	rejected := tutil.NewIDAddr(t, 101)
+	missing := tutil.NewIDAddr(t, 102)
+	policy := func(_ address.Address, actor *states.Actor) bool {
+		return actor.Code.Equals(oldCode)
+	}
+	balance := func(tree *states.Tree, addr address.Address) abi.TokenAmount {
+		actor, found, err := tree.GetActor(addr)
+		require.NoError(t, err)
+		require.True(t, found)
+		return actor.Balance
+	}
+
+	t.Run("credits recipients and redirects to fallback", func(t *testing.T) {
+		tree := newTree(t, store, map[uint64]cid.Cid{99: oldCode, 100: oldCode, 101: newCode})
+		acc := engine.NewTransferAccumulator(fallback, policy)
+		require.NoError(t, acc.Add(recipient, abi.NewTokenAmount(5)))
+		require.NoError(t, acc.Add(recipient, abi.NewTokenAmount(2)))
+		require.NoError(t, acc.Add(rejected, abi.NewTokenAmount(3)))
+		require.NoError(t, acc.Add(missing, abi.NewTokenAmount(4)))
+		assert.Equal(t, abi.NewTokenAmount(14), acc.Total())
+
+		require.NoError(t, acc.Apply(tree))
+		assert.Equal(t, abi.NewTokenAmount(7), balance(tree, recipient))
+		assert.Equal(t, abi.NewTokenAmount(0), balance(tree, rejected))
+		assert.Equal(t, abi.NewTokenAmount(7), balance(tree, fallback))
+
+		// Transfers are applied only once.
+		assert.Error(t, acc.Apply(tree))
+		assert.Error(t, acc.Add(recipient, abi.NewTokenAmount(1)))
+	})
+
+	t.Run("negative transfer", func(t *testing.T) {
+		acc := engine.NewTransferAccumulator(fallback, nil)
+		assert.Error(t, acc.Add(recipient, abi.NewTokenAmount(-1)))
+	})
+
+	t.Run("missing fallback", func(t *testing.T) {
+		tree := newTree(t, store, map[uint64]cid.Cid{100: oldCode})
+		acc := engine.NewTransferAccumulator(fallback, nil)
+		require.NoError(t, acc.Add(missing, abi.NewTokenAmount(1)))
+		assert.Error(t, acc.Apply(tree))
+	})
+}
+
+func TestFallbackRecipient(t *testing.T) {
+	reserve := tutil.NewIDAddr(t, 90)
+
+	addr, err := engine.Config{}.FallbackRecipient()
+	require.NoError(t, err)
+	assert.Equal(t, builtin.BurntFundsActorAddr, addr)
+
+	addr, err = engine.Config{FallbackPolicy: engine.FallbackReserve, FallbackAddress: reserve}.FallbackRecipient()
+	require.NoError(t, err)
+	assert.Equal(t, reserve, addr)
+
+	for _, cfg := range []engine.Config{
+		{FallbackAddress: reserve},
+		{FallbackPolicy: engine.FallbackReserve},
+		{FallbackPolicy: engine.FallbackReserve, FallbackAddress: tutil.NewActorAddr(t, "reserve")},
+		{FallbackPolicy: 7},
+	} {
+		_, err := cfg.FallbackRecipient()
+		assert.Error(t, err, "%+v", cfg)
+	}
+
+	// The reserve actor must be present in the input tree.
+	ctx := context.Background()
+	store := adt.WrapBlockStore(ctx, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	m := &engine.Migration{Migrations: map[cid.Cid]engine.ActorMigration{oldCode: engine.CodeMigrator{OutCodeCID: newCode}}}
+	migrate := func(actorsIn *states.Tree) error {
+		actorsOut, err := states.NewTree(store)
+		require.NoError(t, err)
+		cfg := engine.Config{MaxWorkers: 1, FallbackPolicy: engine.FallbackReserve, FallbackAddress: reserve}
+		_, _, err = m.MigrateStateTree(ctx, store, actorsIn, actorsOut, 0, cfg, engine.TestLogger{TB: t}, engine.NewMemMigrationCache())
+		return err
+	}
+	assert.Error(t, migrate(newTree(t, store, map[uint64]cid.Cid{100: oldCode})))
+	assert.NoError(t, migrate(newTree(t, store, map[uint64]cid.Cid{90: oldCode, 100: oldCode})))
+}
@@ -1,4 +1,4 @@
-package nv15
+package engine
 
 import (
 	"sync"
@@ -51,6 +51,14 @@ func (m *MemMigrationCache) Load(key string, loadFunc func() (cid.Cid, error)) (
 	return c, nil
 }
 
+func (m *MemMigrationCache) InvalidateAll() error {
+	m.MigrationMap.Range(func(key, _ interface{}) bool {
+		m.MigrationMap.Delete(key)
+		return true
+	})
+	return nil
+}
+
 func (m *MemMigrationCache) Clone() *MemMigrationCache {
 	newCache := NewMemMigrationCache()
 	newCache.Update(m)
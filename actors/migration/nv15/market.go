@@ -0,0 +1,270 @@
+package nv15
+
+import (
+	"context"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	market6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/market"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	market7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
+	adt7 "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// Migrates deal proposal labels, previously encoded as CBOR text strings whether or not they were valid UTF-8,
+// to the string or bytes label union. Labels which are valid UTF-8 keep their encoding, so only proposals with
+// other labels are rewritten, along with their CIDs in the set of pending proposals.
+// Every deal state is rewritten to record the datacap its deal consumed, which is the piece size of a verified deal.
+// Each deal op is moved to the epoch at which the v7 actor expects it, the first epoch at the deal's offset within
+// the update interval following its start, or the epoch at which it was last processed. A deal processed late may
+// then be due at an epoch cron has already passed, in which case it is moved to its offset in the interval
+// following the last cron, where the v7 actor also expects it.
+type marketMigrator struct{}
+
+var _ engine.ActorMigration = marketMigrator{}
+
+func (m marketMigrator) MigrateState(ctx context.Context, store cbor.IpldStore, in engine.ActorMigrationInput) (*engine.ActorMigrationResult, error) {
+	var stIn market6.State
+	if err := store.Get(ctx, in.Head, &stIn); err != nil {
+		return nil, xerrors.Errorf("failed to load market state for %s: %w", in.Address, err)
+	}
+	adtStore := adt7.WrapStore(ctx, store)
+	proposals, err := adt7.AsArray(adtStore, stIn.Proposals, market7.ProposalsAmtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load deal proposals: %w", err)
+	}
+
+	// Deal states are migrated before any proposal is relabelled, while all proposals still decode as v6.
+	states, err := migrateDealStates(adtStore, stIn.States, proposals)
+	if err != nil {
+		return nil, err
+	}
+	dealOps, err := migrateDealOps(adtStore, stIn.DealOpsByEpoch, stIn.States, proposals, stIn.LastCron)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the rewritten proposals first, since the array must not be modified while iterating.
+	type relabelled struct {
+		id     uint64
+		oldCid cid.Cid
+		prop   market7.DealProposal
+	}
+	var updated []relabelled
+	var propIn market6.DealProposal
+	if err := proposals.ForEach(&propIn, func(id int64) error {
+		if utf8.ValidString(propIn.Label) {
+			return nil
+		}
+		oldCid, err := propIn.Cid()
+		if err != nil {
+			return xerrors.Errorf("failed to compute CID of deal proposal %d: %w", id, err)
+		}
+		updated = append(updated, relabelled{id: uint64(id), oldCid: oldCid, prop: market7.DealProposal{
+			PieceCID:             propIn.PieceCID,
+			PieceSize:            propIn.PieceSize,
+			VerifiedDeal:         propIn.VerifiedDeal,
+			Client:               propIn.Client,
+			Provider:             propIn.Provider,
+			Label:                market7.NewLabelFromBytes([]byte(propIn.Label)),
+			StartEpoch:           propIn.StartEpoch,
+			EndEpoch:             propIn.EndEpoch,
+			StoragePricePerEpoch: propIn.StoragePricePerEpoch,
+			ProviderCollateral:   propIn.ProviderCollateral,
+			ClientCollateral:     propIn.ClientCollateral,
+		}})
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("failed to iterate deal proposals: %w", err)
+	}
+
+	pending, err := adt7.AsSet(adtStore, stIn.PendingProposals, builtin7.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load pending proposals: %w", err)
+	}
+	for _, u := range updated {
+		if err := proposals.Set(u.id, &u.prop); err != nil {
+			return nil, xerrors.Errorf("failed to write deal proposal %d: %w", u.id, err)
+		}
+		// A proposal remains pending until its deal starts, so its new CID is pending only if the old one was.
+		wasPending, err := pending.TryDelete(abi.CidKey(u.oldCid))
+		if err != nil {
+			return nil, xerrors.Errorf("failed to delete pending proposal %d: %w", u.id, err)
+		}
+		if !wasPending {
+			continue
+		}
+		newCid, err := u.prop.Cid()
+		if err != nil {
+			return nil, xerrors.Errorf("failed to compute CID of deal proposal %d: %w", u.id, err)
+		}
+		if err := pending.Put(abi.CidKey(newCid)); err != nil {
+			return nil, xerrors.Errorf("failed to put pending proposal %d: %w", u.id, err)
+		}
+	}
+
+	stOut := market7.State{
+		States:                        states,
+		EscrowTable:                   stIn.EscrowTable,
+		LockedTable:                   stIn.LockedTable,
+		NextID:                        stIn.NextID,
+		DealOpsByEpoch:                dealOps,
+		LastCron:                      stIn.LastCron,
+		TotalClientLockedCollateral:   stIn.TotalClientLockedCollateral,
+		TotalProviderLockedCollateral: stIn.TotalProviderLockedCollateral,
+		TotalClientStorageFee:         stIn.TotalClientStorageFee,
+	}
+	if stOut.Proposals, err = proposals.Root(); err != nil {
+		return nil, xerrors.Errorf("failed to flush deal proposals: %w", err)
+	}
+	if stOut.PendingProposals, err = pending.Root(); err != nil {
+		return nil, xerrors.Errorf("failed to flush pending proposals: %w", err)
+	}
+	newHead, err := store.Put(ctx, &stOut)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to write market state for %s: %w", in.Address, err)
+	}
+	return &engine.ActorMigrationResult{
+		NewCodeCID: m.MigratedCodeCID(),
+		NewHead:    newHead,
+	}, nil
+}
+
+// Writes a new array of deal states, adding to each the datacap consumed by its deal.
+func migrateDealStates(store adt7.Store, root cid.Cid, proposals *adt7.Array) (cid.Cid, error) {
+	statesIn, err := adt7.AsArray(store, root, market7.StatesAmtBitwidth)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to load deal states: %w", err)
+	}
+	statesOut, err := adt7.MakeEmptyArray(store, market7.StatesAmtBitwidth)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to create deal states: %w", err)
+	}
+
+	var entries []adt7.ArrayEntry
+	var stateIn market6.DealState
+	if err := statesIn.ForEach(&stateIn, func(id int64) error {
+		var prop market6.DealProposal
+		found, err := proposals.Get(uint64(id), &prop)
+		if err != nil {
+			return xerrors.Errorf("failed to load deal proposal %d: %w", id, err)
+		}
+		if !found {
+			return xerrors.Errorf("no proposal for deal state %d", id)
+		}
+		dataCap := big.Zero()
+		if prop.VerifiedDeal {
+			dataCap = big.NewIntUnsigned(uint64(prop.PieceSize))
+		}
+		entries = append(entries, adt7.ArrayEntry{Index: uint64(id), Value: &market7.DealState{
+			SectorStartEpoch: stateIn.SectorStartEpoch,
+			LastUpdatedEpoch: stateIn.LastUpdatedEpoch,
+			SlashEpoch:       stateIn.SlashEpoch,
+			DataCap:          dataCap,
+		}})
+		return nil
+	}); err != nil {
+		return cid.Undef, xerrors.Errorf("failed to iterate deal states: %w", err)
+	}
+	if err := statesOut.BatchSet(entries); err != nil {
+		return cid.Undef, xerrors.Errorf("failed to write deal states: %w", err)
+	}
+	return statesOut.Root()
+}
+
+// Writes a new deal op schedule, moving each deal op to the epoch at which the v7 actor expects it.
+// Deals processed on schedule are already there. A deal which was processed late was scheduled the update interval
+// after it was processed, and is moved back to the first epoch at its offset after it was processed.
+// A deal whose epoch at its offset has already passed is moved to its first epoch at its offset after the last cron,
+// so that the backlog is spread over the following interval rather than processed by the first cron.
+func migrateDealOps(store adt7.Store, root cid.Cid, statesRoot cid.Cid, proposals *adt7.Array, lastCron abi.ChainEpoch) (cid.Cid, error) {
+	opsIn, err := market7.AsSetMultimap(store, root, builtin7.DefaultHamtBitwidth, builtin7.DefaultHamtBitwidth)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to load deal ops: %w", err)
+	}
+	epochs, err := adt7.AsMap(store, root, builtin7.DefaultHamtBitwidth)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to load deal ops: %w", err)
+	}
+	states, err := adt7.AsArray(store, statesRoot, market7.StatesAmtBitwidth)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to load deal states: %w", err)
+	}
+	opsOut, err := market7.MakeEmptySetMultimap(store, builtin7.DefaultHamtBitwidth)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to create deal ops: %w", err)
+	}
+
+	rescheduled := make(map[abi.ChainEpoch][]abi.DealID)
+	if err := epochs.ForEach(nil, func(key string) error {
+		k, err := abi.ParseUIntKey(key)
+		if err != nil {
+			return xerrors.Errorf("deal ops has key that is not an int: %s: %w", key, err)
+		}
+		epoch := abi.ChainEpoch(k)
+		return opsIn.ForEach(epoch, func(id abi.DealID) error {
+			next, err := dealScheduledEpoch(states, proposals, id, lastCron)
+			if err != nil {
+				return err
+			}
+			rescheduled[next] = append(rescheduled[next], id)
+			return nil
+		})
+	}); err != nil {
+		return cid.Undef, xerrors.Errorf("failed to iterate deal ops: %w", err)
+	}
+
+	// Write the rescheduled deals in epoch order, so the result does not depend on map iteration order.
+	nextEpochs := make([]abi.ChainEpoch, 0, len(rescheduled))
+	for epoch := range rescheduled { //nolint:nomaprange
+		nextEpochs = append(nextEpochs, epoch)
+	}
+	sort.Slice(nextEpochs, func(i, j int) bool { return nextEpochs[i] < nextEpochs[j] })
+	for _, epoch := range nextEpochs {
+		if err := opsOut.PutMany(epoch, rescheduled[epoch]); err != nil {
+			return cid.Undef, xerrors.Errorf("failed to write deal ops at epoch %d: %w", epoch, err)
+		}
+	}
+	root, err = opsOut.Root()
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to flush deal ops: %w", err)
+	}
+	return root, nil
+}
+
+// Returns the epoch at which the v7 actor expects a deal's cron operation: the first epoch at the deal's offset
+// from its start, or following the epoch at which it was last processed, and after the last cron.
+func dealScheduledEpoch(states, proposals *adt7.Array, id abi.DealID, lastCron abi.ChainEpoch) (abi.ChainEpoch, error) {
+	var prop market6.DealProposal
+	found, err := proposals.Get(uint64(id), &prop)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to load deal proposal %d: %w", id, err)
+	}
+	if !found {
+		return 0, xerrors.Errorf("no proposal for deal op %d", id)
+	}
+	var state market6.DealState
+	found, err = states.Get(uint64(id), &state)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to load deal state %d: %w", id, err)
+	}
+	from := prop.StartEpoch
+	if found && state.LastUpdatedEpoch != -1 {
+		from = state.LastUpdatedEpoch + 1
+	}
+	if from <= lastCron {
+		from = lastCron + 1
+	}
+	return market7.GenRandNextEpoch(from, id), nil
+}
+
+func (m marketMigrator) MigratedCodeCID() cid.Cid {
+	return builtin7.StorageMarketActorCodeID
+}
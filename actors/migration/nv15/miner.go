@@ -0,0 +1,160 @@
+package nv15
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/big"
+	miner6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/miner"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	miner7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
+	adt7 "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// Migrates the miner info to add a beneficiary, which is initially the owner, to hold any pending worker key
+// change in a queue, and to permit existing control addresses all methods.
+// The miner state gains sector statistics, which start at zero since they were not previously tracked.
+// Each deadline gains a set of dirty partitions, which initially holds all of its partitions.
+type minerMigrator struct{}
+
+var _ engine.ActorMigration = minerMigrator{}
+
+func (m minerMigrator) MigrateState(ctx context.Context, store cbor.IpldStore, in engine.ActorMigrationInput) (*engine.ActorMigrationResult, error) {
+	var stIn miner6.State
+	if err := store.Get(ctx, in.Head, &stIn); err != nil {
+		return nil, xerrors.Errorf("failed to load miner state for %s: %w", in.Address, err)
+	}
+	var infoIn miner6.MinerInfo
+	if err := store.Get(ctx, stIn.Info, &infoIn); err != nil {
+		return nil, xerrors.Errorf("failed to load miner info for %s: %w", in.Address, err)
+	}
+
+	var pendingWorkerKeys []miner7.WorkerKeyChange
+	if infoIn.PendingWorkerKey != nil {
+		pendingWorkerKeys = []miner7.WorkerKeyChange{{
+			NewWorker:   infoIn.PendingWorkerKey.NewWorker,
+			EffectiveAt: infoIn.PendingWorkerKey.EffectiveAt,
+		}}
+	}
+	infoOut := miner7.MinerInfo{
+		Owner:                      infoIn.Owner,
+		Worker:                     infoIn.Worker,
+		ControlAddresses:           infoIn.ControlAddresses,
+		ControlPermissions:         miner7.UnrestrictedControlPermissions(len(infoIn.ControlAddresses)),
+		PendingWorkerKeys:          pendingWorkerKeys,
+		PeerId:                     infoIn.PeerId,
+		Multiaddrs:                 infoIn.Multiaddrs,
+		WindowPoStProofType:        infoIn.WindowPoStProofType,
+		SectorSize:                 infoIn.SectorSize,
+		WindowPoStPartitionSectors: infoIn.WindowPoStPartitionSectors,
+		ConsensusFaultElapsed:      infoIn.ConsensusFaultElapsed,
+		PendingOwnerAddress:        infoIn.PendingOwnerAddress,
+		Beneficiary:                infoIn.Owner,
+		BeneficiaryTerm: miner7.BeneficiaryTerm{
+			Quota:      big.Zero(),
+			UsedQuota:  big.Zero(),
+			Expiration: 0,
+		},
+		PendingBeneficiaryTerm: nil,
+	}
+	infoCid, err := store.Put(ctx, &infoOut)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to write miner info for %s: %w", in.Address, err)
+	}
+
+	deadlinesCid, err := migrateDeadlines(ctx, store, stIn.Deadlines)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to migrate deadlines for %s: %w", in.Address, err)
+	}
+
+	stOut := miner7.State{
+		Info:                       infoCid,
+		PreCommitDeposits:          stIn.PreCommitDeposits,
+		LockedFunds:                stIn.LockedFunds,
+		VestingFunds:               stIn.VestingFunds,
+		FeeDebt:                    stIn.FeeDebt,
+		InitialPledge:              stIn.InitialPledge,
+		PreCommittedSectors:        stIn.PreCommittedSectors,
+		PreCommittedSectorsCleanUp: stIn.PreCommittedSectorsCleanUp,
+		AllocatedSectors:           stIn.AllocatedSectors,
+		Sectors:                    stIn.Sectors,
+		ProvingPeriodStart:         stIn.ProvingPeriodStart,
+		CurrentDeadline:            stIn.CurrentDeadline,
+		Deadlines:                  deadlinesCid,
+		EarlyTerminations:          stIn.EarlyTerminations,
+		DeadlineCronActive:         stIn.DeadlineCronActive,
+		Stats:                      miner7.SectorStats{},
+	}
+	newHead, err := store.Put(ctx, &stOut)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to write miner state for %s: %w", in.Address, err)
+	}
+	return &engine.ActorMigrationResult{
+		NewCodeCID: m.MigratedCodeCID(),
+		NewHead:    newHead,
+	}, nil
+}
+
+// Marks every partition of each deadline dirty, since which of them have power to prove isn't known
+// without loading them. Deadlines with identical state, commonly empty ones, are migrated once.
+func migrateDeadlines(ctx context.Context, store cbor.IpldStore, deadlinesIn cid.Cid) (cid.Cid, error) {
+	var dlsIn miner6.Deadlines
+	if err := store.Get(ctx, deadlinesIn, &dlsIn); err != nil {
+		return cid.Undef, xerrors.Errorf("failed to load deadlines: %w", err)
+	}
+	adtStore := adt7.WrapStore(ctx, store)
+	migrated := make(map[cid.Cid]cid.Cid)
+	dlsOut := miner7.Deadlines{Due: make([]cid.Cid, len(dlsIn.Due))}
+	for dlIdx, dlCidIn := range dlsIn.Due {
+		if dlCidOut, ok := migrated[dlCidIn]; ok {
+			dlsOut.Due[dlIdx] = dlCidOut
+			continue
+		}
+		var dlIn miner6.Deadline
+		if err := store.Get(ctx, dlCidIn, &dlIn); err != nil {
+			return cid.Undef, xerrors.Errorf("failed to load deadline %d: %w", dlIdx, err)
+		}
+		partitions, err := adt7.AsArray(adtStore, dlIn.Partitions, miner7.DeadlinePartitionsAmtBitwidth)
+		if err != nil {
+			return cid.Undef, xerrors.Errorf("failed to load partitions of deadline %d: %w", dlIdx, err)
+		}
+		dirtyPartitions := bitfield.New()
+		for partIdx := uint64(0); partIdx < partitions.Length(); partIdx++ {
+			dirtyPartitions.Set(partIdx)
+		}
+
+		dlOut := miner7.Deadline{
+			Partitions:                        dlIn.Partitions,
+			ExpirationsEpochs:                 dlIn.ExpirationsEpochs,
+			PartitionsPoSted:                  dlIn.PartitionsPoSted,
+			DirtyPartitions:                   dirtyPartitions,
+			EarlyTerminations:                 dlIn.EarlyTerminations,
+			LiveSectors:                       dlIn.LiveSectors,
+			TotalSectors:                      dlIn.TotalSectors,
+			FaultyPower:                       miner7.NewPowerPair(dlIn.FaultyPower.Raw, dlIn.FaultyPower.QA),
+			OptimisticPoStSubmissions:         dlIn.OptimisticPoStSubmissions,
+			PartitionsSnapshot:                dlIn.PartitionsSnapshot,
+			OptimisticPoStSubmissionsSnapshot: dlIn.OptimisticPoStSubmissionsSnapshot,
+		}
+		dlCidOut, err := store.Put(ctx, &dlOut)
+		if err != nil {
+			return cid.Undef, xerrors.Errorf("failed to write deadline %d: %w", dlIdx, err)
+		}
+		migrated[dlCidIn] = dlCidOut
+		dlsOut.Due[dlIdx] = dlCidOut
+	}
+	deadlinesOut, err := store.Put(ctx, &dlsOut)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to write deadlines: %w", err)
+	}
+	return deadlinesOut, nil
+}
+
+func (m minerMigrator) MigratedCodeCID() cid.Cid {
+	return builtin7.StorageMinerActorCodeID
+}
@@ -0,0 +1,89 @@
+package nv15
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-state-types/big"
+	multisig6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/multisig"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	multisig7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/multisig"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
+	adt7 "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// Migrates multisig state to add an expiration to each pending transaction, signer weights and a spending limit.
+// Existing transactions do not expire, existing signers' approvals keep equal weights, and spending is not limited.
+type multisigMigrator struct{}
+
+var _ engine.ActorMigration = multisigMigrator{}
+
+func (m multisigMigrator) MigrateState(ctx context.Context, store cbor.IpldStore, in engine.ActorMigrationInput) (*engine.ActorMigrationResult, error) {
+	var stIn multisig6.State
+	if err := store.Get(ctx, in.Head, &stIn); err != nil {
+		return nil, xerrors.Errorf("failed to load multisig state for %s: %w", in.Address, err)
+	}
+	pending, err := migratePendingTxns(adt7.WrapStore(ctx, store), stIn.PendingTxns)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to migrate pending transactions for %s: %w", in.Address, err)
+	}
+
+	stOut := multisig7.State{
+		Signers:               stIn.Signers,
+		SignerWeights:         nil,
+		NumApprovalsThreshold: stIn.NumApprovalsThreshold,
+		NextTxnID:             stIn.NextTxnID,
+		InitialBalance:        stIn.InitialBalance,
+		StartEpoch:            stIn.StartEpoch,
+		UnlockDuration:        stIn.UnlockDuration,
+		SpendingLimit:         big.Zero(),
+		SpendingPeriod:        0,
+		SpendingPeriodStart:   0,
+		SpentInPeriod:         big.Zero(),
+		PendingTxns:           pending,
+	}
+	newHead, err := store.Put(ctx, &stOut)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to write multisig state for %s: %w", in.Address, err)
+	}
+	return &engine.ActorMigrationResult{
+		NewCodeCID: m.MigratedCodeCID(),
+		NewHead:    newHead,
+	}, nil
+}
+
+func (m multisigMigrator) MigratedCodeCID() cid.Cid {
+	return builtin7.MultisigActorCodeID
+}
+
+// Writes a new map of pending transactions, none of which expire.
+func migratePendingTxns(store adt7.Store, root cid.Cid) (cid.Cid, error) {
+	txnsIn, err := adt7.AsMap(store, root, builtin7.DefaultHamtBitwidth)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to load transactions: %w", err)
+	}
+	txnsOut, err := adt7.MakeEmptyMap(store, builtin7.DefaultHamtBitwidth)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to create transactions: %w", err)
+	}
+
+	var txnIn multisig6.Transaction
+	if err := txnsIn.ForEach(&txnIn, func(k string) error {
+		if err := txnsOut.Put(multisig7.StringKey(k), &multisig7.Transaction{
+			To:       txnIn.To,
+			Value:    txnIn.Value,
+			Method:   txnIn.Method,
+			Params:   txnIn.Params,
+			Approved: txnIn.Approved,
+		}); err != nil {
+			return xerrors.Errorf("failed to write transaction %x: %w", k, err)
+		}
+		return nil
+	}); err != nil {
+		return cid.Undef, xerrors.Errorf("failed to iterate transactions: %w", err)
+	}
+	return txnsOut.Root()
+}
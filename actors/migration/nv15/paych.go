@@ -0,0 +1,48 @@
+package nv15
+
+import (
+	"context"
+
+	paych6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/paych"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	paych7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/paych"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
+)
+
+// Migrates payment channel state to add a watcher. Existing channels have no watcher.
+type paychMigrator struct{}
+
+var _ engine.ActorMigration = paychMigrator{}
+
+func (m paychMigrator) MigrateState(ctx context.Context, store cbor.IpldStore, in engine.ActorMigrationInput) (*engine.ActorMigrationResult, error) {
+	var stIn paych6.State
+	if err := store.Get(ctx, in.Head, &stIn); err != nil {
+		return nil, xerrors.Errorf("failed to load payment channel state for %s: %w", in.Address, err)
+	}
+
+	stOut := paych7.State{
+		From:            stIn.From,
+		To:              stIn.To,
+		Watcher:         nil,
+		ToSend:          stIn.ToSend,
+		SettlingAt:      stIn.SettlingAt,
+		MinSettleHeight: stIn.MinSettleHeight,
+		LaneStates:      stIn.LaneStates,
+	}
+	newHead, err := store.Put(ctx, &stOut)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to write payment channel state for %s: %w", in.Address, err)
+	}
+	return &engine.ActorMigrationResult{
+		NewCodeCID: m.MigratedCodeCID(),
+		NewHead:    newHead,
+	}, nil
+}
+
+func (m paychMigrator) MigratedCodeCID() cid.Cid {
+	return builtin7.PaymentChannelActorCodeID
+}
@@ -0,0 +1,146 @@
+package nv15
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	power6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/power"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	power7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/power"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
+	adt7 "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	smoothing7 "github.com/filecoin-project/specs-actors/v7/actors/util/smoothing"
+)
+
+// Migrates the power state to add a ring buffer of power snapshots. The buffer starts empty, since snapshots
+// were not previously recorded, and is filled by cron from the upgrade.
+// Every claim is rewritten to add a record of the miner's consensus faults, which starts empty for the same reason.
+// Every cron event is rewritten to add its priority.
+type powerMigrator struct{}
+
+var _ engine.ActorMigration = powerMigrator{}
+
+func (m powerMigrator) MigrateState(ctx context.Context, store cbor.IpldStore, in engine.ActorMigrationInput) (*engine.ActorMigrationResult, error) {
+	var stIn power6.State
+	if err := store.Get(ctx, in.Head, &stIn); err != nil {
+		return nil, xerrors.Errorf("failed to load power state for %s: %w", in.Address, err)
+	}
+	adtStore := adt7.WrapStore(ctx, store)
+	claims, err := migrateClaims(adtStore, stIn.Claims)
+	if err != nil {
+		return nil, err
+	}
+	cronEvents, err := migrateCronEvents(adtStore, stIn.CronEventQueue)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := adt7.StoreEmptyArray(adtStore, power7.PowerSnapshotsAmtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create power snapshots: %w", err)
+	}
+
+	stOut := power7.State{
+		TotalRawBytePower:         stIn.TotalRawBytePower,
+		TotalBytesCommitted:       stIn.TotalBytesCommitted,
+		TotalQualityAdjPower:      stIn.TotalQualityAdjPower,
+		TotalQABytesCommitted:     stIn.TotalQABytesCommitted,
+		TotalPledgeCollateral:     stIn.TotalPledgeCollateral,
+		ThisEpochRawBytePower:     stIn.ThisEpochRawBytePower,
+		ThisEpochQualityAdjPower:  stIn.ThisEpochQualityAdjPower,
+		ThisEpochPledgeCollateral: stIn.ThisEpochPledgeCollateral,
+		ThisEpochQAPowerSmoothed: smoothing7.FilterEstimate{
+			PositionEstimate: stIn.ThisEpochQAPowerSmoothed.PositionEstimate,
+			VelocityEstimate: stIn.ThisEpochQAPowerSmoothed.VelocityEstimate,
+		},
+		MinerCount:              stIn.MinerCount,
+		MinerAboveMinPowerCount: stIn.MinerAboveMinPowerCount,
+		CronEventQueue:          cronEvents,
+		FirstCronEpoch:          stIn.FirstCronEpoch,
+		Claims:                  claims,
+		ProofValidationBatch:    stIn.ProofValidationBatch,
+		PowerSnapshots:          snapshots,
+	}
+	newHead, err := store.Put(ctx, &stOut)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to write power state for %s: %w", in.Address, err)
+	}
+	return &engine.ActorMigrationResult{
+		NewCodeCID: m.MigratedCodeCID(),
+		NewHead:    newHead,
+	}, nil
+}
+
+func (m powerMigrator) MigratedCodeCID() cid.Cid {
+	return builtin7.StoragePowerActorCodeID
+}
+
+// Writes a new map of claims, with no consensus faults recorded.
+func migrateClaims(store adt7.Store, root cid.Cid) (cid.Cid, error) {
+	claimsIn, err := adt7.AsMap(store, root, builtin7.DefaultHamtBitwidth)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to load claims: %w", err)
+	}
+	claimsOut, err := adt7.MakeEmptyMap(store, builtin7.DefaultHamtBitwidth)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to create claims: %w", err)
+	}
+
+	var claimIn power6.Claim
+	if err := claimsIn.ForEach(&claimIn, func(k string) error {
+		miner, err := address.NewFromBytes([]byte(k))
+		if err != nil {
+			return xerrors.Errorf("invalid claim key %x: %w", k, err)
+		}
+		if err := claimsOut.Put(abi.AddrKey(miner), &power7.Claim{
+			WindowPoStProofType: claimIn.WindowPoStProofType,
+			RawBytePower:        claimIn.RawBytePower,
+			QualityAdjPower:     claimIn.QualityAdjPower,
+		}); err != nil {
+			return xerrors.Errorf("failed to write claim for %s: %w", miner, err)
+		}
+		return nil
+	}); err != nil {
+		return cid.Undef, xerrors.Errorf("failed to iterate claims: %w", err)
+	}
+	return claimsOut.Root()
+}
+
+// Writes a new queue of cron events, with the same events at the same epochs.
+// Every event is a priority event, since each was enrolled to be invoked at its epoch, before the limit on
+// events per epoch. Nearly all are miner proving deadline events, which miners now enroll with priority.
+func migrateCronEvents(store adt7.Store, root cid.Cid) (cid.Cid, error) {
+	eventsIn, err := adt7.AsMultimap(store, root, power6.CronQueueHamtBitwidth, power6.CronQueueAmtBitwidth)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to load cron events: %w", err)
+	}
+	eventsOut, err := adt7.MakeEmptyMultimap(store, power7.CronQueueHamtBitwidth, power7.CronQueueAmtBitwidth)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to create cron events: %w", err)
+	}
+
+	if err := eventsIn.ForAll(func(k string, arr *adt7.Array) error {
+		epoch, err := abi.ParseIntKey(k)
+		if err != nil {
+			return xerrors.Errorf("invalid cron epoch key %x: %w", k, err)
+		}
+		var eventIn power6.CronEvent
+		return arr.ForEach(&eventIn, func(_ int64) error {
+			if err := eventsOut.Add(abi.IntKey(epoch), &power7.CronEvent{
+				MinerAddr:       eventIn.MinerAddr,
+				CallbackPayload: eventIn.CallbackPayload,
+				Priority:        true,
+			}); err != nil {
+				return xerrors.Errorf("failed to write cron event at epoch %d: %w", epoch, err)
+			}
+			return nil
+		})
+	}); err != nil {
+		return cid.Undef, xerrors.Errorf("failed to iterate cron events: %w", err)
+	}
+	return eventsOut.Root()
+}
@@ -0,0 +1,67 @@
+package test_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	vm6 "github.com/filecoin-project/specs-actors/v6/support/vm"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/nv15"
+	adt7 "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+)
+
+func TestDryRunMigration(t *testing.T) {
+	ctx := context.Background()
+	log := nv15.TestLogger{TB: t}
+	bs := ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory())
+	vm := vm6.NewVMWithSingletons(ctx, t, bs)
+	startRoot := vm.StateRoot()
+
+	// Count writes to the underlying store from here on.
+	metrics := ipld.NewMetricsBlockStore(bs)
+	adtStore := adt7.WrapStore(ctx, cbor.NewCborStore(ipld.NewSyncBlockStore(metrics)))
+	cfg := nv15.Config{MaxWorkers: 2}
+
+	report, err := nv15.DryRunStateTree(ctx, adtStore, startRoot, abi.ChainEpoch(0), cfg, log, nv15.NewMemMigrationCache())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), metrics.WriteCount())
+
+	// Every actor's code changes, but no actor is removed, added, or has its balance changed.
+	// Only actors migrated by code alone keep their state head.
+	codeOnly := map[cid.Cid]bool{
+		builtin7.AccountActorCodeID: true,
+		builtin7.CronActorCodeID:    true,
+		builtin7.InitActorCodeID:    true,
+		builtin7.RewardActorCodeID:  true,
+		builtin7.SystemActorCodeID:  true,
+	}
+	assert.NotEmpty(t, report.Changed)
+	assert.Zero(t, report.Unchanged)
+	assert.Empty(t, report.Removed)
+	assert.Empty(t, report.Added)
+	assert.Empty(t, report.BalanceChanges)
+	for _, change := range report.Changed {
+		assert.NotEqual(t, change.CodeBefore, change.CodeAfter)
+		if codeOnly[change.CodeAfter] {
+			assert.Equal(t, change.HeadBefore, change.HeadAfter)
+		}
+	}
+
+	// The dry run computes the same root as a real migration.
+	cfg.DryRun = true
+	dryRoot, err := nv15.MigrateStateTree(ctx, adtStore, startRoot, abi.ChainEpoch(0), cfg, log, nv15.NewMemMigrationCache())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), metrics.WriteCount())
+
+	cfg.DryRun = false
+	root, err := nv15.MigrateStateTree(ctx, adtStore, startRoot, abi.ChainEpoch(0), cfg, log, nv15.NewMemMigrationCache())
+	require.NoError(t, err)
+	assert.Equal(t, root, dryRoot)
+}
@@ -0,0 +1,127 @@
+package test_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	ipld2 "github.com/filecoin-project/specs-actors/v2/support/ipld"
+	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
+	states6 "github.com/filecoin-project/specs-actors/v6/actors/states"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/nv15"
+	states7 "github.com/filecoin-project/specs-actors/v7/actors/states"
+	adt7 "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+// Prior and expected migrated code CIDs of the built-in actors whose state is not migrated.
+// Miners, the market, power, the verified registry, multisigs and payment channels are omitted, since the
+// migration loads their state (see TestMinerMigration, TestMarketMigration, TestPowerMigration,
+// TestVerifregMigration, TestMultisigMigration and TestPaychMigration).
+var fuzzCodes = [][2]cid.Cid{
+	{builtin6.SystemActorCodeID, builtin7.SystemActorCodeID},
+	{builtin6.InitActorCodeID, builtin7.InitActorCodeID},
+	{builtin6.CronActorCodeID, builtin7.CronActorCodeID},
+	{builtin6.AccountActorCodeID, builtin7.AccountActorCodeID},
+	{builtin6.RewardActorCodeID, builtin7.RewardActorCodeID},
+}
+
+// Migrates randomized state trees with edge-case actors: dust and zero balances, heads which don't decode as
+// the actor's state, missing singletons (including the burnt funds actor), and occasionally
+// an actor with an unknown code CID.
+// The migration must either succeed, migrating every actor's code and preserving the rest of its record (and
+// so the total supply), or fail cleanly with an error.
+func TestMigrationFuzz(t *testing.T) {
+	const seeds = 50
+	for seed := int64(0); seed < seeds; seed++ {
+		seed := seed
+		t.Run(fmt.Sprintf("seed %d", seed), func(t *testing.T) {
+			fuzzMigration(t, rand.New(rand.NewSource(seed)))
+		})
+	}
+}
+
+func fuzzMigration(t *testing.T, rnd *rand.Rand) {
+	ctx := context.Background()
+	log := nv15.TestLogger{TB: t}
+	store := adt7.WrapStore(ctx, cbor.NewCborStore(ipld2.NewSyncBlockStoreInMemory()))
+
+	tree, err := states6.NewTree(store)
+	require.NoError(t, err)
+	expected := map[address.Address]states7.Actor{}
+	actorCount := rnd.Intn(60)
+	for i := 0; i < actorCount; i++ {
+		// Singleton addresses, including f099, are present or not at random.
+		addr := tutil.NewIDAddr(t, uint64(100+rnd.Intn(1000)))
+		if rnd.Intn(4) == 0 {
+			addr = tutil.NewIDAddr(t, uint64(rnd.Intn(100)))
+		}
+
+		codes := fuzzCodes[rnd.Intn(len(fuzzCodes))]
+		if rnd.Intn(50) == 0 {
+			codes = [2]cid.Cid{tutil.MakeCID(fmt.Sprintf("unknown-%d", i), nil), cid.Undef}
+		}
+
+		var balance abi.TokenAmount
+		switch rnd.Intn(4) {
+		case 0:
+			balance = big.Zero()
+		case 1:
+			balance = big.NewInt(1) // Dust.
+		case 2:
+			balance = big.NewInt(rnd.Int63())
+		default:
+			balance = big.Mul(big.NewInt(rnd.Int63()), big.NewInt(1e18))
+		}
+		actor := states6.Actor{
+			Code:       codes[0],
+			Head:       tutil.MakeCID(fmt.Sprintf("head-%d", rnd.Int63()), nil),
+			CallSeqNum: uint64(rnd.Intn(3)),
+			Balance:    balance,
+		}
+		require.NoError(t, tree.SetActor(addr, &actor))
+		expected[addr] = states7.Actor{Code: codes[1], Head: actor.Head, CallSeqNum: actor.CallSeqNum, Balance: actor.Balance}
+	}
+	rootIn, err := tree.Flush()
+	require.NoError(t, err)
+	expectFailure := false
+	for _, actor := range expected { // nolint:nomaprange
+		expectFailure = expectFailure || !actor.Code.Defined()
+	}
+
+	cfg := nv15.Config{MaxWorkers: uint(1 + rnd.Intn(4)), FlushPeriod: uint(rnd.Intn(10))}
+	rootOut, err := nv15.MigrateStateTree(ctx, store, rootIn, abi.ChainEpoch(rnd.Intn(1000)), cfg, log, nv15.NewMemMigrationCache())
+	if expectFailure {
+		require.Error(t, err)
+		return
+	}
+	require.NoError(t, err)
+
+	treeOut, err := states7.LoadTree(store, rootOut)
+	require.NoError(t, err)
+	supplyIn, supplyOut := big.Zero(), big.Zero()
+	count := 0
+	require.NoError(t, treeOut.ForEach(func(addr address.Address, actor *states7.Actor) error {
+		count++
+		exp, ok := expected[addr]
+		require.True(t, ok, "unexpected actor %s", addr)
+		assert.Equal(t, exp, *actor, "actor %s", addr)
+		supplyOut = big.Add(supplyOut, actor.Balance)
+		return nil
+	}))
+	for _, actor := range expected { // nolint:nomaprange
+		supplyIn = big.Add(supplyIn, actor.Balance)
+	}
+	assert.Equal(t, len(expected), count)
+	assert.Equal(t, supplyIn, supplyOut)
+}
@@ -0,0 +1,306 @@
+package test_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	ipld2 "github.com/filecoin-project/specs-actors/v2/support/ipld"
+	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
+	market6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/market"
+	states6 "github.com/filecoin-project/specs-actors/v6/actors/states"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	market7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/nv15"
+	states7 "github.com/filecoin-project/specs-actors/v7/actors/states"
+	adt7 "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestMarketMigration(t *testing.T) {
+	ctx := context.Background()
+	log := nv15.TestLogger{TB: t}
+	store := adt7.WrapStore(ctx, cbor.NewCborStore(ipld2.NewSyncBlockStoreInMemory()))
+
+	interval := market7.DealUpdatesInterval()
+	proposalIn := func(label string, start abi.ChainEpoch) market6.DealProposal {
+		return market6.DealProposal{
+			PieceCID:             tutil.MakeCID("piece", &market7.PieceCIDPrefix),
+			PieceSize:            abi.PaddedPieceSize(2048),
+			Client:               tutil.NewIDAddr(t, 100),
+			Provider:             tutil.NewIDAddr(t, 101),
+			Label:                label,
+			StartEpoch:           start,
+			EndEpoch:             start + 10*interval,
+			StoragePricePerEpoch: big.NewInt(1),
+			ProviderCollateral:   big.NewInt(2),
+			ClientCollateral:     big.NewInt(3),
+		}
+	}
+	// Deals 0 and 3 have UTF-8 labels, deals 1 and 2 do not. Deals 0 and 1 are pending.
+	// Deals 1, 2 and 3 have been activated, and deal 1 is verified.
+	// Deals 1 and 3 started in the first interval, and were each last processed late, after their offsets.
+	// Cron has passed deal 1's following offset, but not deal 3's, which was processed more recently.
+	raw := string([]byte{0xff, 0xfe})
+	propsIn := []market6.DealProposal{proposalIn("label", 3*interval), proposalIn(raw, interval),
+		proposalIn(raw+"x", 3*interval), proposalIn("late", interval)}
+	propsIn[1].VerifiedDeal = true
+	statesIn := map[uint64]market6.DealState{
+		1: {SectorStartEpoch: 11, LastUpdatedEpoch: interval + 2, SlashEpoch: -1},
+		2: {SectorStartEpoch: 13, LastUpdatedEpoch: -1, SlashEpoch: 14},
+		3: {SectorStartEpoch: 11, LastUpdatedEpoch: 2 * interval, SlashEpoch: -1},
+	}
+	empty, err := market7.ConstructState(store)
+	require.NoError(t, err)
+	proposals, err := adt7.AsArray(store, empty.Proposals, market7.ProposalsAmtBitwidth)
+	require.NoError(t, err)
+	pending, err := adt7.AsSet(store, empty.PendingProposals, builtin7.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	for id := range propsIn {
+		require.NoError(t, proposals.Set(uint64(id), &propsIn[id]))
+		if id < 2 {
+			c, err := propsIn[id].Cid()
+			require.NoError(t, err)
+			require.NoError(t, pending.Put(abi.CidKey(c)))
+		}
+	}
+	states, err := adt7.AsArray(store, empty.States, market7.StatesAmtBitwidth)
+	require.NoError(t, err)
+	for id := range statesIn {
+		st := statesIn[id]
+		require.NoError(t, states.Set(id, &st))
+	}
+	// Deals 0 and 2 are scheduled at their offsets following their start, and deals 1 and 3 the update interval
+	// after they were processed late.
+	opsIn := map[abi.ChainEpoch]abi.DealID{
+		market7.GenRandNextEpoch(3*interval, 0): 0,
+		2*interval + 2:                          1,
+		market7.GenRandNextEpoch(3*interval, 2): 2,
+		3 * interval:                            3,
+	}
+	dealOps, err := market7.AsSetMultimap(store, empty.DealOpsByEpoch, builtin7.DefaultHamtBitwidth, builtin7.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	for epoch, id := range opsIn {
+		require.NoError(t, dealOps.Put(epoch, id))
+	}
+	stIn := market6.State{
+		EscrowTable:                   empty.EscrowTable,
+		LockedTable:                   empty.LockedTable,
+		NextID:                        abi.DealID(len(propsIn)),
+		LastCron:                      2*interval + 1,
+		TotalClientLockedCollateral:   big.NewInt(6),
+		TotalProviderLockedCollateral: big.NewInt(7),
+		TotalClientStorageFee:         big.NewInt(8),
+	}
+	stIn.States, err = states.Root()
+	require.NoError(t, err)
+	stIn.DealOpsByEpoch, err = dealOps.Root()
+	require.NoError(t, err)
+	stIn.Proposals, err = proposals.Root()
+	require.NoError(t, err)
+	stIn.PendingProposals, err = pending.Root()
+	require.NoError(t, err)
+	headIn, err := store.Put(ctx, &stIn)
+	require.NoError(t, err)
+
+	tree, err := states6.NewTree(store)
+	require.NoError(t, err)
+	require.NoError(t, tree.SetActor(builtin7.StorageMarketActorAddr, &states6.Actor{
+		Code:    builtin6.StorageMarketActorCodeID,
+		Head:    headIn,
+		Balance: big.NewInt(1e18),
+	}))
+	rootIn, err := tree.Flush()
+	require.NoError(t, err)
+
+	rootOut, err := nv15.MigrateStateTree(ctx, store, rootIn, abi.ChainEpoch(0), nv15.Config{MaxWorkers: 1}, log, nv15.NewMemMigrationCache())
+	require.NoError(t, err)
+
+	treeOut, err := states7.LoadTree(store, rootOut)
+	require.NoError(t, err)
+	actor, found, err := treeOut.GetActor(builtin7.StorageMarketActorAddr)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, builtin7.StorageMarketActorCodeID, actor.Code)
+
+	var stOut market7.State
+	require.NoError(t, store.Get(ctx, actor.Head, &stOut))
+	assert.Equal(t, stIn.NextID, stOut.NextID)
+	assert.Equal(t, stIn.TotalClientStorageFee, stOut.TotalClientStorageFee)
+
+	// The UTF-8 label remains a string and the others become bytes.
+	proposalsOut, err := market7.AsDealProposalArray(store, stOut.Proposals)
+	require.NoError(t, err)
+	pendingOut, err := adt7.AsSet(store, stOut.PendingProposals, builtin7.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	for id, propIn := range propsIn {
+		propOut, found, err := proposalsOut.Get(abi.DealID(id))
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, id == 0 || id == 3, propOut.Label.IsString())
+		assert.Equal(t, []byte(propIn.Label), propOut.Label.ToBytes())
+		assert.Equal(t, propIn.PieceCID, propOut.PieceCID)
+		assert.Equal(t, propIn.EndEpoch, propOut.EndEpoch)
+
+		// Only proposals which were pending are pending under their new CIDs.
+		cidOut, err := propOut.Cid()
+		require.NoError(t, err)
+		isPending, err := pendingOut.Has(abi.CidKey(cidOut))
+		require.NoError(t, err)
+		assert.Equal(t, id < 2, isPending)
+		cidIn, err := propIn.Cid()
+		require.NoError(t, err)
+		assert.Equal(t, id == 0 || id == 3, cidIn == cidOut)
+	}
+	pendingKeys, err := pendingOut.CollectKeys()
+	require.NoError(t, err)
+	assert.Len(t, pendingKeys, 2)
+
+	// Deal states record the datacap consumed by verified deals.
+	statesOut, err := market7.AsDealStateArray(store, stOut.States)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), statesOut.Length())
+	for id, stateIn := range statesIn {
+		stateOut, found, err := statesOut.Get(abi.DealID(id))
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, stateIn.SectorStartEpoch, stateOut.SectorStartEpoch)
+		assert.Equal(t, stateIn.LastUpdatedEpoch, stateOut.LastUpdatedEpoch)
+		assert.Equal(t, stateIn.SlashEpoch, stateOut.SlashEpoch)
+	}
+	stateOut, _, err := statesOut.Get(1)
+	require.NoError(t, err)
+	assert.Equal(t, abi.NewStoragePower(2048), stateOut.DataCap)
+	stateOut, _, err = statesOut.Get(2)
+	require.NoError(t, err)
+	assert.Equal(t, big.Zero(), stateOut.DataCap)
+
+	// Deals 0 and 2 are unchanged. Deal 3 moves back to its offset following the epoch at which it was processed.
+	// That epoch for deal 1 has passed, so it moves to its offset following the last cron, which is unchanged.
+	r, err := market7.NewStateReader(store, actor.Head)
+	require.NoError(t, err)
+	schedule, err := r.DealSchedule(0, 5*interval)
+	require.NoError(t, err)
+	require.Less(t, market7.GenRandNextEpoch(interval+3, 1), stIn.LastCron)
+	require.Greater(t, market7.GenRandNextEpoch(2*interval+1, 3), stIn.LastCron)
+	expected := []market7.EpochDeals{
+		{Epoch: market7.GenRandNextEpoch(3*interval, 0), Deals: []abi.DealID{0}},
+		{Epoch: market7.GenRandNextEpoch(stIn.LastCron+1, 1), Deals: []abi.DealID{1}},
+		{Epoch: market7.GenRandNextEpoch(3*interval, 2), Deals: []abi.DealID{2}},
+		{Epoch: market7.GenRandNextEpoch(2*interval+1, 3), Deals: []abi.DealID{3}},
+	}
+	sort.Slice(expected, func(i, j int) bool { return expected[i].Epoch < expected[j].Epoch })
+	assert.Equal(t, expected, schedule)
+	assert.Equal(t, stIn.LastCron, stOut.LastCron)
+}
+
+func TestMarketMigrationSpreadsDealBacklog(t *testing.T) {
+	ctx := context.Background()
+	log := nv15.TestLogger{TB: t}
+	store := adt7.WrapStore(ctx, cbor.NewCborStore(ipld2.NewSyncBlockStoreInMemory()))
+
+	// Every deal was last processed at the same epoch, and scheduled by v6 the interval after.
+	// Cron has not yet reached them, but most deals' offsets following the epoch at which they were processed
+	// have passed.
+	interval := market7.DealUpdatesInterval()
+	const perEpoch = 3
+	dealCount := perEpoch * int(interval)
+	processed := 2*interval - 1
+	lastCron := 3*interval - 2
+
+	empty, err := market7.ConstructState(store)
+	require.NoError(t, err)
+	proposals, err := adt7.AsArray(store, empty.Proposals, market7.ProposalsAmtBitwidth)
+	require.NoError(t, err)
+	states, err := adt7.AsArray(store, empty.States, market7.StatesAmtBitwidth)
+	require.NoError(t, err)
+	dealOps, err := market7.AsSetMultimap(store, empty.DealOpsByEpoch, builtin7.DefaultHamtBitwidth, builtin7.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	for id := 0; id < dealCount; id++ {
+		require.NoError(t, proposals.Set(uint64(id), &market6.DealProposal{
+			PieceCID:             tutil.MakeCID("piece", &market7.PieceCIDPrefix),
+			PieceSize:            abi.PaddedPieceSize(2048),
+			Client:               tutil.NewIDAddr(t, 100),
+			Provider:             tutil.NewIDAddr(t, 101),
+			StartEpoch:           interval,
+			EndEpoch:             10 * interval,
+			StoragePricePerEpoch: big.NewInt(1),
+			ProviderCollateral:   big.NewInt(2),
+			ClientCollateral:     big.NewInt(3),
+		}))
+		require.NoError(t, states.Set(uint64(id), &market6.DealState{SectorStartEpoch: 1, LastUpdatedEpoch: processed, SlashEpoch: -1}))
+	}
+	ids := make([]abi.DealID, dealCount)
+	for i := range ids {
+		ids[i] = abi.DealID(i)
+	}
+	require.NoError(t, dealOps.PutMany(processed+interval, ids))
+
+	stIn := market6.State{
+		EscrowTable:                   empty.EscrowTable,
+		LockedTable:                   empty.LockedTable,
+		PendingProposals:              empty.PendingProposals,
+		NextID:                        abi.DealID(dealCount),
+		LastCron:                      lastCron,
+		TotalClientLockedCollateral:   big.Zero(),
+		TotalProviderLockedCollateral: big.Zero(),
+		TotalClientStorageFee:         big.Zero(),
+	}
+	stIn.Proposals, err = proposals.Root()
+	require.NoError(t, err)
+	stIn.States, err = states.Root()
+	require.NoError(t, err)
+	stIn.DealOpsByEpoch, err = dealOps.Root()
+	require.NoError(t, err)
+	headIn, err := store.Put(ctx, &stIn)
+	require.NoError(t, err)
+
+	tree, err := states6.NewTree(store)
+	require.NoError(t, err)
+	require.NoError(t, tree.SetActor(builtin7.StorageMarketActorAddr, &states6.Actor{
+		Code:    builtin6.StorageMarketActorCodeID,
+		Head:    headIn,
+		Balance: big.Zero(),
+	}))
+	rootIn, err := tree.Flush()
+	require.NoError(t, err)
+
+	rootOut, err := nv15.MigrateStateTree(ctx, store, rootIn, abi.ChainEpoch(0), nv15.Config{MaxWorkers: 1}, log, nv15.NewMemMigrationCache())
+	require.NoError(t, err)
+	treeOut, err := states7.LoadTree(store, rootOut)
+	require.NoError(t, err)
+	actor, found, err := treeOut.GetActor(builtin7.StorageMarketActorAddr)
+	require.NoError(t, err)
+	require.True(t, found)
+	r, err := market7.NewStateReader(store, actor.Head)
+	require.NoError(t, err)
+
+	// Nothing is left at or before the last cron, and every deal is scheduled once, at its offset in the interval
+	// following the last cron.
+	var stOut market7.State
+	require.NoError(t, store.Get(ctx, actor.Head, &stOut))
+	assert.Equal(t, lastCron, stOut.LastCron)
+	schedule, err := r.DealSchedule(0, lastCron+1)
+	require.NoError(t, err)
+	assert.Empty(t, schedule)
+	schedule, err = r.DealSchedule(lastCron+1, lastCron+1+interval)
+	require.NoError(t, err)
+	seen := make(map[abi.DealID]bool)
+	for _, epochDeals := range schedule {
+		// The backlog is spread over the interval, rather than all due at the first cron.
+		assert.LessOrEqual(t, len(epochDeals.Deals), 5*perEpoch, "too many deals at epoch %d", epochDeals.Epoch)
+		for _, id := range epochDeals.Deals {
+			assert.Equal(t, market7.GenRandNextEpoch(lastCron+1, id), epochDeals.Epoch)
+			assert.False(t, seen[id], "deal %d scheduled twice", id)
+			seen[id] = true
+		}
+	}
+	assert.Len(t, seen, dealCount)
+	assert.GreaterOrEqual(t, len(schedule), int(interval)*9/10)
+}
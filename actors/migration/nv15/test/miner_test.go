@@ -0,0 +1,194 @@
+package test_test
+
+import (
+	"context"
+	"testing"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	ipld2 "github.com/filecoin-project/specs-actors/v2/support/ipld"
+	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
+	miner6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/miner"
+	states6 "github.com/filecoin-project/specs-actors/v6/actors/states"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	miner7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/nv15"
+	states7 "github.com/filecoin-project/specs-actors/v7/actors/states"
+	adt7 "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestMinerMigration(t *testing.T) {
+	ctx := context.Background()
+	log := nv15.TestLogger{TB: t}
+	store := adt7.WrapStore(ctx, cbor.NewCborStore(ipld2.NewSyncBlockStoreInMemory()))
+
+	owner := tutil.NewIDAddr(t, 100)
+	worker := tutil.NewIDAddr(t, 101)
+	pendingOwner := tutil.NewIDAddr(t, 102)
+	infoIn := miner6.MinerInfo{
+		Owner:                      owner,
+		Worker:                     worker,
+		ControlAddresses:           []addr.Address{tutil.NewIDAddr(t, 104)},
+		PendingWorkerKey:           &miner6.WorkerKeyChange{NewWorker: tutil.NewIDAddr(t, 103), EffectiveAt: 10},
+		PeerId:                     abi.PeerID("peer"),
+		Multiaddrs:                 []abi.Multiaddrs{},
+		WindowPoStProofType:        abi.RegisteredPoStProof_StackedDrgWindow32GiBV1,
+		SectorSize:                 abi.SectorSize(32 << 30),
+		WindowPoStPartitionSectors: 2349,
+		ConsensusFaultElapsed:      -1,
+		PendingOwnerAddress:        &pendingOwner,
+	}
+	infoCid, err := store.Put(ctx, &infoIn)
+	require.NoError(t, err)
+	// The empty collections have the same layout in v6 and v7.
+	empty, err := miner7.ConstructState(store, infoCid, 0, 0)
+	require.NoError(t, err)
+	// One deadline has two partitions, the rest are empty.
+	emptyDeadline, err := miner7.ConstructDeadline(store)
+	require.NoError(t, err)
+	partitions, err := adt7.MakeEmptyArray(store, miner7.DeadlinePartitionsAmtBitwidth)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		partition, err := miner7.ConstructPartition(store)
+		require.NoError(t, err)
+		require.NoError(t, partitions.AppendContinuous(partition))
+	}
+	partitionsRoot, err := partitions.Root()
+	require.NoError(t, err)
+	deadlineIn := func(partitions cid.Cid) miner6.Deadline {
+		return miner6.Deadline{
+			Partitions:                        partitions,
+			ExpirationsEpochs:                 emptyDeadline.ExpirationsEpochs,
+			PartitionsPoSted:                  bitfield.New(),
+			EarlyTerminations:                 bitfield.New(),
+			FaultyPower:                       miner6.PowerPair{Raw: big.Zero(), QA: big.Zero()},
+			OptimisticPoStSubmissions:         emptyDeadline.OptimisticPoStSubmissions,
+			PartitionsSnapshot:                emptyDeadline.PartitionsSnapshot,
+			OptimisticPoStSubmissionsSnapshot: emptyDeadline.OptimisticPoStSubmissionsSnapshot,
+		}
+	}
+	emptyDeadlineIn := deadlineIn(emptyDeadline.Partitions)
+	emptyDeadlineInCid, err := store.Put(ctx, &emptyDeadlineIn)
+	require.NoError(t, err)
+	fullDeadlineIn := deadlineIn(partitionsRoot)
+	fullDeadlineInCid, err := store.Put(ctx, &fullDeadlineIn)
+	require.NoError(t, err)
+	deadlinesIn := miner6.Deadlines{}
+	for i := range deadlinesIn.Due {
+		deadlinesIn.Due[i] = emptyDeadlineInCid
+	}
+	deadlinesIn.Due[3] = fullDeadlineInCid
+	deadlinesInCid, err := store.Put(ctx, &deadlinesIn)
+	require.NoError(t, err)
+
+	stIn := miner6.State{
+		Info:                       infoCid,
+		PreCommitDeposits:          big.NewInt(1),
+		LockedFunds:                big.NewInt(2),
+		VestingFunds:               empty.VestingFunds,
+		FeeDebt:                    big.NewInt(3),
+		InitialPledge:              big.NewInt(4),
+		PreCommittedSectors:        empty.PreCommittedSectors,
+		PreCommittedSectorsCleanUp: empty.PreCommittedSectorsCleanUp,
+		AllocatedSectors:           empty.AllocatedSectors,
+		Sectors:                    empty.Sectors,
+		ProvingPeriodStart:         5,
+		CurrentDeadline:            6,
+		Deadlines:                  deadlinesInCid,
+		EarlyTerminations:          bitfield.NewFromSet([]uint64{7}),
+		DeadlineCronActive:         true,
+	}
+	headIn, err := store.Put(ctx, &stIn)
+	require.NoError(t, err)
+
+	tree, err := states6.NewTree(store)
+	require.NoError(t, err)
+	minerAddr := tutil.NewIDAddr(t, 1000)
+	require.NoError(t, tree.SetActor(minerAddr, &states6.Actor{
+		Code:    builtin6.StorageMinerActorCodeID,
+		Head:    headIn,
+		Balance: big.NewInt(1e18),
+	}))
+	rootIn, err := tree.Flush()
+	require.NoError(t, err)
+
+	rootOut, err := nv15.MigrateStateTree(ctx, store, rootIn, abi.ChainEpoch(0), nv15.Config{MaxWorkers: 1}, log, nv15.NewMemMigrationCache())
+	require.NoError(t, err)
+
+	treeOut, err := states7.LoadTree(store, rootOut)
+	require.NoError(t, err)
+	actor, found, err := treeOut.GetActor(minerAddr)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, builtin7.StorageMinerActorCodeID, actor.Code)
+	assert.Equal(t, big.NewInt(1e18), actor.Balance)
+
+	var stOut miner7.State
+	require.NoError(t, store.Get(ctx, actor.Head, &stOut))
+	infoOut, err := stOut.GetInfo(store)
+	require.NoError(t, err)
+	assert.Equal(t, owner, infoOut.Owner)
+	assert.Equal(t, worker, infoOut.Worker)
+	assert.Equal(t, infoIn.ControlAddresses, infoOut.ControlAddresses)
+	assert.Equal(t, []miner7.ControlPermissions{miner7.AllControlPermissions}, infoOut.ControlPermissions)
+	assert.Equal(t, []miner7.WorkerKeyChange{{NewWorker: infoIn.PendingWorkerKey.NewWorker, EffectiveAt: 10}}, infoOut.PendingWorkerKeys)
+	assert.Equal(t, infoIn.PeerId, infoOut.PeerId)
+	assert.Equal(t, infoIn.WindowPoStProofType, infoOut.WindowPoStProofType)
+	assert.Equal(t, infoIn.SectorSize, infoOut.SectorSize)
+	assert.Equal(t, &pendingOwner, infoOut.PendingOwnerAddress)
+	assert.Equal(t, owner, infoOut.Beneficiary)
+	assert.True(t, infoOut.BeneficiaryTerm.Quota.IsZero())
+	assert.True(t, infoOut.BeneficiaryTerm.UsedQuota.IsZero())
+	assert.Equal(t, abi.ChainEpoch(0), infoOut.BeneficiaryTerm.Expiration)
+	assert.Nil(t, infoOut.PendingBeneficiaryTerm)
+
+	// Apart from the info, the state is carried over and the statistics start at zero.
+	assert.Equal(t, miner7.SectorStats{}, stOut.Stats)
+	assert.Equal(t, stIn.PreCommitDeposits, stOut.PreCommitDeposits)
+	assert.Equal(t, stIn.LockedFunds, stOut.LockedFunds)
+	assert.Equal(t, stIn.VestingFunds, stOut.VestingFunds)
+	assert.Equal(t, stIn.FeeDebt, stOut.FeeDebt)
+	assert.Equal(t, stIn.InitialPledge, stOut.InitialPledge)
+	assert.Equal(t, stIn.PreCommittedSectors, stOut.PreCommittedSectors)
+	assert.Equal(t, stIn.PreCommittedSectorsCleanUp, stOut.PreCommittedSectorsCleanUp)
+	assert.Equal(t, stIn.AllocatedSectors, stOut.AllocatedSectors)
+	assert.Equal(t, stIn.Sectors, stOut.Sectors)
+	assert.Equal(t, stIn.ProvingPeriodStart, stOut.ProvingPeriodStart)
+	assert.Equal(t, stIn.CurrentDeadline, stOut.CurrentDeadline)
+	assert.Equal(t, stIn.DeadlineCronActive, stOut.DeadlineCronActive)
+	earlyTerminations, err := stOut.EarlyTerminations.All(miner7.WPoStPeriodDeadlines())
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{7}, earlyTerminations)
+
+	// All partitions are marked dirty, and the deadlines are otherwise unchanged.
+	deadlinesOut, err := stOut.LoadDeadlines(store)
+	require.NoError(t, err)
+	require.NoError(t, deadlinesOut.ForEach(store, func(dlIdx uint64, dl *miner7.Deadline) error {
+		expectedPartitions := emptyDeadline.Partitions
+		var expectedDirty []uint64
+		if dlIdx == 3 {
+			expectedPartitions = partitionsRoot
+			expectedDirty = []uint64{0, 1}
+		}
+		assert.Equal(t, expectedPartitions, dl.Partitions)
+		dirty, err := dl.DirtyPartitions.All(miner7.WPoStPeriodDeadlines())
+		require.NoError(t, err)
+		if expectedDirty == nil {
+			assert.Empty(t, dirty)
+		} else {
+			assert.Equal(t, expectedDirty, dirty)
+		}
+		assert.Equal(t, emptyDeadline.ExpirationsEpochs, dl.ExpirationsEpochs)
+		assert.Equal(t, emptyDeadline.OptimisticPoStSubmissions, dl.OptimisticPoStSubmissions)
+		assert.True(t, dl.FaultyPower.IsZero())
+		return nil
+	}))
+}
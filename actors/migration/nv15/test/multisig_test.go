@@ -0,0 +1,104 @@
+package test_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	ipld2 "github.com/filecoin-project/specs-actors/v2/support/ipld"
+	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
+	multisig6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/multisig"
+	states6 "github.com/filecoin-project/specs-actors/v6/actors/states"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	multisig7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/multisig"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/nv15"
+	states7 "github.com/filecoin-project/specs-actors/v7/actors/states"
+	adt7 "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestMultisigMigration(t *testing.T) {
+	ctx := context.Background()
+	log := nv15.TestLogger{TB: t}
+	store := adt7.WrapStore(ctx, cbor.NewCborStore(ipld2.NewSyncBlockStoreInMemory()))
+	msigAddr := tutil.NewIDAddr(t, 1000)
+	signer := tutil.NewIDAddr(t, 1001)
+
+	txns, err := adt7.MakeEmptyMap(store, builtin7.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	txnIn := multisig6.Transaction{
+		To:       tutil.NewIDAddr(t, 1002),
+		Value:    abi.NewTokenAmount(100),
+		Method:   builtin7.MethodSend,
+		Params:   []byte{1, 2, 3},
+		Approved: []address.Address{signer},
+	}
+	require.NoError(t, txns.Put(multisig6.TxnID(3), &txnIn))
+	txnsRoot, err := txns.Root()
+	require.NoError(t, err)
+
+	stIn := multisig6.State{
+		Signers:               []address.Address{signer},
+		NumApprovalsThreshold: 1,
+		NextTxnID:             4,
+		InitialBalance:        big.Zero(),
+		PendingTxns:           txnsRoot,
+	}
+	headIn, err := store.Put(ctx, &stIn)
+	require.NoError(t, err)
+
+	tree, err := states6.NewTree(store)
+	require.NoError(t, err)
+	require.NoError(t, tree.SetActor(msigAddr, &states6.Actor{
+		Code:    builtin6.MultisigActorCodeID,
+		Head:    headIn,
+		Balance: big.Zero(),
+	}))
+	rootIn, err := tree.Flush()
+	require.NoError(t, err)
+
+	rootOut, err := nv15.MigrateStateTree(ctx, store, rootIn, abi.ChainEpoch(0), nv15.Config{MaxWorkers: 1}, log, nv15.NewMemMigrationCache())
+	require.NoError(t, err)
+
+	treeOut, err := states7.LoadTree(store, rootOut)
+	require.NoError(t, err)
+	actor, found, err := treeOut.GetActor(msigAddr)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, builtin7.MultisigActorCodeID, actor.Code)
+
+	var stOut multisig7.State
+	require.NoError(t, store.Get(ctx, actor.Head, &stOut))
+	assert.Equal(t, stIn.Signers, stOut.Signers)
+	assert.Equal(t, stIn.NumApprovalsThreshold, stOut.NumApprovalsThreshold)
+	assert.Empty(t, stOut.SignerWeights)
+	assert.Equal(t, abi.ChainEpoch(0), stOut.SpendingPeriod)
+	assert.Equal(t, big.Zero(), stOut.SpendingLimit)
+	assert.Equal(t, stIn.NextTxnID, stOut.NextTxnID)
+
+	// The pending transaction is retained, and does not expire.
+	txnsOut, err := adt7.AsMap(store, stOut.PendingTxns, builtin7.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	var txnOut multisig7.Transaction
+	found, err = txnsOut.Get(multisig7.TxnID(3), &txnOut)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, multisig7.Transaction{
+		To:         txnIn.To,
+		Value:      txnIn.Value,
+		Method:     txnIn.Method,
+		Params:     txnIn.Params,
+		Approved:   txnIn.Approved,
+		Expiration: 0,
+	}, txnOut)
+	assert.False(t, txnOut.IsExpired(abi.ChainEpoch(1<<40)))
+
+	_, acc := multisig7.CheckStateInvariants(&stOut, store)
+	assert.True(t, acc.IsEmpty(), acc.Messages())
+}
@@ -0,0 +1,84 @@
+package test_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	ipld2 "github.com/filecoin-project/specs-actors/v2/support/ipld"
+	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
+	paych6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/paych"
+	states6 "github.com/filecoin-project/specs-actors/v6/actors/states"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	paych7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/paych"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/nv15"
+	states7 "github.com/filecoin-project/specs-actors/v7/actors/states"
+	adt7 "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestPaychMigration(t *testing.T) {
+	ctx := context.Background()
+	log := nv15.TestLogger{TB: t}
+	store := adt7.WrapStore(ctx, cbor.NewCborStore(ipld2.NewSyncBlockStoreInMemory()))
+	paychAddr := tutil.NewIDAddr(t, 1000)
+	balance := abi.NewTokenAmount(100)
+
+	lanes, err := adt7.MakeEmptyArray(store, paych7.LaneStatesAmtBitwidth)
+	require.NoError(t, err)
+	require.NoError(t, lanes.Set(2, &paych6.LaneState{Redeemed: abi.NewTokenAmount(10), Nonce: 3}))
+	lanesRoot, err := lanes.Root()
+	require.NoError(t, err)
+
+	stIn := paych6.State{
+		From:            tutil.NewIDAddr(t, 1001),
+		To:              tutil.NewIDAddr(t, 1002),
+		ToSend:          abi.NewTokenAmount(10),
+		SettlingAt:      200,
+		MinSettleHeight: 150,
+		LaneStates:      lanesRoot,
+	}
+	headIn, err := store.Put(ctx, &stIn)
+	require.NoError(t, err)
+
+	tree, err := states6.NewTree(store)
+	require.NoError(t, err)
+	require.NoError(t, tree.SetActor(paychAddr, &states6.Actor{
+		Code:    builtin6.PaymentChannelActorCodeID,
+		Head:    headIn,
+		Balance: balance,
+	}))
+	rootIn, err := tree.Flush()
+	require.NoError(t, err)
+
+	rootOut, err := nv15.MigrateStateTree(ctx, store, rootIn, abi.ChainEpoch(0), nv15.Config{MaxWorkers: 1}, log, nv15.NewMemMigrationCache())
+	require.NoError(t, err)
+
+	treeOut, err := states7.LoadTree(store, rootOut)
+	require.NoError(t, err)
+	actor, found, err := treeOut.GetActor(paychAddr)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, builtin7.PaymentChannelActorCodeID, actor.Code)
+	assert.Equal(t, balance, actor.Balance)
+
+	// The channel is retained, with no watcher.
+	var stOut paych7.State
+	require.NoError(t, store.Get(ctx, actor.Head, &stOut))
+	assert.Equal(t, paych7.State{
+		From:            stIn.From,
+		To:              stIn.To,
+		Watcher:         nil,
+		ToSend:          stIn.ToSend,
+		SettlingAt:      stIn.SettlingAt,
+		MinSettleHeight: stIn.MinSettleHeight,
+		LaneStates:      stIn.LaneStates,
+	}, stOut)
+
+	_, acc := paych7.CheckStateInvariants(&stOut, store, balance)
+	assert.True(t, acc.IsEmpty(), acc.Messages())
+}
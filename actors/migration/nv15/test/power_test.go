@@ -0,0 +1,128 @@
+package test_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	ipld2 "github.com/filecoin-project/specs-actors/v2/support/ipld"
+	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
+	power6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/power"
+	states6 "github.com/filecoin-project/specs-actors/v6/actors/states"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	power7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/power"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/nv15"
+	states7 "github.com/filecoin-project/specs-actors/v7/actors/states"
+	adt7 "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestPowerMigration(t *testing.T) {
+	ctx := context.Background()
+	log := nv15.TestLogger{TB: t}
+	store := adt7.WrapStore(ctx, cbor.NewCborStore(ipld2.NewSyncBlockStoreInMemory()))
+
+	empty, err := power7.ConstructState(store)
+	require.NoError(t, err)
+	miner := tutil.NewIDAddr(t, 1000)
+	claimsIn, err := adt7.AsMap(store, empty.Claims, builtin7.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	claimIn := power6.Claim{
+		WindowPoStProofType: abi.RegisteredPoStProof_StackedDrgWindow32GiBV1,
+		RawBytePower:        big.NewInt(9),
+		QualityAdjPower:     big.NewInt(10),
+	}
+	require.NoError(t, claimsIn.Put(abi.AddrKey(miner), &claimIn))
+	claimsRoot, err := claimsIn.Root()
+	require.NoError(t, err)
+	eventsIn, err := adt7.AsMultimap(store, empty.CronEventQueue, power6.CronQueueHamtBitwidth, power6.CronQueueAmtBitwidth)
+	require.NoError(t, err)
+	require.NoError(t, eventsIn.Add(abi.IntKey(9), &power6.CronEvent{MinerAddr: miner, CallbackPayload: []byte{0x1}}))
+	require.NoError(t, eventsIn.Add(abi.IntKey(9), &power6.CronEvent{MinerAddr: miner, CallbackPayload: []byte{0x2}}))
+	require.NoError(t, eventsIn.Add(abi.IntKey(12), &power6.CronEvent{MinerAddr: miner, CallbackPayload: []byte{0x3}}))
+	eventsRoot, err := eventsIn.Root()
+	require.NoError(t, err)
+
+	stIn := power6.State{
+		TotalRawBytePower:         big.NewInt(1),
+		TotalBytesCommitted:       big.NewInt(2),
+		TotalQualityAdjPower:      big.NewInt(3),
+		TotalQABytesCommitted:     big.NewInt(4),
+		TotalPledgeCollateral:     big.NewInt(5),
+		ThisEpochRawBytePower:     big.NewInt(1),
+		ThisEpochQualityAdjPower:  big.NewInt(3),
+		ThisEpochPledgeCollateral: big.NewInt(5),
+		MinerCount:                6,
+		MinerAboveMinPowerCount:   7,
+		CronEventQueue:            eventsRoot,
+		FirstCronEpoch:            8,
+		Claims:                    claimsRoot,
+	}
+	headIn, err := store.Put(ctx, &stIn)
+	require.NoError(t, err)
+
+	tree, err := states6.NewTree(store)
+	require.NoError(t, err)
+	require.NoError(t, tree.SetActor(builtin7.StoragePowerActorAddr, &states6.Actor{
+		Code:    builtin6.StoragePowerActorCodeID,
+		Head:    headIn,
+		Balance: big.Zero(),
+	}))
+	rootIn, err := tree.Flush()
+	require.NoError(t, err)
+
+	rootOut, err := nv15.MigrateStateTree(ctx, store, rootIn, abi.ChainEpoch(0), nv15.Config{MaxWorkers: 1}, log, nv15.NewMemMigrationCache())
+	require.NoError(t, err)
+
+	treeOut, err := states7.LoadTree(store, rootOut)
+	require.NoError(t, err)
+	actor, found, err := treeOut.GetActor(builtin7.StoragePowerActorAddr)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, builtin7.StoragePowerActorCodeID, actor.Code)
+
+	var stOut power7.State
+	require.NoError(t, store.Get(ctx, actor.Head, &stOut))
+	assert.Equal(t, stIn.TotalRawBytePower, stOut.TotalRawBytePower)
+	assert.Equal(t, stIn.TotalQABytesCommitted, stOut.TotalQABytesCommitted)
+	assert.Equal(t, stIn.ThisEpochPledgeCollateral, stOut.ThisEpochPledgeCollateral)
+	assert.Equal(t, stIn.MinerAboveMinPowerCount, stOut.MinerAboveMinPowerCount)
+	assert.Equal(t, stIn.FirstCronEpoch, stOut.FirstCronEpoch)
+
+	// Claims gain an empty record of consensus faults.
+	claimOut, found, err := stOut.GetClaim(store, miner)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, power7.Claim{
+		WindowPoStProofType: claimIn.WindowPoStProofType,
+		RawBytePower:        claimIn.RawBytePower,
+		QualityAdjPower:     claimIn.QualityAdjPower,
+	}, *claimOut)
+
+	// The power state gains an empty buffer of snapshots.
+	r, err := power7.NewStateReader(store, actor.Head)
+	require.NoError(t, err)
+	snapshots, err := r.PowerSnapshots()
+	require.NoError(t, err)
+	assert.Empty(t, snapshots)
+
+	// Cron events are kept at their epochs, in order, as priority events.
+	queue, err := r.CronEvents(0, 20)
+	require.NoError(t, err)
+	assert.Equal(t, []power7.EpochCronEvents{
+		{Epoch: 9, Events: []power7.CronEvent{
+			{MinerAddr: miner, CallbackPayload: []byte{0x1}, Priority: true},
+			{MinerAddr: miner, CallbackPayload: []byte{0x2}, Priority: true},
+		}},
+		{Epoch: 12, Events: []power7.CronEvent{{MinerAddr: miner, CallbackPayload: []byte{0x3}, Priority: true}}},
+	}, queue)
+
+	acc := &builtin7.MessageAccumulator{}
+	power7.CheckPowerSnapshotInvariants(&stOut, store, acc)
+	assert.True(t, acc.IsEmpty(), acc.Messages())
+}
@@ -0,0 +1,39 @@
+package test_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	ipld2 "github.com/filecoin-project/specs-actors/v2/support/ipld"
+	vm6 "github.com/filecoin-project/specs-actors/v6/support/vm"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/nv15"
+	adt7 "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+func TestPreMigrationMatchesMigration(t *testing.T) {
+	ctx := context.Background()
+	log := nv15.TestLogger{TB: t}
+	bs := ipld2.NewSyncBlockStoreInMemory()
+	vm := vm6.NewVMWithSingletons(ctx, t, bs)
+	adtStore := adt7.WrapStore(ctx, cbor.NewCborStore(bs))
+	startRoot := vm.StateRoot()
+	cfg := nv15.Config{MaxWorkers: 2}
+
+	expectedRoot, err := nv15.MigrateStateTree(ctx, adtStore, startRoot, abi.ChainEpoch(0), cfg, log, nv15.NewMemMigrationCache())
+	require.NoError(t, err)
+
+	// Pre-migrate into a cache, then migrate with that cache.
+	cache := nv15.NewMemMigrationCache()
+	require.NoError(t, nv15.PreMigrateStateTree(ctx, adtStore, startRoot, abi.ChainEpoch(0), cfg, log, cache))
+	actualRoot, err := nv15.MigrateStateTree(ctx, adtStore, startRoot, abi.ChainEpoch(0), cfg, log, cache)
+	require.NoError(t, err)
+	assert.Equal(t, expectedRoot, actualRoot)
+
+	// Pre-migration rejects an invalid config.
+	require.Error(t, nv15.PreMigrateStateTree(ctx, adtStore, startRoot, abi.ChainEpoch(0), nv15.Config{}, log, cache))
+}
@@ -0,0 +1,52 @@
+package test_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	ipld2 "github.com/filecoin-project/specs-actors/v2/support/ipld"
+	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
+	vm6 "github.com/filecoin-project/specs-actors/v6/support/vm"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/nv15"
+	adt7 "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// A store which rejects writes.
+type readOnlyStore struct {
+	cbor.IpldStore
+}
+
+func (readOnlyStore) Put(context.Context, interface{}) (cid.Cid, error) {
+	return cid.Undef, xerrors.Errorf("store is read-only")
+}
+
+func TestMigrateStateTreeStaged(t *testing.T) {
+	ctx := context.Background()
+	log := nv15.TestLogger{TB: t}
+	bs := ipld2.NewSyncBlockStoreInMemory()
+	vm := vm6.NewVMWithSingletons(ctx, t, bs)
+	// Trigger cron to bring the reward actor state up to date with the epoch.
+	vm6.ApplyOk(t, vm, builtin6.SystemActorAddr, builtin6.CronActorAddr, big.Zero(), builtin6.MethodsCron.EpochTick, nil)
+	store := adt7.WrapStore(ctx, cbor.NewCborStore(bs))
+	startRoot := vm.StateRoot()
+
+	// Writes to the input store fail, so the migration must write only to the output store.
+	input := readOnlyStore{store}
+	output := adt7.WrapStore(ctx, cbor.NewCborStore(ipld2.NewSyncBlockStoreInMemory()))
+	root, _, err := nv15.MigrateStateTreeStaged(ctx, input, output, startRoot, abi.ChainEpoch(0), nv15.Config{MaxWorkers: 2}, log, nv15.NewMemMigrationCache())
+	require.NoError(t, err)
+	require.NoError(t, nv15.ValidateMigration(ctx, engine.NewStagingStore(input, output), startRoot, root, abi.ChainEpoch(0)))
+
+	expected, err := nv15.MigrateStateTree(ctx, store, startRoot, abi.ChainEpoch(0), nv15.Config{MaxWorkers: 2}, log, nv15.NewMemMigrationCache())
+	require.NoError(t, err)
+	assert.Equal(t, expected, root)
+}
@@ -0,0 +1,52 @@
+package test_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	ipld2 "github.com/filecoin-project/specs-actors/v2/support/ipld"
+	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
+	vm6 "github.com/filecoin-project/specs-actors/v6/support/vm"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/nv15"
+	states7 "github.com/filecoin-project/specs-actors/v7/actors/states"
+	adt7 "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestValidateMigration(t *testing.T) {
+	ctx := context.Background()
+	log := nv15.TestLogger{TB: t}
+	bs := ipld2.NewSyncBlockStoreInMemory()
+	vm := vm6.NewVMWithSingletons(ctx, t, bs)
+	// Trigger cron to bring the reward actor state up to date with the epoch.
+	vm6.ApplyOk(t, vm, builtin6.SystemActorAddr, builtin6.CronActorAddr, big.Zero(), builtin6.MethodsCron.EpochTick, nil)
+	adtStore := adt7.WrapStore(ctx, cbor.NewCborStore(bs))
+	startRoot := vm.StateRoot()
+
+	root, err := nv15.MigrateStateTree(ctx, adtStore, startRoot, abi.ChainEpoch(0), nv15.Config{MaxWorkers: 2}, log, nv15.NewMemMigrationCache())
+	require.NoError(t, err)
+	require.NoError(t, nv15.ValidateMigration(ctx, adtStore, startRoot, root, abi.ChainEpoch(0)))
+
+	// Add an actor with an unknown code CID and an unbacked balance to the output.
+	tree, err := states7.LoadTree(adtStore, root)
+	require.NoError(t, err)
+	require.NoError(t, tree.SetActor(tutil.NewIDAddr(t, 1000), &states7.Actor{
+		Code:    tutil.MakeCID("bogus", nil),
+		Head:    builtin.AccountActorCodeID,
+		Balance: big.NewInt(1),
+	}))
+	badRoot, err := tree.Flush()
+	require.NoError(t, err)
+
+	err = nv15.ValidateMigration(ctx, adtStore, startRoot, badRoot, abi.ChainEpoch(0))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "total balance changed")
+	assert.Contains(t, err.Error(), "non-v7 code")
+}
@@ -0,0 +1,86 @@
+package test_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	ipld2 "github.com/filecoin-project/specs-actors/v2/support/ipld"
+	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
+	verifreg6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/verifreg"
+	states6 "github.com/filecoin-project/specs-actors/v6/actors/states"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	verifreg7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/verifreg"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/nv15"
+	states7 "github.com/filecoin-project/specs-actors/v7/actors/states"
+	adt7 "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+)
+
+func TestVerifregMigration(t *testing.T) {
+	ctx := context.Background()
+	log := nv15.TestLogger{TB: t}
+	store := adt7.WrapStore(ctx, cbor.NewCborStore(ipld2.NewSyncBlockStoreInMemory()))
+
+	verifiers, err := adt7.MakeEmptyMap(store, builtin7.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	allowance := big.NewInt(1 << 30)
+	require.NoError(t, verifiers.Put(abi.AddrKey(tutil.NewIDAddr(t, 1000)), &allowance))
+	verifiersRoot, err := verifiers.Root()
+	require.NoError(t, err)
+	empty, err := adt7.StoreEmptyMap(store, builtin7.DefaultHamtBitwidth)
+	require.NoError(t, err)
+
+	stIn := verifreg6.State{
+		RootKey:         tutil.NewIDAddr(t, 80),
+		Verifiers:       verifiersRoot,
+		VerifiedClients: empty,
+	}
+	headIn, err := store.Put(ctx, &stIn)
+	require.NoError(t, err)
+
+	tree, err := states6.NewTree(store)
+	require.NoError(t, err)
+	require.NoError(t, tree.SetActor(builtin7.VerifiedRegistryActorAddr, &states6.Actor{
+		Code:    builtin6.VerifiedRegistryActorCodeID,
+		Head:    headIn,
+		Balance: big.Zero(),
+	}))
+	rootIn, err := tree.Flush()
+	require.NoError(t, err)
+
+	rootOut, err := nv15.MigrateStateTree(ctx, store, rootIn, abi.ChainEpoch(0), nv15.Config{MaxWorkers: 1}, log, nv15.NewMemMigrationCache())
+	require.NoError(t, err)
+
+	treeOut, err := states7.LoadTree(store, rootOut)
+	require.NoError(t, err)
+	actor, found, err := treeOut.GetActor(builtin7.VerifiedRegistryActorAddr)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, builtin7.VerifiedRegistryActorCodeID, actor.Code)
+
+	// The state gains empty verifier sub-keys, DataCap removal proposal IDs, verifier allocations and grant events.
+	// No client's DataCap expires.
+	var stOut verifreg7.State
+	require.NoError(t, store.Get(ctx, actor.Head, &stOut))
+	emptyEvents, err := adt7.StoreEmptyArray(store, verifreg7.GrantEventsAmtBitwidth)
+	require.NoError(t, err)
+	assert.Equal(t, verifreg7.State{
+		RootKey:                  stIn.RootKey,
+		Verifiers:                stIn.Verifiers,
+		VerifiedClients:          stIn.VerifiedClients,
+		VerifierSubKeys:          empty,
+		ClientExpirations:        empty,
+		RemoveDataCapProposalIDs: empty,
+		VerifierAllocations:      empty,
+		GrantEvents:              emptyEvents,
+	}, stOut)
+
+	_, acc := verifreg7.CheckStateInvariants(&stOut, store)
+	assert.True(t, acc.IsEmpty(), acc.Messages())
+}
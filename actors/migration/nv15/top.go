@@ -3,13 +3,9 @@ package nv15
 import (
 	"context"
 	"fmt"
-	"sync"
-	"sync/atomic"
-	"time"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
-	"github.com/filecoin-project/go-state-types/rt"
 	builtin6 "github.com/filecoin-project/specs-actors/v6/actors/builtin"
 	states6 "github.com/filecoin-project/specs-actors/v6/actors/states"
 	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
@@ -18,65 +14,59 @@ import (
 
 	"github.com/ipfs/go-cid"
 	cbor "github.com/ipfs/go-ipld-cbor"
-	"golang.org/x/sync/errgroup"
-	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
 )
 
 // Config parameterizes a state tree migration
-type Config struct {
-	// Number of migration worker goroutines to run.
-	// More workers enables higher CPU utilization doing migration computations (including state encoding)
-	MaxWorkers uint
-	// Capacity of the queue of jobs available to workers (zero for unbuffered).
-	// A queue length of hundreds to thousands improves throughput at the cost of memory.
-	JobQueueSize uint
-	// Capacity of the queue receiving migration results from workers, for persisting (zero for unbuffered).
-	// A queue length of tens to hundreds improves throughput at the cost of memory.
-	ResultQueueSize uint
-	// Time between progress logs to emit.
-	// Zero (the default) results in no progress logs.
-	ProgressLogPeriod time.Duration
-}
+type Config = engine.Config
+
+type Logger = engine.Logger
+
+// MigrationCache stores and loads cached data. Its implementation must be threadsafe
+type MigrationCache = engine.MigrationCache
+
+type MemMigrationCache = engine.MemMigrationCache
+
+type TestLogger = engine.TestLogger
 
-type Logger interface {
-	// This is the same logging interface provided by the Runtime
-	Log(level rt.LogLevel, msg string, args ...interface{})
+func NewMemMigrationCache() *MemMigrationCache {
+	return engine.NewMemMigrationCache()
 }
 
+// Identifies this migration's code in cache keys. Change it whenever an actor migration changes its output,
+// so that caches populated by earlier builds are not reused.
+const cacheVersion = "nv15-15"
+
+// Returns the key under which this migration caches the migrated head of an actor.
 func ActorHeadKey(addr address.Address, head cid.Cid) string {
-	return addr.String() + "-h-" + head.String()
+	return engine.VersionedActorHeadKey(cacheVersion, addr, head)
 }
 
-// Migrates from v13 to v14
+// Migrates from v14 to v15
 //
-// This migration only updates the actor code CIDs in the state tree.
-// MigrationCache stores and loads cached data. Its implementation must be threadsafe
-type MigrationCache interface {
-	Write(key string, newCid cid.Cid) error
-	Read(key string) (bool, cid.Cid, error)
-	Load(key string, loadFunc func() (cid.Cid, error)) (cid.Cid, error)
-}
-
-// Migrates the filecoin state tree starting from the global state tree and upgrading all actor state.
-// The store must support concurrent writes (even if the configured worker count is 1).
-func MigrateStateTree(ctx context.Context, store cbor.IpldStore, actorsRootIn cid.Cid, priorEpoch abi.ChainEpoch, cfg Config, log Logger, cache MigrationCache) (cid.Cid, error) {
-	if cfg.MaxWorkers <= 0 {
-		return cid.Undef, xerrors.Errorf("invalid migration config with %d workers", cfg.MaxWorkers)
-	}
-
+// This migration updates the actor code CIDs in the state tree, adds a beneficiary to each miner's info,
+// migrates deal labels which are not valid UTF-8 to bytes labels, records the datacap consumed by each deal,
+// reschedules deals which were processed late at their offsets within the market's update interval,
+// adds a ring buffer of power snapshots to the power state, adds a record of consensus faults to each power claim,
+// adds verifier sub-keys, the IDs of DataCap removal proposals, verifier allocations, a log of grants and
+// client DataCap expirations to the verified registry state, adds signer weights and a spending limit to the
+// multisig state and an expiration to each pending multisig transaction, and adds a watcher to the payment
+// channel state.
+func migration() *engine.Migration {
 	// Maps prior version code CIDs to migration functions.
-	var migrations = map[cid.Cid]actorMigration{
-		builtin6.AccountActorCodeID:          nilMigrator{builtin7.AccountActorCodeID},
-		builtin6.CronActorCodeID:             nilMigrator{builtin7.CronActorCodeID},
-		builtin6.InitActorCodeID:             nilMigrator{builtin7.InitActorCodeID},
-		builtin6.MultisigActorCodeID:         nilMigrator{builtin7.MultisigActorCodeID},
-		builtin6.PaymentChannelActorCodeID:   nilMigrator{builtin7.PaymentChannelActorCodeID},
-		builtin6.RewardActorCodeID:           nilMigrator{builtin7.RewardActorCodeID},
-		builtin6.StorageMarketActorCodeID:    nilMigrator{builtin7.StorageMarketActorCodeID},
-		builtin6.StorageMinerActorCodeID:     nilMigrator{builtin7.StorageMinerActorCodeID},
-		builtin6.StoragePowerActorCodeID:     nilMigrator{builtin7.StoragePowerActorCodeID},
-		builtin6.SystemActorCodeID:           nilMigrator{builtin7.SystemActorCodeID},
-		builtin6.VerifiedRegistryActorCodeID: nilMigrator{builtin7.VerifiedRegistryActorCodeID},
+	var migrations = map[cid.Cid]engine.ActorMigration{
+		builtin6.AccountActorCodeID:          engine.CodeMigrator{OutCodeCID: builtin7.AccountActorCodeID},
+		builtin6.CronActorCodeID:             engine.CodeMigrator{OutCodeCID: builtin7.CronActorCodeID},
+		builtin6.InitActorCodeID:             engine.CodeMigrator{OutCodeCID: builtin7.InitActorCodeID},
+		builtin6.MultisigActorCodeID:         multisigMigrator{},
+		builtin6.PaymentChannelActorCodeID:   paychMigrator{},
+		builtin6.RewardActorCodeID:           engine.CodeMigrator{OutCodeCID: builtin7.RewardActorCodeID},
+		builtin6.StorageMarketActorCodeID:    marketMigrator{},
+		builtin6.StorageMinerActorCodeID:     minerMigrator{},
+		builtin6.StoragePowerActorCodeID:     powerMigrator{},
+		builtin6.SystemActorCodeID:           engine.CodeMigrator{OutCodeCID: builtin7.SystemActorCodeID},
+		builtin6.VerifiedRegistryActorCodeID: verifregMigrator{},
 	}
 
 	// Set of prior version code CIDs for actors to defer during iteration, for explicit migration afterwards.
@@ -87,212 +77,106 @@ func MigrateStateTree(ctx context.Context, store cbor.IpldStore, actorsRootIn ci
 	if len(migrations)+len(deferredCodeIDs) != 11 {
 		panic(fmt.Sprintf("incomplete migration specification with %d code CIDs", len(migrations)))
 	}
-	startTime := time.Now()
-
-	// Load input and output state trees
-	adtStore := adt7.WrapStore(ctx, store)
-	actorsIn, err := states6.LoadTree(adtStore, actorsRootIn)
-	if err != nil {
-		return cid.Undef, err
-	}
-	actorsOut, err := states6.NewTree(adtStore)
-	if err != nil {
-		return cid.Undef, err
-	}
-
-	// Setup synchronization
-	grp, ctx := errgroup.WithContext(ctx)
-	// Input and output queues for workers.
-	jobCh := make(chan *migrationJob, cfg.JobQueueSize)
-	jobResultCh := make(chan *migrationJobResult, cfg.ResultQueueSize)
-	// Atomically-modified counters for logging progress
-	var jobCount uint32
-	var doneCount uint32
-
-	// Iterate all actors in old state root to create migration jobs for each non-deferred actor.
-	grp.Go(func() error {
-		defer close(jobCh)
-		log.Log(rt.INFO, "Creating migration jobs for tree %s", actorsRootIn)
-		if err = actorsIn.ForEach(func(addr address.Address, actorIn *states6.Actor) error {
-			if _, ok := deferredCodeIDs[actorIn.Code]; ok {
-				return nil // Deferred for explicit migration later.
-			}
-			migration, ok := migrations[actorIn.Code]
-			if !ok {
-				return xerrors.Errorf("actor with code %s has no registered migration function", actorIn.Code)
-			}
-			nextInput := &migrationJob{
-				Address:        addr,
-				Actor:          *actorIn, // Must take a copy, the pointer is not stable.
-				cache:          cache,
-				actorMigration: migration,
-			}
-			select {
-			case jobCh <- nextInput:
-			case <-ctx.Done():
-				return ctx.Err()
-			}
-			atomic.AddUint32(&jobCount, 1)
-			return nil
-		}); err != nil {
-			return err
-		}
-		log.Log(rt.INFO, "Done creating %d migration jobs for tree %s after %v", jobCount, actorsRootIn, time.Since(startTime))
-		return nil
-	})
-
-	// Worker threads run jobs.
-	var workerWg sync.WaitGroup
-	for i := uint(0); i < cfg.MaxWorkers; i++ {
-		workerWg.Add(1)
-		workerId := i
-		grp.Go(func() error {
-			defer workerWg.Done()
-			for job := range jobCh {
-				result, err := job.run(ctx, store, priorEpoch)
-				if err != nil {
-					return err
-				}
-				select {
-				case jobResultCh <- result:
-				case <-ctx.Done():
-					return ctx.Err()
-				}
-				atomic.AddUint32(&doneCount, 1)
-			}
-			log.Log(rt.INFO, "Worker %d done", workerId)
-			return nil
-		})
-	}
-	log.Log(rt.INFO, "Started %d workers", cfg.MaxWorkers)
-
-	// Monitor the job queue. This non-critical goroutine is outside the errgroup and exits when
-	// workersFinished is closed, or the context done.
-	workersFinished := make(chan struct{}) // Closed when waitgroup is emptied.
-	if cfg.ProgressLogPeriod > 0 {
-		go func() {
-			defer log.Log(rt.DEBUG, "Job queue monitor done")
-			for {
-				select {
-				case <-time.After(cfg.ProgressLogPeriod):
-					jobsNow := jobCount // Snapshot values to avoid incorrect-looking arithmetic if they change.
-					doneNow := doneCount
-					pendingNow := jobsNow - doneNow
-					elapsed := time.Since(startTime)
-					rate := float64(doneNow) / elapsed.Seconds()
-					log.Log(rt.INFO, "%d jobs created, %d done, %d pending after %v (%.0f/s)",
-						jobsNow, doneNow, pendingNow, elapsed, rate)
-				case <-workersFinished:
-					return
-				case <-ctx.Done():
-					return
-				}
-			}
-		}()
-	}
-
-	// Close result channel when workers are done sending to it.
-	grp.Go(func() error {
-		workerWg.Wait()
-		close(jobResultCh)
-		close(workersFinished)
-		log.Log(rt.INFO, "All workers done after %v", time.Since(startTime))
-		return nil
-	})
-
-	// Insert migrated records in output state tree and accumulators.
-	grp.Go(func() error {
-		log.Log(rt.INFO, "Result writer started")
-		resultCount := 0
-		for result := range jobResultCh {
-			if err := actorsOut.SetActor(result.Address, &result.Actor); err != nil {
-				return err
-			}
-			resultCount++
-		}
-		log.Log(rt.INFO, "Result writer wrote %d results to state tree after %v", resultCount, time.Since(startTime))
-		return nil
-	})
-
-	if err := grp.Wait(); err != nil {
-		return cid.Undef, err
+	return &engine.Migration{
+		Migrations:      migrations,
+		DeferredCodeIDs: deferredCodeIDs,
+		ActorNameByCode: builtin6.ActorNameByCode,
+		CacheVersion:    cacheVersion,
 	}
-
-	elapsed := time.Since(startTime)
-	rate := float64(doneCount) / elapsed.Seconds()
-	log.Log(rt.INFO, "All %d done after %v (%.0f/s). Flushing state tree root.", doneCount, elapsed, rate)
-	return actorsOut.Flush()
 }
 
-type actorMigrationInput struct {
-	address    address.Address // actor's address
-	balance    abi.TokenAmount // actor's balance
-	head       cid.Cid         // actor's state head CID
-	priorEpoch abi.ChainEpoch  // epoch of last state transition prior to migration
-	cache      MigrationCache  // cache of existing cid -> cid migrations for this actor
+// Migrates the filecoin state tree starting from the global state tree and upgrading all actor state.
+// The store must support concurrent writes (even if the configured worker count is 1).
+func MigrateStateTree(ctx context.Context, store cbor.IpldStore, actorsRootIn cid.Cid, priorEpoch abi.ChainEpoch, cfg Config, log Logger, cache MigrationCache) (cid.Cid, error) {
+	root, _, err := MigrateStateTreeWithStats(ctx, store, actorsRootIn, priorEpoch, cfg, log, cache)
+	return root, err
 }
 
-type actorMigrationResult struct {
-	newCodeCID cid.Cid
-	newHead    cid.Cid
+// Migrates the state tree as MigrateStateTree, also returning statistics about the migration.
+func MigrateStateTreeWithStats(ctx context.Context, store cbor.IpldStore, actorsRootIn cid.Cid, priorEpoch abi.ChainEpoch, cfg Config, log Logger, cache MigrationCache) (cid.Cid, *engine.MigrationStats, error) {
+	_, _, root, stats, err := migrateStateTree(ctx, store, actorsRootIn, priorEpoch, cfg, log, cache)
+	return root, stats, err
 }
 
-type actorMigration interface {
-	// Loads an actor's state from an input store and writes new state to an output store.
-	// Returns the new state head CID.
-	migrateState(ctx context.Context, store cbor.IpldStore, input actorMigrationInput) (result *actorMigrationResult, err error)
-	migratedCodeCID() cid.Cid
+// Migrates the state tree as MigrateStateTreeWithStats, reading prior state from an input store and writing new
+// state only to an output store, so that the input store may be read-only.
+// The new state tree can be loaded only from both stores together (see engine.NewStagingStore) until the
+// output store is merged into the input store, e.g. after ValidateMigration succeeds against them.
+// The output store must support concurrent writes.
+func MigrateStateTreeStaged(ctx context.Context, inputStore, outputStore cbor.IpldStore, actorsRootIn cid.Cid, priorEpoch abi.ChainEpoch, cfg Config, log Logger, cache MigrationCache) (cid.Cid, *engine.MigrationStats, error) {
+	return MigrateStateTreeWithStats(ctx, engine.NewStagingStore(inputStore, outputStore), actorsRootIn, priorEpoch, cfg, log, cache)
 }
 
-type migrationJob struct {
-	address.Address
-	states6.Actor
-	actorMigration
-	cache MigrationCache
+// Runs the migration in dry-run mode, returning a report of the changes it would make to the state tree.
+// No state is written to the store.
+func DryRunStateTree(ctx context.Context, store cbor.IpldStore, actorsRootIn cid.Cid, priorEpoch abi.ChainEpoch, cfg Config, log Logger, cache MigrationCache) (*engine.DryRunReport, error) {
+	cfg.DryRun = true
+	actorsIn, actorsOut, _, _, err := migrateStateTree(ctx, store, actorsRootIn, priorEpoch, cfg, log, cache)
+	if err != nil {
+		return nil, err
+	}
+	return engine.BuildDryRunReport(actorsIn, actorsOut)
 }
 
-type migrationJobResult struct {
-	address.Address
-	states7.Actor
+// The prior version state tree satisfies the version-agnostic tree interface.
+var _ states7.VersionedTree = (*states6.Tree)(nil)
+
+// Loads the prior version state tree.
+func loadTreeIn(ctx context.Context, store cbor.IpldStore, actorsRootIn cid.Cid) (states7.VersionedTree, error) {
+	return states6.LoadTree(adt7.WrapStore(ctx, store), actorsRootIn)
 }
 
-func (job *migrationJob) run(ctx context.Context, store cbor.IpldStore, priorEpoch abi.ChainEpoch) (*migrationJobResult, error) {
-	result, err := job.migrateState(ctx, store, actorMigrationInput{
-		address:    job.Address,
-		balance:    job.Actor.Balance,
-		head:       job.Actor.Head,
-		priorEpoch: priorEpoch,
-		cache:      job.cache,
-	})
-	if err != nil {
-		return nil, xerrors.Errorf("state migration failed for %s actor, addr %s: %w",
-			builtin6.ActorNameByCode(job.Actor.Code), job.Address, err)
+func migrateStateTree(ctx context.Context, store cbor.IpldStore, actorsRootIn cid.Cid, priorEpoch abi.ChainEpoch, cfg Config, log Logger, cache MigrationCache) (states7.VersionedTree, states7.VersionedTree, cid.Cid, *engine.MigrationStats, error) {
+	if cfg.DryRun {
+		store = engine.NewDryRunStore(store)
+	}
+	if cfg.MeterWrites {
+		store = engine.NewMeteredStore(store)
 	}
 
-	// Set up new actor record with the migrated state.
-	return &migrationJobResult{
-		job.Address, // Unchanged
-		states7.Actor{
-			Code:       result.newCodeCID,
-			Head:       result.newHead,
-			CallSeqNum: job.Actor.CallSeqNum, // Unchanged
-			Balance:    job.Actor.Balance,    // Unchanged
-		},
-	}, nil
-}
+	// Load input and output state trees
+	actorsIn, err := loadTreeIn(ctx, store, actorsRootIn)
+	if err != nil {
+		return nil, nil, cid.Undef, nil, err
+	}
+	adtStore := adt7.WrapStore(ctx, store)
+	var actorsOut *states7.Tree
+	if cfg.InPlace {
+		actorsOut, err = states7.LoadTree(adtStore, actorsRootIn)
+	} else {
+		actorsOut, err = states7.NewTree(adtStore)
+	}
+	if err != nil {
+		return nil, nil, cid.Undef, nil, err
+	}
 
-// Migrator which preserves the head CID and provides a fixed result code CID.
-type nilMigrator struct {
-	OutCodeCID cid.Cid
+	root, stats, err := migration().MigrateStateTree(ctx, store, actorsIn, actorsOut, priorEpoch, cfg, log, cache)
+	if err != nil {
+		return nil, nil, cid.Undef, nil, err
+	}
+	return actorsIn, actorsOut, root, stats, nil
 }
 
-func (n nilMigrator) migrateState(_ context.Context, _ cbor.IpldStore, in actorMigrationInput) (*actorMigrationResult, error) {
-	return &actorMigrationResult{
-		newCodeCID: n.OutCodeCID,
-		newHead:    in.head,
-	}, nil
+// Migrates only the actors with the given addresses from a state tree, returning their migrated records.
+// This is intended for debugging the migration of individual actors; new state is written to the store,
+// but no new state tree is built.
+func MigrateActors(ctx context.Context, store cbor.IpldStore, actorsRootIn cid.Cid, addrs []address.Address, priorEpoch abi.ChainEpoch, cache MigrationCache) ([]engine.MigratedActor, error) {
+	actorsIn, err := loadTreeIn(ctx, store, actorsRootIn)
+	if err != nil {
+		return nil, err
+	}
+	return migration().MigrateActors(ctx, store, actorsIn, addrs, priorEpoch, cache)
 }
 
-func (n nilMigrator) migratedCodeCID() cid.Cid {
-	return n.OutCodeCID
+// Runs the cacheable actor migrations against a state tree from an epoch prior to the upgrade, populating
+// the cache with their results.
+// When the same cache is subsequently passed to MigrateStateTree, only actors whose state heads have changed
+// since the pre-migration need to be migrated again.
+// The store must support concurrent writes (even if the configured worker count is 1).
+func PreMigrateStateTree(ctx context.Context, store cbor.IpldStore, actorsRootIn cid.Cid, priorEpoch abi.ChainEpoch, cfg Config, log Logger, cache MigrationCache) error {
+	actorsIn, err := loadTreeIn(ctx, store, actorsRootIn)
+	if err != nil {
+		return err
+	}
+	return migration().PreMigrateStateTree(ctx, store, actorsIn, priorEpoch, cfg, log, cache)
 }
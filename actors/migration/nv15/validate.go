@@ -0,0 +1,91 @@
+package nv15
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	states6 "github.com/filecoin-project/specs-actors/v6/actors/states"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
+	states7 "github.com/filecoin-project/specs-actors/v7/actors/states"
+	adt7 "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// Checks a migrated state tree against the tree it was migrated from, returning an error describing
+// every problem found.
+// Both trees must satisfy their version's state invariants, the total token balance must be conserved
+// (this migration moves no balances between actors), and every output actor must have a v7 code CID.
+// Invariant violations already present in the input tree are reported too, since the migration cannot be
+// expected to repair them.
+func ValidateMigration(ctx context.Context, store cbor.IpldStore, rootIn, rootOut cid.Cid, priorEpoch abi.ChainEpoch) error {
+	adtStore := adt7.WrapStore(ctx, store)
+	actorsIn, err := states6.LoadTree(adtStore, rootIn)
+	if err != nil {
+		return xerrors.Errorf("failed to load input state tree: %w", err)
+	}
+	actorsOut, err := states7.LoadTree(adtStore, rootOut)
+	if err != nil {
+		return xerrors.Errorf("failed to load output state tree: %w", err)
+	}
+
+	totalIn, err := totalBalance(actorsIn)
+	if err != nil {
+		return err
+	}
+	totalOut, err := totalBalance(actorsOut)
+	if err != nil {
+		return err
+	}
+
+	// Failures to check invariants (e.g. due to an unknown actor code) are reported as problems rather than
+	// returned immediately, so that the other checks still run.
+	var problems []string
+	if msgs, err := states6.CheckStateInvariants(actorsIn, totalIn, priorEpoch); err != nil {
+		problems = append(problems, fmt.Sprintf("input: failed to check state invariants: %v", err))
+	} else {
+		for _, msg := range msgs.Messages() {
+			problems = append(problems, "input: "+msg)
+		}
+	}
+	if msgs, err := states7.CheckStateInvariants(actorsOut, totalIn, priorEpoch); err != nil {
+		problems = append(problems, fmt.Sprintf("output: failed to check state invariants: %v", err))
+	} else {
+		for _, msg := range msgs.Messages() {
+			problems = append(problems, "output: "+msg)
+		}
+	}
+
+	if !totalIn.Equals(totalOut) {
+		problems = append(problems, fmt.Sprintf("total balance changed from %v to %v", totalIn, totalOut))
+	}
+	if err := actorsOut.ForEach(func(addr address.Address, actor *states7.Actor) error {
+		if !builtin7.IsBuiltinActor(actor.Code) {
+			problems = append(problems, fmt.Sprintf("output actor %v has non-v7 code %v", addr, actor.Code))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		return xerrors.Errorf("migration validation failed with %d problems:\n%s", len(problems), strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+func totalBalance(tree engine.InputTree) (abi.TokenAmount, error) {
+	total := big.Zero()
+	err := tree.ForEach(func(_ address.Address, actor *states7.Actor) error {
+		total = big.Add(total, actor.Balance)
+		return nil
+	})
+	return total, err
+}
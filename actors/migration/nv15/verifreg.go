@@ -0,0 +1,61 @@
+package nv15
+
+import (
+	"context"
+
+	verifreg6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/verifreg"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"golang.org/x/xerrors"
+
+	builtin7 "github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	verifreg7 "github.com/filecoin-project/specs-actors/v7/actors/builtin/verifreg"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
+	adt7 "github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// Migrates the verified registry state to add verifier sub-keys, the IDs of DataCap removal proposals,
+// the verifiers' cumulative allocations and the log of grant events, which all start empty. Grants before the upgrade are not recorded.
+// Client expirations also start empty, so that DataCap granted before the upgrade does not expire.
+type verifregMigrator struct{}
+
+var _ engine.ActorMigration = verifregMigrator{}
+
+func (m verifregMigrator) MigrateState(ctx context.Context, store cbor.IpldStore, in engine.ActorMigrationInput) (*engine.ActorMigrationResult, error) {
+	var stIn verifreg6.State
+	if err := store.Get(ctx, in.Head, &stIn); err != nil {
+		return nil, xerrors.Errorf("failed to load verified registry state for %s: %w", in.Address, err)
+	}
+	adtStore := adt7.WrapStore(ctx, store)
+	emptyMap, err := adt7.StoreEmptyMap(adtStore, builtin7.DefaultHamtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create empty map: %w", err)
+	}
+	grantEvents, err := adt7.StoreEmptyArray(adtStore, verifreg7.GrantEventsAmtBitwidth)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create grant events: %w", err)
+	}
+
+	stOut := verifreg7.State{
+		RootKey:                  stIn.RootKey,
+		Verifiers:                stIn.Verifiers,
+		VerifierSubKeys:          emptyMap,
+		VerifiedClients:          stIn.VerifiedClients,
+		ClientExpirations:        emptyMap,
+		RemoveDataCapProposalIDs: emptyMap,
+		VerifierAllocations:      emptyMap,
+		GrantEvents:              grantEvents,
+	}
+	newHead, err := store.Put(ctx, &stOut)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to write verified registry state for %s: %w", in.Address, err)
+	}
+	return &engine.ActorMigrationResult{
+		NewCodeCID: m.MigratedCodeCID(),
+		NewHead:    newHead,
+	}, nil
+}
+
+func (m verifregMigrator) MigratedCodeCID() cid.Cid {
+	return builtin7.VerifiedRegistryActorCodeID
+}
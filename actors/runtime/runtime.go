@@ -189,6 +189,9 @@ type Syscalls interface {
 
 	// Verifies a proof of spacetime.
 	VerifyPoSt(vi proof.WindowPoStVerifyInfo) error
+	// Verifies a batch of proofs of spacetime, returning the validity of each.
+	// An error indicates that the batch could not be verified, not that any proof is invalid.
+	BatchVerifyPoSts(vis []proof.WindowPoStVerifyInfo) ([]bool, error)
 	// Verifies that two block headers provide proof of a consensus fault:
 	// - both headers mined by the same actor
 	// - headers are different
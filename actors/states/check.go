@@ -2,10 +2,13 @@ package states
 
 import (
 	"bytes"
+	"fmt"
 
 	addr "github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/sync/errgroup"
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin/power"
@@ -20,148 +23,345 @@ import (
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin/paych"
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin/reward"
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin/verifreg"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
 )
 
 // Within this code, Go errors are not expected, but are often converted to messages so that execution
 // can continue to find more errors rather than fail with no insight.
 // Only errors thar are particularly troublesome to recover from should propagate as Go errors.
 func CheckStateInvariants(tree *Tree, expectedBalanceTotal abi.TokenAmount, priorEpoch abi.ChainEpoch) (*builtin.MessageAccumulator, error) {
-	acc := &builtin.MessageAccumulator{}
-	totalFIl := big.Zero()
-	var initSummary *init_.StateSummary
-	var cronSummary *cron.StateSummary
-	var verifregSummary *verifreg.StateSummary
-	var marketSummary *market.StateSummary
-	var rewardSummary *reward.StateSummary
-	var accountSummaries []*account.StateSummary
-	var powerSummary *power.StateSummary
-	var paychSummaries []*paych.StateSummary
-	var multisigSummaries []*multisig.StateSummary
-	minerSummaries := make(map[addr.Address]*miner.StateSummary)
-
+	c := newStateChecker(tree.Store)
 	if err := tree.ForEach(func(key addr.Address, actor *Actor) error {
-		acc := acc.WithPrefix("%v ", key) // Intentional shadow
-		if key.Protocol() != addr.ID {
-			acc.Addf("unexpected address protocol in state tree root: %v", key)
-		}
-		totalFIl = big.Add(totalFIl, actor.Balance)
-
-		switch actor.Code {
-		case builtin.SystemActorCodeID:
-
-		case builtin.InitActorCodeID:
-			var st init_.State
-			if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
-				return err
-			}
-			summary, msgs := init_.CheckStateInvariants(&st, tree.Store)
-			acc.WithPrefix("init: ").AddAll(msgs)
-			initSummary = summary
-		case builtin.CronActorCodeID:
-			var st cron.State
-			if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
-				return err
-			}
-			summary, msgs := cron.CheckStateInvariants(&st, tree.Store)
-			acc.WithPrefix("cron: ").AddAll(msgs)
-			cronSummary = summary
-		case builtin.AccountActorCodeID:
-			var st account.State
-			if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
-				return err
-			}
-			summary, msgs := account.CheckStateInvariants(&st, key)
-			acc.WithPrefix("account: ").AddAll(msgs)
-			accountSummaries = append(accountSummaries, summary)
-		case builtin.StoragePowerActorCodeID:
-			var st power.State
-			if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
-				return err
-			}
-			summary, msgs := power.CheckStateInvariants(&st, tree.Store)
-			acc.WithPrefix("power: ").AddAll(msgs)
-			powerSummary = summary
-		case builtin.StorageMinerActorCodeID:
-			var st miner.State
-			if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
-				return err
-			}
-			summary, msgs := miner.CheckStateInvariants(&st, tree.Store, actor.Balance)
-			acc.WithPrefix("miner: ").AddAll(msgs)
-			minerSummaries[key] = summary
-		case builtin.StorageMarketActorCodeID:
-			var st market.State
-			if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
-				return err
-			}
-			summary, msgs := market.CheckStateInvariants(&st, tree.Store, actor.Balance, priorEpoch)
-			acc.WithPrefix("market: ").AddAll(msgs)
-			marketSummary = summary
-		case builtin.PaymentChannelActorCodeID:
-			var st paych.State
-			if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
-				return err
-			}
-			summary, msgs := paych.CheckStateInvariants(&st, tree.Store, actor.Balance)
-			acc.WithPrefix("paych: ").AddAll(msgs)
-			paychSummaries = append(paychSummaries, summary)
-		case builtin.MultisigActorCodeID:
-			var st multisig.State
-			if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
-				return err
-			}
-			summary, msgs := multisig.CheckStateInvariants(&st, tree.Store)
-			acc.WithPrefix("multisig: ").AddAll(msgs)
-			multisigSummaries = append(multisigSummaries, summary)
-		case builtin.RewardActorCodeID:
-			var st reward.State
-			if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
-				return err
-			}
-			summary, msgs := reward.CheckStateInvariants(&st, tree.Store, priorEpoch, actor.Balance)
-			acc.WithPrefix("reward: ").AddAll(msgs)
-			rewardSummary = summary
-		case builtin.VerifiedRegistryActorCodeID:
-			var st verifreg.State
-			if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
-				return err
-			}
-			summary, msgs := verifreg.CheckStateInvariants(&st, tree.Store)
-			acc.WithPrefix("verifreg: ").AddAll(msgs)
-			verifregSummary = summary
-		default:
-			return xerrors.Errorf("unexpected actor code CID %v for address %v", actor.Code, key)
+		result, err := checkActorState(tree, key, actor, priorEpoch)
+		if err != nil {
+			return err
 		}
+		c.add(key, actor, result)
 		return nil
 	}); err != nil {
 		return nil, err
 	}
+	return c.finish(expectedBalanceTotal), nil
+}
+
+// Accumulates the results of checking each actor's state, in the order of the state tree, and performs the
+// cross-actor checks once all actors have been checked.
+type stateChecker struct {
+	acc      *builtin.MessageAccumulator
+	totalFIl abi.TokenAmount
+	store    adt.Store
+	plugins  []pluginCheck
 
+	initSummary       *init_.StateSummary
+	cronSummary       *cron.StateSummary
+	verifregSummary   *verifreg.StateSummary
+	marketSummary     *market.StateSummary
+	rewardSummary     *reward.StateSummary
+	accountSummaries  []*account.StateSummary
+	powerSummary      *power.StateSummary
+	paychSummaries    []*paych.StateSummary
+	multisigSummaries []*multisig.StateSummary
+	minerSummaries    map[addr.Address]*miner.StateSummary
+}
+
+func newStateChecker(store adt.Store) *stateChecker {
+	return &stateChecker{
+		acc:            &builtin.MessageAccumulator{},
+		totalFIl:       big.Zero(),
+		store:          store,
+		plugins:        newPluginChecks(),
+		minerSummaries: make(map[addr.Address]*miner.StateSummary),
+	}
+}
+
+func (c *stateChecker) add(key addr.Address, actor *Actor, result *actorCheckResult) {
+	acc := c.acc.WithPrefix("%v ", key)
+	if key.Protocol() != addr.ID {
+		acc.Addf("unexpected address protocol in state tree root: %v", key)
+	}
+	c.totalFIl = big.Add(c.totalFIl, actor.Balance)
+	acc.WithPrefix("%s: ", result.name).AddAll(result.msgs)
+	for _, plugin := range c.plugins {
+		plugin.check.CheckActor(c.store, key, actor, result.state, acc.WithPrefix("%s: ", plugin.name))
+	}
+
+	switch summary := result.summary.(type) {
+	case *init_.StateSummary:
+		c.initSummary = summary
+	case *cron.StateSummary:
+		c.cronSummary = summary
+	case *account.StateSummary:
+		c.accountSummaries = append(c.accountSummaries, summary)
+	case *power.StateSummary:
+		c.powerSummary = summary
+	case *miner.StateSummary:
+		c.minerSummaries[key] = summary
+	case *market.StateSummary:
+		c.marketSummary = summary
+	case *paych.StateSummary:
+		c.paychSummaries = append(c.paychSummaries, summary)
+	case *multisig.StateSummary:
+		c.multisigSummaries = append(c.multisigSummaries, summary)
+	case *reward.StateSummary:
+		c.rewardSummary = summary
+	case *verifreg.StateSummary:
+		c.verifregSummary = summary
+	}
+}
+
+func (c *stateChecker) finish(expectedBalanceTotal abi.TokenAmount) *builtin.MessageAccumulator {
 	//
 	// Perform cross-actor checks from state summaries here.
 	//
 
-	CheckMinersAgainstPower(acc, minerSummaries, powerSummary)
-	CheckDealStatesAgainstSectors(acc, minerSummaries, marketSummary)
+	CheckMinersAgainstPower(c.acc, c.minerSummaries, c.powerSummary)
+	CheckDealStatesAgainstSectors(c.acc, c.minerSummaries, c.marketSummary)
+
+	if !c.totalFIl.Equals(expectedBalanceTotal) {
+		c.acc.Addf("total token balance is %v, expected %v", c.totalFIl, expectedBalanceTotal)
+	}
+
+	for _, plugin := range c.plugins {
+		plugin.check.CheckTree(c.acc.WithPrefix("%s: ", plugin.name))
+	}
+
+	return c.acc
+}
+
+// Configuration for checking state invariants in parallel.
+type CheckConfig struct {
+	// Number of workers checking actor states.
+	// Zero or one checks actors sequentially, like CheckStateInvariants.
+	MaxWorkers uint
+	// Capacity of the queue of actors waiting to be checked.
+	JobQueueSize uint
+}
+
+// Checks state invariants as CheckStateInvariants, but checks the states of actors in parallel.
+// The messages are identical, and in the same order, as those of CheckStateInvariants.
+// The tree's store must be safe for concurrent use.
+func CheckStateInvariantsParallel(tree *Tree, expectedBalanceTotal abi.TokenAmount, priorEpoch abi.ChainEpoch, cfg CheckConfig) (*builtin.MessageAccumulator, error) {
+	if cfg.MaxWorkers <= 1 {
+		return CheckStateInvariants(tree, expectedBalanceTotal, priorEpoch)
+	}
+
+	type actorCheck struct {
+		key    addr.Address
+		actor  *Actor
+		result *actorCheckResult
+	}
+	// The results are listed in tree order, and each filled in by a worker.
+	var checks []*actorCheck
+	jobs := make(chan *actorCheck, cfg.JobQueueSize)
+	grp, ctx := errgroup.WithContext(tree.Store.Context())
+
+	grp.Go(func() error {
+		defer close(jobs)
+		return tree.ForEach(func(key addr.Address, actor *Actor) error {
+			// The actor is reused by ForEach, so must be copied.
+			actorCopy := *actor
+			check := &actorCheck{key: key, actor: &actorCopy}
+			checks = append(checks, check)
+			select {
+			case jobs <- check:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return nil
+		})
+	})
+	for w := uint(0); w < cfg.MaxWorkers; w++ {
+		grp.Go(func() error {
+			for check := range jobs {
+				var err error
+				check.result, err = checkActorState(tree, check.key, check.actor, priorEpoch)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if err := grp.Wait(); err != nil {
+		return nil, err
+	}
+
+	c := newStateChecker(tree.Store)
+	for _, check := range checks {
+		c.add(check.key, check.actor, check.result)
+	}
+	return c.finish(expectedBalanceTotal), nil
+}
 
-	_ = initSummary
-	_ = verifregSummary
-	_ = cronSummary
-	_ = marketSummary
-	_ = rewardSummary
+// An invariant violated by the state of a single actor.
+type Finding struct {
+	// The actor whose state violates the invariant.
+	Address addr.Address
+	// Name of the type of actor, as in the messages of CheckStateInvariants (e.g. "miner").
+	// Empty for findings about the actor's place in the tree, rather than its state.
+	Actor   string
+	Message string
+}
 
-	if !totalFIl.Equals(expectedBalanceTotal) {
-		acc.Addf("total token balance is %v, expected %v", totalFIl, expectedBalanceTotal)
+// Formats the finding as CheckStateInvariants would report it.
+func (f Finding) String() string {
+	if f.Actor == "" {
+		return fmt.Sprintf("%v %s", f.Address, f.Message)
+	}
+	return fmt.Sprintf("%v %s: %s", f.Address, f.Actor, f.Message)
+}
+
+// Checks the invariants of the state of a single actor, e.g. to validate one miner quickly.
+// Cross-actor invariants, such as the agreement of miner power with power actor claims, are not checked.
+func CheckActorInvariants(tree *Tree, a addr.Address, priorEpoch abi.ChainEpoch) ([]Finding, error) {
+	actor, found, err := tree.GetActor(a)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load actor %v: %w", a, err)
+	}
+	if !found {
+		return nil, xerrors.Errorf("actor %v not found", a)
 	}
+	return checkActorFindings(tree, a, actor, priorEpoch)
+}
 
-	return acc, nil
+// Checks the invariants of the states of all actors with a code CID, e.g. all miners or the market actor.
+// Cross-actor invariants are not checked.
+func CheckActorTypeInvariants(tree *Tree, code cid.Cid, priorEpoch abi.ChainEpoch) ([]Finding, error) {
+	if !builtin.IsBuiltinActor(code) {
+		return nil, xerrors.Errorf("unexpected actor code CID %v", code)
+	}
+	var findings []Finding
+	if err := tree.ForEach(func(key addr.Address, actor *Actor) error {
+		if !actor.Code.Equals(code) {
+			return nil
+		}
+		actorFindings, err := checkActorFindings(tree, key, actor, priorEpoch)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, actorFindings...)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return findings, nil
+}
+
+func checkActorFindings(tree *Tree, key addr.Address, actor *Actor, priorEpoch abi.ChainEpoch) ([]Finding, error) {
+	var findings []Finding
+	if key.Protocol() != addr.ID {
+		findings = append(findings, Finding{
+			Address: key,
+			Message: fmt.Sprintf("unexpected address protocol in state tree root: %v", key),
+		})
+	}
+	result, err := checkActorState(tree, key, actor, priorEpoch)
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range result.msgs.Messages() {
+		findings = append(findings, Finding{Address: key, Actor: result.name, Message: msg})
+	}
+	return findings, nil
+}
+
+// The result of checking the invariants of the state of one actor.
+type actorCheckResult struct {
+	// Name of the type of actor, e.g. "miner".
+	name string
+	// The loaded state, e.g. *miner.State, and its summary for cross-actor checks. Both nil for the system actor.
+	state   interface{}
+	summary interface{}
+	// Messages describing any violations.
+	msgs *builtin.MessageAccumulator
+}
+
+// Checks the invariants of the state of one actor.
+func checkActorState(tree *Tree, key addr.Address, actor *Actor, priorEpoch abi.ChainEpoch) (*actorCheckResult, error) {
+	switch actor.Code {
+	case builtin.SystemActorCodeID:
+		return &actorCheckResult{name: "system", msgs: &builtin.MessageAccumulator{}}, nil
+	case builtin.InitActorCodeID:
+		var st init_.State
+		if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
+			return nil, err
+		}
+		summary, msgs := init_.CheckStateInvariants(&st, tree.Store)
+		return &actorCheckResult{name: "init", state: &st, summary: summary, msgs: msgs}, nil
+	case builtin.CronActorCodeID:
+		var st cron.State
+		if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
+			return nil, err
+		}
+		summary, msgs := cron.CheckStateInvariants(&st, tree.Store)
+		return &actorCheckResult{name: "cron", state: &st, summary: summary, msgs: msgs}, nil
+	case builtin.AccountActorCodeID:
+		var st account.State
+		if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
+			return nil, err
+		}
+		summary, msgs := account.CheckStateInvariants(&st, key)
+		return &actorCheckResult{name: "account", state: &st, summary: summary, msgs: msgs}, nil
+	case builtin.StoragePowerActorCodeID:
+		var st power.State
+		if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
+			return nil, err
+		}
+		summary, msgs := power.CheckStateInvariants(&st, tree.Store)
+		return &actorCheckResult{name: "power", state: &st, summary: summary, msgs: msgs}, nil
+	case builtin.StorageMinerActorCodeID:
+		var st miner.State
+		if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
+			return nil, err
+		}
+		summary, msgs := miner.CheckStateInvariants(&st, tree.Store, actor.Balance)
+		return &actorCheckResult{name: "miner", state: &st, summary: summary, msgs: msgs}, nil
+	case builtin.StorageMarketActorCodeID:
+		var st market.State
+		if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
+			return nil, err
+		}
+		summary, msgs := market.CheckStateInvariants(&st, tree.Store, actor.Balance, priorEpoch)
+		return &actorCheckResult{name: "market", state: &st, summary: summary, msgs: msgs}, nil
+	case builtin.PaymentChannelActorCodeID:
+		var st paych.State
+		if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
+			return nil, err
+		}
+		summary, msgs := paych.CheckStateInvariants(&st, tree.Store, actor.Balance)
+		return &actorCheckResult{name: "paych", state: &st, summary: summary, msgs: msgs}, nil
+	case builtin.MultisigActorCodeID:
+		var st multisig.State
+		if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
+			return nil, err
+		}
+		summary, msgs := multisig.CheckStateInvariants(&st, tree.Store)
+		return &actorCheckResult{name: "multisig", state: &st, summary: summary, msgs: msgs}, nil
+	case builtin.RewardActorCodeID:
+		var st reward.State
+		if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
+			return nil, err
+		}
+		summary, msgs := reward.CheckStateInvariants(&st, tree.Store, priorEpoch, actor.Balance)
+		return &actorCheckResult{name: "reward", state: &st, summary: summary, msgs: msgs}, nil
+	case builtin.VerifiedRegistryActorCodeID:
+		var st verifreg.State
+		if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
+			return nil, err
+		}
+		summary, msgs := verifreg.CheckStateInvariants(&st, tree.Store)
+		return &actorCheckResult{name: "verifreg", state: &st, summary: summary, msgs: msgs}, nil
+	default:
+		return nil, xerrors.Errorf("unexpected actor code CID %v for address %v", actor.Code, key)
+	}
 }
 
 func CheckMinersAgainstPower(acc *builtin.MessageAccumulator, minerSummaries map[addr.Address]*miner.StateSummary, powerSummary *power.StateSummary) {
 	for addr, minerSummary := range minerSummaries { // nolint:nomaprange
 		// check claim
+		// A miner deregistered from the power actor has no claim, no live sectors and no deadline cron.
 		claim, ok := powerSummary.Claims[addr]
-		acc.Require(ok, "miner %v has no power claim", addr)
+		acc.Require(ok || (minerSummary.LiveSectors == 0 && !minerSummary.DeadlineCronActive), "miner %v has no power claim", addr)
 		if ok {
 			claimPower := miner.NewPowerPair(claim.RawBytePower, claim.QualityAdjPower)
 			acc.Require(minerSummary.ActivePower.Equals(claimPower),
@@ -176,8 +376,10 @@ func CheckMinersAgainstPower(acc *builtin.MessageAccumulator, minerSummaries map
 			continue
 		}
 
+		// Deferring an event moves it, so each event a miner enrolled is queued exactly once.
 		var payload miner.CronEventPayload
 		var provingPeriodCron *power.MinerCronEvent
+		var earlyTerminationCron *power.MinerCronEvent
 		for _, event := range crons {
 			err := payload.UnmarshalCBOR(bytes.NewReader(event.Payload))
 			acc.Require(err == nil, "miner %v registered cron at epoch %d with wrong or corrupt payload",
@@ -190,8 +392,16 @@ func CheckMinersAgainstPower(acc *builtin.MessageAccumulator, minerSummaries map
 					acc.Require(false, "miner %v has duplicate proving period crons at epoch %d and %d",
 						addr, provingPeriodCron.Epoch, event.Epoch)
 				}
+				acc.Require(event.Priority, "miner %v proving period cron at epoch %d is not a priority event", addr, event.Epoch)
 				provingPeriodCron = &event
 			}
+			if payload.EventType == miner.CronEventProcessEarlyTerminations {
+				if earlyTerminationCron != nil {
+					acc.Require(false, "miner %v has duplicate early termination crons at epoch %d and %d",
+						addr, earlyTerminationCron.Epoch, event.Epoch)
+				}
+				earlyTerminationCron = &event
+			}
 		}
 		hasProvingPeriodCron := provingPeriodCron != nil
 		acc.Require(hasProvingPeriodCron == minerSummary.DeadlineCronActive, "miner %v has invalid DeadlineCronActive (%t) for hasProvingPeriodCron status (%t)",
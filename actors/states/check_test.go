@@ -0,0 +1,113 @@
+package states_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/account"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+	vm "github.com/filecoin-project/specs-actors/v7/support/vm"
+)
+
+func TestCheckActorInvariants(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t, ipld.NewBlockStoreInMemory())
+	tree, err := v.GetStateTree()
+	require.NoError(t, err)
+
+	// An account actor whose address is not a key address.
+	badAddr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	head, err := tree.Store.Put(ctx, &account.State{Address: badAddr})
+	require.NoError(t, err)
+	require.NoError(t, tree.SetActor(badAddr, &states.Actor{
+		Code:       builtin.AccountActorCodeID,
+		Head:       head,
+		CallSeqNum: 0,
+		Balance:    big.Zero(),
+	}))
+
+	t.Run("single actor", func(t *testing.T) {
+		findings, err := states.CheckActorInvariants(tree, builtin.CronActorAddr, 0)
+		require.NoError(t, err)
+		assert.Empty(t, findings)
+
+		findings, err = states.CheckActorInvariants(tree, badAddr, 0)
+		require.NoError(t, err)
+		require.Len(t, findings, 1)
+		assert.Equal(t, badAddr, findings[0].Address)
+		assert.Equal(t, "account", findings[0].Actor)
+
+		missing, err := address.NewIDAddress(1001)
+		require.NoError(t, err)
+		_, err = states.CheckActorInvariants(tree, missing, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("actor type", func(t *testing.T) {
+		findings, err := states.CheckActorTypeInvariants(tree, builtin.AccountActorCodeID, 0)
+		require.NoError(t, err)
+		require.Len(t, findings, 1)
+		assert.Equal(t, badAddr, findings[0].Address)
+
+		findings, err = states.CheckActorTypeInvariants(tree, builtin.StorageMarketActorCodeID, 0)
+		require.NoError(t, err)
+		assert.Empty(t, findings)
+
+		_, err = states.CheckActorTypeInvariants(tree, head, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("findings match tree messages", func(t *testing.T) {
+		findings, err := states.CheckActorInvariants(tree, badAddr, 0)
+		require.NoError(t, err)
+		total, err := v.GetTotalActorBalance()
+		require.NoError(t, err)
+		acc, err := states.CheckStateInvariants(tree, total, 0)
+		require.NoError(t, err)
+		assert.Contains(t, acc.Messages(), findings[0].String())
+	})
+}
+
+func TestCheckStateInvariantsParallel(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	tree, err := v.GetStateTree()
+	require.NoError(t, err)
+
+	// Account actors whose addresses are not key addresses.
+	for i := uint64(0); i < 20; i++ {
+		a, err := address.NewIDAddress(1000 + i)
+		require.NoError(t, err)
+		head, err := tree.Store.Put(ctx, &account.State{Address: a})
+		require.NoError(t, err)
+		require.NoError(t, tree.SetActor(a, &states.Actor{
+			Code:       builtin.AccountActorCodeID,
+			Head:       head,
+			CallSeqNum: 0,
+			Balance:    big.NewInt(int64(i)),
+		}))
+	}
+	total := big.Zero()
+	require.NoError(t, tree.ForEach(func(_ address.Address, actor *states.Actor) error {
+		total = big.Add(total, actor.Balance)
+		return nil
+	}))
+
+	expected, err := states.CheckStateInvariants(tree, total, 0)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, len(expected.Messages()), 20)
+
+	for _, workers := range []uint{0, 1, 4} {
+		acc, err := states.CheckStateInvariantsParallel(tree, total, 0, states.CheckConfig{MaxWorkers: workers, JobQueueSize: 2})
+		require.NoError(t, err)
+		assert.Equal(t, expected.Messages(), acc.Messages())
+	}
+}
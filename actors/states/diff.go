@@ -0,0 +1,75 @@
+package states
+
+import (
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+)
+
+// A difference in the record of one actor between two state trees.
+type ActorDifference struct {
+	Address address.Address
+	// The actor records in each tree, nil if the actor is absent from that tree.
+	A, B *Actor
+}
+
+// Decides whether two state heads of actors with a code CID are equivalent.
+type HeadComparer func(code, headA, headB cid.Cid) (bool, error)
+
+// Compares two state trees semantically, returning the actors whose records differ, in the iteration order of
+// tree a followed by that of tree b.
+// Actors are looked up by address, so the trees may differ in HAMT layout (e.g. bitwidth) and still be
+// equivalent. Actor heads are compared by CID unless a comparer is given, which is consulted only for actors
+// with the same code and different heads, e.g. to compare states whose collections have different layouts.
+func DiffTrees(a, b VersionedTree, cmp HeadComparer) ([]ActorDifference, error) {
+	var diffs []ActorDifference
+	if err := a.ForEach(func(addr address.Address, actorA *Actor) error {
+		actorB, found, err := b.GetActor(addr)
+		if err != nil {
+			return xerrors.Errorf("failed to load actor %s: %w", addr, err)
+		}
+		actorACopy := *actorA
+		if !found {
+			diffs = append(diffs, ActorDifference{Address: addr, A: &actorACopy})
+			return nil
+		}
+		equal, err := actorsEquivalent(&actorACopy, actorB, cmp)
+		if err != nil {
+			return xerrors.Errorf("failed to compare actor %s: %w", addr, err)
+		}
+		if !equal {
+			diffs = append(diffs, ActorDifference{Address: addr, A: &actorACopy, B: actorB})
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := b.ForEach(func(addr address.Address, actorB *Actor) error {
+		_, found, err := a.GetActor(addr)
+		if err != nil {
+			return xerrors.Errorf("failed to load actor %s: %w", addr, err)
+		}
+		if !found {
+			actorBCopy := *actorB
+			diffs = append(diffs, ActorDifference{Address: addr, B: &actorBCopy})
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return diffs, nil
+}
+
+func actorsEquivalent(a, b *Actor, cmp HeadComparer) (bool, error) {
+	if !a.Code.Equals(b.Code) || a.CallSeqNum != b.CallSeqNum || !a.Balance.Equals(b.Balance) {
+		return false, nil
+	}
+	if a.Head.Equals(b.Head) {
+		return true, nil
+	}
+	if cmp == nil {
+		return false, nil
+	}
+	return cmp(a.Code, a.Head, b.Head)
+}
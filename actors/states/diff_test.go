@@ -0,0 +1,101 @@
+package states_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+)
+
+func TestDiffTrees(t *testing.T) {
+	store := ipld.NewADTStore(context.Background())
+	head, err := store.Put(context.Background(), builtin.AccountActorCodeID)
+	require.NoError(t, err)
+	otherHead, err := store.Put(context.Background(), builtin.MultisigActorCodeID)
+	require.NoError(t, err)
+
+	// Builds a tree with a HAMT bitwidth, inserting actors in the given order.
+	buildTree := func(bitwidth int, ids []uint64, mutate func(id uint64, actor *states.Actor)) *states.Tree {
+		m, err := adt.MakeEmptyMap(store, bitwidth)
+		require.NoError(t, err)
+		tree := &states.Tree{Map: m, Store: store}
+		for _, id := range ids {
+			addr, err := address.NewIDAddress(id)
+			require.NoError(t, err)
+			actor := &states.Actor{Code: builtin.AccountActorCodeID, Head: head, Balance: abi.NewTokenAmount(int64(id))}
+			if mutate != nil {
+				mutate(id, actor)
+			}
+			require.NoError(t, tree.SetActor(addr, actor))
+		}
+		return tree
+	}
+
+	ids := make([]uint64, 100)
+	reversed := make([]uint64, 100)
+	for i := range ids {
+		ids[i] = uint64(100 + i)
+		reversed[len(ids)-1-i] = ids[i]
+	}
+
+	t.Run("equivalent despite layout", func(t *testing.T) {
+		a := buildTree(builtin.DefaultHamtBitwidth, ids, nil)
+		b := buildTree(3, reversed, nil)
+		diffs, err := states.DiffTrees(a, b, nil)
+		require.NoError(t, err)
+		assert.Empty(t, diffs)
+	})
+
+	t.Run("differences", func(t *testing.T) {
+		a := buildTree(builtin.DefaultHamtBitwidth, ids[:99], nil)
+		b := buildTree(3, ids[1:], func(id uint64, actor *states.Actor) {
+			if id == 150 {
+				actor.Balance = big.Add(actor.Balance, big.NewInt(1))
+			}
+		})
+		diffs, err := states.DiffTrees(a, b, nil)
+		require.NoError(t, err)
+		require.Len(t, diffs, 3)
+		byID := map[uint64]states.ActorDifference{}
+		for _, d := range diffs {
+			id, err := address.IDFromAddress(d.Address)
+			require.NoError(t, err)
+			byID[id] = d
+		}
+		assert.Nil(t, byID[100].B)
+		assert.Nil(t, byID[199].A)
+		assert.NotNil(t, byID[150].A)
+		assert.NotNil(t, byID[150].B)
+	})
+
+	t.Run("head comparer", func(t *testing.T) {
+		a := buildTree(builtin.DefaultHamtBitwidth, ids, nil)
+		b := buildTree(builtin.DefaultHamtBitwidth, ids, func(id uint64, actor *states.Actor) {
+			if id == 150 {
+				actor.Head = otherHead
+			}
+		})
+		diffs, err := states.DiffTrees(a, b, nil)
+		require.NoError(t, err)
+		assert.Len(t, diffs, 1)
+
+		compared := 0
+		diffs, err = states.DiffTrees(a, b, func(code, headA, headB cid.Cid) (bool, error) {
+			compared++
+			return true, nil
+		})
+		require.NoError(t, err)
+		assert.Empty(t, diffs)
+		assert.Equal(t, 1, compared)
+	})
+}
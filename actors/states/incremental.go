@@ -0,0 +1,103 @@
+package states
+
+import (
+	"bytes"
+	"sort"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// The per-actor results of checking the invariants of a state tree, which may be carried forward to check a
+// later state tree incrementally.
+type CheckResults struct {
+	actors map[addr.Address]*cachedActorCheck
+}
+
+type cachedActorCheck struct {
+	actor  Actor
+	result *actorCheckResult
+}
+
+// Checks state invariants as CheckStateInvariants, also returning the per-actor results for a later
+// incremental check.
+// Messages are ordered by actor address, rather than state tree iteration order.
+func CheckStateInvariantsWithResults(tree *Tree, expectedBalanceTotal abi.TokenAmount, priorEpoch abi.ChainEpoch) (*builtin.MessageAccumulator, *CheckResults, error) {
+	results := &CheckResults{actors: map[addr.Address]*cachedActorCheck{}}
+	if err := tree.ForEach(func(key addr.Address, actor *Actor) error {
+		result, err := checkActorState(tree, key, actor, priorEpoch)
+		if err != nil {
+			return err
+		}
+		results.actors[key] = &cachedActorCheck{actor: *actor, result: result}
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+	return results.check(tree.Store, expectedBalanceTotal), results, nil
+}
+
+// Checks state invariants incrementally from the results of checking a prior state tree.
+// Only the touched actors, and actors whose invariants depend on the epoch (reward and market), are checked
+// again; the results for other actors are carried forward from the prior check. Cross-actor invariants are
+// checked in full from the carried forward summaries.
+// Touched must include every actor whose record differs from the prior state tree, including actors created
+// or deleted since. Messages are ordered by actor address.
+func CheckStateInvariantsIncremental(prior *CheckResults, tree *Tree, touched []addr.Address, expectedBalanceTotal abi.TokenAmount, priorEpoch abi.ChainEpoch) (*builtin.MessageAccumulator, *CheckResults, error) {
+	results := &CheckResults{actors: make(map[addr.Address]*cachedActorCheck, len(prior.actors))}
+	for key, cached := range prior.actors { // nolint:nomaprange // Copying a map.
+		results.actors[key] = cached
+	}
+
+	for _, key := range touched {
+		actor, found, err := tree.GetActor(key)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("failed to load actor %v: %w", key, err)
+		}
+		if !found {
+			delete(results.actors, key)
+			continue
+		}
+		result, err := checkActorState(tree, key, actor, priorEpoch)
+		if err != nil {
+			return nil, nil, err
+		}
+		results.actors[key] = &cachedActorCheck{actor: *actor, result: result}
+	}
+
+	for key, cached := range results.actors { // nolint:nomaprange // Each actor is checked independently.
+		if !cached.actor.Code.Equals(builtin.RewardActorCodeID) && !cached.actor.Code.Equals(builtin.StorageMarketActorCodeID) {
+			continue
+		}
+		actor := cached.actor
+		result, err := checkActorState(tree, key, &actor, priorEpoch)
+		if err != nil {
+			return nil, nil, err
+		}
+		results.actors[key] = &cachedActorCheck{actor: actor, result: result}
+	}
+
+	return results.check(tree.Store, expectedBalanceTotal), results, nil
+}
+
+// Accumulates the per-actor results in address order and performs the cross-actor checks.
+func (r *CheckResults) check(store adt.Store, expectedBalanceTotal abi.TokenAmount) *builtin.MessageAccumulator {
+	keys := make([]addr.Address, 0, len(r.actors))
+	for key := range r.actors { // nolint:nomaprange // Sorted below.
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i].Bytes(), keys[j].Bytes()) < 0
+	})
+
+	c := newStateChecker(store)
+	for _, key := range keys {
+		cached := r.actors[key]
+		c.add(key, &cached.actor, cached.result)
+	}
+	return c.finish(expectedBalanceTotal)
+}
@@ -0,0 +1,80 @@
+package states_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/account"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+	vm "github.com/filecoin-project/specs-actors/v7/support/vm"
+)
+
+func TestCheckStateInvariantsIncremental(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t, ipld.NewBlockStoreInMemory())
+	tree, err := v.GetStateTree()
+	require.NoError(t, err)
+
+	setAccount := func(id uint64, key address.Address, balance int64) address.Address {
+		a, err := address.NewIDAddress(id)
+		require.NoError(t, err)
+		head, err := tree.Store.Put(ctx, &account.State{Address: key})
+		require.NoError(t, err)
+		require.NoError(t, tree.SetActor(a, &states.Actor{
+			Code:    builtin.AccountActorCodeID,
+			Head:    head,
+			Balance: abi.NewTokenAmount(balance),
+		}))
+		return a
+	}
+	totalBalance := func() abi.TokenAmount {
+		total := big.Zero()
+		require.NoError(t, tree.ForEach(func(_ address.Address, actor *states.Actor) error {
+			total = big.Add(total, actor.Balance)
+			return nil
+		}))
+		return total
+	}
+
+	// An account whose address is not a key address.
+	bad, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	setAccount(1000, bad, 10)
+	good := setAccount(1001, tutil.NewBLSAddr(t, 1), 20)
+
+	acc, results, err := states.CheckStateInvariantsWithResults(tree, totalBalance(), 0)
+	require.NoError(t, err)
+	full, err := states.CheckStateInvariants(tree, totalBalance(), 0)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, full.Messages(), acc.Messages())
+	assert.Contains(t, acc.Messages(), "t01000 account: actor address t01000 must be BLS or SECP256K1 protocol")
+
+	// Fix the bad account, delete the good one, and add a new bad account.
+	setAccount(1000, tutil.NewBLSAddr(t, 2), 30)
+	require.NoError(t, tree.DeleteActor(good))
+	newBad, err := address.NewIDAddress(1002)
+	require.NoError(t, err)
+	setAccount(1002, newBad, 40)
+	touched := []address.Address{bad, good, newBad}
+
+	// The reward actor is checked again at the new epoch, though not touched.
+	acc, results, err = states.CheckStateInvariantsIncremental(results, tree, touched, totalBalance(), 1)
+	require.NoError(t, err)
+	full, err = states.CheckStateInvariants(tree, totalBalance(), 1)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, full.Messages(), acc.Messages())
+
+	// With nothing touched, the results carry forward.
+	acc2, _, err := states.CheckStateInvariantsIncremental(results, tree, nil, totalBalance(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, acc.Messages(), acc2.Messages())
+}
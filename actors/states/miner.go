@@ -0,0 +1,63 @@
+package states
+
+import (
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// A summary of the state of a single miner at an epoch, for auditing a miner from a chain snapshot.
+type MinerStateSummary struct {
+	// Power, sector counts and deal summaries from the miner's deadlines and partitions.
+	*miner.StateSummary
+	InitialPledge     abi.TokenAmount
+	PreCommitDeposits abi.TokenAmount
+	// Funds in the vesting table, including any which have vested by the epoch but not yet been unlocked.
+	LockedFunds abi.TokenAmount
+	// Funds in the vesting table which have vested by the epoch, and will be unlocked by the miner's next
+	// vesting operation.
+	VestedFunds abi.TokenAmount
+	FeeDebt     abi.TokenAmount
+	// Balance available to withdraw at the epoch, once vested funds are unlocked. Negative if the miner is
+	// in debt.
+	AvailableBalance abi.TokenAmount
+	// Number of deadlines with early terminations awaiting processing.
+	EarlyTerminationDeadlines uint64
+}
+
+// Checks the invariants of a single miner's state, including its deadlines, partitions, expiration queues and
+// early termination queues, returning a summary of the state at an epoch.
+// Invariants relating the miner to other actors, such as its power claim, are not checked.
+func CheckMinerStateInvariants(store adt.Store, st *miner.State, balance abi.TokenAmount, epoch abi.ChainEpoch) (*MinerStateSummary, *builtin.MessageAccumulator) {
+	stateSummary, acc := miner.CheckStateInvariants(st, store, balance)
+	summary := &MinerStateSummary{
+		StateSummary:      stateSummary,
+		InitialPledge:     st.InitialPledge,
+		PreCommitDeposits: st.PreCommitDeposits,
+		LockedFunds:       st.LockedFunds,
+		VestedFunds:       big.Zero(),
+		FeeDebt:           st.FeeDebt,
+		AvailableBalance:  big.Subtract(balance, st.LockedFunds, st.PreCommitDeposits, st.InitialPledge, st.FeeDebt),
+	}
+
+	if funds, err := st.LoadVestingFunds(store); err != nil {
+		acc.Addf("error loading vesting funds: %v", err)
+	} else {
+		for _, vf := range funds.Funds {
+			if vf.Epoch < epoch {
+				summary.VestedFunds = big.Add(summary.VestedFunds, vf.Amount)
+			}
+		}
+		summary.AvailableBalance = big.Add(summary.AvailableBalance, summary.VestedFunds)
+	}
+
+	if count, err := st.EarlyTerminations.Count(); err != nil {
+		acc.Addf("error counting early terminations: %v", err)
+	} else {
+		summary.EarlyTerminationDeadlines = count
+	}
+	return summary, acc
+}
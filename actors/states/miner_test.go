@@ -0,0 +1,59 @@
+package states_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/power"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+	vm "github.com/filecoin-project/specs-actors/v7/support/vm"
+)
+
+func TestCheckMinerStateInvariants(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t, ipld.NewBlockStoreInMemory())
+	addrs := vm.CreateAccounts(ctx, t, v, 1, big.Mul(big.NewInt(10_000), big.NewInt(1e18)), 93837778)
+	balance := big.NewInt(1e18)
+	ret := vm.ApplyOk(t, v, addrs[0], builtin.StoragePowerActorAddr, balance, builtin.MethodsPower.CreateMiner, &power.CreateMinerParams{
+		Owner:               addrs[0],
+		Worker:              addrs[0],
+		WindowPoStProofType: abi.RegisteredPoStProof_StackedDrgWindow32GiBV1,
+		Peer:                abi.PeerID("not really a peer id"),
+	})
+	minerAddrs, ok := ret.(*power.CreateMinerReturn)
+	require.True(t, ok)
+
+	var st miner.State
+	require.NoError(t, v.GetState(minerAddrs.IDAddress, &st))
+	store := v.Store()
+	reward := big.NewInt(1000)
+	_, err := st.AddLockedFunds(store, 0, reward, miner.RewardVestingSpec())
+	require.NoError(t, err)
+	// The miner actor enrolls in deadline cron when locking funds.
+	st.DeadlineCronActive = true
+
+	summary, acc := states.CheckMinerStateInvariants(store, &st, balance, 0)
+	assert.True(t, acc.IsEmpty(), acc.Messages())
+	assert.True(t, summary.LivePower.IsZero())
+	assert.Zero(t, summary.LiveSectors)
+	assert.Zero(t, summary.FaultySectors)
+	assert.Equal(t, reward, summary.LockedFunds)
+	assert.True(t, summary.VestedFunds.IsZero())
+	assert.Equal(t, big.Sub(balance, reward), summary.AvailableBalance)
+
+	// Once all the reward has vested, it is available, though still in the vesting table.
+	vestEnd := miner.RewardVestingSpec().InitialDelay + miner.RewardVestingSpec().VestPeriod + miner.RewardVestingSpec().Quantization + 1
+	summary, acc = states.CheckMinerStateInvariants(store, &st, balance, vestEnd)
+	assert.True(t, acc.IsEmpty(), acc.Messages())
+	assert.Equal(t, reward, summary.LockedFunds)
+	assert.Equal(t, reward, summary.VestedFunds)
+	assert.Equal(t, balance, summary.AvailableBalance)
+}
@@ -0,0 +1,77 @@
+package states
+
+import (
+	"fmt"
+	"sync"
+
+	addr "github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+)
+
+// An additional invariant check, run alongside the built-in checks by CheckStateInvariants and
+// CheckStateInvariantsParallel.
+// A new instance is created for each check of a state tree, and its methods are never called concurrently.
+type InvariantCheck interface {
+	// Checks the state of one actor, after the built-in checks of that actor.
+	// The state is loaded into the actor's state type, e.g. *miner.State, or nil for the system actor.
+	// Actors are checked in the iteration order of the state tree.
+	CheckActor(store adt.Store, key addr.Address, actor *Actor, state interface{}, acc *builtin.MessageAccumulator)
+	// Performs any checks across actors, after all actors have been checked.
+	CheckTree(acc *builtin.MessageAccumulator)
+}
+
+type registeredCheck struct {
+	name     string
+	newCheck func() InvariantCheck
+}
+
+// An instance of a registered check, for a check of one state tree.
+type pluginCheck struct {
+	name  string
+	check InvariantCheck
+}
+
+var (
+	registeredChecksLk sync.Mutex
+	registeredChecks   []registeredCheck
+)
+
+// Registers an additional invariant check, e.g. from a package's init function.
+// Messages from the check are prefixed with its name. Checks run in order of registration.
+// Panics if a check with the same name is already registered.
+func RegisterInvariantCheck(name string, newCheck func() InvariantCheck) {
+	registeredChecksLk.Lock()
+	defer registeredChecksLk.Unlock()
+	for _, c := range registeredChecks {
+		if c.name == name {
+			panic(fmt.Sprintf("invariant check %s already registered", name))
+		}
+	}
+	registeredChecks = append(registeredChecks, registeredCheck{name: name, newCheck: newCheck})
+}
+
+// Removes a registered invariant check, returning whether it was registered.
+func UnregisterInvariantCheck(name string) bool {
+	registeredChecksLk.Lock()
+	defer registeredChecksLk.Unlock()
+	for i, c := range registeredChecks {
+		if c.name == name {
+			registeredChecks = append(registeredChecks[:i:i], registeredChecks[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Instantiates the registered checks for a check of a state tree.
+func newPluginChecks() []pluginCheck {
+	registeredChecksLk.Lock()
+	defer registeredChecksLk.Unlock()
+	checks := make([]pluginCheck, len(registeredChecks))
+	for i, c := range registeredChecks {
+		checks[i] = pluginCheck{name: c.name, check: c.newCheck()}
+	}
+	return checks
+}
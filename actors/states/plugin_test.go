@@ -0,0 +1,75 @@
+package states_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/reward"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+	vm "github.com/filecoin-project/specs-actors/v7/support/vm"
+)
+
+// Reports the reward actor's effective network time, and the number of actors checked.
+type testCheck struct {
+	actors int
+}
+
+func (c *testCheck) CheckActor(_ adt.Store, _ address.Address, _ *states.Actor, state interface{}, acc *builtin.MessageAccumulator) {
+	c.actors++
+	if st, ok := state.(*reward.State); ok {
+		acc.Addf("effective network time %d", st.EffectiveNetworkTime)
+	}
+}
+
+func (c *testCheck) CheckTree(acc *builtin.MessageAccumulator) {
+	acc.Addf("checked %d actors", c.actors)
+}
+
+func TestRegisterInvariantCheck(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t, ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	tree, err := v.GetStateTree()
+	require.NoError(t, err)
+	total, err := v.GetTotalActorBalance()
+	require.NoError(t, err)
+	actorCount := 0
+	require.NoError(t, tree.ForEach(func(_ address.Address, _ *states.Actor) error {
+		actorCount++
+		return nil
+	}))
+	var rewardSt reward.State
+	require.NoError(t, v.GetState(builtin.RewardActorAddr, &rewardSt))
+
+	without, err := states.CheckStateInvariants(tree, total, 0)
+	require.NoError(t, err)
+
+	states.RegisterInvariantCheck("test", func() states.InvariantCheck { return &testCheck{} })
+	defer states.UnregisterInvariantCheck("test")
+	assert.Panics(t, func() {
+		states.RegisterInvariantCheck("test", func() states.InvariantCheck { return &testCheck{} })
+	})
+
+	expected := append(without.Messages(),
+		fmt.Sprintf("%v test: effective network time %d", builtin.RewardActorAddr, rewardSt.EffectiveNetworkTime),
+		fmt.Sprintf("test: checked %d actors", actorCount),
+	)
+	for _, workers := range []uint{1, 4} {
+		acc, err := states.CheckStateInvariantsParallel(tree, total, 0, states.CheckConfig{MaxWorkers: workers})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, expected, acc.Messages())
+	}
+
+	assert.True(t, states.UnregisterInvariantCheck("test"))
+	assert.False(t, states.UnregisterInvariantCheck("test"))
+	acc, err := states.CheckStateInvariants(tree, total, 0)
+	require.NoError(t, err)
+	assert.Equal(t, without.Messages(), acc.Messages())
+}
@@ -0,0 +1,129 @@
+package states
+
+import (
+	"bytes"
+
+	addr "github.com/filecoin-project/go-address"
+	hamt "github.com/filecoin-project/go-hamt-ipld/v3"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+)
+
+// Statistics describing a state tree, e.g. for upgrade planning and capacity analysis.
+type TreeSummary struct {
+	// Number of actors in the tree.
+	ActorCount uint64
+	// Sum of all actor balances.
+	TotalBalance abi.TokenAmount
+	// Statistics for the actors with each code CID.
+	ActorTypes map[cid.Cid]*ActorTypeSummary
+	// Shape of the HAMT mapping addresses to actors.
+	HAMT HAMTSummary
+}
+
+// Statistics for the actors with a single code CID.
+type ActorTypeSummary struct {
+	// Name of the actor type, from builtin.ActorNameByCode.
+	Name  string
+	Count uint64
+	// Sum of the balances of the actors.
+	Balance abi.TokenAmount
+	// Total encoded size of the actors' head state objects. Blocks linked from the heads are not included.
+	HeadBytes uint64
+}
+
+// Statistics describing the shape of a HAMT.
+type HAMTSummary struct {
+	// Number of HAMT nodes.
+	Nodes uint64
+	// Total encoded size of the HAMT nodes, which includes the actor records.
+	Bytes uint64
+	// Depth of the deepest node, where the root has depth zero.
+	MaxDepth int
+	// Number of entries held in nodes at each depth.
+	EntriesByDepth []uint64
+}
+
+// An estimate of the size of the state, being the sizes of the state tree HAMT nodes and the actor head objects.
+func (s *TreeSummary) EstimatedBytes() uint64 {
+	total := s.HAMT.Bytes
+	for _, t := range s.ActorTypes { // nolint:nomaprange // Order doesn't matter for a sum.
+		total += t.HeadBytes
+	}
+	return total
+}
+
+// Computes statistics describing a state tree.
+// The tree is flushed in order to walk its HAMT nodes.
+func Summarize(tree *Tree) (*TreeSummary, error) {
+	summary := &TreeSummary{
+		TotalBalance: big.Zero(),
+		ActorTypes:   map[cid.Cid]*ActorTypeSummary{},
+	}
+	if err := tree.ForEach(func(_ addr.Address, actor *Actor) error {
+		typeSummary, ok := summary.ActorTypes[actor.Code]
+		if !ok {
+			typeSummary = &ActorTypeSummary{
+				Name:    builtin.ActorNameByCode(actor.Code),
+				Balance: big.Zero(),
+			}
+			summary.ActorTypes[actor.Code] = typeSummary
+		}
+		var head cbg.Deferred
+		if err := tree.Store.Get(tree.Store.Context(), actor.Head, &head); err != nil {
+			return xerrors.Errorf("failed to load head %v: %w", actor.Head, err)
+		}
+		typeSummary.Count++
+		typeSummary.Balance = big.Add(typeSummary.Balance, actor.Balance)
+		typeSummary.HeadBytes += uint64(len(head.Raw))
+		summary.ActorCount++
+		summary.TotalBalance = big.Add(summary.TotalBalance, actor.Balance)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	root, err := tree.Flush()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to flush state tree: %w", err)
+	}
+	if err := summarizeHAMTNode(tree, root, 0, &summary.HAMT); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+func summarizeHAMTNode(tree *Tree, c cid.Cid, depth int, summary *HAMTSummary) error {
+	var raw cbg.Deferred
+	if err := tree.Store.Get(tree.Store.Context(), c, &raw); err != nil {
+		return xerrors.Errorf("failed to load HAMT node %v: %w", c, err)
+	}
+	var node hamt.Node
+	if err := node.UnmarshalCBOR(bytes.NewReader(raw.Raw)); err != nil {
+		return xerrors.Errorf("failed to decode HAMT node %v: %w", c, err)
+	}
+
+	summary.Nodes++
+	summary.Bytes += uint64(len(raw.Raw))
+	if depth > summary.MaxDepth {
+		summary.MaxDepth = depth
+	}
+	for len(summary.EntriesByDepth) <= depth {
+		summary.EntriesByDepth = append(summary.EntriesByDepth, 0)
+	}
+	for _, p := range node.Pointers {
+		if p.Link.Defined() {
+			if err := summarizeHAMTNode(tree, p.Link, depth+1, summary); err != nil {
+				return err
+			}
+		} else {
+			summary.EntriesByDepth[depth] += uint64(len(p.KVs))
+		}
+	}
+	return nil
+}
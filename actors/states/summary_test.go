@@ -0,0 +1,53 @@
+package states_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+	vm "github.com/filecoin-project/specs-actors/v7/support/vm"
+)
+
+func TestSummarize(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t, ipld.NewBlockStoreInMemory())
+	tree, err := v.GetStateTree()
+	require.NoError(t, err)
+
+	summary, err := states.Summarize(tree)
+	require.NoError(t, err)
+
+	total, err := v.GetTotalActorBalance()
+	require.NoError(t, err)
+	assert.Equal(t, total, summary.TotalBalance)
+
+	count := uint64(0)
+	balance := big.Zero()
+	for _, typeSummary := range summary.ActorTypes {
+		count += typeSummary.Count
+		balance = big.Add(balance, typeSummary.Balance)
+		assert.NotZero(t, typeSummary.HeadBytes)
+	}
+	assert.Equal(t, summary.ActorCount, count)
+	assert.Equal(t, summary.TotalBalance, balance)
+
+	reward := summary.ActorTypes[builtin.RewardActorCodeID]
+	require.NotNil(t, reward)
+	assert.Equal(t, "fil/7/reward", reward.Name)
+	assert.Equal(t, uint64(1), reward.Count)
+	rewardActor, found, err := tree.GetActor(builtin.RewardActorAddr)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, rewardActor.Balance, reward.Balance)
+
+	assert.NotZero(t, summary.HAMT.Nodes)
+	assert.NotZero(t, summary.HAMT.Bytes)
+	assert.Len(t, summary.HAMT.EntriesByDepth, summary.HAMT.MaxDepth+1)
+	assert.Greater(t, summary.EstimatedBytes(), summary.HAMT.Bytes)
+}
@@ -0,0 +1,111 @@
+package states
+
+import (
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/multisig"
+)
+
+// A breakdown of the token supply held in a state tree, at some epoch.
+// Every locked amount is part of the balance of some actor, and so also of the total.
+type Supply struct {
+	// Sum of all actor balances.
+	Total abi.TokenAmount
+	// Balance of the burnt funds actor.
+	Burnt abi.TokenAmount
+	// Balance of the reward actor, which is yet to be paid out as block rewards.
+	Unmined abi.TokenAmount
+	// Sum of miner initial pledge.
+	Pledge abi.TokenAmount
+	// Sum of miner pre-commit deposits.
+	PreCommitDeposits abi.TokenAmount
+	// Sum of miner funds locked in vesting tables, which have not vested by the epoch.
+	MinerVesting abi.TokenAmount
+	// Client and provider collateral and client storage fees locked in the market actor.
+	MarketLocked abi.TokenAmount
+	// Sum of multisig balances still locked at the epoch.
+	MultisigLocked abi.TokenAmount
+}
+
+// The tokens free to circulate: the total less burnt, unmined and locked tokens.
+func (s *Supply) Circulating() abi.TokenAmount {
+	return big.Sum(s.Total,
+		s.Burnt.Neg(),
+		s.Unmined.Neg(),
+		s.Pledge.Neg(),
+		s.PreCommitDeposits.Neg(),
+		s.MinerVesting.Neg(),
+		s.MarketLocked.Neg(),
+		s.MultisigLocked.Neg(),
+	)
+}
+
+// Computes the breakdown of the token supply held in a state tree at an epoch, using the actors' own
+// accounting of locked funds.
+// Funds in miner vesting tables which have vested by the epoch, but not yet been unlocked by the miner, are
+// considered circulating, as are multisig funds which have been unlocked but not yet spent.
+func ComputeTotalSupply(tree *Tree, epoch abi.ChainEpoch) (*Supply, error) {
+	supply := &Supply{
+		Total:             big.Zero(),
+		Burnt:             big.Zero(),
+		Unmined:           big.Zero(),
+		Pledge:            big.Zero(),
+		PreCommitDeposits: big.Zero(),
+		MinerVesting:      big.Zero(),
+		MarketLocked:      big.Zero(),
+		MultisigLocked:    big.Zero(),
+	}
+	if err := tree.ForEach(func(key addr.Address, actor *Actor) error {
+		supply.Total = big.Add(supply.Total, actor.Balance)
+		if key == builtin.BurntFundsActorAddr {
+			supply.Burnt = big.Add(supply.Burnt, actor.Balance)
+		}
+
+		switch actor.Code {
+		case builtin.RewardActorCodeID:
+			supply.Unmined = big.Add(supply.Unmined, actor.Balance)
+		case builtin.StorageMinerActorCodeID:
+			var st miner.State
+			if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
+				return xerrors.Errorf("failed to load miner %v state: %w", key, err)
+			}
+			supply.Pledge = big.Add(supply.Pledge, st.InitialPledge)
+			supply.PreCommitDeposits = big.Add(supply.PreCommitDeposits, st.PreCommitDeposits)
+			funds, err := st.LoadVestingFunds(tree.Store)
+			if err != nil {
+				return xerrors.Errorf("failed to load miner %v vesting funds: %w", key, err)
+			}
+			for _, vf := range funds.Funds {
+				if vf.Epoch >= epoch {
+					supply.MinerVesting = big.Add(supply.MinerVesting, vf.Amount)
+				}
+			}
+		case builtin.StorageMarketActorCodeID:
+			var st market.State
+			if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
+				return xerrors.Errorf("failed to load market state: %w", err)
+			}
+			supply.MarketLocked = big.Sum(supply.MarketLocked,
+				st.TotalClientLockedCollateral,
+				st.TotalProviderLockedCollateral,
+				st.TotalClientStorageFee,
+			)
+		case builtin.MultisigActorCodeID:
+			var st multisig.State
+			if err := tree.Store.Get(tree.Store.Context(), actor.Head, &st); err != nil {
+				return xerrors.Errorf("failed to load multisig %v state: %w", key, err)
+			}
+			supply.MultisigLocked = big.Add(supply.MultisigLocked, st.AmountLocked(epoch-st.StartEpoch))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return supply, nil
+}
@@ -0,0 +1,76 @@
+package states_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/multisig"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+	vm "github.com/filecoin-project/specs-actors/v7/support/vm"
+)
+
+func TestComputeTotalSupply(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t, ipld.NewBlockStoreInMemory())
+	tree, err := v.GetStateTree()
+	require.NoError(t, err)
+
+	burnt, found, err := tree.GetActor(builtin.BurntFundsActorAddr)
+	require.NoError(t, err)
+	require.True(t, found)
+	burnt.Balance = abi.NewTokenAmount(1_000)
+	require.NoError(t, tree.SetActor(builtin.BurntFundsActorAddr, burnt))
+
+	// A multisig unlocking its whole balance linearly over 10 epochs.
+	pending, err := adt.StoreEmptyMap(tree.Store, builtin.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	msigAddr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	head, err := tree.Store.Put(ctx, &multisig.State{
+		Signers:               []address.Address{msigAddr},
+		NumApprovalsThreshold: 1,
+		InitialBalance:        abi.NewTokenAmount(100),
+		StartEpoch:            10,
+		UnlockDuration:        10,
+		PendingTxns:           pending,
+	})
+	require.NoError(t, err)
+	require.NoError(t, tree.SetActor(msigAddr, &states.Actor{
+		Code:    builtin.MultisigActorCodeID,
+		Head:    head,
+		Balance: abi.NewTokenAmount(100),
+	}))
+
+	reward, found, err := tree.GetActor(builtin.RewardActorAddr)
+	require.NoError(t, err)
+	require.True(t, found)
+	total := big.Zero()
+	require.NoError(t, tree.ForEach(func(_ address.Address, actor *states.Actor) error {
+		total = big.Add(total, actor.Balance)
+		return nil
+	}))
+
+	for _, tc := range []struct {
+		epoch  abi.ChainEpoch
+		locked int64
+	}{{0, 100}, {15, 50}, {20, 0}} {
+		supply, err := states.ComputeTotalSupply(tree, tc.epoch)
+		require.NoError(t, err)
+		assert.Equal(t, total, supply.Total)
+		assert.Equal(t, abi.NewTokenAmount(1_000), supply.Burnt)
+		assert.Equal(t, reward.Balance, supply.Unmined)
+		assert.Equal(t, abi.NewTokenAmount(tc.locked), supply.MultisigLocked)
+		assert.True(t, supply.MarketLocked.IsZero())
+		expected := big.Sum(total, burnt.Balance.Neg(), reward.Balance.Neg(), abi.NewTokenAmount(-tc.locked))
+		assert.Equal(t, expected, supply.Circulating())
+	}
+}
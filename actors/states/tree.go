@@ -74,6 +74,14 @@ func (t *Tree) SetActor(addr address.Address, actor *Actor) error {
 	return t.Map.Put(abi.AddrKey(addr), actor)
 }
 
+// Removes the state associated with an address, failing if it is not present.
+func (t *Tree) DeleteActor(addr address.Address) error {
+	if addr.Protocol() != address.ID {
+		return xerrors.Errorf("non-ID address %v invalid as actor key", addr)
+	}
+	return t.Map.Delete(abi.AddrKey(addr))
+}
+
 // Traverses all entries in the tree.
 func (t *Tree) ForEach(fn func(addr address.Address, actor *Actor) error) error {
 	var val Actor
@@ -146,6 +146,28 @@ func TestSetCache(t *testing.T) {
 	}
 }
 
+func TestDeleteActor(t *testing.T) {
+	store := ipld.NewADTStore(context.Background())
+	st, err := states.NewTree(store)
+	require.NoError(t, err)
+
+	a, err := address.NewIDAddress(uint64(222))
+	require.NoError(t, err)
+	require.NoError(t, st.SetActor(a, &states.Actor{
+		Code:    builtin.AccountActorCodeID,
+		Head:    builtin.AccountActorCodeID,
+		Balance: big.NewInt(0),
+	}))
+
+	require.NoError(t, st.DeleteActor(a))
+	_, found, err := st.GetActor(a)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// Deleting an absent actor fails.
+	require.Error(t, st.DeleteActor(a))
+}
+
 func TestStateTreeConsistency(t *testing.T) {
 	store := ipld.NewADTStore(context.Background())
 	st, err := states.NewTree(store)
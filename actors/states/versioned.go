@@ -0,0 +1,18 @@
+package states
+
+import (
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+)
+
+// A state tree of any actors version.
+// The trees of all versions share the Actor type, so the Tree of each versioned states package satisfies this
+// interface directly, allowing migrations and tooling to handle trees of several versions with the same code.
+type VersionedTree interface {
+	GetActor(addr address.Address) (*Actor, bool, error)
+	SetActor(addr address.Address, actor *Actor) error
+	ForEach(fn func(addr address.Address, actor *Actor) error) error
+	Flush() (cid.Cid, error)
+}
+
+var _ VersionedTree = (*Tree)(nil)
@@ -251,7 +251,7 @@ func TestCommitPoStFlow(t *testing.T) {
 				{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.OnEpochTickEnd, SubInvocations: []vm.ExpectInvocation{
 					{To: builtin.RewardActorAddr, Method: builtin.MethodsReward.ThisEpochReward},
 					{To: minerAddrs.IDAddress, Method: builtin.MethodsMiner.OnDeferredCronEvent, SubInvocations: []vm.ExpectInvocation{
-						{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.EnrollCronEvent},
+						{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.EnrollPriorityCronEvent},
 					}},
 					{To: builtin.RewardActorAddr, Method: builtin.MethodsReward.UpdateNetworkKPI},
 				}},
@@ -473,7 +473,7 @@ func TestBatchOnboarding(t *testing.T) {
 		{ // Pre: 30, Proven: 8
 			epochDelay:               miner.PreCommitChallengeDelay() + 1,
 			proveCommitSectorCount:   8,
-			proveCommitAggregateSize: miner.MaxAggregatedSectors,
+			proveCommitAggregateSize: int(miner.MaxAggregatedSectors()),
 		},
 		{ // Pre: 30, Proven: 16 (spanning pre-commit batches)
 			epochDelay:               1,
@@ -592,7 +592,7 @@ func TestAggregateOnePreCommitExpires(t *testing.T) {
 	// Assert that we have a valid aggregate batch size
 	aggSectorsCount, err := sectorNosBf.Count()
 	require.NoError(t, err)
-	require.True(t, aggSectorsCount >= miner.MinAggregatedSectors && aggSectorsCount < miner.MaxAggregatedSectors)
+	require.True(t, aggSectorsCount >= miner.MinAggregatedSectors() && aggSectorsCount < miner.MaxAggregatedSectors())
 
 	proveCommitAggregateParams := miner.ProveCommitAggregateParams{
 		SectorNumbers: sectorNosBf,
@@ -664,7 +664,7 @@ func TestAggregateSizeLimits(t *testing.T) {
 	assert.Equal(t, exitcode.ErrIllegalArgument, res.Code) // fail with too many aggregates
 
 	// Fail with too few sectors
-	tooFewSectorNosBf := precommitSectorNumbers(precommits[:miner.MinAggregatedSectors-1])
+	tooFewSectorNosBf := precommitSectorNumbers(precommits[:miner.MinAggregatedSectors()-1])
 	proveCommitAggregateTooFewParams := miner.ProveCommitAggregateParams{
 		SectorNumbers: tooFewSectorNosBf,
 	}
@@ -672,10 +672,10 @@ func TestAggregateSizeLimits(t *testing.T) {
 	assert.Equal(t, exitcode.ErrIllegalArgument, res.Code)
 
 	// Fail with proof too big
-	justRightSectorNosBf := precommitSectorNumbers(precommits[:miner.MaxAggregatedSectors])
+	justRightSectorNosBf := precommitSectorNumbers(precommits[:miner.MaxAggregatedSectors()])
 	proveCommitAggregateTooBigProofParams := miner.ProveCommitAggregateParams{
 		SectorNumbers:  justRightSectorNosBf,
-		AggregateProof: make([]byte, miner.MaxAggregateProofSize+1),
+		AggregateProof: make([]byte, miner.MaxAggregateProofSize()+1),
 	}
 	res = vm.RequireApplyMessage(t, v, addrs[0], minerAddrs.RobustAddress, big.Zero(), builtin.MethodsMiner.ProveCommitAggregate, &proveCommitAggregateTooBigProofParams, t.Name())
 	assert.Equal(t, exitcode.ErrIllegalArgument, res.Code)
@@ -869,7 +869,7 @@ func preCommitSectors(t *testing.T, v *vm.VM, count, batchSize int, worker, mAdd
 		{To: builtin.RewardActorAddr, Method: builtin.MethodsReward.ThisEpochReward},
 		{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.CurrentTotalPower},
 	}
-	invocFirst := vm.ExpectInvocation{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.EnrollCronEvent}
+	invocFirst := vm.ExpectInvocation{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.EnrollPriorityCronEvent}
 
 	sectorIndex := 0
 	for sectorIndex < count {
@@ -35,13 +35,15 @@ func createMiner(t *testing.T, v *vm.VM, owner, worker addr.Address, wPoStProof
 func publishDeal(t *testing.T, v *vm.VM, provider, dealClient, minerID addr.Address, dealLabel string,
 	pieceSize abi.PaddedPieceSize, verifiedDeal bool, dealStart abi.ChainEpoch, dealLifetime abi.ChainEpoch,
 ) *market.PublishStorageDealsReturn {
+	label, err := market.NewLabelFromString(dealLabel)
+	require.NoError(t, err)
 	deal := market.DealProposal{
 		PieceCID:             tutil.MakeCID(dealLabel, &market.PieceCIDPrefix),
 		PieceSize:            pieceSize,
 		VerifiedDeal:         verifiedDeal,
 		Client:               dealClient,
 		Provider:             minerID,
-		Label:                dealLabel,
+		Label:                label,
 		StartEpoch:           dealStart,
 		EndEpoch:             dealStart + dealLifetime,
 		StoragePricePerEpoch: abi.NewTokenAmount(1 << 20),
@@ -97,13 +99,15 @@ func newDealBatcher(v *vm.VM) *dealBatcher {
 
 func (db *dealBatcher) stage(t *testing.T, dealClient, dealProvider addr.Address, dealLabel string, pieceSize abi.PaddedPieceSize, verifiedDeal bool, dealStart,
 	dealLifetime abi.ChainEpoch, pricePerEpoch, providerCollateral, clientCollateral abi.TokenAmount) {
+	label, err := market.NewLabelFromString(dealLabel)
+	require.NoError(t, err)
 	deal := market.DealProposal{
 		PieceCID:             tutil.MakeCID(dealLabel, &market.PieceCIDPrefix),
 		PieceSize:            pieceSize,
 		VerifiedDeal:         verifiedDeal,
 		Client:               dealClient,
 		Provider:             dealProvider,
-		Label:                dealLabel,
+		Label:                label,
 		StartEpoch:           dealStart,
 		EndEpoch:             dealStart + dealLifetime,
 		StoragePricePerEpoch: pricePerEpoch,
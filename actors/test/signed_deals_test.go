@@ -0,0 +1,247 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/go-state-types/exitcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/power"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/verifreg"
+	"github.com/filecoin-project/specs-actors/v7/actors/states"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
+	"github.com/filecoin-project/specs-actors/v7/support/vm"
+)
+
+func TestProveCommitWithSignedDeals(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t, ipld.NewBlockStoreInMemory())
+	addrs := vm.CreateAccounts(ctx, t, v, 3, big.Mul(big.NewInt(10_000), vm.FIL), 93837778)
+	worker, verifier, verifiedClient := addrs[0], addrs[1], addrs[2]
+
+	minerBalance := big.Mul(big.NewInt(1_000), vm.FIL)
+	sectorNumber := abi.SectorNumber(100)
+	sealedCid := tutil.MakeCID("100", &miner.SealedCIDPrefix)
+	sealProof := abi.RegisteredSealProof_StackedDrg32GiBV1_1
+
+	v, err := v.WithEpoch(200)
+	require.NoError(t, err)
+
+	// create miner
+	params := power.CreateMinerParams{
+		Owner:               worker,
+		Worker:              worker,
+		WindowPoStProofType: abi.RegisteredPoStProof_StackedDrgWindow32GiBV1,
+		Peer:                abi.PeerID("not really a peer id"),
+	}
+	ret := vm.ApplyOk(t, v, worker, builtin.StoragePowerActorAddr, minerBalance, builtin.MethodsPower.CreateMiner, &params)
+	minerAddrs, ok := ret.(*power.CreateMinerReturn)
+	require.True(t, ok)
+
+	// register verifier then verified client
+	addVerifierParams := verifreg.AddVerifierParams{
+		Address:   verifier,
+		Allowance: abi.NewStoragePower(32 << 40),
+	}
+	vm.ApplyOk(t, v, vm.VerifregRoot, builtin.VerifiedRegistryActorAddr, big.Zero(), builtin.MethodsVerifiedRegistry.AddVerifier, &addVerifierParams)
+	addClientParams := verifreg.AddVerifiedClientParams{
+		Address:   verifiedClient,
+		Allowance: abi.NewStoragePower(32 << 40),
+	}
+	vm.ApplyOk(t, v, verifier, builtin.VerifiedRegistryActorAddr, big.Zero(), builtin.MethodsVerifiedRegistry.AddVerifiedClient, &addClientParams)
+
+	// add market collateral for client and miner
+	collateral := big.Mul(big.NewInt(3), vm.FIL)
+	vm.ApplyOk(t, v, verifiedClient, builtin.StorageMarketActorAddr, collateral, builtin.MethodsMarket.AddBalance, &verifiedClient)
+	collateral = big.Mul(big.NewInt(64), vm.FIL)
+	vm.ApplyOk(t, v, worker, builtin.StorageMarketActorAddr, collateral, builtin.MethodsMarket.AddBalance, &minerAddrs.IDAddress)
+
+	// precommit a sector without deals, to be proven when its deal starts
+	proveTime := v.GetEpoch() + miner.PreCommitChallengeDelay() + 1
+	dealStart := proveTime
+	dealLifetime := 240 * builtin.EpochsInDay()
+	preCommitParams := miner.PreCommitSectorParams{
+		SealProof:     sealProof,
+		SectorNumber:  sectorNumber,
+		SealedCID:     sealedCid,
+		SealRandEpoch: v.GetEpoch() - 1,
+		Expiration:    dealStart + dealLifetime,
+	}
+	vm.ApplyOk(t, v, worker, minerAddrs.RobustAddress, big.Zero(), builtin.MethodsMiner.PreCommitSector, &preCommitParams)
+
+	// the client signs a verified deal for the whole sector, which is never published
+	label, err := market.NewLabelFromString("signed deal")
+	require.NoError(t, err)
+	deal := market.ClientDealProposal{
+		Proposal: market.DealProposal{
+			PieceCID:             tutil.MakeCID("signed deal", &market.PieceCIDPrefix),
+			PieceSize:            32 << 30,
+			VerifiedDeal:         true,
+			Client:               verifiedClient,
+			Provider:             minerAddrs.IDAddress,
+			Label:                label,
+			StartEpoch:           dealStart,
+			EndEpoch:             dealStart + dealLifetime,
+			StoragePricePerEpoch: abi.NewTokenAmount(1 << 20),
+			ProviderCollateral:   big.Mul(big.NewInt(2), vm.FIL),
+			ClientCollateral:     big.Mul(big.NewInt(1), vm.FIL),
+		},
+		ClientSignature: crypto.Signature{Type: crypto.SigTypeBLS},
+	}
+
+	// prove the sector with the signed deal once the interactive epoch has passed
+	v, _ = vm.AdvanceByDeadlineTillEpoch(t, v, minerAddrs.IDAddress, proveTime)
+	v, err = v.WithEpoch(proveTime)
+	require.NoError(t, err)
+
+	proveParams := miner.ProveCommitWithSignedDealsParams{
+		SectorNumber: sectorNumber,
+		Deals:        []market.ClientDealProposal{deal},
+	}
+	vm.ApplyOk(t, v, worker, minerAddrs.RobustAddress, big.Zero(), builtin.MethodsMiner.ProveCommitWithSignedDeals, &proveParams)
+	vm.ExpectInvocation{
+		To:     minerAddrs.IDAddress,
+		Method: builtin.MethodsMiner.ProveCommitWithSignedDeals,
+		SubInvocations: []vm.ExpectInvocation{
+			{To: builtin.StorageMarketActorAddr, Method: builtin.MethodsMarket.ActivateSignedDeals, SubInvocations: []vm.ExpectInvocation{
+				{To: builtin.RewardActorAddr, Method: builtin.MethodsReward.ThisEpochReward},
+				{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.CurrentTotalPower},
+				{To: builtin.VerifiedRegistryActorAddr, Method: builtin.MethodsVerifiedRegistry.UseBytes},
+			}},
+			{To: builtin.RewardActorAddr, Method: builtin.MethodsReward.ThisEpochReward},
+			{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.CurrentTotalPower},
+			{To: builtin.StoragePowerActorAddr, Method: builtin.MethodsPower.UpdatePledgeTotal},
+		},
+	}.Matches(t, v.LastInvocation())
+
+	// the sector is active immediately, with the weight of the verified deal
+	var mState miner.State
+	require.NoError(t, v.GetState(minerAddrs.IDAddress, &mState))
+	info, found, err := mState.GetSector(v.Store(), sectorNumber)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Len(t, info.DealIDs, 1)
+	assert.Equal(t, proveTime, info.Activation)
+	assert.Equal(t, big.Zero(), info.DealWeight)
+	assert.Equal(t, big.NewInt(int64(dealLifetime)*(32<<30)), info.VerifiedDealWeight)
+	assert.Equal(t, abi.NewStoragePower(10*(32<<30)), miner.QAPowerForSector(32<<30, info))
+
+	// the deal is published and activated in the sector
+	var marketState market.State
+	require.NoError(t, v.GetState(builtin.StorageMarketActorAddr, &marketState))
+	dealStates, err := market.AsDealStateArray(v.Store(), marketState.States)
+	require.NoError(t, err)
+	dealState, found, err := dealStates.Get(info.DealIDs[0])
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, proveTime, dealState.SectorStartEpoch)
+
+	// the proposal can't then be published again
+	publishParams := market.PublishStorageDealsParams{Deals: []market.ClientDealProposal{deal}}
+	result := vm.RequireApplyMessage(t, v, worker, builtin.StorageMarketActorAddr, big.Zero(), builtin.MethodsMarket.PublishStorageDeals, &publishParams, t.Name())
+	assert.Equal(t, exitcode.ErrIllegalArgument, result.Code)
+
+	// the sector's power is claimed at its first PoSt
+	dlInfo, pIdx, v := vm.AdvanceTillProvingDeadline(t, v, minerAddrs.IDAddress, sectorNumber)
+	submitWindowPoSt(t, v, worker, minerAddrs.IDAddress, dlInfo, []miner.PoStPartition{{Index: pIdx}},
+		miner.NewPowerPair(abi.NewStoragePower(32<<30), abi.NewStoragePower(10*(32<<30))))
+	minerPower := vm.MinerPower(t, v, minerAddrs.IDAddress)
+	assert.Equal(t, abi.NewStoragePower(10*(32<<30)), minerPower.QA)
+
+	// Trigger cron to keep reward accounting correct
+	vm.ApplyOk(t, v, builtin.SystemActorAddr, builtin.CronActorAddr, big.Zero(), builtin.MethodsCron.EpochTick, nil)
+
+	stateTree, err := v.GetStateTree()
+	require.NoError(t, err)
+	totalBalance, err := v.GetTotalActorBalance()
+	require.NoError(t, err)
+	acc, err := states.CheckStateInvariants(stateTree, totalBalance, v.GetEpoch())
+	require.NoError(t, err)
+	assert.True(t, acc.IsEmpty(), strings.Join(acc.Messages(), "\n"))
+}
+
+func TestProveCommitWithSignedDealsRejectsSectorWithDeals(t *testing.T) {
+	ctx := context.Background()
+	v := vm.NewVMWithSingletons(ctx, t, ipld.NewBlockStoreInMemory())
+	addrs := vm.CreateAccounts(ctx, t, v, 2, big.Mul(big.NewInt(10_000), vm.FIL), 93837778)
+	worker, client := addrs[0], addrs[1]
+	sealProof := abi.RegisteredSealProof_StackedDrg32GiBV1_1
+
+	v, err := v.WithEpoch(200)
+	require.NoError(t, err)
+
+	minerAddrs := createMiner(t, v, worker, worker, abi.RegisteredPoStProof_StackedDrgWindow32GiBV1, big.Mul(big.NewInt(1_000), vm.FIL))
+	vm.ApplyOk(t, v, client, builtin.StorageMarketActorAddr, big.Mul(big.NewInt(3), vm.FIL), builtin.MethodsMarket.AddBalance, &client)
+	vm.ApplyOk(t, v, worker, builtin.StorageMarketActorAddr, big.Mul(big.NewInt(64), vm.FIL), builtin.MethodsMarket.AddBalance, &minerAddrs.IDAddress)
+
+	// precommit a sector with a published deal
+	dealStart := v.GetEpoch() + miner.MaxProveCommitDuration()[sealProof]
+	dealLifetime := 180 * builtin.EpochsInDay()
+	deals := publishDeal(t, v, worker, client, minerAddrs.IDAddress, "deal1", 1<<30, false, dealStart, dealLifetime)
+	sectorNumber := abi.SectorNumber(100)
+	preCommitParams := miner.PreCommitSectorParams{
+		SealProof:     sealProof,
+		SectorNumber:  sectorNumber,
+		SealedCID:     tutil.MakeCID("100", &miner.SealedCIDPrefix),
+		SealRandEpoch: v.GetEpoch() - 1,
+		DealIDs:       deals.IDs,
+		Expiration:    dealStart + dealLifetime,
+	}
+	vm.ApplyOk(t, v, worker, minerAddrs.RobustAddress, big.Zero(), builtin.MethodsMiner.PreCommitSector, &preCommitParams)
+
+	proveTime := v.GetEpoch() + miner.PreCommitChallengeDelay() + 1
+	v, _ = vm.AdvanceByDeadlineTillEpoch(t, v, minerAddrs.IDAddress, proveTime)
+	v, err = v.WithEpoch(proveTime)
+	require.NoError(t, err)
+
+	// proving it with further signed deals is rejected, leaving it pre-committed
+	label, err := market.NewLabelFromString("signed deal")
+	require.NoError(t, err)
+	proveParams := miner.ProveCommitWithSignedDealsParams{
+		SectorNumber: sectorNumber,
+		Deals: []market.ClientDealProposal{{
+			Proposal: market.DealProposal{
+				PieceCID:             tutil.MakeCID("signed deal", &market.PieceCIDPrefix),
+				PieceSize:            1 << 30,
+				Client:               client,
+				Provider:             minerAddrs.IDAddress,
+				Label:                label,
+				StartEpoch:           dealStart,
+				EndEpoch:             dealStart + dealLifetime,
+				StoragePricePerEpoch: abi.NewTokenAmount(1 << 20),
+				ProviderCollateral:   big.Mul(big.NewInt(2), vm.FIL),
+				ClientCollateral:     big.Mul(big.NewInt(1), vm.FIL),
+			},
+			ClientSignature: crypto.Signature{Type: crypto.SigTypeBLS},
+		}},
+	}
+	result := vm.RequireApplyMessage(t, v, worker, minerAddrs.RobustAddress, big.Zero(), builtin.MethodsMiner.ProveCommitWithSignedDeals, &proveParams, t.Name())
+	assert.Equal(t, exitcode.ErrIllegalArgument, result.Code)
+
+	var mState miner.State
+	require.NoError(t, v.GetState(minerAddrs.IDAddress, &mState))
+	_, found, err := mState.GetPrecommittedSector(v.Store(), sectorNumber)
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	// Trigger cron to keep reward accounting correct
+	vm.ApplyOk(t, v, builtin.SystemActorAddr, builtin.CronActorAddr, big.Zero(), builtin.MethodsCron.EpochTick, nil)
+
+	stateTree, err := v.GetStateTree()
+	require.NoError(t, err)
+	totalBalance, err := v.GetTotalActorBalance()
+	require.NoError(t, err)
+	acc, err := states.CheckStateInvariants(stateTree, totalBalance, v.GetEpoch())
+	require.NoError(t, err)
+	assert.True(t, acc.IsEmpty(), strings.Join(acc.Messages(), "\n"))
+}
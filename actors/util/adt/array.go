@@ -2,6 +2,7 @@ package adt
 
 import (
 	"bytes"
+	"sort"
 
 	amt "github.com/filecoin-project/go-amt-ipld/v3"
 
@@ -69,6 +70,29 @@ func (a *Array) AppendContinuous(value cbor.Marshaler) error {
 	return nil
 }
 
+// Appends many values to the end of the array, then flushes the modified nodes to the store once.
+// Assumes continuous array, as AppendContinuous.
+// The last value is set first, so that the AMT grows to its final height once rather than as each level fills.
+func (a *Array) AppendAll(values []cbor.Marshaler) error {
+	if len(values) == 0 {
+		return nil
+	}
+	start := a.root.Len()
+	last := len(values) - 1
+	if err := a.root.Set(a.store.Context(), start+uint64(last), values[last]); err != nil {
+		return xerrors.Errorf("append failed to set index %v in root %v: %w", start+uint64(last), a.root, err)
+	}
+	for i, value := range values[:last] {
+		if err := a.root.Set(a.store.Context(), start+uint64(i), value); err != nil {
+			return xerrors.Errorf("append failed to set index %v value %v in root %v: %w", start+uint64(i), value, a.root, err)
+		}
+	}
+	if _, err := a.root.Flush(a.store.Context()); err != nil {
+		return xerrors.Errorf("failed to flush array root: %w", err)
+	}
+	return nil
+}
+
 func (a *Array) Set(i uint64, value cbor.Marshaler) error {
 	if err := a.root.Set(a.store.Context(), i, value); err != nil {
 		return xerrors.Errorf("failed to set index %v value %v in root %v: %w", i, value, a.root, err)
@@ -76,6 +100,33 @@ func (a *Array) Set(i uint64, value cbor.Marshaler) error {
 	return nil
 }
 
+// An index and value to set in an array.
+type ArrayEntry struct {
+	Index uint64
+	Value cbor.Marshaler
+}
+
+// Sets many entries in the array, then flushes the modified nodes to the store once.
+// Entries are set in index order, so that successive sets traverse the same AMT nodes.
+// If an index appears more than once, the last value is set.
+func (a *Array) BatchSet(entries []ArrayEntry) error {
+	sorted := make([]ArrayEntry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Index < sorted[j].Index
+	})
+
+	for _, e := range sorted {
+		if err := a.root.Set(a.store.Context(), e.Index, e.Value); err != nil {
+			return xerrors.Errorf("failed to set index %v value %v in root %v: %w", e.Index, e.Value, a.root, err)
+		}
+	}
+	if _, err := a.root.Flush(a.store.Context()); err != nil {
+		return xerrors.Errorf("failed to flush array root: %w", err)
+	}
+	return nil
+}
+
 // Removes the value at index `i` from the AMT, if it exists.
 // Returns whether the index was previously present.
 func (a *Array) TryDelete(i uint64) (bool, error) {
@@ -120,6 +171,21 @@ func (a *Array) ForEach(out cbor.Unmarshaler, fn func(i int64) error) error {
 	})
 }
 
+// Iterates the entries in the array with index at least `start`, in the same manner as ForEach.
+// Nodes holding only lower indices are not loaded, so iteration can resume from a previous position.
+func (a *Array) ForEachFrom(start uint64, out cbor.Unmarshaler, fn func(i int64) error) error {
+	return a.root.ForEachAt(a.store.Context(), start, func(k uint64, val *cbg.Deferred) error {
+		if out != nil {
+			if deferred, ok := out.(*cbg.Deferred); ok {
+				*deferred = *val
+			} else if err := out.UnmarshalCBOR(bytes.NewReader(val.Raw)); err != nil {
+				return err
+			}
+		}
+		return fn(int64(k))
+	})
+}
+
 func (a *Array) Length() uint64 {
 	return a.root.Len()
 }
@@ -4,10 +4,13 @@ import (
 	"testing"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/cbor"
 	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
 
 	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
 	"github.com/filecoin-project/specs-actors/v7/support/mock"
+	tutil "github.com/filecoin-project/specs-actors/v7/support/testing"
 )
 
 func TestArrayNotFound(t *testing.T) {
@@ -20,3 +23,86 @@ func TestArrayNotFound(t *testing.T) {
 	require.NoError(t, err)
 	require.False(t, found)
 }
+
+func TestArrayBatchSet(t *testing.T) {
+	rt := mock.NewBuilder(address.Undef).Build(t)
+	store := adt.AsStore(rt)
+
+	expected, err := adt.MakeEmptyArray(store, 3)
+	require.NoError(t, err)
+	var entries []adt.ArrayEntry
+	for i := uint64(0); i < 100; i++ {
+		// Set in reverse order, with a duplicate of each index whose later value wins.
+		idx := 99 - i
+		require.NoError(t, expected.Set(idx, cborInt(int64(idx))))
+		entries = append(entries, adt.ArrayEntry{Index: idx, Value: cborInt(-1)}, adt.ArrayEntry{Index: idx, Value: cborInt(int64(idx))})
+	}
+	expectedRoot, err := expected.Root()
+	require.NoError(t, err)
+
+	arr, err := adt.MakeEmptyArray(store, 3)
+	require.NoError(t, err)
+	require.NoError(t, arr.BatchSet(entries))
+	root, err := arr.Root()
+	require.NoError(t, err)
+	require.Equal(t, expectedRoot, root)
+	require.Equal(t, uint64(100), arr.Length())
+
+	var v cbg.CborInt
+	found, err := arr.Get(42, &v)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, cbg.CborInt(42), v)
+}
+
+func TestArrayAppendAll(t *testing.T) {
+	rt := mock.NewBuilder(address.Undef).Build(t)
+	store := adt.AsStore(rt)
+
+	expected, err := adt.MakeEmptyArray(store, 3)
+	require.NoError(t, err)
+	arr, err := adt.MakeEmptyArray(store, 3)
+	require.NoError(t, err)
+	require.NoError(t, arr.AppendAll(nil))
+
+	for _, count := range []int64{1, 7, 100} {
+		var values []cbor.Marshaler
+		for i := int64(0); i < count; i++ {
+			v := cborInt(int64(expected.Length()))
+			require.NoError(t, expected.AppendContinuous(v))
+			values = append(values, v)
+		}
+		require.NoError(t, arr.AppendAll(values))
+		require.Equal(t, expected.Length(), arr.Length())
+		require.Equal(t, tutil.MustRoot(t, expected), tutil.MustRoot(t, arr))
+	}
+}
+
+func TestArrayForEachFrom(t *testing.T) {
+	rt := mock.NewBuilder(address.Undef).Build(t)
+	store := adt.AsStore(rt)
+
+	// Sparse indices spanning several nodes.
+	arr, err := adt.MakeEmptyArray(store, 3)
+	require.NoError(t, err)
+	for i := uint64(0); i < 100; i += 3 {
+		require.NoError(t, arr.Set(i, cborInt(int64(i))))
+	}
+
+	collect := func(start uint64) []int64 {
+		var indices []int64
+		var v cbg.CborInt
+		require.NoError(t, arr.ForEachFrom(start, &v, func(i int64) error {
+			require.Equal(t, cbg.CborInt(i), v)
+			indices = append(indices, i)
+			return nil
+		}))
+		return indices
+	}
+	all := collect(0)
+	require.Len(t, all, 34)
+	require.Equal(t, all[1:], collect(1))
+	require.Equal(t, all[1:], collect(3))
+	require.Equal(t, all[20:], collect(58))
+	require.Empty(t, collect(100))
+}
@@ -1,6 +1,9 @@
 package adt
 
 import (
+	"bytes"
+	"sort"
+
 	addr "github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
@@ -24,8 +27,9 @@ func AsBalanceTable(s Store, r cid.Cid) (*BalanceTable, error) {
 	}
 
 	return &BalanceTable{
-		root:  m.root,
-		store: s,
+		root:     m.root,
+		store:    s,
+		bitwidth: m.bitwidth,
 	}, nil
 }
 
@@ -93,6 +97,73 @@ func (t *BalanceTable) MustSubtract(key addr.Address, req abi.TokenAmount) error
 	return t.Add(key, req.Neg())
 }
 
+// Determines which entries are removed from a balance table after adjustment.
+// Zero balances are always removed.
+type DustPolicy struct {
+	// Positive balances below this amount are removed, and their amounts swept. Zero removes only zero balances.
+	Threshold abi.TokenAmount
+}
+
+// Removes only zero balances.
+var NoDust = DustPolicy{Threshold: big.Zero()}
+
+// Adds many amounts to balances, requiring each resulting balance to be non-negative, then flushes the modified
+// nodes to the store once.
+// Balances are adjusted in order of key hash, so that successive adjustments traverse the same HAMT nodes.
+// Resulting balances removed by the dust policy are returned as the swept total, for the caller to account for.
+// If any resulting balance would be negative, an error is returned and no balance is adjusted.
+func (t *BalanceTable) ApplyDeltas(deltas map[addr.Address]abi.TokenAmount, policy DustPolicy) (abi.TokenAmount, error) {
+	type adjustment struct {
+		key   string
+		hash  []byte
+		value abi.TokenAmount
+	}
+	adjustments := make([]adjustment, 0, len(deltas))
+	for a, delta := range deltas { // nolint:nomaprange // Sorted below.
+		key := abi.AddrKey(a).Key()
+		adjustments = append(adjustments, adjustment{key: key, hash: hashKey([]byte(key)), value: delta})
+	}
+	sort.Slice(adjustments, func(i, j int) bool {
+		return bytes.Compare(adjustments[i].hash, adjustments[j].hash) < 0
+	})
+
+	// Compute all resulting balances before modifying any.
+	swept := big.Zero()
+	ctx := t.store.Context()
+	for i, adj := range adjustments {
+		prev := big.Zero()
+		if _, err := t.root.Find(ctx, adj.key, &prev); err != nil {
+			return big.Zero(), xerrors.Errorf("failed to get balance for key %v: %w", adj.key, err)
+		}
+		sum := big.Add(prev, adj.value)
+		if sum.Sign() < 0 {
+			return big.Zero(), xerrors.Errorf("adding %v to balance %v would give negative: %v", adj.value, prev, sum)
+		}
+		if sum.Sign() > 0 && sum.LessThan(policy.Threshold) {
+			swept = big.Add(swept, sum)
+			sum = big.Zero()
+		}
+		adjustments[i].value = sum
+	}
+
+	for _, adj := range adjustments {
+		if adj.value.IsZero() {
+			if _, err := t.root.Delete(ctx, adj.key); err != nil {
+				return big.Zero(), xerrors.Errorf("failed to delete balance for key %v: %w", adj.key, err)
+			}
+			continue
+		}
+		value := adj.value
+		if err := t.root.Set(ctx, adj.key, &value); err != nil {
+			return big.Zero(), xerrors.Errorf("failed to set balance for key %v: %w", adj.key, err)
+		}
+	}
+	if err := t.root.Flush(ctx); err != nil {
+		return big.Zero(), xerrors.Errorf("failed to flush balance table: %w", err)
+	}
+	return swept, nil
+}
+
 // Returns the total balance held by this BalanceTable
 func (t *BalanceTable) Total() (abi.TokenAmount, error) {
 	total := big.Zero()
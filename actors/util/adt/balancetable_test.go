@@ -172,3 +172,77 @@ func TestSubtractWithMinimum(t *testing.T) {
 		require.EqualValues(t, abi.NewTokenAmount(2), remaining)
 	})
 }
+
+func TestApplyDeltas(t *testing.T) {
+	buildBalanceTable := func() *adt.BalanceTable {
+		rt := mock.NewBuilder(address.Undef).Build(t)
+		store := adt.AsStore(rt)
+		emptyMap, err := adt.MakeEmptyMap(store, adt.BalanceTableBitwidth)
+		require.NoError(t, err)
+
+		bt, err := adt.AsBalanceTable(store, tutil.MustRoot(t, emptyMap))
+		require.NoError(t, err)
+		return bt
+	}
+	addr1 := tutil.NewIDAddr(t, 100)
+	addr2 := tutil.NewIDAddr(t, 101)
+	addr3 := tutil.NewIDAddr(t, 102)
+
+	t.Run("credits and debits", func(t *testing.T) {
+		bt := buildBalanceTable()
+		require.NoError(t, bt.Add(addr1, abi.NewTokenAmount(10)))
+		require.NoError(t, bt.Add(addr2, abi.NewTokenAmount(10)))
+
+		swept, err := bt.ApplyDeltas(map[address.Address]abi.TokenAmount{
+			addr1: abi.NewTokenAmount(-4),
+			addr2: abi.NewTokenAmount(-10),
+			addr3: abi.NewTokenAmount(7),
+		}, adt.NoDust)
+		require.NoError(t, err)
+		assert.Equal(t, big.Zero(), swept)
+
+		for a, expected := range map[address.Address]int64{addr1: 6, addr2: 0, addr3: 7} {
+			amount, err := bt.Get(a)
+			require.NoError(t, err)
+			assert.Equal(t, abi.NewTokenAmount(expected), amount)
+		}
+		// The zero entry is not stored.
+		found, err := ((*adt.Map)(bt)).Get(abi.AddrKey(addr2), nil)
+		require.NoError(t, err)
+		require.False(t, found)
+	})
+
+	t.Run("sweeps dust", func(t *testing.T) {
+		bt := buildBalanceTable()
+		require.NoError(t, bt.Add(addr1, abi.NewTokenAmount(10)))
+		require.NoError(t, bt.Add(addr2, abi.NewTokenAmount(10)))
+
+		swept, err := bt.ApplyDeltas(map[address.Address]abi.TokenAmount{
+			addr1: abi.NewTokenAmount(-8),
+			addr2: abi.NewTokenAmount(-5),
+			addr3: abi.NewTokenAmount(1),
+		}, adt.DustPolicy{Threshold: abi.NewTokenAmount(5)})
+		require.NoError(t, err)
+		assert.Equal(t, abi.NewTokenAmount(3), swept)
+
+		total, err := bt.Total()
+		require.NoError(t, err)
+		assert.Equal(t, abi.NewTokenAmount(5), total)
+		found, err := ((*adt.Map)(bt)).Get(abi.AddrKey(addr3), nil)
+		require.NoError(t, err)
+		require.False(t, found)
+	})
+
+	t.Run("negative balance fails without change", func(t *testing.T) {
+		bt := buildBalanceTable()
+		require.NoError(t, bt.Add(addr1, abi.NewTokenAmount(10)))
+		before := tutil.MustRoot(t, (*adt.Map)(bt))
+
+		_, err := bt.ApplyDeltas(map[address.Address]abi.TokenAmount{
+			addr1: abi.NewTokenAmount(5),
+			addr2: abi.NewTokenAmount(-1),
+		}, adt.NoDust)
+		require.Error(t, err)
+		assert.Equal(t, before, tutil.MustRoot(t, (*adt.Map)(bt)))
+	})
+}
@@ -0,0 +1,139 @@
+package adt
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"reflect"
+	"sync"
+
+	cid "github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+)
+
+// A read-only store which memoizes decoded objects, so that repeated traversals of the same collections (e.g.
+// deals, claims or sectors during invariant checks) don't decode the same blocks again.
+// The least recently used objects are evicted when the encoded size of the cached blocks exceeds a bound.
+// A cached object shares its internal state with every value decoded from it, so collections loaded through
+// this store must not be modified. Writes to the store fail.
+// The store is safe for concurrent use if the base store is.
+type CachingStore struct {
+	base     Store
+	maxBytes int
+
+	lk      sync.Mutex
+	entries map[cacheKey]*list.Element
+	lru     *list.List // Of *cacheEntry, most recently used first.
+	stats   CacheStats
+}
+
+// Statistics of a CachingStore's use.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// Number of objects currently cached.
+	Entries int
+	// Encoded size of the blocks of the objects currently cached.
+	Bytes int
+}
+
+// Objects are cached per type, since the same block may be decoded into different types.
+type cacheKey struct {
+	cid cid.Cid
+	typ reflect.Type
+}
+
+type cacheEntry struct {
+	key   cacheKey
+	value reflect.Value // A pointer to the decoded object.
+	size  int
+}
+
+var _ Store = &CachingStore{}
+
+// Creates a store memoizing objects decoded from blocks of a base store, holding blocks of total size up to
+// maxBytes.
+func NewCachingStore(base Store, maxBytes int) *CachingStore {
+	return &CachingStore{
+		base:     base,
+		maxBytes: maxBytes,
+		entries:  map[cacheKey]*list.Element{},
+		lru:      list.New(),
+	}
+}
+
+func (s *CachingStore) Context() context.Context {
+	return s.base.Context()
+}
+
+func (s *CachingStore) Get(ctx context.Context, c cid.Cid, out interface{}) error {
+	outVal := reflect.ValueOf(out)
+	if _, ok := out.(cbg.CBORUnmarshaler); !ok || outVal.Kind() != reflect.Ptr || outVal.IsNil() {
+		// Not an object this store can decode itself.
+		return s.base.Get(ctx, c, out)
+	}
+	key := cacheKey{cid: c, typ: outVal.Type()}
+
+	s.lk.Lock()
+	if elem, ok := s.entries[key]; ok {
+		s.lru.MoveToFront(elem)
+		s.stats.Hits++
+		outVal.Elem().Set(elem.Value.(*cacheEntry).value.Elem())
+		s.lk.Unlock()
+		return nil
+	}
+	s.stats.Misses++
+	s.lk.Unlock()
+
+	var raw cbg.Deferred
+	if err := s.base.Get(ctx, c, &raw); err != nil {
+		return err
+	}
+	decoded := reflect.New(key.typ.Elem())
+	if err := decoded.Interface().(cbg.CBORUnmarshaler).UnmarshalCBOR(bytes.NewReader(raw.Raw)); err != nil {
+		return xerrors.Errorf("failed to decode %v as %v: %w", c, key.typ, err)
+	}
+	outVal.Elem().Set(decoded.Elem())
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.insert(&cacheEntry{key: key, value: decoded, size: len(raw.Raw)})
+	return nil
+}
+
+// Always fails, since the store is read-only.
+func (s *CachingStore) Put(_ context.Context, _ interface{}) (cid.Cid, error) {
+	return cid.Undef, xerrors.New("caching store is read-only")
+}
+
+// Returns the store's statistics so far.
+func (s *CachingStore) Stats() CacheStats {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return s.stats
+}
+
+// Caches an entry, evicting the least recently used entries to make room.
+// Entries larger than the bound are not cached.
+func (s *CachingStore) insert(e *cacheEntry) {
+	if e.size > s.maxBytes {
+		return
+	}
+	if _, ok := s.entries[e.key]; ok {
+		// Cached concurrently by another reader.
+		return
+	}
+	for s.stats.Bytes+e.size > s.maxBytes {
+		oldest := s.lru.Back()
+		evicted := s.lru.Remove(oldest).(*cacheEntry)
+		delete(s.entries, evicted.key)
+		s.stats.Entries--
+		s.stats.Bytes -= evicted.size
+		s.stats.Evictions++
+	}
+	s.entries[e.key] = s.lru.PushFront(e)
+	s.stats.Entries++
+	s.stats.Bytes += e.size
+}
@@ -0,0 +1,95 @@
+package adt_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	cid "github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+)
+
+func TestCachingStore(t *testing.T) {
+	base := adt.WrapBlockStore(context.Background(), ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	m, err := adt.MakeEmptyMap(base, 3)
+	require.NoError(t, err)
+	for i := int64(0); i < 500; i++ {
+		require.NoError(t, m.Put(abi.IntKey(i), cborInt(i)))
+	}
+	root, err := m.Root()
+	require.NoError(t, err)
+
+	sum := func(store adt.Store) int64 {
+		cached, err := adt.AsMap(store, root, 3)
+		require.NoError(t, err)
+		var v cbg.CborInt
+		total := int64(0)
+		require.NoError(t, cached.ForEach(&v, func(string) error {
+			total += int64(v)
+			return nil
+		}))
+		return total
+	}
+
+	t.Run("memoizes decoded nodes", func(t *testing.T) {
+		store := adt.NewCachingStore(base, 1<<20)
+		assert.Equal(t, int64(499*500/2), sum(store))
+		first := store.Stats()
+		assert.Greater(t, first.Misses, uint64(0))
+		assert.Equal(t, int(first.Misses), first.Entries)
+		assert.Greater(t, first.Bytes, 0)
+
+		assert.Equal(t, int64(499*500/2), sum(store))
+		second := store.Stats()
+		// The cached root shares the children loaded beneath it, so the second traversal fetches only the root.
+		assert.Equal(t, first.Misses, second.Misses)
+		assert.Equal(t, uint64(1), second.Hits)
+		assert.Zero(t, second.Evictions)
+	})
+
+	t.Run("evicts least recently used", func(t *testing.T) {
+		var roots []cid.Cid
+		for i := int64(0); i < 5; i++ {
+			single, err := adt.MakeEmptyMap(base, 3)
+			require.NoError(t, err)
+			require.NoError(t, single.Put(abi.IntKey(i), cborInt(i)))
+			r, err := single.Root()
+			require.NoError(t, err)
+			roots = append(roots, r)
+		}
+		load := func(store adt.Store, r cid.Cid) {
+			_, err := adt.AsMap(store, r, 3)
+			require.NoError(t, err)
+		}
+
+		// Measure the size of one root block, then bound the cache to hold only one.
+		measure := adt.NewCachingStore(base, 1<<20)
+		load(measure, roots[0])
+		size := measure.Stats().Bytes
+
+		store := adt.NewCachingStore(base, size+size/2)
+		for _, r := range roots {
+			load(store, r)
+		}
+		stats := store.Stats()
+		assert.Equal(t, 1, stats.Entries)
+		assert.Equal(t, size, stats.Bytes)
+		assert.Equal(t, uint64(4), stats.Evictions)
+
+		load(store, roots[4])
+		assert.Equal(t, uint64(1), store.Stats().Hits)
+		load(store, roots[0])
+		assert.Equal(t, uint64(6), store.Stats().Misses)
+	})
+
+	t.Run("read only", func(t *testing.T) {
+		store := adt.NewCachingStore(base, 1<<20)
+		_, err := store.Put(context.Background(), cborInt(1))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,101 @@
+package adt
+
+import (
+	amt "github.com/filecoin-project/go-amt-ipld/v3"
+	hamt "github.com/filecoin-project/go-hamt-ipld/v3"
+	cid "github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+)
+
+// The kind of change to an entry of a collection.
+type ChangeType int
+
+const (
+	ChangeAdd ChangeType = iota
+	ChangeRemove
+	ChangeModify
+)
+
+// A change to an entry between two versions of a map.
+// Values are not decoded. Before is nil for an added entry, and After is nil for a removed entry.
+type MapChange struct {
+	Type   ChangeType
+	Key    string
+	Before *cbg.Deferred
+	After  *cbg.Deferred
+}
+
+// A change to an entry between two versions of an array.
+// Values are not decoded. Before is nil for an added entry, and After is nil for a removed entry.
+type ArrayChange struct {
+	Type   ChangeType
+	Index  uint64
+	Before *cbg.Deferred
+	After  *cbg.Deferred
+}
+
+// Computes the changes between two versions of a map with the same bitwidth, e.g. the deal states at two epochs.
+// Subtrees with identical CIDs are skipped without being loaded, so the cost is proportional to the extent of
+// the changes rather than the size of the maps.
+func DiffMaps(store Store, prev, cur cid.Cid, bitwidth int) ([]MapChange, error) {
+	options := append(DefaultHamtOptions, hamt.UseTreeBitWidth(bitwidth))
+	changes, err := hamt.Diff(store.Context(), store, store, prev, cur, options...)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to diff maps %v and %v: %w", prev, cur, err)
+	}
+	out := make([]MapChange, 0, len(changes))
+	for _, c := range changes {
+		changeType, err := hamtChangeType(c.Type)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, MapChange{Type: changeType, Key: c.Key, Before: c.Before, After: c.After})
+	}
+	return out, nil
+}
+
+// Computes the changes between two versions of an array with the same bitwidth, e.g. the sectors of a miner at
+// two epochs.
+// Subtrees with identical CIDs are skipped without being loaded, so the cost is proportional to the extent of
+// the changes rather than the size of the arrays.
+func DiffArrays(store Store, prev, cur cid.Cid, bitwidth int) ([]ArrayChange, error) {
+	options := append(DefaultAmtOptions, amt.UseTreeBitWidth(uint(bitwidth)))
+	changes, err := amt.Diff(store.Context(), store, store, prev, cur, options...)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to diff arrays %v and %v: %w", prev, cur, err)
+	}
+	out := make([]ArrayChange, 0, len(changes))
+	for _, c := range changes {
+		changeType, err := amtChangeType(c.Type)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ArrayChange{Type: changeType, Index: c.Key, Before: c.Before, After: c.After})
+	}
+	return out, nil
+}
+
+func hamtChangeType(t hamt.ChangeType) (ChangeType, error) {
+	switch t {
+	case hamt.Add:
+		return ChangeAdd, nil
+	case hamt.Remove:
+		return ChangeRemove, nil
+	case hamt.Modify:
+		return ChangeModify, nil
+	}
+	return 0, xerrors.Errorf("unknown map change type %d", t)
+}
+
+func amtChangeType(t amt.ChangeType) (ChangeType, error) {
+	switch t {
+	case amt.Add:
+		return ChangeAdd, nil
+	case amt.Remove:
+		return ChangeRemove, nil
+	case amt.Modify:
+		return ChangeModify, nil
+	}
+	return 0, xerrors.Errorf("unknown array change type %d", t)
+}
@@ -0,0 +1,84 @@
+package adt_test
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/mock"
+)
+
+func TestDiffMaps(t *testing.T) {
+	rt := mock.NewBuilder(address.Undef).Build(t)
+	store := adt.AsStore(rt)
+
+	m, err := adt.MakeEmptyMap(store, builtin.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	for i := int64(0); i < 100; i++ {
+		require.NoError(t, m.Put(abi.IntKey(i), cborInt(i)))
+	}
+	prev, err := m.Root()
+	require.NoError(t, err)
+
+	require.NoError(t, m.Put(abi.IntKey(100), cborInt(100)))
+	require.NoError(t, m.Delete(abi.IntKey(5)))
+	require.NoError(t, m.Put(abi.IntKey(7), cborInt(-7)))
+	cur, err := m.Root()
+	require.NoError(t, err)
+
+	changes, err := adt.DiffMaps(store, prev, cur, builtin.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+	byKey := map[string]adt.MapChange{}
+	for _, c := range changes {
+		byKey[c.Key] = c
+	}
+	assert.Equal(t, adt.ChangeAdd, byKey[abi.IntKey(100).Key()].Type)
+	assert.Nil(t, byKey[abi.IntKey(100).Key()].Before)
+	assert.Equal(t, adt.ChangeRemove, byKey[abi.IntKey(5).Key()].Type)
+	assert.Nil(t, byKey[abi.IntKey(5).Key()].After)
+	modified := byKey[abi.IntKey(7).Key()]
+	assert.Equal(t, adt.ChangeModify, modified.Type)
+	assert.Equal(t, mustMarshal(t, cborInt(7)), modified.Before.Raw)
+	assert.Equal(t, mustMarshal(t, cborInt(-7)), modified.After.Raw)
+
+	changes, err = adt.DiffMaps(store, cur, cur, builtin.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestDiffArrays(t *testing.T) {
+	rt := mock.NewBuilder(address.Undef).Build(t)
+	store := adt.AsStore(rt)
+
+	arr, err := adt.MakeEmptyArray(store, 3)
+	require.NoError(t, err)
+	for i := uint64(0); i < 100; i++ {
+		require.NoError(t, arr.Set(i, cborInt(int64(i))))
+	}
+	prev, err := arr.Root()
+	require.NoError(t, err)
+
+	require.NoError(t, arr.Set(1000, cborInt(1000)))
+	require.NoError(t, arr.Delete(5))
+	require.NoError(t, arr.Set(7, cborInt(-7)))
+	cur, err := arr.Root()
+	require.NoError(t, err)
+
+	changes, err := adt.DiffArrays(store, prev, cur, 3)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+	byIndex := map[uint64]adt.ArrayChange{}
+	for _, c := range changes {
+		byIndex[c.Index] = c
+	}
+	assert.Equal(t, adt.ChangeAdd, byIndex[1000].Type)
+	assert.Equal(t, adt.ChangeRemove, byIndex[5].Type)
+	assert.Equal(t, adt.ChangeModify, byIndex[7].Type)
+	assert.Equal(t, mustMarshal(t, cborInt(-7)), byIndex[7].After.Raw)
+}
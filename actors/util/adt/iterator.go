@@ -0,0 +1,197 @@
+package adt
+
+import (
+	"bytes"
+
+	hamt "github.com/filecoin-project/go-hamt-ipld/v3"
+	"github.com/filecoin-project/go-state-types/cbor"
+	cid "github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+)
+
+// An iterator over the entries of a map, in the same order as ForEach.
+// Nodes are loaded only as the iterator reaches them, so a page of entries can be read without loading the
+// whole map.
+// The iterator reads the map as it was when the iterator was created; later modifications are not seen.
+type MapIterator struct {
+	store    Store
+	root     *hamt.Node
+	bitwidth int
+	// The path from the root to the node holding the next entry.
+	stack []*iteratorFrame
+	// The current entry, after a successful call to Next.
+	key   string
+	value *cbg.Deferred
+}
+
+type iteratorFrame struct {
+	node *hamt.Node
+	// Index of the next pointer to visit, and of the next entry in that pointer if it is a bucket.
+	pointer int
+	entry   int
+}
+
+// Returns an iterator positioned before the first entry of the map.
+// The map is flushed in order to read its nodes.
+func (m *Map) Iterator() (*MapIterator, error) {
+	root, err := m.Root()
+	if err != nil {
+		return nil, err
+	}
+	rootNode, err := loadMapNode(m.store, root)
+	if err != nil {
+		return nil, err
+	}
+	return &MapIterator{
+		store:    m.store,
+		root:     rootNode,
+		bitwidth: m.bitwidth,
+		stack:    []*iteratorFrame{{node: rootNode}},
+	}, nil
+}
+
+// Advances the iterator to the next entry, returning false if there are no more entries.
+func (it *MapIterator) Next() (bool, error) {
+	for len(it.stack) > 0 {
+		top := it.stack[len(it.stack)-1]
+		if top.pointer >= len(top.node.Pointers) {
+			it.stack = it.stack[:len(it.stack)-1]
+			continue
+		}
+		p := top.node.Pointers[top.pointer]
+		if p.Link.Defined() {
+			child, err := loadMapNode(it.store, p.Link)
+			if err != nil {
+				return false, err
+			}
+			top.pointer++
+			it.stack = append(it.stack, &iteratorFrame{node: child})
+			continue
+		}
+		if top.entry >= len(p.KVs) {
+			top.pointer++
+			top.entry = 0
+			continue
+		}
+		kv := p.KVs[top.entry]
+		top.entry++
+		it.key = string(kv.Key)
+		it.value = kv.Value
+		return true, nil
+	}
+	it.key = ""
+	it.value = nil
+	return false, nil
+}
+
+// Returns the key of the current entry.
+func (it *MapIterator) Key() string {
+	return it.key
+}
+
+// Deserializes the value of the current entry into `out`.
+func (it *MapIterator) Value(out cbor.Unmarshaler) error {
+	if it.value == nil {
+		return xerrors.New("iterator is not positioned at an entry")
+	}
+	return out.UnmarshalCBOR(bytes.NewReader(it.value.Raw))
+}
+
+// Positions the iterator so that the next call to Next advances to the entry with key `k`, or, if there
+// is no such entry, to the entry which would follow it.
+func (it *MapIterator) Seek(k string) error {
+	hash := hashKey([]byte(k))
+	it.key = ""
+	it.value = nil
+	it.stack = []*iteratorFrame{{node: it.root}}
+	for depth := 0; ; depth++ {
+		top := it.stack[len(it.stack)-1]
+		index, err := hashIndex(hash, depth, it.bitwidth)
+		if err != nil {
+			return xerrors.Errorf("failed to seek key %v: %w", k, err)
+		}
+		// Pointers are held in order of index, for each index set in the bitfield.
+		position := 0
+		for i := 0; i < index; i++ {
+			position += int(top.node.Bitfield.Bit(i))
+		}
+		top.pointer = position
+		if top.node.Bitfield.Bit(index) == 0 {
+			// The next entry is the first of the following pointer.
+			return nil
+		}
+		p := top.node.Pointers[position]
+		if !p.Link.Defined() {
+			// Entries in a bucket are held in key order.
+			for top.entry < len(p.KVs) && bytes.Compare(p.KVs[top.entry].Key, []byte(k)) < 0 {
+				top.entry++
+			}
+			return nil
+		}
+		child, err := loadMapNode(it.store, p.Link)
+		if err != nil {
+			return err
+		}
+		top.pointer++
+		it.stack = append(it.stack, &iteratorFrame{node: child})
+	}
+}
+
+// Iterates up to `limit` entries of the map, starting from the entry with key `start` (or the entry which would
+// follow it), or from the first entry if `start` is empty. Each value is deserialized into `out` (if non-nil)
+// before calling a function with the key.
+// Returns the key of the entry following the last one iterated, from which to start the next page, or an empty
+// string if there are no more entries.
+// Iteration halts if the function returns an error.
+func (m *Map) ForEachPage(start string, limit int, out cbor.Unmarshaler, fn func(key string) error) (string, error) {
+	it, err := m.Iterator()
+	if err != nil {
+		return "", err
+	}
+	if start != "" {
+		if err := it.Seek(start); err != nil {
+			return "", err
+		}
+	}
+	for i := 0; ; i++ {
+		more, err := it.Next()
+		if err != nil {
+			return "", err
+		}
+		if !more {
+			return "", nil
+		}
+		if i == limit {
+			return it.Key(), nil
+		}
+		if out != nil {
+			if err := it.Value(out); err != nil {
+				return "", err
+			}
+		}
+		if err := fn(it.Key()); err != nil {
+			return "", err
+		}
+	}
+}
+
+func loadMapNode(store Store, c cid.Cid) (*hamt.Node, error) {
+	var node hamt.Node
+	if err := store.Get(store.Context(), c, &node); err != nil {
+		return nil, xerrors.Errorf("failed to load map node %v: %w", c, err)
+	}
+	return &node, nil
+}
+
+// Returns the index at a depth of a HAMT, taken from the bits of a key's hash, most significant first.
+func hashIndex(hash []byte, depth, bitwidth int) (int, error) {
+	if (depth+1)*bitwidth > len(hash)*8 {
+		return 0, xerrors.Errorf("HAMT depth %d exceeds hash length", depth)
+	}
+	index := 0
+	for b := depth * bitwidth; b < (depth+1)*bitwidth; b++ {
+		index = index<<1 | int(hash[b/8]>>(7-uint(b%8))&1)
+	}
+	return index, nil
+}
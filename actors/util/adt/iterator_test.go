@@ -0,0 +1,108 @@
+package adt_test
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/mock"
+)
+
+func TestMapIterator(t *testing.T) {
+	rt := mock.NewBuilder(address.Undef).Build(t)
+	store := adt.AsStore(rt)
+
+	// A small bitwidth gives a deep tree with both buckets and links.
+	m, err := adt.MakeEmptyMap(store, 2)
+	require.NoError(t, err)
+	for i := int64(0); i < 500; i++ {
+		require.NoError(t, m.Put(abi.IntKey(i), cborInt(i)))
+	}
+	var expected []string
+	require.NoError(t, m.ForEach(nil, func(k string) error {
+		expected = append(expected, k)
+		return nil
+	}))
+
+	t.Run("iterates in ForEach order", func(t *testing.T) {
+		it, err := m.Iterator()
+		require.NoError(t, err)
+		var keys []string
+		var v cbg.CborInt
+		for {
+			more, err := it.Next()
+			require.NoError(t, err)
+			if !more {
+				break
+			}
+			require.NoError(t, it.Value(&v))
+			keys = append(keys, it.Key())
+		}
+		assert.Equal(t, expected, keys)
+	})
+
+	t.Run("seeks to every key", func(t *testing.T) {
+		it, err := m.Iterator()
+		require.NoError(t, err)
+		for i, k := range expected {
+			require.NoError(t, it.Seek(k))
+			more, err := it.Next()
+			require.NoError(t, err)
+			require.True(t, more)
+			require.Equal(t, k, it.Key())
+			if i+1 < len(expected) {
+				more, err = it.Next()
+				require.NoError(t, err)
+				require.True(t, more)
+				require.Equal(t, expected[i+1], it.Key())
+			}
+		}
+	})
+
+	t.Run("seeks to absent key", func(t *testing.T) {
+		it, err := m.Iterator()
+		require.NoError(t, err)
+		require.NoError(t, it.Seek(abi.IntKey(-1).Key()))
+		var rest []string
+		for {
+			more, err := it.Next()
+			require.NoError(t, err)
+			if !more {
+				break
+			}
+			rest = append(rest, it.Key())
+		}
+		// The remaining entries are a suffix of the iteration order.
+		require.NotEmpty(t, rest)
+		assert.Equal(t, expected[len(expected)-len(rest):], rest)
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		var keys []string
+		var values []int64
+		var v cbg.CborInt
+		start := ""
+		pages := 0
+		for {
+			next, err := m.ForEachPage(start, 64, &v, func(k string) error {
+				keys = append(keys, k)
+				values = append(values, int64(v))
+				return nil
+			})
+			require.NoError(t, err)
+			pages++
+			if next == "" {
+				break
+			}
+			start = next
+		}
+		assert.Equal(t, 8, pages)
+		assert.Equal(t, expected, keys)
+		assert.Len(t, values, 500)
+	})
+}
@@ -0,0 +1,66 @@
+package adt
+
+import (
+	cid "github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+)
+
+// A link to an object of type T which is loaded from the store only when first needed, and then cached.
+// This allows a method to defer loading a large part of an actor's state, e.g. a miner's deadlines, until
+// it is actually used, and to load it at most once.
+// Modifications are written to the store only when flushed.
+type LazyCid[T any, PT CBORPointer[T]] struct {
+	store Store
+	c     cid.Cid
+	value PT // Nil until loaded or set.
+	dirty bool
+}
+
+// Creates a lazy link to the object with CID `c`.
+func NewLazyCid[T any, PT CBORPointer[T]](store Store, c cid.Cid) *LazyCid[T, PT] {
+	return &LazyCid[T, PT]{store: store, c: c}
+}
+
+// Returns the CID of the object as last loaded or flushed, which excludes unflushed modifications.
+func (l *LazyCid[T, PT]) Cid() cid.Cid {
+	return l.c
+}
+
+// Returns whether the object has been loaded or set.
+func (l *LazyCid[T, PT]) Loaded() bool {
+	return l.value != nil
+}
+
+// Returns the object, loading it from the store on first use.
+// The same object is returned by each call. If the caller modifies it, it must call Set for the
+// modification to be flushed.
+func (l *LazyCid[T, PT]) Get() (PT, error) {
+	if l.value == nil {
+		v := PT(new(T))
+		if err := l.store.Get(l.store.Context(), l.c, v); err != nil {
+			return nil, xerrors.Errorf("failed to load %T (%v): %w", v, l.c, err)
+		}
+		l.value = v
+	}
+	return l.value, nil
+}
+
+// Replaces the object, to be written to the store by the next flush.
+func (l *LazyCid[T, PT]) Set(v PT) {
+	l.value = v
+	l.dirty = true
+}
+
+// Writes the object to the store if it has been set since it was loaded or last flushed, returning its CID.
+func (l *LazyCid[T, PT]) Flush() (cid.Cid, error) {
+	if !l.dirty {
+		return l.c, nil
+	}
+	c, err := l.store.Put(l.store.Context(), l.value)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to store %T: %w", l.value, err)
+	}
+	l.c = c
+	l.dirty = false
+	return c, nil
+}
@@ -0,0 +1,54 @@
+package adt_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+)
+
+func TestLazyCid(t *testing.T) {
+	base := adt.WrapBlockStore(context.Background(), ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	c, err := base.Put(context.Background(), cborInt(7))
+	require.NoError(t, err)
+
+	// The caching store counts loads from the base store.
+	store := adt.NewCachingStore(base, 0)
+	lazy := adt.NewLazyCid[cbg.CborInt](store, c)
+	assert.False(t, lazy.Loaded())
+
+	t.Run("loads once", func(t *testing.T) {
+		v, err := lazy.Get()
+		require.NoError(t, err)
+		assert.Equal(t, cbg.CborInt(7), *v)
+		again, err := lazy.Get()
+		require.NoError(t, err)
+		assert.Same(t, v, again)
+		assert.True(t, lazy.Loaded())
+		assert.Equal(t, uint64(1), store.Stats().Misses)
+	})
+
+	t.Run("flushes only when set", func(t *testing.T) {
+		flushed, err := lazy.Flush()
+		require.NoError(t, err)
+		assert.Equal(t, c, flushed)
+
+		writable := adt.NewLazyCid[cbg.CborInt](base, c)
+		writable.Set(cborInt(8))
+		assert.Equal(t, c, writable.Cid())
+		flushed, err = writable.Flush()
+		require.NoError(t, err)
+		assert.NotEqual(t, c, flushed)
+		assert.Equal(t, flushed, writable.Cid())
+
+		reloaded := adt.NewLazyCid[cbg.CborInt](base, flushed)
+		v, err := reloaded.Get()
+		require.NoError(t, err)
+		assert.Equal(t, cbg.CborInt(8), *v)
+	})
+}
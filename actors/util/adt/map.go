@@ -3,6 +3,7 @@ package adt
 import (
 	"bytes"
 	"crypto/sha256"
+	"sort"
 
 	hamt "github.com/filecoin-project/go-hamt-ipld/v3"
 	"github.com/filecoin-project/go-state-types/abi"
@@ -15,17 +16,20 @@ import (
 // DefaultHamtOptions specifies default options used to construct Filecoin HAMTs.
 // Specific HAMT instances may specify additional options, especially the bitwidth.
 var DefaultHamtOptions = []hamt.Option{
-	hamt.UseHashFunction(func(input []byte) []byte {
-		res := sha256.Sum256(input)
-		return res[:]
-	}),
+	hamt.UseHashFunction(hashKey),
+}
+
+func hashKey(input []byte) []byte {
+	res := sha256.Sum256(input)
+	return res[:]
 }
 
 // Map stores key-value pairs in a HAMT.
 type Map struct {
-	lastCid cid.Cid
-	root    *hamt.Node
-	store   Store
+	lastCid  cid.Cid
+	root     *hamt.Node
+	store    Store
+	bitwidth int
 }
 
 // AsMap interprets a store as a HAMT-based map with root `r`.
@@ -39,9 +43,10 @@ func AsMap(s Store, root cid.Cid, bitwidth int) (*Map, error) {
 	}
 
 	return &Map{
-		lastCid: root,
-		root:    nd,
-		store:   s,
+		lastCid:  root,
+		root:     nd,
+		store:    s,
+		bitwidth: bitwidth,
 	}, nil
 }
 
@@ -53,9 +58,10 @@ func MakeEmptyMap(s Store, bitwidth int) (*Map, error) {
 		return nil, err
 	}
 	return &Map{
-		lastCid: cid.Undef,
-		root:    nd,
-		store:   s,
+		lastCid:  cid.Undef,
+		root:     nd,
+		store:    s,
+		bitwidth: bitwidth,
 	}, nil
 }
 
@@ -91,6 +97,41 @@ func (m *Map) Put(k abi.Keyer, v cbor.Marshaler) error {
 	return nil
 }
 
+// A key and value to put in a map.
+type MapEntry struct {
+	Key   abi.Keyer
+	Value cbor.Marshaler
+}
+
+// Puts many entries in the map, then flushes the modified nodes to the store once.
+// Entries are put in order of key hash, so that successive puts traverse the same HAMT nodes.
+// If a key appears more than once, the last value is put.
+func (m *Map) BatchPut(entries []MapEntry) error {
+	type hashedEntry struct {
+		key   string
+		hash  []byte
+		value cbor.Marshaler
+	}
+	hashed := make([]hashedEntry, len(entries))
+	for i, e := range entries {
+		key := e.Key.Key()
+		hashed[i] = hashedEntry{key: key, hash: hashKey([]byte(key)), value: e.Value}
+	}
+	sort.SliceStable(hashed, func(i, j int) bool {
+		return bytes.Compare(hashed[i].hash, hashed[j].hash) < 0
+	})
+
+	for _, e := range hashed {
+		if err := m.root.Set(m.store.Context(), e.key, e.value); err != nil {
+			return xerrors.Errorf("failed to set key %v value %v in node %v: %w", e.key, e.value, m.lastCid, err)
+		}
+	}
+	if err := m.root.Flush(m.store.Context()); err != nil {
+		return xerrors.Errorf("failed to flush map root: %w", err)
+	}
+	return nil
+}
+
 // Get retrieves the value at `k` into `out`, if the `k` is present and `out` is non-nil.
 // Returns whether the key was found.
 func (m *Map) Get(k abi.Keyer, out cbor.Unmarshaler) (bool, error) {
@@ -0,0 +1,55 @@
+package adt_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/mock"
+)
+
+func TestMapBatchPut(t *testing.T) {
+	rt := mock.NewBuilder(address.Undef).Build(t)
+	store := adt.AsStore(rt)
+
+	expected, err := adt.MakeEmptyMap(store, builtin.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	var entries []adt.MapEntry
+	for i := int64(0); i < 200; i++ {
+		// A duplicate of each key, whose later value wins.
+		require.NoError(t, expected.Put(abi.IntKey(i), cborInt(i)))
+		entries = append(entries, adt.MapEntry{Key: abi.IntKey(i), Value: cborInt(-1)}, adt.MapEntry{Key: abi.IntKey(i), Value: cborInt(i)})
+	}
+	expectedRoot, err := expected.Root()
+	require.NoError(t, err)
+
+	m, err := adt.MakeEmptyMap(store, builtin.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	require.NoError(t, m.BatchPut(entries))
+	root, err := m.Root()
+	require.NoError(t, err)
+	require.Equal(t, expectedRoot, root)
+
+	var v cbg.CborInt
+	found, err := m.Get(abi.IntKey(42), &v)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, cbg.CborInt(42), v)
+}
+
+func cborInt(i int64) *cbg.CborInt {
+	v := cbg.CborInt(i)
+	return &v
+}
+
+func mustMarshal(t *testing.T, v *cbg.CborInt) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, v.MarshalCBOR(&buf))
+	return buf.Bytes()
+}
@@ -91,6 +91,56 @@ func (mm *Multimap) RemoveAll(key abi.Keyer) error {
 	return nil
 }
 
+// Removes all values for a key, returning the array of removed values.
+// Returns a boolean indicating whether the key was previously in the multimap.
+func (mm *Multimap) PopAll(key abi.Keyer) (*Array, bool, error) {
+	var arrayRoot cbg.CborCid
+	found, err := mm.mp.Pop(key, &arrayRoot)
+	if err != nil {
+		return nil, false, xerrors.Errorf("failed to pop multimap key %v: %w", key, err)
+	}
+	if !found {
+		return nil, false, nil
+	}
+	array, err := AsArray(mm.mp.store, cid.Cid(arrayRoot), mm.innerBitwidth)
+	if err != nil {
+		return nil, false, xerrors.Errorf("failed to load value %v as an array: %w", key, err)
+	}
+	return array, true, nil
+}
+
+// Returns the number of values for a key.
+func (mm *Multimap) Count(key abi.Keyer) (uint64, error) {
+	array, found, err := mm.Get(key)
+	if err != nil || !found {
+		return 0, err
+	}
+	return array.Length(), nil
+}
+
+// Iterates the keys of the multimap, without loading their values.
+// Iteration halts if the function returns an error.
+func (mm *Multimap) ForEachKey(fn func(k string) error) error {
+	return mm.mp.ForEach(nil, fn)
+}
+
+// Collects all the keys of the multimap into a slice of strings.
+func (mm *Multimap) CollectKeys() ([]string, error) {
+	return mm.mp.CollectKeys()
+}
+
+// Collects the number of values for every key of the multimap.
+func (mm *Multimap) CollectCounts() (map[string]uint64, error) {
+	counts := map[string]uint64{}
+	if err := mm.ForAll(func(k string, arr *Array) error {
+		counts[k] = arr.Length()
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
 // Iterates all entries for a key in the order they were inserted, deserializing each value in turn into `out` and then
 // calling a function.
 // Iteration halts if the function returns an error.
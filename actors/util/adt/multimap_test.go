@@ -0,0 +1,73 @@
+package adt_test
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/mock"
+)
+
+func TestMultimapKeys(t *testing.T) {
+	rt := mock.NewBuilder(address.Undef).Build(t)
+	store := adt.AsStore(rt)
+
+	mm, err := adt.MakeEmptyMultimap(store, builtin.DefaultHamtBitwidth, 3)
+	require.NoError(t, err)
+	for k := int64(1); k <= 3; k++ {
+		for v := int64(0); v < k*10; v++ {
+			require.NoError(t, mm.Add(abi.IntKey(k), cborInt(v)))
+		}
+	}
+
+	t.Run("keys and counts", func(t *testing.T) {
+		keys, err := mm.CollectKeys()
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{abi.IntKey(1).Key(), abi.IntKey(2).Key(), abi.IntKey(3).Key()}, keys)
+
+		count, err := mm.Count(abi.IntKey(2))
+		require.NoError(t, err)
+		assert.Equal(t, uint64(20), count)
+		count, err = mm.Count(abi.IntKey(4))
+		require.NoError(t, err)
+		assert.Zero(t, count)
+
+		counts, err := mm.CollectCounts()
+		require.NoError(t, err)
+		assert.Equal(t, map[string]uint64{
+			abi.IntKey(1).Key(): 10,
+			abi.IntKey(2).Key(): 20,
+			abi.IntKey(3).Key(): 30,
+		}, counts)
+	})
+
+	t.Run("pop all", func(t *testing.T) {
+		arr, found, err := mm.PopAll(abi.IntKey(1))
+		require.NoError(t, err)
+		require.True(t, found)
+		var v cbg.CborInt
+		var values []int64
+		require.NoError(t, arr.ForEach(&v, func(int64) error {
+			values = append(values, int64(v))
+			return nil
+		}))
+		assert.Equal(t, []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, values)
+
+		_, found, err = mm.PopAll(abi.IntKey(1))
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		var keys []string
+		require.NoError(t, mm.ForEachKey(func(k string) error {
+			keys = append(keys, k)
+			return nil
+		}))
+		assert.ElementsMatch(t, []string{abi.IntKey(2).Key(), abi.IntKey(3).Key()}, keys)
+	})
+}
@@ -0,0 +1,139 @@
+package adt
+
+import (
+	"context"
+
+	hamt "github.com/filecoin-project/go-hamt-ipld/v3"
+	cid "github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/xerrors"
+)
+
+// Options for RehashMapWithBitwidth.
+type RehashOptions struct {
+	// Number of goroutines reading subtrees of the existing map concurrently.
+	// Zero or one reads the map sequentially. With more than one, the store must be safe for concurrent use.
+	Workers int
+	// Called with the number of entries copied so far, after each ProgressInterval entries and on completion.
+	// Optional.
+	Progress func(entries uint64)
+	// Number of entries between calls to Progress. Zero reports only on completion.
+	ProgressInterval uint64
+}
+
+type rehashEntry struct {
+	key   string
+	value *cbg.Deferred
+}
+
+// Copies the entries of a map into a new map with a different bitwidth, returning the new map's root.
+// Values are copied without decoding. Entries are read from the existing map's root subtrees in parallel (see
+// RehashOptions.Workers) and inserted into the new map by a single writer.
+func RehashMapWithBitwidth(store Store, root cid.Cid, fromBitwidth, toBitwidth int, opts RehashOptions) (cid.Cid, error) {
+	out, err := MakeEmptyMap(store, toBitwidth)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	var rootNode hamt.Node
+	if err := store.Get(store.Context(), root, &rootNode); err != nil {
+		return cid.Undef, xerrors.Errorf("failed to load map root %v: %w", root, err)
+	}
+	// Entries held directly in the root, and the roots of subtrees to be read by workers.
+	var rootEntries []rehashEntry
+	var subtrees []cid.Cid
+	for _, p := range rootNode.Pointers {
+		if p.Link.Defined() {
+			subtrees = append(subtrees, p.Link)
+			continue
+		}
+		for _, kv := range p.KVs {
+			rootEntries = append(rootEntries, rehashEntry{key: string(kv.Key), value: kv.Value})
+		}
+	}
+
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	entries := make(chan rehashEntry, 1000)
+	jobs := make(chan cid.Cid, len(subtrees))
+	for _, c := range subtrees {
+		jobs <- c
+	}
+	close(jobs)
+
+	cancelCtx, cancel := context.WithCancel(store.Context())
+	defer cancel()
+	readers, ctx := errgroup.WithContext(cancelCtx)
+	options := append(DefaultHamtOptions, hamt.UseTreeBitWidth(fromBitwidth))
+	for w := 0; w < workers; w++ {
+		readers.Go(func() error {
+			for c := range jobs {
+				subtree, err := hamt.LoadNode(ctx, store, c, options...)
+				if err != nil {
+					return xerrors.Errorf("failed to load map node %v: %w", c, err)
+				}
+				if err := subtree.ForEach(ctx, func(k string, val *cbg.Deferred) error {
+					// The value may be reused by the iteration.
+					value := &cbg.Deferred{Raw: append([]byte(nil), val.Raw...)}
+					select {
+					case entries <- rehashEntry{key: k, value: value}:
+						return nil
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- readers.Wait()
+		close(entries)
+	}()
+
+	count := uint64(0)
+	put := func(e rehashEntry) error {
+		if err := out.root.Set(store.Context(), e.key, e.value); err != nil {
+			return xerrors.Errorf("failed to set key %v: %w", e.key, err)
+		}
+		count++
+		if opts.Progress != nil && opts.ProgressInterval > 0 && count%opts.ProgressInterval == 0 {
+			opts.Progress(count)
+		}
+		return nil
+	}
+	var writeErr error
+	for _, e := range rootEntries {
+		if writeErr = put(e); writeErr != nil {
+			break
+		}
+	}
+	if writeErr != nil {
+		cancel()
+	}
+	for e := range entries {
+		if writeErr == nil {
+			if writeErr = put(e); writeErr != nil {
+				cancel()
+			}
+		}
+		// Continue to drain the channel after an error, until the readers terminate.
+	}
+	readerErr := <-readErr
+	if writeErr != nil {
+		return cid.Undef, writeErr
+	}
+	if readerErr != nil {
+		return cid.Undef, readerErr
+	}
+	if opts.Progress != nil {
+		opts.Progress(count)
+	}
+	return out.Root()
+}
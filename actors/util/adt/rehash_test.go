@@ -0,0 +1,42 @@
+package adt_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+)
+
+func TestRehashMapWithBitwidth(t *testing.T) {
+	store := adt.WrapBlockStore(context.Background(), ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+
+	from, err := adt.MakeEmptyMap(store, 5)
+	require.NoError(t, err)
+	expected, err := adt.MakeEmptyMap(store, 3)
+	require.NoError(t, err)
+	for i := int64(0); i < 1000; i++ {
+		require.NoError(t, from.Put(abi.IntKey(i), cborInt(i)))
+		require.NoError(t, expected.Put(abi.IntKey(i), cborInt(i)))
+	}
+	fromRoot, err := from.Root()
+	require.NoError(t, err)
+	expectedRoot, err := expected.Root()
+	require.NoError(t, err)
+
+	for _, workers := range []int{0, 4} {
+		var progress []uint64
+		root, err := adt.RehashMapWithBitwidth(store, fromRoot, 5, 3, adt.RehashOptions{
+			Workers:          workers,
+			Progress:         func(entries uint64) { progress = append(progress, entries) },
+			ProgressInterval: 300,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, expectedRoot, root)
+		assert.Equal(t, []uint64{300, 600, 900, 1000}, progress)
+	}
+}
@@ -0,0 +1,192 @@
+package adt
+
+import (
+	"bytes"
+	"io"
+
+	hamt "github.com/filecoin-project/go-hamt-ipld/v3"
+	cid "github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+)
+
+// Statistics describing the shape of a collection, computed from its nodes without decoding its values.
+type CollectionStats struct {
+	// Number of nodes, including the root.
+	Nodes uint64
+	// Depth of the deepest node, where the root has depth zero.
+	MaxDepth int
+	// Total encoded size of the nodes, which includes the values held in them.
+	Bytes uint64
+	// Number of entries.
+	Entries uint64
+}
+
+// Computes statistics describing the map's HAMT.
+// The map is flushed in order to walk its nodes.
+func (m *Map) Stats() (CollectionStats, error) {
+	root, err := m.Root()
+	if err != nil {
+		return CollectionStats{}, err
+	}
+	var stats CollectionStats
+	if err := mapNodeStats(m.store, root, 0, &stats); err != nil {
+		return CollectionStats{}, err
+	}
+	return stats, nil
+}
+
+func mapNodeStats(store Store, c cid.Cid, depth int, stats *CollectionStats) error {
+	var raw cbg.Deferred
+	if err := store.Get(store.Context(), c, &raw); err != nil {
+		return xerrors.Errorf("failed to load map node %v: %w", c, err)
+	}
+	var node hamt.Node
+	if err := node.UnmarshalCBOR(bytes.NewReader(raw.Raw)); err != nil {
+		return xerrors.Errorf("failed to decode map node %v: %w", c, err)
+	}
+
+	stats.Nodes++
+	stats.Bytes += uint64(len(raw.Raw))
+	if depth > stats.MaxDepth {
+		stats.MaxDepth = depth
+	}
+	for _, p := range node.Pointers {
+		if p.Link.Defined() {
+			if err := mapNodeStats(store, p.Link, depth+1, stats); err != nil {
+				return err
+			}
+		} else {
+			stats.Entries += uint64(len(p.KVs))
+		}
+	}
+	return nil
+}
+
+// Computes statistics describing the array's AMT.
+// The array is flushed in order to walk its nodes.
+func (a *Array) Stats() (CollectionStats, error) {
+	root, err := a.Root()
+	if err != nil {
+		return CollectionStats{}, err
+	}
+	var raw cbg.Deferred
+	if err := a.store.Get(a.store.Context(), root, &raw); err != nil {
+		return CollectionStats{}, xerrors.Errorf("failed to load array root %v: %w", root, err)
+	}
+	var rootNode amtRoot
+	if err := rootNode.UnmarshalCBOR(bytes.NewReader(raw.Raw)); err != nil {
+		return CollectionStats{}, xerrors.Errorf("failed to decode array root %v: %w", root, err)
+	}
+
+	stats := CollectionStats{Nodes: 1, Bytes: uint64(len(raw.Raw))}
+	if err := arrayNodeStats(a.store, &rootNode.node, 0, &stats); err != nil {
+		return CollectionStats{}, err
+	}
+	return stats, nil
+}
+
+func arrayNodeStats(store Store, node *amtNode, depth int, stats *CollectionStats) error {
+	if depth > stats.MaxDepth {
+		stats.MaxDepth = depth
+	}
+	stats.Entries += node.values
+	for _, c := range node.links {
+		var raw cbg.Deferred
+		if err := store.Get(store.Context(), c, &raw); err != nil {
+			return xerrors.Errorf("failed to load array node %v: %w", c, err)
+		}
+		var child amtNode
+		if err := child.UnmarshalCBOR(bytes.NewReader(raw.Raw)); err != nil {
+			return xerrors.Errorf("failed to decode array node %v: %w", c, err)
+		}
+		stats.Nodes++
+		stats.Bytes += uint64(len(raw.Raw))
+		if err := arrayNodeStats(store, &child, depth+1, stats); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The structure of an AMT root, as serialized by go-amt-ipld: [bitWidth, height, count, node].
+// Only the node's links and number of values are retained.
+type amtRoot struct {
+	node amtNode
+}
+
+// The structure of an AMT node: [bitmap, [links...], [values...]].
+type amtNode struct {
+	links  []cid.Cid
+	values uint64
+}
+
+func (r *amtRoot) UnmarshalCBOR(rd io.Reader) error {
+	br := cbg.GetPeeker(rd)
+	if err := readArrayHeader(br, 4); err != nil {
+		return err
+	}
+	// Bitwidth, height and count.
+	for i := 0; i < 3; i++ {
+		maj, _, err := cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return xerrors.Errorf("expected unsigned int in AMT root, got major type %d", maj)
+		}
+	}
+	return r.node.UnmarshalCBOR(br)
+}
+
+func (n *amtNode) UnmarshalCBOR(rd io.Reader) error {
+	br := cbg.GetPeeker(rd)
+	if err := readArrayHeader(br, 3); err != nil {
+		return err
+	}
+	var bitmap cbg.Deferred
+	if err := bitmap.UnmarshalCBOR(br); err != nil {
+		return err
+	}
+
+	maj, count, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return xerrors.Errorf("expected array of AMT links, got major type %d", maj)
+	}
+	n.links = make([]cid.Cid, count)
+	for i := range n.links {
+		if n.links[i], err = cbg.ReadCid(br); err != nil {
+			return err
+		}
+	}
+
+	maj, count, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return xerrors.Errorf("expected array of AMT values, got major type %d", maj)
+	}
+	for i := uint64(0); i < count; i++ {
+		var value cbg.Deferred
+		if err := value.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+	}
+	n.values = count
+	return nil
+}
+
+func readArrayHeader(br cbg.BytePeeker, expected uint64) error {
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray || extra != expected {
+		return xerrors.Errorf("expected array of %d elements, got major type %d with extra %d", expected, maj, extra)
+	}
+	return nil
+}
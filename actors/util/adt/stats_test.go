@@ -0,0 +1,65 @@
+package adt_test
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/mock"
+)
+
+func TestMapStats(t *testing.T) {
+	rt := mock.NewBuilder(address.Undef).Build(t)
+	store := adt.AsStore(rt)
+
+	m, err := adt.MakeEmptyMap(store, builtin.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	stats, err := m.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), stats.Nodes)
+	assert.Equal(t, 0, stats.MaxDepth)
+	assert.Zero(t, stats.Entries)
+
+	for i := int64(0); i < 1000; i++ {
+		require.NoError(t, m.Put(abi.IntKey(i), cborInt(i)))
+	}
+	stats, err = m.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1000), stats.Entries)
+	assert.GreaterOrEqual(t, stats.Nodes, uint64(1))
+	assert.Greater(t, stats.Bytes, uint64(1000))
+}
+
+func TestArrayStats(t *testing.T) {
+	rt := mock.NewBuilder(address.Undef).Build(t)
+	store := adt.AsStore(rt)
+
+	arr, err := adt.MakeEmptyArray(store, 3)
+	require.NoError(t, err)
+	stats, err := arr.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, adt.CollectionStats{Nodes: 1, Bytes: stats.Bytes}, stats)
+
+	// Indexes 0..63 fill a tree of height 1: a root with 8 leaves of 8 values each.
+	for i := uint64(0); i < 64; i++ {
+		require.NoError(t, arr.Set(i, cborInt(int64(i))))
+	}
+	stats, err = arr.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(9), stats.Nodes)
+	assert.Equal(t, 1, stats.MaxDepth)
+	assert.Equal(t, uint64(64), stats.Entries)
+
+	// A distant index adds a level above the existing root, and a new path of one internal node and one leaf.
+	require.NoError(t, arr.Set(511, cborInt(511)))
+	stats, err = arr.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1+(1+8)+(1+1)), stats.Nodes)
+	assert.Equal(t, 2, stats.MaxDepth)
+	assert.Equal(t, uint64(65), stats.Entries)
+}
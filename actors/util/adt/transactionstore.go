@@ -0,0 +1,117 @@
+package adt
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/cbor"
+	cid "github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+)
+
+// A store which buffers all writes in memory, reading through to a base store, until the writes are either
+// committed to the base store or reverted.
+// This allows speculative execution, e.g. of a message in a test scenario, to be rolled back without copying
+// the base store.
+// The store is safe for concurrent use if the base store is.
+type TransactionStore struct {
+	base Store
+
+	lk       sync.RWMutex
+	buffered map[cid.Cid][]byte
+	order    []cid.Cid // Buffered CIDs in order of first write, so that commits are deterministic.
+	bytes    int
+}
+
+var _ Store = &TransactionStore{}
+
+// Creates a store buffering writes over a base store.
+func NewTransactionStore(base Store) *TransactionStore {
+	return &TransactionStore{
+		base:     base,
+		buffered: map[cid.Cid][]byte{},
+	}
+}
+
+func (s *TransactionStore) Context() context.Context {
+	return s.base.Context()
+}
+
+func (s *TransactionStore) Get(ctx context.Context, c cid.Cid, out interface{}) error {
+	s.lk.RLock()
+	data, ok := s.buffered[c]
+	s.lk.RUnlock()
+	if !ok {
+		return s.base.Get(ctx, c, out)
+	}
+	um, ok := out.(cbor.Unmarshaler)
+	if !ok {
+		return xerrors.Errorf("cannot decode buffered block %v into %T, which is not a CBOR unmarshaler", c, out)
+	}
+	return um.UnmarshalCBOR(bytes.NewReader(data))
+}
+
+func (s *TransactionStore) Put(_ context.Context, v interface{}) (cid.Cid, error) {
+	m, ok := v.(cbor.Marshaler)
+	if !ok {
+		return cid.Undef, xerrors.Errorf("cannot encode %T, which is not a CBOR marshaler", v)
+	}
+	buf := new(bytes.Buffer)
+	if err := m.MarshalCBOR(buf); err != nil {
+		return cid.Undef, xerrors.Errorf("failed to encode %T: %w", v, err)
+	}
+	c, err := abi.CidBuilder.Sum(buf.Bytes())
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("failed to compute CID: %w", err)
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if _, ok := s.buffered[c]; !ok {
+		s.buffered[c] = buf.Bytes()
+		s.order = append(s.order, c)
+		s.bytes += buf.Len()
+	}
+	return c, nil
+}
+
+// Writes all buffered blocks to the base store, in the order they were first written, and clears the buffer.
+// If writing a block fails, the blocks not yet written remain buffered.
+func (s *TransactionStore) Commit() error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	for i, c := range s.order {
+		data := s.buffered[c]
+		written, err := s.base.Put(s.base.Context(), &cbg.Deferred{Raw: data})
+		if err == nil && !written.Equals(c) {
+			err = xerrors.Errorf("base store computed CID %v", written)
+		}
+		if err != nil {
+			s.order = s.order[i:]
+			return xerrors.Errorf("failed to commit block %v: %w", c, err)
+		}
+		delete(s.buffered, c)
+		s.bytes -= len(data)
+	}
+	s.order = nil
+	return nil
+}
+
+// Discards all buffered blocks.
+func (s *TransactionStore) Revert() {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.buffered = map[cid.Cid][]byte{}
+	s.order = nil
+	s.bytes = 0
+}
+
+// Returns the number and total size of the buffered blocks.
+func (s *TransactionStore) Buffered() (blocks int, size int) {
+	s.lk.RLock()
+	defer s.lk.RUnlock()
+	return len(s.buffered), s.bytes
+}
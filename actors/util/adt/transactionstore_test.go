@@ -0,0 +1,80 @@
+package adt_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	cid "github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/ipld"
+)
+
+func TestTransactionStore(t *testing.T) {
+	base := adt.WrapBlockStore(context.Background(), ipld.NewSyncBlockStore(ipld.NewBlockStoreInMemory()))
+	m, err := adt.MakeEmptyMap(base, 5)
+	require.NoError(t, err)
+	require.NoError(t, m.Put(abi.IntKey(1), cborInt(1)))
+	baseRoot, err := m.Root()
+	require.NoError(t, err)
+
+	// Adds entries to the map through a store, returning the new root.
+	modify := func(store adt.Store) cid.Cid {
+		modified, err := adt.AsMap(store, baseRoot, 5)
+		require.NoError(t, err)
+		for i := int64(2); i < 100; i++ {
+			require.NoError(t, modified.Put(abi.IntKey(i), cborInt(i)))
+		}
+		root, err := modified.Root()
+		require.NoError(t, err)
+		return root
+	}
+	assertValue := func(store adt.Store, root cid.Cid, k, expected int64) {
+		loaded, err := adt.AsMap(store, root, 5)
+		require.NoError(t, err)
+		var v cbg.CborInt
+		found, err := loaded.Get(abi.IntKey(k), &v)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, cbg.CborInt(expected), v)
+	}
+
+	t.Run("revert", func(t *testing.T) {
+		txn := adt.NewTransactionStore(base)
+		root := modify(txn)
+		blocks, size := txn.Buffered()
+		assert.Greater(t, blocks, 0)
+		assert.Greater(t, size, 0)
+
+		// The modified map is readable through the transaction store, but not from the base store.
+		assertValue(txn, root, 50, 50)
+		_, err := adt.AsMap(base, root, 5)
+		require.Error(t, err)
+
+		txn.Revert()
+		blocks, size = txn.Buffered()
+		assert.Zero(t, blocks)
+		assert.Zero(t, size)
+		_, err = adt.AsMap(txn, root, 5)
+		require.Error(t, err)
+		assertValue(txn, baseRoot, 1, 1)
+	})
+
+	t.Run("commit", func(t *testing.T) {
+		txn := adt.NewTransactionStore(base)
+		root := modify(txn)
+		require.NoError(t, txn.Commit())
+		blocks, size := txn.Buffered()
+		assert.Zero(t, blocks)
+		assert.Zero(t, size)
+
+		assertValue(base, root, 99, 99)
+
+		// The CIDs computed by the transaction store match those of the base store.
+		assert.Equal(t, root, modify(base))
+	})
+}
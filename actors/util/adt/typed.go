@@ -0,0 +1,180 @@
+package adt
+
+import (
+	"bytes"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/cbor"
+	cid "github.com/ipfs/go-cid"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// A pointer to a value of type T which is encoded as CBOR, e.g. *miner.SectorOnChainInfo.
+// This constrains the values held in typed collections.
+type CBORPointer[T any] interface {
+	*T
+	cbor.Er
+}
+
+// TypedMap wraps a Map holding values of a single type.
+// Values are returned as freshly decoded objects, rather than decoded into an output parameter which
+// is overwritten by the next entry.
+type TypedMap[T any, PT CBORPointer[T]] struct {
+	m *Map
+}
+
+// Interprets a store as a HAMT-based map of values of type T with root `r`.
+// The HAMT is interpreted with branching factor 2^bitwidth.
+func AsTypedMap[T any, PT CBORPointer[T]](s Store, root cid.Cid, bitwidth int) (*TypedMap[T, PT], error) {
+	m, err := AsMap(s, root, bitwidth)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedMap[T, PT]{m}, nil
+}
+
+// Creates a new map of values of type T backed by an empty HAMT.
+func MakeEmptyTypedMap[T any, PT CBORPointer[T]](s Store, bitwidth int) (*TypedMap[T, PT], error) {
+	m, err := MakeEmptyMap(s, bitwidth)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedMap[T, PT]{m}, nil
+}
+
+// Returns the underlying untyped map.
+func (t *TypedMap[T, PT]) Map() *Map {
+	return t.m
+}
+
+// Returns the root cid of the underlying HAMT.
+func (t *TypedMap[T, PT]) Root() (cid.Cid, error) {
+	return t.m.Root()
+}
+
+// Puts a value for a key.
+func (t *TypedMap[T, PT]) Put(k abi.Keyer, v PT) error {
+	return t.m.Put(k, v)
+}
+
+// Gets the value for a key, returning whether it was found.
+func (t *TypedMap[T, PT]) Get(k abi.Keyer) (PT, bool, error) {
+	v := PT(new(T))
+	found, err := t.m.Get(k, v)
+	if !found || err != nil {
+		return nil, found, err
+	}
+	return v, true, nil
+}
+
+// Returns whether a key is present.
+func (t *TypedMap[T, PT]) Has(k abi.Keyer) (bool, error) {
+	return t.m.Has(k)
+}
+
+// Removes the value for a key, if present, returning whether it was present.
+func (t *TypedMap[T, PT]) TryDelete(k abi.Keyer) (bool, error) {
+	return t.m.TryDelete(k)
+}
+
+// Removes the value for a key, expecting it to be present.
+func (t *TypedMap[T, PT]) Delete(k abi.Keyer) error {
+	return t.m.Delete(k)
+}
+
+// Iterates all entries in the map, calling a function with each key and a freshly decoded value, which the
+// function may retain.
+// Iteration halts if the function returns an error.
+func (t *TypedMap[T, PT]) ForEach(fn func(key string, v PT) error) error {
+	return t.m.root.ForEach(t.m.store.Context(), func(key string, val *cbg.Deferred) error {
+		v := PT(new(T))
+		if err := v.UnmarshalCBOR(bytes.NewReader(val.Raw)); err != nil {
+			return err
+		}
+		return fn(key, v)
+	})
+}
+
+// TypedArray wraps an Array holding values of a single type.
+// Values are returned as freshly decoded objects, rather than decoded into an output parameter which
+// is overwritten by the next entry.
+type TypedArray[T any, PT CBORPointer[T]] struct {
+	a *Array
+}
+
+// Interprets a store as an AMT-based array of values of type T with root `r`.
+// The AMT is interpreted with branching factor 2^bitwidth.
+func AsTypedArray[T any, PT CBORPointer[T]](s Store, root cid.Cid, bitwidth int) (*TypedArray[T, PT], error) {
+	a, err := AsArray(s, root, bitwidth)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedArray[T, PT]{a}, nil
+}
+
+// Creates a new array of values of type T backed by an empty AMT.
+func MakeEmptyTypedArray[T any, PT CBORPointer[T]](s Store, bitwidth int) (*TypedArray[T, PT], error) {
+	a, err := MakeEmptyArray(s, bitwidth)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedArray[T, PT]{a}, nil
+}
+
+// Returns the underlying untyped array.
+func (t *TypedArray[T, PT]) Array() *Array {
+	return t.a
+}
+
+// Returns the root cid of the underlying AMT.
+func (t *TypedArray[T, PT]) Root() (cid.Cid, error) {
+	return t.a.Root()
+}
+
+// Returns the number of values in the array.
+func (t *TypedArray[T, PT]) Length() uint64 {
+	return t.a.Length()
+}
+
+// Sets the value at an index.
+func (t *TypedArray[T, PT]) Set(i uint64, v PT) error {
+	return t.a.Set(i, v)
+}
+
+// Appends a value at the index following the last value.
+func (t *TypedArray[T, PT]) AppendContinuous(v PT) error {
+	return t.a.AppendContinuous(v)
+}
+
+// Gets the value at an index, returning whether it was found.
+func (t *TypedArray[T, PT]) Get(i uint64) (PT, bool, error) {
+	v := PT(new(T))
+	found, err := t.a.Get(i, v)
+	if !found || err != nil {
+		return nil, found, err
+	}
+	return v, true, nil
+}
+
+// Removes the value at an index, if present, returning whether it was present.
+func (t *TypedArray[T, PT]) TryDelete(i uint64) (bool, error) {
+	return t.a.TryDelete(i)
+}
+
+// Removes the value at an index, expecting it to be present.
+func (t *TypedArray[T, PT]) Delete(i uint64) error {
+	return t.a.Delete(i)
+}
+
+// Iterates all entries in the array in index order, calling a function with each index and a freshly decoded
+// value, which the function may retain.
+// Iteration halts if the function returns an error.
+func (t *TypedArray[T, PT]) ForEach(fn func(i uint64, v PT) error) error {
+	return t.a.root.ForEach(t.a.store.Context(), func(i uint64, val *cbg.Deferred) error {
+		v := PT(new(T))
+		if err := v.UnmarshalCBOR(bytes.NewReader(val.Raw)); err != nil {
+			return err
+		}
+		return fn(i, v)
+	})
+}
@@ -0,0 +1,86 @@
+package adt_test
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/mock"
+)
+
+func TestTypedMap(t *testing.T) {
+	rt := mock.NewBuilder(address.Undef).Build(t)
+	store := adt.AsStore(rt)
+
+	m, err := adt.MakeEmptyTypedMap[cbg.CborInt](store, builtin.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	for i := int64(0); i < 10; i++ {
+		require.NoError(t, m.Put(abi.IntKey(i), cborInt(i)))
+	}
+	root, err := m.Root()
+	require.NoError(t, err)
+
+	loaded, err := adt.AsTypedMap[cbg.CborInt](store, root, builtin.DefaultHamtBitwidth)
+	require.NoError(t, err)
+	v, found, err := loaded.Get(abi.IntKey(3))
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, cbg.CborInt(3), *v)
+	v, found, err = loaded.Get(abi.IntKey(10))
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Nil(t, v)
+
+	// Values passed to the iteration function may be retained.
+	retained := map[string]*cbg.CborInt{}
+	require.NoError(t, loaded.ForEach(func(k string, v *cbg.CborInt) error {
+		retained[k] = v
+		return nil
+	}))
+	require.Len(t, retained, 10)
+	for i := int64(0); i < 10; i++ {
+		assert.Equal(t, cbg.CborInt(i), *retained[abi.IntKey(i).Key()])
+	}
+
+	require.NoError(t, loaded.Delete(abi.IntKey(3)))
+	found, err = loaded.Has(abi.IntKey(3))
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestTypedArray(t *testing.T) {
+	rt := mock.NewBuilder(address.Undef).Build(t)
+	store := adt.AsStore(rt)
+
+	arr, err := adt.MakeEmptyTypedArray[cbg.CborInt](store, 3)
+	require.NoError(t, err)
+	for i := int64(0); i < 10; i++ {
+		require.NoError(t, arr.AppendContinuous(cborInt(i*10)))
+	}
+	root, err := arr.Root()
+	require.NoError(t, err)
+
+	loaded, err := adt.AsTypedArray[cbg.CborInt](store, root, 3)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(10), loaded.Length())
+	v, found, err := loaded.Get(4)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, cbg.CborInt(40), *v)
+
+	var retained []*cbg.CborInt
+	require.NoError(t, loaded.ForEach(func(i uint64, v *cbg.CborInt) error {
+		retained = append(retained, v)
+		return nil
+	}))
+	require.Len(t, retained, 10)
+	for i, v := range retained {
+		assert.Equal(t, cbg.CborInt(i*10), *v)
+	}
+}
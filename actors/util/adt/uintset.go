@@ -0,0 +1,115 @@
+package adt
+
+import (
+	"github.com/filecoin-project/go-bitfield"
+	cid "github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+)
+
+// UintSet interprets an Array as a set of integers, storing members (with empty values) in an AMT.
+// This is more compact than a HAMT-backed Set when members are dense, e.g. sequential sector numbers.
+type UintSet struct {
+	a *Array
+}
+
+// Interprets a store as an AMT-based set of integers with root `r`.
+// The AMT is interpreted with branching factor 2^bitwidth.
+func AsUintSet(s Store, r cid.Cid, bitwidth int) (*UintSet, error) {
+	a, err := AsArray(s, r, bitwidth)
+	if err != nil {
+		return nil, err
+	}
+	return &UintSet{a}, nil
+}
+
+// Creates a new set backed by an empty AMT.
+// The AMT has branching factor 2^bitwidth.
+func MakeEmptyUintSet(s Store, bitwidth int) (*UintSet, error) {
+	a, err := MakeEmptyArray(s, bitwidth)
+	if err != nil {
+		return nil, err
+	}
+	return &UintSet{a}, nil
+}
+
+// Returns the root cid of the underlying AMT.
+func (h *UintSet) Root() (cid.Cid, error) {
+	return h.a.Root()
+}
+
+// Adds `i` to the set.
+func (h *UintSet) Put(i uint64) error {
+	return h.a.Set(i, nil)
+}
+
+// Returns whether `i` is in the set.
+func (h *UintSet) Has(i uint64) (bool, error) {
+	return h.a.Get(i, nil)
+}
+
+// Removes `i` from the set, if present.
+// Returns whether it was previously present.
+func (h *UintSet) TryDelete(i uint64) (bool, error) {
+	return h.a.TryDelete(i)
+}
+
+// Removes `i` from the set, expecting it to be present.
+func (h *UintSet) Delete(i uint64) error {
+	return h.a.Delete(i)
+}
+
+// Returns the number of members of the set.
+func (h *UintSet) Length() uint64 {
+	return h.a.Length()
+}
+
+// Iterates the members of the set in ascending order.
+// Iteration halts if the function returns an error.
+func (h *UintSet) ForEach(fn func(i uint64) error) error {
+	return h.a.ForEach(nil, func(i int64) error {
+		return fn(uint64(i))
+	})
+}
+
+// Collects the members of the set into a bitfield.
+func (h *UintSet) BitField() (bitfield.BitField, error) {
+	var members []uint64
+	if err := h.ForEach(func(i uint64) error {
+		members = append(members, i)
+		return nil
+	}); err != nil {
+		return bitfield.BitField{}, err
+	}
+	return bitfield.NewFromSet(members), nil
+}
+
+// Adds every member of another set to this set.
+func (h *UintSet) Union(other *UintSet) error {
+	return other.ForEach(func(i uint64) error {
+		if err := h.Put(i); err != nil {
+			return xerrors.Errorf("failed to add %d to set: %w", i, err)
+		}
+		return nil
+	})
+}
+
+// Removes every member of this set that is not a member of another set.
+func (h *UintSet) Intersect(other *UintSet) error {
+	var remove []uint64
+	if err := h.ForEach(func(i uint64) error {
+		found, err := other.Has(i)
+		if err != nil {
+			return xerrors.Errorf("failed to check %d in set: %w", i, err)
+		}
+		if !found {
+			remove = append(remove, i)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if len(remove) == 0 {
+		return nil
+	}
+	return h.a.BatchDelete(remove, true)
+}
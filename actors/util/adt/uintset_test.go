@@ -0,0 +1,72 @@
+package adt_test
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/specs-actors/v7/actors/util/adt"
+	"github.com/filecoin-project/specs-actors/v7/support/mock"
+)
+
+func TestUintSet(t *testing.T) {
+	rt := mock.NewBuilder(address.Undef).Build(t)
+	store := adt.AsStore(rt)
+
+	makeSet := func(members ...uint64) *adt.UintSet {
+		set, err := adt.MakeEmptyUintSet(store, 5)
+		require.NoError(t, err)
+		for _, i := range members {
+			require.NoError(t, set.Put(i))
+		}
+		return set
+	}
+	members := func(set *adt.UintSet) []uint64 {
+		bf, err := set.BitField()
+		require.NoError(t, err)
+		all, err := bf.All(1000)
+		require.NoError(t, err)
+		return all
+	}
+
+	t.Run("put, has and delete", func(t *testing.T) {
+		set := makeSet(1, 2, 3, 100)
+		assert.Equal(t, uint64(4), set.Length())
+
+		found, err := set.Has(3)
+		require.NoError(t, err)
+		assert.True(t, found)
+		found, err = set.Has(4)
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		require.NoError(t, set.Delete(3))
+		found, err = set.TryDelete(3)
+		require.NoError(t, err)
+		assert.False(t, found)
+		assert.Error(t, set.Delete(3))
+
+		root, err := set.Root()
+		require.NoError(t, err)
+		reloaded, err := adt.AsUintSet(store, root, 5)
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{1, 2, 100}, members(reloaded))
+	})
+
+	t.Run("union", func(t *testing.T) {
+		set := makeSet(1, 2, 3)
+		require.NoError(t, set.Union(makeSet(3, 4, 5)))
+		assert.Equal(t, []uint64{1, 2, 3, 4, 5}, members(set))
+	})
+
+	t.Run("intersect", func(t *testing.T) {
+		set := makeSet(1, 2, 3, 4)
+		require.NoError(t, set.Intersect(makeSet(2, 4, 6)))
+		assert.Equal(t, []uint64{2, 4}, members(set))
+
+		require.NoError(t, set.Intersect(makeSet()))
+		assert.Zero(t, set.Length())
+	})
+}
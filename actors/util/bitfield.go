@@ -3,6 +3,7 @@ package util
 import (
 	"github.com/filecoin-project/go-bitfield"
 	"github.com/filecoin-project/go-bitfield/rle"
+	"golang.org/x/xerrors"
 )
 
 type BitField = bitfield.BitField
@@ -67,3 +68,64 @@ func BitFieldContainsAll(a, b BitField) (bool, error) {
 	}
 	return isEmpty(combined)
 }
+
+// Returns the bits of a bitfield in the range [start, end).
+func BitFieldRange(bf BitField, start, end uint64) (BitField, error) {
+	if end <= start {
+		return bitfield.New(), nil
+	}
+	runs, err := bf.RunIterator()
+	if err != nil {
+		return BitField{}, err
+	}
+	window := &rlepluslazy.RunSliceIterator{Runs: []rlepluslazy.Run{
+		{Val: false, Len: start},
+		{Val: true, Len: end - start},
+	}}
+	inRange, err := rlepluslazy.And(runs, window)
+	if err != nil {
+		return BitField{}, err
+	}
+	return bitfield.NewFromIter(inRange)
+}
+
+// Expands a bitfield into a slice of the set bits, in ascending order.
+// Returns an error wrapping bitfield.ErrBitFieldTooMany as soon as more than max bits are found, without
+// expanding any more of the bitfield.
+func BitFieldAllCapped(bf BitField, max uint64) ([]uint64, error) {
+	runs, err := bf.RunIterator()
+	if err != nil {
+		return nil, err
+	}
+	var out []uint64
+	pos := uint64(0)
+	for runs.HasNext() {
+		r, err := runs.NextRun()
+		if err != nil {
+			return nil, err
+		}
+		if r.Val {
+			if uint64(len(out))+r.Len > max {
+				return nil, xerrors.Errorf("more than %d bits set: %w", max, bitfield.ErrBitFieldTooMany)
+			}
+			for i := uint64(0); i < r.Len; i++ {
+				out = append(out, pos+i)
+			}
+		}
+		pos += r.Len
+	}
+	return out, nil
+}
+
+// Returns the bits which are set in exactly one of two bitfields.
+func BitFieldSymmetricDifference(a, b BitField) (BitField, error) {
+	aOnly, err := bitfield.SubtractBitField(a, b)
+	if err != nil {
+		return BitField{}, err
+	}
+	bOnly, err := bitfield.SubtractBitField(b, a)
+	if err != nil {
+		return BitField{}, err
+	}
+	return bitfield.MergeBitFields(aOnly, bOnly)
+}
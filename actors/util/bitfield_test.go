@@ -2,10 +2,12 @@ package util_test
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 
 	"github.com/filecoin-project/go-bitfield"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/specs-actors/v7/actors/util"
 )
@@ -94,3 +96,59 @@ func TestBitFieldContains(t *testing.T) {
 	assertContainsAll(b, c, false)
 	assertContainsAll(c, b, false)
 }
+
+func TestBitFieldRange(t *testing.T) {
+	bf := bitfield.NewFromSet([]uint64{1, 2, 3, 10, 11, 20, 100})
+
+	assertRange := func(start, end uint64, expected ...uint64) {
+		t.Helper()
+		r, err := util.BitFieldRange(bf, start, end)
+		require.NoError(t, err)
+		all, err := r.All(1000)
+		require.NoError(t, err)
+		if expected == nil {
+			expected = []uint64{}
+		}
+		assert.Equal(t, expected, all)
+	}
+	assertRange(0, 200, 1, 2, 3, 10, 11, 20, 100)
+	assertRange(2, 11, 2, 3, 10)
+	assertRange(4, 10)
+	assertRange(20, 21, 20)
+	assertRange(101, 1000)
+	assertRange(10, 10)
+	assertRange(11, 10)
+}
+
+func TestBitFieldAllCapped(t *testing.T) {
+	bf := bitfield.NewFromSet([]uint64{1, 2, 3, 10, 11, 20})
+
+	all, err := util.BitFieldAllCapped(bf, 6)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2, 3, 10, 11, 20}, all)
+
+	_, err = util.BitFieldAllCapped(bf, 5)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, bitfield.ErrBitFieldTooMany))
+
+	all, err = util.BitFieldAllCapped(bitfield.New(), 0)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func TestBitFieldSymmetricDifference(t *testing.T) {
+	a := bitfield.NewFromSet([]uint64{1, 2, 3, 10})
+	b := bitfield.NewFromSet([]uint64{2, 3, 4, 20})
+
+	diff, err := util.BitFieldSymmetricDifference(a, b)
+	require.NoError(t, err)
+	all, err := diff.All(100)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 4, 10, 20}, all)
+
+	diff, err = util.BitFieldSymmetricDifference(a, a)
+	require.NoError(t, err)
+	empty, err := diff.IsEmpty()
+	require.NoError(t, err)
+	assert.True(t, empty)
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin/reward"
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin/system"
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin/verifreg"
+	"github.com/filecoin-project/specs-actors/v7/actors/migration/engine"
 	"github.com/filecoin-project/specs-actors/v7/actors/util/smoothing"
 	"github.com/filecoin-project/specs-actors/v7/support/vm"
 )
@@ -95,12 +96,16 @@ func main() {
 	if err := gen.WriteTupleEncodersToFile("./actors/builtin/multisig/cbor_gen.go", "multisig",
 		// actor state
 		multisig.State{},
-		//multisig.Transaction{}, // Aliased from v0
+		multisig.Transaction{},
 		//multisig.ProposalHashData{}, // Aliased from v0
 		// method params and returns
 		// multisig.ConstructorParams{}, // Aliased from v2
 		//multisig.ProposeParams{}, // Aliased from v0
 		//multisig.ProposeReturn{}, // Aliased from v0
+		multisig.ProposeWithExpirationParams{},
+		multisig.ApproveBatchParams{},
+		multisig.ApproveBatchReturn{},
+		multisig.ApproveBatchResult{},
 		//multisig.AddSignerParams{}, // Aliased from v0
 		//multisig.RemoveSignerParams{}, // Aliased from v0
 		//multisig.TxnIDParams{}, // Aliased from v0
@@ -108,6 +113,9 @@ func main() {
 		//multisig.ChangeNumApprovalsThresholdParams{}, // Aliased from v0
 		//multisig.SwapSignerParams{}, // Aliased from v0
 		//multisig.LockBalanceParams{}, // Aliased from v0
+		multisig.SetSignerWeightsParams{},
+		multisig.WeightedSigner{},
+		multisig.SetSpendingLimitParams{},
 	); err != nil {
 		panic(err)
 	}
@@ -119,6 +127,8 @@ func main() {
 		// method params and returns
 		//paych.ConstructorParams{}, // Aliased from v0
 		// paych.UpdateChannelStateParams{}, // Aliased from v2
+		paych.UpdateChannelStateBatchParams{},
+		paych.SetWatcherParams{},
 		//paych.SignedVoucher{}, // Aliased from v0
 		//paych.ModVerifyParams{}, // Aliased from v0
 		// other types
@@ -132,6 +142,7 @@ func main() {
 		power.State{},
 		power.Claim{},
 		power.CronEvent{},
+		power.PowerSnapshot{},
 		// method params and returns
 		power.CreateMinerParams{},
 		//power.CreateMinerReturn{}, // Aliased from v0
@@ -149,7 +160,7 @@ func main() {
 		market.State{},
 		// method params and returns
 		//market.WithdrawBalanceParams{}, // Aliased from v0
-		// market.PublishStorageDealsParams{}, // Aliased from v0
+		market.PublishStorageDealsParams{},
 		market.PublishStorageDealsReturn{},
 		//market.ActivateDealsParams{}, // Aliased from v0
 		market.VerifyDealsForActivationParams{},
@@ -157,10 +168,20 @@ func main() {
 		market.SectorDataSpec{},
 		market.ComputeDataCommitmentParams{},
 		market.ComputeDataCommitmentReturn{},
+		market.SettleDealPaymentsParams{},
+		market.SettleDealPaymentsReturn{},
+		market.ActivateSignedDealsParams{},
+		market.ActivateSignedDealsReturn{},
+		market.EscrowAmount{},
+		market.AddBalancesParams{},
+		market.WithdrawBalancesParams{},
+		market.WithdrawBalancesReturn{},
+		market.PurgeExpiredProposalsParams{},
+		market.PurgeExpiredProposalsReturn{},
 		//market.OnMinerSectorsTerminateParams{}, // Aliased from v0
 		// other types
-		//market.DealProposal{}, // Aliased from v0
-		//market.ClientDealProposal{},
+		market.DealProposal{},
+		market.ClientDealProposal{},
 		market.SectorDeals{},
 		market.SectorWeights{},
 		market.DealState{},
@@ -181,6 +202,9 @@ func main() {
 		miner.SectorPreCommitInfo{},
 		miner.SectorOnChainInfo{},
 		miner.WorkerKeyChange{},
+		miner.BeneficiaryTerm{},
+		miner.PendingBeneficiaryChange{},
+		miner.SectorStats{},
 		miner.VestingFunds{},
 		miner.VestingFund{},
 		miner.WindowedPoSt{},
@@ -193,11 +217,17 @@ func main() {
 		//miner.ChangeMultiaddrsParams{}, // Aliased from v0
 		//miner.ProveCommitSectorParams{}, // Aliased from v0
 		miner.ProveCommitAggregateParams{},
-		//miner.ChangeWorkerAddressParams{},  // Aliased from v0
+		miner.ProveCommitWithSignedDealsParams{},
+		miner.ChangeWorkerAddressParams{},
+		miner.ChangeWorkerAddressWithPermissionsParams{},
+		miner.DisputeWindowedPoStSectorsParams{},
 		//miner.ExtendSectorExpirationParams{}, // Aliased from v0
 		//miner.DeclareFaultsParams{}, // Aliased from v0
 		//miner.DeclareFaultsRecoveredParams{}, // Aliased from v0
-		//miner.ReportConsensusFaultParams{}, // Aliased from v0
+		miner.ReportConsensusFaultParams{},
+		miner.DoubleForkMiningEvidence{},
+		miner.ParentGrindingEvidence{},
+		miner.TimeOffsetMiningEvidence{},
 		// miner.GetControlAddressesReturn{}, // Aliased from v2
 		//miner.CheckSectorProvenParams{}, // Aliased from v0
 		//miner.WithdrawBalanceParams{}, // Aliased from v0
@@ -206,6 +236,12 @@ func main() {
 		//miner.CronEventPayload{}, // Aliased from v0
 		// miner.DisputeWindowedPoStParams{}, // Aliased from v3
 		miner.PreCommitSectorBatchParams{},
+		miner.ExtendSectorExpirationBatchParams{},
+		miner.ExpirationExtensionGroup{},
+		miner.ChangeBeneficiaryParams{},
+		miner.CompactTerminatedSectorsParams{},
+		miner.DeclareFaultsAndRecoveriesParams{},
+		miner.RecommitSectorParams{},
 		// other types
 		//miner.FaultDeclaration{}, // Aliased from v0
 		//miner.RecoveryDeclaration{}, // Aliased from v0
@@ -224,7 +260,18 @@ func main() {
 		//verifreg.AddVerifiedClientParams{}, // Aliased from v0
 		//verifreg.UseBytesParams{}, // Aliased from v0
 		//verifreg.RestoreBytesParams{}, // Aliased from v0
+		verifreg.RemoveDataCapParams{},
+		verifreg.RemoveDataCapReturn{},
+		verifreg.RemoveExpiredDataCapParams{},
+		verifreg.RemoveExpiredDataCapReturn{},
+		verifreg.AddVerifierSubKeyParams{},
 		// other types
+		verifreg.RemoveDataCapProposal{},
+		verifreg.RemoveDataCapRequest{},
+		verifreg.RmDcProposalID{},
+		verifreg.AddrPairKey{},
+		verifreg.GrantEvent{},
+		verifreg.SubKey{},
 	); err != nil {
 		panic(err)
 	}
@@ -235,6 +282,15 @@ func main() {
 		panic(err)
 	}
 
+	if err := gen.WriteTupleEncodersToFile("./actors/migration/engine/cbor_gen.go", "engine",
+		engine.RollbackRecord{},
+		engine.DeletedActor{},
+		engine.BalanceTransfer{},
+		engine.RemovedClaim{},
+	); err != nil {
+		panic(err)
+	}
+
 	if err := gen.WriteTupleEncodersToFile("./support/vm/cbor_gen.go", "vm",
 		vm.ChainMessage{},
 		vm.StateInfo0{},
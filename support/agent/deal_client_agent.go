@@ -28,6 +28,7 @@ type DealClientConfig struct {
 	MaxStoragePrice  abi.TokenAmount // maximum price per epoch a client will pay for storage
 	MinMarketBalance abi.TokenAmount // balance below which client will top up funds in market actor
 	MaxMarketBalance abi.TokenAmount // balance to which client will top up funds in market actor
+	ClientCollateral abi.TokenAmount // collateral client locks in each deal, slashed per market policy on termination (may be nil)
 }
 
 type DealClientAgent struct {
@@ -145,15 +146,25 @@ func (dca *DealClientAgent) createDeal(s SimState, provider DealProvider) error
 		dealEnd = dealStart + market.DealMinDuration()
 	}
 
-	// lower expected balance in anticipation of market actor locking storage fee
+	// lower expected balance in anticipation of market actor locking storage fee and client collateral
 	storageFee := big.Mul(big.NewInt(int64(dealEnd-dealStart)), price)
+	clientCollateral := big.Zero()
+	if !dca.config.ClientCollateral.Nil() {
+		clientCollateral = dca.config.ClientCollateral
+	}
+	balanceRequirement := big.Add(storageFee, clientCollateral)
 
-	// if this client does not have enough balance for storage fee, just skip this deal
-	if dca.expectedMarketBalance.LessThan(storageFee) {
+	// if this client does not have enough balance for storage fee and collateral, just skip this deal
+	if dca.expectedMarketBalance.LessThan(balanceRequirement) {
 		return nil
 	}
 
-	dca.expectedMarketBalance = big.Sub(dca.expectedMarketBalance, storageFee)
+	label, err := market.NewLabelFromString(dca.account.String() + ":" + strconv.Itoa(dca.DealCount))
+	if err != nil {
+		return err
+	}
+
+	dca.expectedMarketBalance = big.Sub(dca.expectedMarketBalance, balanceRequirement)
 
 	proposal := market.DealProposal{
 		PieceCID:             pieceCid,
@@ -161,12 +172,12 @@ func (dca *DealClientAgent) createDeal(s SimState, provider DealProvider) error
 		VerifiedDeal:         false,
 		Client:               dca.account,
 		Provider:             provider.Address(),
-		Label:                dca.account.String() + ":" + strconv.Itoa(dca.DealCount),
+		Label:                label,
 		StartEpoch:           dealStart,
 		EndEpoch:             dealEnd,
 		StoragePricePerEpoch: price,
 		ProviderCollateral:   providerCollateral,
-		ClientCollateral:     big.Zero(),
+		ClientCollateral:     clientCollateral,
 	}
 
 	provider.CreateDeal(market.ClientDealProposal{
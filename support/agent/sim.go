@@ -23,6 +23,7 @@ import (
 	vm2 "github.com/filecoin-project/specs-actors/v2/support/vm"
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin"
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/v7/actors/builtin/miner"
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin/power"
 	"github.com/filecoin-project/specs-actors/v7/actors/builtin/reward"
 	"github.com/filecoin-project/specs-actors/v7/actors/states"
@@ -346,14 +347,18 @@ func (s *Sim) computePowerTable(v SimVM, agents []Agent) (PowerTable, error) {
 	pt.totalQAPower = st.TotalQualityAdjPower
 
 	for _, agent := range agents {
-		if miner, ok := agent.(*MinerAgent); ok {
-			if claim, found, err := st.GetClaim(v.Store(), miner.IDAddress); err != nil {
+		if ma, ok := agent.(*MinerAgent); ok {
+			if claim, found, err := st.GetClaim(v.Store(), ma.IDAddress); err != nil {
 				return pt, err
 			} else if found {
-				if sufficient, err := st.MinerNominalPowerMeetsConsensusMinimum(v.Store(), miner.IDAddress); err != nil {
+				// A miner is ineligible to win blocks for a period after it is penalized for a consensus fault.
+				if claim.ConsensusFaultCount > 0 && v.GetEpoch() <= claim.LastConsensusFaultEpoch+miner.ConsensusFaultIneligibilityDuration() {
+					continue
+				}
+				if sufficient, err := st.MinerNominalPowerMeetsConsensusMinimum(v.Store(), ma.IDAddress); err != nil {
 					return pt, err
 				} else if sufficient {
-					pt.minerPower = append(pt.minerPower, minerPowerTable{miner.IDAddress, claim.QualityAdjPower})
+					pt.minerPower = append(pt.minerPower, minerPowerTable{ma.IDAddress, claim.QualityAdjPower})
 				}
 			}
 		}
@@ -75,6 +75,7 @@ type Runtime struct {
 	expectVerifySeal               *expectVerifySeal
 	expectComputeUnsealedSectorCID []*expectComputeUnsealedSectorCID
 	expectVerifyPoSt               *expectVerifyPoSt
+	expectBatchVerifyPoSts         *expectBatchVerifyPoSts
 	expectVerifyConsensusFault     *expectVerifyConsensusFault
 	expectDeleteActor              *addr.Address
 	expectBatchVerifySeals         *expectBatchVerifySeals
@@ -144,6 +145,12 @@ type expectVerifyPoSt struct {
 	result error
 }
 
+type expectBatchVerifyPoSts struct {
+	posts  []proof.WindowPoStVerifyInfo
+	result []bool
+	err    error
+}
+
 func (m *expectedMessage) Equal(to addr.Address, method abi.MethodNum, params cbor.Marshaler, value abi.TokenAmount) bool {
 	// avoid nil vs. zero/empty discrepancies that would disappear in serialization
 	paramBuf1 := new(bytes.Buffer)
@@ -708,6 +715,24 @@ func (rt *Runtime) VerifyPoSt(vi proof.WindowPoStVerifyInfo) error {
 	return nil
 }
 
+func (rt *Runtime) BatchVerifyPoSts(vis []proof.WindowPoStVerifyInfo) ([]bool, error) {
+	exp := rt.expectBatchVerifyPoSts
+	if exp != nil {
+		if !reflect.DeepEqual(exp.posts, vis) {
+			rt.failTest("unexpected batch PoSt verification\n"+
+				"        : %v\n"+
+				"expected: %v",
+				vis, exp.posts)
+		}
+		defer func() {
+			rt.expectBatchVerifyPoSts = nil
+		}()
+		return exp.result, exp.err
+	}
+	rt.failTestNow("unexpected syscall to batch verify PoSts %v", vis)
+	return nil, nil
+}
+
 func (rt *Runtime) VerifyConsensusFault(h1, h2, extra []byte) (*runtime.ConsensusFault, error) {
 	if rt.expectVerifyConsensusFault == nil {
 		rt.failTestNow("Unexpected syscall VerifyConsensusFault")
@@ -942,6 +967,14 @@ func (rt *Runtime) ExpectVerifyPoSt(post proof.WindowPoStVerifyInfo, result erro
 	}
 }
 
+func (rt *Runtime) ExpectBatchVerifyPoSts(posts []proof.WindowPoStVerifyInfo, result []bool, err error) {
+	rt.expectBatchVerifyPoSts = &expectBatchVerifyPoSts{
+		posts:  posts,
+		result: result,
+		err:    err,
+	}
+}
+
 func (rt *Runtime) ExpectVerifyConsensusFault(h1, h2, extra []byte, result *runtime.ConsensusFault, resultErr error) {
 	rt.expectVerifyConsensusFault = &expectVerifyConsensusFault{
 		requireCorrectInput: true,
@@ -1005,6 +1038,10 @@ func (rt *Runtime) Verify() {
 		rt.failTest("missing expected PoSt verification with %v", rt.expectVerifyPoSt)
 	}
 
+	if rt.expectBatchVerifyPoSts != nil {
+		rt.failTest("missing expected batch PoSt verification with %v", rt.expectBatchVerifyPoSts)
+	}
+
 	if rt.expectVerifyConsensusFault != nil {
 		rt.failTest("missing expected verify consensus fault")
 	}
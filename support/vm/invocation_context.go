@@ -265,6 +265,12 @@ func (ic *invocationContext) VerifyPoSt(vi proof.WindowPoStVerifyInfo) error {
 	return ic.Syscalls().VerifyPoSt(vi)
 }
 
+func (ic *invocationContext) BatchVerifyPoSts(vis []proof.WindowPoStVerifyInfo) ([]bool, error) {
+	ic.topLevel.fakeSyscallsAccessed = true
+	ic.topLevel.chargeGas(ic.topLevel.gasPrices.OnBatchVerifyPost(vis))
+	return ic.Syscalls().BatchVerifyPoSts(vis)
+}
+
 func (ic *invocationContext) VerifyConsensusFault(h1, h2, extra []byte) (*runtime.ConsensusFault, error) {
 	ic.topLevel.fakeSyscallsAccessed = true
 	ic.topLevel.chargeGas(ic.topLevel.gasPrices.OnVerifyConsensusFault())
@@ -574,6 +580,14 @@ func (s fakeSyscalls) VerifyPoSt(_ proof.WindowPoStVerifyInfo) error {
 	return nil
 }
 
+func (s fakeSyscalls) BatchVerifyPoSts(vis []proof.WindowPoStVerifyInfo) ([]bool, error) {
+	verified := make([]bool, len(vis))
+	for i := range vis {
+		verified[i] = true
+	}
+	return verified, nil
+}
+
 func (s fakeSyscalls) VerifyConsensusFault(_, _, _ []byte) (*runtime.ConsensusFault, error) {
 	return &runtime.ConsensusFault{
 		Target: s.receiver,
@@ -34,6 +34,7 @@ type Pricelist interface {
 	OnComputeUnsealedSectorCid(proofType abi.RegisteredSealProof, pieces []abi.PieceInfo) GasCharge
 	OnVerifySeal(info proof.SealVerifyInfo) GasCharge
 	OnVerifyPost(info proof.WindowPoStVerifyInfo) GasCharge
+	OnBatchVerifyPost(infos []proof.WindowPoStVerifyInfo) GasCharge
 	OnVerifyConsensusFault() GasCharge
 }
 
@@ -276,6 +277,40 @@ func (pl *pricelist) OnVerifyPost(info proof.WindowPoStVerifyInfo) GasCharge {
 		})
 }
 
+// OnBatchVerifyPost charges the flat verification cost once for the whole batch, plus the per-sector cost
+// for every challenged sector.
+// The flat cost is dominated by the final pairing check, which a batch verifier performs once over a random
+// linear combination of the proofs. The per-sector cost, for deriving each sector's challenges and public
+// inputs, is unchanged by batching.
+func (pl *pricelist) OnBatchVerifyPost(infos []proof.WindowPoStVerifyInfo) GasCharge {
+	var proofType abi.RegisteredPoStProof
+	sectorCount := 0
+	for _, info := range infos {
+		if len(info.Proofs) != 0 {
+			proofType = info.Proofs[0].PoStProof
+		}
+		sectorCount += len(info.ChallengedSectors)
+	}
+
+	cost, ok := pl.verifyPostLookup[proofType]
+	if !ok {
+		cost = pl.verifyPostLookup[abi.RegisteredPoStProof_StackedDrgWindow512MiBV1]
+	}
+
+	gasUsed := cost.flat + int64(sectorCount)*cost.scale
+	if pl.verifyPostDiscount {
+		// Discounted as for a single proof, so that a batch of one costs the same as VerifyPoSt
+		// and a batch never costs more than verifying its proofs separately.
+		gasUsed /= 2
+	}
+
+	return newGasCharge("OnBatchVerifyPost", gasUsed, 0).
+		WithExtra(map[string]interface{}{
+			"count": len(infos),
+			"size":  sectorCount,
+		})
+}
+
 // OnVerifyConsensusFault
 func (pl *pricelist) OnVerifyConsensusFault() GasCharge {
 	return newGasCharge("OnVerifyConsensusFault", pl.verifyConsensusFault, 0)